@@ -43,6 +43,66 @@ pub struct SampleEvent {
 /// Sample types
 pub const SAMPLE_TYPE_ON_CPU: u8 = 1;
 pub const SAMPLE_TYPE_OFF_CPU: u8 = 2;
+/// Time spent runnable before getting a CPU, counted in microseconds
+pub const SAMPLE_TYPE_SCHED_LAT: u8 = 3;
+
+/// Time spent in slow network calls, counted in microseconds
+pub const SAMPLE_TYPE_NET: u8 = 4;
+
+/// Slots of the SAMPLE_STATS counters: samples lost because COUNTS was full,
+/// and user stacks STACK_TRACES could not store (full or hash collision)
+pub const STAT_COUNTS_FULL: u32 = 0;
+pub const STAT_STACK_LOST: u32 = 1;
+pub const SAMPLE_STAT_SLOTS: u32 = 2;
+
+/// Number of log2 microsecond buckets of the scheduling latency histogram
+pub const SCHED_LAT_SLOTS: u32 = 32;
+
+/// Address families of NetEndpoint
+pub const AF_INET: u16 = 2;
+pub const AF_INET6: u16 = 10;
+
+/// Remote end of a socket of the target process
+#[repr(C)]
+#[derive(Clone, Copy, Debug, Hash, PartialEq, Eq)]
+pub struct NetEndpoint {
+    /// AF_INET or AF_INET6
+    pub family: u16,
+    /// Port in host byte order
+    pub port: u16,
+    /// Padding for alignment (4 bytes)
+    pub _padding: [u8; 4],
+    /// IPv4 address in the first 4 bytes, or IPv6 address
+    pub addr: [u8; 16],
+}
+
+/// Network calls of the target process to one remote endpoint
+#[repr(C)]
+#[derive(Clone, Copy, Debug, Default)]
+pub struct NetStats {
+    /// Connections made or accepted
+    pub connections: u64,
+    /// Completed send and receive calls
+    pub calls: u64,
+    pub bytes_sent: u64,
+    pub bytes_received: u64,
+    /// Sum and maximum of the call latencies in nanoseconds
+    pub latency_ns: u64,
+    pub max_latency_ns: u64,
+}
+
+/// CPU of profile keys whose samples are not kept per CPU
+pub const CPU_UNSET: u16 = u16::MAX;
+
+/// TARGET_CGROUP values: no cgroup filtering
+pub const CGROUP_FILTER_OFF: u64 = 0;
+/// TARGET_CGROUP values: adopt the cgroup of the target process on its first sample
+pub const CGROUP_FILTER_LEARN: u64 = u64::MAX;
+
+/// PID_NAMESPACE slots: device and inode number of the PID namespace
+pub const PID_NS_DEV: u32 = 0;
+pub const PID_NS_INO: u32 = 1;
+pub const PID_NS_SLOTS: u32 = 2;
 
 /// Simplified profile key for eBPF to avoid verification issues
 #[repr(C)]
@@ -56,8 +116,13 @@ pub struct EbpfProfileKey {
     pub kernel_stack_id: i32,
     /// Sample type (on-cpu or off-cpu)
     pub sample_type: u8,
-    /// Padding for alignment (3 bytes)
-    pub _padding: [u8; 3],
+    /// Padding for alignment (1 byte)
+    pub _padding: u8,
+    /// CPU of an on-CPU sample kept per CPU, CPU_UNSET otherwise
+    pub cpu: u16,
+    /// Name of the thread the sample was taken on, zeroed unless samples are
+    /// kept per thread
+    pub comm: [u8; 16],
 }
 
 /// Complete profile aggregation key for userspace processing
@@ -72,10 +137,12 @@ pub struct ProfileKey {
     pub user_stack_id: i32,
     /// Kernel stack ID from stack trace map
     pub kernel_stack_id: i32,
-    /// Process name (TASK_COMM_LEN = 16)
+    /// Thread name (TASK_COMM_LEN = 16), zeroed unless samples are kept per thread
     pub name: [u8; 16],
     /// Sample type (on-cpu or off-cpu)
     pub sample_type: u8,
+    /// CPU of an on-CPU sample kept per CPU, CPU_UNSET otherwise
+    pub cpu: u16,
 }
 
 /// Golang runtime information
@@ -272,3 +339,7 @@ unsafe impl aya::Pod for FuncInfo {}
 unsafe impl aya::Pod for ProfileKey {}
 #[cfg(feature = "user")]
 unsafe impl aya::Pod for EbpfProfileKey {}
+#[cfg(feature = "user")]
+unsafe impl aya::Pod for NetEndpoint {}
+#[cfg(feature = "user")]
+unsafe impl aya::Pod for NetStats {}
@@ -2,13 +2,23 @@
 #![no_main]
 
 use aya_ebpf::{
-    helpers::{bpf_get_current_pid_tgid, bpf_ktime_get_ns},
+    bindings::bpf_pidns_info,
+    helpers::{
+        bpf_get_current_cgroup_id, bpf_get_current_comm, bpf_get_current_pid_tgid, bpf_ktime_get_ns,
+        gen::{bpf_get_ns_current_pid_tgid, bpf_get_smp_processor_id},
+    },
     macros::{map, perf_event, tracepoint},
     maps::{Array, HashMap, StackTrace},
     programs::{PerfEventContext, TracePointContext},
+    EbpfContext,
 };
 use aya_log_ebpf::info;
-use golang_profiling_common::{EbpfProfileKey, SAMPLE_TYPE_ON_CPU, SAMPLE_TYPE_OFF_CPU};
+use core::sync::atomic::{AtomicU64, Ordering};
+use golang_profiling_common::{
+    AF_INET, AF_INET6, CGROUP_FILTER_LEARN, CGROUP_FILTER_OFF, CPU_UNSET, EbpfProfileKey, NetEndpoint,
+    NetStats, PID_NS_DEV, PID_NS_INO, PID_NS_SLOTS, SAMPLE_STAT_SLOTS, SAMPLE_TYPE_NET, SAMPLE_TYPE_OFF_CPU, SAMPLE_TYPE_ON_CPU,
+    SAMPLE_TYPE_SCHED_LAT, SCHED_LAT_SLOTS, STAT_COUNTS_FULL, STAT_STACK_LOST,
+};
 use aya_ebpf::helpers::bpf_probe_read_user;
 
 // eBPF program metadata
@@ -23,6 +33,29 @@ static _version: u32 = 0xFFFFFFFE;
 // BPF constants
 const BPF_F_USER_STACK: u64 = 1 << 8;
 
+// Field offsets of the sched/sched_switch and sched/sched_wakeup tracepoints
+const SCHED_SWITCH_PREV_STATE: usize = 32;
+const SCHED_SWITCH_NEXT_PID: usize = 56;
+const SCHED_WAKEUP_PID: usize = 24;
+
+// Task state bits reported by sched_switch, a preempted task reports none of them
+const TASK_REPORT_MASK: u64 = 0xff;
+
+// Field offsets of the syscalls/sys_enter_* and syscalls/sys_exit_* tracepoints
+const SYS_ENTER_ARG0: usize = 16;
+const SYS_ENTER_ARG1: usize = 24;
+const SYS_EXIT_RET: usize = 16;
+
+// Errors of non-blocking sockets, Go's netpoller parks the goroutine on both
+const EAGAIN: i64 = 11;
+const EINPROGRESS: i64 = 115;
+
+// Network calls tracked between their sys_enter and sys_exit
+const NET_OP_CONNECT: u8 = 1;
+const NET_OP_ACCEPT: u8 = 2;
+const NET_OP_SEND: u8 = 3;
+const NET_OP_RECV: u8 = 4;
+
 // Optimized map sizes for better memory usage
 // Stack trace storage - reduced from 16384 to 8192 for memory efficiency
 #[map]
@@ -32,14 +65,104 @@ static STACK_TRACES: StackTrace = StackTrace::with_max_entries(8192, 0);
 #[map]
 static COUNTS: HashMap<EbpfProfileKey, u64> = HashMap::with_max_entries(16384, 0);
 
+// Sampling health counters, see STAT_COUNTS_FULL and STAT_STACK_LOST
+#[map]
+static SAMPLE_STATS: Array<u64> = Array::with_max_entries(SAMPLE_STAT_SLOTS, 0);
+
 // Target PID configuration - using Array for single value storage
 #[map]
 static TARGET_PID: Array<u32> = Array::with_max_entries(1, 0);
 
+// Processes to sample: the target PID and, with --include-children, its descendants
+#[map]
+static TARGET_PIDS: HashMap<u32, u8> = HashMap::with_max_entries(1024, 0);
+
+// cgroup v2 ID samples must belong to, see CGROUP_FILTER_OFF and CGROUP_FILTER_LEARN
+#[map]
+static TARGET_CGROUP: Array<u64> = Array::with_max_entries(1, 0);
+
+// PID namespace the target PIDs are given in, see PID_NS_DEV and PID_NS_INO.
+// Unset in the initial namespace; set when the profiler runs in a nested
+// container such as a kind or minikube node.
+#[map]
+static PID_NAMESPACE: Array<u64> = Array::with_max_entries(PID_NS_SLOTS, 0);
+
 // Process timestamps for off-CPU duration calculation
 #[map]
 static PROCESS_TIMESTAMPS: HashMap<u32, u64> = HashMap::with_max_entries(4096, 0);
 
+/// Last switch-out of a target thread, used to measure its run queue latency
+#[repr(C)]
+#[derive(Clone, Copy)]
+struct RunqEntry {
+    tgid: u32,
+    user_stack_id: i32,
+    kernel_stack_id: i32,
+    _padding: u32,
+    /// When the thread became runnable, 0 while it sleeps
+    runnable_ns: u64,
+}
+
+// Threads of the target keyed by thread ID, for scheduling latency
+#[map]
+static RUNQ: HashMap<u32, RunqEntry> = HashMap::with_max_entries(16384, 0);
+
+// Scheduling latency histogram, slot n counts waits of [2^n, 2^(n+1)) microseconds
+#[map]
+static SCHED_LAT_HIST: Array<u64> = Array::with_max_entries(SCHED_LAT_SLOTS, 0);
+
+/// Network call of a target thread between its sys_enter and sys_exit
+#[repr(C)]
+#[derive(Clone, Copy)]
+struct NetCall {
+    fd: u64,
+    op: u8,
+    _padding: [u8; 7],
+    start_ns: u64,
+    /// Remote address of connect, or where accept writes the peer address
+    endpoint: NetEndpoint,
+    addr_ptr: u64,
+}
+
+/// Socket of the target process with a known remote endpoint
+#[repr(C)]
+#[derive(Clone, Copy)]
+struct NetSocket {
+    endpoint: NetEndpoint,
+    /// Start of a wait the next completed call ends: a connect in progress or
+    /// a receive that found no response yet, 0 when none
+    wait_start_ns: u64,
+    /// Made by connect; waits on accepted sockets are the peer's idle time
+    outbound: u8,
+    /// Last completed NET_OP_* on the socket
+    last_op: u8,
+    _padding: [u8; 6],
+}
+
+// Calls in flight keyed by thread ID
+#[map]
+static NET_CALLS: HashMap<u32, NetCall> = HashMap::with_max_entries(16384, 0);
+
+// Sockets keyed by (tgid << 32 | fd)
+#[map]
+static NET_SOCKETS: HashMap<u64, NetSocket> = HashMap::with_max_entries(65536, 0);
+
+// Network statistics per remote endpoint
+#[map]
+static NET_STATS: HashMap<NetEndpoint, NetStats> = HashMap::with_max_entries(4096, 0);
+
+// Calls slower than this many nanoseconds get their stack recorded
+#[map]
+static NET_THRESHOLD: Array<u64> = Array::with_max_entries(1, 0);
+
+// Non-zero to key on-CPU samples by the CPU they were taken on
+#[map]
+static PER_CPU: Array<u8> = Array::with_max_entries(1, 0);
+
+// Non-zero to key samples by the name of the thread they were taken on
+#[map]
+static PER_THREAD: Array<u8> = Array::with_max_entries(1, 0);
+
 #[perf_event]
 pub fn golang_profile(ctx: PerfEventContext) -> u32 {
     match unsafe { try_golang_profile(ctx) } {
@@ -48,38 +171,114 @@ pub fn golang_profile(ctx: PerfEventContext) -> u32 {
     }
 }
 
-unsafe fn try_golang_profile(ctx: PerfEventContext) -> Result<u32, u32> {
-    let pid_tgid = bpf_get_current_pid_tgid();
-    let tgid = (pid_tgid >> 32) as u32;
+/// Check whether the current task belongs to the profiling target. Only PIDs
+/// in TARGET_PIDS are sampled, so an unconfigured profiler samples nothing
+/// instead of the whole host.
+#[inline(always)]
+/// pid_tgid of the current task as seen in PID_NAMESPACE, so that it compares
+/// with the PIDs userspace configured. Tasks outside of it read as 0.
+#[inline(always)]
+unsafe fn current_pid_tgid() -> u64 {
+    let ino = PID_NAMESPACE.get(PID_NS_INO).copied().unwrap_or(0);
+    if ino == 0 {
+        return bpf_get_current_pid_tgid();
+    }
+    let dev = PID_NAMESPACE.get(PID_NS_DEV).copied().unwrap_or(0);
+    let mut info = bpf_pidns_info { pid: 0, tgid: 0 };
+    let size = core::mem::size_of::<bpf_pidns_info>() as u32;
+    if bpf_get_ns_current_pid_tgid(dev, ino, &mut info, size) != 0 {
+        return 0;
+    }
+    ((info.tgid as u64) << 32) | info.pid as u64
+}
 
+unsafe fn is_target(tgid: u32) -> bool {
     // Skip idle process (PID 0)
     if tgid == 0 {
-        return Ok(0);
+        return false;
     }
-    
 
-    // Check if we have a target PID configured and filter accordingly
-    let target_pid = match TARGET_PID.get(0) {
-        Some(pid) => pid,
-        None => {
-            // No target PID configured, skip profiling
-            return Ok(0);
+    if TARGET_PIDS.get(&tgid).is_none() {
+        return false;
+    }
+
+    match TARGET_CGROUP.get(0).copied().unwrap_or(CGROUP_FILTER_OFF) {
+        CGROUP_FILTER_OFF => true,
+        CGROUP_FILTER_LEARN => {
+            // Only the target process itself may define the container cgroup
+            if TARGET_PID.get(0).copied() != Some(tgid) {
+                return false;
+            }
+            if let Some(cgroup) = TARGET_CGROUP.get_ptr_mut(0) {
+                *cgroup = bpf_get_current_cgroup_id();
+            }
+            true
         }
-    };
+        cgroup => bpf_get_current_cgroup_id() == cgroup,
+    }
+}
+
+/// Increments a SAMPLE_STATS counter
+#[inline(always)]
+unsafe fn count_stat(slot: u32) {
+    if let Some(counter) = SAMPLE_STATS.get_ptr_mut(slot) {
+        AtomicU64::from_ptr(counter).fetch_add(1, Ordering::Relaxed);
+    }
+}
+
+/// Adds to the count of a key, counting the samples lost to a full COUNTS map
+#[inline(always)]
+unsafe fn add_count(key: &EbpfProfileKey, value: u64) {
+    let count = COUNTS.get(key).copied().unwrap_or(0);
+    if COUNTS.insert(key, &(count + value), 0).is_err() {
+        count_stat(STAT_COUNTS_FULL);
+    }
+}
+
+/// Stores the user stack of the current task, -1 and counted when it could not be
+#[inline(always)]
+unsafe fn user_stack_id<C: EbpfContext>(ctx: &C) -> i32 {
+    match STACK_TRACES.get_stackid(ctx, BPF_F_USER_STACK) {
+        Ok(id) => id as i32,
+        Err(_) => {
+            count_stat(STAT_STACK_LOST);
+            -1
+        }
+    }
+}
+
+/// CPU an on-CPU sample is keyed by, CPU_UNSET unless samples are kept per CPU
+#[inline(always)]
+unsafe fn sample_cpu() -> u16 {
+    if PER_CPU.get(0).copied().unwrap_or(0) == 0 {
+        return CPU_UNSET;
+    }
+    bpf_get_smp_processor_id() as u16
+}
+
+/// Name of the current thread a sample is keyed by, zeroed unless samples are
+/// kept per thread
+#[inline(always)]
+unsafe fn sample_thread() -> [u8; 16] {
+    if PER_THREAD.get(0).copied().unwrap_or(0) == 0 {
+        return [0; 16];
+    }
+    bpf_get_current_comm().unwrap_or([0; 16])
+}
+
+unsafe fn try_golang_profile(ctx: PerfEventContext) -> Result<u32, u32> {
+    let pid_tgid = current_pid_tgid();
+    let tgid = (pid_tgid >> 32) as u32;
 
     // Debug output disabled for production use
-    // info!(&ctx, "Current PID: {}, Target PID: {}", tgid, *target_pid);
+    // info!(&ctx, "Current PID: {}", tgid);
 
-    // If target_pid is 0, it means no filtering (profile all processes)
-    // Otherwise, only profile the specified PID
-    if *target_pid != 0 && tgid != *target_pid {
+    if !is_target(tgid) {
         return Ok(0);
     }
 
     // Get stack traces
-    let user_stack_id = STACK_TRACES
-        .get_stackid(&ctx, BPF_F_USER_STACK)
-        .unwrap_or(-1) as i32;
+    let user_stack_id = user_stack_id(&ctx);
 
     let kernel_stack_id = STACK_TRACES.get_stackid(&ctx, 0).unwrap_or(-1) as i32;
 
@@ -89,12 +288,13 @@ unsafe fn try_golang_profile(ctx: PerfEventContext) -> Result<u32, u32> {
         user_stack_id,
         kernel_stack_id,
         sample_type: SAMPLE_TYPE_ON_CPU,
-        _padding: [0; 3],
+        _padding: 0,
+        cpu: sample_cpu(),
+        comm: sample_thread(),
     };
 
     // Increment count
-    let count = COUNTS.get(&key).copied().unwrap_or(0);
-    let _ = COUNTS.insert(&key, &(count + 1), 0);
+    add_count(&key, 1);
 
     Ok(0)
 }
@@ -108,26 +308,10 @@ pub fn sched_switch(ctx: TracePointContext) -> u32 {
 }
 
 unsafe fn try_sched_switch(ctx: TracePointContext) -> Result<u32, u32> {
-    let pid_tgid = bpf_get_current_pid_tgid();
+    let pid_tgid = current_pid_tgid();
     let prev_pid = (pid_tgid >> 32) as u32;
-    
-    // Skip idle process (PID 0)
-    if prev_pid == 0 {
-        return Ok(0);
-    }
-    
-    // Check if we have a target PID configured and filter accordingly
-    let target_pid = match TARGET_PID.get(0) {
-        Some(pid) => pid,
-        None => {
-            // No target PID configured, skip profiling
-            return Ok(0);
-        }
-    };
-    
-    // If target_pid is 0, it means no filtering (profile all processes)
-    // Otherwise, only profile the specified PID
-    if *target_pid != 0 && prev_pid != *target_pid {
+
+    if !is_target(prev_pid) {
         return Ok(0);
     }
     
@@ -140,9 +324,7 @@ unsafe fn try_sched_switch(ctx: TracePointContext) -> Result<u32, u32> {
         // Only record if duration is significant (> 1ms)
         if duration > 1_000_000 {
             // Get stack traces for the process being switched out
-            let user_stack_id = STACK_TRACES
-                .get_stackid(&ctx, BPF_F_USER_STACK)
-                .unwrap_or(-1) as i32;
+            let user_stack_id = user_stack_id(&ctx);
             
             let kernel_stack_id = STACK_TRACES.get_stackid(&ctx, 0).unwrap_or(-1) as i32;
             
@@ -152,13 +334,14 @@ unsafe fn try_sched_switch(ctx: TracePointContext) -> Result<u32, u32> {
                 user_stack_id,
                 kernel_stack_id,
                 sample_type: SAMPLE_TYPE_OFF_CPU,
-                _padding: [0; 3],
+                _padding: 0,
+                cpu: CPU_UNSET,
+                comm: sample_thread(),
             };
             
             // Use duration in microseconds as count (to avoid overflow)
             let duration_us = (duration / 1000) as u64;
-            let count = COUNTS.get(&key).copied().unwrap_or(0);
-            let _ = COUNTS.insert(&key, &(count + duration_us), 0);
+            add_count(&key, duration_us);
         }
         
         // Remove the timestamp entry
@@ -171,6 +354,417 @@ unsafe fn try_sched_switch(ctx: TracePointContext) -> Result<u32, u32> {
     Ok(0)
 }
 
+#[tracepoint]
+pub fn schedlat_switch(ctx: TracePointContext) -> u32 {
+    match unsafe { try_schedlat_switch(ctx) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+/// Remember where target threads leave the CPU and account the run queue
+/// latency of the thread switched in. Preempted threads stay runnable, so
+/// their wait starts right away; sleeping threads wait from their wakeup.
+unsafe fn try_schedlat_switch(ctx: TracePointContext) -> Result<u32, u32> {
+    let now = bpf_ktime_get_ns();
+    // RUNQ is keyed by the global thread ID the tracepoints report
+    let prev_tid = bpf_get_current_pid_tgid() as u32;
+    let prev_tgid = (current_pid_tgid() >> 32) as u32;
+
+    if is_target(prev_tgid) {
+        let prev_state: u64 = ctx.read_at(SCHED_SWITCH_PREV_STATE).map_err(|_| 1u32)?;
+        let entry = RunqEntry {
+            tgid: prev_tgid,
+            user_stack_id: user_stack_id(&ctx),
+            kernel_stack_id: STACK_TRACES.get_stackid(&ctx, 0).unwrap_or(-1) as i32,
+            _padding: 0,
+            runnable_ns: if prev_state & TASK_REPORT_MASK == 0 {
+                now
+            } else {
+                0
+            },
+        };
+        let _ = RUNQ.insert(&prev_tid, &entry, 0);
+    }
+
+    let next_tid: u32 = ctx.read_at(SCHED_SWITCH_NEXT_PID).map_err(|_| 1u32)?;
+    let entry = match RUNQ.get_ptr_mut(&next_tid) {
+        Some(entry) => entry,
+        None => return Ok(0),
+    };
+    let runnable_ns = (*entry).runnable_ns;
+    if runnable_ns == 0 || now < runnable_ns {
+        return Ok(0);
+    }
+    (*entry).runnable_ns = 0;
+
+    let latency_us = (now - runnable_ns) / 1000;
+    let key = EbpfProfileKey {
+        pid: (*entry).tgid,
+        user_stack_id: (*entry).user_stack_id,
+        kernel_stack_id: (*entry).kernel_stack_id,
+        sample_type: SAMPLE_TYPE_SCHED_LAT,
+        _padding: 0,
+        cpu: CPU_UNSET,
+        comm: [0; 16],
+    };
+    add_count(&key, latency_us);
+
+    let slot = log2(latency_us).min(SCHED_LAT_SLOTS - 1);
+    if let Some(bucket) = SCHED_LAT_HIST.get_ptr_mut(slot) {
+        AtomicU64::from_ptr(bucket).fetch_add(1, Ordering::Relaxed);
+    }
+
+    Ok(0)
+}
+
+#[tracepoint]
+pub fn schedlat_wakeup(ctx: TracePointContext) -> u32 {
+    match unsafe { try_schedlat_wakeup(ctx) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+/// Start the wait of a target thread that is woken up. Only threads seen
+/// leaving the CPU are tracked, the waker runs in another task's context.
+unsafe fn try_schedlat_wakeup(ctx: TracePointContext) -> Result<u32, u32> {
+    let tid: u32 = ctx.read_at(SCHED_WAKEUP_PID).map_err(|_| 1u32)?;
+    if let Some(entry) = RUNQ.get_ptr_mut(&tid) {
+        if (*entry).runnable_ns == 0 {
+            (*entry).runnable_ns = bpf_ktime_get_ns();
+        }
+    }
+    Ok(0)
+}
+
+#[tracepoint]
+pub fn net_connect_enter(ctx: TracePointContext) -> u32 {
+    match unsafe { try_net_connect_enter(ctx) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+unsafe fn try_net_connect_enter(ctx: TracePointContext) -> Result<u32, u32> {
+    let pid_tgid = current_pid_tgid();
+    if !is_target((pid_tgid >> 32) as u32) {
+        return Ok(0);
+    }
+    let fd: u64 = ctx.read_at(SYS_ENTER_ARG0).map_err(|_| 1u32)?;
+    let addr_ptr: u64 = ctx.read_at(SYS_ENTER_ARG1).map_err(|_| 1u32)?;
+    let endpoint = match read_sockaddr(addr_ptr) {
+        Some(endpoint) => endpoint,
+        None => return Ok(0),
+    };
+    let call = NetCall {
+        fd,
+        op: NET_OP_CONNECT,
+        _padding: [0; 7],
+        start_ns: bpf_ktime_get_ns(),
+        endpoint,
+        addr_ptr: 0,
+    };
+    let _ = NET_CALLS.insert(&(pid_tgid as u32), &call, 0);
+    Ok(0)
+}
+
+#[tracepoint]
+pub fn net_accept_enter(ctx: TracePointContext) -> u32 {
+    match unsafe { try_net_accept_enter(ctx) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+unsafe fn try_net_accept_enter(ctx: TracePointContext) -> Result<u32, u32> {
+    let pid_tgid = current_pid_tgid();
+    if !is_target((pid_tgid >> 32) as u32) {
+        return Ok(0);
+    }
+    let addr_ptr: u64 = ctx.read_at(SYS_ENTER_ARG1).map_err(|_| 1u32)?;
+    if addr_ptr == 0 {
+        return Ok(0);
+    }
+    let call = NetCall {
+        fd: 0,
+        op: NET_OP_ACCEPT,
+        _padding: [0; 7],
+        start_ns: bpf_ktime_get_ns(),
+        endpoint: EMPTY_ENDPOINT,
+        addr_ptr,
+    };
+    let _ = NET_CALLS.insert(&(pid_tgid as u32), &call, 0);
+    Ok(0)
+}
+
+#[tracepoint]
+pub fn net_send_enter(ctx: TracePointContext) -> u32 {
+    match unsafe { try_net_io_enter(ctx, NET_OP_SEND) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+#[tracepoint]
+pub fn net_recv_enter(ctx: TracePointContext) -> u32 {
+    match unsafe { try_net_io_enter(ctx, NET_OP_RECV) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+/// Track reads and writes of the target on sockets with a known remote
+/// endpoint; files and pipes go through the same syscalls and are skipped
+unsafe fn try_net_io_enter(ctx: TracePointContext, op: u8) -> Result<u32, u32> {
+    let pid_tgid = current_pid_tgid();
+    let tgid = (pid_tgid >> 32) as u32;
+    if !is_target(tgid) {
+        return Ok(0);
+    }
+    let fd: u64 = ctx.read_at(SYS_ENTER_ARG0).map_err(|_| 1u32)?;
+    if NET_SOCKETS.get(&socket_key(tgid, fd)).is_none() {
+        return Ok(0);
+    }
+    let call = NetCall {
+        fd,
+        op,
+        _padding: [0; 7],
+        start_ns: bpf_ktime_get_ns(),
+        endpoint: EMPTY_ENDPOINT,
+        addr_ptr: 0,
+    };
+    let _ = NET_CALLS.insert(&(pid_tgid as u32), &call, 0);
+    Ok(0)
+}
+
+#[tracepoint]
+pub fn net_close_enter(ctx: TracePointContext) -> u32 {
+    match unsafe { try_net_close_enter(ctx) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+unsafe fn try_net_close_enter(ctx: TracePointContext) -> Result<u32, u32> {
+    let tgid = (current_pid_tgid() >> 32) as u32;
+    if !is_target(tgid) {
+        return Ok(0);
+    }
+    let fd: u64 = ctx.read_at(SYS_ENTER_ARG0).map_err(|_| 1u32)?;
+    let _ = NET_SOCKETS.remove(&socket_key(tgid, fd));
+    Ok(0)
+}
+
+#[tracepoint]
+pub fn net_exit(ctx: TracePointContext) -> u32 {
+    match unsafe { try_net_exit(ctx) } {
+        Ok(ret) => ret,
+        Err(ret) => ret,
+    }
+}
+
+/// Complete the call started by one of the net_*_enter programs. Go sockets
+/// are non-blocking: a connect returns EINPROGRESS and a receive without data
+/// EAGAIN while the goroutine waits in the netpoller, so on outbound sockets
+/// the latency runs from the start of that wait to the next call that
+/// completes. On accepted sockets it is the duration of the syscall.
+unsafe fn try_net_exit(ctx: TracePointContext) -> Result<u32, u32> {
+    let pid_tgid = current_pid_tgid();
+    let tid = pid_tgid as u32;
+    let tgid = (pid_tgid >> 32) as u32;
+    let call = match NET_CALLS.get(&tid) {
+        Some(call) => *call,
+        None => return Ok(0),
+    };
+    let _ = NET_CALLS.remove(&tid);
+    let ret: i64 = ctx.read_at(SYS_EXIT_RET).map_err(|_| 1u32)?;
+    let now = bpf_ktime_get_ns();
+
+    match call.op {
+        NET_OP_CONNECT => {
+            if ret != 0 && ret != -EINPROGRESS {
+                return Ok(0);
+            }
+            let socket = NetSocket {
+                endpoint: call.endpoint,
+                wait_start_ns: if ret == 0 { 0 } else { call.start_ns },
+                outbound: 1,
+                last_op: NET_OP_CONNECT,
+                _padding: [0; 6],
+            };
+            let _ = NET_SOCKETS.insert(&socket_key(tgid, call.fd), &socket, 0);
+            if let Some(stats) = net_stats(&call.endpoint) {
+                AtomicU64::from_ptr(&mut (*stats).connections).fetch_add(1, Ordering::Relaxed);
+            }
+        }
+        NET_OP_ACCEPT => {
+            if ret < 0 {
+                return Ok(0);
+            }
+            let endpoint = match read_sockaddr(call.addr_ptr) {
+                Some(endpoint) => endpoint,
+                None => return Ok(0),
+            };
+            let socket = NetSocket {
+                endpoint,
+                wait_start_ns: 0,
+                outbound: 0,
+                last_op: NET_OP_ACCEPT,
+                _padding: [0; 6],
+            };
+            let _ = NET_SOCKETS.insert(&socket_key(tgid, ret as u64), &socket, 0);
+            if let Some(stats) = net_stats(&endpoint) {
+                AtomicU64::from_ptr(&mut (*stats).connections).fetch_add(1, Ordering::Relaxed);
+            }
+        }
+        _ => {
+            let socket = match NET_SOCKETS.get_ptr_mut(&socket_key(tgid, call.fd)) {
+                Some(socket) => socket,
+                None => return Ok(0),
+            };
+            if ret == -EAGAIN {
+                // Waiting for the response to a request sent on the socket
+                if call.op == NET_OP_RECV
+                    && (*socket).outbound != 0
+                    && (*socket).last_op == NET_OP_SEND
+                    && (*socket).wait_start_ns == 0
+                {
+                    (*socket).wait_start_ns = call.start_ns;
+                }
+                return Ok(0);
+            }
+            if ret < 0 {
+                return Ok(0);
+            }
+
+            let mut start_ns = call.start_ns;
+            let wait_start_ns = (*socket).wait_start_ns;
+            if wait_start_ns != 0 && wait_start_ns < start_ns {
+                start_ns = wait_start_ns;
+            }
+            (*socket).wait_start_ns = 0;
+            (*socket).last_op = call.op;
+            let latency_ns = now - start_ns;
+
+            if let Some(stats) = net_stats(&(*socket).endpoint) {
+                AtomicU64::from_ptr(&mut (*stats).calls).fetch_add(1, Ordering::Relaxed);
+                let bytes = if call.op == NET_OP_SEND {
+                    &mut (*stats).bytes_sent
+                } else {
+                    &mut (*stats).bytes_received
+                };
+                AtomicU64::from_ptr(bytes).fetch_add(ret as u64, Ordering::Relaxed);
+                AtomicU64::from_ptr(&mut (*stats).latency_ns)
+                    .fetch_add(latency_ns, Ordering::Relaxed);
+                // Racing updates may lose a maximum, acceptable for a report
+                if latency_ns > (*stats).max_latency_ns {
+                    (*stats).max_latency_ns = latency_ns;
+                }
+            }
+
+            let threshold_ns = NET_THRESHOLD.get(0).copied().unwrap_or(0);
+            if latency_ns >= threshold_ns {
+                let key = EbpfProfileKey {
+                    pid: tgid,
+                    user_stack_id: user_stack_id(&ctx),
+                    kernel_stack_id: STACK_TRACES.get_stackid(&ctx, 0).unwrap_or(-1) as i32,
+                    sample_type: SAMPLE_TYPE_NET,
+                    _padding: 0,
+                    cpu: CPU_UNSET,
+                    comm: sample_thread(),
+                };
+                add_count(&key, latency_ns / 1000);
+            }
+        }
+    }
+    Ok(0)
+}
+
+const EMPTY_ENDPOINT: NetEndpoint = NetEndpoint {
+    family: 0,
+    port: 0,
+    _padding: [0; 4],
+    addr: [0; 16],
+};
+
+/// Key of a socket in NET_SOCKETS
+#[inline(always)]
+fn socket_key(tgid: u32, fd: u64) -> u64 {
+    ((tgid as u64) << 32) | (fd & 0xffff_ffff)
+}
+
+/// Statistics of an endpoint, created on first use
+#[inline(always)]
+unsafe fn net_stats(endpoint: &NetEndpoint) -> Option<*mut NetStats> {
+    if let Some(stats) = NET_STATS.get_ptr_mut(endpoint) {
+        return Some(stats);
+    }
+    let _ = NET_STATS.insert(endpoint, &NetStats::default(), 0);
+    NET_STATS.get_ptr_mut(endpoint)
+}
+
+/// Read an IPv4 or IPv6 socket address from user memory. Other families,
+/// such as Unix sockets, return None. Only the size of the family is read,
+/// a sockaddr_in may end right before an unmapped page.
+#[inline(always)]
+unsafe fn read_sockaddr(ptr: u64) -> Option<NetEndpoint> {
+    if ptr == 0 {
+        return None;
+    }
+    let family: u16 = bpf_probe_read_user(ptr as *const u16).ok()?;
+    let port: u16 = bpf_probe_read_user((ptr + 2) as *const u16).ok()?;
+    let mut endpoint = NetEndpoint {
+        family,
+        port: u16::from_be(port),
+        _padding: [0; 4],
+        addr: [0; 16],
+    };
+    match family {
+        // struct sockaddr_in: the address follows the port
+        AF_INET => {
+            let addr: [u8; 4] = bpf_probe_read_user((ptr + 4) as *const [u8; 4]).ok()?;
+            endpoint.addr[..4].copy_from_slice(&addr);
+        }
+        // struct sockaddr_in6: the address follows the port and flow info
+        AF_INET6 => {
+            endpoint.addr = bpf_probe_read_user((ptr + 8) as *const [u8; 16]).ok()?;
+        }
+        _ => return None,
+    }
+    Some(endpoint)
+}
+
+/// Integer log2 without loops, which the verifier would have to unroll
+#[inline(always)]
+fn log2(mut v: u64) -> u32 {
+    let mut r = 0;
+    if v >= 1 << 32 {
+        v >>= 32;
+        r += 32;
+    }
+    if v >= 1 << 16 {
+        v >>= 16;
+        r += 16;
+    }
+    if v >= 1 << 8 {
+        v >>= 8;
+        r += 8;
+    }
+    if v >= 1 << 4 {
+        v >>= 4;
+        r += 4;
+    }
+    if v >= 1 << 2 {
+        v >>= 2;
+        r += 2;
+    }
+    if v >= 1 << 1 {
+        r += 1;
+    }
+    r
+}
+
 // Optimized for aya framework - minimal eBPF program
 
 #[cfg(not(test))]
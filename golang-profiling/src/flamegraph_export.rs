@@ -1,4 +1,4 @@
-use crate::symbol_resolver::SymbolResolver;
+use crate::symbol_resolver::ProcessResolvers;
 use anyhow::Result;
 use std::collections::HashMap;
 use std::fs::File;
@@ -6,6 +6,15 @@ use std::io::{self, Write};
 use std::path::Path;
 use std::time::{SystemTime, UNIX_EPOCH};
 
+/// Where the samples of a stack were taken: the process, and the CPU and the
+/// thread when samples are kept per CPU or per thread
+#[derive(Clone, Debug, Hash, PartialEq, Eq)]
+pub struct SampleOrigin {
+    pub pid: u32,
+    pub cpu: Option<u16>,
+    pub thread: Option<String>,
+}
+
 /// Export performance data in formats compatible with external flame graph tools
 pub struct FlameGraphExporter {
     // We'll create the symbol resolver when needed since it requires runtime info
@@ -19,27 +28,37 @@ impl FlameGraphExporter {
     /// Export data in Brendan Gregg's FlameGraph format (folded stacks)
     pub fn export_folded_stacks(
         &self,
-        aggregated_data: &HashMap<Vec<u64>, u64>,
+        aggregated_data: &HashMap<(SampleOrigin, Vec<u64>), u64>,
         output_path: &Path,
-        symbol_resolver: &SymbolResolver,
+        resolvers: &ProcessResolvers,
     ) -> Result<()> {
         let mut file = File::create(output_path)
             .map_err(|e| anyhow::anyhow!("Failed to create file: {}", e))?;
 
-        for (stack, count) in aggregated_data {
-            let mut stack_str = String::new();
+        for ((origin, stack), count) in aggregated_data {
+            let stack_str = Self::fold_stack(origin, stack, resolvers);
 
-            // Build the stack trace string in reverse order (leaf to root)
-            for (i, &pc) in stack.iter().rev().enumerate() {
-                if i > 0 {
-                    stack_str.push(';');
-                }
+            // Write the folded stack line: "stack_trace count"
+            writeln!(file, "{} {}", stack_str, count)
+                .map_err(|e| anyhow::anyhow!("Failed to write to file: {}", e))?;
+        }
 
-                let symbol = symbol_resolver.resolve_pc(pc);
-                stack_str.push_str(&symbol);
-            }
+        Ok(())
+    }
+
+    /// Export time-ordered folded stacks without merging, for flamegraph.pl --flamechart
+    pub fn export_flamechart_stacks(
+        &self,
+        timeline: &[(u64, SampleOrigin, Vec<u64>, u64)],
+        output_path: &Path,
+        resolvers: &ProcessResolvers,
+    ) -> Result<()> {
+        let mut file = File::create(output_path)
+            .map_err(|e| anyhow::anyhow!("Failed to create file: {}", e))?;
+
+        for (_, origin, stack, count) in timeline {
+            let stack_str = Self::fold_stack(origin, stack, resolvers);
 
-            // Write the folded stack line: "stack_trace count"
             writeln!(file, "{} {}", stack_str, count)
                 .map_err(|e| anyhow::anyhow!("Failed to write to file: {}", e))?;
         }
@@ -47,12 +66,53 @@ impl FlameGraphExporter {
         Ok(())
     }
 
+    /// Export the timeline format: "offset_ms stack_trace count", one line per
+    /// stack and polling interval, in time order
+    pub fn export_timeline(
+        &self,
+        timeline: &[(u64, SampleOrigin, Vec<u64>, u64)],
+        output_path: &Path,
+        resolvers: &ProcessResolvers,
+    ) -> Result<()> {
+        let mut file = File::create(output_path)
+            .map_err(|e| anyhow::anyhow!("Failed to create file: {}", e))?;
+
+        for (offset_ms, origin, stack, count) in timeline {
+            let stack_str = Self::fold_stack(origin, stack, resolvers);
+
+            writeln!(file, "{} {} {}", offset_ms, stack_str, count)
+                .map_err(|e| anyhow::anyhow!("Failed to write to file: {}", e))?;
+        }
+
+        Ok(())
+    }
+
+    /// Build the folded stack string root first from a leaf first stack. Stacks
+    /// of child processes are rooted at a frame naming the process, stacks kept
+    /// per CPU at a cpuN frame below it and stacks kept per thread at a
+    /// thread:name frame above it.
+    fn fold_stack(origin: &SampleOrigin, stack: &[u64], resolvers: &ProcessResolvers) -> String {
+        let mut frames: Vec<String> = origin
+            .cpu
+            .map(|cpu| format!("cpu{}", cpu))
+            .into_iter()
+            .collect();
+        frames.extend(resolvers.process_frame(origin.pid));
+        frames.extend(origin.thread.as_ref().map(|name| format!("thread:{}", name)));
+
+        for &pc in stack.iter().rev() {
+            frames.push(resolvers.resolve_pc(origin.pid, pc));
+        }
+
+        frames.join(";")
+    }
+
     /// Export data in perf script format
     pub fn export_perf_script(
         &self,
-        aggregated_data: &HashMap<Vec<u64>, u64>,
+        aggregated_data: &HashMap<(SampleOrigin, Vec<u64>), u64>,
         output_path: &Path,
-        symbol_resolver: &SymbolResolver,
+        resolvers: &ProcessResolvers,
     ) -> Result<()> {
         let mut file = File::create(output_path)
             .map_err(|e| anyhow::anyhow!("Failed to create file: {}", e))?;
@@ -86,20 +146,22 @@ impl FlameGraphExporter {
 
         let mut sample_id = 1;
 
-        for (stack, count) in aggregated_data {
+        for ((origin, stack), count) in aggregated_data {
             // Simulate multiple samples for the count
             for _ in 0..*count {
                 writeln!(
                     file,
-                    "golang-profile {} [000] {:.6}: cycles:",
+                    "{} {} [{:03}] {:.6}: cycles:",
+                    origin.thread.as_deref().unwrap_or("golang-profile"),
                     sample_id,
+                    origin.cpu.unwrap_or(0),
                     sample_id as f64 / 1000000.0
                 )
                 .map_err(|e| anyhow::anyhow!("Failed to write to file: {}", e))?;
 
                 // Write stack trace (from leaf to root)
-                for &pc in stack.iter().rev() {
-                    let symbol = symbol_resolver.resolve_pc(pc);
+                for &pc in stack {
+                    let symbol = resolvers.resolve_pc(origin.pid, pc);
                     writeln!(file, "\t{:016x} {}", pc, symbol)
                         .map_err(|e| anyhow::anyhow!("Failed to write to file: {}", e))?;
                 }
@@ -9,6 +9,66 @@ use std::{
     path::Path,
 };
 
+/// Magic at the start of the .go.buildinfo section
+const BUILD_INFO_MAGIC: &[u8] = b"\xff Go buildinf:";
+
+/// Size of the .go.buildinfo header, the inline strings follow it
+const BUILD_INFO_HEADER_SIZE: usize = 32;
+
+/// Header flag of Go 1.18+ binaries, which store the strings inline instead
+/// of pointing at them
+const BUILD_INFO_FLAG_INLINE: u8 = 0x2;
+
+/// Read the Go version and module information embedded in the executable of
+/// a process, in the text form of `go version -m`: a "go" line followed by
+/// the path, mod, dep and build lines. Returns None for binaries built before
+/// Go 1.18 or without a .go.buildinfo section.
+pub fn read_build_info(pid: u32) -> Result<Option<String>> {
+    let exe_path = format!("/proc/{}/exe", pid);
+    let file = File::open(&exe_path)
+        .map_err(|e| anyhow!("Failed to open executable {}: {}", exe_path, e))?;
+    let mmap = unsafe { Mmap::map(&file)? };
+    let obj = object::File::parse(&*mmap)?;
+    let Some(section) = obj.section_by_name(".go.buildinfo") else {
+        return Ok(None);
+    };
+    Ok(parse_build_info(section.data()?))
+}
+
+/// Parse the inline strings of a .go.buildinfo section
+fn parse_build_info(data: &[u8]) -> Option<String> {
+    if data.len() < BUILD_INFO_HEADER_SIZE
+        || !data.starts_with(BUILD_INFO_MAGIC)
+        || data[15] & BUILD_INFO_FLAG_INLINE == 0
+    {
+        return None;
+    }
+    let (version, rest) = read_varint_string(&data[BUILD_INFO_HEADER_SIZE..])?;
+    let (mut module, _) = read_varint_string(rest)?;
+    // The module information is framed by 16 byte sentinels
+    if module.len() >= 33 && module[module.len() - 17] == b'\n' {
+        module = &module[16..module.len() - 16];
+    }
+    Some(format!(
+        "go\t{}\n{}",
+        String::from_utf8_lossy(version),
+        String::from_utf8_lossy(module)
+    ))
+}
+
+/// Split a uvarint length prefixed string off the front of data
+fn read_varint_string(data: &[u8]) -> Option<(&[u8], &[u8])> {
+    let mut len: usize = 0;
+    for (i, &byte) in data.iter().enumerate().take(10) {
+        len |= ((byte & 0x7f) as usize) << (7 * i);
+        if byte & 0x80 == 0 {
+            let end = (i + 1).checked_add(len)?;
+            return (end <= data.len()).then(|| (&data[i + 1..end], &data[end..]));
+        }
+    }
+    None
+}
+
 /// Parser for Go runtime information
 pub struct GoRuntimeParser {
     // Cache for parsed runtime info
@@ -346,6 +406,35 @@ mod tests {
         assert!(runtime_info.func_tab_base >= original_base);
     }
 
+    #[test]
+    fn test_parse_build_info() {
+        let sentinel = [0u8; 16];
+        let mut module = sentinel.to_vec();
+        module.extend_from_slice(
+            b"path\texample.com/app\nmod\texample.com/app\t(devel)\t\nbuild\tvcs.revision=abc123\n",
+        );
+        module.extend_from_slice(&sentinel);
+
+        let mut data = BUILD_INFO_MAGIC.to_vec();
+        data.extend_from_slice(&[8, BUILD_INFO_FLAG_INLINE]);
+        data.resize(BUILD_INFO_HEADER_SIZE, 0);
+        data.push(8);
+        data.extend_from_slice(b"go1.22.4");
+        data.push(module.len() as u8);
+        data.extend_from_slice(&module);
+
+        let info = parse_build_info(&data).unwrap();
+        assert_eq!(
+            info,
+            "go\tgo1.22.4\npath\texample.com/app\nmod\texample.com/app\t(devel)\t\nbuild\tvcs.revision=abc123\n"
+        );
+
+        // Pointer based headers of Go < 1.18 are not supported
+        data[15] = 0;
+        assert_eq!(parse_build_info(&data), None);
+        assert_eq!(parse_build_info(b"short"), None);
+    }
+
     #[test]
     fn test_new_parser() {
         let parser = GoRuntimeParser::new();
@@ -7,13 +7,19 @@ use aya::{
 };
 use aya_log::EbpfLogger;
 use clap::Parser;
-use golang_profiling_common::{EbpfProfileKey, GoRuntimeInfo, ProfileKey, SAMPLE_TYPE_ON_CPU, SAMPLE_TYPE_OFF_CPU};
+use golang_profiling_common::{
+    AF_INET, CGROUP_FILTER_LEARN, CGROUP_FILTER_OFF, CPU_UNSET, EbpfProfileKey, GoRuntimeInfo, NetEndpoint,
+    MAX_STACK_DEPTH, NetStats, PID_NS_DEV, PID_NS_INO, ProfileKey, SAMPLE_TYPE_NET, SAMPLE_TYPE_OFF_CPU,
+    SAMPLE_TYPE_ON_CPU, SAMPLE_TYPE_SCHED_LAT, SCHED_LAT_SLOTS, STAT_COUNTS_FULL,
+    STAT_STACK_LOST,
+};
 use log::{error, info, warn};
 use std::{
-    collections::HashMap,
+    collections::{HashMap, HashSet},
     convert::TryInto,
     fs,
     io::Write,
+    net::{Ipv4Addr, Ipv6Addr},
     path::PathBuf,
     process,
     sync::{
@@ -27,6 +33,9 @@ use tokio::{signal, time};
 // Embed the flamegraph.pl script at compile time
 const FLAMEGRAPH_SCRIPT: &str = include_str!("../../flamegraph.pl");
 
+// Inode number of the initial PID namespace
+const PROC_PID_INIT_INO: u64 = 0xEFFF_FFFC;
+
 mod dwarf_parser;
 mod elfgopclntab;
 mod flamegraph_export;
@@ -34,9 +43,9 @@ mod golang_parser;
 mod symbol_resolver;
 
 use dwarf_parser::DwarfParser;
-use flamegraph_export::FlameGraphExporter;
+use flamegraph_export::{FlameGraphExporter, SampleOrigin};
 use golang_parser::GoRuntimeParser;
-use symbol_resolver::SymbolResolver;
+use symbol_resolver::{ProcessResolvers, SymbolResolver};
 
 #[derive(Parser, Debug)]
 #[command(name = "golang-profiling")]
@@ -62,6 +71,31 @@ struct Args {
     #[arg(long)]
     off_cpu: bool,
 
+    /// Measure how long the target's threads wait runnable before getting a
+    /// CPU, instead of sampling on-CPU stacks. The flame graph shows the
+    /// waiting stacks weighted by microseconds of latency.
+    #[arg(long, conflicts_with = "off_cpu")]
+    sched_latency: bool,
+
+    /// Export the scheduling latency histogram ("<low_us> <high_us> <count>")
+    #[arg(long, requires = "sched_latency")]
+    export_histogram: Option<PathBuf>,
+
+    /// Trace the target's connects, accepts, sends and receives on TCP/UDP
+    /// sockets instead of sampling on-CPU stacks. The flame graph shows the
+    /// stacks of slow calls weighted by microseconds of latency.
+    #[arg(long, conflicts_with_all = ["off_cpu", "sched_latency"])]
+    net: bool,
+
+    /// Network calls at least this slow get their stack recorded
+    #[arg(long, default_value = "1000", requires = "net")]
+    net_threshold_us: u64,
+
+    /// Export per remote endpoint statistics ("<remote> <connections> <calls>
+    /// <bytes_sent> <bytes_received> <total_us> <max_us>")
+    #[arg(long, requires = "net")]
+    export_net: Option<PathBuf>,
+
     /// Sampling frequency in Hz
     #[arg(short, long, default_value = "99")]
     frequency: u64,
@@ -74,6 +108,67 @@ struct Args {
     #[arg(long)]
     export_folded: Option<PathBuf>,
 
+    /// Also sample the descendants of the target process, as they appear
+    #[arg(long)]
+    include_children: bool,
+
+    /// More processes to sample together with --pid, comma separated
+    #[arg(long, value_delimiter = ',')]
+    extra_pids: Vec<u32>,
+
+    /// Also sample every other process in the target process's cgroup, such
+    /// as the workers of a pre-forking server
+    #[arg(long)]
+    all_processes: bool,
+
+    /// Give every sampled process, the target included, a root frame of its
+    /// own instead of merging their stacks
+    #[arg(long)]
+    separate_processes: bool,
+
+    /// Only keep samples of tasks in the target process's cgroup (cgroup v2),
+    /// so processes of other containers never leak into the profile
+    #[arg(long)]
+    cgroup_only: bool,
+
+    /// Put the kernel frames of every sample, suffixed _[k], on top of its
+    /// user stack, as when syscalls or page faults dominate
+    #[arg(long)]
+    include_kernel_stacks: bool,
+
+    /// Root every on-CPU stack at a cpuN frame naming the CPU it was sampled
+    /// on, to spot a core pegged by one goroutine while the others idle
+    #[arg(long, conflicts_with_all = ["off_cpu", "sched_latency", "net"])]
+    per_cpu: bool,
+
+    /// Root every stack at a thread:name frame naming the OS thread it was
+    /// sampled on, to see which threads do the work
+    #[arg(long, conflicts_with = "sched_latency")]
+    per_thread: bool,
+
+    /// Frame granularity: functions, or lines to name every frame with the
+    /// file:line it was sampled at when symbols permit
+    #[arg(long, default_value = "functions", value_parser = ["functions", "lines"])]
+    granularity: String,
+
+    /// Export time-ordered stacks ("<offset_ms> <stack> <count>") for flame charts
+    #[arg(long)]
+    export_timeline: Option<PathBuf>,
+
+    /// Periodically write the folded stacks sampled so far to this path, so a
+    /// long profile can be watched while it runs
+    #[arg(long)]
+    snapshot: Option<PathBuf>,
+
+    /// Seconds between two snapshots
+    #[arg(long, default_value = "30", requires = "snapshot")]
+    snapshot_interval: u64,
+
+    /// Stop as soon as this many on-CPU samples were captured, --duration
+    /// becomes the upper bound
+    #[arg(long, conflicts_with_all = ["sched_latency", "net"])]
+    min_samples: Option<u64>,
+
     /// Flame graph title
     #[arg(long, default_value = "Golang CPU Profiling")]
     title: String,
@@ -123,9 +218,18 @@ struct Args {
     random: bool,
 }
 
+/// Samples of one stack gained during a polling interval, used to keep the time order
+struct TimelineEntry {
+    /// Milliseconds since profiling started
+    offset_ms: u64,
+    key: ProfileKey,
+    count: u64,
+}
+
 struct ProfilerState {
     aggregated_counts: Arc<Mutex<HashMap<ProfileKey, u64>>>,
-    symbol_resolver: Arc<Mutex<SymbolResolver>>,
+    timeline: Arc<Mutex<Vec<TimelineEntry>>>,
+    symbol_resolver: Arc<Mutex<ProcessResolvers>>,
     stack_traces_map: Arc<Mutex<Option<aya::maps::StackTraceMap<MapData>>>>,
 }
 
@@ -153,6 +257,12 @@ async fn main() -> anyhow::Result<()> {
         }
     };
 
+    // PID 0 is the idle task, never fall back to sampling the whole host
+    if target_pid == 0 {
+        error!("Invalid target PID 0");
+        process::exit(1);
+    }
+
     info!("Profiling process PID: {}", target_pid);
 
     // Bump the memlock rlimit
@@ -183,6 +293,17 @@ async fn main() -> anyhow::Result<()> {
         String::from_utf8_lossy(&runtime_info.version)
     );
 
+    // Parsed by kubectl-pprof, one line of `go version -m` output per marker
+    match golang_parser::read_build_info(target_pid) {
+        Ok(Some(build_info)) => {
+            for line in build_info.lines() {
+                println!("BUILD_INFO:{}", line);
+            }
+        }
+        Ok(None) => info!("No Go build info in the executable of PID {}", target_pid),
+        Err(e) => warn!("Failed to read the Go build info: {}", e),
+    }
+
     // Set target PID in eBPF map for filtering
     let mut target_pid_map: Array<_, u32> = Array::try_from(ebpf.map_mut("TARGET_PID").unwrap())?;
     target_pid_map.set(0, target_pid, 0)?;
@@ -195,30 +316,158 @@ async fn main() -> anyhow::Result<()> {
         error!("Failed to verify TARGET_PID map setting");
     }
 
+    // Only processes in TARGET_PIDS are sampled
+    let mut target_pids: AyaHashMap<_, u32, u8> =
+        AyaHashMap::try_from(ebpf.take_map("TARGET_PIDS").unwrap())?;
+    target_pids.insert(target_pid, 1, 0)?;
+
+    // Restrict samples to the cgroup of the target, learned in eBPF from its first sample
+    let mut target_cgroup_map: Array<_, u64> =
+        Array::try_from(ebpf.map_mut("TARGET_CGROUP").unwrap())?;
+    if args.cgroup_only {
+        target_cgroup_map.set(0, CGROUP_FILTER_LEARN, 0)?;
+        info!("Samples restricted to the cgroup of PID {}", target_pid);
+    } else {
+        target_cgroup_map.set(0, CGROUP_FILTER_OFF, 0)?;
+    }
+
+    // Key on-CPU samples by CPU, the graph roots them at a cpuN frame
+    if args.per_cpu {
+        let mut per_cpu: Array<_, u8> = Array::try_from(ebpf.map_mut("PER_CPU").unwrap())?;
+        per_cpu.set(0, 1, 0)?;
+        info!("On-CPU samples kept per CPU");
+    }
+
+    // Key samples by thread name, the graph roots them at a thread:name frame
+    if args.per_thread {
+        let mut per_thread: Array<_, u8> = Array::try_from(ebpf.map_mut("PER_THREAD").unwrap())?;
+        per_thread.set(0, 1, 0)?;
+        info!("Samples kept per thread");
+    }
+
+    // Inside the node container of kind or minikube the PIDs above are not the
+    // kernel's global ones, let eBPF translate task IDs into our namespace
+    if let Some((dev, ino)) = nested_pid_namespace() {
+        let mut pid_namespace_map: Array<_, u64> =
+            Array::try_from(ebpf.map_mut("PID_NAMESPACE").unwrap())?;
+        pid_namespace_map.set(PID_NS_DEV, dev, 0)?;
+        pid_namespace_map.set(PID_NS_INO, ino, 0)?;
+        info!(
+            "Running in a nested PID namespace ({}), PIDs are translated in eBPF (Linux 5.7+)",
+            ino
+        );
+    }
+
     // Runtime info is now only used in user space for symbol resolution
 
     // Initialize symbol resolver
-    let symbol_resolver = Arc::new(Mutex::new(SymbolResolver::new(target_pid, runtime_info)?));
+    let symbol_resolver = Arc::new(Mutex::new(ProcessResolvers::new(
+        target_pid,
+        SymbolResolver::new(target_pid, runtime_info)?,
+    )));
+    if args.separate_processes {
+        symbol_resolver
+            .lock()
+            .unwrap()
+            .separate_target(process_name(target_pid));
+    }
+    if args.granularity == "lines" {
+        symbol_resolver.lock().unwrap().keep_lines();
+    }
+
+    // Further processes sampled together with the target
+    let mut extra_pids = args.extra_pids.clone();
+    if args.all_processes {
+        extra_pids.extend(find_cgroup_processes(target_pid));
+    }
+    extra_pids.sort_unstable();
+    extra_pids.dedup();
+    for pid in extra_pids.into_iter().filter(|&pid| pid != 0 && pid != target_pid) {
+        if let Err(e) = target_pids.insert(pid, 1, 0) {
+            warn!("Failed to add process {}: {}", pid, e);
+            continue;
+        }
+        let (name, resolver) = load_process(pid);
+        symbol_resolver
+            .lock()
+            .unwrap()
+            .add_process(pid, name, resolver);
+        info!("Sampling process {}", pid);
+    }
+
+    if args.include_children {
+        // cgroup v1 has no cgroup ID in eBPF, compare the cgroup paths instead
+        let target_cgroup = if args.cgroup_only {
+            read_cgroup(target_pid)
+        } else {
+            None
+        };
+        tokio::spawn(track_children(
+            target_pids,
+            target_pid,
+            target_cgroup,
+            symbol_resolver.clone(),
+        ));
+        info!("Sampling descendants of PID {}", target_pid);
+    }
 
     // Initialize profiler state
     let state = Arc::new(ProfilerState {
         aggregated_counts: Arc::new(Mutex::new(HashMap::new())),
+        timeline: Arc::new(Mutex::new(Vec::new())),
         symbol_resolver: symbol_resolver.clone(),
         stack_traces_map: Arc::new(Mutex::new(None)),
     });
 
-    // Attach perf event for on-CPU profiling
-    let program: &mut PerfEvent = ebpf.program_mut("golang_profile").unwrap().try_into()?;
-    program.load()?;
+    if args.sched_latency {
+        // Scheduling latency replaces on-CPU sampling, the graph only holds waits
+        let program: &mut TracePoint = ebpf.program_mut("schedlat_switch").unwrap().try_into()?;
+        program.load()?;
+        program.attach("sched", "sched_switch")?;
 
-    for cpu in online_cpus().map_err(|(_, error)| error)? {
-        program.attach(
-            perf_event::PerfTypeId::Software,
-            perf_event::perf_sw_ids::PERF_COUNT_SW_CPU_CLOCK as u64,
-            perf_event::PerfEventScope::AllProcessesOneCpu { cpu },
-            perf_event::SamplePolicy::Frequency(args.frequency),
-            true,
+        let program: &mut TracePoint = ebpf.program_mut("schedlat_wakeup").unwrap().try_into()?;
+        program.load()?;
+        program.attach("sched", "sched_wakeup")?;
+        program.attach("sched", "sched_wakeup_new")?;
+        info!("Scheduling latency profiling enabled");
+    } else if args.net {
+        // Network tracing replaces on-CPU sampling, the graph only holds slow calls
+        let mut threshold: Array<_, u64> = Array::try_from(ebpf.map_mut("NET_THRESHOLD").unwrap())?;
+        threshold.set(0, args.net_threshold_us * 1000, 0)?;
+
+        attach_syscalls(&mut ebpf, "net_connect_enter", &["sys_enter_connect"])?;
+        attach_syscalls(
+            &mut ebpf,
+            "net_accept_enter",
+            &["sys_enter_accept", "sys_enter_accept4"],
         )?;
+        attach_syscalls(&mut ebpf, "net_send_enter", &NET_SEND_SYSCALLS.map(|s| s.0))?;
+        attach_syscalls(&mut ebpf, "net_recv_enter", &NET_RECV_SYSCALLS.map(|s| s.0))?;
+        attach_syscalls(&mut ebpf, "net_close_enter", &["sys_enter_close"])?;
+        let exits: Vec<&str> = ["sys_exit_connect", "sys_exit_accept", "sys_exit_accept4"]
+            .into_iter()
+            .chain(NET_SEND_SYSCALLS.map(|s| s.1))
+            .chain(NET_RECV_SYSCALLS.map(|s| s.1))
+            .collect();
+        attach_syscalls(&mut ebpf, "net_exit", &exits)?;
+        info!(
+            "Network tracing enabled, stacks of calls over {} us are recorded",
+            args.net_threshold_us
+        );
+    } else {
+        // Attach perf event for on-CPU profiling
+        let program: &mut PerfEvent = ebpf.program_mut("golang_profile").unwrap().try_into()?;
+        program.load()?;
+
+        for cpu in online_cpus().map_err(|(_, error)| error)? {
+            program.attach(
+                perf_event::PerfTypeId::Software,
+                perf_event::perf_sw_ids::PERF_COUNT_SW_CPU_CLOCK as u64,
+                perf_event::PerfEventScope::AllProcessesOneCpu { cpu },
+                perf_event::SamplePolicy::Frequency(args.frequency),
+                true,
+            )?;
+        }
     }
 
     // Attach tracepoint for off-CPU profiling if enabled
@@ -246,20 +495,58 @@ async fn main() -> anyhow::Result<()> {
         read_aggregated_counts(counts_map, state_clone).await;
     });
 
+    // Snapshots hold the same samples as the final graph of the mode
+    let snapshots = args.snapshot.clone().map(|path| {
+        let (sched_latency, net, include_kernel) =
+            (args.sched_latency, args.net, args.include_kernel_stacks);
+        let keep = move |key: &ProfileKey| {
+            if sched_latency {
+                key.sample_type == SAMPLE_TYPE_SCHED_LAT
+            } else if net {
+                key.sample_type == SAMPLE_TYPE_NET
+            } else {
+                key.sample_type == SAMPLE_TYPE_ON_CPU || key.sample_type == SAMPLE_TYPE_OFF_CPU
+            }
+        };
+        let interval = Duration::from_secs(args.snapshot_interval.max(1));
+        info!(
+            "Writing snapshots to {} every {} seconds",
+            path.display(),
+            interval.as_secs()
+        );
+        tokio::spawn(write_snapshots(state.clone(), path, interval, include_kernel, keep))
+    });
+
     // Wait for specified duration or Ctrl-C
     info!(
         "Profiling for {} seconds... Press Ctrl-C to stop early",
         args.duration
     );
 
+    let sampling_start = std::time::Instant::now();
     tokio::select! {
         _ = time::sleep(Duration::from_secs(args.duration)) => {
             info!("Profiling duration completed");
         }
+        _ = wait_for_samples(state.clone(), args.min_samples) => {
+            info!("Sample target reached, stopping profiler");
+        }
         _ = signal::ctrl_c() => {
             info!("Received Ctrl-C, stopping profiler");
         }
     }
+    if let Some(min_samples) = args.min_samples {
+        // Parsed by kubectl-pprof: "<min> <captured> <elapsed_ms>"
+        println!(
+            "SAMPLE_TARGET:{} {} {}",
+            min_samples,
+            on_cpu_samples(&state),
+            sampling_start.elapsed().as_millis()
+        );
+    }
+    if let Some(snapshots) = snapshots {
+        snapshots.abort();
+    }
 
     // Generate flame graph using Brendan Gregg's FlameGraph tools
     info!("Generating flame graph...");
@@ -274,48 +561,43 @@ async fn main() -> anyhow::Result<()> {
     // Separate on-CPU and off-CPU data for different visualization
     let mut on_cpu_data = HashMap::new();
     let mut off_cpu_data = HashMap::new();
+    let mut sched_lat_data = HashMap::new();
+    let mut net_data = HashMap::new();
 
     for (profile_key, count) in &aggregated_counts {
         // Get stack traces for this profile key
-        let mut stack = Vec::new();
-
-        // Add kernel stack if present
-        if profile_key.kernel_stack_id >= 0 {
-            if let Ok(kernel_stack) = stack_traces_map.get(&(profile_key.kernel_stack_id as u32), 0)
-            {
-                for frame in kernel_stack.frames().iter().rev() {
-                    if frame.ip != 0 {
-                        stack.push(frame.ip);
-                    }
-                }
-            }
-        }
-
-        // Add user stack if present
-        if profile_key.user_stack_id >= 0 {
-            if let Ok(user_stack) = stack_traces_map.get(&(profile_key.user_stack_id as u32), 0) {
-                for frame in user_stack.frames().iter().rev() {
-                    if frame.ip != 0 {
-                        stack.push(frame.ip);
-                    }
-                }
-            }
-        }
+        let stack = resolve_stack(profile_key, stack_traces_map, args.include_kernel_stacks);
 
         if !stack.is_empty() {
-            // Separate data based on sample type
-            if profile_key.sample_type == SAMPLE_TYPE_OFF_CPU {
-                off_cpu_data.insert(stack, *count);
+            // Separate data based on sample type. Keys that only differ in
+            // their dropped kernel stack share a stack, their counts add up.
+            let data = if profile_key.sample_type == SAMPLE_TYPE_OFF_CPU {
+                &mut off_cpu_data
+            } else if profile_key.sample_type == SAMPLE_TYPE_SCHED_LAT {
+                &mut sched_lat_data
+            } else if profile_key.sample_type == SAMPLE_TYPE_NET {
+                &mut net_data
             } else {
-                on_cpu_data.insert(stack, *count);
-            }
+                &mut on_cpu_data
+            };
+            *data
+                .entry((key_origin(profile_key), stack))
+                .or_insert(0) += *count;
         }
     }
 
     // Combine data for flame graph generation, with off-CPU events marked
     let mut converted_data = on_cpu_data.clone();
     for (stack, count) in off_cpu_data.clone() {
-        converted_data.insert(stack, count);
+        *converted_data.entry(stack).or_insert(0) += count;
+    }
+    if args.sched_latency {
+        converted_data = sched_lat_data.clone();
+        export_sched_latency(&ebpf, args.export_histogram.as_deref())?;
+    }
+    if args.net {
+        converted_data = net_data.clone();
+        export_net_stats(&ebpf, args.export_net.as_deref())?;
     }
 
     // Log final statistics
@@ -323,7 +605,21 @@ async fn main() -> anyhow::Result<()> {
     let off_cpu_count: u64 = off_cpu_data.values().sum();
     let total_count = on_cpu_count + off_cpu_count;
     
-    if off_cpu_count > 0 {
+    if args.sched_latency {
+        let waited_us: u64 = sched_lat_data.values().sum();
+        info!(
+            "Final statistics: {} us spent waiting for a CPU over {} stacks",
+            waited_us,
+            sched_lat_data.len()
+        );
+    } else if args.net {
+        let slow_us: u64 = net_data.values().sum();
+        info!(
+            "Final statistics: {} us spent in slow network calls over {} stacks",
+            slow_us,
+            net_data.len()
+        );
+    } else if off_cpu_count > 0 {
         info!(
             "Final statistics: {} total samples (on-CPU: {}, off-CPU: {})",
             total_count, on_cpu_count, off_cpu_count
@@ -331,6 +627,14 @@ async fn main() -> anyhow::Result<()> {
     } else {
         info!("Final statistics: {} on-CPU samples", total_count);
     }
+    print_sample_summary(
+        &ebpf,
+        on_cpu_count,
+        &aggregated_counts,
+        &converted_data,
+        stack_traces_map,
+        &*resolver,
+    )?;
 
     let exporter = FlameGraphExporter::new()?;
 
@@ -340,13 +644,50 @@ async fn main() -> anyhow::Result<()> {
         info!("Folded stacks exported to: {}", folded_path.display());
     }
 
-    // Generate flame graph using Brendan Gregg's tools
+    // Resolve the time-ordered samples only when a flame chart needs them
+    let timeline: Vec<(u64, SampleOrigin, Vec<u64>, u64)> =
+        if args.flamechart || args.export_timeline.is_some() {
+            state
+                .timeline
+                .lock()
+                .unwrap()
+                .iter()
+                .map(|entry| {
+                    (
+                        entry.offset_ms,
+                        key_origin(&entry.key),
+                        resolve_stack(&entry.key, stack_traces_map, args.include_kernel_stacks),
+                        entry.count,
+                    )
+                })
+                .filter(|(_, _, stack, _)| !stack.is_empty())
+                .collect()
+        } else {
+            Vec::new()
+        };
+
+    // Export time-ordered stacks if requested
+    if let Some(timeline_path) = &args.export_timeline {
+        exporter.export_timeline(&timeline, timeline_path, &*resolver)?;
+        info!("Timeline exported to: {}", timeline_path.display());
+    }
+
+    // Generate flame graph using Brendan Gregg's tools. Flame charts keep the
+    // samples in time order, flamegraph.pl then lays them out left to right.
     let folded_file = "stacks.folded";
-    exporter.export_folded_stacks(
-        &converted_data,
-        std::path::Path::new(folded_file),
-        &*resolver,
-    )?;
+    if args.flamechart {
+        exporter.export_flamechart_stacks(
+            &timeline,
+            std::path::Path::new(folded_file),
+            &*resolver,
+        )?;
+    } else {
+        exporter.export_folded_stacks(
+            &converted_data,
+            std::path::Path::new(folded_file),
+            &*resolver,
+        )?;
+    }
 
     // Use embedded flamegraph.pl script to generate SVG
     let temp_script_path = "/tmp/flamegraph_embedded.pl";
@@ -415,10 +756,341 @@ async fn main() -> anyhow::Result<()> {
     Ok(())
 }
 
+/// Log the scheduling latency histogram and write it to path, if given. Only
+/// the range between the first and last non-empty slot is printed.
+/// Prints the machine-readable health of the samples, parsed by kubectl-pprof:
+/// "SAMPLE_SUMMARY:<samples> <dropped> <lost_stacks> <truncated> <frames> <unsymbolized>"
+fn print_sample_summary(
+    ebpf: &Ebpf,
+    samples: u64,
+    aggregated_counts: &HashMap<ProfileKey, u64>,
+    graph: &HashMap<(SampleOrigin, Vec<u64>), u64>,
+    stack_traces_map: &aya::maps::StackTraceMap<MapData>,
+    resolver: &ProcessResolvers,
+) -> Result<()> {
+    let stats: Array<_, u64> = Array::try_from(
+        ebpf.map("SAMPLE_STATS")
+            .ok_or_else(|| anyhow!("SAMPLE_STATS map not found"))?,
+    )?;
+    let dropped = stats.get(&STAT_COUNTS_FULL, 0).unwrap_or(0);
+    let lost_stacks = stats.get(&STAT_STACK_LOST, 0).unwrap_or(0);
+
+    // A user stack as deep as the kernel walks was cut short at its root
+    let truncated: u64 = aggregated_counts
+        .iter()
+        .filter(|(key, _)| key.sample_type == SAMPLE_TYPE_ON_CPU && key.user_stack_id >= 0)
+        .filter(|(key, _)| {
+            stack_traces_map
+                .get(&(key.user_stack_id as u32), 0)
+                .map(|stack| {
+                    stack.frames().iter().filter(|frame| frame.ip != 0).count() >= MAX_STACK_DEPTH
+                })
+                .unwrap_or(false)
+        })
+        .map(|(_, count)| *count)
+        .sum();
+
+    let frames: HashSet<(u32, u64)> = graph
+        .keys()
+        .flat_map(|(origin, stack)| stack.iter().map(move |pc| (origin.pid, *pc)))
+        .collect();
+    let unsymbolized = frames
+        .iter()
+        .filter(|(pid, pc)| {
+            let name = resolver.resolve_pc(*pid, *pc);
+            name.starts_with("[unknown:") || name.starts_with("0x")
+        })
+        .count();
+
+    if dropped > 0 {
+        warn!("{} samples were dropped, the counts map was full", dropped);
+    }
+    println!(
+        "SAMPLE_SUMMARY:{} {} {} {} {} {}",
+        samples,
+        dropped,
+        lost_stacks,
+        truncated,
+        frames.len(),
+        unsymbolized
+    );
+    Ok(())
+}
+
+fn export_sched_latency(ebpf: &Ebpf, path: Option<&std::path::Path>) -> Result<()> {
+    let hist: Array<_, u64> = Array::try_from(
+        ebpf.map("SCHED_LAT_HIST")
+            .ok_or_else(|| anyhow!("SCHED_LAT_HIST map not found"))?,
+    )?;
+    let slots: Vec<u64> = (0..SCHED_LAT_SLOTS)
+        .map(|slot| hist.get(&slot, 0).unwrap_or(0))
+        .collect();
+
+    let mut lines = Vec::new();
+    if let (Some(first), Some(last)) = (
+        slots.iter().position(|&count| count > 0),
+        slots.iter().rposition(|&count| count > 0),
+    ) {
+        for slot in first..=last {
+            let low = if slot == 0 { 0 } else { 1u64 << slot };
+            lines.push(format!("{} {} {}", low, 1u64 << (slot + 1), slots[slot]));
+        }
+    }
+
+    info!("Scheduling latency histogram (usecs: low high count):");
+    for line in &lines {
+        info!("  {}", line);
+    }
+
+    if let Some(path) = path {
+        let mut file = fs::File::create(path)?;
+        writeln!(file, "# low_us high_us count")?;
+        for line in &lines {
+            writeln!(file, "{}", line)?;
+        }
+        info!(
+            "Scheduling latency histogram exported to: {}",
+            path.display()
+        );
+    }
+    Ok(())
+}
+
+/// Socket send and receive syscalls traced by --net, as (enter, exit)
+/// tracepoints. Go uses read and write on connected sockets.
+const NET_SEND_SYSCALLS: [(&str, &str); 4] = [
+    ("sys_enter_write", "sys_exit_write"),
+    ("sys_enter_writev", "sys_exit_writev"),
+    ("sys_enter_sendto", "sys_exit_sendto"),
+    ("sys_enter_sendmsg", "sys_exit_sendmsg"),
+];
+const NET_RECV_SYSCALLS: [(&str, &str); 4] = [
+    ("sys_enter_read", "sys_exit_read"),
+    ("sys_enter_readv", "sys_exit_readv"),
+    ("sys_enter_recvfrom", "sys_exit_recvfrom"),
+    ("sys_enter_recvmsg", "sys_exit_recvmsg"),
+];
+
+/// Load a tracepoint program and attach it to syscall tracepoints. Syscalls
+/// missing on the architecture, such as accept on arm64, are skipped.
+fn attach_syscalls(ebpf: &mut Ebpf, name: &str, tracepoints: &[&str]) -> Result<()> {
+    let program: &mut TracePoint = ebpf
+        .program_mut(name)
+        .ok_or_else(|| anyhow!("{} program not found", name))?
+        .try_into()?;
+    program.load()?;
+    for tracepoint in tracepoints {
+        if let Err(e) = program.attach("syscalls", tracepoint) {
+            warn!("Failed to attach {} to {}: {}", name, tracepoint, e);
+        }
+    }
+    Ok(())
+}
+
+/// Log the network statistics per remote endpoint, slowest first, and write
+/// them to path, if given
+fn export_net_stats(ebpf: &Ebpf, path: Option<&std::path::Path>) -> Result<()> {
+    let stats: AyaHashMap<_, NetEndpoint, NetStats> = AyaHashMap::try_from(
+        ebpf.map("NET_STATS")
+            .ok_or_else(|| anyhow!("NET_STATS map not found"))?,
+    )?;
+    let mut endpoints: Vec<(NetEndpoint, NetStats)> =
+        stats.iter().filter_map(|entry| entry.ok()).collect();
+    endpoints.sort_by(|a, b| b.1.latency_ns.cmp(&a.1.latency_ns));
+
+    let lines: Vec<String> = endpoints
+        .iter()
+        .map(|(endpoint, stats)| {
+            format!(
+                "{} {} {} {} {} {} {}",
+                format_endpoint(endpoint),
+                stats.connections,
+                stats.calls,
+                stats.bytes_sent,
+                stats.bytes_received,
+                stats.latency_ns / 1000,
+                stats.max_latency_ns / 1000
+            )
+        })
+        .collect();
+
+    info!("Network calls (remote connections calls sent received total_us max_us):");
+    for line in &lines {
+        info!("  {}", line);
+    }
+
+    if let Some(path) = path {
+        let mut file = fs::File::create(path)?;
+        writeln!(
+            file,
+            "# remote connections calls bytes_sent bytes_received total_us max_us"
+        )?;
+        for line in &lines {
+            writeln!(file, "{}", line)?;
+        }
+        info!("Network statistics exported to: {}", path.display());
+    }
+    Ok(())
+}
+
+/// Format an endpoint as "1.2.3.4:80" or "[::1]:80"
+fn format_endpoint(endpoint: &NetEndpoint) -> String {
+    if endpoint.family == AF_INET {
+        let addr = Ipv4Addr::new(
+            endpoint.addr[0],
+            endpoint.addr[1],
+            endpoint.addr[2],
+            endpoint.addr[3],
+        );
+        format!("{}:{}", addr, endpoint.port)
+    } else {
+        format!("[{}]:{}", Ipv6Addr::from(endpoint.addr), endpoint.port)
+    }
+}
+
+/// Where the samples of a profile key were taken. The CPU is None unless
+/// samples are kept per CPU, the thread None unless they are kept per thread.
+fn key_origin(profile_key: &ProfileKey) -> SampleOrigin {
+    let name = &profile_key.name;
+    let len = name.iter().position(|&b| b == 0).unwrap_or(name.len());
+    SampleOrigin {
+        pid: profile_key.pid,
+        cpu: (profile_key.cpu != CPU_UNSET).then_some(profile_key.cpu),
+        thread: (len > 0).then(|| String::from_utf8_lossy(&name[..len]).into_owned()),
+    }
+}
+
+/// Resolve the stack of a profile key into instruction pointers, leaf first
+/// as the kernel records them: the kernel frames, when included, then the
+/// user frames, so the folded stack has the kernel on top
+fn resolve_stack(
+    profile_key: &ProfileKey,
+    stack_traces_map: &aya::maps::StackTraceMap<MapData>,
+    include_kernel: bool,
+) -> Vec<u64> {
+    let mut stack = Vec::new();
+
+    // Add kernel stack if present
+    if include_kernel && profile_key.kernel_stack_id >= 0 {
+        if let Ok(kernel_stack) = stack_traces_map.get(&(profile_key.kernel_stack_id as u32), 0) {
+            for frame in kernel_stack.frames() {
+                if frame.ip != 0 {
+                    stack.push(frame.ip);
+                }
+            }
+        }
+    }
+
+    // Add user stack if present
+    if profile_key.user_stack_id >= 0 {
+        if let Ok(user_stack) = stack_traces_map.get(&(profile_key.user_stack_id as u32), 0) {
+            for frame in user_stack.frames() {
+                if frame.ip != 0 {
+                    stack.push(frame.ip);
+                }
+            }
+        }
+    }
+
+    stack
+}
+
+/// Resolves once `min_samples` on-CPU samples were captured, never without a target
+async fn wait_for_samples(state: Arc<ProfilerState>, min_samples: Option<u64>) {
+    let Some(min_samples) = min_samples else {
+        return std::future::pending().await;
+    };
+    loop {
+        time::sleep(Duration::from_millis(500)).await;
+        if on_cpu_samples(&state) >= min_samples {
+            return;
+        }
+    }
+}
+
+/// Returns the number of on-CPU samples aggregated so far
+fn on_cpu_samples(state: &ProfilerState) -> u64 {
+    state
+        .aggregated_counts
+        .lock()
+        .unwrap()
+        .iter()
+        .filter(|(key, _)| key.sample_type == SAMPLE_TYPE_ON_CPU)
+        .map(|(_, count)| *count)
+        .sum()
+}
+
+/// Periodically writes the samples aggregated so far as folded stacks. Each
+/// snapshot is written aside and renamed into place, readers never see a
+/// partial file.
+async fn write_snapshots(
+    state: Arc<ProfilerState>,
+    path: PathBuf,
+    interval: Duration,
+    include_kernel: bool,
+    keep: impl Fn(&ProfileKey) -> bool,
+) {
+    let exporter = match FlameGraphExporter::new() {
+        Ok(exporter) => exporter,
+        Err(e) => {
+            warn!("Snapshots disabled: {}", e);
+            return;
+        }
+    };
+    let partial = path.with_extension("partial");
+    let mut ticker = time::interval(interval);
+    // The first tick completes at once, there is nothing to show yet
+    ticker.tick().await;
+
+    loop {
+        ticker.tick().await;
+        let counts = state.aggregated_counts.lock().unwrap().clone();
+        let mut data = HashMap::new();
+        {
+            let stack_traces_map_guard = state.stack_traces_map.lock().unwrap();
+            let Some(stack_traces_map) = stack_traces_map_guard.as_ref() else {
+                continue;
+            };
+            for (profile_key, count) in &counts {
+                if !keep(profile_key) {
+                    continue;
+                }
+                let stack = resolve_stack(profile_key, stack_traces_map, include_kernel);
+                if !stack.is_empty() {
+                    *data
+                        .entry((key_origin(profile_key), stack))
+                        .or_insert(0) += *count;
+                }
+            }
+        }
+
+        let written = {
+            let resolver = state.symbol_resolver.lock().unwrap();
+            exporter.export_folded_stacks(&data, &partial, &*resolver)
+        }
+        .and_then(|_| {
+            fs::rename(&partial, &path).map_err(|e| anyhow!("Failed to rename snapshot: {}", e))
+        });
+        match written {
+            Ok(()) => info!(
+                "Snapshot of {} stacks written to {}",
+                data.len(),
+                path.display()
+            ),
+            Err(e) => warn!("Failed to write snapshot: {}", e),
+        }
+    }
+}
+
 async fn read_aggregated_counts(
     counts_map: AyaHashMap<MapData, EbpfProfileKey, u64>,
     state: Arc<ProfilerState>,
 ) {
+    // The eBPF map only holds running totals, the per-interval deltas give the
+    // time order of samples at polling resolution
+    let start = std::time::Instant::now();
+    let mut previous_counts: HashMap<ProfileKey, u64> = HashMap::new();
+
     loop {
         // Read all entries from the COUNTS map (now filtered by target PID in eBPF)
         let mut current_counts = HashMap::new();
@@ -435,8 +1107,9 @@ async fn read_aggregated_counts(
                     kernel_ip: 0,
                     user_stack_id: ebpf_key.user_stack_id,
                     kernel_stack_id: ebpf_key.kernel_stack_id,
-                    name: [0u8; 16],
+                    name: ebpf_key.comm,
                     sample_type: ebpf_key.sample_type,
+                    cpu: ebpf_key.cpu,
                 };
 
                 current_counts.insert(profile_key, value);
@@ -453,6 +1126,22 @@ async fn read_aggregated_counts(
 
         // Update the aggregated counts in our state
         if !current_counts.is_empty() {
+            let offset_ms = start.elapsed().as_millis() as u64;
+            {
+                let mut timeline = state.timeline.lock().unwrap();
+                for (key, value) in &current_counts {
+                    let previous = previous_counts.get(key).copied().unwrap_or(0);
+                    if *value > previous {
+                        timeline.push(TimelineEntry {
+                            offset_ms,
+                            key: *key,
+                            count: value - previous,
+                        });
+                    }
+                }
+            }
+            previous_counts = current_counts.clone();
+
             let mut aggregated = state.aggregated_counts.lock().unwrap();
             *aggregated = current_counts;
 
@@ -488,6 +1177,149 @@ async fn read_aggregated_counts(
     }
 }
 
+/// Keep TARGET_PIDS in sync with the descendants of the target process. With a
+/// target cgroup, descendants that moved to another cgroup are left out.
+async fn track_children(
+    mut target_pids: AyaHashMap<MapData, u32, u8>,
+    target_pid: u32,
+    target_cgroup: Option<String>,
+    resolvers: Arc<Mutex<ProcessResolvers>>,
+) {
+    let mut sampled: HashSet<u32> = HashSet::new();
+    let mut skipped: HashSet<u32> = HashSet::new();
+
+    loop {
+        let descendants = find_descendants(target_pid);
+
+        for &pid in &descendants {
+            if sampled.contains(&pid) || skipped.contains(&pid) {
+                continue;
+            }
+            if let Some(cgroup) = &target_cgroup {
+                if read_cgroup(pid).as_ref() != Some(cgroup) {
+                    warn!("Skipping child process {} outside the target cgroup", pid);
+                    skipped.insert(pid);
+                    continue;
+                }
+            }
+            if let Err(e) = target_pids.insert(pid, 1, 0) {
+                warn!("Failed to add child process {}: {}", pid, e);
+                continue;
+            }
+
+            // Load symbols while the child is still running, outside the lock
+            let (name, resolver) = load_process(pid);
+            resolvers.lock().unwrap().add_process(pid, name, resolver);
+            sampled.insert(pid);
+            info!("Sampling child process {}", pid);
+        }
+
+        // Exited PIDs may be reused by unrelated processes
+        sampled.retain(|pid| {
+            if descendants.contains(pid) {
+                return true;
+            }
+            let _ = target_pids.remove(pid);
+            false
+        });
+        skipped.retain(|pid| descendants.contains(pid));
+
+        time::sleep(Duration::from_secs(1)).await;
+    }
+}
+
+/// Read the name of a process and load its symbols. Without symbols its
+/// frames are shown as raw addresses.
+fn load_process(pid: u32) -> (String, Option<SymbolResolver>) {
+    let runtime_info = GoRuntimeParser::new()
+        .parse_process(pid)
+        .unwrap_or_default();
+    let resolver = SymbolResolver::new(pid, runtime_info)
+        .map_err(|e| warn!("Failed to load symbols of process {}: {}", pid, e))
+        .ok();
+    (process_name(pid), resolver)
+}
+
+/// Read the command name of a process from /proc/<pid>/comm
+fn process_name(pid: u32) -> String {
+    fs::read_to_string(format!("/proc/{}/comm", pid))
+        .map(|comm| comm.trim().to_string())
+        .unwrap_or_else(|_| "unknown".to_string())
+}
+
+/// Find the other processes sharing the cgroup of a process
+fn find_cgroup_processes(target_pid: u32) -> Vec<u32> {
+    let Some(cgroup) = read_cgroup(target_pid) else {
+        warn!("Cannot read the cgroup of PID {}, sampling it alone", target_pid);
+        return Vec::new();
+    };
+    let mut pids = Vec::new();
+    if let Ok(entries) = fs::read_dir("/proc") {
+        for entry in entries.flatten() {
+            let Ok(pid) = entry.file_name().to_string_lossy().parse::<u32>() else {
+                continue;
+            };
+            if pid != target_pid && read_cgroup(pid).as_ref() == Some(&cgroup) {
+                pids.push(pid);
+            }
+        }
+    }
+    pids
+}
+
+/// Find all descendants of a process from the parent PIDs in /proc
+fn find_descendants(root: u32) -> HashSet<u32> {
+    let mut children: HashMap<u32, Vec<u32>> = HashMap::new();
+    if let Ok(entries) = fs::read_dir("/proc") {
+        for entry in entries.flatten() {
+            let Ok(pid) = entry.file_name().to_string_lossy().parse::<u32>() else {
+                continue;
+            };
+            if let Some(ppid) = read_ppid(pid) {
+                children.entry(ppid).or_default().push(pid);
+            }
+        }
+    }
+
+    let mut descendants = HashSet::new();
+    let mut pending = vec![root];
+    while let Some(parent) = pending.pop() {
+        for &child in children.get(&parent).map(Vec::as_slice).unwrap_or_default() {
+            if descendants.insert(child) {
+                pending.push(child);
+            }
+        }
+    }
+    descendants
+}
+
+/// Read the parent PID from /proc/<pid>/stat, skipping the command name
+/// which may contain spaces and parentheses
+fn read_ppid(pid: u32) -> Option<u32> {
+    let stat = fs::read_to_string(format!("/proc/{}/stat", pid)).ok()?;
+    let rest = &stat[stat.rfind(')')? + 1..];
+    rest.split_whitespace().nth(1)?.parse().ok()
+}
+
+/// Read the cgroup membership of a process
+/// Device and inode of our PID namespace when it is not the initial one, in
+/// the encoding bpf_get_ns_current_pid_tgid compares them with
+fn nested_pid_namespace() -> Option<(u64, u64)> {
+    use std::os::unix::fs::MetadataExt;
+
+    let ns = fs::metadata("/proc/self/ns/pid").ok()?;
+    if ns.ino() == PROC_PID_INIT_INO {
+        return None;
+    }
+    // The kernel dev_t keeps the minor number in the low 20 bits
+    let dev = ((libc::major(ns.dev()) as u64) << 20) | libc::minor(ns.dev()) as u64;
+    Some((dev, ns.ino()))
+}
+
+fn read_cgroup(pid: u32) -> Option<String> {
+    fs::read_to_string(format!("/proc/{}/cgroup", pid)).ok()
+}
+
 fn find_process_by_name(name: &str) -> anyhow::Result<u32> {
     let output = std::process::Command::new("pgrep")
         .arg("-f")
@@ -532,6 +532,7 @@ impl SymbolResolver {
                 debug!("Resolved kernel symbol: {}", kernel_symbol);
                 return format!("{}_[k]", kernel_symbol);
             }
+            return format!("0x{:x}_[k]", pc);
         }
 
         // Try DWARF information first (highest priority)
@@ -889,6 +890,87 @@ impl SymbolResolver {
     }
 }
 
+/// Symbol resolvers of all sampled processes. The target process is always
+/// present; child processes get their own resolver when they are discovered
+/// since they may run a different binary.
+pub struct ProcessResolvers {
+    target_pid: u32,
+    resolvers: HashMap<u32, SymbolResolver>,
+    names: HashMap<u32, String>,
+    /// The target process gets a root frame like the others
+    separate: bool,
+    /// Frames keep the file:line they were resolved with
+    lines: bool,
+}
+
+impl ProcessResolvers {
+    pub fn new(target_pid: u32, target: SymbolResolver) -> Self {
+        let mut resolvers = HashMap::new();
+        resolvers.insert(target_pid, target);
+        ProcessResolvers {
+            target_pid,
+            resolvers,
+            names: HashMap::new(),
+            separate: false,
+            lines: false,
+        }
+    }
+
+    /// Name frames with the file:line of the sampled instruction, so every
+    /// source line of a function gets a frame of its own
+    pub fn keep_lines(&mut self) {
+        self.lines = true;
+    }
+
+    /// Give the target process a root frame too, so that every sampled
+    /// process shows up as a separate top-level frame
+    pub fn separate_target(&mut self, name: String) {
+        self.names.insert(self.target_pid, name);
+        self.separate = true;
+    }
+
+    /// Register a child process. Without a resolver its frames are shown as
+    /// raw addresses rather than with the symbols of another binary.
+    pub fn add_process(&mut self, pid: u32, name: String, resolver: Option<SymbolResolver>) {
+        self.names.insert(pid, name);
+        if let Some(resolver) = resolver {
+            self.resolvers.insert(pid, resolver);
+        }
+    }
+
+    /// Resolve a PC in the address space of the given process
+    pub fn resolve_pc(&self, pid: u32, pc: u64) -> String {
+        match self.resolvers.get(&pid) {
+            Some(resolver) if self.lines => resolver.resolve_pc(pc),
+            Some(resolver) => function_name(resolver.resolve_pc(pc)),
+            None => format!("0x{:x}", pc),
+        }
+    }
+
+    /// Root frame that separates the stacks of other processes from the
+    /// target's, None for the target process itself unless separated
+    pub fn process_frame(&self, pid: u32) -> Option<String> {
+        if pid == self.target_pid && !self.separate {
+            return None;
+        }
+        let name = self
+            .names
+            .get(&pid)
+            .map(String::as_str)
+            .unwrap_or("unknown");
+        Some(format!("[{} {}]", name, pid))
+    }
+}
+
+/// Drop the " file:line" location a symbol was resolved with. Go function
+/// names have no spaces; " +0x.." offsets of unresolved functions stay.
+fn function_name(symbol: String) -> String {
+    match symbol.split_once(' ') {
+        Some((name, location)) if !location.starts_with('+') => name.to_string(),
+        _ => symbol,
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
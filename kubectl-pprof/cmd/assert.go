@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/compare"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/gate"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+	"github.com/withlin/kubectl-pprof/pkg/store"
+)
+
+// newAssertCmd 创建 assert 子命令
+func newAssertCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		maxFuncs   []string
+		maxUnknown string
+		from       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "assert [workload] [flags]",
+		Short: "Profile a pod and fail when hot functions exceed their limits",
+		Long: `Profile a pod, or the first running pod of a workload given as kind/name, and
+exit non-zero when a limit is exceeded, as a performance regression gate in
+pipelines. Limits are percentages of all samples:
+
+  --max-func function=percent   samples with the function anywhere on the stack,
+                                * in the name matches any characters, repeatable
+  --max-unknown-frames percent  samples with a frame that could not be symbolized
+
+With --from the limits are checked against a stored session or an exported
+stacks file instead, without profiling.
+
+Examples:
+  # Fail when GC marking takes more than 20% of the CPU of the deployment
+  kubectl pprof assert -n staging deployment/api -d 60s \
+    --max-func 'runtime.gcBgMarkWorker=20%' --max-unknown-frames 10%
+
+  # Bound every encoding/json function of an existing profile
+  kubectl pprof assert --from profile.folded --max-func 'encoding/json.*=5%'
+`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g := gate.Gate{MaxUnknown: -1}
+			for _, value := range maxFuncs {
+				limit, err := gate.ParseFuncLimit(value)
+				if err != nil {
+					return err
+				}
+				g.Funcs = append(g.Funcs, limit)
+			}
+			if maxUnknown != "" {
+				limit, err := compare.ParseThreshold(maxUnknown)
+				if err != nil {
+					return fmt.Errorf("invalid --max-unknown-frames: %w", err)
+				}
+				g.MaxUnknown = limit
+			}
+			if len(g.Funcs) == 0 && g.MaxUnknown < 0 {
+				return fmt.Errorf("set at least one of --max-func or --max-unknown-frames")
+			}
+
+			var (
+				profile *flamegraph.Profile
+				err     error
+			)
+			if from != "" {
+				if len(args) > 0 {
+					return fmt.Errorf("--from checks an existing profile and cannot be used with a workload")
+				}
+				profile, _, err = loadCompared(store.New(opts.SessionsDir), from)
+			} else {
+				profile, err = profileForAssert(cmd, cfg, opts, args)
+			}
+			if err != nil {
+				return err
+			}
+
+			checks := g.Evaluate(profile)
+			if !opts.Quiet {
+				if err := gate.WriteChecks(os.Stdout, checks); err != nil {
+					return err
+				}
+			}
+			if failed := gate.Failed(checks); failed > 0 {
+				return fmt.Errorf("%d of %d assertions failed", failed, len(checks))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&maxFuncs, "max-func", nil, "Limit of a function's share of samples as function=percent, e.g. 'runtime.gcBgMarkWorker=20%' (repeatable)")
+	cmd.Flags().StringVar(&maxUnknown, "max-unknown-frames", "", "Limit of the share of samples with an unsymbolized frame, e.g. 10%")
+	cmd.Flags().StringVar(&from, "from", "", "Check a stored session or stacks file instead of profiling")
+
+	return cmd
+}
+
+// profileForAssert profiles the target of assert and loads its stacks
+func profileForAssert(cmd *cobra.Command, cfg *api.ProfileConfig, opts *api.ProfileOptions, args []string) (*flamegraph.Profile, error) {
+	ctx := cmd.Context()
+	if cfg.Namespace == "" {
+		return nil, fmt.Errorf("target namespace is required")
+	}
+	if cfg.AllContainers || cfg.Spread != "" {
+		return nil, fmt.Errorf("assert profiles a single pod and cannot be used with --all-containers or --spread")
+	}
+	if len(args) > 0 && cfg.PodName != "" {
+		return nil, fmt.Errorf("give either a workload or --target-pod, not both")
+	}
+
+	k8sConfig, err := config.LoadKubernetesConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubernetes config: %w", err)
+	}
+	profilerClient, err := profiler.NewProfiler(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profiler: %w", err)
+	}
+	if len(args) > 0 {
+		if cfg.PodName, err = profilerClient.WorkloadPod(ctx, cfg.Namespace, args[0]); err != nil {
+			return nil, err
+		}
+		opts.Log().Info("Profiling pod of workload", "workload", args[0], "pod", cfg.PodName)
+	}
+	if err := validateProfileFlags(cfg, opts); err != nil {
+		return nil, err
+	}
+	exportFolded(cfg)
+
+	result, err := profilerClient.Profile(ctx, cfg, opts)
+	if err != nil {
+		return nil, fmt.Errorf("profiling failed: %w", err)
+	}
+	saveSessions(opts, result)
+
+	stacks := result.FoldedPath
+	if stacks == "" {
+		stacks = result.PprofPath
+	}
+	if stacks == "" {
+		return nil, fmt.Errorf("the session produced no stacks to check")
+	}
+	profile, _, err := flamegraph.LoadFile(stacks, "", "")
+	return profile, err
+}
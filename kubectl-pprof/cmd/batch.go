@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/metrics"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+// newBatchCmd 创建 batch 子命令
+func newBatchCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		filename    string
+		maxParallel int
+		outputDir   string
+		metricsAddr string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "batch -f <targets.yaml> [flags]",
+		Short: "Profile many pods across namespaces from a manifest file",
+		Long: `Profile every target listed in a manifest file with bounded parallelism and print
+a summary table. Each pod gets its own artifacts in the output directory, next to a
+summary.json with the result of every target.
+
+A target names a pod or a label selector (every running pod matching is profiled).
+Unset fields are taken from defaults, then from the command line flags:
+
+  defaults:
+    duration: 30s
+    options:
+      profileType: cpu
+  targets:
+    - name: api
+      namespace: prod
+      pod: api-0
+      container: api
+    - namespace: prod
+      selector: app=worker
+      duration: 1m
+      options:
+        mode: pprof-endpoint
+        outputFormat: html
+
+Examples:
+  # Sweep the targets, four at a time
+  kubectl pprof batch -f targets.yaml --max-parallel 4 --output-dir sweep-1
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if maxParallel < 1 {
+				return fmt.Errorf("--max-parallel must be at least 1")
+			}
+			spec, err := profiler.LoadBatchSpec(filename)
+			if err != nil {
+				return err
+			}
+
+			cfg.Language = "go"
+			cfg.CrictlPath = "/usr/bin/crictl"
+			if cfg.EnvVars == nil {
+				cfg.EnvVars = make(map[string]string)
+			}
+			if err := applyOutputFormat(cfg, opts); err != nil {
+				return err
+			}
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+			var sessionMetrics *metrics.Metrics
+			if metricsAddr != "" {
+				sessionMetrics = metrics.New()
+				server, err := sessionMetrics.Listen(metricsAddr)
+				if err != nil {
+					return err
+				}
+				defer server.Close()
+				opts.Log().Info("Serving metrics", "addr", metricsAddr, "path", "/metrics")
+			}
+			profilerClient, err := profiler.NewProfiler(k8sConfig, profiler.WithMetrics(sessionMetrics))
+			if err != nil {
+				return fmt.Errorf("failed to create profiler: %w", err)
+			}
+
+			results, err := profilerClient.ProfileBatch(cmd.Context(), spec, cfg, opts, maxParallel, outputDir)
+			if err != nil {
+				return fmt.Errorf("batch profiling failed: %w", err)
+			}
+
+			failed := printBatchSummary(results)
+			if !opts.Quiet {
+				fmt.Printf("Summary: %s\n", filepath.Join(outputDir, "summary.json"))
+			}
+			if failed > 0 {
+				return fmt.Errorf("profiling failed for %d of %d targets", failed, len(results))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&filename, "filename", "f", "", "Manifest listing the targets (YAML or JSON)")
+	cmd.Flags().IntVar(&maxParallel, "max-parallel", 4, "Maximum number of targets profiled at the same time")
+	cmd.Flags().StringVar(&outputDir, "output-dir", "pprof-batch", "Directory for the artifacts of every target and summary.json")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics of the sweep on this address under /metrics, e.g. :9090")
+	_ = cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+// printBatchSummary prints one line per profiled pod and returns how many failed
+func printBatchSummary(results []api.BatchResult) int {
+	failed := 0
+	fmt.Printf("%-48s %-8s %10s  %s\n", "TARGET", "STATUS", "ELAPSED", "OUTPUT")
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			fmt.Printf("%-48s %-8s %10v  %s\n", r.Target, "FAILED", r.Elapsed.Round(time.Second), r.Error)
+			continue
+		}
+		fmt.Printf("%-48s %-8s %10v  %s\n", r.Target, "OK", r.Elapsed.Round(time.Second), r.Result.OutputPath)
+	}
+	return failed
+}
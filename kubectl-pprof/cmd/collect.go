@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+// newCollectCmd 创建 collect 子命令
+func newCollectCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "collect <job> [flags]",
+		Short: "Collect the results of a profiling Job started with --no-collect",
+		Long: `Wait for a profiling Job started with --no-collect to finish, then fetch its artifacts
+from the profiler logs and render them as the session that started it would have.
+The session is recorded on the Job, so only its name and namespace are needed.
+Its output paths are not: the results are written to --output, or to a name
+with the current time in the current directory, like a profiling run.
+
+The Job is kept until collected, then cleaned up as --cleanup and --keep-failed-jobs
+of the session say. Once finished, it is deleted by the cluster after --job-ttl,
+collect it before then.
+
+Examples:
+  # Start a 30 minute profile and return at once
+  kubectl pprof -n prod -p api-0 -d 30m --no-collect
+
+  # Fetch the results later, from any terminal
+  kubectl pprof collect kubectl-pprof-api-0-x7k2p --job-namespace prod -o api.svg
+`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace := cfg.GetJobNamespace()
+			if namespace == "" {
+				return fmt.Errorf("the namespace of the Job is required, use --job-namespace or --target-namespace")
+			}
+
+			if output != "" {
+				local := api.ProfileConfig{OutputPath: output, Overwrite: cfg.Overwrite}
+				if err := prepareOutputPath(&local); err != nil {
+					return err
+				}
+				output = local.OutputPath
+			}
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+			profilerClient, err := profiler.NewProfiler(k8sConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create profiler: %w", err)
+			}
+
+			result, err := profilerClient.Collect(cmd.Context(), args[0], namespace, output, cfg.Overwrite, opts)
+			if err != nil {
+				return fmt.Errorf("collect failed: %w", err)
+			}
+			saveSessions(opts, result)
+			if !opts.Quiet {
+				printProfileResult(result.Config, result)
+				fmt.Printf("Collection completed! Output: %s\n", result.OutputPath)
+			}
+			return nil
+		},
+	}
+
+	// Local flag, the persistent --output default belongs to profiling
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file path, flamegraph-<YYYYMMDD-HHMMSS>.<format> in the current directory when not given")
+
+	return cmd
+}
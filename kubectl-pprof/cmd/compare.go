@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/compare"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/store"
+)
+
+// newCompareCmd 创建 compare 子命令
+func newCompareCmd(opts *api.ProfileOptions) *cobra.Command {
+	var (
+		threshold    string
+		reportFormat string
+		output       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare <base> <target> [flags]",
+		Short: "Report the per-function regressions between two profiles",
+		Long: `Compare the self and total share of samples of every function between a base
+and a target profile, print the functions that changed by at least --threshold
+percentage points, and render a differential flame graph of the target: red
+frames grew against the base, blue frames shrank.
+
+Each profile is a session stored with --save-session, by ID, unique ID prefix or
+directory, or an exported .folded, .timeline or pprof file.
+
+Examples:
+  # Compare two stored sessions
+  kubectl pprof compare 20261017-101500-api-0 20261017-143000-api-0 --threshold 5%
+
+  # Paste the report into a pull request
+  kubectl pprof compare before.folded after.folded --report-format markdown > report.md
+`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			points, err := compare.ParseThreshold(threshold)
+			if err != nil {
+				return err
+			}
+			reportFormat = strings.ToLower(reportFormat)
+			if reportFormat != "text" && reportFormat != "markdown" {
+				return fmt.Errorf("invalid report format '%s', must be one of: text, markdown", reportFormat)
+			}
+
+			s := store.New(opts.SessionsDir)
+			base, baseLabel, err := loadCompared(s, args[0])
+			if err != nil {
+				return err
+			}
+			target, targetLabel, err := loadCompared(s, args[1])
+			if err != nil {
+				return err
+			}
+
+			report := compare.Profiles(base, target, baseLabel, targetLabel, points)
+			if reportFormat == "markdown" {
+				err = report.WriteMarkdown(os.Stdout)
+			} else {
+				err = report.WriteText(os.Stdout)
+			}
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				return nil
+			}
+			var buf bytes.Buffer
+			diffOpts := flamegraph.Options{
+				Title:    "Differential Flame Graph",
+				Subtitle: fmt.Sprintf("%s vs %s", targetLabel, baseLabel),
+				Baseline: base,
+			}
+			if err := flamegraph.RenderSVG(&buf, target, diffOpts); err != nil {
+				return fmt.Errorf("failed to render differential flame graph: %w", err)
+			}
+			if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write output file: %w", err)
+			}
+			// Logged, stdout carries the report
+			opts.Log().Info("Differential flame graph saved", "path", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&threshold, "threshold", "5%", "Report functions whose self or total share of samples changed by at least this many percentage points")
+	cmd.Flags().StringVar(&reportFormat, "report-format", "text", "Report format (text, markdown)")
+	// Local flag, the persistent --output default belongs to profiling
+	cmd.Flags().StringVarP(&output, "output", "o", "diff.svg", "Differential flame graph output file, empty to skip it")
+
+	return cmd
+}
+
+// loadCompared loads a stored session or a stacks file, and labels it
+func loadCompared(s *store.Store, ref string) (*flamegraph.Profile, string, error) {
+	path, label := ref, ref
+	if info, err := os.Stat(ref); err != nil || info.IsDir() {
+		session, err := s.Get(ref)
+		if err != nil {
+			return nil, "", err
+		}
+		if path, err = session.Stacks(); err != nil {
+			return nil, "", err
+		}
+		label = fmt.Sprintf("%s (%s)", session.ID, session.Target())
+	}
+	profile, _, err := flamegraph.LoadFile(path, "", "")
+	if err != nil {
+		return nil, "", err
+	}
+	return profile, label, nil
+}
+
+// resolveStacks returns the stacks file of a stored session, or ref itself
+// when it names a file
+func resolveStacks(s *store.Store, ref string) (string, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		return ref, nil
+	}
+	session, err := s.Get(ref)
+	if err != nil {
+		return "", err
+	}
+	return session.Stacks()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+// Exit codes of kubectl pprof, by the type of the error that ended it
+const (
+	exitFailure    = 1 // Errors without a type
+	exitUsage      = 2 // Invalid flags or configuration
+	exitPermission = 3 // RBAC denied a request, or the credentials were refused
+	exitKubernetes = 4 // The API server failed a request, or the profiler pod cannot start
+	exitTimeout    = 5
+	exitNetwork    = 6 // The API server or the target cannot be reached
+	exitProfiler   = 7 // The profile cannot be taken on the node, or was refused
+	exitIO         = 8 // Local files cannot be read or written
+)
+
+// exitCodes maps the error types to exit codes
+var exitCodes = map[errors.ErrorType]int{
+	errors.ErrorTypeValidation:    exitUsage,
+	errors.ErrorTypeConfiguration: exitUsage,
+	errors.ErrorTypePermission:    exitPermission,
+	errors.ErrorTypeKubernetes:    exitKubernetes,
+	errors.ErrorTypeTimeout:       exitTimeout,
+	errors.ErrorTypeNetwork:       exitNetwork,
+	errors.ErrorTypeProfiler:      exitProfiler,
+	errors.ErrorTypeIO:            exitIO,
+}
+
+// docsURL is the README, followed by the section explaining an error type
+const docsURL = "https://github.com/withlin/kubectl-pprof#"
+
+// docSections maps the error types to README sections, the troubleshooting
+// section when missing
+var docSections = map[errors.ErrorType]string{
+	errors.ErrorTypeValidation:    "命令行选项",
+	errors.ErrorTypeConfiguration: "命令行选项",
+	errors.ErrorTypePermission:    "权限要求",
+}
+
+// docsLink returns the README section explaining an error type
+func docsLink(errorType errors.ErrorType) string {
+	if section, ok := docSections[errorType]; ok {
+		return docsURL + section
+	}
+	return docsURL + "故障排除"
+}
+
+// usageError reports invalid flags with the usage exit code
+func usageError(cmd *cobra.Command, err error) error {
+	return errors.NewValidationError(err.Error(), fmt.Sprintf("Run '%s --help' for the flags", cmd.CommandPath()))
+}
+
+// invalidFlags reports an error of the flag checks with the usage exit code,
+// keeping the type of the errors that already have one
+func invalidFlags(err error) error {
+	if err == nil || errors.IsProfileError(err) {
+		return err
+	}
+	return errors.NewValidationError(err.Error(), "Run 'kubectl pprof --help' for the flags")
+}
+
+// configFileError reports an invalid configuration file with the usage exit code
+func configFileError(path string, err error) error {
+	return errors.NewConfigurationError(fmt.Sprintf("invalid configuration file %s", path), err,
+		fmt.Sprintf("Fix the file, or point %s to another one", config.FileEnv))
+}
+
+// presentError prints the error that ended the command to w and returns the
+// exit code of its type. Structured errors are printed with their suggestions
+// and a link to the documentation, as one JSON record with --log-format json;
+// --quiet keeps to the error itself.
+func presentError(w io.Writer, err error, logFormat string, quiet bool) int {
+	profileErr := errors.GetProfileError(err)
+	code := exitFailure
+	if profileErr != nil {
+		if typed, ok := exitCodes[profileErr.Type]; ok {
+			code = typed
+		}
+	}
+
+	if logFormat == logging.FormatJSON {
+		attrs := []any{"exitCode", code}
+		if profileErr != nil {
+			attrs = append(attrs,
+				"type", profileErr.Type,
+				"retryable", profileErr.Retryable,
+				"suggestions", profileErr.Suggestions,
+				"docs", docsLink(profileErr.Type))
+		}
+		slog.New(slog.NewJSONHandler(w, nil)).Error(err.Error(), attrs...)
+		return code
+	}
+	if profileErr == nil || quiet {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return code
+	}
+	fmt.Fprint(w, profileErr.FormatUserMessage())
+	fmt.Fprintf(w, "\n📖 %s\n", docsLink(profileErr.Type))
+	return code
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+func TestExitCodeOfInvalidFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown flag", args: []string{"--no-such-flag"}},
+		{name: "missing namespace", args: []string{"-p", "app"}},
+		{name: "missing pod", args: []string{"-n", "default"}},
+		{name: "negative duration", args: []string{"-n", "default", "-p", "app", "-d", "-1s"}},
+		{name: "invalid compression", args: []string{"-n", "default", "-p", "app", "--compression", "lz4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newRootCmd()
+			root.SetArgs(tt.args)
+			root.SetOut(&bytes.Buffer{})
+			err := root.Execute()
+			if err == nil {
+				t.Fatalf("kubectl pprof %v succeeded", tt.args)
+			}
+			var stderr bytes.Buffer
+			if code := presentError(&stderr, err, logging.FormatText, false); code != exitUsage {
+				t.Errorf("exit code %d, want %d: %s", code, exitUsage, stderr.String())
+			}
+		})
+	}
+}
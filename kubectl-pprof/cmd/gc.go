@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+	"github.com/withlin/kubectl-pprof/pkg/store"
+)
+
+// newGCCmd 创建 gc 子命令
+func newGCCmd(opts *api.ProfileOptions) *cobra.Command {
+	cleanupCfg := job.DefaultCleanupConfig()
+	var (
+		watch      bool
+		olderThan  string
+		failedOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gc [flags]",
+		Short: "Delete stale kubectl-pprof Jobs and orphaned pods in all namespaces",
+		Long: `List and delete the kubectl-pprof Jobs of all namespaces older than --older-than,
+together with their pods, and profiler pods whose Job is gone. Jobs still running
+and --no-collect Jobs not yet collected are kept, however old. Interrupted sessions
+leave privileged pods behind on clusters without the TTL controller, or for Jobs
+created before --job-ttl existed.
+
+Examples:
+  # Show what would be deleted
+  kubectl pprof gc --dry-run
+
+  # Delete the failed Jobs older than a day
+  kubectl pprof gc --older-than 1d --failed-only
+
+  # Keep cleaning up every minute until interrupted
+  kubectl pprof gc --watch --interval 1m
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cleanupCfg.CleanupInterval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			age, err := store.ParseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+			cleanupCfg.AutoCleanupDelay = age
+			cleanupCfg.MaxJobRetention = age
+			cleanupCfg.CleanupSuccessfulJobs = !failedOnly
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+
+			// The one-shot listing is printed as a table, only --watch logs as it goes
+			logger := logging.Discard()
+			if watch {
+				logger = opts.Log()
+			}
+			cleaner := job.NewJobCleaner(k8sConfig.Clientset, cleanupCfg, logger)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if watch {
+				if err := cleaner.RunOnce(ctx); err != nil {
+					return err
+				}
+				// Returns once interrupted
+				cleaner.Start(ctx)
+				return nil
+			}
+
+			jobs, pods, err := cleaner.FindStale(ctx)
+			if err != nil {
+				return err
+			}
+			if !opts.Quiet {
+				printStale(jobs, pods)
+			}
+			if cleanupCfg.DryRun {
+				return nil
+			}
+
+			failed := 0
+			for _, j := range jobs {
+				if err := cleaner.CleanupJob(ctx, j.Name, j.Namespace); err != nil {
+					opts.Log().Warn("Failed to delete job", "error", err)
+					failed++
+				}
+			}
+			for _, pod := range pods {
+				if err := cleaner.CleanupPod(ctx, pod.Name, pod.Namespace); err != nil {
+					opts.Log().Warn("Failed to delete pod", "error", err)
+					failed++
+				}
+			}
+			total := len(jobs) + len(pods)
+			if !opts.Quiet {
+				fmt.Printf("Deleted %d of %d resources\n", total-failed, total)
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to delete %d of %d resources", failed, total)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&olderThan, "older-than", "1h", "Only delete Jobs and pods created at least this long ago, e.g. 1d or 12h")
+	cmd.Flags().BoolVar(&failedOnly, "failed-only", false, "Only delete failed Jobs and pods")
+	cmd.Flags().BoolVar(&cleanupCfg.DryRun, "dry-run", false, "List what would be deleted without deleting it")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Keep cleaning up every --interval until interrupted")
+	cmd.Flags().DurationVar(&cleanupCfg.CleanupInterval, "interval", 5*time.Minute, "Time between cleanups with --watch")
+
+	return cmd
+}
+
+// printStale prints one line per Job or pod gc deletes
+func printStale(jobs []batchv1.Job, pods []corev1.Pod) {
+	if len(jobs)+len(pods) == 0 {
+		fmt.Println("No stale kubectl-pprof resources found")
+		return
+	}
+	now := time.Now()
+	fmt.Printf("%-4s %-20s %-63s %-10s %8s\n", "KIND", "NAMESPACE", "NAME", "STATUS", "AGE")
+	for _, j := range jobs {
+		status := "Running"
+		if j.Status.Succeeded > 0 {
+			status = "Succeeded"
+		} else if j.Status.Failed > 0 {
+			status = "Failed"
+		}
+		fmt.Printf("%-4s %-20s %-63s %-10s %8v\n", "job", j.Namespace, j.Name, status, now.Sub(j.CreationTimestamp.Time).Round(time.Minute))
+	}
+	for _, pod := range pods {
+		fmt.Printf("%-4s %-20s %-63s %-10s %8v\n", "pod", pod.Namespace, pod.Name, pod.Status.Phase, now.Sub(pod.CreationTimestamp.Time).Round(time.Minute))
+	}
+}
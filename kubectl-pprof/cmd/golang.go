@@ -2,15 +2,17 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/withlin/kubectl-pprof/internal/types"
+	"github.com/spf13/pflag"
+	"github.com/withlin/kubectl-pprof/pkg/api"
 )
 
 // newGolangCmd 创建 golang 子命令
-func newGolangCmd(cfg *types.ProfileConfig, opts *types.ProfileOptions) *cobra.Command {
+func newGolangCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "golang [flags]",
@@ -32,6 +34,7 @@ func newGolangCmd(cfg *types.ProfileConfig, opts *types.ProfileOptions) *cobra.C
 		frequency       int
 		image           string
 		imagePullPolicy string
+		goOpts          api.GoProfilingOptions
 	)
 
 	cmd.Flags().IntVar(&pid, "pid", 0, "Process ID to profile (0 = auto-detect by crictl)")
@@ -41,6 +44,34 @@ func newGolangCmd(cfg *types.ProfileConfig, opts *types.ProfileOptions) *cobra.C
 	cmd.Flags().StringVar(&image, "image", "golang-profiling:latest", "Profiling tool image")
 	cmd.Flags().StringVar(&imagePullPolicy, "image-pull-policy", "IfNotPresent", "Image pull policy (Always, IfNotPresent, Never)")
 
+	// Go profiling and flame graph options
+	cmd.Flags().BoolVar(&goOpts.OffCPU, "off-cpu", false, "Enable off-CPU analysis")
+	cmd.Flags().StringVar(&goOpts.Title, "go-title", "", "Flame graph title")
+	cmd.Flags().StringVar(&goOpts.Subtitle, "go-subtitle", "", "Flame graph subtitle (default: session metadata)")
+	cmd.Flags().StringVar(&goOpts.Colors, "go-colors", "", "Flame graph color scheme (hot, mem, io, wakeup, chain, java, js, perl, red, green, blue, aqua, yellow, purple, orange, kernel_user)")
+	cmd.Flags().StringVar(&goOpts.BgColors, "go-bgcolors", "", "Flame graph background colors (yellow, blue, green, grey or #rrggbb)")
+	cmd.Flags().IntVar(&goOpts.Width, "go-width", 0, "Flame graph width in pixels (default 1200)")
+	cmd.Flags().IntVar(&goOpts.Height, "go-height", 0, "Flame graph frame height in pixels (default 16)")
+	cmd.Flags().StringVar(&goOpts.FontType, "go-font-type", "", "Flame graph font type (default Verdana)")
+	cmd.Flags().Float64Var(&goOpts.FontSize, "go-font-size", 0, "Flame graph font size (default 12)")
+	cmd.Flags().BoolVar(&goOpts.Inverted, "go-inverted", false, "Generate an inverted icicle graph")
+	cmd.Flags().BoolVar(&goOpts.FlameChart, "go-flame-chart", false, "Generate a time-ordered flame chart instead of a flame graph (also saves <output>.timeline)")
+	cmd.Flags().BoolVar(&goOpts.Hash, "go-hash", false, "Use hash-based colors so functions keep their color across graphs")
+	cmd.Flags().BoolVar(&goOpts.Random, "go-random", false, "Use random colors")
+	cmd.Flags().BoolVar(&goOpts.ClientRender, "client-render", false, "Render the flame graph locally from folded stacks instead of in the profiling pod")
+	cmd.Flags().BoolVar(&goOpts.GoroutineDump, "goroutine-dump", false, "Also capture a full goroutine stack dump from the pod's pprof endpoint (saved as <output>.goroutines.txt/.json)")
+	cmd.Flags().BoolVar(&opts.BlockProfile, "block-profile", false, "Also capture a block profile from the pod's pprof endpoint and render a contention flame graph (saved as <output>.block.*)")
+	cmd.Flags().BoolVar(&opts.MutexProfile, "mutex-profile", false, "Also capture a mutex profile from the pod's pprof endpoint and render a contention flame graph (saved as <output>.mutex.*)")
+	cmd.Flags().StringVar(&goOpts.ExportFolded, "go-export-folded", "", "Also save folded stacks to this path (relative paths are placed next to the output file)")
+
+	// --flame-chart is accepted as a shorter spelling of --go-flame-chart
+	cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "flame-chart" {
+			name = "go-flame-chart"
+		}
+		return pflag.NormalizedName(name)
+	})
+
 	// Note: Job configuration, resource limits, and UI options are inherited from parent command
 
 	// Note: Required flags are handled by parent command
@@ -49,7 +80,6 @@ func newGolangCmd(cfg *types.ProfileConfig, opts *types.ProfileOptions) *cobra.C
 	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
 		// 设置默认配置
 		cfg.Language = "go"
-		cfg.ProfileType = "cpu"
 		
 		// 设置Go特定配置
 		// 只有当用户明确指定了pid且不为0时才设置PID
@@ -73,9 +103,8 @@ func newGolangCmd(cfg *types.ProfileConfig, opts *types.ProfileOptions) *cobra.C
 		}
 		
 		// Configure Go-specific options
-		cfg.GoOptions = &types.GoProfilingOptions{
-			Frequency: frequency,
-		}
+		goOpts.Frequency = frequency
+		cfg.GoOptions = &goOpts
 
 		// Validate configuration
 		if err := validateGoConfig(cfg, opts); err != nil {
@@ -89,22 +118,17 @@ func newGolangCmd(cfg *types.ProfileConfig, opts *types.ProfileOptions) *cobra.C
 }
 
 // validateGoConfig 验证 Go 特定的配置
-func validateGoConfig(cfg *types.ProfileConfig, opts *types.ProfileOptions) error {
+func validateGoConfig(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
 	// 验证命名空间
 	if cfg.Namespace == "" {
 		return fmt.Errorf("namespace is required")
 	}
 
 	// 验证 Pod 名称
-	if cfg.PodName == "" {
+	if cfg.PodName == "" && cfg.Spread == "" && cfg.TargetImage == "" {
 		return fmt.Errorf("pod name is required")
 	}
 
-	// 只支持CPU分析
-	if cfg.ProfileType != "cpu" {
-		cfg.ProfileType = "cpu"
-	}
-
 	// 验证持续时间
 	if cfg.Duration <= 0 {
 		return fmt.Errorf("duration must be positive")
@@ -148,6 +172,26 @@ func validateGoConfig(cfg *types.ProfileConfig, opts *types.ProfileOptions) erro
 		}
 	}
 
+	// 验证背景颜色
+	if cfg.GoOptions != nil && cfg.GoOptions.BgColors != "" {
+		validBgColors := []string{"yellow", "blue", "green", "grey"}
+		valid := regexp.MustCompile(`^#[0-9a-fA-F]{6}$`).MatchString(cfg.GoOptions.BgColors)
+		for _, c := range validBgColors {
+			if cfg.GoOptions.BgColors == c {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid background colors '%s', must be one of: %s or a #rrggbb hex color", cfg.GoOptions.BgColors, strings.Join(validBgColors, ", "))
+		}
+	}
+
+	// 验证颜色选项互斥
+	if cfg.GoOptions != nil && cfg.GoOptions.Hash && cfg.GoOptions.Random {
+		return fmt.Errorf("--go-hash and --go-random cannot be used together")
+	}
+
 	// 验证镜像拉取策略
 	if cfg.ImagePullPolicy != "" {
 		validPolicies := []string{"Always", "IfNotPresent", "Never"}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/manifests"
+)
+
+// newInstallCmd 创建 install 子命令
+func newInstallCmd(opts *api.ProfileOptions) *cobra.Command {
+	var (
+		install      manifests.Install
+		caBundleFile string
+		dryRun       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "install [flags]",
+		Short: "Deploy the in-cluster components of kubectl-pprof",
+		Long: `Generate and apply the manifests of an in-cluster component, or print them with
+--dry-run. Available modes: ` + strings.Join(manifests.InstallModes, ", ") + `
+
+  webhook  the admission webhook enforcing namespace profiling policies (see
+           policy-webhook): Deployment, Service, RBAC and the
+           ValidatingWebhookConfiguration for profiling Jobs
+  openshift  the SecurityContextConstraints kubectl-pprof admitting profiling
+           Jobs on OpenShift, and a ServiceAccount of the same name allowed
+           to use it; profile with --job-namespace <namespace>
+           --service-account kubectl-pprof --scc kubectl-pprof
+
+The webhook serves the certificate in the Secret kubectl-pprof-policy-webhook-tls.
+With --cert-manager, cert-manager issues it and injects the CA bundle; otherwise
+create the Secret yourself and pass the CA that signed it with --ca-bundle-file.
+
+Examples:
+  kubectl pprof install --mode webhook --namespace profiling-system --cert-manager --dry-run
+  kubectl pprof install --mode webhook --namespace profiling-system --ca-bundle-file ca.crt
+  kubectl pprof install --mode openshift --namespace profiling-system
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if caBundleFile != "" {
+				if install.CertManager {
+					return fmt.Errorf("--ca-bundle-file and --cert-manager cannot be used together")
+				}
+				data, err := os.ReadFile(caBundleFile)
+				if err != nil {
+					return fmt.Errorf("failed to read CA bundle: %w", err)
+				}
+				install.CABundle = data
+			}
+
+			objects, err := install.Objects()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				return manifests.Render(os.Stdout, objects...)
+			}
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+			if err := manifests.Apply(cmd.Context(), k8sConfig.Config, objects...); err != nil {
+				return err
+			}
+			if !opts.Quiet {
+				fmt.Printf("Installed %s into namespace %s\n", install.Mode, install.Namespace)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&install.Mode, "mode", manifests.ModeWebhook, "Component to install ("+strings.Join(manifests.InstallModes, ", ")+")")
+	// Local flag, the persistent --namespace names the profiling target
+	cmd.Flags().StringVar(&install.Namespace, "namespace", "profiling-system", "Namespace of the component")
+	cmd.Flags().BoolVar(&install.CreateNamespace, "create-namespace", true, "Create the namespace as well")
+	cmd.Flags().StringVar(&install.Image, "image", manifests.DefaultCLIImage, "Image providing the kubectl-pprof binary")
+	cmd.Flags().StringVar(&caBundleFile, "ca-bundle-file", "", "PEM CA bundle that signed the webhook certificate")
+	cmd.Flags().BoolVar(&install.CertManager, "cert-manager", false, "Let cert-manager issue the webhook certificate and inject the CA bundle")
+	cmd.Flags().BoolVar(&install.FailClosed, "fail-closed", false, "Reject profiling Jobs while the webhook is unavailable, instead of admitting them")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the manifests instead of applying them")
+
+	return cmd
+}
@@ -0,0 +1,103 @@
+// Command kubectl-pprof-launcher runs the profiler in the profiling pod of a
+// --direct-exec session, in place of the job shell script, and reports each
+// step as a JSON status record on stdout (see launcher.Status). The Job spec
+// gives it the target and the profiler command as arguments:
+//
+//	kubectl-pprof-launcher --container api --container-id 3f2a... \
+//	    --artifact FLAMEGRAPH=/tmp/profile.svg -- /usr/local/bin/golang-profiling --duration 30 ...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+)
+
+// artifactsFlag collects repeated NAME=PATH artifact flags
+type artifactsFlag struct {
+	artifacts *[]launcher.Artifact
+	optional  bool
+}
+
+func (f artifactsFlag) String() string {
+	return ""
+}
+
+func (f artifactsFlag) Set(value string) error {
+	name, path, ok := strings.Cut(value, "=")
+	if !ok || name == "" || path == "" {
+		return fmt.Errorf("artifact %q must be NAME=PATH", value)
+	}
+	*f.artifacts = append(*f.artifacts, launcher.Artifact{Name: name, Path: path, Optional: f.optional})
+	return nil
+}
+
+// pidsFlag parses a comma-separated list of PIDs
+type pidsFlag struct {
+	pids *[]int
+}
+
+func (f pidsFlag) String() string {
+	return ""
+}
+
+func (f pidsFlag) Set(value string) error {
+	for _, field := range strings.Split(value, ",") {
+		pid, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || pid <= 0 {
+			return fmt.Errorf("invalid PID %q", field)
+		}
+		*f.pids = append(*f.pids, pid)
+	}
+	return nil
+}
+
+func main() {
+	var opts launcher.Options
+	flags := flag.NewFlagSet("kubectl-pprof-launcher", flag.ExitOnError)
+	flags.StringVar(&opts.RuntimeEndpoint, "runtime-endpoint", "", "CRI endpoint of the node, e.g. unix:///run/containerd/containerd.sock")
+	flags.StringVar(&opts.ContainerName, "container", "", "Name of the target container")
+	flags.StringVar(&opts.ContainerID, "container-id", "", "ID of the target container, without the runtime scheme")
+	flags.StringVar(&opts.PodUID, "pod-uid", "", "UID of the target pod as in cgroup paths")
+	flags.StringVar(&opts.Command, "command", "", "Executable name of the container command")
+	flags.StringVar(&opts.HostProcess, "process", "", "Name of the node process to profile instead of a container")
+	flags.Var(pidsFlag{&opts.PIDs}, "pids", "PIDs as seen in the target container, comma separated")
+	flags.StringVar(&opts.ProcRoot, "proc-root", "/host/proc", "Host /proc")
+	flags.StringVar(&opts.CgroupRoot, "cgroup-root", "/host/sys/fs/cgroup", "Host cgroup hierarchy")
+	flags.StringVar(&opts.Crictl, "crictl", "crictl", "crictl binary")
+	flags.Var(artifactsFlag{artifacts: &opts.Artifacts}, "artifact", "NAME=PATH of a file to print to the logs (repeatable)")
+	flags.Var(artifactsFlag{artifacts: &opts.Artifacts, optional: true}, "optional-artifact", "NAME=PATH of a file to print to the logs when the profiler wrote it (repeatable)")
+	flags.StringVar(&opts.Compression, "compression", launcher.EncodingGzip, "Compression of the printed artifacts, gzip or zstd")
+	flags.DurationVar(&opts.ServeTimeout, "serve-timeout", 0, "Serve the artifacts over HTTP on localhost for this long for the CLI to fetch through a port-forward, before printing them to the logs (0 = only print them)")
+	flags.StringVar(&opts.ServeAddr, "serve-addr", "127.0.0.1:0", "Address to serve the artifacts on")
+	_ = flags.Parse(os.Args[1:])
+	opts.Profiler = flags.Args()
+	if opts.Compression != launcher.EncodingGzip && opts.Compression != launcher.EncodingZstd {
+		fmt.Fprintf(os.Stderr, "Error: --compression must be %s or %s\n", launcher.EncodingGzip, launcher.EncodingZstd)
+		os.Exit(2)
+	}
+	if opts.ContainerName == "" && opts.HostProcess == "" {
+		fmt.Fprintln(os.Stderr, "Error: --container or --process is required")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := launcher.New(opts, os.Stdout, os.Stderr).Run(ctx); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
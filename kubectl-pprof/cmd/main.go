@@ -1,15 +1,32 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/withlin/kubectl-pprof/internal/types"
+	"github.com/withlin/kubectl-pprof/pkg/analysis"
+	"github.com/withlin/kubectl-pprof/pkg/api"
 	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/discovery"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+	"github.com/withlin/kubectl-pprof/pkg/nodecache"
 	"github.com/withlin/kubectl-pprof/pkg/profiler"
+	"github.com/withlin/kubectl-pprof/pkg/record"
+	"github.com/withlin/kubectl-pprof/pkg/store"
 )
 
 // Build information set by ldflags
@@ -20,16 +37,19 @@ var (
 )
 
 func main() {
-	if err := newRootCmd().Execute(); err != nil {
-		// cobra已经通过RunE返回的错误自动输出了错误信息
-		// 这里不需要再次输出，避免重复
-		os.Exit(1)
+	profiler.Version = version
+	root := newRootCmd()
+	if err := root.Execute(); err != nil {
+		// cobra 不输出错误，由 presentError 按错误类型输出建议并给出退出码
+		logFormat := root.PersistentFlags().Lookup("log-format").Value.String()
+		quiet := root.PersistentFlags().Lookup("quiet").Value.String() == "true"
+		os.Exit(presentError(os.Stderr, err, logFormat, quiet))
 	}
 }
 
 func newRootCmd() *cobra.Command {
-	var cfg types.ProfileConfig
-	var opts types.ProfileOptions
+	var cfg api.ProfileConfig
+	var opts api.ProfileOptions
 
 	cmd := &cobra.Command{
 		Use:   "kubectl-pprof [flags]",
@@ -54,46 +74,189 @@ Examples:
 
   # Export folded stacks for external analysis
   kubectl pprof golang -n monitoring -p prometheus --go-export-folded /output/stacks.folded
+
+  # Fetch the heap profile from the pod's net/http/pprof handler, no privileges needed
+  kubectl pprof -n default -p my-go-app --mode pprof-endpoint --pprof-profile heap
+
+  # Profile every container of a pod concurrently and merge them into one graph
+  kubectl pprof -n default -p my-go-app --all-containers --parallel --merge
+
+  # Profile a DaemonSet on every node, three nodes at a time, and merge the graphs
+  kubectl pprof -n kube-system --spread daemonset/kube-proxy --max-concurrent 3 --merge
+
+  # Profile a pod with a random name by its image, picking among the matches
+  kubectl pprof -n default --target-image 'ghcr.io/foo/api:*'
+
+  # Profile the targets listed in a manifest, four at a time
+  kubectl pprof batch -f targets.yaml --max-parallel 4
+
+  # Profile the kubelet of a node
+  kubectl pprof node worker-1 --process kubelet
 `,
-		SilenceUsage: true, // 禁止在错误时显示用法信息
+		SilenceUsage:  true, // 禁止在错误时显示用法信息
+		SilenceErrors: true, // 错误由 main 中的 presentError 输出
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runProfile(cmd.Context(), &cfg, &opts)
 		},
 	}
+	cmd.SetFlagErrorFunc(usageError)
 
 	// Add subcommands
 	cmd.AddCommand(newGolangCmd(&cfg, &opts))
+	cmd.AddCommand(newSelftestCmd(&cfg, &opts))
+	cmd.AddCommand(newRenderCmd(&cfg, &opts))
+	cmd.AddCommand(newBatchCmd(&cfg, &opts))
+	cmd.AddCommand(newGCCmd(&opts))
+	cmd.AddCommand(newReplayCmd(&opts))
+	cmd.AddCommand(newCollectCmd(&cfg, &opts))
+	cmd.AddCommand(newPolicyWebhookCmd(&opts))
+	cmd.AddCommand(newSessionsCmd(&opts))
+	cmd.AddCommand(newCompareCmd(&opts))
+	cmd.AddCommand(newAssertCmd(&cfg, &opts))
+	cmd.AddCommand(newScheduleCmd(&opts))
+	cmd.AddCommand(newInstallCmd(&opts))
+	cmd.AddCommand(newNodeCmd(&cfg, &opts))
+	cmd.AddCommand(newNativeCmd(&cfg, &opts))
+	cmd.AddCommand(newNodejsCmd(&cfg, &opts))
+	cmd.AddCommand(newWhenCmd(&cfg, &opts))
+	cmd.AddCommand(newWatchCmd(&cfg, &opts))
+	cmd.AddCommand(newPreheatCmd(&cfg, &opts))
 
 	// Target specification (kubectl-prof style with aliases) - 使用PersistentFlags让子命令继承
 	cmd.PersistentFlags().StringVarP(&cfg.Namespace, "target-namespace", "n", "", "Target namespace (required)")
 	cmd.PersistentFlags().StringVarP(&cfg.PodName, "target-pod", "p", "", "Target pod name (required)")
 	cmd.PersistentFlags().StringVarP(&cfg.ContainerName, "container", "c", "", "Target container name")
-	cmd.PersistentFlags().StringVar(&cfg.PID, "pid", "", "Specific process ID to profile (default: auto-detect by crictl)")
-	cmd.MarkPersistentFlagRequired("target-namespace")
-	cmd.MarkPersistentFlagRequired("target-pod")
+	cmd.PersistentFlags().StringVar(&cfg.PID, "pid", "", "Process IDs to profile as seen in the target container, comma separated to sample several together, e.g. 123,456 (default: the container's main process)")
+	cmd.PersistentFlags().BoolVar(&cfg.Strict, "strict", false, "Without --container, profile the first container instead of skipping well-known sidecars")
+	cmd.PersistentFlags().BoolVar(&cfg.AllContainers, "all-containers", false, "Profile every container of the pod, one Job per container")
+	cmd.PersistentFlags().BoolVar(&cfg.ParallelContainers, "parallel", false, "Profile the containers concurrently (with --all-containers)")
+	cmd.PersistentFlags().BoolVar(&cfg.MergeContainers, "merge", false, "Also render a merged graph with a root frame per container or node (with --all-containers or --spread)")
+	cmd.PersistentFlags().StringVar(&cfg.Spread, "spread", "", "Profile one pod per node of a workload (daemonset/NAME, deployment/NAME or statefulset/NAME), one Job per node")
+	cmd.PersistentFlags().BoolVar(&cfg.Retarget, "retarget", false, "When the target pod belongs to a Deployment and its container restarts or the pod is evicted mid-profile, profile the replacement pod instead of failing")
+	cmd.PersistentFlags().Int64Var(&cfg.MinSamples, "min-samples", 0, "Stop as soon as this many on-CPU samples were captured, --duration (or --max-duration) becomes the upper bound")
+	cmd.PersistentFlags().DurationVar(&cfg.MaxDuration, "max-duration", 0, "Upper bound of a profile stopped by --min-samples, replaces --duration")
+	cmd.PersistentFlags().BoolVar(&cfg.Live, "live", false, "Re-render the output file from snapshots of the stacks sampled so far while the profile runs; Ctrl-C stops early and keeps the last snapshot")
+	cmd.PersistentFlags().DurationVar(&cfg.LiveInterval, "live-interval", api.DefaultLiveInterval, "Time between two live snapshots (with --live)")
+	cmd.PersistentFlags().StringVar(&cfg.TargetImage, "target-image", "", "Find the target among the running pods of the namespace by container image glob, e.g. 'ghcr.io/foo/api:*' (instead of --target-pod)")
+	cmd.PersistentFlags().BoolVar(&cfg.AllMatches, "all", false, "Profile every container matching --target-image one after the other, instead of picking one")
+	cmd.PersistentFlags().IntVar(&cfg.MaxConcurrentJobs, "max-concurrent", 5, "Maximum number of kubectl-pprof Jobs active in the cluster with --spread")
+	cmd.PersistentFlags().BoolVar(&cfg.IncludeChildren, "include-children", false, "Also sample child processes of the target process")
+	cmd.PersistentFlags().BoolVar(&cfg.AllProcesses, "all-processes", false, "Sample every process of the target container together, e.g. the workers of a pre-forking server")
+	cmd.PersistentFlags().BoolVar(&cfg.SeparateProcesses, "separate-processes", false, "Keep every sampled process under a root frame of its own instead of merging their stacks")
+	cmd.PersistentFlags().BoolVar(&cfg.IncludeKernelStacks, "include-kernel-stacks", false, "Put the kernel frames of every sample, suffixed _[k], on top of its user stack, e.g. when syscalls dominate")
+	cmd.PersistentFlags().BoolVar(&cfg.PerCPU, "per-cpu", false, "Root every on-CPU stack at a cpuN frame and break the samples down by CPU, e.g. to spot one core pegged by a single goroutine")
+	cmd.PersistentFlags().StringVar(&cfg.Annotate, "annotate", api.AnnotateNone, "Root every stack at a synthetic frame: none, thread (the OS thread name) or labels (the runtime/pprof labels of the goroutine, read from the pprof endpoint)")
+	cmd.PersistentFlags().StringArrayVar(&cfg.TagFilters, "tag-filter", nil, "Only keep samples whose runtime/pprof label matches key=regex, like go tool pprof -tagfocus; repeat to require several (reads the pprof endpoint)")
+	cmd.PersistentFlags().BoolVar(&cfg.Traces, "traces", false, "List the trace IDs active during the window, read from the runtime/pprof labels OpenTelemetry integrations set (reads the pprof endpoint)")
+	cmd.PersistentFlags().StringArrayVar(&cfg.TraceLabels, "trace-label", nil, "pprof label holding the trace ID, repeat for several (default trace_id, traceID, otel.trace_id)")
+	cmd.PersistentFlags().StringVar(&cfg.Granularity, "granularity", "", "Frame granularity: functions, or lines to name frames \"function file:line\" when symbols permit (default functions, lines with --list)")
+	cmd.PersistentFlags().StringVar(&cfg.List, "list", "", "Print the hot source lines of the functions matching this regex, like go tool pprof list")
+	cmd.PersistentFlags().StringVar(&cfg.ExportSummary, "export-summary", "", "Also save a compact markdown summary (top functions and stacks, runtime metrics, findings) to this path, e.g. to paste into an incident doc (relative paths are placed next to the output file)")
+	cmd.PersistentFlags().StringVar(&cfg.Baseline, "baseline", "", "Stored session or stacks file the --export-summary lists the changes against")
+	cmd.PersistentFlags().BoolVar(&cfg.Analyze, "analyze", false, "Recognize common problems in the stacks (GC, map growth, regexp compilation, JSON, mutex contention) and print them as ranked findings")
+	cmd.PersistentFlags().StringVar(&cfg.SourceURLTemplate, "source-url-template", "", "Link the frames of the target's own module in the HTML report to their source at the built revision, e.g. 'https://github.com/org/repo/blob/{commit}/{file}#L{line}'")
+	cmd.PersistentFlags().BoolVar(&cfg.CgroupOnly, "cgroup-only", true, "Only keep samples from the target container's cgroup, so neighboring workloads never show up")
+	// Namespace and pod are validated by the profiling commands themselves so that
+	// auxiliary subcommands (version, selftest, ...) can run without them
 
-	// Profiling options (CPU only) - 使用PersistentFlags让子命令继承
+	// Profiling options - 使用PersistentFlags让子命令继承
 	cmd.PersistentFlags().DurationVarP(&cfg.Duration, "duration", "d", 30*time.Second, "Profiling duration")
+	cmd.PersistentFlags().StringVar(&cfg.ProfileType, "profile-type", api.ProfileTypeCPU, "What to measure: cpu (on-CPU stacks), schedlat (time the target's threads wait runnable for a CPU, as a latency histogram and a flame graph of the waiting stacks), heap (growth between heap snapshots from the pprof endpoint) or net (latency and bytes of network calls per remote endpoint, and a flame graph of the stacks issuing slow calls)")
+	cmd.PersistentFlags().IntVar(&cfg.Snapshots, "snapshots", 5, "Heap snapshots spread over the duration with --profile-type heap (at least 2)")
+	cmd.PersistentFlags().DurationVar(&cfg.NetThreshold, "net-threshold", time.Millisecond, "Network calls at least this slow are shown in the --profile-type net flame graph")
 
 	// Note: Go-specific options (off-cpu, frequency, etc.) are available in 'golang' subcommand
 
 	// Output options - 使用PersistentFlags让子命令继承
-	cmd.PersistentFlags().StringVarP(&cfg.OutputPath, "output", "o", "flamegraph.svg", "Output file path")
-	cmd.PersistentFlags().StringVar(&opts.OutputFormat, "output-format", "svg", "Output format (svg, png, pdf, json)")
+	cmd.PersistentFlags().StringVarP(&cfg.OutputPath, "output", "o", "", "Output file path, flamegraph-<YYYYMMDD-HHMMSS>.<format> in the current directory when not given; an existing file is only replaced with --overwrite")
+	cmd.PersistentFlags().StringVar(&opts.OutputFormat, "output-format", "svg", "Output format (svg, png, pdf, html, json)")
+	cmd.PersistentFlags().IntVar(&opts.DPI, "dpi", flamegraph.BaseDPI, "Resolution of png output and print size of pdf output (96 = one pixel per --go-width unit)")
+	cmd.PersistentFlags().StringSliceVar(&cfg.AlsoFormats, "also-format", nil, "Also convert the stacks into these formats next to the output file, concurrently: "+strings.Join(flamegraph.ConvertFormats, ", "))
 	cmd.PersistentFlags().BoolVar(&opts.FlameGraph, "flamegraph", true, "Generate flame graph")
 
 	// Job configuration
 	cmd.Flags().StringVar(&cfg.Image, "image", "golang-profiling:latest", "Profiling tool image")
+	var language string
+	cmd.Flags().StringVar(&language, "language", "", "Profile with the backend of this language instead of detecting it: go, java, python, node, rust or one declared under languages in ~/.kubectl-pprof.yaml")
 	cmd.Flags().StringVar(&cfg.ImagePullPolicy, "image-pull-policy", "IfNotPresent", "Image pull policy (Always, IfNotPresent, Never)")
 	cmd.Flags().StringVar(&cfg.NodeName, "node", "", "Force scheduling on specific node")
-	cmd.Flags().StringVar(&cfg.JobName, "job-name", "kubectl-pprof", "Job name prefix")
+	cmd.PersistentFlags().StringVar(&cfg.JobName, "job-name", "kubectl-pprof", "Job name prefix, followed by the target pod name and a random suffix")
 	cmd.Flags().BoolVar(&cfg.Cleanup, "cleanup", true, "Cleanup Job resources after completion")
-	cmd.Flags().DurationVar(&cfg.Timeout, "timeout", 5*time.Minute, "Job timeout")
+	cmd.PersistentFlags().BoolVar(&cfg.KeepFailedJobs, "keep-failed-jobs", false, "Keep failed Jobs and their pods for inspection, until --job-ttl expires")
+	cmd.PersistentFlags().DurationVar(&cfg.JobTTL, "job-ttl", time.Hour, "Let the cluster delete finished Jobs after this long (ttlSecondsAfterFinished), 0 to disable")
+	cmd.Flags().DurationVar(&cfg.Timeout, "timeout", 0, "Deadline of the profiling pod (default: --startup-timeout + --duration + --flush-timeout)")
+	cmd.PersistentFlags().DurationVar(&cfg.StartupTimeout, "startup-timeout", api.DefaultStartupTimeout, "Time allowed for scheduling the profiler, pulling its image and finding the target before sampling starts")
+	cmd.PersistentFlags().DurationVar(&cfg.FlushTimeout, "flush-timeout", api.DefaultFlushTimeout, "Time allowed for rendering and printing the artifacts after sampling")
 	cmd.Flags().BoolVar(&cfg.Privileged, "privileged", true, "Run profiling container in privileged mode")
+	cmd.PersistentFlags().StringVar(&cfg.ImageArchSuffix, "image-arch-suffix", "", "Tag suffix scheme for per-architecture images, e.g. '-{arch}' (default: image is multi-arch)")
+	cmd.PersistentFlags().StringVar((*string)(&cfg.Mode), "mode", string(api.ModeAuto), "How to reach the target: job (privileged hostPID Job), ephemeral (debug container in the target pod), pprof-endpoint (port-forward to net/http/pprof) or auto")
+	cmd.PersistentFlags().StringVar(&cfg.PprofPort, "pprof-port", "", "pprof endpoint port number or container port name (default: annotation, a port named pprof/debug or 6060)")
+	cmd.PersistentFlags().StringVar(&cfg.PprofPath, "pprof-path", "", "Path of the net/http/pprof handlers (default: annotation or /debug/pprof)")
+	cmd.PersistentFlags().Float64Var(&cfg.ThrottleWarnPercent, "throttle-warn-percent", 10, "Warn when the target container was CPU throttled in more than this percentage of CFS periods during the profile")
+	cmd.PersistentFlags().BoolVar(&cfg.ResourceUsage, "resource-usage", true, "Sample the CPU and memory usage of the target container during the profile from metrics-server, or the kubelet summary API, and report it against its requests and limits")
+	cmd.PersistentFlags().BoolVar(&cfg.RuntimeMetrics, "runtime-metrics", true, "Snapshot GC, heap and scheduler metrics around the profile when the pod serves a pprof endpoint")
+	cmd.PersistentFlags().StringVar(&cfg.PprofProfile, "pprof-profile", "profile", "Profile to fetch in pprof-endpoint mode ("+strings.Join(endpoint.Profiles, ", ")+")")
+	cmd.PersistentFlags().StringVar(&cfg.JobNamespace, "job-namespace", "", "Namespace to create the profiling Job in (default: target namespace)")
+	cmd.PersistentFlags().StringSliceVar(&cfg.Tolerations, "tolerations", nil, "Extra tolerations of the profiling pod as key[=value][:effect], on top of the target pod's (repeatable)")
+	cmd.PersistentFlags().BoolVar(&cfg.TolerateAll, "tolerate-all", false, "Tolerate every taint, cordoned and dedicated nodes included, instead of copying the target pod's tolerations")
+	cmd.PersistentFlags().StringVar(&cfg.PriorityClass, "priority-class", "", "PriorityClass of the profiling pod, high enough not to be preempted mid-sample; use a class with preemptionPolicy: Never so it never preempts workloads either")
+	cmd.PersistentFlags().StringVar(&cfg.ServiceAccount, "service-account", "", "ServiceAccount to run the profiling pod as")
+	cmd.PersistentFlags().BoolVar(&cfg.NoEvents, "no-events", false, "Do not record a 'Profiling' Event with the user, duration and frequency on the target pod")
+	cmd.PersistentFlags().StringVar(&cfg.AuditConfigMap, "audit-configmap", "", "Also append every session to this ConfigMap (namespace/name), created when missing")
+	cmd.PersistentFlags().StringSliceVar(&cfg.ImagePullSecrets, "image-pull-secret", nil, "Image pull secret for the profiling image (repeatable)")
+	cmd.PersistentFlags().StringVar(&cfg.RegistryMirror, "registry-mirror", "", "Registry, optionally with a path, to pull the profiling image from instead of its own, e.g. mirror.corp:5000 (default: registryMirror of ~/.kubectl-pprof.yaml)")
+	cmd.PersistentFlags().StringVar(&cfg.ImageDigest, "image-digest", "", "Pin the profiling image to this digest, e.g. sha256:<64 hex digits>")
+	cmd.PersistentFlags().BoolVar(&cfg.RequireDigest, "require-digest", false, "Refuse to run a profiling image that is not pinned by digest")
+	cmd.PersistentFlags().BoolVar(&cfg.Force, "force", false, "Profile even when the estimated sampling overhead on the node is too high or the node is under resource pressure, and accept profiles taken by a heavily throttled profiler")
+	cmd.PersistentFlags().BoolVar(&cfg.Overwrite, "overwrite", false, "Replace the output file when it already exists")
+	cmd.PersistentFlags().DurationVar(&cfg.WaitForSlot, "wait-for-slot", 0, "Queue up to this long when another session profiles the target node, instead of failing at once")
+	cmd.PersistentFlags().StringVar(&cfg.LeaseNamespace, "lease-namespace", api.DefaultLeaseNamespace, "Namespace of the per-node Leases that keep two sessions from sampling the same node")
+	cmd.PersistentFlags().Var(optionalBool{&cfg.OpenShift}, "openshift", "Build the Job for OpenShift: request an SCC, run as SELinux type spc_t and use the CRI-O socket; detected from the cluster when not given")
+	cmd.PersistentFlags().Lookup("openshift").NoOptDefVal = "true"
+	cmd.PersistentFlags().StringVar(&cfg.SCC, "scc", api.DefaultSCC, "SecurityContextConstraints the profiling pod requests on OpenShift, see 'kubectl pprof install --mode openshift'")
+	cmd.PersistentFlags().StringVar(&cfg.JobTemplate, "job-template", "", "YAML overlay strategically merged onto the generated Job (tolerations, nodeSelector, serviceAccountName, annotations, ...)")
+	cmd.PersistentFlags().StringVar(&cfg.ScriptTemplate, "script-template", "", "Go text/template replacing the embedded script of the profiling Job")
+	cmd.PersistentFlags().StringVar(&cfg.Compression, "compression", api.CompressionGzip, "Compression of the artifacts sent through the pod logs: gzip, or zstd for large profiles over slow API server connections (falls back to gzip when the profiling image has no zstd)")
+	cmd.PersistentFlags().StringVar(&cfg.Transfer, "transfer", "", "How the artifacts reach the CLI: port-forward to the launcher, verified and resumable, or logs (default: port-forward with --direct-exec, logs otherwise)")
+	cmd.PersistentFlags().BoolVar(&cfg.NoCollect, "no-collect", false, "Start the profiling Job and return at once with its name, 'kubectl pprof collect <job>' fetches the results later")
+	cmd.PersistentFlags().BoolVar(&cfg.DirectExec, "direct-exec", false, "Run golang-profiling through the launcher of the profiling image with structured arguments instead of a shell script")
 
 	// UI options - 使用PersistentFlags让子命令继承
 	cmd.PersistentFlags().BoolVarP(&opts.Quiet, "quiet", "q", false, "Suppress interactive prompts and progress output")
+	var verbosity int
+	var logFormat string
+	cmd.PersistentFlags().IntVar(&verbosity, "v", 0, fmt.Sprintf("Log verbosity, 0 to %d", logging.MaxVerbosity))
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText, "Progress log format (text, json)")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		logger, err := logging.New(os.Stderr, logFormat, verbosity)
+		if err != nil {
+			return err
+		}
+		opts.Logger = logger
+		slog.SetDefault(logger)
+
+		file, err := config.LoadFile(config.DefaultFilePath())
+		if err != nil {
+			return configFileError(config.DefaultFilePath(), err)
+		}
+		if cfg.RegistryMirror == "" {
+			cfg.RegistryMirror = file.RegistryMirror
+		}
+		if len(file.Languages) > 0 {
+			languages := job.NewLanguageManager()
+			if err := job.RegisterCustomLanguages(languages, file.Languages); err != nil {
+				return configFileError(config.DefaultFilePath(), err)
+			}
+			opts.Languages = languages
+		}
+		return nil
+	}
 	cmd.PersistentFlags().BoolVar(&opts.PrintLogs, "print-logs", false, "Print profiling job logs to console")
+	cmd.PersistentFlags().BoolVar(&opts.AllowPartial, "allow-partial", false, "Save a placeholder graph and exit 0 when the flame graph cannot be extracted, instead of failing")
+	cmd.PersistentFlags().BoolVar(&opts.SaveSession, "save-session", false, "Archive the artifacts of the session into the session store, see 'kubectl pprof sessions'")
+	cmd.PersistentFlags().StringVar(&opts.SessionsDir, "sessions-dir", "", "Session store directory (default $KUBECTL_PPROF_SESSIONS or ~/.kubectl-pprof/sessions)")
+	cmd.PersistentFlags().DurationVar(&opts.NodeCacheTTL, "node-cache-ttl", nodecache.DefaultTTL, "Reuse the kernel capabilities probed on a node for this long, cached in $KUBECTL_PPROF_NODE_CACHE or ~/.kubectl-pprof/nodes; 0 probes every run")
+	cmd.PersistentFlags().StringVar(&opts.RecordSession, "record-session", "", "Record every API request, created object, watch event and log stream of the session into this directory, for 'kubectl pprof replay'")
 
 	// Resource limits (simplified with defaults)
 	var cpuLimit, memoryLimit string
@@ -137,7 +300,7 @@ Examples:
 				cfg.Duration = duration
 			}
 		}
-		if output, _ := cmd.Flags().GetString("out"); output != "" && cfg.OutputPath == "flamegraph.svg" {
+		if output, _ := cmd.Flags().GetString("out"); output != "" && !cmd.Flags().Changed("output") {
 			cfg.OutputPath = output
 		}
 		if format, _ := cmd.Flags().GetString("format"); format != "" && opts.OutputFormat == "svg" {
@@ -155,15 +318,26 @@ Examples:
 
 		// Set resource limits
 		if cpuLimit != "" || memoryLimit != "" {
-			cfg.ResourceLimits = &types.ResourceLimits{
+			cfg.ResourceLimits = &api.ResourceLimits{
 				CPU:    cpuLimit,
 				Memory: memoryLimit,
 			}
 		}
 
-		// Set default configuration for Go language (CPU profiling only)
-		cfg.Language = "go"
-		cfg.ProfileType = "cpu"
+		// Without a language subcommand the job detects it from the target process
+		cfg.Language = string(api.LanguageAuto)
+		if language != "" {
+			lang, err := opts.Languages.ParseLanguage(language)
+			if err != nil {
+				return fmt.Errorf("%w, declare in-house profilers under languages in %s", err, config.DefaultFilePath())
+			}
+			cfg.Language = string(lang)
+			// Custom languages bring their own profiler image
+			if _, err := api.ParseLanguage(language); err != nil && cfg.Image == "golang-profiling:latest" {
+				langConfig, _ := opts.Languages.GetConfig(lang)
+				cfg.Image = langConfig.DefaultImage
+			}
+		}
 		if cfg.Image == "golang-profiling:latest" {
 			cfg.Image = "golang-profiling:latest"
 		}
@@ -187,67 +361,944 @@ Examples:
 	return cmd
 }
 
-func runProfile(ctx context.Context, cfg *types.ProfileConfig, opts *types.ProfileOptions) error {
+// optionalBool is a boolean flag left nil until given, for settings detected
+// from the cluster unless set explicitly
+type optionalBool struct {
+	value **bool
+}
+
+func (b optionalBool) String() string {
+	if *b.value == nil {
+		return "auto"
+	}
+	return strconv.FormatBool(**b.value)
+}
+
+func (b optionalBool) Set(s string) error {
+	value, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b.value = &value
+	return nil
+}
+
+func (b optionalBool) Type() string {
+	return "bool"
+}
+
+// validateImage checks the digest of the profiling image and, with
+// --require-digest, that the image it resolves to is pinned
+func validateImage(cfg *api.ProfileConfig) error {
+	if cfg.ImageDigest != "" {
+		if err := job.ValidateDigest(cfg.ImageDigest); err != nil {
+			return err
+		}
+	}
+	if image := job.ProfilingImage(cfg, nil); cfg.RequireDigest && !job.IsPinned(image) {
+		return fmt.Errorf("--require-digest is set but the profiling image %s is not pinned, pass --image-digest sha256:<digest> or an image reference ending in @sha256:<digest>", image)
+	}
+	return nil
+}
+
+// validateProfileFlags checks the target and the combination of profiling
+// flags, reporting the invalid ones with the usage exit code
+func validateProfileFlags(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
+	return invalidFlags(checkProfileFlags(cfg, opts))
+}
+
+func checkProfileFlags(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
 	// Validate required parameters
-	if cfg.Namespace == "" {
+	if cfg.HostProcess != "" {
+		if err := validateHostProcessFlags(cfg); err != nil {
+			return err
+		}
+	} else if cfg.Namespace == "" {
 		return fmt.Errorf("target namespace is required")
-	}
-	if cfg.PodName == "" {
+	} else if cfg.PodName == "" && cfg.Spread == "" && cfg.TargetImage == "" {
 		return fmt.Errorf("target pod name is required")
 	}
+	if err := applyOutputFormat(cfg, opts); err != nil {
+		return err
+	}
+	if opts.RecordSession != "" && (cfg.AllContainers || cfg.Spread != "" || cfg.AllMatches) {
+		return fmt.Errorf("--record-session records a single session and cannot be combined with --all-containers, --spread or --all")
+	}
+	if cfg.AllContainers && cfg.ContainerName != "" {
+		return fmt.Errorf("--all-containers and --container cannot be used together")
+	}
+	if cfg.ParallelContainers && !cfg.AllContainers {
+		return fmt.Errorf("--parallel requires --all-containers")
+	}
+	if cfg.MergeContainers && !cfg.AllContainers && cfg.Spread == "" && !cfg.AllMatches {
+		return fmt.Errorf("--merge requires --all-containers, --spread or --all")
+	}
+	pids, err := cfg.PIDs()
+	if err != nil {
+		return err
+	}
+	if cfg.SeparateProcesses && len(pids) < 2 && !cfg.AllProcesses && !cfg.IncludeChildren {
+		return fmt.Errorf("--separate-processes needs several processes: --pid with more than one PID, --all-processes or --include-children")
+	}
+	if (len(pids) > 1 || cfg.AllProcesses) && cfg.Mode == api.ModePprof {
+		return fmt.Errorf("sampling several processes needs eBPF and cannot be used with --mode pprof-endpoint")
+	}
+	if cfg.MaxDuration > 0 && cfg.MinSamples == 0 {
+		return fmt.Errorf("--max-duration bounds a profile stopped by --min-samples, use --duration otherwise")
+	}
+	if cfg.MinSamples < 0 {
+		return fmt.Errorf("--min-samples must not be negative")
+	}
+	if cfg.MinSamples > 0 {
+		if cfg.ProfileType != api.ProfileTypeCPU || cfg.Mode == api.ModePprof {
+			return fmt.Errorf("--min-samples counts eBPF on-CPU samples and needs --profile-type cpu without --mode pprof-endpoint")
+		}
+		if cfg.MaxDuration > 0 {
+			cfg.Duration = cfg.MaxDuration
+		}
+	}
+	if err := cfg.ValidateTimings(); err != nil {
+		return err
+	}
+	if _, err := job.ParseTolerations(cfg.Tolerations); err != nil {
+		return err
+	}
+	if _, err := job.LoadScriptTemplate(cfg.ScriptTemplate); err != nil {
+		return err
+	}
+	if c := cfg.GetCompression(); c != api.CompressionGzip && c != api.CompressionZstd {
+		return fmt.Errorf("invalid --compression %q, must be %s or %s", c, api.CompressionGzip, api.CompressionZstd)
+	}
+	if cfg.DirectExec {
+		if cfg.Mode != api.ModeAuto && cfg.Mode != api.ModeJob {
+			return fmt.Errorf("--direct-exec runs the profiling Job and cannot be used with --mode %s", cfg.Mode)
+		}
+		if cfg.ScriptTemplate != "" || cfg.Live {
+			return fmt.Errorf("--direct-exec runs no shell script and cannot be combined with --script-template or --live")
+		}
+	}
+	switch cfg.Transfer {
+	case "", api.TransferLogs:
+	case api.TransferPortForward:
+		if !cfg.DirectExec {
+			return fmt.Errorf("--transfer %s needs the launcher of --direct-exec", api.TransferPortForward)
+		}
+		if cfg.NoCollect || opts.RecordSession != "" {
+			return fmt.Errorf("--transfer %s cannot be combined with --no-collect or --record-session, their artifacts are read from the logs", api.TransferPortForward)
+		}
+	default:
+		return fmt.Errorf("invalid --transfer %q, must be %s or %s", cfg.Transfer, api.TransferPortForward, api.TransferLogs)
+	}
+	// Nobody fetches the artifacts of a detached Job, and a recorded session
+	// is replayed from its logs
+	if cfg.Transfer == "" && (cfg.NoCollect || opts.RecordSession != "") {
+		cfg.Transfer = api.TransferLogs
+	}
+	if err := validateImage(cfg); err != nil {
+		return err
+	}
+	if cfg.NoCollect {
+		if cfg.Mode != api.ModeAuto && cfg.Mode != api.ModeJob {
+			return fmt.Errorf("--no-collect leaves a profiling Job running and cannot be used with --mode %s", cfg.Mode)
+		}
+		if cfg.Live || cfg.AllContainers || cfg.Spread != "" || cfg.AllMatches || cfg.Retarget || opts.RecordSession != "" {
+			return fmt.Errorf("--no-collect starts a single Job and cannot be combined with --live, --all-containers, --spread, --all, --retarget or --record-session")
+		}
+		cfg.Mode = api.ModeJob
+	}
+	if cfg.Live {
+		if cfg.AllContainers || cfg.Spread != "" || cfg.AllMatches {
+			return fmt.Errorf("--live renders a single output and cannot be combined with --all-containers, --spread or --all")
+		}
+		if opts.OutputFormat == "json" {
+			return fmt.Errorf("--live renders flame graphs and cannot be used with --output-format json")
+		}
+		if cfg.Mode == api.ModePprof || cfg.ProfileType == api.ProfileTypeHeap {
+			return fmt.Errorf("--live needs eBPF sampling and cannot be used with --mode pprof-endpoint or --profile-type heap")
+		}
+		if cfg.LiveInterval < time.Second {
+			return fmt.Errorf("--live-interval must be at least 1s")
+		}
+	}
+	if cfg.AllMatches && cfg.TargetImage == "" {
+		return fmt.Errorf("--all requires --target-image")
+	}
+	if cfg.TargetImage != "" && (cfg.PodName != "" || cfg.ContainerName != "" || cfg.AllContainers || cfg.Spread != "") {
+		return fmt.Errorf("--target-image picks the pod and container itself and cannot be used with --target-pod, --container, --all-containers or --spread")
+	}
+	switch cfg.Mode {
+	case api.ModeAuto, api.ModeJob, api.ModeEphemeral, api.ModePprof:
+	default:
+		return fmt.Errorf("invalid mode '%s', must be one of: auto, job, ephemeral, pprof-endpoint", cfg.Mode)
+	}
+	if cfg.Spread != "" {
+		if cfg.PodName != "" || cfg.AllContainers {
+			return fmt.Errorf("--spread picks the pods itself and cannot be used with --target-pod or --all-containers")
+		}
+		if cfg.Mode != api.ModeAuto && cfg.Mode != api.ModeJob {
+			return fmt.Errorf("--spread runs one Job per node and cannot be used with --mode %s", cfg.Mode)
+		}
+		if cfg.MaxConcurrentJobs < 1 {
+			return fmt.Errorf("--max-concurrent must be at least 1")
+		}
+		cfg.Mode = api.ModeJob
+	}
+	if !containsString(endpoint.Profiles, cfg.PprofProfile) {
+		return fmt.Errorf("invalid pprof profile '%s', must be one of: %s", cfg.PprofProfile, strings.Join(endpoint.Profiles, ", "))
+	}
+	if cfg.Mode == api.ModePprof && cfg.GoOptions != nil && (cfg.GoOptions.FlameChart || cfg.GoOptions.OffCPU) {
+		return fmt.Errorf("--go-flame-chart and --off-cpu need eBPF sampling and cannot be used with --mode pprof-endpoint")
+	}
+	if cfg.Mode == api.ModePprof && cfg.IncludeKernelStacks {
+		return fmt.Errorf("--include-kernel-stacks needs eBPF sampling, the Go runtime profiles only user frames with --mode pprof-endpoint")
+	}
+	if cfg.PerCPU {
+		if cfg.Mode == api.ModePprof {
+			return fmt.Errorf("--per-cpu needs eBPF sampling, the Go runtime does not record the CPU of its samples with --mode pprof-endpoint")
+		}
+		if cfg.ProfileType != api.ProfileTypeCPU {
+			return fmt.Errorf("--per-cpu breaks down on-CPU samples and only works with --profile-type cpu")
+		}
+		if cfg.GoOptions != nil && cfg.GoOptions.OffCPU {
+			return fmt.Errorf("--per-cpu cannot be combined with --off-cpu, blocked stacks run on no CPU")
+		}
+	}
+	switch annotate := cfg.GetAnnotate(); annotate {
+	case api.AnnotateNone:
+	case api.AnnotateThread:
+		if cfg.Mode == api.ModePprof || cfg.ProfileType == api.ProfileTypeHeap {
+			return fmt.Errorf("--annotate thread needs eBPF sampling, the Go runtime does not record threads in its profiles")
+		}
+		if cfg.ProfileType == api.ProfileTypeSchedLat {
+			return fmt.Errorf("--annotate thread cannot be used with --profile-type schedlat")
+		}
+	case api.AnnotateLabels:
+	default:
+		return fmt.Errorf("invalid --annotate %q, must be %s, %s or %s", annotate, api.AnnotateNone, api.AnnotateThread, api.AnnotateLabels)
+	}
+	switch cfg.Granularity {
+	case "", api.GranularityFunctions, api.GranularityLines:
+	default:
+		return fmt.Errorf("invalid --granularity %q, must be %s or %s", cfg.Granularity, api.GranularityFunctions, api.GranularityLines)
+	}
+	if cfg.List != "" {
+		if _, err := regexp.Compile(cfg.List); err != nil {
+			return fmt.Errorf("invalid --list %q: %w", cfg.List, err)
+		}
+		if cfg.GetGranularity() != api.GranularityLines {
+			return fmt.Errorf("--list needs the source lines of --granularity lines")
+		}
+		if cfg.ProfileType == api.ProfileTypeHeap {
+			return fmt.Errorf("--list cannot be used with --profile-type heap")
+		}
+	}
+	if cfg.ExportSummary != "" && cfg.ProfileType == api.ProfileTypeHeap {
+		return fmt.Errorf("--export-summary summarizes sampled stacks and cannot be used with --profile-type heap")
+	}
+	if cfg.Baseline != "" {
+		if cfg.ExportSummary == "" {
+			return fmt.Errorf("--baseline lists the changes in the --export-summary, which is not requested")
+		}
+		baseline, err := resolveStacks(store.New(opts.SessionsDir), cfg.Baseline)
+		if err != nil {
+			return fmt.Errorf("invalid --baseline: %w", err)
+		}
+		cfg.Baseline = baseline
+	}
+	if cfg.Analyze && cfg.ProfileType != api.ProfileTypeCPU {
+		return fmt.Errorf("--analyze reads CPU stacks and only works with --profile-type cpu")
+	}
+	if cfg.SourceURLTemplate != "" {
+		if !strings.Contains(cfg.SourceURLTemplate, "{file}") {
+			return fmt.Errorf("--source-url-template must contain {file}, e.g. https://github.com/org/repo/blob/{commit}/{file}#L{line}")
+		}
+		if opts.OutputFormat != "html" {
+			return fmt.Errorf("--source-url-template links the frames of the HTML report and needs --output-format html")
+		}
+		if cfg.GetGranularity() != api.GranularityLines {
+			return fmt.Errorf("--source-url-template needs the source lines of --granularity lines")
+		}
+		if cfg.Mode == api.ModePprof || cfg.ProfileType == api.ProfileTypeHeap || cfg.ReadsLabels() {
+			return fmt.Errorf("--source-url-template reads the VCS revision from the target executable in a profiling Job and cannot be used with the pprof endpoint")
+		}
+	}
+	for i, format := range cfg.AlsoFormats {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if !containsString(flamegraph.ConvertFormats, format) {
+			return fmt.Errorf("invalid --also-format '%s', must be one of: %s", format, strings.Join(flamegraph.ConvertFormats, ", "))
+		}
+		if format == opts.OutputFormat {
+			return fmt.Errorf("--also-format %s is already the --output-format", format)
+		}
+		cfg.AlsoFormats[i] = format
+	}
+	if len(cfg.AlsoFormats) > 0 && (cfg.Mode == api.ModePprof || cfg.ProfileType == api.ProfileTypeHeap || cfg.ReadsLabels()) {
+		return fmt.Errorf("--also-format converts the stacks of a profiling Job and cannot be used with the pprof endpoint")
+	}
+	for _, filter := range cfg.TagFilters {
+		if _, err := flamegraph.ParseTagFilter(filter); err != nil {
+			return err
+		}
+	}
+	if cfg.ReadsLabels() {
+		if cfg.Mode == api.ModeJob || cfg.Mode == api.ModeEphemeral {
+			return fmt.Errorf("--annotate labels, --tag-filter and --traces read the pprof endpoint and cannot be used with --mode %s", cfg.Mode)
+		}
+		if cfg.ProfileType != api.ProfileTypeCPU {
+			return fmt.Errorf("--annotate labels, --tag-filter and --traces only work with --profile-type cpu, the labels are recorded in CPU profiles")
+		}
+		if cfg.PerCPU || cfg.IncludeKernelStacks || cfg.GoOptions != nil && (cfg.GoOptions.FlameChart || cfg.GoOptions.OffCPU) {
+			return fmt.Errorf("--annotate labels, --tag-filter and --traces read the pprof endpoint and cannot be combined with --per-cpu, --include-kernel-stacks, --go-flame-chart or --off-cpu")
+		}
+	}
+	if cfg.ThrottleWarnPercent < 0 || cfg.ThrottleWarnPercent > 100 {
+		return fmt.Errorf("--throttle-warn-percent must be between 0 and 100")
+	}
+	switch cfg.ProfileType {
+	case api.ProfileTypeCPU:
+	case api.ProfileTypeSchedLat:
+		if cfg.Mode == api.ModePprof {
+			return fmt.Errorf("--profile-type schedlat traces the scheduler with eBPF and cannot be used with --mode pprof-endpoint")
+		}
+		if cfg.GoOptions != nil && (cfg.GoOptions.FlameChart || cfg.GoOptions.OffCPU) {
+			return fmt.Errorf("--profile-type schedlat cannot be combined with --go-flame-chart or --off-cpu")
+		}
+	case api.ProfileTypeNet:
+		if cfg.Mode == api.ModePprof {
+			return fmt.Errorf("--profile-type net traces syscalls with eBPF and cannot be used with --mode pprof-endpoint")
+		}
+		if cfg.GoOptions != nil && (cfg.GoOptions.FlameChart || cfg.GoOptions.OffCPU) {
+			return fmt.Errorf("--profile-type net cannot be combined with --go-flame-chart or --off-cpu")
+		}
+		if cfg.NetThreshold < 0 {
+			return fmt.Errorf("--net-threshold must not be negative")
+		}
+	case api.ProfileTypeHeap:
+		if cfg.Mode == api.ModeJob || cfg.Mode == api.ModeEphemeral {
+			return fmt.Errorf("--profile-type heap reads the pprof endpoint and cannot be used with --mode %s", cfg.Mode)
+		}
+		if cfg.GoOptions != nil && (cfg.GoOptions.FlameChart || cfg.GoOptions.OffCPU) {
+			return fmt.Errorf("--profile-type heap cannot be combined with --go-flame-chart or --off-cpu")
+		}
+		if cfg.Snapshots < 2 || cfg.Snapshots > 100 {
+			return fmt.Errorf("--snapshots must be between 2 and 100")
+		}
+	default:
+		return fmt.Errorf("invalid profile type '%s', must be one of: cpu, schedlat, heap, net", cfg.ProfileType)
+	}
+	// Last, the flags are valid by now: a run that writes nothing must not
+	// fail only after sampling for the whole duration
+	return prepareOutputPath(cfg)
+}
 
-	// Simple output - only basic initialization info
-	if !opts.Quiet {
-		fmt.Println("ℹ️  🔍 Initializing profiling session...")
+// validateHostProcessFlags rejects the pod targeting flags and the modes a
+// host process target cannot use, see 'kubectl pprof node'
+func validateHostProcessFlags(cfg *api.ProfileConfig) error {
+	if cfg.NodeName == "" {
+		return fmt.Errorf("a node is required to profile a host process")
+	}
+	if err := job.ValidateHostProcess(cfg.HostProcess); err != nil {
+		return err
+	}
+	if cfg.PodName != "" || cfg.ContainerName != "" || cfg.AllContainers || cfg.Spread != "" || cfg.TargetImage != "" || cfg.PID != "" {
+		return fmt.Errorf("--process profiles a node process and cannot be used with --target-pod, --container, --all-containers, --spread, --target-image or --pid")
+	}
+	if cfg.Mode != api.ModeAuto && cfg.Mode != api.ModeJob {
+		return fmt.Errorf("--process needs a hostPID Job and cannot be used with --mode %s", cfg.Mode)
 	}
+	if cfg.ProfileType == api.ProfileTypeHeap {
+		return fmt.Errorf("--process samples with eBPF and cannot be used with --profile-type heap")
+	}
+	if cfg.Retarget {
+		return fmt.Errorf("--retarget follows pods and cannot be used with --process")
+	}
+	return nil
+}
 
-	// Load Kubernetes config
-	if !opts.Quiet {
-		fmt.Println(" Loading Kubernetes configuration... ✅")
+func runProfile(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
+	if err := validateProfileFlags(cfg, opts); err != nil {
+		return err
 	}
+	prepareSessionSave(cfg, opts)
+
+	log := opts.Log()
+	log.Info("Initializing profiling session", "namespace", cfg.Namespace, "pod", cfg.PodName)
+
+	// Load Kubernetes config
+	log.Log(ctx, logging.V(1), "Loading Kubernetes configuration")
 	k8sConfig, err := config.LoadKubernetesConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load kubernetes config: %w", err)
 	}
+	if opts.RecordSession != "" {
+		recorder, err := record.NewRecorder(opts.RecordSession)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		if k8sConfig, err = recorder.Wrap(k8sConfig); err != nil {
+			return err
+		}
+		log.Info("Recording session", "dir", opts.RecordSession)
+	}
 
 	// Create profiler
-	if !opts.Quiet {
-		fmt.Println(" Creating profiler client... ✅")
-	}
+	log.Log(ctx, logging.V(1), "Creating profiler client")
 	profilerClient, err := profiler.NewProfiler(k8sConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create profiler: %w", err)
 	}
 
 	// Start profiling
-	if !opts.Quiet {
-		fmt.Println("ℹ️  🚀 Starting profiling job...")
+	log.Info("Starting profiling job")
+
+	if cfg.AllContainers {
+		return runAllContainers(ctx, profilerClient, cfg, opts)
+	}
+	if cfg.Spread != "" {
+		return runSpread(ctx, profilerClient, cfg, opts)
+	}
+	if cfg.TargetImage != "" {
+		targets, err := profilerClient.ImageTargets(ctx, cfg.Namespace, cfg.TargetImage)
+		if err != nil {
+			return err
+		}
+		if cfg.AllMatches {
+			return runImageTargets(ctx, profilerClient, cfg, opts, targets)
+		}
+		target, err := pickImageTarget(targets, opts)
+		if err != nil {
+			return err
+		}
+		cfg.PodName, cfg.ContainerName = target.Pod, target.Container
+		log.Info("Resolved target", "pod", target.Pod, "container", target.Container, "image", target.Image)
+	}
+
+	// Ctrl-C stops a live session early, keeping its last snapshot
+	if cfg.Live {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
 	}
+	started := time.Now()
 
 	// Run profiling with simple progress indication
 	result, err := profilerClient.Profile(ctx, cfg, opts)
 	if err != nil {
+		if cfg.Live && ctx.Err() != nil {
+			return liveStopped(cfg, opts, started, err)
+		}
 		return fmt.Errorf("profiling failed: %w", err)
 	}
+	saveSessions(opts, result)
 
+	if cfg.NoCollect {
+		if !opts.Quiet {
+			fmt.Printf("Profiling job started: %s/%s\n", cfg.GetJobNamespace(), result.JobName)
+			fmt.Printf("Collect the results once it finishes with: kubectl pprof collect %s --job-namespace %s\n", result.JobName, cfg.GetJobNamespace())
+		}
+		return nil
+	}
 	if !opts.Quiet {
+		printProfileResult(cfg, result)
 		fmt.Printf("Profiling completed! Output: %s\n", result.OutputPath)
 	}
 
 	return nil
 }
 
+// printProfileResult prints the reports of a session besides its output
+func printProfileResult(cfg *api.ProfileConfig, result *api.ProfileResult) {
+	printPreflightWarnings(result.Preflight)
+	if result.Detection != "" {
+		fmt.Printf("🔍 Detected %s: %s, sampled with %s\n", result.Language, result.Detection, result.Backend)
+	}
+	if result.Methodology != "" {
+		fmt.Printf("ℹ️  Note: %s\n", result.Methodology)
+	}
+	printBuildInfo(result.BuildInfo)
+	printOverhead(result.Overhead)
+	printGoroutines(result.Goroutines)
+	printSchedLatency(result.SchedLatency)
+	printCPUBreakdown(result.CPUBreakdown)
+	printListing(cfg.List, result.Listing)
+	printFindings(cfg.Analyze, result.Findings)
+	printTraces(result.Traces)
+	printNet(result.Net)
+	printThrottling(result.Throttling)
+	printSampleTarget(result.SampleTarget)
+	printSampleStats(result.SampleStats)
+	printDiagnosis(result.Diagnosis)
+	printHeapGrowth(result.HeapGrowth)
+	printContention(result.Contention)
+	if result.Metadata != nil {
+		printRuntime(result.Metadata.Runtime)
+		printResourceUsage(result.Metadata.ResourceUsage)
+	}
+	if result.Error != "" {
+		fmt.Printf("Warning: the output is a placeholder (--allow-partial): %s\n", result.Error)
+	}
+}
+
+// liveStopped reports a live session stopped early, whose output file holds
+// the last snapshot rendered before the interruption, if any
+func liveStopped(cfg *api.ProfileConfig, opts *api.ProfileOptions, started time.Time, err error) error {
+	info, statErr := os.Stat(cfg.OutputPath)
+	if statErr != nil || info.ModTime().Before(started) {
+		return fmt.Errorf("profiling stopped before the first live snapshot: %w", err)
+	}
+	if !opts.Quiet {
+		fmt.Printf("Profiling stopped early! Last live snapshot: %s\n", cfg.OutputPath)
+	}
+	return nil
+}
+
+// runAllContainers profiles every container of the pod and prints a summary
+func runAllContainers(ctx context.Context, profilerClient *profiler.Profiler, cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
+	multi, err := profilerClient.ProfileAllContainers(ctx, cfg, opts)
+	if multi != nil {
+		saveSessions(opts, multi.Results...)
+	}
+	if multi != nil && !opts.Quiet {
+		for _, result := range multi.Results {
+			fmt.Printf("✅ %s: %s\n", result.Config.ContainerName, result.OutputPath)
+		}
+		failed := make([]string, 0, len(multi.Failures))
+		for container := range multi.Failures {
+			failed = append(failed, container)
+		}
+		sort.Strings(failed)
+		for _, container := range failed {
+			fmt.Printf("❌ %s: %s\n", container, multi.Failures[container])
+		}
+		if multi.MergedPath != "" {
+			fmt.Printf("Merged output: %s\n", multi.MergedPath)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("profiling failed: %w", err)
+	}
+	if len(multi.Failures) > 0 {
+		return fmt.Errorf("profiling failed for %d of %d containers", len(multi.Failures), len(multi.Failures)+len(multi.Results))
+	}
+	return nil
+}
+
+// runSpread profiles one pod per node of the workload and prints a summary
+func runSpread(ctx context.Context, profilerClient *profiler.Profiler, cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
+	multi, err := profilerClient.ProfileSpread(ctx, cfg, opts)
+	if multi != nil {
+		saveSessions(opts, multi.Results...)
+	}
+	if multi != nil && !opts.Quiet {
+		for _, result := range multi.Results {
+			fmt.Printf("✅ %s (%s): %s\n", result.Config.NodeName, result.Config.PodName, result.OutputPath)
+		}
+		failed := make([]string, 0, len(multi.Failures))
+		for node := range multi.Failures {
+			failed = append(failed, node)
+		}
+		sort.Strings(failed)
+		for _, node := range failed {
+			fmt.Printf("❌ %s: %s\n", node, multi.Failures[node])
+		}
+		if multi.MergedPath != "" {
+			fmt.Printf("Merged output: %s\n", multi.MergedPath)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("profiling failed: %w", err)
+	}
+	if len(multi.Failures) > 0 {
+		return fmt.Errorf("profiling failed for %d of %d nodes", len(multi.Failures), len(multi.Failures)+len(multi.Results))
+	}
+	return nil
+}
+
+// runImageTargets profiles every container matching the image glob and prints a summary
+func runImageTargets(ctx context.Context, profilerClient *profiler.Profiler, cfg *api.ProfileConfig, opts *api.ProfileOptions, targets []discovery.ImageTarget) error {
+	multi, err := profilerClient.ProfileImageTargets(ctx, cfg, opts, targets)
+	if multi != nil {
+		saveSessions(opts, multi.Results...)
+	}
+	if multi != nil && !opts.Quiet {
+		for _, result := range multi.Results {
+			fmt.Printf("✅ %s/%s: %s\n", result.Config.PodName, result.Config.ContainerName, result.OutputPath)
+		}
+		failed := make([]string, 0, len(multi.Failures))
+		for name := range multi.Failures {
+			failed = append(failed, name)
+		}
+		sort.Strings(failed)
+		for _, name := range failed {
+			fmt.Printf("❌ %s: %s\n", name, multi.Failures[name])
+		}
+		if multi.MergedPath != "" {
+			fmt.Printf("Merged output: %s\n", multi.MergedPath)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("profiling failed: %w", err)
+	}
+	if len(multi.Failures) > 0 {
+		return fmt.Errorf("profiling failed for %d of %d containers", len(multi.Failures), len(multi.Failures)+len(multi.Results))
+	}
+	return nil
+}
+
+// pickImageTarget returns the only container matching the image glob, or asks
+// which one to profile when several match and stdin is a terminal
+func pickImageTarget(targets []discovery.ImageTarget, opts *api.ProfileOptions) (discovery.ImageTarget, error) {
+	if len(targets) == 1 {
+		return targets[0], nil
+	}
+
+	var list strings.Builder
+	for i, target := range targets {
+		fmt.Fprintf(&list, "  %d) %s/%s  %s  (node %s)\n", i+1, target.Pod, target.Container, target.Image, target.Node)
+	}
+	if opts.Quiet || !isTerminal(os.Stdin) {
+		return discovery.ImageTarget{}, fmt.Errorf("%d containers match the image, pass --all or --target-pod:\n%s", len(targets), strings.TrimRight(list.String(), "\n"))
+	}
+
+	fmt.Fprintf(os.Stderr, "%d containers match the image:\n%s", len(targets), list.String())
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Profile which one [1-%d]? ", len(targets))
+		line, err := reader.ReadString('\n')
+		if choice, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && choice >= 1 && choice <= len(targets) {
+			return targets[choice-1], nil
+		}
+		if err != nil {
+			return discovery.ImageTarget{}, fmt.Errorf("no target picked")
+		}
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// applyOutputFormat validates the output format and names the default output
+// after the current time, with the matching extension
+func applyOutputFormat(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
+	opts.OutputFormat = strings.ToLower(opts.OutputFormat)
+	switch opts.OutputFormat {
+	case "svg", "png", "pdf", "html", "json":
+	default:
+		return fmt.Errorf("invalid output format '%s', must be one of: svg, png, pdf, html, json", opts.OutputFormat)
+	}
+	if opts.DPI < 24 || opts.DPI > 1200 {
+		return fmt.Errorf("dpi must be between 24 and 1200")
+	}
+
+	if cfg.OutputPath == "" {
+		cfg.OutputPath = profiler.DefaultOutputPath(opts.OutputFormat)
+	}
+	return nil
+}
+
+// printPreflightWarnings prints non-blocking kernel preflight findings
+func printPreflightWarnings(report *api.PreflightReport) {
+	if report == nil {
+		return
+	}
+	for _, check := range report.Warnings {
+		fmt.Printf("⚠️  Preflight: %s\n", job.PreflightRemediation(report, check))
+	}
+}
+
+// printOverhead prints the estimated and measured profiling cost
+func printOverhead(report *api.OverheadReport) {
+	if report == nil {
+		return
+	}
+	if report.NodeCPUs > 0 {
+		fmt.Printf("📊 Estimated sampling overhead: %.2f%% of %d node CPUs (%d samples)\n",
+			report.EstimatedCPUPercent, report.NodeCPUs, report.ExpectedSamples)
+	}
+	if report.ProfilerCPU > 0 {
+		memory := ""
+		if report.ProfilerMemory > 0 {
+			memory = fmt.Sprintf(", peak memory %.1f MiB", float64(report.ProfilerMemory)/(1<<20))
+		}
+		fmt.Printf("📊 Profiler CPU usage: %v (%.1f%% of one core)%s\n",
+			report.ProfilerCPU.Round(time.Millisecond), report.ProfilerCPUPercent, memory)
+	}
+	if throttling := report.ProfilerThrottling; throttling != nil && throttling.Warning != "" {
+		fmt.Printf("⚠️  Profiler throttled: %d of %d periods (%.1f%%), %v held back\n",
+			throttling.ThrottledPeriods, throttling.Periods, throttling.ThrottledPercent, throttling.ThrottledTime.Round(time.Millisecond))
+	}
+}
+
+// printRuntime prints the GC and scheduler behavior over the profiling window
+func printRuntime(report *api.RuntimeMetricsReport) {
+	if report == nil {
+		return
+	}
+	fmt.Printf("♻️  GC: %d cycles, %v paused (max %v), heap %+d bytes, goroutines %d → %d\n",
+		report.GCCycles, report.GCPauseTotal, report.GCPauseMax, report.HeapDelta,
+		report.Start.Goroutines, report.End.Goroutines)
+	if report.SchedLatencyP99 > 0 {
+		fmt.Printf("♻️  Scheduling latency p99: %v\n", report.SchedLatencyP99)
+	}
+}
+
+// printGoroutines prints the goroutine counts by state, most common first
+func printGoroutines(report *api.GoroutineReport) {
+	if report == nil {
+		return
+	}
+	states := make([]string, 0, len(report.States))
+	for state := range report.States {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if report.States[states[i]] != report.States[states[j]] {
+			return report.States[states[i]] > report.States[states[j]]
+		}
+		return states[i] < states[j]
+	})
+	fmt.Printf("🧵 Goroutines: %d\n", report.Total)
+	for _, state := range states {
+		fmt.Printf("   %-24s %d\n", state, report.States[state])
+	}
+}
+
+// printHeapGrowth prints the in-use heap of each snapshot and the allocation
+// sites that grew the most
+func printHeapGrowth(report *api.HeapGrowthReport) {
+	if report == nil {
+		return
+	}
+	sizes := make([]string, len(report.Snapshots))
+	for i, snapshot := range report.Snapshots {
+		sizes[i] = profiler.FormatBytes(uint64(snapshot.InuseBytes))
+	}
+	fmt.Printf("📈 Heap in use: %s (%s)\n", strings.Join(sizes, " → "), signedBytes(report.GrowthBytes))
+	if len(report.TopSites) == 0 {
+		fmt.Println("   No allocation site grew between the first and the last snapshot")
+		return
+	}
+	for _, site := range report.TopSites {
+		fmt.Printf("   %12s %+8d objects  %s\n", signedBytes(site.BytesDelta), site.ObjectsDelta, site.Function)
+	}
+}
+
+// printContention prints how often and how long goroutines waited on
+// channels and locks during the profile
+func printContention(reports []api.ContentionReport) {
+	for _, report := range reports {
+		fmt.Printf("🔒 %s contention: %d events, %v waited (%s)\n",
+			report.Profile, report.Contentions, report.Delay.Round(time.Microsecond), report.OutputPath)
+	}
+}
+
+// signedBytes formats a byte delta with its sign
+func signedBytes(n int64) string {
+	if n < 0 {
+		return "-" + profiler.FormatBytes(uint64(-n))
+	}
+	return "+" + profiler.FormatBytes(uint64(n))
+}
+
+// printResourceUsage prints the CPU and memory usage of the target during the
+// profile against its requests and limits
+func printResourceUsage(report *api.ResourceUsageReport) {
+	if report == nil {
+		return
+	}
+	fmt.Printf("📊 CPU usage: %s\n", profiler.FormatCPUUsage(report))
+	fmt.Printf("📊 Memory usage: %s (%d readings from %s)\n", profiler.FormatMemoryUsage(report), report.Samples, report.Source)
+	if report.Warning != "" {
+		fmt.Printf("Warning: %s\n", report.Warning)
+	}
+}
+
+// printThrottling prints the CPU throttling of the target during the profile
+func printThrottling(report *api.ThrottlingReport) {
+	if report == nil {
+		return
+	}
+	if report.Periods == 0 {
+		fmt.Println("🐢 CPU throttling: none (no CPU limit or no runnable periods)")
+		return
+	}
+	fmt.Printf("🐢 CPU throttling: %d of %d periods (%.1f%%), %v throttled\n",
+		report.ThrottledPeriods, report.Periods, report.ThrottledPercent, report.ThrottledTime.Round(time.Millisecond))
+	if report.Warning != "" {
+		fmt.Printf("Warning: %s\n", report.Warning)
+	}
+}
+
+// printFindings prints the problems --analyze recognized, largest share first
+func printFindings(analyzed bool, findings []api.Finding) {
+	if !analyzed {
+		return
+	}
+	if len(findings) == 0 {
+		fmt.Println("🔎 Findings: none of the known problems shows in the stacks")
+		return
+	}
+	fmt.Println("🔎 Findings:")
+	analysis.WriteFindings(os.Stdout, findings)
+}
+
+// maxPrintedTraces bounds the trace IDs printed, the JSON output has more
+const maxPrintedTraces = 5
+
+// printTraces prints the traces active during the window, the most sampled first
+func printTraces(report *api.TraceReport) {
+	if report == nil {
+		return
+	}
+	fmt.Printf("🔗 Traces (%s label): %s\n", report.Label, profiler.TraceSummary(report))
+	for i, trace := range report.Traces {
+		if i == maxPrintedTraces {
+			fmt.Printf("   ... %d more in the JSON output\n", report.Distinct-i)
+			break
+		}
+		fmt.Printf("   %s  %d samples\n", trace.TraceID, trace.Samples)
+	}
+}
+
+// printBuildInfo prints the Go build of the target the profile was taken from
+func printBuildInfo(info *api.BuildInfo) {
+	if info == nil {
+		return
+	}
+	fmt.Printf("🏗️  Build: %s\n", profiler.BuildSummary(info))
+	if info.Revision != "" {
+		fmt.Printf("   Revision: %s\n", profiler.RevisionSummary(info))
+	}
+	if len(info.Flags) > 0 {
+		fmt.Printf("   Flags: %s\n", profiler.BuildFlags(info))
+	}
+}
+
+// printSampleTarget prints how many samples a profile stopped at a sample count captured
+func printSampleTarget(report *api.SampleTargetReport) {
+	if report == nil {
+		return
+	}
+	if report.Reached {
+		fmt.Printf("🎯 Samples: %d captured in %v, target of %d reached\n", report.Samples, report.Elapsed.Round(time.Second), report.MinSamples)
+		return
+	}
+	fmt.Printf("🎯 Samples: %d captured in %v, target of %d not reached (the target is mostly idle; try --off-cpu or a longer --max-duration)\n",
+		report.Samples, report.Elapsed.Round(time.Second), report.MinSamples)
+}
+
+// printSampleStats prints the sample count and what was lost on the way
+func printSampleStats(stats *api.SampleStats) {
+	if stats == nil {
+		return
+	}
+	fmt.Printf("📊 Samples: %d on-CPU, %d dropped, %d stacks lost, %d truncated; %d of %d frames unsymbolized\n",
+		stats.Samples, stats.Dropped, stats.LostStacks, stats.Truncated, stats.Unsymbolized, stats.Frames)
+	if stats.Dropped > 0 || stats.LostStacks > 0 {
+		fmt.Println("Warning: the profiler's maps overflowed, the graph under-counts; profile for a shorter --duration or at a lower --frequency")
+	}
+}
+
+// printDiagnosis prints the likely causes of a profile with too few samples
+func printDiagnosis(diagnosis *api.SampleDiagnosis) {
+	if diagnosis == nil {
+		return
+	}
+	fmt.Printf("🔍 Only %d on-CPU samples (a fully busy thread yields about %d), possible causes:\n", diagnosis.Samples, diagnosis.Expected)
+	for _, cause := range diagnosis.Causes {
+		fmt.Printf("  - %s\n", cause)
+	}
+}
+
+// printNet prints the network calls per remote endpoint, slowest first
+func printNet(report *api.NetReport) {
+	if report == nil {
+		return
+	}
+	if len(report.Endpoints) == 0 {
+		fmt.Println("🌐 Network calls: no TCP or UDP traffic of the target during the profile")
+		return
+	}
+	fmt.Println("🌐 Network calls by remote endpoint:")
+	fmt.Printf("   %-40s %8s %8s %10s %10s %12s %10s\n", "REMOTE", "CONNS", "CALLS", "SENT", "RECEIVED", "TOTAL", "MAX")
+	for _, e := range report.Endpoints {
+		fmt.Printf("   %-40s %8d %8d %10s %10s %12v %10v\n", e.Remote, e.Connections, e.Calls,
+			profiler.FormatBytes(e.BytesSent), profiler.FormatBytes(e.BytesReceived),
+			e.TotalLatency.Round(time.Microsecond), e.MaxLatency.Round(time.Microsecond))
+	}
+}
+
+// printListing prints the hot source lines of the functions matching --list
+func printListing(pattern, listing string) {
+	if pattern == "" {
+		return
+	}
+	if listing == "" {
+		fmt.Printf("📄 --list %q matched no sampled function with source lines\n", pattern)
+		return
+	}
+	fmt.Print(listing)
+}
+
+// printCPUBreakdown prints the on-CPU samples of every CPU, busiest first
+func printCPUBreakdown(report *api.CPUBreakdownReport) {
+	if report == nil {
+		return
+	}
+	if len(report.CPUs) == 0 {
+		fmt.Println("🧮 Per-CPU samples: no stack was rooted at a cpuN frame, the sampler of this language does not record CPUs")
+		return
+	}
+	fmt.Println("🧮 On-CPU samples by CPU:")
+	fmt.Printf("   %-6s %8s %6s  %s\n", "CPU", "SAMPLES", "BUSY", "TOP FRAME")
+	for _, cpu := range report.CPUs {
+		fmt.Printf("   cpu%-3d %8d %5.0f%%  %s (%.0f%%)\n", cpu.CPU, cpu.Samples, cpu.Busy*100, cpu.TopFrame, cpu.TopShare*100)
+	}
+	if report.Pegged != "" {
+		fmt.Printf("⚠️  %s\n", report.Pegged)
+	}
+}
+
+// printSchedLatency prints the run queue latency histogram as text bars
+func printSchedLatency(report *api.SchedLatencyReport) {
+	if report == nil {
+		return
+	}
+	if report.Count == 0 {
+		fmt.Println("⏱️  Scheduling latency: no waits measured, the target's threads never queued for a CPU")
+		return
+	}
+	fmt.Printf("⏱️  Scheduling latency: %d waits, p50 < %v, p99 < %v, max < %v\n",
+		report.Count, report.P50, report.P99, report.Max)
+
+	const barWidth = 40
+	var peak uint64
+	for _, bucket := range report.Buckets {
+		if bucket.Count > peak {
+			peak = bucket.Count
+		}
+	}
+	for _, bucket := range report.Buckets {
+		bar := strings.Repeat("█", int(bucket.Count*barWidth/peak))
+		fmt.Printf("   %10v - %-10v %10d |%-*s|\n", bucket.Low, bucket.High, bucket.Count, barWidth, bar)
+	}
+}
+
 // validateConfig performs basic validation of profiling configuration
-func validateConfig(cfg *types.ProfileConfig, opts *types.ProfileOptions) error {
+func validateConfig(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
 	// Basic validation
 	if cfg.Namespace == "" {
-		return fmt.Errorf("namespace is required")
+		return invalidFlags(fmt.Errorf("namespace is required"))
 	}
-	if cfg.PodName == "" {
-		return fmt.Errorf("pod name is required")
+	if cfg.PodName == "" && cfg.Spread == "" && cfg.TargetImage == "" {
+		return invalidFlags(fmt.Errorf("pod name is required"))
 	}
 	if cfg.Duration <= 0 {
-		return fmt.Errorf("duration must be positive")
+		return invalidFlags(fmt.Errorf("duration must be positive"))
 	}
 	return nil
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// newNativeCmd 创建 native 子命令
+func newNativeCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		image      string
+		frequency  int
+		title      string
+		nativeOpts api.NativeProfilingOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "native [flags]",
+		Short: "Profile Rust, C and C++ applications",
+		Long: `Profile a native process, such as a Rust, C or C++ program, with perf. The profiler
+resolves symbols through the target's own filesystem, so binaries keep their symbols as
+shipped in the container image.
+
+Release builds usually omit frame pointers, so stacks are unwound with DWARF by default:
+perf copies the top of the user stack with every sample and unwinds it with the
+binary's call frame information. --unwind fp walks frame pointers instead, which is
+cheaper and enough for binaries built with -C force-frame-pointers=yes or
+-fno-omit-frame-pointer. Stripped binaries get their symbols from a debuginfod server
+given with --debuginfod-url, looked up by build ID.
+
+Examples:
+  # Flame graph of a Rust service
+  kubectl pprof native -n prod -p api-7d9f-x2k4q -d 30s -o api.svg
+
+  # A C++ service built with frame pointers, symbols from the distribution's debuginfod
+  kubectl pprof native -n prod -p envoy-0 --unwind fp --debuginfod-url https://debuginfod.ubuntu.com
+`,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProfile(cmd.Context(), cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&nativeOpts.Unwind, "unwind", api.UnwindDWARF, "Stack unwinding: dwarf (works without frame pointers, larger samples) or fp (frame pointers)")
+	cmd.Flags().StringVar(&nativeOpts.DebuginfodURL, "debuginfod-url", "", "debuginfod server to fetch the debug info of stripped binaries from, e.g. https://debuginfod.elfutils.org")
+	cmd.Flags().IntVar(&frequency, "frequency", api.DefaultFrequency, "Sampling frequency in Hz")
+	cmd.Flags().StringVar(&title, "title", "", "Flame graph title")
+	cmd.Flags().StringVar(&image, "image", "golang-profiling:latest", "Profiling tool image, which must provide perf")
+
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		cfg.Language = string(api.LanguageRust)
+		cfg.CrictlPath = "/usr/bin/crictl"
+		if cfg.EnvVars == nil {
+			cfg.EnvVars = make(map[string]string)
+		}
+		if cmd.Flags().Changed("image") {
+			cfg.Image = image
+		}
+		cfg.NativeOptions = &nativeOpts
+		cfg.GoOptions = &api.GoProfilingOptions{Frequency: frequency, Title: title}
+		return validateNativeConfig(cfg)
+	}
+
+	return cmd
+}
+
+// validateNativeConfig 验证 native 子命令的配置
+func validateNativeConfig(cfg *api.ProfileConfig) error {
+	if cfg.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if cfg.PodName == "" && cfg.Spread == "" && cfg.TargetImage == "" {
+		return fmt.Errorf("pod name is required")
+	}
+	if cfg.ProfileType != api.ProfileTypeCPU {
+		return fmt.Errorf("native profiling only supports --profile-type cpu, got %q", cfg.ProfileType)
+	}
+	if cfg.ReadsLabels() {
+		return fmt.Errorf("--annotate labels, --tag-filter and --traces read the runtime/pprof labels of Go programs")
+	}
+	if cfg.SourceURLTemplate != "" {
+		return fmt.Errorf("--source-url-template links frames through the build info of Go programs")
+	}
+	if cfg.GetGranularity() == api.GranularityLines {
+		return fmt.Errorf("--granularity lines and --list are only supported for Go programs")
+	}
+	if unwind := cfg.NativeOptions.Unwind; unwind != api.UnwindDWARF && unwind != api.UnwindFP {
+		return fmt.Errorf("invalid --unwind %q, must be %s or %s", unwind, api.UnwindDWARF, api.UnwindFP)
+	}
+	if cfg.GoOptions.Frequency < 1 || cfg.GoOptions.Frequency > 10000 {
+		return fmt.Errorf("frequency must be between 1 and 10000 Hz")
+	}
+	if raw := cfg.NativeOptions.DebuginfodURL; raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --debuginfod-url %q, must be an http or https URL", raw)
+		}
+	}
+	return nil
+}
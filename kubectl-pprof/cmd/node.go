@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// newNodeCmd 创建 node 子命令
+func newNodeCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var image string
+
+	cmd := &cobra.Command{
+		Use:   "node <node-name> --process <name> [flags]",
+		Short: "Profile a process of the node itself, such as the kubelet or containerd",
+		Long: `Profile a process running directly on a node instead of in a pod. Pod discovery is
+skipped: a privileged hostPID Job is scheduled on the node and picks the oldest process
+whose name (comm) or executable matches --process.
+
+The Job is created in --job-namespace, else --target-namespace, else default.
+
+Examples:
+  # Flame graph of the kubelet of a node
+  kubectl pprof node worker-1 --process kubelet -d 60s -o kubelet.svg
+
+  # Where containerd waits for a CPU
+  kubectl pprof node worker-1 --process containerd --profile-type schedlat
+`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Language = "go"
+			cfg.NodeName = args[0]
+			cfg.CrictlPath = "/usr/bin/crictl"
+			if cfg.EnvVars == nil {
+				cfg.EnvVars = make(map[string]string)
+			}
+			if cmd.Flags().Changed("image") {
+				cfg.Image = image
+			}
+			if cfg.GetJobNamespace() == "" {
+				cfg.JobNamespace = "default"
+			}
+			return runProfile(cmd.Context(), cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&cfg.HostProcess, "process", "", "Name of the node process to profile, e.g. kubelet")
+	cmd.Flags().StringVar(&image, "image", "golang-profiling:latest", "Profiling tool image")
+	_ = cmd.MarkFlagRequired("process")
+
+	return cmd
+}
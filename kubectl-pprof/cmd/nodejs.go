@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// newNodejsCmd 创建 nodejs 子命令
+func newNodejsCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		image     string
+		frequency int
+		title     string
+		nodeOpts  api.NodeProfilingOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:     "nodejs [flags]",
+		Aliases: []string{"js"},
+		Short:   "Profile Node.js applications",
+		Long: `Profile a Node.js process. The profiler picks how to attach in this order:
+
+  1. The V8 inspector, when node runs with --inspect (on its command line or in
+     NODE_OPTIONS), the pod has the kubectl-pprof.io/inspector-port annotation or
+     --inspect-port is given. The V8 CPU profiler runs through a port-forward, with
+     no privileged pod, and its .cpuprofile is saved next to the flame graph for
+     Chrome DevTools.
+  2. perf in a profiling Job otherwise. JavaScript frames are named from the
+     /tmp/perf-<pid>.map V8 writes when node runs with --perf-basic-prof; without
+     it they show as bare addresses and the profiler says so.
+
+--attach inspector or --attach perf skips the other.
+
+Examples:
+  # Flame graph of a service started with node --inspect server.js
+  kubectl pprof nodejs -n prod -p web-5c8d9-q7x2z -d 30s -o web.svg
+
+  # Sample with perf, NODE_OPTIONS=--perf-basic-prof names the JavaScript frames
+  kubectl pprof nodejs -n prod -p web-5c8d9-q7x2z --attach perf
+`,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProfile(cmd.Context(), cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&nodeOpts.Attach, "attach", api.NodeAttachAuto, "How to attach: auto, inspector (V8 CPU profiler through a port-forward) or perf (profiling Job)")
+	cmd.Flags().Int32Var(&nodeOpts.InspectPort, "inspect-port", 0, "Inspector port of the target (default: from --inspect, the pod annotation or 9229)")
+	cmd.Flags().IntVar(&frequency, "frequency", api.DefaultFrequency, "Sampling frequency in Hz")
+	cmd.Flags().StringVar(&title, "title", "", "Flame graph title")
+	cmd.Flags().StringVar(&image, "image", "golang-profiling:latest", "Profiling tool image for --attach perf, which must provide perf")
+
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		cfg.Language = string(api.LanguageNode)
+		cfg.CrictlPath = "/usr/bin/crictl"
+		if cfg.EnvVars == nil {
+			cfg.EnvVars = make(map[string]string)
+		}
+		if cmd.Flags().Changed("image") {
+			cfg.Image = image
+		}
+		cfg.NodeOptions = &nodeOpts
+		cfg.GoOptions = &api.GoProfilingOptions{Frequency: frequency, Title: title}
+		return validateNodejsConfig(cfg)
+	}
+
+	return cmd
+}
+
+// validateNodejsConfig 验证 nodejs 子命令的配置
+func validateNodejsConfig(cfg *api.ProfileConfig) error {
+	if cfg.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if cfg.PodName == "" && cfg.Spread == "" && cfg.TargetImage == "" {
+		return fmt.Errorf("pod name is required")
+	}
+	if cfg.ProfileType != api.ProfileTypeCPU {
+		return fmt.Errorf("Node.js profiling only supports --profile-type cpu, got %q", cfg.ProfileType)
+	}
+	switch attach := cfg.NodeOptions.Attach; attach {
+	case api.NodeAttachAuto, api.NodeAttachPerf:
+	case api.NodeAttachInspector:
+		if cfg.Mode == api.ModeJob || cfg.Mode == api.ModeEphemeral {
+			return fmt.Errorf("--attach inspector profiles through a port-forward and cannot run with --mode %s", cfg.Mode)
+		}
+		if cfg.PerCPU {
+			return fmt.Errorf("--per-cpu needs perf, the V8 CPU profiler does not record CPUs")
+		}
+		if cfg.GetAnnotate() == api.AnnotateThread {
+			return fmt.Errorf("--annotate thread needs perf, the V8 CPU profiler does not record threads")
+		}
+	default:
+		return fmt.Errorf("invalid --attach %q, must be %s, %s or %s", attach, api.NodeAttachAuto, api.NodeAttachInspector, api.NodeAttachPerf)
+	}
+	if cfg.ReadsLabels() {
+		return fmt.Errorf("--annotate labels, --tag-filter and --traces read the runtime/pprof labels of Go programs")
+	}
+	if cfg.SourceURLTemplate != "" {
+		return fmt.Errorf("--source-url-template links frames through the build info of Go programs")
+	}
+	if cfg.GetGranularity() == api.GranularityLines {
+		return fmt.Errorf("--granularity lines and --list are only supported for Go programs")
+	}
+	if port := cfg.NodeOptions.InspectPort; port < 0 || port > 65535 {
+		return fmt.Errorf("invalid --inspect-port %d", port)
+	}
+	if cfg.GoOptions.Frequency < 1 || cfg.GoOptions.Frequency > 10000 {
+		return fmt.Errorf("frequency must be between 1 and 10000 Hz")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// prepareOutputPath expands ~ in the output path and checks the output can be
+// written before anything runs on the cluster, without creating anything: the
+// nearest existing directory on its path must be writable, and an existing
+// output is only replaced with --overwrite. Outputs named after each container
+// or node are not checked for existence, they are written next to the output
+// path. The profiler checks again right before writing, a scheduled run may
+// write hours later.
+func prepareOutputPath(cfg *api.ProfileConfig) error {
+	path, err := expandHome(cfg.OutputPath)
+	if err != nil {
+		return err
+	}
+	cfg.OutputPath = path
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid output path %s: %w", path, err)
+	}
+	if err := checkOutputDir(filepath.Dir(abs)); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(abs)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("cannot check the output file: %w", err)
+	case info.IsDir():
+		return fmt.Errorf("the output path %s is a directory, pass a file name", path)
+	case cfg.AllContainers || cfg.Spread != "" || cfg.AllMatches:
+		return nil
+	case !cfg.Overwrite:
+		return fmt.Errorf("the output file %s already exists, pass --overwrite to replace it or another --output", path)
+	}
+	return nil
+}
+
+// checkOutputDir checks the output directory can be written: the directory
+// itself, or the nearest existing one above it, in which the missing ones
+// are created on write
+func checkOutputDir(dir string) error {
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("cannot create the output directory %s: %s is not a directory", dir, existing)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("cannot check the output directory: %w", err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return fmt.Errorf("cannot check the output directory: %w", err)
+		}
+		existing = parent
+	}
+	if err := checkWritable(existing); err != nil {
+		return fmt.Errorf("the output directory %s is not writable: %w", existing, err)
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~ with the home directory, for paths the
+// shell did not expand, e.g. --output=~/profile.svg
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot expand ~ in the output path: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
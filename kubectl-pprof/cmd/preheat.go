@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+)
+
+// newPreheatCmd 创建 preheat 子命令
+func newPreheatCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	spec := job.PreheatSpec{}
+
+	cmd := &cobra.Command{
+		Use:   "preheat [flags]",
+		Short: "Pull the profiling image onto nodes ahead of time",
+		Long: `Pull the profiling image onto the nodes matching --nodes with a short-lived
+DaemonSet, so the first profile taken during an incident does not wait a minute
+for the image. The DaemonSet runs an unprivileged container that only sleeps,
+tolerates every taint and is deleted once every node pulled the image, failed
+to, or --timeout passed. With --image-arch-suffix every architecture gets its
+own DaemonSet and tag. The registry mirror and digest apply as they do to profiles.
+
+The DaemonSet is created in --job-namespace, else default.
+
+Examples:
+  # Pull the default image onto every node
+  kubectl pprof preheat
+
+  # Pull a pinned image onto the nodes of the api node pool
+  kubectl pprof preheat --nodes pool=api --image registry.example.com/golang-profiling:v1.4.0
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if spec.Timeout <= 0 {
+				return fmt.Errorf("--timeout must be positive")
+			}
+			spec.Namespace = cfg.JobNamespace
+			if spec.Namespace == "" {
+				spec.Namespace = "default"
+			}
+			// Pull what profiles will run: mirrored and pinned like theirs
+			imageCfg := *cfg
+			imageCfg.Image = spec.Image
+			if err := validateImage(&imageCfg); err != nil {
+				return err
+			}
+			spec.Image = job.ProfilingImage(&imageCfg, nil)
+			spec.ImageArchSuffix = cfg.ImageArchSuffix
+			spec.ImagePullSecrets = cfg.ImagePullSecrets
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			nodes, err := job.NewPreheater(k8sConfig.Clientset, opts.Log()).Run(ctx, spec)
+			if err != nil {
+				return err
+			}
+			if !opts.Quiet {
+				printPreheat(nodes)
+			}
+			failed := 0
+			for _, node := range nodes {
+				if node.Status == job.PreheatFailed || node.Status == job.PreheatPending {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("the image is not on %d of %d nodes", failed, len(nodes))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&spec.NodeSelector, "nodes", "", "Label selector of the nodes to pull the image onto, e.g. pool=api (default: every node)")
+	cmd.Flags().StringVar(&spec.Image, "image", "golang-profiling:latest", "Profiling tool image")
+	cmd.Flags().DurationVar(&spec.Timeout, "timeout", 10*time.Minute, "Give up on the nodes that did not pull the image within this time")
+
+	return cmd
+}
+
+// printPreheat prints one line per preheated node
+func printPreheat(nodes []job.PreheatNode) {
+	fmt.Printf("%-40s %-8s %-50s %s\n", "NODE", "STATUS", "IMAGE", "DETAIL")
+	for _, node := range nodes {
+		fmt.Printf("%-40s %-8s %-50s %s\n", node.Node, node.Status, node.Image, node.Detail)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/analysis"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+// renderFormats output formats supported by the render subcommand
+var renderFormats = []string{"svg", "png", "pdf", "html"}
+
+// newRenderCmd 创建 render 子命令
+func newRenderCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		renderOpts  flamegraph.Options
+		inputFormat string
+		sampleType  string
+		tagFilters  []string
+		granularity string
+		list        string
+		analyze     bool
+		maxStacks   int
+		spillDir    string
+		alsoFormats []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "render <stacks-file> [flags]",
+		Short: "Render a flame graph locally from exported stacks",
+		Long: `Render a flame graph (SVG, PNG, PDF or HTML) from a previously exported .folded,
+.timeline or pprof file.
+
+No cluster access is needed, so visual options can be changed freely without
+profiling the workload again.
+
+Examples:
+  # Re-render exported folded stacks as an icicle graph
+  kubectl pprof render stacks.folded --inverted
+
+  # Render a flame chart from the timeline saved by --go-flame-chart
+  kubectl pprof render profile.timeline --flame-chart -o chart.svg
+
+  # Render a Go pprof CPU profile as an interactive HTML page
+  kubectl pprof render cpu.pprof --output-format html --colors java
+
+  # Only the samples of the checkout route, from the runtime/pprof labels
+  kubectl pprof render cpu.pprof --tag-filter route=/checkout
+
+  # Print the hot source lines of the handlers instead of rendering
+  kubectl pprof render cpu.pprof --list 'api\.handle.*'
+
+  # List GC, JSON and other known hotspots above the graph
+  kubectl pprof render cpu.pprof --output-format html --analyze
+
+  # Render a PNG with a wider canvas and stable colors
+  kubectl pprof render stacks.folded -o stacks.png --width 2400 --hash
+
+  # Render a high-resolution PNG for slides
+  kubectl pprof render stacks.folded -o stacks.png --dpi 192
+
+  # Render SVG, PNG and a speedscope file in one pass
+  kubectl pprof render stacks.folded --also-format png,speedscope
+
+  # Merge a multi-GB folded file with at most 1M stacks in memory
+  kubectl pprof render huge.folded --max-stacks 1000000 --spill-dir /var/tmp
+`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input := args[0]
+
+			format := strings.ToLower(opts.OutputFormat)
+			if !cmd.Flags().Changed("output-format") && cmd.Flags().Changed("output") {
+				// Infer the format from the output file name
+				format = strings.TrimPrefix(strings.ToLower(filepath.Ext(cfg.OutputPath)), ".")
+			}
+			if !containsString(renderFormats, format) {
+				return fmt.Errorf("invalid render format '%s', must be one of: %s", format, strings.Join(renderFormats, ", "))
+			}
+
+			output := cfg.OutputPath
+			if !cmd.Flags().Changed("output") {
+				output = strings.TrimSuffix(input, filepath.Ext(input)) + "." + format
+			}
+
+			if err := validateRenderOptions(renderOpts); err != nil {
+				return err
+			}
+			if granularity != api.GranularityFunctions && granularity != api.GranularityLines {
+				return fmt.Errorf("invalid --granularity %q, must be %s or %s", granularity, api.GranularityFunctions, api.GranularityLines)
+			}
+
+			if maxStacks < 0 {
+				return fmt.Errorf("--max-stacks must not be negative")
+			}
+			profile, unit, err := flamegraph.LoadFileWith(input, flamegraph.LoadOptions{
+				Format:     inputFormat,
+				SampleType: sampleType,
+				Lines:      granularity == api.GranularityLines || list != "",
+				KeepOrder:  renderOpts.FlameChart,
+				MaxStacks:  maxStacks,
+				SpillDir:   spillDir,
+			})
+			if err != nil {
+				return err
+			}
+			if len(tagFilters) > 0 {
+				filters := make([]flamegraph.TagFilter, 0, len(tagFilters))
+				for _, s := range tagFilters {
+					f, err := flamegraph.ParseTagFilter(s)
+					if err != nil {
+						return err
+					}
+					filters = append(filters, f)
+				}
+				if profile = flamegraph.FilterTags(profile, filters); len(profile.Samples) == 0 {
+					return fmt.Errorf("no sample of %s matches the tag filters, only pprof input carries labels", input)
+				}
+			}
+			if renderOpts.CountName == "" {
+				renderOpts.CountName = unit
+			}
+
+			// --list prints text instead of rendering
+			if list != "" {
+				re, err := regexp.Compile(list)
+				if err != nil {
+					return fmt.Errorf("invalid --list %q: %w", list, err)
+				}
+				n, err := flamegraph.WriteList(os.Stdout, profile, re, renderOpts.CountName)
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					return fmt.Errorf("no function of %s with source lines matches %q", input, list)
+				}
+				return nil
+			}
+			renderOpts.DPI = opts.DPI
+			var findings []api.Finding
+			if analyze {
+				findings = analysis.Analyze(profile, analysis.DefaultRules())
+				renderOpts.Findings = profiler.HTMLFindings(findings)
+			}
+
+			// The output and the --also-format conversions are encoded concurrently
+			formats := []string{format}
+			for _, f := range alsoFormats {
+				f = strings.ToLower(strings.TrimSpace(f))
+				if !containsString(flamegraph.ConvertFormats, f) {
+					return fmt.Errorf("invalid --also-format '%s', must be one of: %s", f, strings.Join(flamegraph.ConvertFormats, ", "))
+				}
+				if !containsString(formats, f) {
+					formats = append(formats, f)
+				}
+			}
+			start := time.Now()
+			conversions := flamegraph.ConvertAll(profile, formats, renderOpts, 0)
+			opts.Log().Log(cmd.Context(), logging.V(1), "Converted stacks", "formats", len(formats), "duration", time.Since(start).Round(time.Millisecond))
+
+			base := strings.TrimSuffix(output, filepath.Ext(output))
+			for i, c := range conversions {
+				if c.Err != nil {
+					return fmt.Errorf("failed to render %s as %s: %w", input, c.Format, c.Err)
+				}
+				opts.Log().Log(cmd.Context(), logging.V(1), "Conversion finished", "format", c.Format, "bytes", len(c.Data), "duration", c.Duration.Round(time.Millisecond))
+
+				path := output
+				if i > 0 {
+					path = base + flamegraph.Extension(c.Format)
+				}
+				if err := os.WriteFile(path, c.Data, 0644); err != nil {
+					return fmt.Errorf("failed to write output file: %w", err)
+				}
+				if i > 0 && !opts.Quiet {
+					fmt.Printf("Converted %s to %s\n", input, path)
+				}
+			}
+
+			if !opts.Quiet {
+				fmt.Printf("Rendered %s (%d stacks) to %s\n", input, len(profile.Samples), output)
+			}
+			printFindings(analyze, findings)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&inputFormat, "input-format", "", "Input format (folded, timeline, pprof); detected from the file when empty")
+	cmd.Flags().StringArrayVar(&tagFilters, "tag-filter", nil, "Only keep samples whose pprof label matches key=regex, like go tool pprof -tagfocus; repeat to require several")
+	cmd.Flags().StringVar(&granularity, "granularity", api.GranularityFunctions, "Frame granularity of pprof input: functions, or lines to name frames \"function file:line\"")
+	cmd.Flags().BoolVar(&analyze, "analyze", false, "Recognize common problems in the stacks and print them as ranked findings, listed in HTML output too")
+	cmd.Flags().StringVar(&list, "list", "", "Print the hot source lines of the functions matching this regex instead of rendering, like go tool pprof list")
+	cmd.Flags().StringSliceVar(&alsoFormats, "also-format", nil, "Also convert the stacks into these formats next to the output, concurrently: "+strings.Join(flamegraph.ConvertFormats, ", "))
+	cmd.Flags().IntVar(&maxStacks, "max-stacks", 0, "Distinct folded stacks held in memory before spilling to disk while merging huge inputs (0: no limit)")
+	cmd.Flags().StringVar(&spillDir, "spill-dir", "", "Directory of the files --max-stacks spills to (default: the system temp dir)")
+	cmd.Flags().StringVar(&sampleType, "sample-type", "", "pprof sample type to render, e.g. cpu, alloc_space (default: the profile's default)")
+
+	cmd.Flags().StringVar(&renderOpts.Title, "title", "", "Flame graph title")
+	cmd.Flags().StringVar(&renderOpts.Subtitle, "subtitle", "", "Flame graph subtitle")
+	cmd.Flags().StringVar(&renderOpts.Colors, "colors", "hot", "Color palette ("+strings.Join(flamegraph.Palettes, ", ")+")")
+	cmd.Flags().StringVar(&renderOpts.BgColors, "bgcolors", "", "Background colors (yellow, blue, green, grey or #rrggbb)")
+	cmd.Flags().IntVar(&renderOpts.Width, "width", 1200, "Image width in pixels")
+	cmd.Flags().IntVar(&renderOpts.Height, "height", 16, "Frame height in pixels")
+	cmd.Flags().StringVar(&renderOpts.FontType, "font-type", "Verdana", "Font type")
+	cmd.Flags().Float64Var(&renderOpts.FontSize, "font-size", 12, "Font size")
+	cmd.Flags().Float64Var(&renderOpts.MinWidth, "min-width", 0.1, "Omit frames narrower than this many pixels")
+	cmd.Flags().StringVar(&renderOpts.CountName, "count-name", "", "Unit shown in frame details (default: samples, or the pprof sample type)")
+	cmd.Flags().BoolVar(&renderOpts.Inverted, "inverted", false, "Render an inverted icicle graph")
+	cmd.Flags().BoolVar(&renderOpts.FlameChart, "flame-chart", false, "Keep the input order instead of merging stacks (use with .timeline input)")
+	cmd.Flags().BoolVar(&renderOpts.Hash, "hash", false, "Use hash-based colors so functions keep their color across graphs")
+	cmd.Flags().BoolVar(&renderOpts.Random, "random", false, "Use random colors")
+
+	return cmd
+}
+
+// validateRenderOptions 验证渲染选项
+func validateRenderOptions(o flamegraph.Options) error {
+	if !containsString(flamegraph.Palettes, o.Colors) {
+		return fmt.Errorf("invalid color scheme '%s', must be one of: %s", o.Colors, strings.Join(flamegraph.Palettes, ", "))
+	}
+	if o.Width < 100 || o.Width > 20000 {
+		return fmt.Errorf("width must be between 100 and 20000 pixels")
+	}
+	if o.Height < 5 || o.Height > 100 {
+		return fmt.Errorf("height must be between 5 and 100 pixels")
+	}
+	if o.Hash && o.Random {
+		return fmt.Errorf("--hash and --random cannot be used together")
+	}
+	return nil
+}
+
+// containsString 判断切片是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+// newReplayCmd 创建 replay 子命令
+func newReplayCmd(opts *api.ProfileOptions) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "replay <recording-dir> [flags]",
+		Short: "Collect and render the results of a recorded session again",
+		Long: `Re-run result extraction and rendering of a session recorded with --record-session,
+reading the artifacts from the recorded profiler logs. No cluster access is needed,
+so a session that failed in another cluster can be debugged locally.
+
+The recording directory also holds every API request of the session in
+exchanges.jsonl, with the bodies of created objects, watch events and log streams
+in the numbered .body files.
+
+Examples:
+  # Record a session
+  kubectl pprof -n prod -p api-0 --record-session ./session-1
+
+  # Render it again, e.g. after fixing the renderer
+  kubectl pprof replay ./session-1 -o replayed.svg
+`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := profiler.Replay(cmd.Context(), args[0], output, opts)
+			if err != nil {
+				return fmt.Errorf("replay failed: %w", err)
+			}
+			if !opts.Quiet {
+				printSchedLatency(result.SchedLatency)
+				printNet(result.Net)
+				fmt.Printf("Replay completed! Output: %s\n", result.OutputPath)
+			}
+			return nil
+		},
+	}
+
+	// Local flag, the persistent --output default belongs to profiling
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file path (default: the recorded output path)")
+
+	return cmd
+}
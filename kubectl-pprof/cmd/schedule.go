@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/manifests"
+)
+
+// newScheduleCmd 创建 schedule 子命令
+func newScheduleCmd(opts *api.ProfileOptions) *cobra.Command {
+	var (
+		schedule  manifests.Schedule
+		every     time.Duration
+		sink      string
+		namespace string
+		dryRun    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "schedule <name> [flags] -- <profiling flags>",
+		Short: "Profile periodically with a CronJob in the cluster",
+		Long: `Generate and apply a CronJob that runs kubectl-pprof with the profiling flags
+after --, plus the ServiceAccount and ClusterRole it runs with, so baseline
+profiles are taken without running the CLI by hand.
+
+Every run saves its session into --sink:
+  pvc:CLAIM            the session store on a PersistentVolumeClaim in --namespace
+  s3://BUCKET/PREFIX   uploaded with the AWS CLI, credentials from --sink-secret
+  gs://BUCKET/PREFIX   uploaded with gcloud, credentials from --sink-secret or
+                       workload identity
+
+The CronJob runs --image, which must provide the kubectl-pprof binary.
+
+Examples:
+  # Profile a pod of the api deployment every 6 hours into a claim
+  kubectl pprof schedule api-baseline --every 6h --sink pvc:profiles \
+    -- -n prod --spread deployment/api -d 60s
+
+  # Print the manifests instead of applying them
+  kubectl pprof schedule api-baseline --every 6h --sink s3://perf/api \
+    --sink-secret aws-credentials --dry-run -- -n prod -p api-0
+`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dash := cmd.ArgsLenAtDash()
+			if dash != 1 {
+				return fmt.Errorf("give the schedule name, then the profiling flags after --")
+			}
+			schedule.Name = args[0]
+			schedule.Args = args[dash:]
+			schedule.Namespace = namespace
+
+			if every > 0 && schedule.Cron != "" {
+				return fmt.Errorf("--every and --cron cannot be used together")
+			}
+			if every > 0 {
+				cron, err := manifests.CronSchedule(every)
+				if err != nil {
+					return err
+				}
+				schedule.Cron = cron
+			}
+			if schedule.Cron == "" {
+				return fmt.Errorf("set --every or --cron")
+			}
+			var err error
+			if schedule.Sink, err = manifests.ParseSink(sink); err != nil {
+				return err
+			}
+			if err := schedule.Validate(); err != nil {
+				return err
+			}
+
+			objects := schedule.Objects()
+			if dryRun {
+				return manifests.Render(os.Stdout, objects...)
+			}
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+			if err := manifests.Apply(cmd.Context(), k8sConfig.Config, objects...); err != nil {
+				return err
+			}
+			if !opts.Quiet {
+				fmt.Printf("Scheduled %s/%s (%s)\n", schedule.Namespace, schedule.Name, schedule.Cron)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&every, "every", 0, "Interval between runs, a divisor of an hour or a day, e.g. 15m or 6h")
+	cmd.Flags().StringVar(&schedule.Cron, "cron", "", "Cron schedule of the runs, instead of --every")
+	cmd.Flags().StringVar(&sink, "sink", "", "Where runs store their sessions: pvc:CLAIM, s3://BUCKET/PREFIX or gs://BUCKET/PREFIX")
+	cmd.Flags().StringVar(&schedule.SinkSecret, "sink-secret", "", "Secret with the object store credentials, exposed to the upload as environment variables")
+	cmd.Flags().StringVar(&schedule.Image, "image", manifests.DefaultCLIImage, "Image providing the kubectl-pprof binary")
+	cmd.Flags().StringVar(&namespace, "cronjob-namespace", "default", "Namespace of the CronJob and its ServiceAccount")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the manifests instead of applying them")
+
+	return cmd
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/selftest"
+)
+
+// newSelftestCmd 创建 selftest 子命令
+func newSelftestCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var stOpts selftest.Options
+	var duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "selftest [flags]",
+		Short: "Run an end-to-end smoke test against a bundled example workload",
+		Long: `Deploy one of the example workloads into a sandbox namespace, profile it end to end,
+verify the flame graph contains the expected hot functions and clean everything up.
+
+Use it to validate the plugin and the profiling image after installing them on a new cluster.
+
+Examples:
+  # Validate the default on-CPU workload
+  kubectl pprof selftest --workload-image my-registry/golang-profiling-example:latest
+
+  # Use the mixed workload and keep the resources for debugging
+  kubectl pprof selftest --workload mixed --keep
+`,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Language = "go"
+			cfg.ProfileType = "cpu"
+			cfg.Duration = duration
+			if cfg.EnvVars == nil {
+				cfg.EnvVars = make(map[string]string)
+			}
+			if !cmd.Flags().Changed("output") {
+				cfg.OutputPath = fmt.Sprintf("selftest-%s.svg", stOpts.Workload)
+			}
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+
+			// The node is what is tested, probe it rather than trust the cache
+			opts.NodeCacheTTL = 0
+
+			runner, err := selftest.NewRunner(k8sConfig, opts.Log())
+			if err != nil {
+				return err
+			}
+
+			result, err := runner.Run(cmd.Context(), &stOpts, cfg, opts)
+			if err != nil {
+				return fmt.Errorf("selftest failed: %w", err)
+			}
+
+			if !result.Passed {
+				return fmt.Errorf("selftest failed: artifact %s is missing expected functions: %s",
+					result.OutputPath, strings.Join(result.MissingFunctions, ", "))
+			}
+
+			if !opts.Quiet {
+				fmt.Printf("✅ Selftest passed in %v (found: %s, artifact: %s)\n",
+					result.Duration.Round(time.Second), strings.Join(result.FoundFunctions, ", "), result.OutputPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&stOpts.Namespace, "sandbox-namespace", "kubectl-pprof-selftest", "Namespace to deploy the workload into (created and deleted when missing)")
+	cmd.Flags().StringVar(&stOpts.Workload, "workload", "oncpu", fmt.Sprintf("Example workload to profile (%s)", strings.Join(selftest.WorkloadNames(), ", ")))
+	cmd.Flags().StringVar(&stOpts.WorkloadImage, "workload-image", "golang-profiling-example:latest", "Image containing the example workloads (see example/Dockerfile)")
+	cmd.Flags().DurationVar(&stOpts.ReadyTimeout, "ready-timeout", 2*time.Minute, "How long to wait for the workload pod to start")
+	cmd.Flags().BoolVar(&stOpts.KeepResources, "keep", false, "Keep the sandbox resources after the test")
+	cmd.Flags().DurationVar(&duration, "duration", 10*time.Second, "Profiling duration")
+
+	return cmd
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/store"
+)
+
+// newSessionsCmd 创建 sessions 子命令
+func newSessionsCmd(opts *api.ProfileOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List and prune the sessions archived with --save-session",
+		Long: `Sessions profiled with --save-session are archived in the session store,
+--sessions-dir (default $KUBECTL_PPROF_SESSIONS or ~/.kubectl-pprof/sessions),
+one directory per session holding its artifacts and session.json.
+
+Examples:
+  # Archive a session
+  kubectl pprof -n prod -p api-0 --save-session
+
+  # Show the archive and its size
+  kubectl pprof sessions list
+
+  # Keep the 50 newest sessions of the last 30 days
+  kubectl pprof sessions prune --keep 50 --older-than 30d
+`,
+	}
+	cmd.AddCommand(newSessionsListCmd(opts))
+	cmd.AddCommand(newSessionsPruneCmd(opts))
+	return cmd
+}
+
+// newSessionsListCmd 创建 sessions list 子命令
+func newSessionsListCmd(opts *api.ProfileOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "List the stored sessions, newest first, with their size",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s := store.New(opts.SessionsDir)
+			sessions, err := s.List()
+			if err != nil {
+				return err
+			}
+			printSessions(sessions)
+			if len(sessions) > 0 {
+				fmt.Printf("%d sessions, %s in %s\n", len(sessions), store.FormatSize(totalSize(sessions)), s.Dir)
+			}
+			return nil
+		},
+	}
+}
+
+// newSessionsPruneCmd 创建 sessions prune 子命令
+func newSessionsPruneCmd(opts *api.ProfileOptions) *cobra.Command {
+	var (
+		retention store.Retention
+		olderThan string
+		maxSize   string
+		dryRun    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune [flags]",
+		Short: "Delete the stored sessions outside the retention policy",
+		Long: `Delete the sessions beyond the --keep newest, older than --older-than, or that
+push the store past --max-size counting from the newest. A session is deleted
+when any of the set bounds drops it.
+
+Examples:
+  kubectl pprof sessions prune --keep 50 --older-than 30d
+  kubectl pprof sessions prune --max-size 2Gi --dry-run
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if retention.Keep < 0 {
+				return fmt.Errorf("--keep must not be negative")
+			}
+			if olderThan != "" {
+				age, err := store.ParseAge(olderThan)
+				if err != nil {
+					return fmt.Errorf("invalid --older-than: %w", err)
+				}
+				retention.OlderThan = age
+			}
+			if maxSize != "" {
+				quantity, err := resource.ParseQuantity(maxSize)
+				if err != nil || quantity.Sign() <= 0 {
+					return fmt.Errorf("invalid --max-size %q, use e.g. 500Mi or 2Gi", maxSize)
+				}
+				retention.MaxSize = quantity.Value()
+			}
+			if retention == (store.Retention{}) {
+				return fmt.Errorf("set at least one of --keep, --older-than or --max-size")
+			}
+
+			pruned, freed, err := store.New(opts.SessionsDir).Prune(retention, time.Now(), dryRun)
+			if !opts.Quiet {
+				printSessions(pruned)
+				verb := "Deleted"
+				if dryRun {
+					verb = "Would delete"
+				}
+				fmt.Printf("%s %d sessions, %s\n", verb, len(pruned), store.FormatSize(freed))
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&retention.Keep, "keep", 0, "Keep at most this many sessions, the newest (0 = unbounded)")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Delete sessions older than this, e.g. 30d or 12h")
+	cmd.Flags().StringVar(&maxSize, "max-size", "", "Delete the oldest sessions until the store fits, e.g. 2Gi")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List what would be deleted without deleting it")
+
+	return cmd
+}
+
+// printSessions prints one line per stored session
+func printSessions(sessions []*store.Session) {
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found")
+		return
+	}
+	fmt.Printf("%-40s %-20s %-8s %-45s %8s %10s\n", "ID", "TIME", "TYPE", "TARGET", "DURATION", "SIZE")
+	for _, s := range sessions {
+		fmt.Printf("%-40s %-20s %-8s %-45s %8v %10s\n", s.ID, s.Time.Local().Format("2006-01-02 15:04:05"), s.ProfileType, s.Target(), s.Duration, store.FormatSize(s.Size))
+	}
+}
+
+// totalSize sums the size of sessions on disk
+func totalSize(sessions []*store.Session) int64 {
+	var size int64
+	for _, s := range sessions {
+		size += s.Size
+	}
+	return size
+}
+
+// prepareSessionSave makes the session export the folded stacks that stored
+// sessions are compared by
+func prepareSessionSave(cfg *api.ProfileConfig, opts *api.ProfileOptions) {
+	if opts.SaveSession {
+		exportFolded(cfg)
+	}
+}
+
+// exportFolded makes the session export its folded stacks next to the output,
+// unless it exports them already
+func exportFolded(cfg *api.ProfileConfig) {
+	if cfg.OutputPath == "" {
+		return
+	}
+	if cfg.GoOptions == nil {
+		cfg.GoOptions = &api.GoProfilingOptions{}
+	}
+	if cfg.GoOptions.ExportFolded == "" {
+		cfg.GoOptions.ExportFolded = filepath.Base(strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath))) + ".folded"
+	}
+}
+
+// saveSessions archives the results of a run into the session store. Failures
+// are logged, the artifacts are already saved.
+func saveSessions(opts *api.ProfileOptions, results ...*api.ProfileResult) {
+	if !opts.SaveSession {
+		return
+	}
+	s := store.New(opts.SessionsDir)
+	for _, result := range results {
+		session, err := s.Save(result)
+		if err != nil {
+			opts.Log().Warn("Failed to store session", "error", err)
+			continue
+		}
+		opts.Log().Info("Session stored", "id", session.ID, "dir", session.Dir)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+	"github.com/withlin/kubectl-pprof/pkg/trigger"
+)
+
+// newWatchCmd 创建 watch 子命令
+func newWatchCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		filename string
+		interval time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch -f <rules.yaml> [flags]",
+		Short: "Profile containers automatically when their watch rules fire",
+		Long: `Watch the pods listed in a manifest and profile a container as soon as one of
+its rules fires, storing every profile in the session store, so spikes are
+captured while nobody is looking. Every entry watches the pods of a namespace
+matching a label selector with its own rules:
+
+  cooldown: 15m            # a container is left alone this long after its profile
+  watches:
+    - namespace: prod
+      selector: app=api
+      container: api
+      rules:
+        - cpuPercent: 80   # of the container's CPU limit, or of a core without one
+          for: 30s
+        - oom: true        # the container was OOM killed
+        - restarts: 3      # the container restarted 3 times within 10 minutes
+          window: 10m
+
+CPU usage is read from the metrics API, which needs metrics-server. Signals that
+happened before the watch started never fire. Profiles run one at a time with the
+profiling flags of kubectl pprof; the watch runs until interrupted.
+
+Examples:
+  # Capture 30s profiles of the watched containers into the session store
+  kubectl pprof watch -f rules.yaml -d 30s -o spikes/profile.svg
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			spec, err := trigger.LoadWatchSpec(filename)
+			if err != nil {
+				return err
+			}
+			watcher := &trigger.Watcher{Cooldown: trigger.DefaultCooldown}
+			if spec.Cooldown != "" {
+				// Validated by LoadWatchSpec
+				watcher.Cooldown, _ = time.ParseDuration(spec.Cooldown)
+			}
+			rules := make([][]trigger.Rule, len(spec.Watches))
+			withCPU := make([]bool, len(spec.Watches))
+			for i, watch := range spec.Watches {
+				for _, r := range watch.Rules {
+					rule, _ := trigger.ParseRule(r)
+					rules[i] = append(rules[i], rule)
+					withCPU[i] = withCPU[i] || rule.CPUPercent > 0
+				}
+			}
+
+			// Fail on bad profiling flags now rather than when a rule fires
+			probe := *cfg
+			probe.Namespace, probe.PodName = spec.Watches[0].Namespace, "watched"
+			if err := validateProfileFlags(&probe, opts); err != nil {
+				return err
+			}
+			// Firing profiles are only useful if they are kept
+			opts.SaveSession = true
+
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+			profilerClient, err := profiler.NewProfiler(k8sConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create profiler: %w", err)
+			}
+			observer := &trigger.PodObserver{Clientset: k8sConfig.Clientset, Log: opts.Log()}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts.Log().Info("Watching", "watches", len(spec.Watches), "interval", interval, "cooldown", watcher.Cooldown)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				for i, watch := range spec.Watches {
+					observations, err := observer.Observe(ctx, watch, withCPU[i])
+					if err != nil {
+						opts.Log().Warn("Failed to observe pods", "namespace", watch.Namespace, "error", err)
+						continue
+					}
+					for _, o := range observations {
+						if fired, ok := watcher.Observe(o, rules[i], time.Now()); ok {
+							profileFired(ctx, profilerClient, cfg, opts, fired)
+						}
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&filename, "filename", "f", "", "Manifest of the watched pods and their rules (YAML or JSON)")
+	cmd.Flags().DurationVar(&interval, "interval", 15*time.Second, "Time between two observations of the watched pods")
+	_ = cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+// profileFired profiles the container a rule fired for and stores the
+// session. Failures are logged, the watch goes on.
+func profileFired(ctx context.Context, profilerClient *profiler.Profiler, cfg *api.ProfileConfig, opts *api.ProfileOptions, fired trigger.Fired) {
+	if !opts.Quiet {
+		fmt.Printf("🚨 %s/%s (%s): %s, %s, profiling\n", fired.Namespace, fired.Pod, fired.Container, fired.Rule, fired.Reason)
+	}
+
+	rcfg := *cfg
+	rcfg.Namespace = fired.Namespace
+	rcfg.PodName = fired.Pod
+	rcfg.ContainerName = fired.Container
+	output := cfg.OutputPath
+	if output == "" {
+		output = "flamegraph" + profiler.OutputExtension(strings.ToLower(opts.OutputFormat))
+	}
+	ext := filepath.Ext(output)
+	rcfg.OutputPath = fmt.Sprintf("%s-%s-%s-%s%s", strings.TrimSuffix(output, ext), fired.Pod, fired.Container, time.Now().Format(profiler.OutputTimeFormat), ext)
+	if cfg.GoOptions != nil {
+		// Exports are named after the output of every run
+		goOpts := *cfg.GoOptions
+		rcfg.GoOptions = &goOpts
+	}
+	if err := validateProfileFlags(&rcfg, opts); err != nil {
+		opts.Log().Warn("Not profiling", "pod", fired.Pod, "error", err)
+		return
+	}
+	prepareSessionSave(&rcfg, opts)
+
+	result, err := profilerClient.Profile(ctx, &rcfg, opts)
+	if err != nil {
+		opts.Log().Warn("Profiling failed", "pod", fired.Pod, "container", fired.Container, "error", err)
+		return
+	}
+	saveSessions(opts, result)
+	if !opts.Quiet {
+		fmt.Printf("✅ %s/%s (%s): %s\n", fired.Namespace, fired.Pod, fired.Container, result.OutputPath)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/policy"
+)
+
+// newPolicyWebhookCmd 创建 policy-webhook 子命令
+func newPolicyWebhookCmd(opts *api.ProfileOptions) *cobra.Command {
+	var addr, certFile, keyFile string
+
+	cmd := &cobra.Command{
+		Use:   "policy-webhook [flags]",
+		Short: "Serve the admission webhook enforcing namespace profiling policies",
+		Long: `Serve a validating admission webhook on /validate that denies kubectl-pprof Jobs
+breaking the profiling policy of their target namespace. Platform teams set the
+policy as annotations on the namespace:
+
+  kubectl-pprof.io/max-duration: 2m
+  kubectl-pprof.io/max-frequency: "99"
+  kubectl-pprof.io/allowed-hours: 09:00-18:00      (UTC, comma-separated)
+  kubectl-pprof.io/max-concurrent: "1"
+
+Register it with a ValidatingWebhookConfiguration for CREATE of batch/v1 jobs,
+objectSelector app=kubectl-pprof. Runs with the in-cluster config, or the
+kubeconfig outside a cluster.
+
+Examples:
+  kubectl pprof policy-webhook --tls-cert-file /certs/tls.crt --tls-private-key-file /certs/tls.key
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if certFile == "" || keyFile == "" {
+				return fmt.Errorf("--tls-cert-file and --tls-private-key-file are required, the API server only calls webhooks over TLS")
+			}
+			k8sConfig, err := config.LoadKubernetesConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubernetes config: %w", err)
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle("/validate", &policy.Webhook{Client: k8sConfig.Clientset})
+			server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				server.Shutdown(shutdownCtx)
+			}()
+
+			opts.Log().Info("Serving the policy webhook", "addr", addr)
+			if err := server.ListenAndServeTLS(certFile, keyFile); !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("policy webhook failed: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":8443", "Address to serve the webhook on")
+	cmd.Flags().StringVar(&certFile, "tls-cert-file", "", "TLS certificate of the webhook")
+	cmd.Flags().StringVar(&keyFile, "tls-private-key-file", "", "TLS private key of the webhook")
+
+	return cmd
+}
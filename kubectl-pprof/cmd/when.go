@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/trigger"
+)
+
+// Environment variables of the Prometheus server queried by when
+const (
+	prometheusURLEnv   = "PROMETHEUS_URL"
+	prometheusTokenEnv = "PROMETHEUS_TOKEN"
+)
+
+// newWhenCmd 创建 when 子命令
+func newWhenCmd(cfg *api.ProfileConfig, opts *api.ProfileOptions) *cobra.Command {
+	var (
+		condition trigger.Condition
+		prom      trigger.Prometheus
+		maxWait   time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "when --promql <expr> [flags]",
+		Short: "Profile as soon as a PromQL condition holds",
+		Long: `Evaluate a PromQL expression every --interval and start the profile once it
+held for --for, like the for clause of an alerting rule, so the profile catches
+the regression while it happens instead of after somebody noticed it. The
+condition holds while the query returns a series, or a non-zero scalar.
+
+The Prometheus server is --prometheus-url, or $PROMETHEUS_URL; $PROMETHEUS_TOKEN
+is sent as a bearer token when set. The profiling flags are those of
+kubectl pprof.
+
+Examples:
+  # Profile the api pod once its CPU usage stayed above 80% of a core for 2 minutes
+  kubectl pprof when -n prod -p api-7d9f8-xk2lp -d 30s --for 2m \
+    --prometheus-url http://prometheus.monitoring:9090 \
+    --promql 'rate(container_cpu_usage_seconds_total{pod="api-7d9f8-xk2lp",container="api"}[1m]) > 0.8'
+
+  # Give up when the latency SLO held for the whole night
+  kubectl pprof when -n prod -p api-7d9f8-xk2lp --max-wait 12h \
+    --promql 'histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket[5m]))) > 0.5'
+`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // 禁止在错误时显示用法信息
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if condition.Query == "" {
+				return fmt.Errorf("--promql is required")
+			}
+			if prom.URL == "" {
+				prom.URL = os.Getenv(prometheusURLEnv)
+			}
+			if prom.URL == "" {
+				return fmt.Errorf("set --prometheus-url or $%s", prometheusURLEnv)
+			}
+			if token := os.Getenv(prometheusTokenEnv); token != "" {
+				prom.Authorization = "Bearer " + token
+			}
+			if condition.For < 0 || condition.Interval <= 0 || maxWait < 0 {
+				return fmt.Errorf("--for and --max-wait cannot be negative and --interval must be positive")
+			}
+			// Fail on bad profiling flags now rather than when the condition fires
+			if err := validateProfileFlags(cfg, opts); err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if maxWait > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, maxWait)
+				defer cancel()
+			}
+			opts.Log().Info("Waiting for the trigger condition", "query", condition.Query, "for", condition.For, "interval", condition.Interval)
+			description, err := trigger.Wait(ctx, &prom, condition, opts.Log())
+			if errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("the condition did not hold for %v within --max-wait %v, no profile was taken", condition.For, maxWait)
+			}
+			if err != nil {
+				return err
+			}
+			if !opts.Quiet {
+				fmt.Printf("🚨 Condition held for %v (%s), profiling\n", condition.For, description)
+			}
+			return runProfile(cmd.Context(), cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&condition.Query, "promql", "", "PromQL condition that starts the profile, e.g. 'rate(container_cpu_usage_seconds_total{pod=\"api-0\"}[1m]) > 0.8'")
+	cmd.Flags().DurationVar(&condition.For, "for", 0, "How long the condition must hold before profiling, so a single spike does not fire")
+	cmd.Flags().DurationVar(&condition.Interval, "interval", 15*time.Second, "Time between two evaluations of the condition")
+	cmd.Flags().StringVar(&prom.URL, "prometheus-url", "", "Base URL of the Prometheus server (default $PROMETHEUS_URL)")
+	cmd.Flags().DurationVar(&maxWait, "max-wait", 0, "Give up when the condition did not fire within this time (default: wait until interrupted)")
+
+	return cmd
+}
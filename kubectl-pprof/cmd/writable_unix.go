@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// checkWritable checks the current user may create files in a directory,
+// with the permissions the kernel would apply
+func checkWritable(dir string) error {
+	return unix.Access(dir, unix.W_OK|unix.X_OK)
+}
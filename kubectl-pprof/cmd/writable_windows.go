@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkWritable checks a directory is not read-only, Windows has no cheap
+// equivalent of access(2) for the ACLs
+func checkWritable(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("the directory is read-only")
+	}
+	return nil
+}
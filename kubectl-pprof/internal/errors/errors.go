@@ -1,6 +1,7 @@
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
 	"strings"
 )
@@ -51,11 +52,15 @@ func (e *ProfileError) GetSuggestions() []string {
 	return e.Suggestions
 }
 
-// FormatUserMessage returns a user-friendly error message with suggestions
+// FormatUserMessage returns a user-friendly error message with its cause and suggestions
 func (e *ProfileError) FormatUserMessage() string {
 	var builder strings.Builder
 	
-	builder.WriteString(fmt.Sprintf("❌ %s\n", e.Message))
+	if e.Cause != nil {
+		builder.WriteString(fmt.Sprintf("❌ %s: %v\n", e.Message, e.Cause))
+	} else {
+		builder.WriteString(fmt.Sprintf("❌ %s\n", e.Message))
+	}
 	
 	if len(e.Suggestions) > 0 {
 		builder.WriteString("\n💡 Suggestions:\n")
@@ -163,15 +168,15 @@ func WrapError(err error, errorType ErrorType, message string, suggestions ...st
 	}
 }
 
-// IsProfileError checks if an error is a ProfileError
+// IsProfileError checks if an error chain holds a ProfileError
 func IsProfileError(err error) bool {
-	_, ok := err.(*ProfileError)
-	return ok
+	return GetProfileError(err) != nil
 }
 
 // GetProfileError extracts ProfileError from an error chain
 func GetProfileError(err error) *ProfileError {
-	if profileErr, ok := err.(*ProfileError); ok {
+	var profileErr *ProfileError
+	if stderrors.As(err, &profileErr) {
 		return profileErr
 	}
 	return nil
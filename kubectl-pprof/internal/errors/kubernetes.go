@@ -0,0 +1,101 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// forbiddenPattern extracts the verb, resource, group and namespace of an
+// RBAC denial from the message of the API server, e.g. 'User "dev" cannot
+// create resource "jobs" in API group "batch" in the namespace "prod"'
+var forbiddenPattern = regexp.MustCompile(`cannot (\S+) resource "([^"]+)"(?: in API group "([^"]*)")?(?: in the namespace "([^"]+)")?`)
+
+// FromKubernetes converts an error of the Kubernetes API into a ProfileError
+// whose type, retryability and suggestions follow its status reason, message
+// saying what failed, e.g. "failed to get pod prod/api". Suggestions of the
+// caller come first. The API error stays in the chain, so apierrors.IsNotFound
+// and friends keep working on the result. A nil error converts to nil.
+func FromKubernetes(err error, message string, suggestions ...string) error {
+	if err == nil {
+		return nil
+	}
+	// Already converted further down
+	var profileErr *ProfileError
+	if stderrors.As(err, &profileErr) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+
+	switch {
+	case apierrors.IsNotFound(err):
+		return NewKubernetesError(message, err, false, append(suggestions,
+			"Check the name and the namespace (-n), and the kubeconfig context pointing to the right cluster")...)
+	case apierrors.IsForbidden(err):
+		permission := NewPermissionError(message, append(suggestions, forbiddenSuggestions(err)...)...)
+		permission.Cause = err
+		return permission
+	case apierrors.IsUnauthorized(err):
+		permission := NewPermissionError(message, append(suggestions,
+			"The API server refused your credentials, log in again or check the user of the kubeconfig context")...)
+		permission.Cause = err
+		return permission
+	case apierrors.IsConflict(err):
+		return NewKubernetesError(message, err, true, append(suggestions,
+			"The object changed while it was being updated, retry")...)
+	case apierrors.IsAlreadyExists(err):
+		return NewKubernetesError(message, err, false, append(suggestions,
+			"An object with the same name exists, delete it or remove leftover profiling Jobs with 'kubectl pprof gc'")...)
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return WrapError(err, ErrorTypeValidation, message, append(suggestions,
+			"The API server rejected the object, check the fields --job-template overlays")...)
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), stderrors.Is(err, context.DeadlineExceeded), isNetTimeout(err):
+		timeout := NewTimeoutError(message, append(suggestions,
+			"The API server did not answer in time, retry")...)
+		timeout.Cause = err
+		return timeout
+	case apierrors.IsTooManyRequests(err), apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err):
+		return NewKubernetesError(message, err, true, append(suggestions,
+			"The API server is overloaded or restarting, retry in a moment")...)
+	case utilnet.IsConnectionRefused(err), utilnet.IsConnectionReset(err), utilnet.IsProbableEOF(err), isNetError(err):
+		return NewNetworkError(message, err, append(suggestions,
+			"The API server cannot be reached, check the server of the kubeconfig context, VPN and proxies")...)
+	}
+	return NewKubernetesError(message, err, false, suggestions...)
+}
+
+// forbiddenSuggestions names the permission RBAC denied, when the message of
+// the API server says which
+func forbiddenSuggestions(err error) []string {
+	match := forbiddenPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return []string{"Check your permissions with 'kubectl auth can-i --list' against the RBAC the plugin needs (README, 权限要求)"}
+	}
+	resource := match[2]
+	if match[3] != "" {
+		resource += "." + match[3]
+	}
+	command := fmt.Sprintf("kubectl auth can-i %s %s", match[1], resource)
+	if match[4] != "" {
+		command += " -n " + match[4]
+	}
+	return []string{
+		fmt.Sprintf("You may not %s %s, ask for the role the plugin needs (README, 权限要求) and check with '%s'", match[1], resource, command),
+	}
+}
+
+// isNetError reports whether err is a network error dialing or talking to the API server
+func isNetError(err error) bool {
+	var netErr net.Error
+	return stderrors.As(err, &netErr)
+}
+
+// isNetTimeout reports whether err is a network timeout talking to the API server
+func isNetTimeout(err error) bool {
+	var netErr net.Error
+	return stderrors.As(err, &netErr) && netErr.Timeout()
+}
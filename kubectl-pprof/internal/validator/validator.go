@@ -9,23 +9,23 @@ import (
 	"time"
 
 	"github.com/withlin/kubectl-pprof/internal/errors"
-	"github.com/withlin/kubectl-pprof/internal/types"
+	"github.com/withlin/kubectl-pprof/pkg/api"
 )
 
 // Validator provides comprehensive validation for profiling configurations
 type Validator struct {
-	langManager *types.LanguageManager
+	langManager *api.LanguageManager
 }
 
 // NewValidator creates a new validator instance
-func NewValidator(langManager *types.LanguageManager) *Validator {
+func NewValidator(langManager *api.LanguageManager) *Validator {
 	return &Validator{
 		langManager: langManager,
 	}
 }
 
 // ValidateConfig performs comprehensive validation of profiling configuration
-func (v *Validator) ValidateConfig(cfg *types.ProfileConfig, opts *types.ProfileOptions) error {
+func (v *Validator) ValidateConfig(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
 	if cfg == nil {
 		return errors.NewValidationError(
 			"profile configuration is required",
@@ -73,7 +73,7 @@ func (v *Validator) ValidateConfig(cfg *types.ProfileConfig, opts *types.Profile
 }
 
 // validateRequiredFields validates that all required fields are present
-func (v *Validator) validateRequiredFields(cfg *types.ProfileConfig) error {
+func (v *Validator) validateRequiredFields(cfg *api.ProfileConfig) error {
 	if strings.TrimSpace(cfg.Namespace) == "" {
 		return errors.NewValidationError(
 			"target namespace is required",
@@ -118,7 +118,7 @@ func (v *Validator) validateRequiredFields(cfg *types.ProfileConfig) error {
 }
 
 // validateKubernetesFields validates Kubernetes-specific field formats
-func (v *Validator) validateKubernetesFields(cfg *types.ProfileConfig) error {
+func (v *Validator) validateKubernetesFields(cfg *api.ProfileConfig) error {
 	// Validate namespace format (RFC 1123 DNS label)
 	if !isValidKubernetesName(cfg.Namespace) {
 		return errors.NewValidationError(
@@ -155,11 +155,40 @@ func (v *Validator) validateKubernetesFields(cfg *types.ProfileConfig) error {
 		)
 	}
 
+	// Validate job namespace if specified
+	if cfg.JobNamespace != "" && !isValidKubernetesName(cfg.JobNamespace) {
+		return errors.NewValidationError(
+			fmt.Sprintf("invalid job namespace format: %s", cfg.JobNamespace),
+			"Namespace must be a valid DNS label (lowercase alphanumeric and hyphens)",
+			"Example: --job-namespace profiling-system",
+		)
+	}
+
+	// Validate service account if specified
+	if cfg.ServiceAccount != "" && !isValidKubernetesName(cfg.ServiceAccount) {
+		return errors.NewValidationError(
+			fmt.Sprintf("invalid service account name format: %s", cfg.ServiceAccount),
+			"ServiceAccount name must be a valid DNS label (lowercase alphanumeric and hyphens)",
+			"Example: --service-account profiler",
+		)
+	}
+
+	// Validate image pull secrets
+	for _, secret := range cfg.ImagePullSecrets {
+		if !isValidKubernetesName(secret) {
+			return errors.NewValidationError(
+				fmt.Sprintf("invalid image pull secret name format: %s", secret),
+				"Secret name must be a valid DNS label (lowercase alphanumeric and hyphens)",
+				"Example: --image-pull-secret regcred",
+			)
+		}
+	}
+
 	return nil
 }
 
 // validateTimingParameters validates duration and timeout settings
-func (v *Validator) validateTimingParameters(cfg *types.ProfileConfig) error {
+func (v *Validator) validateTimingParameters(cfg *api.ProfileConfig) error {
 	const (
 		minDuration = 1 * time.Second
 		maxDuration = 10 * time.Minute
@@ -191,13 +220,17 @@ func (v *Validator) validateTimingParameters(cfg *types.ProfileConfig) error {
 		)
 	}
 
-	if cfg.Timeout <= 0 {
+	// An unset timeout is derived from the duration, see ProfileConfig.Timings
+	if cfg.Timeout < 0 {
 		return errors.NewValidationError(
-			"timeout must be positive",
-			"Use --timeout to specify a positive timeout",
+			"timeout must not be negative",
+			"Leave --timeout unset to derive it from the duration",
 			"Example: --timeout 5m",
 		)
 	}
+	if cfg.Timeout == 0 {
+		return nil
+	}
 
 	if cfg.Timeout < minTimeout {
 		return errors.NewValidationError(
@@ -227,7 +260,7 @@ func (v *Validator) validateTimingParameters(cfg *types.ProfileConfig) error {
 }
 
 // validateLanguageConfig validates language and profile type compatibility
-func (v *Validator) validateLanguageConfig(cfg *types.ProfileConfig) error {
+func (v *Validator) validateLanguageConfig(cfg *api.ProfileConfig) error {
 	if v.langManager == nil {
 		return errors.NewConfigurationError(
 			"language manager not initialized",
@@ -236,8 +269,8 @@ func (v *Validator) validateLanguageConfig(cfg *types.ProfileConfig) error {
 		)
 	}
 
-	// Parse language
-	lang, err := types.ParseLanguage(cfg.Language)
+	// Parse language, custom languages registered in the manager included
+	lang, err := v.langManager.ParseLanguage(cfg.Language)
 	if err != nil {
 		supportedLangs := v.langManager.GetSupportedLanguages()
 		supportedLangStrs := make([]string, len(supportedLangs))
@@ -250,6 +283,10 @@ func (v *Validator) validateLanguageConfig(cfg *types.ProfileConfig) error {
 			"Example: --lang go, --lang java, --lang python",
 		)
 	}
+	// Detection mostly finds Go, whose profile types are the broadest
+	if lang == api.LanguageAuto {
+		lang = api.LanguageGo
+	}
 
 	// Validate profile type for the language
 	if err := v.langManager.ValidateProfileType(lang, cfg.ProfileType); err != nil {
@@ -269,7 +306,7 @@ func (v *Validator) validateLanguageConfig(cfg *types.ProfileConfig) error {
 }
 
 // validateOutputConfig validates output format and path settings
-func (v *Validator) validateOutputConfig(cfg *types.ProfileConfig, opts *types.ProfileOptions) error {
+func (v *Validator) validateOutputConfig(cfg *api.ProfileConfig, opts *api.ProfileOptions) error {
 	validFormats := map[string]bool{
 		"svg": true, "png": true, "pdf": true,
 		"json": true, "html": true, "raw": true,
@@ -333,7 +370,7 @@ func (v *Validator) validateOutputConfig(cfg *types.ProfileConfig, opts *types.P
 }
 
 // validateResourceLimits validates CPU and memory resource limits
-func (v *Validator) validateResourceLimits(cfg *types.ProfileConfig) error {
+func (v *Validator) validateResourceLimits(cfg *api.ProfileConfig) error {
 	if cfg.ResourceLimits == nil {
 		return nil // Resource limits are optional
 	}
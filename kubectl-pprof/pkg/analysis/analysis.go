@@ -0,0 +1,155 @@
+// Package analysis recognizes common performance problems in the stacks of a
+// profile, such as garbage collection or JSON encoding taking a large share
+// of the CPU, and ranks them as findings with a recommendation.
+//
+// DefaultRules lists the built-in rules. Callers extend the set with their
+// own Rule implementations, most simply a FrameRule matching functions of
+// their code base:
+//
+//	rules := append(analysis.DefaultRules(), analysis.FrameRule{
+//		Name:           "template-parse",
+//		Title:          "Templates parsed on a hot path",
+//		Functions:      regexp.MustCompile(`^html/template\.\(\*Template\)\.Parse$`),
+//		MinShare:       1,
+//		Recommendation: "Parse templates once at startup",
+//	})
+//	findings := analysis.Analyze(profile, rules)
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// maxFrames bounds the matching functions a finding names
+const maxFrames = 5
+
+// Rule recognizes one pattern in the stacks of a profile
+type Rule interface {
+	// Evaluate returns the finding of the rule, false when the profile does
+	// not show the pattern
+	Evaluate(p *flamegraph.Profile) (api.Finding, bool)
+}
+
+// FrameRule reports the samples with a function matching Functions anywhere
+// on their stack once they make up MinShare percent of the profile
+type FrameRule struct {
+	Name  string
+	Title string
+	// Matched against function names, without the file:line of frames at
+	// line granularity
+	Functions *regexp.Regexp
+	// Percentage of samples the matching stacks must reach
+	MinShare       float64
+	Recommendation string
+}
+
+// Evaluate implements Rule
+func (r FrameRule) Evaluate(p *flamegraph.Profile) (api.Finding, bool) {
+	total := p.Total()
+	if total == 0 {
+		return api.Finding{}, false
+	}
+	functions := make(map[string]int64)
+	var matched int64
+	for _, sample := range p.Samples {
+		seen := make(map[string]bool)
+		for _, frame := range sample.Stack {
+			function := functionName(frame)
+			if seen[function] || !r.Functions.MatchString(function) {
+				continue
+			}
+			seen[function] = true
+			functions[function] += sample.Value
+		}
+		if len(seen) > 0 {
+			matched += sample.Value
+		}
+	}
+	share := stacks.Share(matched, total)
+	if matched == 0 || share < r.MinShare {
+		return api.Finding{}, false
+	}
+	frames := topFunctions(functions, maxFrames)
+	return api.Finding{
+		Rule:           r.Name,
+		Title:          r.Title,
+		Share:          share,
+		Samples:        matched,
+		Frames:         frames,
+		Search:         searchPattern(frames),
+		Recommendation: r.Recommendation,
+	}, true
+}
+
+// Analyze evaluates every rule against the profile and returns the findings,
+// largest share first
+func Analyze(p *flamegraph.Profile, rules []Rule) []api.Finding {
+	var findings []api.Finding
+	for _, rule := range rules {
+		if finding, ok := rule.Evaluate(p); ok {
+			findings = append(findings, finding)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Share > findings[j].Share
+	})
+	return findings
+}
+
+// functionName strips the source location off a "function file:line" frame
+func functionName(frame string) string {
+	if function, _, _, ok := flamegraph.SourceLocation(frame); ok {
+		return function
+	}
+	return frame
+}
+
+// topFunctions returns the n functions with the most samples
+func topFunctions(samples map[string]int64, n int) []string {
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if samples[names[i]] != samples[names[j]] {
+			return samples[names[i]] > samples[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// searchPattern matches the frames of the functions in the search box of the
+// HTML report, with or without a source location. QuoteMeta only emits
+// escapes JavaScript regexes understand.
+func searchPattern(functions []string) string {
+	quoted := make([]string, len(functions))
+	for i, function := range functions {
+		quoted[i] = regexp.QuoteMeta(function)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")( |$)"
+}
+
+// WriteFindings writes the findings as a numbered list, each with the
+// functions it matched and its recommendation
+func WriteFindings(w io.Writer, findings []api.Finding) error {
+	var b strings.Builder
+	for i, f := range findings {
+		fmt.Fprintf(&b, "%2d. %s: %.1f%% of samples\n", i+1, f.Title, f.Share)
+		fmt.Fprintf(&b, "    in %s\n", strings.Join(f.Frames, ", "))
+		fmt.Fprintf(&b, "    %s\n", f.Recommendation)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
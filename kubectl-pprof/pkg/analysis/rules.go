@@ -0,0 +1,49 @@
+package analysis
+
+import "regexp"
+
+// DefaultRules returns the built-in rules, a new slice callers may append to
+func DefaultRules() []Rule {
+	return []Rule{
+		FrameRule{
+			Name:      "gc",
+			Title:     "Garbage collection",
+			Functions: regexp.MustCompile(`^runtime\.(gcBgMarkWorker|gcDrain|gcDrainN|gcAssistAlloc|gcAssistAlloc1|scanobject|scanblock|scanstack|markroot|greyobject|bgsweep|sweepone|gcStart|gcMarkDone|gcMarkTermination)$`),
+			MinShare:  10,
+			Recommendation: "Allocate less on the hot paths, a heap profile (--profile-type heap) shows where; " +
+				"with memory to spare, raise GOGC or set GOMEMLIMIT so the collector runs less often",
+		},
+		FrameRule{
+			Name:      "map-growth",
+			Title:     "Map growth",
+			Functions: regexp.MustCompile(`^(runtime\.(hashGrow|growWork|growWork_fast32|growWork_fast64|growWork_faststr|evacuate|evacuate_fast32|evacuate_fast64|evacuate_faststr)|internal/runtime/maps\.\(\*(table|Map)\)\.(grow|rehash|split|growToSmall|growToTable))$`),
+			MinShare:  2,
+			Recommendation: "Size maps up front with make(map[K]V, n) when the number of entries is known, " +
+				"or reuse them with clear() instead of building new ones",
+		},
+		FrameRule{
+			Name:      "regexp-compile",
+			Title:     "Regular expressions compiled on a hot path",
+			Functions: regexp.MustCompile(`^(regexp\.(Compile|MustCompile|CompilePOSIX|MustCompilePOSIX|Match|MatchString|MatchReader)|regexp/syntax\.(Parse|Compile))$`),
+			MinShare:  1,
+			Recommendation: "Compile each expression once, e.g. into a package-level var with regexp.MustCompile; " +
+				"the package-level regexp.Match functions compile on every call",
+		},
+		FrameRule{
+			Name:      "json",
+			Title:     "JSON encoding and decoding",
+			Functions: regexp.MustCompile(`^encoding/json\.`),
+			MinShare:  5,
+			Recommendation: "Decode into concrete types rather than interface{} or map[string]interface{}, stream large documents " +
+				"with json.Decoder, and consider a code-generated encoder for the hottest types",
+		},
+		FrameRule{
+			Name:      "mutex",
+			Title:     "Mutex contention",
+			Functions: regexp.MustCompile(`^(sync\.\(\*Mutex\)\.lockSlow|internal/sync\.\(\*Mutex\)\.lockSlow|sync\.\(\*RWMutex\)\.(Lock|RLock|rUnlockSlow)|sync\.runtime_SemacquireMutex|sync\.runtime_SemacquireRWMutex|sync\.runtime_SemacquireRWMutexR|runtime\.semacquire1)$`),
+			MinShare:  2,
+			Recommendation: "Shorten the critical sections or shard the lock; --mutex-profile shows which locks goroutines wait on " +
+				"and --off-cpu how long they sleep",
+		},
+	}
+}
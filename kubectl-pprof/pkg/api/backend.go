@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Paths a Backend leaves its results at in the profiler container, printed
+// to the logs by the job script
+const (
+	BackendFlameGraphPath = "/tmp/profile.svg"
+	BackendFoldedPath     = "/tmp/profile.folded"
+)
+
+// Backend is a profiling tool run in the profiler container. The job manager
+// wraps its script with the target lookup, overhead accounting and artifact
+// transport, so adding a language or tool means implementing Backend and
+// registering it in a LanguageManager.
+type Backend interface {
+	// Name identifies the tool in logs, e.g. golang-profiling or perf
+	Name() string
+	// BuildArgs returns the tool arguments of the session, without the target PID
+	BuildArgs(cfg *ProfileConfig) []string
+	// BuildScript returns the shell snippet sampling the process whose PID is
+	// held in the shell variable pidVar. It leaves the flame graph at
+	// BackendFlameGraphPath, folded stacks at BackendFoldedPath when it can,
+	// and the exit status of the tool in PROFILE_EXIT_CODE.
+	BuildScript(cfg *ProfileConfig, pidVar string) string
+	// RequiredMounts lists the host paths the tool needs besides /proc and /sys
+	RequiredMounts() []HostMount
+	// RequiredCapabilities lists the Linux capabilities the tool needs
+	RequiredCapabilities() []string
+	// ParseOutput reads the results specific to the tool from the profiler logs
+	ParseOutput(logs string) (*BackendOutput, error)
+}
+
+// HostMount is a host path mounted into the profiler container
+type HostMount struct {
+	Name      string
+	HostPath  string
+	MountPath string
+	ReadOnly  bool
+}
+
+// BackendOutput holds what a Backend reports besides its artifacts
+type BackendOutput struct {
+	SampleStats  *SampleStats
+	SampleTarget *SampleTargetReport
+
+	// Set by backends picking the tool in the job: the language detected,
+	// what revealed it, the tool that sampled it and how that tool differs
+	Language    Language
+	Detection   string
+	Backend     string
+	Methodology string
+}
+
+// CustomLanguage declares an in-house profiler in the configuration file,
+// run by the profiling Job like the built-in backends
+type CustomLanguage struct {
+	// Name selecting the language with --language, not a built-in one
+	Name string `json:"name"`
+	// Image holding the profiler, the default --image of the language
+	Image string `json:"image"`
+	// Shell command sampling the target, a text/template given the PID,
+	// Duration, Frequency, ProfileType, FoldedPath and FlameGraphPath
+	Command string `json:"command"`
+	// What the command leaves: folded stacks at FoldedPath, rendered into a
+	// flame graph, or an SVG flame graph at FlameGraphPath; folded when empty
+	OutputFormats []string `json:"outputFormats,omitempty"`
+	// Linux capabilities the profiler needs, e.g. SYS_PTRACE
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// RegisterLanguage adds a language other than the built-in ones, profiled by
+// backend and selected by its name
+func (lm *LanguageManager) RegisterLanguage(config *LanguageConfig, backend Backend) error {
+	if _, err := ParseLanguage(string(config.Language)); err == nil {
+		return fmt.Errorf("language %s is built in", config.Language)
+	}
+	if _, exists := lm.configs[config.Language]; exists {
+		return fmt.Errorf("language %s is declared twice", config.Language)
+	}
+	lm.configs[config.Language] = config
+	lm.backends[config.Language] = backend
+	return nil
+}
+
+// ParseLanguage converts a name to a built-in or registered Language. A nil
+// manager only knows the built-in languages.
+func (lm *LanguageManager) ParseLanguage(name string) (Language, error) {
+	lang, err := ParseLanguage(name)
+	if err == nil || lm == nil {
+		return lang, err
+	}
+	if custom := Language(strings.ToLower(strings.TrimSpace(name))); lm.configs[custom] != nil {
+		return custom, nil
+	}
+	return "", err
+}
@@ -0,0 +1,10 @@
+// Package api holds the configuration, options and result types shared by
+// the kubectl-pprof packages. Programs embedding the profiler build a
+// ProfileConfig with NewProfileConfig and pass it to pkg/profiler:
+//
+//	cfg := api.NewProfileConfig("default", "my-go-app",
+//		api.WithDuration(time.Minute),
+//		api.WithOutputPath("/tmp/my-go-app.svg"),
+//	)
+//	result, err := p.Profile(ctx, cfg, api.DefaultProfileOptions())
+package api
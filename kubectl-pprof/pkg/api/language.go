@@ -1,5 +1,4 @@
-// Package types provides language-specific configuration and management for profiling tools.
-package types
+package api
 
 import (
 	"fmt"
@@ -7,19 +6,36 @@ import (
 )
 
 // LanguageManager manages language-specific configurations for profiling
+// and the Backend profiling each language
 type LanguageManager struct {
-	configs map[Language]*LanguageConfig
+	configs  map[Language]*LanguageConfig
+	backends map[Language]Backend
 }
 
 // NewLanguageManager creates a new language manager with default configurations
 func NewLanguageManager() *LanguageManager {
 	lm := &LanguageManager{
-		configs: make(map[Language]*LanguageConfig),
+		configs:  make(map[Language]*LanguageConfig),
+		backends: make(map[Language]Backend),
 	}
 	lm.initializeDefaultConfigs()
 	return lm
 }
 
+// RegisterBackend makes backend profile the language, replacing any other
+func (lm *LanguageManager) RegisterBackend(lang Language, backend Backend) {
+	lm.backends[lang] = backend
+}
+
+// GetBackend returns the Backend registered for a language
+func (lm *LanguageManager) GetBackend(lang Language) (Backend, error) {
+	backend, exists := lm.backends[lang]
+	if !exists {
+		return nil, fmt.Errorf("no profiling backend for language: %s", lang)
+	}
+	return backend, nil
+}
+
 // GetConfig returns the configuration for a specific language
 func (lm *LanguageManager) GetConfig(lang Language) (*LanguageConfig, error) {
 	config, exists := lm.configs[lang]
@@ -68,8 +84,10 @@ func ParseLanguage(langStr string) (Language, error) {
 		return LanguagePython, nil
 	case "node", "nodejs", "javascript", "js":
 		return LanguageNode, nil
-	case "rust", "rs":
+	case "rust", "rs", "native", "c", "cpp", "c++":
 		return LanguageRust, nil
+	case "", "auto":
+		return LanguageAuto, nil
 	default:
 		return "", fmt.Errorf("unsupported language: %s", langStr)
 	}
@@ -80,7 +98,7 @@ func (lm *LanguageManager) initializeDefaultConfigs() {
 	// Go language configuration
 	lm.configs[LanguageGo] = &LanguageConfig{
 		Language:             LanguageGo,
-		SupportedTypes:       []string{"cpu", "memory", "goroutine", "block", "mutex", "heap", "allocs"},
+		SupportedTypes:       []string{"cpu", "memory", "goroutine", "block", "mutex", "heap", "allocs", "schedlat", "net"},
 		DefaultType:          "cpu",
 		DefaultImage:         "golang-profiling:latest",
 		ProfilerCommand:      []string{"/usr/local/bin/golang-profiling"},
@@ -124,27 +142,26 @@ func (lm *LanguageManager) initializeDefaultConfigs() {
 	// Node.js language configuration
 	lm.configs[LanguageNode] = &LanguageConfig{
 		Language:             LanguageNode,
-		SupportedTypes:       []string{"cpu", "memory", "heap"},
+		SupportedTypes:       []string{"cpu"},
 		DefaultType:          "cpu",
-		DefaultImage:         "node-profiler:latest",
-		ProfilerCommand:      []string{"/usr/local/bin/node-profiler"},
-		OutputFormats:        []string{"svg", "json", "cpuprofile", "heapprofile"},
-		RequiredCapabilities: []string{"SYS_PTRACE"},
+		DefaultImage:         "golang-profiling:latest",
+		ProfilerCommand:      []string{"/usr/local/bin/perf"},
+		OutputFormats:        []string{"svg", "cpuprofile", "perf"},
+		RequiredCapabilities: []string{"SYS_PTRACE", "SYS_ADMIN", "PERFMON"},
 		EnvironmentVars: map[string]string{
-			"NODE_OPTIONS":       "--inspect",
 			"PROFILING_LANGUAGE": "node",
 		},
 	}
 
-	// Rust language configuration
+	// Rust language configuration, also used for C and C++ by 'kubectl pprof native'
 	lm.configs[LanguageRust] = &LanguageConfig{
 		Language:             LanguageRust,
-		SupportedTypes:       []string{"cpu", "memory"},
+		SupportedTypes:       []string{"cpu"},
 		DefaultType:          "cpu",
-		DefaultImage:         "rust-profiler:latest",
+		DefaultImage:         "golang-profiling:latest",
 		ProfilerCommand:      []string{"/usr/local/bin/perf"},
 		OutputFormats:        []string{"svg", "flamegraph", "perf"},
-		RequiredCapabilities: []string{"SYS_PTRACE", "SYS_ADMIN"},
+		RequiredCapabilities: []string{"SYS_PTRACE", "SYS_ADMIN", "PERFMON"},
 		EnvironmentVars: map[string]string{
 			"PROFILING_LANGUAGE": "rust",
 		},
@@ -228,29 +245,32 @@ func (lm *LanguageManager) getPythonProfilerArgs(cfg *ProfileConfig, opts *Profi
 	return args
 }
 
+// getNodeProfilerArgs returns the perf arguments of the Job, processes with an
+// inspector are profiled through it instead and take no arguments
 func (lm *LanguageManager) getNodeProfilerArgs(cfg *ProfileConfig, opts *ProfileOptions) []string {
-	args := []string{
-		"--profile-type", cfg.ProfileType,
-		"--duration", cfg.Duration.String(),
-		"--output", "/tmp/profile.cpuprofile",
-		"--pid", "1", // Will be replaced with actual PID
+	frequency := "99"
+	if opts.SampleRate > 0 {
+		frequency = fmt.Sprintf("%d", opts.SampleRate)
+	}
+	return []string{
+		"record",
+		"-F", frequency,
+		"--call-graph", UnwindFP,
+		"-p", "1", // Will be replaced with actual PID
+		"--", "sleep", fmt.Sprintf("%d", int(cfg.Duration.Seconds())),
 	}
-	
-	return args
 }
 
 func (lm *LanguageManager) getRustProfilerArgs(cfg *ProfileConfig, opts *ProfileOptions) []string {
-	args := []string{
+	frequency := "99"
+	if opts.SampleRate > 0 {
+		frequency = fmt.Sprintf("%d", opts.SampleRate)
+	}
+	return []string{
 		"record",
-		"-F", "99",
+		"-F", frequency,
+		"--call-graph", cfg.GetUnwind(),
 		"-p", "1", // Will be replaced with actual PID
-		"--", "sleep", cfg.Duration.String(),
+		"--", "sleep", fmt.Sprintf("%d", int(cfg.Duration.Seconds())),
 	}
-	
-	if opts.SampleRate > 0 {
-		args[1] = "-F"
-		args[2] = fmt.Sprintf("%d", opts.SampleRate)
-	}
-	
-	return args
 }
\ No newline at end of file
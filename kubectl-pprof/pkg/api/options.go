@@ -0,0 +1,97 @@
+package api
+
+import "time"
+
+// ConfigOption customizes a ProfileConfig built by NewProfileConfig
+type ConfigOption func(*ProfileConfig)
+
+// NewProfileConfig returns the configuration of a session profiling a pod,
+// with the defaults of the command line flags
+func NewProfileConfig(namespace, podName string, options ...ConfigOption) *ProfileConfig {
+	cfg := &ProfileConfig{
+		Namespace:           namespace,
+		PodName:             podName,
+		CgroupOnly:          true,
+		Language:            string(LanguageAuto),
+		ProfileType:         ProfileTypeCPU,
+		Duration:            30 * time.Second,
+		OutputPath:          "flamegraph.svg",
+		Snapshots:           5,
+		NetThreshold:        time.Millisecond,
+		Mode:                ModeAuto,
+		JobName:             "kubectl-pprof",
+		Image:               "golang-profiling:latest",
+		ImagePullPolicy:     "IfNotPresent",
+		Cleanup:             true,
+		JobTTL:              time.Hour,
+		Privileged:          true,
+		PprofProfile:        "profile",
+		RuntimeMetrics:      true,
+		ThrottleWarnPercent: 10,
+		MaxConcurrentJobs:   5,
+		ExtraArgs:           []string{},
+		EnvVars:             map[string]string{},
+		ResourceLimits:      &ResourceLimits{CPU: "1000m", Memory: "512Mi"},
+		CrictlPath:          "/usr/bin/crictl",
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+// WithContainer profiles the named container instead of the detected one
+func WithContainer(name string) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.ContainerName = name }
+}
+
+// WithDuration sets how long to profile
+func WithDuration(duration time.Duration) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.Duration = duration }
+}
+
+// WithProfileType sets what to measure: cpu, schedlat, heap or net
+func WithProfileType(profileType string) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.ProfileType = profileType }
+}
+
+// WithMode sets how the target is reached
+func WithMode(mode ProfileMode) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.Mode = mode }
+}
+
+// WithImage sets the profiling image
+func WithImage(image string) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.Image = image }
+}
+
+// WithOutputPath sets the local path of the flame graph; other artifacts are
+// written next to it
+func WithOutputPath(path string) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.OutputPath = path }
+}
+
+// WithJobNamespace creates the profiling Job outside the target namespace
+func WithJobNamespace(namespace string) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.JobNamespace = namespace }
+}
+
+// WithLanguage sets the language of the target instead of detecting it
+func WithLanguage(lang Language) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.Language = string(lang) }
+}
+
+// WithGoOptions sets the Go specific sampling and rendering options
+func WithGoOptions(goOpts GoProfilingOptions) ConfigOption {
+	return func(cfg *ProfileConfig) { cfg.GoOptions = &goOpts }
+}
+
+// DefaultProfileOptions returns the output options of the command line
+// defaults: an SVG flame graph at 96 DPI
+func DefaultProfileOptions() *ProfileOptions {
+	return &ProfileOptions{
+		FlameGraph:   true,
+		OutputFormat: "svg",
+		DPI:          96,
+	}
+}
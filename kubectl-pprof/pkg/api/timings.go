@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// Default margins around the sampling window of a run
+const (
+	DefaultStartupTimeout = 2 * time.Minute // Scheduling, image pull and finding the target process
+	DefaultFlushTimeout   = time.Minute     // Rendering and printing the artifacts after sampling
+)
+
+// monitorSlack lets the Job fail on its own deadline before the CLI gives up
+// waiting for it, so the failure reason is the Job's
+const monitorSlack = 30 * time.Second
+
+// leaseSlack keeps the node Lease past the wait for the run; the Lease of a
+// crashed session expires this long after the session would have given up
+const leaseSlack = time.Minute
+
+// cleanupTimeout bounds deleting the Job of a finished or aborted run
+const cleanupTimeout = 30 * time.Second
+
+// Timings are the deadlines of one profiling run. They all derive from the
+// profiling duration, so a long profile never outlives the wait for it.
+type Timings struct {
+	// ActiveDeadline bounds the profiler pod from its start to the last
+	// artifact, the activeDeadlineSeconds of the Job
+	ActiveDeadline time.Duration
+	// Monitor is how long the session waits for the run to finish
+	Monitor time.Duration
+	// Lease is how long the node Lease is held for the run
+	Lease time.Duration
+	// Cleanup bounds deleting the Job once the run ended
+	Cleanup time.Duration
+	// Serve is how long the launcher serves the artifacts of a port-forward
+	// transfer before printing them to the logs, half the flush timeout so
+	// printing still fits in it
+	Serve time.Duration
+}
+
+// Timings derives the deadlines of a run: the startup timeout, the duration
+// and the flush timeout, or Timeout when set
+func (c *ProfileConfig) Timings() Timings {
+	startup, flush := c.StartupTimeout, c.FlushTimeout
+	if startup <= 0 {
+		startup = DefaultStartupTimeout
+	}
+	if flush <= 0 {
+		flush = DefaultFlushTimeout
+	}
+
+	active := startup + c.Duration + flush
+	if c.Timeout > 0 {
+		active = c.Timeout
+	}
+	monitor := active + monitorSlack
+	return Timings{
+		ActiveDeadline: active,
+		Monitor:        monitor,
+		Lease:          monitor + leaseSlack,
+		Cleanup:        cleanupTimeout,
+		Serve:          flush / 2,
+	}
+}
+
+// ValidateTimings checks that an explicit Timeout leaves the run time to
+// sample for the whole duration
+func (c *ProfileConfig) ValidateTimings() error {
+	if c.StartupTimeout < 0 || c.FlushTimeout < 0 || c.Timeout < 0 {
+		return fmt.Errorf("--timeout, --startup-timeout and --flush-timeout must not be negative")
+	}
+	if c.Timeout > 0 && c.Timeout <= c.Duration {
+		return fmt.Errorf("timeout (%v) must be greater than duration (%v), leave it unset to derive it from the duration", c.Timeout, c.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ProfileConfig
+		want Timings
+	}{
+		{
+			name: "default margins",
+			cfg:  ProfileConfig{Duration: 30 * time.Second},
+			want: Timings{
+				ActiveDeadline: 3*time.Minute + 30*time.Second,
+				Monitor:        4 * time.Minute,
+				Lease:          5 * time.Minute,
+				Cleanup:        30 * time.Second,
+				Serve:          30 * time.Second,
+			},
+		},
+		{
+			name: "custom margins",
+			cfg:  ProfileConfig{Duration: 10 * time.Minute, StartupTimeout: 5 * time.Minute, FlushTimeout: 4 * time.Minute},
+			want: Timings{
+				ActiveDeadline: 19 * time.Minute,
+				Monitor:        19*time.Minute + 30*time.Second,
+				Lease:          20*time.Minute + 30*time.Second,
+				Cleanup:        30 * time.Second,
+				Serve:          2 * time.Minute,
+			},
+		},
+		{
+			name: "negative margins use the defaults",
+			cfg:  ProfileConfig{Duration: time.Minute, StartupTimeout: -time.Second, FlushTimeout: -time.Second},
+			want: Timings{
+				ActiveDeadline: 4 * time.Minute,
+				Monitor:        4*time.Minute + 30*time.Second,
+				Lease:          5*time.Minute + 30*time.Second,
+				Cleanup:        30 * time.Second,
+				Serve:          30 * time.Second,
+			},
+		},
+		{
+			name: "timeout replaces the margins",
+			cfg:  ProfileConfig{Duration: time.Minute, StartupTimeout: 5 * time.Minute, Timeout: 2 * time.Minute},
+			want: Timings{
+				ActiveDeadline: 2 * time.Minute,
+				Monitor:        2*time.Minute + 30*time.Second,
+				Lease:          3*time.Minute + 30*time.Second,
+				Cleanup:        30 * time.Second,
+				Serve:          30 * time.Second,
+			},
+		},
+		{
+			name: "long profile",
+			cfg:  ProfileConfig{Duration: 2 * time.Hour},
+			want: Timings{
+				ActiveDeadline: 2*time.Hour + 3*time.Minute,
+				Monitor:        2*time.Hour + 3*time.Minute + 30*time.Second,
+				Lease:          2*time.Hour + 4*time.Minute + 30*time.Second,
+				Cleanup:        30 * time.Second,
+				Serve:          30 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cfg.Timings()
+			if got != tt.want {
+				t.Errorf("Timings() = %+v, want %+v", got, tt.want)
+			}
+			// The session outwaits the Job, and the Lease outlives the session
+			if got.Monitor <= got.ActiveDeadline || got.Lease <= got.Monitor {
+				t.Errorf("Timings() = %+v, want ActiveDeadline < Monitor < Lease", got)
+			}
+		})
+	}
+}
+
+func TestValidateTimings(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ProfileConfig
+		wantErr string
+	}{
+		{name: "derived", cfg: ProfileConfig{Duration: 30 * time.Second}},
+		{name: "custom margins", cfg: ProfileConfig{Duration: 30 * time.Second, StartupTimeout: time.Minute, FlushTimeout: time.Minute}},
+		{name: "timeout above duration", cfg: ProfileConfig{Duration: 30 * time.Second, Timeout: 31 * time.Second}},
+		{name: "timeout equal to duration", cfg: ProfileConfig{Duration: 30 * time.Second, Timeout: 30 * time.Second}, wantErr: "must be greater than duration"},
+		{name: "timeout below duration", cfg: ProfileConfig{Duration: time.Minute, Timeout: 30 * time.Second}, wantErr: "must be greater than duration"},
+		{name: "negative timeout", cfg: ProfileConfig{Duration: 30 * time.Second, Timeout: -time.Second}, wantErr: "must not be negative"},
+		{name: "negative startup timeout", cfg: ProfileConfig{Duration: 30 * time.Second, StartupTimeout: -time.Second}, wantErr: "must not be negative"},
+		{name: "negative flush timeout", cfg: ProfileConfig{Duration: 30 * time.Second, FlushTimeout: -time.Second}, wantErr: "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.ValidateTimings()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("ValidateTimings() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("ValidateTimings() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,1145 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProfileConfig represents the configuration for profiling operations
+type ProfileConfig struct {
+	// Target information
+	Namespace     string `json:"namespace"`
+	PodName       string `json:"podName"`
+	ContainerName string `json:"containerName"`
+	PID           string `json:"pid,omitempty"` // Process IDs to profile, comma separated, as seen in the target container
+	Strict        bool   `json:"strict,omitempty"` // Pick the first container instead of skipping sidecars
+
+	// Profile every container of the pod, each in its own Job
+	AllContainers      bool `json:"allContainers,omitempty"`
+	ParallelContainers bool `json:"parallelContainers,omitempty"` // Run the per-container Jobs concurrently
+	MergeContainers    bool `json:"mergeContainers,omitempty"`    // Also render one graph with a frame per container or node
+
+	// Profile one pod per node of a workload (daemonset/NAME, deployment/NAME or statefulset/NAME)
+	Spread            string `json:"spread,omitempty"`
+	MaxConcurrentJobs int    `json:"maxConcurrentJobs,omitempty"` // Cluster-wide cap on active profiling Jobs with Spread
+	NodeAntiAffinity  bool   `json:"nodeAntiAffinity,omitempty"`  // Keep the Job off nodes running another session's Job
+
+	// Profile a process of the node itself (e.g. kubelet) instead of a pod, on NodeName
+	HostProcess string `json:"hostProcess,omitempty"`
+
+	// Find the target by container image glob (e.g. ghcr.io/foo/api:*) instead of pod name
+	TargetImage string `json:"targetImage,omitempty"`
+	AllMatches  bool   `json:"allMatches,omitempty"` // Profile every matching container instead of picking one
+
+	// Profile the replacement pod when a Deployment's target pod is lost mid-profile
+	Retarget bool `json:"retarget,omitempty"`
+
+	// Stream the folded stacks sampled so far while a long profile runs
+	Live         bool          `json:"live,omitempty"`
+	LiveInterval time.Duration `json:"liveInterval,omitempty"` // Time between two snapshots, DefaultLiveInterval when 0
+
+	// Start the Job and return without waiting, its results are fetched
+	// later by `kubectl pprof collect`
+	NoCollect bool `json:"noCollect,omitempty"`
+
+	// Sampling scope around the target process
+	IncludeChildren bool `json:"includeChildren,omitempty"` // Also sample descendants of the target process
+	CgroupOnly      bool `json:"cgroupOnly,omitempty"`      // Drop samples from outside the target container's cgroup
+	// Also sample every process of the target container, e.g. pre-forked workers
+	AllProcesses bool `json:"allProcesses,omitempty"`
+	// Keep each sampled process under a root frame of its own instead of merging them
+	SeparateProcesses bool `json:"separateProcesses,omitempty"`
+	// Put the kernel frames of every sample, suffixed _[k], on top of its user stack
+	IncludeKernelStacks bool `json:"includeKernelStacks,omitempty"`
+	// Root every on-CPU stack at a cpuN frame naming the CPU it was sampled on
+	PerCPU bool `json:"perCpu,omitempty"`
+	// Synthetic frame every stack is rooted at: none, thread or labels
+	Annotate string `json:"annotate,omitempty"`
+	// Keep the samples whose runtime/pprof labels match these "key=regex" filters
+	TagFilters []string `json:"tagFilters,omitempty"`
+	// List the trace IDs the pprof labels of the samples name, read from the pprof endpoint
+	Traces bool `json:"traces,omitempty"`
+	// pprof labels holding the trace ID, DefaultTraceLabels when empty
+	TraceLabels []string `json:"traceLabels,omitempty"`
+	// Frame granularity: functions, or lines for "function file:line" frames
+	Granularity string `json:"granularity,omitempty"`
+	// Print the hot source lines of the functions matching this regex
+	List string `json:"list,omitempty"`
+	// Save a compact markdown summary of the profile to this path
+	ExportSummary string `json:"exportSummary,omitempty"`
+	// Stacks file the summary lists the changes against
+	Baseline string `json:"baseline,omitempty"`
+	// Recognize common performance problems in the stacks and list them as findings
+	Analyze bool `json:"analyze,omitempty"`
+	// Link the frames of the HTML report to their source, a URL with {commit}, {file} and {line}
+	SourceURLTemplate string `json:"sourceUrlTemplate,omitempty"`
+	// Also convert the stacks into these formats next to the output file, e.g. png, pprof, speedscope
+	AlsoFormats []string `json:"alsoFormats,omitempty"`
+
+	// net/http/pprof endpoint of the target, profiled in pprof-endpoint mode and read for runtime metrics
+	PprofPort      string `json:"pprofPort,omitempty"`      // Port number or container port name, detected when empty
+	PprofPath      string `json:"pprofPath,omitempty"`      // Handler path, /debug/pprof by default
+	PprofProfile   string `json:"pprofProfile,omitempty"`   // profile (CPU), heap, allocs, goroutine, block, mutex, threadcreate
+	RuntimeMetrics bool   `json:"runtimeMetrics,omitempty"` // Snapshot runtime metrics around the window when a pprof endpoint exists
+
+	// Warn when more than this share of CFS periods were throttled during the profile
+	ThrottleWarnPercent float64 `json:"throttleWarnPercent,omitempty"`
+	// Sample the CPU and memory usage of the target container during the window
+	ResourceUsage bool `json:"resourceUsage,omitempty"`
+	// Heap snapshots spread over the duration by --profile-type heap
+	Snapshots int `json:"snapshots,omitempty"`
+	// Network calls at least this slow get their stack recorded by --profile-type net
+	NetThreshold time.Duration `json:"netThreshold,omitempty"`
+	// Stop sampling once this many on-CPU samples were captured, Duration bounds the wait
+	MinSamples  int64         `json:"minSamples,omitempty"`
+	MaxDuration time.Duration `json:"maxDuration,omitempty"` // Replaces Duration as the bound with MinSamples, set by the CLI
+
+	// Profiling parameters
+	Duration    time.Duration `json:"duration"`
+	ProfileType string        `json:"profileType"` // cpu, schedlat, heap or net
+	OutputPath  string        `json:"outputPath"`
+	Language    string        `json:"language"` // go, java, python, etc., auto to detect it
+
+	// Job configuration
+	Mode            ProfileMode   `json:"mode,omitempty"` // auto, job, ephemeral or pprof-endpoint
+	JobName         string        `json:"jobName"`
+	JobNamespace    string        `json:"jobNamespace,omitempty"` // Namespace for the Job, defaults to the target namespace
+	Image           string        `json:"image"`
+	ImageArchSuffix string        `json:"imageArchSuffix,omitempty"` // Per-architecture tag suffix, e.g. "-{arch}"
+	ImagePullPolicy string        `json:"imagePullPolicy"` // Always, IfNotPresent, Never
+	NodeName        string        `json:"nodeName,omitempty"`
+	Timeout         time.Duration `json:"timeout"`                  // Deadline of the profiling pod, derived from Duration when 0
+	StartupTimeout  time.Duration `json:"startupTimeout,omitempty"` // Margin before sampling, DefaultStartupTimeout when 0
+	FlushTimeout    time.Duration `json:"flushTimeout,omitempty"`   // Margin after sampling, DefaultFlushTimeout when 0
+	Cleanup         bool          `json:"cleanup"`
+	KeepFailedJobs  bool          `json:"keepFailedJobs,omitempty"` // Leave failed Jobs for inspection even with Cleanup
+	JobTTL          time.Duration `json:"jobTTL,omitempty"`         // ttlSecondsAfterFinished of the Job, 0 leaves it unset
+	Privileged      bool          `json:"privileged"`
+	Force           bool          `json:"force,omitempty"`       // Profile even when the estimated overhead is too high or the node under pressure
+	Overwrite       bool          `json:"overwrite,omitempty"`   // Replace an existing output file instead of failing
+	JobTemplate     string        `json:"jobTemplate,omitempty"` // YAML overlay merged onto the generated Job
+	ScriptTemplate  string        `json:"scriptTemplate,omitempty"` // text/template replacing the embedded script of the Job
+	DirectExec      bool          `json:"directExec,omitempty"`     // Run the profiler through the launcher of the image instead of a shell script
+	Compression     string        `json:"compression,omitempty"`    // Encoding of the artifacts in the logs: gzip or zstd, gzip when empty
+	Transfer        string        `json:"transfer,omitempty"`       // How the artifacts reach the CLI, see GetTransfer
+	LeaseNamespace  string        `json:"leaseNamespace,omitempty"` // Namespace of the per-node Leases, DefaultLeaseNamespace when empty
+	WaitForSlot     time.Duration `json:"waitForSlot,omitempty"`    // How long to queue for a node another session profiles, 0 fails at once
+	LeaseHolder     string        `json:"leaseHolder,omitempty"`    // Lease holder shared by sessions sampling a node together, the Job name when empty
+	PriorityClass   string        `json:"priorityClass,omitempty"`  // PriorityClass of the profiling pod
+	Tolerations     []string      `json:"tolerations,omitempty"`    // Extra tolerations as key[=value][:effect]
+	TolerateAll     bool          `json:"tolerateAll,omitempty"`    // Tolerate every taint instead of copying the target pod's tolerations
+	OpenShift       *bool         `json:"openShift,omitempty"`      // Admit the Job through an SCC and talk to CRI-O, detected from the cluster when nil
+	SCC             string        `json:"scc,omitempty"`            // SecurityContextConstraints the Job requests on OpenShift, DefaultSCC when empty
+
+	// Pod identity and registry access
+	ServiceAccount   string   `json:"serviceAccount,omitempty"`   // ServiceAccount the profiler pod runs as
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"` // Secrets used to pull the profiling image
+	// Registry host, optionally with a path, replacing the registry of the
+	// profiling image, for clusters that only reach an internal mirror
+	RegistryMirror string `json:"registryMirror,omitempty"`
+	// Digest the profiling image is pinned to, e.g. sha256:<64 hex digits>
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// Refuse to run a profiling image that is not pinned by digest
+	RequireDigest bool `json:"requireDigest,omitempty"`
+
+	// Audit trail of who profiled what
+	NoEvents       bool   `json:"noEvents,omitempty"`       // Do not record a Kubernetes Event on the target pod
+	AuditConfigMap string `json:"auditConfigMap,omitempty"` // namespace/name of a ConfigMap the session is appended to
+
+	// Advanced options
+    ExtraArgs     []string          `json:"extraArgs,omitempty"`
+    EnvVars       map[string]string `json:"envVars,omitempty"`
+    ResourceLimits *ResourceLimits   `json:"resourceLimits,omitempty"`
+    CrictlPath    string            `json:"crictlPath,omitempty"` // Path to crictl binary on the node
+
+	// Go-specific options
+	GoOptions *GoProfilingOptions `json:"goOptions,omitempty"`
+	// Rust, C and C++ specific options
+	NativeOptions *NativeProfilingOptions `json:"nativeOptions,omitempty"`
+	// Node.js specific options
+	NodeOptions *NodeProfilingOptions `json:"nodeOptions,omitempty"`
+}
+
+// GetJobNamespace returns the namespace the profiling Job runs in
+func (c *ProfileConfig) GetJobNamespace() string {
+	if c.JobNamespace != "" {
+		return c.JobNamespace
+	}
+	return c.Namespace
+}
+
+// DefaultLiveInterval is the time between two snapshots of a Live session
+const DefaultLiveInterval = 30 * time.Second
+
+// SnapshotInterval returns the time between two snapshots of a Live session
+func (c *ProfileConfig) SnapshotInterval() time.Duration {
+	if c.LiveInterval > 0 {
+		return c.LiveInterval
+	}
+	return DefaultLiveInterval
+}
+
+// PIDs parses PID, the process IDs to profile with the first as the target
+// whose runtime is inspected; nil when the target is detected instead
+func (c *ProfileConfig) PIDs() ([]int, error) {
+	if c.PID == "" {
+		return nil, nil
+	}
+	var pids []int
+	for _, field := range strings.Split(c.PID, ",") {
+		pid, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("invalid --pid %q: must be positive process IDs separated by commas", c.PID)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// DefaultLeaseNamespace holds the per-node Leases guarding against two
+// sessions sampling the same node
+const DefaultLeaseNamespace = "default"
+
+// GetLeaseNamespace returns the namespace of the per-node Leases
+func (c *ProfileConfig) GetLeaseNamespace() string {
+	if c.LeaseNamespace != "" {
+		return c.LeaseNamespace
+	}
+	return DefaultLeaseNamespace
+}
+
+// DefaultSCC is the SecurityContextConstraints profiling Jobs request on
+// OpenShift, the only built-in one admitting privileged hostPID pods
+const DefaultSCC = "privileged"
+
+// IsOpenShift reports whether the Job is built for OpenShift
+func (c *ProfileConfig) IsOpenShift() bool {
+	return c.OpenShift != nil && *c.OpenShift
+}
+
+// GetSCC returns the SecurityContextConstraints the Job requests on OpenShift
+func (c *ProfileConfig) GetSCC() string {
+	if c.SCC != "" {
+		return c.SCC
+	}
+	return DefaultSCC
+}
+
+// RetainJob reports whether a finished Job is kept instead of deleted
+func (c *ProfileConfig) RetainJob(succeeded bool) bool {
+	return !c.Cleanup || (c.KeepFailedJobs && !succeeded)
+}
+
+// SamplingFrequency returns the sampling frequency of the session in Hz, 0
+// for traced profiles which sample nothing
+func (c *ProfileConfig) SamplingFrequency() int {
+	if c.ProfileType == ProfileTypeSchedLat || c.ProfileType == ProfileTypeNet {
+		return 0
+	}
+	if c.GoOptions != nil && c.GoOptions.Frequency > 0 {
+		return c.GoOptions.Frequency
+	}
+	return DefaultFrequency
+}
+
+// GoProfilingOptions Go language specific profiling options
+type GoProfilingOptions struct {
+	OffCPU       bool    `json:"offCpu,omitempty"`       // Enable off-CPU analysis
+	Frequency    int     `json:"frequency,omitempty"`    // Sampling frequency (Hz)
+	Title        string  `json:"title,omitempty"`        // Flame graph title
+	Subtitle     string  `json:"subtitle,omitempty"`     // Flame graph subtitle
+	Colors       string  `json:"colors,omitempty"`       // Color scheme
+	BgColors     string  `json:"bgColors,omitempty"`     // Background colors
+	Width        int     `json:"width,omitempty"`        // Image width
+	Height       int     `json:"height,omitempty"`       // Frame height
+	FontType     string  `json:"fontType,omitempty"`     // Font type
+	FontSize     float64 `json:"fontSize,omitempty"`     // Font size
+	Inverted     bool    `json:"inverted,omitempty"`     // Generate inverted icicle graph
+	FlameChart   bool    `json:"flameChart,omitempty"`   // Generate flame chart
+	Hash         bool    `json:"hash,omitempty"`         // Use hash-based colors
+	Random       bool    `json:"random,omitempty"`       // Use random colors
+	ExportFolded string  `json:"exportFolded,omitempty"` // Export folded stack file path
+	ClientRender bool    `json:"clientRender,omitempty"` // Render the flame graph locally from folded stacks
+	// Capture a goroutine stack dump from the pprof endpoint after profiling
+	GoroutineDump bool `json:"goroutineDump,omitempty"`
+}
+
+// Stack unwinding methods of native profiling
+const (
+	UnwindDWARF = "dwarf" // Copies the user stack with each sample and unwinds it with the DWARF CFI
+	UnwindFP    = "fp"    // Walks frame pointers, cheap but broken by -fomit-frame-pointer
+)
+
+// NativeProfilingOptions Rust、C 与 C++ 程序的分析选项
+type NativeProfilingOptions struct {
+	Unwind        string `json:"unwind,omitempty"`        // dwarf or fp
+	DebuginfodURL string `json:"debuginfodUrl,omitempty"` // Server perf fetches missing debug info from
+}
+
+// GetUnwind returns the unwinding method of native profiling, DWARF by default
+func (c *ProfileConfig) GetUnwind() string {
+	if c.NativeOptions != nil && c.NativeOptions.Unwind != "" {
+		return c.NativeOptions.Unwind
+	}
+	return UnwindDWARF
+}
+
+// Synthetic frames stacks are annotated with
+const (
+	AnnotateNone   = "none"   // No annotation
+	AnnotateThread = "thread" // A thread:name frame naming the OS thread of the sample
+	AnnotateLabels = "labels" // A frame of the runtime/pprof labels of the goroutine, from the pprof endpoint
+)
+
+// GetAnnotate returns the frame stacks are annotated with, none by default
+func (c *ProfileConfig) GetAnnotate() string {
+	if c.Annotate != "" {
+		return c.Annotate
+	}
+	return AnnotateNone
+}
+
+// Frame granularities
+const (
+	GranularityFunctions = "functions" // One frame per function
+	GranularityLines     = "lines"     // One frame per source line, "function file:line"
+)
+
+// Artifact compressions
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd" // Smaller and faster, gzip when the profiling image lacks zstd
+)
+
+// GetCompression returns the encoding of the artifacts, gzip by default
+func (c *ProfileConfig) GetCompression() string {
+	if c.Compression == "" {
+		return CompressionGzip
+	}
+	return c.Compression
+}
+
+// Artifact transfers
+const (
+	TransferLogs        = "logs"         // Printed to the profiler logs
+	TransferPortForward = "port-forward" // Served by the launcher and fetched through a port-forward, the logs as fallback
+)
+
+// GetTransfer returns how the artifacts reach the CLI: port-forward for
+// --direct-exec runs, whose launcher serves them, and logs otherwise
+func (c *ProfileConfig) GetTransfer() string {
+	if c.Transfer != "" {
+		return c.Transfer
+	}
+	if c.DirectExec {
+		return TransferPortForward
+	}
+	return TransferLogs
+}
+
+// GetGranularity returns the frame granularity, lines for --list and
+// --source-url-template which need them and functions otherwise
+func (c *ProfileConfig) GetGranularity() string {
+	if c.Granularity != "" {
+		return c.Granularity
+	}
+	if c.List != "" || c.SourceURLTemplate != "" {
+		return GranularityLines
+	}
+	return GranularityFunctions
+}
+
+// ReadsLabels reports whether the session needs the runtime/pprof labels of
+// the samples, which only the pprof endpoint of the Go runtime provides
+func (c *ProfileConfig) ReadsLabels() bool {
+	return c.GetAnnotate() == AnnotateLabels || len(c.TagFilters) > 0 || c.Traces
+}
+
+// DefaultTraceLabels are the pprof labels OpenTelemetry integrations commonly
+// put the trace ID of the active span in
+var DefaultTraceLabels = []string{"trace_id", "traceID", "otel.trace_id"}
+
+// GetTraceLabels returns the pprof labels holding the trace ID
+func (c *ProfileConfig) GetTraceLabels() []string {
+	if len(c.TraceLabels) > 0 {
+		return c.TraceLabels
+	}
+	return DefaultTraceLabels
+}
+
+// How a Node.js process is attached to
+const (
+	NodeAttachAuto      = "auto"      // The inspector when the process listens for one, perf otherwise
+	NodeAttachInspector = "inspector" // V8 CPU profiler through the inspector protocol
+	NodeAttachPerf      = "perf"      // perf in the Job, JavaScript frames named by --perf-basic-prof maps
+)
+
+// NodeProfilingOptions Node.js 程序的分析选项
+type NodeProfilingOptions struct {
+	Attach      string `json:"attach,omitempty"`      // auto, inspector or perf
+	InspectPort int32  `json:"inspectPort,omitempty"` // Inspector port, detected from --inspect when 0
+}
+
+// GetNodeAttach returns how a Node.js process is attached to, auto by default
+func (c *ProfileConfig) GetNodeAttach() string {
+	if c.NodeOptions != nil && c.NodeOptions.Attach != "" {
+		return c.NodeOptions.Attach
+	}
+	return NodeAttachAuto
+}
+
+// ResourceLimits 资源限制
+type ResourceLimits struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// TargetInfo 目标容器信息
+type TargetInfo struct {
+	Namespace     string `json:"namespace"`
+	PodName       string `json:"podName"`
+	ContainerName string `json:"containerName"`
+	NodeName      string `json:"nodeName"`
+	PodUID        string `json:"podUID"`
+	ContainerID   string `json:"containerID"`
+	PID           int32  `json:"pid,omitempty"`
+	Status        string `json:"status"`
+	Image         string `json:"image"`
+	Command       []string `json:"command,omitempty"`
+	Args          []string `json:"args,omitempty"`
+	Pod           interface{} `json:"pod,omitempty"` // *corev1.Pod
+	Container     interface{} `json:"container,omitempty"` // *corev1.Container
+	NodeInfo      *NodeInfo `json:"nodeInfo,omitempty"`
+	RuntimeInfo   *RuntimeInfo `json:"runtimeInfo,omitempty"`
+	// Why the container was picked when none was named
+	SelectionReason string `json:"selectionReason,omitempty"`
+	// Static pod run by the kubelet from a manifest, seen through its mirror pod
+	StaticPod bool `json:"staticPod,omitempty"`
+	// UID in the cgroup paths of the pod, the manifest hash for a static pod
+	CgroupUID string `json:"cgroupUID,omitempty"`
+	// Name of the node process profiled instead of a pod, see ProfileConfig.HostProcess
+	HostProcess string `json:"hostProcess,omitempty"`
+	// Kernel capabilities of the node cached from an earlier run, which the
+	// Job uses instead of probing them again
+	Preflight *PreflightReport `json:"preflight,omitempty"`
+}
+
+// JobStatus Job执行状态
+type JobStatus struct {
+	JobName   string             `json:"jobName"`
+	Namespace string             `json:"namespace"`
+	Phase     JobPhase           `json:"phase"`
+	StartTime *time.Time         `json:"startTime,omitempty"`
+	EndTime   *time.Time         `json:"endTime,omitempty"`
+	Message   string             `json:"message,omitempty"`
+	PodName   string             `json:"podName,omitempty"`
+	Conditions []JobCondition    `json:"conditions,omitempty"`
+}
+
+// JobPhase Job阶段
+type JobPhase string
+
+const (
+	JobPhasePending   JobPhase = "Pending"
+	JobPhaseRunning   JobPhase = "Running"
+	JobPhaseSucceeded JobPhase = "Succeeded"
+	JobPhaseFailed    JobPhase = "Failed"
+	JobPhaseTimeout   JobPhase = "Timeout"
+)
+
+// JobCondition Job条件
+type JobCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+}
+
+// NodeCondition 节点条件
+type NodeCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+}
+
+// ProgressEventType 进度事件类型
+type ProgressEventType string
+
+const (
+	EventTargetResolved     ProgressEventType = "TargetResolved"     // Pod, container and node of the target are known
+	EventJobCreated         ProgressEventType = "JobCreated"         // Profiling Job or ephemeral container created
+	EventPodRunning         ProgressEventType = "PodRunning"         // Profiler pod or container started
+	EventSamplingStarted    ProgressEventType = "SamplingStarted"    // Profiler began collecting samples
+	EventSamplingFinished   ProgressEventType = "SamplingFinished"   // Profiler stopped, artifacts are ready to collect
+	EventArtifactDownloaded ProgressEventType = "ArtifactDownloaded" // An artifact was written locally
+)
+
+// ProgressEvent 分析进度事件
+type ProgressEvent struct {
+	Type      ProgressEventType `json:"type"`
+	Time      time.Time         `json:"time"`
+	Namespace string            `json:"namespace,omitempty"` // Target namespace
+	PodName   string            `json:"podName,omitempty"`   // Target pod
+	Container string            `json:"container,omitempty"` // Target container
+	NodeName  string            `json:"nodeName,omitempty"`  // Node of the target pod
+	Mode      ProfileMode       `json:"mode,omitempty"`      // Resolved profiling mode
+	JobName   string            `json:"jobName,omitempty"`   // Profiling Job or ephemeral container
+	Artifact  string            `json:"artifact,omitempty"`  // Kind of a downloaded artifact, e.g. "Flamegraph"
+	Path      string            `json:"path,omitempty"`      // Local path of a downloaded artifact
+}
+
+// ProgressFunc 接收分析进度事件，在分析所在的 goroutine 中同步调用
+type ProgressFunc func(ProgressEvent)
+
+// ProfileResult 分析结果
+type ProfileResult struct {
+	Config     *ProfileConfig `json:"config"`
+	JobStatus  *JobStatus     `json:"jobStatus"`
+	OutputPath string         `json:"outputPath"`
+	FileSize   int64          `json:"fileSize"`
+	Duration   time.Duration  `json:"duration"`
+	Samples    int64          `json:"samples,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	JobName    string         `json:"jobName"`
+	Success    bool           `json:"success"`
+
+	// Kernel feature report gathered by the job before sampling
+	Preflight *PreflightReport `json:"preflight,omitempty"`
+	// Session facts embedded into the artifact
+	Metadata *SessionMetadata `json:"metadata,omitempty"`
+	// Local path of the exported folded stacks, if requested
+	FoldedPath string `json:"foldedPath,omitempty"`
+	// Local path of the time-ordered stacks kept for flame charts
+	TimelinePath string `json:"timelinePath,omitempty"`
+	// Local path of the markdown summary, if requested
+	SummaryPath string `json:"summaryPath,omitempty"`
+	// Local paths of the --also-format conversions, by format
+	ConvertedPaths map[string]string `json:"convertedPaths,omitempty"`
+	// Estimated and measured cost of profiling on the target node
+	Overhead *OverheadReport `json:"overhead,omitempty"`
+	// Local path of the raw profile fetched from a pprof endpoint
+	PprofPath string `json:"pprofPath,omitempty"`
+	// Local path of the V8 CPU profile taken through the Node.js inspector
+	CPUProfilePath string `json:"cpuProfilePath,omitempty"`
+	// Goroutine dump captured alongside the profile
+	Goroutines *GoroutineReport `json:"goroutines,omitempty"`
+	// Run queue latency histogram of a schedlat profile
+	SchedLatency *SchedLatencyReport `json:"schedLatency,omitempty"`
+	// On-CPU samples of a --per-cpu profile by CPU
+	CPUBreakdown *CPUBreakdownReport `json:"cpuBreakdown,omitempty"`
+	// Hot source lines of the functions matching --list
+	Listing string `json:"listing,omitempty"`
+	// Common performance problems recognized in the stacks, largest share first
+	Findings []Finding `json:"findings,omitempty"`
+	// Trace IDs the pprof labels of the samples name
+	Traces *TraceReport `json:"traces,omitempty"`
+	// Go build information embedded in the target executable
+	BuildInfo *BuildInfo `json:"buildInfo,omitempty"`
+	// CPU throttling of the target container during the profile
+	Throttling *ThrottlingReport `json:"throttling,omitempty"`
+	// Heap growth between the snapshots of a heap profile
+	HeapGrowth *HeapGrowthReport `json:"heapGrowth,omitempty"`
+	// Block and mutex contention profiles captured alongside the profile
+	Contention []ContentionReport `json:"contention,omitempty"`
+	// Per remote endpoint network statistics of a net profile
+	Net *NetReport `json:"net,omitempty"`
+	// Samples and sampling time of a profile stopped at a sample count
+	SampleTarget *SampleTargetReport `json:"sampleTarget,omitempty"`
+	// Dropped samples, lost and truncated stacks and unsymbolized frames
+	SampleStats *SampleStats `json:"sampleStats,omitempty"`
+	// Why a CPU profile captured too few samples to read
+	Diagnosis *SampleDiagnosis `json:"diagnosis,omitempty"`
+	// Tool that sampled the target, e.g. golang-profiling or perf
+	Backend string `json:"backend,omitempty"`
+	// How sampling differs from eBPF when the backend is a fallback
+	Methodology string `json:"methodology,omitempty"`
+	// Language sampled, and what revealed it when the job detected it
+	Language  string `json:"language,omitempty"`
+	Detection string `json:"detection,omitempty"`
+}
+
+// MultiProfileResult 多容器或多节点分析结果
+type MultiProfileResult struct {
+	Results    []*ProfileResult  `json:"results"`              // Successful runs, in container or node order
+	Failures   map[string]string `json:"failures,omitempty"`   // Container or node name to error
+	MergedPath string            `json:"mergedPath,omitempty"` // Merged graph, if requested
+}
+
+// BatchOptions 批量分析中一个目标的分析选项
+type BatchOptions struct {
+	ProfileType  string `json:"profileType,omitempty"` // cpu, schedlat, heap or net
+	Mode         string `json:"mode,omitempty"`        // auto, job, ephemeral or pprof-endpoint
+	PprofPort    string `json:"pprofPort,omitempty"`
+	Frequency    int    `json:"frequency,omitempty"`
+	OffCPU       bool   `json:"offCPU,omitempty"`
+	OutputFormat string `json:"outputFormat,omitempty"` // svg, png, pdf, html or json
+}
+
+// BatchTarget 批量分析清单中的一个目标，未设置的字段取自 defaults
+type BatchTarget struct {
+	Name      string       `json:"name,omitempty"` // Label in the summary and artifact names
+	Namespace string       `json:"namespace,omitempty"`
+	Pod       string       `json:"pod,omitempty"`
+	Selector  string       `json:"selector,omitempty"` // Label selector, every running pod matching is profiled
+	Container string       `json:"container,omitempty"`
+	Duration  string       `json:"duration,omitempty"` // e.g. 30s
+	Options   BatchOptions `json:"options,omitempty"`
+}
+
+// BatchSpec 批量分析清单（kubectl pprof batch -f）
+type BatchSpec struct {
+	Defaults BatchTarget   `json:"defaults,omitempty"`
+	Targets  []BatchTarget `json:"targets"`
+}
+
+// WatchSpec 触发式分析的监视清单（kubectl pprof watch -f），每个条目监视一个命名空间中的 Pod
+type WatchSpec struct {
+	Cooldown string        `json:"cooldown,omitempty"` // Time a container is left alone after its profile, e.g. 15m
+	Watches  []WatchTarget `json:"watches"`
+}
+
+// WatchTarget 一个命名空间中被监视的 Pod 及其触发规则
+type WatchTarget struct {
+	Namespace string      `json:"namespace"`
+	Selector  string      `json:"selector,omitempty"`  // Label selector of the watched pods, every pod when empty
+	Container string      `json:"container,omitempty"` // Watched container, every container when empty
+	Rules     []WatchRule `json:"rules"`
+}
+
+// WatchRule 一条触发规则，cpuPercent、oom 与 restarts 三选一
+type WatchRule struct {
+	Name string `json:"name,omitempty"`
+	// CPU usage in percent of the container's limit, or of a core without one
+	CPUPercent float64 `json:"cpuPercent,omitempty"`
+	For        string  `json:"for,omitempty"` // How long the usage must stay above cpuPercent, e.g. 30s
+	// The container was OOM killed
+	OOM bool `json:"oom,omitempty"`
+	// The container restarted this many times within window, e.g. 3 within 10m
+	Restarts int    `json:"restarts,omitempty"`
+	Window   string `json:"window,omitempty"`
+}
+
+// BatchResult 批量分析中一个 Pod 的分析结果
+type BatchResult struct {
+	Target    string         `json:"target"` // Name of the target, with the pod for selectors
+	Namespace string         `json:"namespace"`
+	PodName   string         `json:"podName,omitempty"`
+	Result    *ProfileResult `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Elapsed   time.Duration  `json:"elapsed"`
+}
+
+// SessionMetadata 分析会话元数据，嵌入到输出文件中
+type SessionMetadata struct {
+	Namespace     string        `json:"namespace"`
+	PodName       string        `json:"podName"`
+	ContainerName string        `json:"containerName"`
+	NodeName      string        `json:"nodeName"`
+	KernelVersion string        `json:"kernelVersion,omitempty"`
+	Frequency     int           `json:"frequency"`
+	Duration      time.Duration `json:"duration"`
+	ToolVersion   string        `json:"toolVersion"`
+	StartTime     time.Time     `json:"startTime"`
+	// Node process profiled instead of a pod
+	HostProcess string `json:"hostProcess,omitempty"`
+	// Go runtime behavior over the profiling window, when a pprof endpoint is available
+	Runtime *RuntimeMetricsReport `json:"runtime,omitempty"`
+	// CPU throttling of the target container over the profiling window
+	Throttling *ThrottlingReport `json:"throttling,omitempty"`
+	// CPU and memory usage of the target container over the profiling window, against its requests and limits
+	ResourceUsage *ResourceUsageReport `json:"resourceUsage,omitempty"`
+	// Tool that sampled the target and, for a fallback, how it differs from eBPF
+	Backend     string `json:"backend,omitempty"`
+	Methodology string `json:"methodology,omitempty"`
+	// Go build information of the target, read from its executable
+	BuildInfo *BuildInfo `json:"buildInfo,omitempty"`
+}
+
+// BuildInfo 目标程序嵌入的 Go 构建信息，Go 1.18 起由 go build 写入
+type BuildInfo struct {
+	GoVersion string `json:"goVersion,omitempty"`
+	Path      string `json:"path,omitempty"`     // Import path of the main package
+	Module    string `json:"module,omitempty"`   // Path of the main module
+	Revision  string `json:"revision,omitempty"` // VCS revision the binary was built from
+	Modified  bool   `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+	Time      string `json:"time,omitempty"`     // Commit time of the revision, RFC 3339
+	// Build settings other than VCS stamping, e.g. -trimpath, -ldflags, CGO_ENABLED and GOARCH
+	Flags []BuildSetting `json:"flags,omitempty"`
+}
+
+// BuildSetting 一项构建设置，即 go version -m 输出中的 build 行
+type BuildSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// RuntimeSnapshot Go 运行时指标快照，来自 pprof 接口
+type RuntimeSnapshot struct {
+	Time          time.Time `json:"time"`
+	HeapAlloc     uint64    `json:"heapAlloc"`
+	HeapSys       uint64    `json:"heapSys"`
+	HeapObjects   uint64    `json:"heapObjects"`
+	NextGC        uint64    `json:"nextGC"`
+	NumGC         uint32    `json:"numGC"`
+	NumForcedGC   uint32    `json:"numForcedGC"`
+	GCCPUFraction float64   `json:"gcCPUFraction"`
+	Goroutines    int       `json:"goroutines"`
+	GOMAXPROCS    int       `json:"gomaxprocs,omitempty"` // Only known when the Prometheus Go collector is served too
+
+	// Raw cumulative data used to compute window deltas
+	PauseNs             []uint64        `json:"-"` // Circular buffer of recent GC pauses, see runtime.MemStats
+	SchedLatencyBuckets []LatencyBucket `json:"-"` // go_sched_latencies_seconds histogram
+}
+
+// LatencyBucket 累积直方图的一个桶
+type LatencyBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// RuntimeMetricsReport 分析窗口内的 GC 与调度器表现
+type RuntimeMetricsReport struct {
+	Start           *RuntimeSnapshot `json:"start"`
+	End             *RuntimeSnapshot `json:"end"`
+	GCCycles        uint32           `json:"gcCycles"`                  // GC cycles completed in the window
+	GCPauseTotal    time.Duration    `json:"gcPauseTotal"`              // Stop-the-world time of those cycles
+	GCPauseMax      time.Duration    `json:"gcPauseMax"`                // Longest single pause
+	HeapDelta       int64            `json:"heapDelta"`                 // HeapAlloc change over the window
+	SchedLatencyP99 time.Duration    `json:"schedLatencyP99,omitempty"` // Goroutine scheduling latency p99 in the window
+}
+
+// PreflightReport 内核特性预检结果
+type PreflightReport struct {
+	KernelVersion     string   `json:"kernelVersion"`
+	BTF               bool     `json:"btf"`                // /sys/kernel/btf/vmlinux present
+	PerfEventParanoid int      `json:"perfEventParanoid"`  // kernel.perf_event_paranoid, -1 if unknown
+	MemlockLimit      string   `json:"memlockLimit"`       // ulimit -l inside the profiler container
+	CgroupVersion     string   `json:"cgroupVersion"`      // v1 or v2
+	Failures          []string `json:"failures,omitempty"` // Checks that block profiling
+	Warnings          []string `json:"warnings,omitempty"` // Checks that may degrade profiling
+}
+
+// OverheadReport 采样开销估算与实测结果
+type OverheadReport struct {
+	Frequency           int           `json:"frequency"`                    // Sampling frequency in Hz
+	ExpectedThreads     int           `json:"expectedThreads"`              // Threads expected to run on CPU concurrently
+	NodeCPUs            int           `json:"nodeCpus"`                     // CPU capacity of the node
+	ExpectedSamples     int64         `json:"expectedSamples"`              // frequency × duration × threads
+	EstimatedCPU        time.Duration `json:"estimatedCpu"`                 // Estimated CPU time spent sampling
+	EstimatedCPUPercent float64       `json:"estimatedCpuPercent"`          // Estimated share of the node CPU capacity
+	Warning             string        `json:"warning,omitempty"`            // Set when the estimate exceeds the warning threshold
+	ProfilerCPU         time.Duration `json:"profilerCpu,omitempty"`        // Measured CPU time of the golang-profiling process
+	ProfilerCPUPercent  float64       `json:"profilerCpuPercent,omitempty"` // Measured CPU time as a share of one core over the duration
+	// Peak memory of the profiler pod's cgroup
+	ProfilerMemory uint64 `json:"profilerMemoryBytes,omitempty"`
+	// CFS throttling of the profiler pod, which falls behind reading samples when throttled
+	ProfilerThrottling *ThrottlingReport `json:"profilerThrottling,omitempty"`
+}
+
+// ThrottlingReport 分析期间目标容器的 CFS 限流情况，来自 cgroup cpu.stat
+type ThrottlingReport struct {
+	Periods          uint64        `json:"periods"`          // CFS periods with runnable threads, 0 without a CPU limit
+	ThrottledPeriods uint64        `json:"throttledPeriods"` // Periods that exhausted the quota
+	ThrottledTime    time.Duration `json:"throttledTime"`    // Total time threads were held back
+	ThrottledPercent float64       `json:"throttledPercent"`
+	Warning          string        `json:"warning,omitempty"` // Set when throttling exceeds the warning threshold
+}
+
+// ResourceUsageReport 分析期间目标容器的 CPU 与内存用量及其 requests 与 limits，
+// 来自 metrics-server 或 kubelet 的 summary API
+type ResourceUsageReport struct {
+	Source  string `json:"source"`  // metrics-server or kubelet
+	Samples int    `json:"samples"` // Usage readings taken over the window
+	// CPU usage in millicores and working set memory in bytes
+	CPUAvgMillis   int64 `json:"cpuAvgMillis"`
+	CPUMaxMillis   int64 `json:"cpuMaxMillis"`
+	MemoryAvgBytes int64 `json:"memoryAvgBytes"`
+	MemoryMaxBytes int64 `json:"memoryMaxBytes"`
+	// Resources of the container, 0 when not set
+	CPURequestMillis   int64  `json:"cpuRequestMillis,omitempty"`
+	CPULimitMillis     int64  `json:"cpuLimitMillis,omitempty"`
+	MemoryRequestBytes int64  `json:"memoryRequestBytes,omitempty"`
+	MemoryLimitBytes   int64  `json:"memoryLimitBytes,omitempty"`
+	Warning            string `json:"warning,omitempty"` // Set when the usage came close to a limit
+}
+
+// SampleStats 采样健康度：丢弃的样本、丢失或截断的堆栈与未符号化的帧
+type SampleStats struct {
+	Samples      int64 `json:"samples"`      // On-CPU samples captured
+	Dropped      int64 `json:"dropped"`      // Samples lost because the counts map was full
+	LostStacks   int64 `json:"lostStacks"`   // User stacks the stack map could not store, shown without their frames
+	Truncated    int64 `json:"truncated"`    // Samples whose stack was cut at the maximum depth
+	Frames       int64 `json:"frames"`       // Distinct frames in the graph
+	Unsymbolized int64 `json:"unsymbolized"` // Frames left as raw addresses
+}
+
+// SampleDiagnosis 样本过少的 CPU 分析的可能原因
+type SampleDiagnosis struct {
+	Samples  int64    `json:"samples"`  // On-CPU samples captured
+	Expected int64    `json:"expected"` // Samples one fully busy thread yields over the window
+	Causes   []string `json:"causes"`   // Likely causes, each with what to try
+}
+
+// SampleTargetReport 按样本数结束的分析实际采集的样本与时长
+type SampleTargetReport struct {
+	MinSamples int64         `json:"minSamples"` // Requested on-CPU samples
+	Samples    int64         `json:"samples"`    // On-CPU samples captured when sampling stopped
+	Elapsed    time.Duration `json:"elapsed"`    // Time spent sampling
+	Reached    bool          `json:"reached"`    // Whether the target was reached before the maximum duration
+}
+
+// GoroutineDump 协程堆栈快照
+type GoroutineDump struct {
+	CapturedAt time.Time       `json:"capturedAt"`
+	Total      int             `json:"total"`
+	States     map[string]int  `json:"states"` // Goroutine count by state, e.g. "chan receive"
+	Goroutines []GoroutineInfo `json:"goroutines"`
+}
+
+// GoroutineInfo 单个协程的状态与堆栈
+type GoroutineInfo struct {
+	ID             int64            `json:"id"`
+	State          string           `json:"state"`
+	WaitMinutes    int              `json:"waitMinutes,omitempty"` // How long the goroutine has been blocked
+	LockedToThread bool             `json:"lockedToThread,omitempty"`
+	Frames         []GoroutineFrame `json:"frames"` // Innermost call first
+	CreatedBy      string           `json:"createdBy,omitempty"`
+}
+
+// GoroutineFrame 协程堆栈中的一帧
+type GoroutineFrame struct {
+	Function string `json:"function"`
+	Location string `json:"location,omitempty"` // file:line
+}
+
+// GoroutineReport 协程快照摘要及本地文件路径
+type GoroutineReport struct {
+	Total    int            `json:"total"`
+	States   map[string]int `json:"states"`
+	TextPath string         `json:"textPath"` // Raw debug=2 dump
+	JSONPath string         `json:"jsonPath"` // Parsed GoroutineDump
+}
+
+// SchedLatencyBucket 调度延迟直方图的一个区间 [Low, High)
+type SchedLatencyBucket struct {
+	Low   time.Duration `json:"low"`
+	High  time.Duration `json:"high"`
+	Count uint64        `json:"count"`
+}
+
+// SchedLatencyReport 目标线程在运行队列中等待 CPU 的延迟分布
+type SchedLatencyReport struct {
+	Count   uint64               `json:"count"` // Number of waits measured
+	P50     time.Duration        `json:"p50"`   // Upper bound of the bucket holding the median
+	P99     time.Duration        `json:"p99"`
+	Max     time.Duration        `json:"max"` // Upper bound of the highest non-empty bucket
+	Buckets []SchedLatencyBucket `json:"buckets"`
+	Path    string               `json:"path,omitempty"` // Local copy of the histogram
+}
+
+// CPUSamples 一个 CPU 上采到的目标进程样本
+type CPUSamples struct {
+	CPU      int     `json:"cpu"`
+	Samples  int64   `json:"samples"`
+	Busy     float64 `json:"busy"`               // Share of the window the target ran on this CPU, from the sampling frequency
+	TopFrame string  `json:"topFrame,omitempty"` // Leaf frame with the most samples on this CPU
+	TopShare float64 `json:"topShare,omitempty"` // Share of this CPU's samples in TopFrame
+}
+
+// CPUBreakdownReport 按 CPU 划分的 on-CPU 样本，按样本数从高到低排列
+type CPUBreakdownReport struct {
+	CPUs []CPUSamples `json:"cpus"`
+	// Set when one CPU carried the target while the others idled
+	Pegged string `json:"pegged,omitempty"`
+}
+
+// NetEndpointStats 目标进程与一个远端地址之间的网络调用统计
+type NetEndpointStats struct {
+	Remote        string        `json:"remote"`      // host:port
+	Connections   uint64        `json:"connections"` // Connects and accepts
+	Calls         uint64        `json:"calls"`       // Completed sends and receives
+	BytesSent     uint64        `json:"bytesSent"`
+	BytesReceived uint64        `json:"bytesReceived"`
+	TotalLatency  time.Duration `json:"totalLatency"`
+	MaxLatency    time.Duration `json:"maxLatency"`
+}
+
+// NetReport 网络 I/O 分析结果，按总延迟从高到低排列
+type NetReport struct {
+	Endpoints []NetEndpointStats `json:"endpoints"`
+	Path      string             `json:"path,omitempty"` // Local copy of the statistics
+}
+
+// HeapSnapshot 一次堆快照
+type HeapSnapshot struct {
+	Time         time.Time `json:"time"`
+	InuseBytes   int64     `json:"inuseBytes"`
+	InuseObjects int64     `json:"inuseObjects"`
+	Path         string    `json:"path"` // Raw heap profile
+}
+
+// HeapGrowthSite 两次快照之间增长的分配点
+type HeapGrowthSite struct {
+	Function     string `json:"function"` // Leaf frame of the allocating stacks
+	BytesDelta   int64  `json:"bytesDelta"`
+	ObjectsDelta int64  `json:"objectsDelta"`
+}
+
+// HeapGrowthReport 多次堆快照的增长报告，用于发现内存泄漏
+type HeapGrowthReport struct {
+	Snapshots   []HeapSnapshot   `json:"snapshots"`
+	GrowthBytes int64            `json:"growthBytes"` // In-use bytes of the last snapshot minus the first
+	TopSites    []HeapGrowthSite `json:"topSites"`    // Largest growth first
+	Path        string           `json:"path,omitempty"`
+}
+
+// Finding 分析规则在堆栈中识别出的一种常见性能问题
+type Finding struct {
+	Rule  string  `json:"rule"`
+	Title string  `json:"title"`
+	Share float64 `json:"share"` // Percentage of samples with a matching frame
+	// Samples with a matching frame
+	Samples int64 `json:"samples"`
+	// Matching functions with the most samples
+	Frames []string `json:"frames,omitempty"`
+	// Regex of Frames for the search box of the HTML report
+	Search         string `json:"search,omitempty"`
+	Recommendation string `json:"recommendation"`
+}
+
+// TraceReport 采样窗口内活跃的 trace，来自样本的 pprof 标签
+type TraceReport struct {
+	Label string `json:"label"` // pprof label the trace IDs were read from
+	// Traces with the most samples first, at most MaxTraces
+	Traces []TraceSamples `json:"traces"`
+	// Distinct trace IDs, more than listed when truncated
+	Distinct int `json:"distinct"`
+	// Samples taken while a trace was active, and in total
+	Traced  int64 `json:"traced"`
+	Samples int64 `json:"samples"`
+}
+
+// MaxTraces bounds the trace IDs listed in a TraceReport
+const MaxTraces = 100
+
+// TraceSamples 一个 trace 的样本数
+type TraceSamples struct {
+	TraceID string `json:"traceId"`
+	Samples int64  `json:"samples"`
+}
+
+// ContentionReport 阻塞或互斥锁竞争分析结果
+type ContentionReport struct {
+	Profile     string        `json:"profile"` // block or mutex
+	Contentions int64         `json:"contentions"`
+	Delay       time.Duration `json:"delay"` // Total time goroutines spent waiting
+	OutputPath  string        `json:"outputPath"`
+	PprofPath   string        `json:"pprofPath"`
+}
+
+// ProfileMode how the profiler reaches the target process
+type ProfileMode string
+
+const (
+	ModeAuto      ProfileMode = "auto"           // Pick from the cluster capabilities
+	ModeJob       ProfileMode = "job"            // Privileged hostPID Job on the target node
+	ModeEphemeral ProfileMode = "ephemeral"      // Ephemeral container sharing the target's PID namespace
+	ModePprof     ProfileMode = "pprof-endpoint" // net/http/pprof handler of the target, through a port-forward
+	ModeInspector ProfileMode = "inspector"      // V8 inspector of a Node.js target, through a port-forward
+)
+
+// Profile types of the eBPF profiler
+const (
+	ProfileTypeCPU      = "cpu"      // On-CPU stack sampling
+	ProfileTypeSchedLat = "schedlat" // Time the target's threads wait runnable for a CPU
+	ProfileTypeHeap     = "heap"     // Heap growth between snapshots from the pprof endpoint
+	ProfileTypeNet      = "net"      // Latency and bytes of the target's network calls
+)
+
+// DefaultFrequency is the golang-profiling default sampling frequency in Hz
+const DefaultFrequency = 99
+
+// ContainerRuntime represents container runtime types
+type ContainerRuntime string
+
+const (
+	RuntimeContainerd ContainerRuntime = "containerd"
+	RuntimeDocker     ContainerRuntime = "docker"
+	RuntimeCRIO       ContainerRuntime = "cri-o"
+)
+
+// Language represents supported programming languages for profiling
+type Language string
+
+const (
+	LanguageGo     Language = "go"
+	LanguageJava   Language = "java"
+	LanguagePython Language = "python"
+	LanguageNode   Language = "node"
+	LanguageRust   Language = "rust"
+	// LanguageAuto leaves the language to the profiler job, which detects it
+	// from the executable and libraries of the target process
+	LanguageAuto Language = "auto"
+)
+
+// LanguageConfig contains language-specific profiling configuration
+type LanguageConfig struct {
+	Language           Language          `json:"language"`
+	SupportedTypes     []string          `json:"supportedTypes"`
+	DefaultType        string            `json:"defaultType"`
+	DefaultImage       string            `json:"defaultImage"`
+	ProfilerCommand    []string          `json:"profilerCommand"`
+	OutputFormats      []string          `json:"outputFormats"`
+	RequiredCapabilities []string        `json:"requiredCapabilities,omitempty"`
+	EnvironmentVars    map[string]string `json:"environmentVars,omitempty"`
+}
+
+// RuntimeInfo 运行时信息
+type RuntimeInfo struct {
+	Type            ContainerRuntime `json:"type"`
+	Version         string           `json:"version"`
+	SocketPath      string           `json:"socketPath"`
+	SupportsSharing bool             `json:"supportsSharing"`
+	Runtime         ContainerRuntime `json:"runtime"`
+	ContainerID     string           `json:"containerID"`
+	ImageID         string           `json:"imageID"`
+	PID             int              `json:"pid"`
+}
+
+// NodeInfo 节点信息
+type NodeInfo struct {
+	Name            string                `json:"name"`
+	Labels          map[string]string     `json:"labels"`
+	Annotations     map[string]string     `json:"annotations"`
+	Conditions      []NodeCondition       `json:"conditions"`
+	Capacity        map[string]string     `json:"capacity"`
+	Allocatable     map[string]string     `json:"allocatable"`
+	RuntimeInfo     *RuntimeInfo          `json:"runtimeInfo,omitempty"`
+	KubeletVersion  string                `json:"kubeletVersion"`
+	OperatingSystem string                `json:"operatingSystem"`
+	Architecture    string                `json:"architecture"`
+	KernelVersion   string                `json:"kernelVersion"`
+	OSImage         string                `json:"osImage"`
+}
+
+// ProfileOptions 分析选项
+type ProfileOptions struct {
+	// 基础选项
+	CPUProfile     bool `json:"cpuProfile"`
+	MemoryProfile  bool `json:"memoryProfile"`
+	GoroutineProfile bool `json:"goroutineProfile"`
+	BlockProfile   bool `json:"blockProfile"`
+	MutexProfile   bool `json:"mutexProfile"`
+
+	// 输出选项
+	FlameGraph     bool   `json:"flameGraph"`
+	RawData        bool   `json:"rawData"`
+	JSONReport     bool   `json:"jsonReport"`
+	OutputFormat   string `json:"outputFormat"` // svg, png, pdf, json
+	DPI            int    `json:"dpi,omitempty"` // png/pdf resolution
+
+	// 高级选项
+	SampleRate     int    `json:"sampleRate,omitempty"`
+	StackDepth     int    `json:"stackDepth,omitempty"`
+	FilterPattern  string `json:"filterPattern,omitempty"`
+	IgnorePattern  string `json:"ignorePattern,omitempty"`
+
+	// UI选项
+	Quiet          bool   `json:"quiet"`
+	PrintLogs      bool   `json:"printLogs"`
+	// Save a placeholder graph instead of failing when the flame graph cannot be extracted
+	AllowPartial bool `json:"allowPartial,omitempty"`
+
+	// Directory the API traffic and session are recorded into for replay
+	RecordSession string `json:"recordSession,omitempty"`
+	// Archive the artifacts of the session into the session store
+	SaveSession bool `json:"saveSession,omitempty"`
+	// Session store directory, the default store when empty
+	SessionsDir string `json:"sessionsDir,omitempty"`
+	// How long the kernel capabilities probed on a node are reused by later
+	// runs against it, 0 probes them every run
+	NodeCacheTTL time.Duration `json:"nodeCacheTTL,omitempty"`
+
+	// Progress and diagnostics, slog.Default() when unset
+	Logger *slog.Logger `json:"-"`
+	// Typed progress events for programs embedding the profiler
+	Progress ProgressFunc `json:"-"`
+	// Receives the folded stacks sampled so far by a Live session, at every snapshot
+	Snapshot func(folded []byte) `json:"-"`
+	// Languages and backends of the session, with the user's custom ones;
+	// those of the job manager when nil
+	Languages *LanguageManager `json:"-"`
+}
+
+// Emit sends a progress event to Progress, stamping its time
+func (o *ProfileOptions) Emit(event ProgressEvent) {
+	if o == nil || o.Progress == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	o.Progress(event)
+}
+
+// Log returns the logger of the session, which drops everything with Quiet
+func (o *ProfileOptions) Log() *slog.Logger {
+	switch {
+	case o != nil && o.Quiet:
+		return slog.New(slog.DiscardHandler)
+	case o != nil && o.Logger != nil:
+		return o.Logger
+	default:
+		return slog.Default()
+	}
+}
+
+// ErrorCode 错误代码
+type ErrorCode string
+
+const (
+	ErrCodePodNotFound        ErrorCode = "POD_NOT_FOUND"
+	ErrCodeContainerNotFound  ErrorCode = "CONTAINER_NOT_FOUND"
+	ErrCodePodNotRunning      ErrorCode = "POD_NOT_RUNNING"
+	ErrCodeInsufficientPerms  ErrorCode = "INSUFFICIENT_PERMISSIONS"
+	ErrCodeJobCreationFailed  ErrorCode = "JOB_CREATION_FAILED"
+	ErrCodeJobTimeout         ErrorCode = "JOB_TIMEOUT"
+	ErrCodeJobFailed          ErrorCode = "JOB_FAILED"
+	ErrCodeResultNotFound     ErrorCode = "RESULT_NOT_FOUND"
+	ErrCodeInvalidConfig      ErrorCode = "INVALID_CONFIG"
+	ErrCodeRuntimeError       ErrorCode = "RUNTIME_ERROR"
+)
+
+// ProfileError 分析错误
+type ProfileError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Details string    `json:"details,omitempty"`
+	Cause   error     `json:"-"`
+}
+
+func (e *ProfileError) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Code, e.Message, e.Details)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *ProfileError) Unwrap() error {
+	return e.Cause
+}
+
+// NewProfileError 创建分析错误
+func NewProfileError(code ErrorCode, message string, cause error) *ProfileError {
+	return &ProfileError{
+		Code:    code,
+		Message: message,
+		Cause:   cause,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,184 @@
+// Package audit leaves a trail of profiling sessions in the cluster: an Event
+// on the profiled pod and, optionally, an entry in a shared ConfigMap, so
+// security teams can see who profiled what.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// Component is the source of the Events recorded on profiled pods
+const Component = "kubectl-pprof"
+
+// EventReason is the reason of the Events recorded on profiled pods
+const EventReason = "Profiling"
+
+// ConfigMapKey holds the audit entries of a ConfigMap, one JSON object per line
+const ConfigMapKey = "audit.jsonl"
+
+// MaxConfigMapEntries bounds the entries kept in the ConfigMap, the oldest are
+// dropped first so it stays far below the 1MiB object size limit
+const MaxConfigMapEntries = 1000
+
+// UnknownUser is reported when the API server does not tell who we are
+const UnknownUser = "unknown"
+
+// Entry is a profiling session as recorded in the audit trail
+type Entry struct {
+	Time        time.Time     `json:"time"`
+	User        string        `json:"user"`
+	Namespace   string        `json:"namespace"`
+	PodName     string        `json:"podName"`
+	Container   string        `json:"container,omitempty"`
+	NodeName    string        `json:"nodeName,omitempty"`
+	Mode        string        `json:"mode"`
+	ProfileType string        `json:"profileType"`
+	Duration    time.Duration `json:"duration"`
+	Frequency   int           `json:"frequency,omitempty"`
+}
+
+// Message renders the entry as the message of an Event
+func (e *Entry) Message() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Profiled by user %s for %s", e.User, e.Duration)
+	if e.Frequency > 0 {
+		fmt.Fprintf(&b, " at freq %dHz", e.Frequency)
+	}
+	fmt.Fprintf(&b, " (%s, mode %s", e.ProfileType, e.Mode)
+	if e.Container != "" {
+		fmt.Fprintf(&b, ", container %s", e.Container)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// Auditor records profiling sessions through a Kubernetes client
+type Auditor struct {
+	client kubernetes.Interface
+
+	userOnce sync.Once
+	user     string
+}
+
+// NewAuditor returns an Auditor recording through client
+func NewAuditor(client kubernetes.Interface) *Auditor {
+	return &Auditor{client: client}
+}
+
+// User returns the user name the client authenticates as, asked once with a
+// SelfSubjectReview (Kubernetes >= 1.28), or UnknownUser
+func (a *Auditor) User(ctx context.Context) string {
+	a.userOnce.Do(func() {
+		a.user = UnknownUser
+		review, err := a.client.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+		if err == nil && review.Status.UserInfo.Username != "" {
+			a.user = review.Status.UserInfo.Username
+		}
+	})
+	return a.user
+}
+
+// RecordEvent records the entry as a Normal Event on the profiled pod
+func (a *Auditor) RecordEvent(ctx context.Context, pod *corev1.Pod, entry *Entry) error {
+	now := metav1.NewTime(entry.Time)
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", pod.Name, entry.Time.UnixNano()),
+			Namespace: pod.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:            "Pod",
+			APIVersion:      "v1",
+			Namespace:       pod.Namespace,
+			Name:            pod.Name,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+		},
+		Reason:              EventReason,
+		Message:             entry.Message(),
+		Type:                corev1.EventTypeNormal,
+		Source:              corev1.EventSource{Component: Component},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: Component,
+	}
+	if _, err := a.client.CoreV1().Events(pod.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to record event on pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	return nil
+}
+
+// AppendToConfigMap appends the entry to a ConfigMap given as namespace/name,
+// creating it when missing
+func (a *Auditor) AppendToConfigMap(ctx context.Context, ref string, entry *Entry) error {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return fmt.Errorf("audit ConfigMap %q must be given as namespace/name", ref)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	configMaps := a.client.CoreV1().ConfigMaps(namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Labels:    map[string]string{"app.kubernetes.io/managed-by": Component},
+				},
+				Data: map[string]string{ConfigMapKey: string(line) + "\n"},
+			}
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently, append on the next attempt
+				return apierrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[ConfigMapKey] = appendEntry(cm.Data[ConfigMapKey], string(line))
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append to audit ConfigMap %s: %w", ref, err)
+	}
+	return nil
+}
+
+// appendEntry appends a line to the entries, dropping the oldest beyond
+// MaxConfigMapEntries
+func appendEntry(entries, line string) string {
+	lines := strings.Split(strings.TrimRight(entries, "\n"), "\n")
+	if lines[0] == "" {
+		lines = lines[:0]
+	}
+	lines = append(lines, line)
+	if len(lines) > MaxConfigMapEntries {
+		lines = lines[len(lines)-MaxConfigMapEntries:]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,176 @@
+// Package compare computes the per-function change between two profiles and
+// renders it as a regression report, as text for terminals or as markdown
+// for pull requests.
+//
+// Functions are compared by their share of all samples rather than by sample
+// counts, so profiles of different durations or frequencies compare fairly.
+// Self is the share with the function as the leaf frame, total the share with
+// the function anywhere on the stack.
+package compare
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// Delta is the change of a function between the base and the target profile,
+// as percentages of all samples
+type Delta struct {
+	Name      string
+	BaseSelf  float64
+	BaseTotal float64
+	Self      float64
+	Total     float64
+	New       bool // Absent from the base profile
+	Gone      bool // Absent from the target profile
+}
+
+// SelfDelta returns the change of the self share in percentage points
+func (d Delta) SelfDelta() float64 { return d.Self - d.BaseSelf }
+
+// TotalDelta returns the change of the total share in percentage points
+func (d Delta) TotalDelta() float64 { return d.Total - d.BaseTotal }
+
+// magnitude is the larger absolute change, reports are ordered by it
+func (d Delta) magnitude() float64 {
+	return math.Max(math.Abs(d.SelfDelta()), math.Abs(d.TotalDelta()))
+}
+
+// Report is the comparison of a target profile against a base profile
+type Report struct {
+	Base          string
+	Target        string
+	BaseSamples   int64
+	TargetSamples int64
+	// Percentage points a self or total share must change by to be reported
+	Threshold float64
+	// Functions whose share grew, resp. shrank, by at least the threshold,
+	// largest change first
+	Regressions  []Delta
+	Improvements []Delta
+}
+
+// Profiles compares target against base, the labels name the profiles
+// in the report.
+func Profiles(base, target *flamegraph.Profile, baseLabel, targetLabel string, threshold float64) *Report {
+	report := &Report{
+		Base:          baseLabel,
+		Target:        targetLabel,
+		BaseSamples:   base.Total(),
+		TargetSamples: target.Total(),
+		Threshold:     threshold,
+	}
+	baseStats, targetStats := flamegraph.Functions(base), flamegraph.Functions(target)
+
+	names := make(map[string]bool, len(baseStats)+len(targetStats))
+	for name := range baseStats {
+		names[name] = true
+	}
+	for name := range targetStats {
+		names[name] = true
+	}
+
+	for name := range names {
+		d := Delta{Name: name}
+		if s, ok := baseStats[name]; ok {
+			d.BaseSelf, d.BaseTotal = stacks.Share(s.Self, report.BaseSamples), stacks.Share(s.Total, report.BaseSamples)
+		} else {
+			d.New = true
+		}
+		if s, ok := targetStats[name]; ok {
+			d.Self, d.Total = stacks.Share(s.Self, report.TargetSamples), stacks.Share(s.Total, report.TargetSamples)
+		} else {
+			d.Gone = true
+		}
+
+		switch {
+		case d.SelfDelta() >= threshold || d.TotalDelta() >= threshold:
+			report.Regressions = append(report.Regressions, d)
+		case -d.SelfDelta() >= threshold || -d.TotalDelta() >= threshold:
+			report.Improvements = append(report.Improvements, d)
+		}
+	}
+
+	for _, deltas := range [][]Delta{report.Regressions, report.Improvements} {
+		sort.Slice(deltas, func(i, j int) bool {
+			if deltas[i].magnitude() != deltas[j].magnitude() {
+				return deltas[i].magnitude() > deltas[j].magnitude()
+			}
+			return deltas[i].Name < deltas[j].Name
+		})
+	}
+	return report
+}
+
+// ParseThreshold parses a threshold in percentage points, e.g. "5%" or "2.5"
+func ParseThreshold(value string) (float64, error) {
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return 0, fmt.Errorf("invalid threshold %q, must be a percentage between 0%% and 100%%", value)
+	}
+	return threshold, nil
+}
+
+// WriteText writes the report as aligned text
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Base:   %s (%d samples)\nTarget: %s (%d samples)\nThreshold: %g%% of samples\n", r.Base, r.BaseSamples, r.Target, r.TargetSamples, r.Threshold)
+	for _, section := range []struct {
+		title  string
+		deltas []Delta
+	}{{"Regressions", r.Regressions}, {"Improvements", r.Improvements}} {
+		fmt.Fprintf(&b, "\n%s (%d)\n", section.title, len(section.deltas))
+		if len(section.deltas) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%9s %9s %9s %9s  %s\n", "SELF", "ΔSELF", "TOTAL", "ΔTOTAL", "FUNCTION")
+		for _, d := range section.deltas {
+			fmt.Fprintf(&b, "%8.2f%% %+8.2f%% %8.2f%% %+8.2f%%  %s%s\n", d.Self, d.SelfDelta(), d.Total, d.TotalDelta(), d.Name, d.note())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMarkdown writes the report as markdown tables for a pull request
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Profile comparison\n\n")
+	fmt.Fprintf(&b, "| | Profile | Samples |\n|---|---|---:|\n| Base | `%s` | %d |\n| Target | `%s` | %d |\n\n", r.Base, r.BaseSamples, r.Target, r.TargetSamples)
+	fmt.Fprintf(&b, "Functions whose self or total share of samples changed by at least %g%%.\n", r.Threshold)
+	for _, section := range []struct {
+		title  string
+		deltas []Delta
+	}{{"Regressions", r.Regressions}, {"Improvements", r.Improvements}} {
+		fmt.Fprintf(&b, "\n#### %s (%d)\n\n", section.title, len(section.deltas))
+		if len(section.deltas) == 0 {
+			b.WriteString("None.\n")
+			continue
+		}
+		b.WriteString("| Function | Self | Δ Self | Total | Δ Total |\n|---|---:|---:|---:|---:|\n")
+		for _, d := range section.deltas {
+			fmt.Fprintf(&b, "| `%s`%s | %.2f%% | %+.2f%% | %.2f%% | %+.2f%% |\n",
+				strings.ReplaceAll(d.Name, "|", "\\|"), d.note(), d.Self, d.SelfDelta(), d.Total, d.TotalDelta())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// note marks functions present in only one of the profiles
+func (d Delta) note() string {
+	switch {
+	case d.New:
+		return " (new)"
+	case d.Gone:
+		return " (gone)"
+	}
+	return ""
+}
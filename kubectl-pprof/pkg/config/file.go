@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// FileEnv overrides the path of the user configuration file
+const FileEnv = "KUBECTL_PPROF_CONFIG"
+
+// File is the user configuration file, settings shared by every invocation
+// that flags override
+type File struct {
+	// Registry host, optionally with a path, the profiling images are pulled
+	// from instead of their own registry, e.g. mirror.corp:5000/pprof
+	RegistryMirror string `json:"registryMirror,omitempty"`
+	// In-house profilers, selected with --language like the built-in ones
+	Languages []api.CustomLanguage `json:"languages,omitempty"`
+}
+
+// DefaultFilePath returns $KUBECTL_PPROF_CONFIG, else ~/.kubectl-pprof.yaml
+func DefaultFilePath() string {
+	if path := os.Getenv(FileEnv); path != "" {
+		return path
+	}
+	return filepath.Join(homedir.HomeDir(), ".kubectl-pprof.yaml")
+}
+
+// LoadFile reads the user configuration file. A missing file is an empty
+// configuration, unknown fields are errors so typos do not go unnoticed.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	file := &File{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return file, nil
+}
@@ -3,25 +3,25 @@ package config
 import (
 	"fmt"
 
-	"github.com/withlin/kubectl-pprof/internal/types"
+	"github.com/withlin/kubectl-pprof/pkg/api"
 )
 
 // LanguageManager manages language-specific configurations
 type LanguageManager struct {
-	configs map[types.Language]*types.LanguageConfig
+	configs map[api.Language]*api.LanguageConfig
 }
 
 // NewLanguageManager creates a new language manager with default configurations
 func NewLanguageManager() *LanguageManager {
 	lm := &LanguageManager{
-		configs: make(map[types.Language]*types.LanguageConfig),
+		configs: make(map[api.Language]*api.LanguageConfig),
 	}
 	lm.initializeDefaultConfigs()
 	return lm
 }
 
 // GetConfig returns the configuration for a specific language
-func (lm *LanguageManager) GetConfig(lang types.Language) (*types.LanguageConfig, error) {
+func (lm *LanguageManager) GetConfig(lang api.Language) (*api.LanguageConfig, error) {
 	if config, exists := lm.configs[lang]; exists {
 		return config, nil
 	}
@@ -29,14 +29,14 @@ func (lm *LanguageManager) GetConfig(lang types.Language) (*types.LanguageConfig
 }
 
 // IsSupported checks if a language is supported
-func (lm *LanguageManager) IsSupported(lang types.Language) bool {
+func (lm *LanguageManager) IsSupported(lang api.Language) bool {
 	_, exists := lm.configs[lang]
 	return exists
 }
 
 // GetSupportedLanguages returns a list of all supported languages
-func (lm *LanguageManager) GetSupportedLanguages() []types.Language {
-	languages := make([]types.Language, 0, len(lm.configs))
+func (lm *LanguageManager) GetSupportedLanguages() []api.Language {
+	languages := make([]api.Language, 0, len(lm.configs))
 	for lang := range lm.configs {
 		languages = append(languages, lang)
 	}
@@ -44,7 +44,7 @@ func (lm *LanguageManager) GetSupportedLanguages() []types.Language {
 }
 
 // ValidateProfileType checks if a profile type is valid for the given language
-func (lm *LanguageManager) ValidateProfileType(lang types.Language, profileType string) error {
+func (lm *LanguageManager) ValidateProfileType(lang api.Language, profileType string) error {
 	config, err := lm.GetConfig(lang)
 	if err != nil {
 		return err
@@ -63,9 +63,9 @@ func (lm *LanguageManager) ValidateProfileType(lang types.Language, profileType
 // initializeDefaultConfigs sets up default configurations for supported languages
 func (lm *LanguageManager) initializeDefaultConfigs() {
 	// Go language configuration
-	lm.configs[types.LanguageGo] = &types.LanguageConfig{
-		Language:       types.LanguageGo,
-		SupportedTypes: []string{"cpu", "memory", "goroutine", "block", "mutex", "heap", "allocs"},
+	lm.configs[api.LanguageGo] = &api.LanguageConfig{
+		Language:       api.LanguageGo,
+		SupportedTypes: []string{"cpu", "memory", "goroutine", "block", "mutex", "heap", "allocs", "schedlat", "net"},
 		DefaultType:    "cpu",
 		DefaultImage:   "golang-profiling:latest",
 		ProfilerCommand: []string{"/usr/local/bin/golang-profiling"},
@@ -77,8 +77,8 @@ func (lm *LanguageManager) initializeDefaultConfigs() {
 	}
 
 	// Java language configuration
-	lm.configs[types.LanguageJava] = &types.LanguageConfig{
-		Language:       types.LanguageJava,
+	lm.configs[api.LanguageJava] = &api.LanguageConfig{
+		Language:       api.LanguageJava,
 		SupportedTypes: []string{"cpu", "memory", "allocation", "lock", "wall"},
 		DefaultType:    "cpu",
 		DefaultImage:   "java-profiling:latest",
@@ -92,8 +92,8 @@ func (lm *LanguageManager) initializeDefaultConfigs() {
 	}
 
 	// Python language configuration
-	lm.configs[types.LanguagePython] = &types.LanguageConfig{
-		Language:       types.LanguagePython,
+	lm.configs[api.LanguagePython] = &api.LanguageConfig{
+		Language:       api.LanguagePython,
 		SupportedTypes: []string{"cpu", "memory"},
 		DefaultType:    "cpu",
 		DefaultImage:   "python-profiling:latest",
@@ -106,8 +106,8 @@ func (lm *LanguageManager) initializeDefaultConfigs() {
 	}
 
 	// Node.js language configuration
-	lm.configs[types.LanguageNode] = &types.LanguageConfig{
-		Language:       types.LanguageNode,
+	lm.configs[api.LanguageNode] = &api.LanguageConfig{
+		Language:       api.LanguageNode,
 		SupportedTypes: []string{"cpu", "memory", "heap"},
 		DefaultType:    "cpu",
 		DefaultImage:   "node-profiling:latest",
@@ -120,8 +120,8 @@ func (lm *LanguageManager) initializeDefaultConfigs() {
 	}
 
 	// Rust language configuration
-	lm.configs[types.LanguageRust] = &types.LanguageConfig{
-		Language:       types.LanguageRust,
+	lm.configs[api.LanguageRust] = &api.LanguageConfig{
+		Language:       api.LanguageRust,
 		SupportedTypes: []string{"cpu", "memory"},
 		DefaultType:    "cpu",
 		DefaultImage:   "rust-profiling:latest",
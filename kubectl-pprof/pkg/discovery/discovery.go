@@ -3,10 +3,14 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"github.com/withlin/kubectl-pprof/internal/types"
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
 	"github.com/withlin/kubectl-pprof/pkg/config"
 )
 
@@ -26,7 +30,7 @@ func NewDiscovery(k8sConfig *config.KubernetesConfig) (*Discovery, error) {
 func (d *Discovery) FindPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error) {
 	pod, err := d.k8sConfig.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
+		return nil, errors.FromKubernetes(err, fmt.Sprintf("failed to get pod %s/%s", namespace, podName))
 	}
 
 	// Validate Pod status
@@ -37,14 +41,72 @@ func (d *Discovery) FindPod(ctx context.Context, namespace, podName string) (*co
 	return pod, nil
 }
 
+// ListPods returns the running pods of a namespace matching a label selector, by name
+func (d *Discovery) ListPods(ctx context.Context, namespace, selector string) ([]corev1.Pod, error) {
+	list, err := d.k8sConfig.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, errors.FromKubernetes(err, fmt.Sprintf("failed to list pods in %s matching %q", namespace, selector))
+	}
+
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// WorkloadPods returns the running pods of a workload given as kind/name
+// (daemonset, deployment or statefulset, a bare name is a daemonset), by name
+func (d *Discovery) WorkloadPods(ctx context.Context, namespace, ref string) ([]corev1.Pod, error) {
+	kind, name, found := strings.Cut(ref, "/")
+	if !found {
+		kind, name = "daemonset", ref
+	}
+
+	apps := d.k8sConfig.Clientset.AppsV1()
+	var (
+		selector *metav1.LabelSelector
+		err      error
+	)
+	switch strings.ToLower(kind) {
+	case "daemonset", "ds":
+		var ds *appsv1.DaemonSet
+		if ds, err = apps.DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+			selector = ds.Spec.Selector
+		}
+	case "deployment", "deploy":
+		var deploy *appsv1.Deployment
+		if deploy, err = apps.Deployments(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+			selector = deploy.Spec.Selector
+		}
+	case "statefulset", "sts":
+		var sts *appsv1.StatefulSet
+		if sts, err = apps.StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+			selector = sts.Spec.Selector
+		}
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %q, must be one of: daemonset, deployment, statefulset", kind)
+	}
+	if err != nil {
+		return nil, errors.FromKubernetes(err, fmt.Sprintf("failed to get %s %s/%s", kind, namespace, name))
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of %s %s/%s: %w", kind, namespace, name, err)
+	}
+	return d.ListPods(ctx, namespace, labelSelector.String())
+}
+
 // FindContainer finds container
 func (d *Discovery) FindContainer(pod *corev1.Pod, containerName string) (*corev1.Container, error) {
-	// If no container name specified, use the first container
+	// If no container name specified, skip sidecars to find the application container
 	if containerName == "" {
-		if len(pod.Spec.Containers) == 0 {
-			return nil, fmt.Errorf("no containers found in pod %s/%s", pod.Namespace, pod.Name)
-		}
-		return &pod.Spec.Containers[0], nil
+		container, _, err := SelectContainer(pod, false)
+		return container, err
 	}
 
 	// Find the specified container
@@ -58,16 +120,16 @@ func (d *Discovery) FindContainer(pod *corev1.Pod, containerName string) (*corev
 }
 
 // GetNodeInfo 获取节点信息
-func (d *Discovery) GetNodeInfo(ctx context.Context, nodeName string) (*types.NodeInfo, error) {
+func (d *Discovery) GetNodeInfo(ctx context.Context, nodeName string) (*api.NodeInfo, error) {
 	node, err := d.k8sConfig.Clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+		return nil, errors.FromKubernetes(err, fmt.Sprintf("failed to get node %s", nodeName))
 	}
 
 	// 转换节点条件
-	conditions := make([]types.NodeCondition, len(node.Status.Conditions))
+	conditions := make([]api.NodeCondition, len(node.Status.Conditions))
 	for i, cond := range node.Status.Conditions {
-		conditions[i] = types.NodeCondition{
+		conditions[i] = api.NodeCondition{
 			Type:               string(cond.Type),
 			Status:             string(cond.Status),
 			LastTransitionTime: cond.LastTransitionTime.Time,
@@ -87,7 +149,7 @@ func (d *Discovery) GetNodeInfo(ctx context.Context, nodeName string) (*types.No
 		allocatable[string(k)] = v.String()
 	}
 
-	return &types.NodeInfo{
+	return &api.NodeInfo{
 		Name:        node.Name,
 		Labels:      node.Labels,
 		Annotations: node.Annotations,
@@ -97,11 +159,13 @@ func (d *Discovery) GetNodeInfo(ctx context.Context, nodeName string) (*types.No
 		KernelVersion: node.Status.NodeInfo.KernelVersion,
 		OSImage:     node.Status.NodeInfo.OSImage,
 		Architecture: node.Status.NodeInfo.Architecture,
+		OperatingSystem: node.Status.NodeInfo.OperatingSystem,
+		KubeletVersion: node.Status.NodeInfo.KubeletVersion,
 	}, nil
 }
 
 // GetRuntimeInfo 获取运行时信息
-func (d *Discovery) GetRuntimeInfo(ctx context.Context, pod *corev1.Pod, container *corev1.Container) (*types.RuntimeInfo, error) {
+func (d *Discovery) GetRuntimeInfo(ctx context.Context, pod *corev1.Pod, container *corev1.Container) (*api.RuntimeInfo, error) {
 	// 检测容器运行时
 	runtime := d.detectContainerRuntime(pod)
 
@@ -111,7 +175,7 @@ func (d *Discovery) GetRuntimeInfo(ctx context.Context, pod *corev1.Pod, contain
 		return nil, fmt.Errorf("container %s status not found", container.Name)
 	}
 
-	return &types.RuntimeInfo{
+	return &api.RuntimeInfo{
 		Runtime:     runtime,
 		ContainerID: containerStatus.ContainerID,
 		ImageID:     containerStatus.ImageID,
@@ -120,24 +184,24 @@ func (d *Discovery) GetRuntimeInfo(ctx context.Context, pod *corev1.Pod, contain
 }
 
 // detectContainerRuntime 检测容器运行时
-func (d *Discovery) detectContainerRuntime(pod *corev1.Pod) types.ContainerRuntime {
+func (d *Discovery) detectContainerRuntime(pod *corev1.Pod) api.ContainerRuntime {
 	// 从容器状态中检测运行时
 	for _, containerStatus := range pod.Status.ContainerStatuses {
 		if containerStatus.ContainerID != "" {
 			if len(containerStatus.ContainerID) > 11 && containerStatus.ContainerID[:11] == "containerd:" {
-				return types.RuntimeContainerd
+				return api.RuntimeContainerd
 			}
 			if len(containerStatus.ContainerID) > 9 && containerStatus.ContainerID[:9] == "docker://" {
-				return types.RuntimeDocker
+				return api.RuntimeDocker
 			}
 			if len(containerStatus.ContainerID) > 6 && containerStatus.ContainerID[:6] == "cri-o:" {
-				return types.RuntimeCRIO
+				return api.RuntimeCRIO
 			}
 		}
 	}
 
 	// 默认假设是containerd
-	return types.RuntimeContainerd
+	return api.RuntimeContainerd
 }
 
 // getContainerStatus 获取容器状态
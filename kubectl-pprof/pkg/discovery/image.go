@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ImageTarget is a container whose image matched an image glob
+type ImageTarget struct {
+	Pod       string
+	Container string
+	Image     string
+	Node      string
+}
+
+// ImagePattern matches container images against a glob where * matches any
+// run of characters, slashes and colons included, e.g. ghcr.io/foo/api:*
+type ImagePattern struct {
+	re *regexp.Regexp
+}
+
+// NewImagePattern compiles an image glob
+func NewImagePattern(glob string) *ImagePattern {
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(glob), `\*`, ".*")
+	return &ImagePattern{re: regexp.MustCompile("^" + quoted + "$")}
+}
+
+// Match reports whether the image of the container matches, as written in the
+// pod spec or as resolved by the runtime (docker.io/library/... for short names)
+func (p *ImagePattern) Match(container *corev1.Container, status *corev1.ContainerStatus) bool {
+	if p.re.MatchString(container.Image) {
+		return true
+	}
+	return status != nil && status.Image != "" && p.re.MatchString(status.Image)
+}
+
+// ImageTargets returns the containers of the pods whose image matches the
+// glob, in pod order and then container order
+func ImageTargets(pods []corev1.Pod, glob string) []ImageTarget {
+	pattern := NewImagePattern(glob)
+	var targets []ImageTarget
+	for i := range pods {
+		pod := &pods[i]
+		for j := range pod.Spec.Containers {
+			container := &pod.Spec.Containers[j]
+			if !pattern.Match(container, ContainerStatus(pod, container.Name)) {
+				continue
+			}
+			targets = append(targets, ImageTarget{
+				Pod:       pod.Name,
+				Container: container.Name,
+				Image:     container.Image,
+				Node:      pod.Spec.NodeName,
+			})
+		}
+	}
+	return targets
+}
+
+// ContainerStatus returns the status of a container of the pod, or nil
+func ContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
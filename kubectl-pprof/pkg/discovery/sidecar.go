@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultContainerAnnotation names the container kubectl uses by default
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// sidecarNames well-known sidecar container names: service mesh proxies, log
+// shippers, secret and auth agents
+var sidecarNames = []string{
+	"istio-proxy", "istio-init", "linkerd-proxy", "linkerd-init", "envoy", "envoy-sidecar",
+	"consul-connect-envoy-sidecar", "kuma-sidecar", "cloud-sql-proxy", "cloudsql-proxy",
+	"vault-agent", "oauth2-proxy", "fluent-bit", "fluentbit", "fluentd", "filebeat",
+	"promtail", "vector", "logrotate", "otel-collector", "jaeger-agent", "datadog-agent",
+}
+
+// sidecarImages well-known sidecar image name fragments
+var sidecarImages = []string{
+	"istio/proxyv2", "linkerd2-proxy", "linkerd/proxy", "envoyproxy/envoy", "cloud-sql-proxy",
+	"hashicorp/vault", "oauth2-proxy", "fluent-bit", "fluentd", "beats/filebeat",
+	"grafana/promtail", "timberio/vector", "opentelemetry-collector", "jaegertracing/jaeger-agent",
+	"datadog/agent",
+}
+
+// isSidecar reports why a container looks like a well-known sidecar, or ""
+func isSidecar(container *corev1.Container) string {
+	for _, name := range sidecarNames {
+		if container.Name == name {
+			return fmt.Sprintf("name %q", container.Name)
+		}
+	}
+	for _, image := range sidecarImages {
+		if strings.Contains(container.Image, image) {
+			return fmt.Sprintf("image %q", container.Image)
+		}
+	}
+	return ""
+}
+
+// SelectContainer picks the container to profile when none was named and
+// explains the choice. Unless strict, the kubectl default-container annotation
+// is honored and well-known sidecars are skipped; strict picks the first container.
+func SelectContainer(pod *corev1.Pod, strict bool) (*corev1.Container, string, error) {
+	containers := pod.Spec.Containers
+	if len(containers) == 0 {
+		return nil, "", fmt.Errorf("no containers found in pod %s/%s", pod.Namespace, pod.Name)
+	}
+	if strict || len(containers) == 1 {
+		if len(containers) == 1 {
+			return &containers[0], "only container in the pod", nil
+		}
+		return &containers[0], "first container in the pod (--strict)", nil
+	}
+
+	if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i], fmt.Sprintf("named by the %s annotation", defaultContainerAnnotation), nil
+			}
+		}
+	}
+
+	var (
+		candidates []*corev1.Container
+		skipped    []string
+	)
+	for i := range containers {
+		if why := isSidecar(&containers[i]); why != "" {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", containers[i].Name, why))
+			continue
+		}
+		candidates = append(candidates, &containers[i])
+	}
+
+	switch {
+	case len(candidates) == 0:
+		return &containers[0], "all containers look like sidecars, using the first one", nil
+	case len(skipped) == 0:
+		return candidates[0], "first container in the pod, no known sidecars found", nil
+	case len(candidates) == 1:
+		return candidates[0], fmt.Sprintf("skipped sidecars %s", strings.Join(skipped, ", ")), nil
+	default:
+		return candidates[0], fmt.Sprintf("first of %d application containers, skipped sidecars %s", len(candidates), strings.Join(skipped, ", ")), nil
+	}
+}
@@ -0,0 +1,34 @@
+package discovery
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Annotations the kubelet sets on the mirror pod of a static pod
+const (
+	mirrorAnnotation     = "kubernetes.io/config.mirror"
+	configHashAnnotation = "kubernetes.io/config.hash"
+)
+
+// IsStaticPod reports whether the pod is the mirror of a static pod, which
+// the kubelet runs from a manifest on the node rather than from the API
+func IsStaticPod(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorAnnotation]; ok {
+		return true
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "Node" {
+			return true
+		}
+	}
+	return false
+}
+
+// CgroupPodUID returns the UID the pod's cgroups are named after. For a
+// static pod that is the hash of its manifest, not the UID of the mirror pod.
+func CgroupPodUID(pod *corev1.Pod) string {
+	if hash := pod.Annotations[configHashAnnotation]; hash != "" && IsStaticPod(pod) {
+		return hash
+	}
+	return string(pod.UID)
+}
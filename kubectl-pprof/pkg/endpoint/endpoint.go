@@ -0,0 +1,171 @@
+// Package endpoint fetches profiles from the net/http/pprof handler of a pod
+// through a port-forward, which needs no privileged access to the node.
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+)
+
+// Annotations that point kubectl-pprof at the pprof handler of a pod
+const (
+	PortAnnotation = "kubectl-pprof.io/pprof-port" // Port number or container port name
+	PathAnnotation = "kubectl-pprof.io/pprof-path" // Path the handler is mounted at
+)
+
+// DefaultPath is where net/http/pprof registers its handlers
+const DefaultPath = "/debug/pprof"
+
+// defaultPort is the port conventionally used for a dedicated pprof listener
+const defaultPort = 6060
+
+// portNames container port names that conventionally serve pprof
+var portNames = []string{"pprof", "http-pprof", "debug", "http-debug"}
+
+// Profiles net/http/pprof profiles that can be fetched. "profile" is the CPU profile.
+var Profiles = []string{"profile", "heap", "allocs", "goroutine", "block", "mutex", "threadcreate"}
+
+// windowed profiles are collected over the profiling duration via ?seconds=N,
+// the others are snapshots of the current state
+var windowed = map[string]bool{"profile": true, "block": true, "mutex": true}
+
+// Endpoint the pprof handler of a pod
+type Endpoint struct {
+	Port   int32
+	Path   string
+	Reason string // How the port was found
+}
+
+// Detect finds the pprof handler of a container. An explicit port (number or
+// container port name) wins over the pod annotations, which win over container
+// ports named like pprof and the conventional port 6060.
+func Detect(pod *corev1.Pod, container *corev1.Container, port, path string) (*Endpoint, error) {
+	ep := &Endpoint{Path: path}
+	if ep.Path == "" {
+		ep.Path = pod.Annotations[PathAnnotation]
+	}
+	if ep.Path == "" {
+		ep.Path = DefaultPath
+	}
+	ep.Path = "/" + strings.Trim(ep.Path, "/")
+
+	var err error
+	switch {
+	case port != "":
+		ep.Port, err = resolvePort(pod, container, port)
+		ep.Reason = "set with --pprof-port"
+	case pod.Annotations[PortAnnotation] != "":
+		ep.Port, err = resolvePort(pod, container, pod.Annotations[PortAnnotation])
+		ep.Reason = fmt.Sprintf("named by the %s annotation", PortAnnotation)
+	default:
+		ep.Port, ep.Reason = detectPort(container)
+		if ep.Port == 0 {
+			err = errors.NewValidationError(
+				fmt.Sprintf("no pprof endpoint found on container %s", container.Name),
+				fmt.Sprintf("Name the port with --pprof-port or the %s pod annotation", PortAnnotation),
+				fmt.Sprintf("Name the container port one of: %s", strings.Join(portNames, ", ")),
+				"Import net/http/pprof and serve it, e.g. http.ListenAndServe(\"localhost:6060\", nil)",
+			)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// detectPort looks for a container port that conventionally serves pprof
+func detectPort(container *corev1.Container) (int32, string) {
+	for _, name := range portNames {
+		for _, p := range container.Ports {
+			if p.Name == name {
+				return p.ContainerPort, fmt.Sprintf("container port named %q", name)
+			}
+		}
+	}
+	for _, p := range container.Ports {
+		if p.ContainerPort == defaultPort {
+			return p.ContainerPort, fmt.Sprintf("container port %d", defaultPort)
+		}
+	}
+	return 0, ""
+}
+
+// resolvePort resolves a port number or a container port name, looking at the
+// target container first and then at the rest of the pod
+func resolvePort(pod *corev1.Pod, container *corev1.Container, value string) (int32, error) {
+	if n, err := strconv.ParseInt(value, 10, 32); err == nil {
+		if n <= 0 || n > 65535 {
+			return 0, fmt.Errorf("invalid pprof port %d", n)
+		}
+		return int32(n), nil
+	}
+
+	containers := append([]corev1.Container{*container}, pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			if p.Name == value {
+				return p.ContainerPort, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("pod %s/%s has no container port named %q", pod.Namespace, pod.Name, value)
+}
+
+// URL returns the address of a profile on a local port forwarded to the endpoint
+func (e *Endpoint) URL(localPort uint16, profile string, duration time.Duration) string {
+	u := url.URL{
+		Scheme: "http",
+		Host:   fmt.Sprintf("127.0.0.1:%d", localPort),
+		Path:   e.Path + "/" + profile,
+	}
+	if windowed[profile] {
+		u.RawQuery = url.Values{"seconds": {strconv.Itoa(int(duration.Seconds()))}}.Encode()
+	}
+	return u.String()
+}
+
+// HeapSnapshotURL returns the address of the heap profile. A GC runs first so
+// the in-use figures are current instead of as of the last cycle.
+func (e *Endpoint) HeapSnapshotURL(localPort uint16) string {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     fmt.Sprintf("127.0.0.1:%d", localPort),
+		Path:     e.Path + "/heap",
+		RawQuery: "gc=1",
+	}
+	return u.String()
+}
+
+// Fetch downloads a profile in the protobuf format
+func Fetch(ctx context.Context, profileURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, profileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", profileURL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", profileURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s: %s", profileURL, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
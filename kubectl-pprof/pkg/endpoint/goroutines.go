@@ -0,0 +1,118 @@
+package endpoint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// goroutineHeader matches "goroutine 7 [chan receive, 5 minutes]:", optionally
+// with the gp=/m= fields printed by newer runtimes
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+)(?: [a-z]+=\S+)* \[(.*)\]:$`)
+
+// GoroutineDumpURL returns the address of the full goroutine stack dump, the
+// same text the runtime prints on SIGQUIT but without stopping the process
+func (e *Endpoint) GoroutineDumpURL(localPort uint16) string {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     fmt.Sprintf("127.0.0.1:%d", localPort),
+		Path:     e.Path + "/goroutine",
+		RawQuery: "debug=2",
+	}
+	return u.String()
+}
+
+// ParseGoroutineDump parses a debug=2 goroutine dump and counts goroutines by state
+func ParseGoroutineDump(r io.Reader) (*api.GoroutineDump, error) {
+	dump := &api.GoroutineDump{
+		CapturedAt: time.Now().UTC(),
+		States:     make(map[string]int),
+	}
+
+	var current *api.GoroutineInfo
+	flush := func() {
+		if current == nil {
+			return
+		}
+		dump.Goroutines = append(dump.Goroutines, *current)
+		dump.States[current.State]++
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			flush()
+		case goroutineHeader.MatchString(line):
+			flush()
+			m := goroutineHeader.FindStringSubmatch(line)
+			id, _ := strconv.ParseInt(m[1], 10, 64)
+			current = &api.GoroutineInfo{ID: id}
+			parseGoroutineStatus(current, m[2])
+		case current == nil:
+			// Text outside a goroutine block, e.g. a truncation note
+		case strings.HasPrefix(line, "\t"):
+			location := strings.TrimSpace(line)
+			if i := strings.LastIndex(location, " +0x"); i >= 0 {
+				location = location[:i]
+			}
+			if n := len(current.Frames); n > 0 && current.Frames[n-1].Location == "" {
+				current.Frames[n-1].Location = location
+			}
+		case strings.HasPrefix(line, "created by "):
+			created := strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(created, " in goroutine "); i >= 0 {
+				created = created[:i]
+			}
+			current.CreatedBy = created
+		default:
+			current.Frames = append(current.Frames, api.GoroutineFrame{Function: frameFunction(line)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read goroutine dump: %w", err)
+	}
+	flush()
+
+	if len(dump.Goroutines) == 0 {
+		return nil, fmt.Errorf("no goroutines found in dump")
+	}
+	dump.Total = len(dump.Goroutines)
+	return dump, nil
+}
+
+// parseGoroutineStatus parses the bracketed status, e.g.
+// "select, 12 minutes, locked to thread"
+func parseGoroutineStatus(g *api.GoroutineInfo, status string) {
+	for i, part := range strings.Split(status, ", ") {
+		switch {
+		case i == 0:
+			g.State = part
+		case part == "locked to thread":
+			g.LockedToThread = true
+		case strings.HasSuffix(part, " minutes"):
+			g.WaitMinutes, _ = strconv.Atoi(strings.TrimSuffix(part, " minutes"))
+		}
+	}
+}
+
+// frameFunction strips the argument list from a traceback function line.
+// Receivers such as "(*Server).Serve" keep their parentheses.
+func frameFunction(line string) string {
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndex(line, "("); i > 0 {
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,176 @@
+package endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Prometheus Go collector series read when the application serves /metrics
+// on the pprof port
+const (
+	gomaxprocsMetric   = "go_sched_gomaxprocs_threads"
+	schedLatencyBucket = "go_sched_latencies_seconds_bucket"
+)
+
+// RuntimeSnapshot reads the runtime.MemStats printed with the debug=1 heap
+// profile and the goroutine count, plus GOMAXPROCS and the scheduler latency
+// histogram when the Prometheus Go collector is served on the same port
+func (e *Endpoint) RuntimeSnapshot(ctx context.Context, localPort uint16) (*api.RuntimeSnapshot, error) {
+	base := fmt.Sprintf("http://127.0.0.1:%d%s", localPort, e.Path)
+	snapshot := &api.RuntimeSnapshot{Time: time.Now().UTC()}
+
+	heap, err := Fetch(ctx, base+"/heap?debug=1")
+	if err != nil {
+		return nil, err
+	}
+	if err := parseMemStats(heap, snapshot); err != nil {
+		return nil, err
+	}
+
+	goroutines, err := Fetch(ctx, base+"/goroutine?debug=1")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Sscanf(string(goroutines), "goroutine profile: total %d", &snapshot.Goroutines); err != nil {
+		return nil, fmt.Errorf("failed to parse goroutine count: %w", err)
+	}
+
+	// The metrics endpoint is optional, most pprof handlers do not have one
+	if metrics, err := Fetch(ctx, fmt.Sprintf("http://127.0.0.1:%d/metrics", localPort)); err == nil {
+		parseGoCollector(metrics, snapshot)
+	}
+	return snapshot, nil
+}
+
+// parseMemStats reads the "# Name = value" lines that follow the heap profile
+func parseMemStats(data []byte, snapshot *api.RuntimeSnapshot) error {
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimPrefix(scanner.Text(), "# "), " = ")
+		if !ok {
+			continue
+		}
+		found = true
+		switch name {
+		case "HeapAlloc":
+			snapshot.HeapAlloc, _ = strconv.ParseUint(value, 10, 64)
+		case "HeapSys":
+			snapshot.HeapSys, _ = strconv.ParseUint(value, 10, 64)
+		case "HeapObjects":
+			snapshot.HeapObjects, _ = strconv.ParseUint(value, 10, 64)
+		case "NextGC":
+			snapshot.NextGC, _ = strconv.ParseUint(value, 10, 64)
+		case "NumGC":
+			n, _ := strconv.ParseUint(value, 10, 32)
+			snapshot.NumGC = uint32(n)
+		case "NumForcedGC":
+			n, _ := strconv.ParseUint(value, 10, 32)
+			snapshot.NumForcedGC = uint32(n)
+		case "GCCPUFraction":
+			snapshot.GCCPUFraction, _ = strconv.ParseFloat(value, 64)
+		case "PauseNs":
+			for _, field := range strings.Fields(strings.Trim(value, "[]")) {
+				ns, _ := strconv.ParseUint(field, 10, 64)
+				snapshot.PauseNs = append(snapshot.PauseNs, ns)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read heap profile: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no runtime.MemStats found in the heap profile")
+	}
+	return nil
+}
+
+// parseGoCollector picks the Go collector series from a Prometheus text exposition
+func parseGoCollector(data []byte, snapshot *api.RuntimeSnapshot) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, gomaxprocsMetric+" "):
+			value, _ := strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(line, gomaxprocsMetric)), 64)
+			snapshot.GOMAXPROCS = int(value)
+		case strings.HasPrefix(line, schedLatencyBucket+"{"):
+			start := strings.Index(line, `le="`)
+			end := strings.LastIndex(line, `"}`)
+			if start < 0 || end < start {
+				continue
+			}
+			bound, err := strconv.ParseFloat(line[start+4:end], 64)
+			if err != nil {
+				continue
+			}
+			count, _ := strconv.ParseFloat(strings.TrimSpace(line[end+2:]), 64)
+			snapshot.SchedLatencyBuckets = append(snapshot.SchedLatencyBuckets, api.LatencyBucket{UpperBound: bound, Count: uint64(count)})
+		}
+	}
+	sort.Slice(snapshot.SchedLatencyBuckets, func(i, j int) bool {
+		return snapshot.SchedLatencyBuckets[i].UpperBound < snapshot.SchedLatencyBuckets[j].UpperBound
+	})
+}
+
+// RuntimeReport compares two snapshots taken around the profiling window
+func RuntimeReport(start, end *api.RuntimeSnapshot) *api.RuntimeMetricsReport {
+	report := &api.RuntimeMetricsReport{
+		Start:     start,
+		End:       end,
+		HeapDelta: int64(end.HeapAlloc) - int64(start.HeapAlloc),
+	}
+	if end.NumGC > start.NumGC {
+		report.GCCycles = end.NumGC - start.NumGC
+	}
+
+	// PauseNs[(n+255)%256] is the pause of the n-th GC, older pauses are overwritten
+	if size := uint32(len(end.PauseNs)); size > 0 {
+		for n := end.NumGC; n > start.NumGC && end.NumGC-n < size; n-- {
+			pause := time.Duration(end.PauseNs[(n+size-1)%size])
+			report.GCPauseTotal += pause
+			if pause > report.GCPauseMax {
+				report.GCPauseMax = pause
+			}
+		}
+	}
+
+	report.SchedLatencyP99 = windowQuantile(start.SchedLatencyBuckets, end.SchedLatencyBuckets, 0.99)
+	return report
+}
+
+// windowQuantile estimates a quantile of the observations made between two
+// readings of a cumulative histogram, as the upper bound of its bucket
+func windowQuantile(start, end []api.LatencyBucket, q float64) time.Duration {
+	if len(end) == 0 || len(start) != len(end) {
+		return 0
+	}
+	total := end[len(end)-1].Count - start[len(start)-1].Count
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	for i := range end {
+		if end[i].Count-start[i].Count >= rank {
+			if math.IsInf(end[i].UpperBound, 1) {
+				if i == 0 {
+					return 0
+				}
+				return time.Duration(end[i-1].UpperBound * float64(time.Second))
+			}
+			return time.Duration(end[i].UpperBound * float64(time.Second))
+		}
+	}
+	return 0
+}
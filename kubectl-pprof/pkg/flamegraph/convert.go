@@ -0,0 +1,92 @@
+package flamegraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output formats written by Encode besides the rendered graphs
+const (
+	FormatSpeedscope = "speedscope"
+)
+
+// ConvertFormats lists the formats Encode writes
+var ConvertFormats = []string{"svg", "png", "pdf", "html", FormatPprof, FormatSpeedscope}
+
+// Extension returns the file extension of an output format
+func Extension(format string) string {
+	switch format {
+	case FormatPprof:
+		return ".pb.gz"
+	case FormatSpeedscope:
+		return ".speedscope.json"
+	default:
+		return "." + format
+	}
+}
+
+// Encode renders or converts stacks into one of ConvertFormats
+func Encode(w io.Writer, p *Profile, format string, opts Options) error {
+	switch format {
+	case "svg":
+		return RenderSVG(w, p, opts)
+	case "png":
+		return RenderPNG(w, p, opts)
+	case "pdf":
+		return RenderPDF(w, p, opts)
+	case "html":
+		return RenderHTML(w, p, opts)
+	case FormatPprof:
+		return WritePprof(w, p, opts.CountName)
+	case FormatSpeedscope:
+		return WriteSpeedscope(w, p, opts.Title)
+	default:
+		return fmt.Errorf("unsupported output format %q, must be one of: %s", format, strings.Join(ConvertFormats, ", "))
+	}
+}
+
+// Conversion is the outcome of encoding stacks into one format
+type Conversion struct {
+	Format   string
+	Data     []byte
+	Err      error
+	Duration time.Duration
+}
+
+// ConvertAll encodes the stacks into every format, running up to workers
+// conversions at once (the number of CPUs when 0 or less). Conversions are
+// returned in the order of formats; the profile and options are only read,
+// so they are shared by the workers.
+func ConvertAll(p *Profile, formats []string, opts Options, workers int) []Conversion {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	conversions := make([]Conversion, len(formats))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(formats); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				start := time.Now()
+				var buf bytes.Buffer
+				err := Encode(&buf, p, formats[j], opts)
+				conversions[j] = Conversion{Format: formats[j], Data: buf.Bytes(), Err: err, Duration: time.Since(start)}
+			}
+		}()
+	}
+	for j := range formats {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	return conversions
+}
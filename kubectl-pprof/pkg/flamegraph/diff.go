@@ -0,0 +1,64 @@
+package flamegraph
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// baselineValues returns the value of every frame of root in the baseline
+// tree, in the order Layout lists the frames. Frames are matched by their
+// path from the root, frames absent from the baseline have 0.
+func baselineValues(root, baseline *Node) []int64 {
+	var values []int64
+	var walk func(n, b *Node)
+	walk = func(n, b *Node) {
+		var value int64
+		if b != nil {
+			value = b.Value
+		}
+		values = append(values, value)
+		for _, c := range n.Children {
+			var match *Node
+			if b != nil {
+				for _, bc := range b.Children {
+					if bc.Name == c.Name {
+						match = bc
+						break
+					}
+				}
+			}
+			walk(c, match)
+		}
+	}
+	walk(root, baseline)
+	return values
+}
+
+// fill returns the fill color of a frame: by palette, or in a differential
+// graph red for frames that grew against the baseline and blue for frames
+// that shrank, deeper for larger changes
+func (g *graph) fill(b box, colors *colorizer) color.RGBA {
+	if b.Depth == 0 {
+		return rgb(250, 250, 250)
+	}
+	if g.opts.Baseline == nil {
+		return colors.frameColor(b.Name)
+	}
+	if g.maxDelta == 0 || b.Delta == 0 {
+		return rgb(250, 250, 250)
+	}
+	fade := 255 - int(math.Round(200*math.Abs(b.Delta)/g.maxDelta))
+	if b.Delta > 0 {
+		return rgb(255, fade, fade)
+	}
+	return rgb(fade, fade, 255)
+}
+
+// deltaDetails returns the change of a frame against the baseline for its tooltip
+func (g *graph) deltaDetails(b box) string {
+	if g.opts.Baseline == nil {
+		return ""
+	}
+	return fmt.Sprintf(", %+.2f%% vs baseline", b.Delta)
+}
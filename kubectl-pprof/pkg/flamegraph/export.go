@@ -0,0 +1,114 @@
+package flamegraph
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// speedscopeSchema identifies the speedscope file format
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+// WritePprof encodes stacks as a gzipped pprof profile with one sample type,
+// named after countName ("samples" when empty, "type/unit" as ParsePprof
+// returns it). Every distinct frame becomes a function of its own.
+func WritePprof(w io.Writer, p *Profile, countName string) error {
+	sampleType, unit := countName, "count"
+	if sampleType == "" {
+		sampleType = defaultCountName
+	} else if t, u, ok := strings.Cut(countName, "/"); ok {
+		sampleType, unit = t, u
+	}
+
+	prof := &profile.Profile{
+		SampleType:        []*profile.ValueType{{Type: sampleType, Unit: unit}},
+		DefaultSampleType: sampleType,
+	}
+	locations := make(map[string]*profile.Location)
+	location := func(name string) *profile.Location {
+		if loc, ok := locations[name]; ok {
+			return loc
+		}
+		fn := &profile.Function{ID: uint64(len(prof.Function) + 1), Name: name, SystemName: name}
+		prof.Function = append(prof.Function, fn)
+		loc := &profile.Location{ID: uint64(len(prof.Location) + 1), Line: []profile.Line{{Function: fn}}}
+		prof.Location = append(prof.Location, loc)
+		locations[name] = loc
+		return loc
+	}
+
+	for _, s := range p.Samples {
+		// pprof lists locations leaf first
+		locs := make([]*profile.Location, len(s.Stack))
+		for i, name := range s.Stack {
+			locs[len(s.Stack)-1-i] = location(name)
+		}
+		prof.Sample = append(prof.Sample, &profile.Sample{Location: locs, Value: []int64{s.Value}, Label: s.Labels})
+	}
+	return prof.Write(w)
+}
+
+// speedscopeFile is the speedscope JSON file format, with one sampled profile
+type speedscopeFile struct {
+	Schema   string              `json:"$schema"`
+	Name     string              `json:"name,omitempty"`
+	Shared   speedscopeShared    `json:"shared"`
+	Profiles []speedscopeProfile `json:"profiles"`
+	Exporter string              `json:"exporter"`
+}
+
+type speedscopeShared struct {
+	Frames []speedscopeFrame `json:"frames"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+}
+
+type speedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue int64   `json:"startValue"`
+	EndValue   int64   `json:"endValue"`
+	Samples    [][]int `json:"samples"`
+	Weights    []int64 `json:"weights"`
+}
+
+// WriteSpeedscope encodes stacks in the speedscope file format, in sample
+// order so timeline input opens as a time-ordered chart
+func WriteSpeedscope(w io.Writer, p *Profile, name string) error {
+	frames := make(map[string]int)
+	prof := speedscopeProfile{
+		Type:     "sampled",
+		Name:     name,
+		Unit:     "none",
+		EndValue: p.Total(),
+		Samples:  make([][]int, 0, len(p.Samples)),
+		Weights:  make([]int64, 0, len(p.Samples)),
+	}
+	file := speedscopeFile{Schema: speedscopeSchema, Name: name, Exporter: "kubectl-pprof"}
+
+	for _, s := range p.Samples {
+		stack := make([]int, len(s.Stack))
+		for i, frame := range s.Stack {
+			index, ok := frames[frame]
+			if !ok {
+				index = len(file.Shared.Frames)
+				frames[frame] = index
+				file.Shared.Frames = append(file.Shared.Frames, speedscopeFrame{Name: frame})
+			}
+			stack[i] = index
+		}
+		prof.Samples = append(prof.Samples, stack)
+		prof.Weights = append(prof.Weights, s.Value)
+	}
+	file.Profiles = []speedscopeProfile{prof}
+	if file.Shared.Frames == nil {
+		file.Shared.Frames = []speedscopeFrame{}
+	}
+
+	return json.NewEncoder(w).Encode(file)
+}
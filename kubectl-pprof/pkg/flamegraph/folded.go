@@ -0,0 +1,29 @@
+package flamegraph
+
+import (
+	"io"
+
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// Sample 一条折叠堆栈记录
+type Sample = stacks.Sample
+
+// Profile 折叠堆栈数据
+type Profile = stacks.Profile
+
+// ParseFolded parses Brendan Gregg's folded format: "root;child;leaf count"
+func ParseFolded(r io.Reader) (*Profile, error) {
+	return stacks.Parse(r)
+}
+
+// ParseTimeline parses the time-ordered format written by golang-profiling
+// --export-timeline: "offset_ms root;child;leaf count"
+func ParseTimeline(r io.Reader) (*Profile, error) {
+	return stacks.ParseTimeline(r)
+}
+
+// WriteFolded writes stacks in the folded format read by ParseFolded
+func WriteFolded(w io.Writer, p *Profile) error {
+	return stacks.Write(w, p)
+}
@@ -0,0 +1,38 @@
+package flamegraph
+
+// FunctionStat is the number of samples a function accounts for
+type FunctionStat struct {
+	Name string
+	// Samples with the function as the leaf frame
+	Self int64
+	// Samples with the function anywhere on the stack, recursion counted once
+	Total int64
+}
+
+// Functions aggregates the samples of a profile per function
+func Functions(p *Profile) map[string]*FunctionStat {
+	stats := make(map[string]*FunctionStat)
+	stat := func(name string) *FunctionStat {
+		s, ok := stats[name]
+		if !ok {
+			s = &FunctionStat{Name: name}
+			stats[name] = s
+		}
+		return s
+	}
+
+	for _, sample := range p.Samples {
+		if len(sample.Stack) == 0 {
+			continue
+		}
+		seen := make(map[string]bool, len(sample.Stack))
+		for _, name := range sample.Stack {
+			if !seen[name] {
+				seen[name] = true
+				stat(name).Total += sample.Value
+			}
+		}
+		stat(sample.Stack[len(sample.Stack)-1]).Self += sample.Value
+	}
+	return stats
+}
@@ -0,0 +1,177 @@
+package flamegraph
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// htmlSearchScript highlights frames of the shown graph matching the search
+// box and reports the share of samples they cover. With kernel frames, the
+// toggle swaps the graph for one with each run of them collapsed.
+const htmlSearchScript = `
+<script>
+(function () {
+	var input = document.getElementById("search");
+	var status = document.getElementById("matched");
+	var toggle = document.getElementById("collapse-kernel");
+	function search() {
+		var graph = document.querySelector(".graph:not([hidden])");
+		var frames = Array.prototype.slice.call(graph.querySelectorAll("svg g"));
+		var term = input.value;
+		var re = null;
+		try { re = term ? new RegExp(term) : null; } catch (e) { return; }
+		var matched = {}, total = 0, sum = 0;
+		frames.forEach(function (g) {
+			var title = g.querySelector("title"), rect = g.querySelector("rect");
+			if (!title || !rect) { return; }
+			if (!rect.dataset.fill) { rect.dataset.fill = rect.getAttribute("fill"); }
+			var value = parseInt(g.dataset.value || "0", 10);
+			if (g.dataset.depth === "0") { total = value; }
+			var name = title.textContent.replace(/ \([^)]*\)$/, "");
+			if (re && re.test(name)) {
+				rect.setAttribute("fill", "rgb(230,0,230)");
+				// Count each matched region once: skip frames nested in a match
+				var start = parseInt(g.dataset.start, 10), end = start + value, nested = false;
+				Object.keys(matched).forEach(function (k) {
+					var m = matched[k];
+					if (start >= m[0] && end <= m[1]) { nested = true; }
+				});
+				if (!nested) { matched[start + ":" + end] = [start, end]; sum += value; }
+			} else {
+				rect.setAttribute("fill", rect.dataset.fill);
+			}
+		});
+		status.textContent = re && total ? "Matched: " + (100 * sum / total).toFixed(2) + "%" : "";
+	}
+	input.addEventListener("input", search);
+	Array.prototype.forEach.call(document.querySelectorAll("a.finding"), function (a) {
+		a.addEventListener("click", function (e) {
+			e.preventDefault();
+			input.value = a.dataset.search;
+			search();
+		});
+	});
+	if (toggle) {
+		toggle.addEventListener("change", function () {
+			document.getElementById("graph-full").hidden = toggle.checked;
+			document.getElementById("graph-collapsed").hidden = !toggle.checked;
+			search();
+		});
+	}
+})();
+</script>
+`
+
+// RenderHTML renders the profile as a self-contained HTML page with the SVG
+// inline and a regex search box that highlights matching frames. Profiles
+// with kernel frames get a second graph with them collapsed and a toggle,
+// profiles with pprof labels a table of the samples of every label value.
+// Options.SourceURL turns the frames with a source location into links, the
+// findings of Options.Findings search their frames when clicked.
+func RenderHTML(w io.Writer, p *Profile, opts Options) error {
+	var svg bytes.Buffer
+	if err := renderSVG(&svg, p, opts, true); err != nil {
+		return err
+	}
+	graphs := fmt.Sprintf("<div class=\"graph\" id=\"graph-full\">\n%s\n</div>\n", svgBody(svg.Bytes()))
+	toggle := ""
+	if HasKernelFrames(p) {
+		var collapsed bytes.Buffer
+		if err := renderSVG(&collapsed, CollapseKernelFrames(p), opts, true); err != nil {
+			return err
+		}
+		graphs += fmt.Sprintf("<div class=\"graph\" id=\"graph-collapsed\" hidden>\n%s\n</div>\n", svgBody(collapsed.Bytes()))
+		toggle = "\n\t<label><input id=\"collapse-kernel\" type=\"checkbox\"> Collapse kernel frames</label>"
+	}
+
+	title := opts.withDefaults().Title
+	_, err := fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<style>
+	body { margin: 0; font-family: Verdana, sans-serif; font-size: 12px; }
+	.toolbar { padding: 8px 10px; }
+	#matched { margin-left: 12px; color: rgb(160,0,160); }
+	.toolbar label { margin-left: 12px; }
+	.facts { margin: 0 10px 8px; border-collapse: collapse; }
+	.facts td { padding: 2px 12px 2px 0; }
+	.facts td:first-child { color: rgb(100,100,100); }
+	.findings { margin: 0 10px 8px; border-collapse: collapse; }
+	.findings td { padding: 2px 12px 2px 0; }
+	.tags { margin: 0 10px 8px; border-collapse: collapse; }
+	.tags th { text-align: left; padding: 2px 12px 2px 0; }
+	.tags td { padding: 2px 12px 2px 0; }
+	.tags td.share { text-align: right; }
+</style>
+</head>
+<body>
+<div class="toolbar">
+	<input id="search" type="search" placeholder="Search (regex)" size="40">
+	<span id="matched"></span>%[5]s
+</div>
+%[2]s%[3]s%[4]s</body>
+</html>
+`, html.EscapeString(title), factsTable(opts.Facts)+findingsTable(opts.Findings)+tagsTable(Tags(p), opts.withDefaults().CountName), graphs, htmlSearchScript, toggle)
+	return err
+}
+
+// factsTable renders the session facts as a two-column table
+func factsTable(facts []Fact) string {
+	if len(facts) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteString("<table class=\"facts\">\n")
+	for _, f := range facts {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td></tr>\n", html.EscapeString(f.Name), html.EscapeString(f.Value))
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// findingsTable renders the findings, each a link searching its frames
+func findingsTable(findings []Finding) string {
+	if len(findings) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteString("<table class=\"findings\">\n")
+	for _, f := range findings {
+		fmt.Fprintf(&b, "<tr><td><a href=\"#\" class=\"finding\" data-search=\"%s\">%s</a></td><td>%s</td></tr>\n",
+			html.EscapeString(f.Search), html.EscapeString(f.Title), html.EscapeString(f.Detail))
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// tagsTable renders the samples of every label value, one section per key
+func tagsTable(tags []TagBreakdown, countName string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteString("<table class=\"tags\">\n")
+	for _, tag := range tags {
+		fmt.Fprintf(&b, "<tr><th>%s</th><th>%s</th><th>share</th></tr>\n", html.EscapeString(tag.Key), html.EscapeString(countName))
+		for _, v := range tag.Values {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td class=\"share\">%d</td><td class=\"share\">%.2f%%</td></tr>\n",
+				html.EscapeString(v.Value), v.Samples, stacks.Share(v.Samples, tag.Total))
+		}
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// svgBody strips the XML prolog so the SVG can be inlined into HTML
+func svgBody(svg []byte) []byte {
+	if i := bytes.Index(svg, []byte("<svg")); i >= 0 {
+		return svg[i:]
+	}
+	return svg
+}
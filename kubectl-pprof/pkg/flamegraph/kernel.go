@@ -0,0 +1,41 @@
+package flamegraph
+
+import "strings"
+
+// kernelSuffix marks the kernel frames of mixed kernel and user stacks
+const kernelSuffix = "_[k]"
+
+// collapsedKernelFrame stands in for a run of kernel frames
+const collapsedKernelFrame = "[kernel]" + kernelSuffix
+
+// HasKernelFrames reports whether any stack of the profile has kernel frames
+func HasKernelFrames(p *Profile) bool {
+	for _, s := range p.Samples {
+		for _, frame := range s.Stack {
+			if strings.HasSuffix(frame, kernelSuffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CollapseKernelFrames returns the profile with every run of kernel frames
+// replaced by a single [kernel]_[k] frame, so the time spent in the kernel
+// stays on the user frame that entered it
+func CollapseKernelFrames(p *Profile) *Profile {
+	collapsed := &Profile{Samples: make([]Sample, 0, len(p.Samples))}
+	for _, s := range p.Samples {
+		stack := make([]string, 0, len(s.Stack))
+		for _, frame := range s.Stack {
+			if !strings.HasSuffix(frame, kernelSuffix) {
+				stack = append(stack, frame)
+			} else if len(stack) == 0 || stack[len(stack)-1] != collapsedKernelFrame {
+				stack = append(stack, collapsedKernelFrame)
+			}
+		}
+		s.Stack = stack
+		collapsed.Samples = append(collapsed.Samples, s)
+	}
+	return collapsed
+}
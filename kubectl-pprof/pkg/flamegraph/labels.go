@@ -0,0 +1,145 @@
+package flamegraph
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RootAtLabels returns the profile with the stack of every labeled sample
+// rooted at a frame of its runtime/pprof labels, "key:value" pairs sorted by
+// key, so the work of each label set stands apart. Unlabeled stacks are kept
+// as they are.
+func RootAtLabels(p *Profile) *Profile {
+	rooted := &Profile{Samples: make([]Sample, 0, len(p.Samples))}
+	for _, s := range p.Samples {
+		if frame := labelFrame(s.Labels); frame != "" {
+			s.Stack = append([]string{frame}, s.Stack...)
+		}
+		rooted.Samples = append(rooted.Samples, s)
+	}
+	return rooted
+}
+
+// labelFrame names a label set, "" when it is empty
+func labelFrame(labels map[string][]string) string {
+	keys := labelKeys(labels)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+":"+strings.Join(labels[key], "|"))
+	}
+	// A ; would split the frame in the folded format
+	return strings.ReplaceAll(strings.Join(pairs, ","), ";", "_")
+}
+
+// TagFilter keeps the samples with a label value matching a regular
+// expression, like the -tagfocus option of go tool pprof
+type TagFilter struct {
+	Key   string
+	Value *regexp.Regexp
+}
+
+// ParseTagFilter parses a "key=regex" tag filter
+func ParseTagFilter(s string) (TagFilter, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q, must be key=regex", s)
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q: %w", s, err)
+	}
+	return TagFilter{Key: key, Value: re}, nil
+}
+
+// matches reports whether a value of the filtered label matches
+func (f TagFilter) matches(labels map[string][]string) bool {
+	for _, value := range labels[f.Key] {
+		if f.Value.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterTags returns the samples of the profile matching every filter
+func FilterTags(p *Profile, filters []TagFilter) *Profile {
+	filtered := &Profile{}
+	for _, s := range p.Samples {
+		keep := true
+		for _, f := range filters {
+			if !f.matches(s.Labels) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			filtered.Samples = append(filtered.Samples, s)
+		}
+	}
+	return filtered
+}
+
+// UnsetTag names the samples without a value for a label key
+const UnsetTag = "(unset)"
+
+// TagValue 一个标签值的样本数
+type TagValue struct {
+	Value   string
+	Samples int64
+}
+
+// TagBreakdown 一个标签键下各取值的样本分布，按样本数从高到低排列
+type TagBreakdown struct {
+	Key    string
+	Values []TagValue
+	Total  int64
+}
+
+// Tags breaks the samples of the profile down by the values of every label
+// key, sorted by key. Samples without the key are counted as UnsetTag.
+func Tags(p *Profile) []TagBreakdown {
+	counts := make(map[string]map[string]int64)
+	for _, s := range p.Samples {
+		for key, values := range s.Labels {
+			if counts[key] == nil {
+				counts[key] = make(map[string]int64)
+			}
+			counts[key][strings.Join(values, "|")] += s.Value
+		}
+	}
+	total := p.Total()
+
+	var tags []TagBreakdown
+	for key, values := range counts {
+		tag := TagBreakdown{Key: key, Total: total}
+		var labeled int64
+		for value, samples := range values {
+			tag.Values = append(tag.Values, TagValue{Value: value, Samples: samples})
+			labeled += samples
+		}
+		if unset := total - labeled; unset > 0 {
+			tag.Values = append(tag.Values, TagValue{Value: UnsetTag, Samples: unset})
+		}
+		sort.Slice(tag.Values, func(i, j int) bool {
+			if tag.Values[i].Samples != tag.Values[j].Samples {
+				return tag.Values[i].Samples > tag.Values[j].Samples
+			}
+			return tag.Values[i].Value < tag.Values[j].Value
+		})
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags
+}
+
+// labelKeys returns the keys of a label set, sorted
+func labelKeys(labels map[string][]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
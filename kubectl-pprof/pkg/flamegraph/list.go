@@ -0,0 +1,154 @@
+package flamegraph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// lineStat the samples of one source line of a function
+type lineStat struct {
+	flat, cum int64
+}
+
+// routine the source lines a function was sampled at
+type routine struct {
+	name, file string
+	lines      map[int]*lineStat
+	flat, cum  int64
+}
+
+// SourceLocation splits a "function file:line" frame into its parts, ok is
+// false for frames without a source location
+func SourceLocation(frame string) (function, file string, line int, ok bool) {
+	i := strings.LastIndex(frame, " ")
+	if i < 0 {
+		return "", "", 0, false
+	}
+	function, location := frame[:i], frame[i+1:]
+	j := strings.LastIndex(location, ":")
+	if j < 0 {
+		return "", "", 0, false
+	}
+	line, err := strconv.Atoi(location[j+1:])
+	if err != nil || line <= 0 {
+		return "", "", 0, false
+	}
+	return function, location[:j], line, true
+}
+
+// WriteList prints the hot source lines of the functions matching re, like
+// the list command of go tool pprof. It needs frames named "function
+// file:line" (see ParsePprofLines); each source line shows the samples spent
+// in it (flat) and below it (cum). The source text is printed when the file
+// exists locally. It returns the number of functions listed.
+func WriteList(w io.Writer, p *Profile, re *regexp.Regexp, countName string) (int, error) {
+	if countName == "" {
+		countName = defaultCountName
+	}
+	routines := make(map[string]*routine)
+	for _, s := range p.Samples {
+		seen := make(map[string]bool, len(s.Stack))
+		for i, frame := range s.Stack {
+			function, file, line, ok := SourceLocation(frame)
+			if !ok || !re.MatchString(function) {
+				continue
+			}
+			key := function + " " + file
+			r := routines[key]
+			if r == nil {
+				r = &routine{name: function, file: file, lines: make(map[int]*lineStat)}
+				routines[key] = r
+			}
+			stat := r.lines[line]
+			if stat == nil {
+				stat = &lineStat{}
+				r.lines[line] = stat
+			}
+			leaf := i == len(s.Stack)-1
+			if leaf {
+				stat.flat += s.Value
+				r.flat += s.Value
+			}
+			// Recursion counts once per line and once per function
+			if !seen[frame] {
+				seen[frame] = true
+				stat.cum += s.Value
+			}
+			if !seen[key] {
+				seen[key] = true
+				r.cum += s.Value
+			}
+		}
+	}
+
+	sorted := make([]*routine, 0, len(routines))
+	for _, r := range routines {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].cum != sorted[j].cum {
+			return sorted[i].cum > sorted[j].cum
+		}
+		return sorted[i].name < sorted[j].name
+	})
+
+	total := p.Total()
+	bw := bufio.NewWriter(w)
+	for _, r := range sorted {
+		fmt.Fprintf(bw, "ROUTINE ======================== %s in %s\n", r.name, r.file)
+		fmt.Fprintf(bw, "%10d %10d (flat, cum, %s) %.2f%% of Total\n", r.flat, r.cum, countName, stacks.Share(r.cum, total))
+		writeRoutineLines(bw, r)
+	}
+	return len(sorted), bw.Flush()
+}
+
+// writeRoutineLines prints the sampled lines of a routine, with the source
+// between the first and the last of them when the file can be read
+func writeRoutineLines(w io.Writer, r *routine) {
+	numbers := make([]int, 0, len(r.lines))
+	for line := range r.lines {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+
+	source := readSource(r.file)
+	if len(source) < numbers[len(numbers)-1] {
+		for _, line := range numbers {
+			writeListLine(w, r.lines[line], line, "")
+		}
+		return
+	}
+	for line := numbers[0]; line <= numbers[len(numbers)-1]; line++ {
+		writeListLine(w, r.lines[line], line, source[line-1])
+	}
+}
+
+// writeListLine prints one source line, "." for counts of zero like pprof
+func writeListLine(w io.Writer, stat *lineStat, line int, text string) {
+	flat, cum := ".", "."
+	if stat != nil && stat.flat != 0 {
+		flat = strconv.FormatInt(stat.flat, 10)
+	}
+	if stat != nil && stat.cum != 0 {
+		cum = strconv.FormatInt(stat.cum, 10)
+	}
+	fmt.Fprintf(w, "%10s %10s %6d:%s\n", flat, cum, line, strings.TrimRight(" "+text, " "))
+}
+
+// readSource returns the lines of a local source file, nil when it cannot be read
+func readSource(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n")
+}
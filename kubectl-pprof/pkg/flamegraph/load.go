@@ -0,0 +1,144 @@
+package flamegraph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// Input formats understood by LoadFile
+const (
+	FormatFolded   = "folded"
+	FormatTimeline = "timeline"
+	FormatPprof    = "pprof"
+)
+
+// InputFormats lists the supported input formats
+var InputFormats = []string{FormatFolded, FormatTimeline, FormatPprof}
+
+// timelineLinePattern matches "offset_ms stack count"
+var timelineLinePattern = regexp.MustCompile(`^\d+ \S.* \d+(\.\d*)?$`)
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// detectPrefix is how much of a file DetectFormat looks at
+const detectPrefix = 64 * 1024
+
+// LoadOptions tune how LoadFileWith reads a stacks file
+type LoadOptions struct {
+	// Input format, detected from the file when empty
+	Format string
+	// pprof sample type, the profile's default when empty
+	SampleType string
+	// Name the frames of pprof input after their source line
+	Lines bool
+	// Keep every folded line as its own sample, in file order, as flame
+	// charts need. Otherwise identical stacks are merged while reading, so
+	// memory grows with the distinct stacks rather than the file size.
+	KeepOrder bool
+	// Distinct stacks held in memory before spilling to disk, 0 for no bound
+	MaxStacks int
+	// Directory of the spill files, the system temp dir when empty
+	SpillDir string
+}
+
+// LoadFile reads stacks from a file. An empty format is detected from the
+// file extension and, failing that, from the content.
+func LoadFile(path, format, sampleType string) (*Profile, string, error) {
+	return LoadFileWith(path, LoadOptions{Format: format, SampleType: sampleType})
+}
+
+// LoadFileLines is LoadFile with the frames of pprof input named after their
+// source line, see ParsePprofLines. Folded input keeps the frames it has.
+func LoadFileLines(path, format, sampleType string) (*Profile, string, error) {
+	return LoadFileWith(path, LoadOptions{Format: format, SampleType: sampleType, Lines: true})
+}
+
+// LoadFileWith reads stacks from a file one line at a time, see LoadOptions
+func LoadFileWith(path string, o LoadOptions) (*Profile, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, detectPrefix)
+	// Stacks stored by the session store are zstd compressed
+	if magic, _ := reader.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+		decoder, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer decoder.Close()
+		reader = bufio.NewReaderSize(decoder, detectPrefix)
+	}
+	format := o.Format
+	if format == "" {
+		// Peek fails short of the whole prefix on small files, which is fine
+		prefix, _ := reader.Peek(detectPrefix)
+		format = DetectFormat(path, prefix)
+	}
+
+	var (
+		profile *Profile
+		unit    = defaultCountName
+	)
+	switch format {
+	case FormatFolded:
+		if o.KeepOrder {
+			profile, err = ParseFolded(reader)
+		} else {
+			profile, err = stacks.Aggregate(stacks.NewDecoder(reader), &stacks.Aggregator{MaxStacks: o.MaxStacks, SpillDir: o.SpillDir})
+		}
+	case FormatTimeline:
+		profile, err = ParseTimeline(reader)
+	case FormatPprof:
+		profile, unit, err = parsePprof(reader, o.SampleType, o.Lines)
+	default:
+		return nil, "", fmt.Errorf("unsupported input format %q, must be one of: %s", format, strings.Join(InputFormats, ", "))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return profile, unit, nil
+}
+
+// DetectFormat guesses the input format of a stacks file
+func DetectFormat(path string, data []byte) string {
+	name := strings.ToLower(filepath.Base(path))
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst")
+	switch {
+	case strings.HasSuffix(name, ".timeline"):
+		return FormatTimeline
+	case strings.HasSuffix(name, ".folded"), strings.HasSuffix(name, ".collapsed"):
+		return FormatFolded
+	case strings.HasSuffix(name, ".pprof"), strings.HasSuffix(name, ".pb"), strings.HasSuffix(name, ".prof"):
+		return FormatPprof
+	}
+
+	// gzip magic or raw protobuf means pprof, text is folded or timeline
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		return FormatPprof
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if timelineLinePattern.MatchString(line) {
+			return FormatTimeline
+		}
+		return FormatFolded
+	}
+	return FormatFolded
+}
@@ -0,0 +1,244 @@
+package flamegraph
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"math/rand"
+	"regexp"
+	"strings"
+)
+
+// Palettes supported by the renderer, matching flamegraph.pl --colors
+var Palettes = []string{"hot", "mem", "io", "wakeup", "chain", "java", "js", "perl", "red", "green", "blue", "aqua", "yellow", "purple", "orange", "kernel_user"}
+
+// BackgroundColors supported by the renderer, besides #rrggbb values
+var BackgroundColors = []string{"yellow", "blue", "green", "grey"}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// colorizer picks frame colors the same way flamegraph.pl does
+type colorizer struct {
+	palette string
+	hash    bool
+	random  *rand.Rand
+}
+
+func newColorizer(opts Options) *colorizer {
+	c := &colorizer{palette: opts.Colors, hash: opts.Hash}
+	if opts.Random {
+		c.random = rand.New(rand.NewSource(rand.Int63()))
+	}
+	return c
+}
+
+// frameColor returns the fill color of a frame
+func (c *colorizer) frameColor(name string) color.RGBA {
+	var v1, v2, v3 float64
+	switch {
+	case c.hash:
+		v1 = nameHash(name)
+		v2 = nameHash(reverse(name))
+		v3 = v2
+	case c.random != nil:
+		v1, v2, v3 = c.random.Float64(), c.random.Float64(), c.random.Float64()
+	default:
+		// Stable per name, so a function keeps its color across graphs
+		v1 = stableHash(name)
+		v2, v3 = v1, v1
+	}
+
+	palette := c.palette
+	switch palette {
+	case "kernel_user":
+		if strings.HasSuffix(name, "_[k]") {
+			return rgb(153+int(20*v1), 223+int(20*v1), 138+int(20*v1))
+		}
+		return rgb(48+int(20*v1), 209+int(20*v1), 243+int(12*v1))
+	case "hot":
+		return rgb(205+int(50*v3), int(230*v1), int(55*v2))
+	case "mem":
+		return rgb(0, 190+int(50*v2), int(210*v1))
+	case "io":
+		r := 80 + int(60*v1)
+		return rgb(r, r, 190+int(55*v2))
+	case "java":
+		palette = javaPalette(name)
+	case "perl":
+		palette = perlPalette(name)
+	case "js":
+		palette = jsPalette(name)
+	case "wakeup":
+		palette = "aqua"
+	case "chain":
+		if strings.Contains(name, "_[w]") {
+			palette = "aqua"
+		} else {
+			palette = "blue"
+		}
+	}
+
+	switch palette {
+	case "red":
+		x := 50 + int(80*v1)
+		return rgb(200+int(55*v1), x, x)
+	case "green":
+		x := 50 + int(60*v1)
+		return rgb(x, 200+int(55*v1), x)
+	case "blue":
+		x := 80 + int(60*v1)
+		return rgb(x, x, 205+int(50*v1))
+	case "yellow":
+		x := 175 + int(55*v1)
+		return rgb(x, x, 50+int(20*v1))
+	case "purple":
+		x := 190 + int(65*v1)
+		return rgb(x, 80+int(60*v1), x)
+	case "aqua":
+		return rgb(50+int(60*v1), 165+int(55*v1), 165+int(55*v1))
+	case "orange":
+		return rgb(190+int(65*v1), 90+int(65*v1), 0)
+	}
+	return rgb(0, 0, 0)
+}
+
+var javaPackagePattern = regexp.MustCompile(`^L?(java|javax|jdk|net|org|com|io|sun)/`)
+
+func javaPalette(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_[j]"), javaPackagePattern.MatchString(name), strings.Contains(name, ":::"):
+		return "green"
+	case strings.HasSuffix(name, "_[i]"):
+		return "aqua"
+	case strings.Contains(name, "::"):
+		return "yellow"
+	case strings.HasSuffix(name, "_[k]"):
+		return "orange"
+	}
+	return "red"
+}
+
+func perlPalette(name string) string {
+	switch {
+	case strings.Contains(name, "::"):
+		return "yellow"
+	case strings.Contains(name, "Perl"), strings.Contains(name, ".pl"):
+		return "green"
+	case strings.HasSuffix(name, "_[k]"):
+		return "orange"
+	}
+	return "red"
+}
+
+var jsSourcePattern = regexp.MustCompile(`/.*\.js`)
+
+func jsPalette(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_[j]"):
+		if strings.Contains(name, "/") {
+			return "green"
+		}
+		return "aqua"
+	case strings.Contains(name, "::"):
+		return "yellow"
+	case jsSourcePattern.MatchString(name), name == " ":
+		return "green"
+	case strings.Contains(name, ":"):
+		return "aqua"
+	case strings.Contains(name, "_[k]"):
+		return "orange"
+	}
+	return "red"
+}
+
+// nameHash is flamegraph.pl's namehash: a vector hash weighting early characters
+func nameHash(name string) float64 {
+	// If a module name is present, truncate it to the first character
+	if i := strings.IndexByte(name, '`'); i > 0 {
+		name = name[:1] + name[i+1:]
+	}
+
+	vector, weight, max, mod := 0.0, 1.0, 1.0, 10
+	for _, c := range []byte(name) {
+		i := int(c) % mod
+		vector += float64(i) / float64(mod-1) * weight
+		mod++
+		max += weight
+		weight *= 0.70
+		if mod > 12 {
+			break
+		}
+	}
+	return 1 - vector/max
+}
+
+// stableHash maps a name to [0, 1)
+func stableHash(name string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+func rgb(r, g, b int) color.RGBA {
+	return color.RGBA{R: uint8(clamp(r)), G: uint8(clamp(g)), B: uint8(clamp(b)), A: 0xff}
+}
+
+func clamp(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// background returns the background gradient colors, defaulting by palette
+func background(palette, bgcolors string) (color.RGBA, color.RGBA, error) {
+	if bgcolors == "" {
+		switch palette {
+		case "mem":
+			bgcolors = "green"
+		case "io", "wakeup", "chain":
+			bgcolors = "blue"
+		case "red", "green", "blue", "aqua", "yellow", "purple", "orange":
+			bgcolors = "grey"
+		default:
+			bgcolors = "yellow"
+		}
+	}
+
+	switch bgcolors {
+	case "yellow":
+		return hexColor("#eeeeee"), hexColor("#eeeeb0"), nil
+	case "blue":
+		return hexColor("#eeeeee"), hexColor("#e0e0ff"), nil
+	case "green":
+		return hexColor("#eef2ee"), hexColor("#e0ffe0"), nil
+	case "grey":
+		return hexColor("#f8f8f8"), hexColor("#e8e8e8"), nil
+	}
+	if hexColorPattern.MatchString(bgcolors) {
+		c := hexColor(bgcolors)
+		return c, c, nil
+	}
+	return color.RGBA{}, color.RGBA{}, fmt.Errorf("unrecognized background colors %q", bgcolors)
+}
+
+func hexColor(s string) color.RGBA {
+	var r, g, b uint8
+	fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b)
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
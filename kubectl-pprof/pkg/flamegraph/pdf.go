@@ -0,0 +1,122 @@
+package flamegraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RenderPDF renders the profile as a single-page vector PDF. The page is sized
+// so that the graph prints at DPI pixels per inch. Text uses the standard
+// Helvetica font, characters outside Latin-1 are replaced with '?'.
+func RenderPDF(w io.Writer, p *Profile, opts Options) error {
+	g, err := newGraph(p, opts)
+	if err != nil {
+		return err
+	}
+	bg1, bg2, err := background(g.opts.Colors, g.opts.BgColors)
+	if err != nil {
+		return err
+	}
+	colors := newColorizer(g.opts)
+
+	// Pixels to points; PDF's origin is bottom-left, so y is flipped
+	k := 72 / (BaseDPI * g.opts.scale())
+	pageW, pageH := g.width*k, g.height*k
+	x := func(v float64) float64 { return v * k }
+	y := func(v float64) float64 { return pageH - v*k }
+
+	var content bytes.Buffer
+	// Background gradient approximated with horizontal bands
+	const bands = 32
+	for i := 0; i < bands; i++ {
+		c := blend(bg1, bg2, float64(i)/(bands-1))
+		top, bottom := g.height*float64(i)/bands, g.height*float64(i+1)/bands
+		fmt.Fprintf(&content, "%s 0 %.2f %.2f %.2f re f\n", pdfColor(c), y(bottom), pageW, (bottom-top)*k+0.5)
+	}
+
+	text := func(s string, size, px, py float64, centered bool) {
+		if centered {
+			px -= pdfTextWidth(s, size/k) / 2
+		}
+		fmt.Fprintf(&content, "BT /F1 %.2f Tf %.2f %.2f Td (%s) Tj ET\n", size, x(px), y(py), pdfEscape(s))
+	}
+
+	fontSize := g.opts.FontSize * k
+	content.WriteString("0 0 0 rg\n")
+	text(g.opts.Title, (g.opts.FontSize+5)*k, g.width/2, g.titleY, true)
+	if g.opts.Subtitle != "" {
+		content.WriteString("0.63 0.63 0.63 rg\n")
+		text(g.opts.Subtitle, fontSize, g.width/2, g.subtitleY, true)
+		content.WriteString("0 0 0 rg\n")
+	}
+	text(fmt.Sprintf("%d %s", g.total, g.opts.CountName), fontSize, xPad, g.detailsY, false)
+
+	for _, b := range g.boxes {
+		fill := g.fill(b, colors)
+		fmt.Fprintf(&content, "%s %.2f %.2f %.2f %.2f re f\n", pdfColor(fill), x(b.X1), y(b.Y2), (b.X2-b.X1)*k, (b.Y2-b.Y1)*k)
+		if label := g.label(b); label != "" {
+			content.WriteString("0 0 0 rg\n")
+			text(label, fontSize, b.X1+3, (b.Y1+b.Y2)/2+g.opts.FontSize/3, false)
+		}
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageW, pageH),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		fmt.Sprintf("<< /Title (%s) /Producer (kubectl-pprof) >>", pdfEscape(g.opts.Title)),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// pdfColor returns the PDF fill color operator for c
+func pdfColor(c interface{ RGBA() (r, g, b, a uint32) }) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("%.3f %.3f %.3f rg", float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+}
+
+// pdfEscape encodes s as a WinAnsi PDF string literal body
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth estimates the width of Helvetica text in pixels
+func pdfTextWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.5
+}
@@ -0,0 +1,129 @@
+package flamegraph
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// BaseDPI is the resolution the layout is computed at, a DPI of 192 doubles the image size
+const BaseDPI = 96
+
+var (
+	goRegular     *opentype.Font
+	goRegularErr  error
+	goRegularOnce sync.Once
+)
+
+// textFace returns the bundled Go Regular font at the given pixel size.
+// FontType is not honoured for raster output, no system fonts are loaded.
+func textFace(size float64) (font.Face, error) {
+	goRegularOnce.Do(func() {
+		goRegular, goRegularErr = opentype.Parse(goregular.TTF)
+	})
+	if goRegularErr != nil {
+		return nil, fmt.Errorf("failed to load font: %w", goRegularErr)
+	}
+	return opentype.NewFace(goRegular, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// RenderPNG renders the profile as a PNG image, scaled by DPI/BaseDPI
+func RenderPNG(w io.Writer, p *Profile, opts Options) error {
+	img, err := renderImage(p, opts)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("failed to encode png: %w", err)
+	}
+	return nil
+}
+
+// renderImage rasterizes the flame graph
+func renderImage(p *Profile, opts Options) (*image.RGBA, error) {
+	g, err := newGraph(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	bg1, bg2, err := background(g.opts.Colors, g.opts.BgColors)
+	if err != nil {
+		return nil, err
+	}
+	colors := newColorizer(g.opts)
+	scale := g.opts.scale()
+
+	face, err := textFace(g.opts.FontSize * scale)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	titleFace, err := textFace((g.opts.FontSize + 5) * scale)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+
+	width, height := int(math.Ceil(g.width*scale)), int(math.Ceil(g.height*scale))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// Vertical background gradient, same stops as the SVG
+	for y := 0; y < height; y++ {
+		t := (float64(y)/float64(height) - 0.05) / 0.9
+		c := blend(bg1, bg2, math.Max(0, math.Min(1, t)))
+		draw.Draw(img, image.Rect(0, y, width, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	black := image.NewUniform(color.Black)
+	grey := image.NewUniform(color.RGBA{R: 160, G: 160, B: 160, A: 0xff})
+
+	drawText(img, titleFace, black, g.opts.Title, g.width/2*scale, g.titleY*scale, true)
+	if g.opts.Subtitle != "" {
+		drawText(img, face, grey, g.opts.Subtitle, g.width/2*scale, g.subtitleY*scale, true)
+	}
+	drawText(img, face, black, fmt.Sprintf("%d %s", g.total, g.opts.CountName), xPad*scale, g.detailsY*scale, false)
+
+	for _, b := range g.boxes {
+		fill := g.fill(b, colors)
+		rect := image.Rect(
+			int(math.Round(b.X1*scale)), int(math.Round(b.Y1*scale)),
+			int(math.Round(b.X2*scale)), int(math.Round(b.Y2*scale)))
+		if rect.Dx() == 0 {
+			rect.Max.X++
+		}
+		draw.Draw(img, rect, image.NewUniform(fill), image.Point{}, draw.Src)
+
+		if text := g.label(b); text != "" {
+			drawText(img, face, black, text, (b.X1+3)*scale, ((b.Y1+b.Y2)/2+g.opts.FontSize/3)*scale, false)
+		}
+	}
+
+	return img, nil
+}
+
+// drawText draws a single line of text with its baseline at y
+func drawText(img draw.Image, face font.Face, src image.Image, text string, x, y float64, centered bool) {
+	d := &font.Drawer{Dst: img, Src: src, Face: face}
+	if centered {
+		x -= float64(d.MeasureString(text).Round()) / 2
+	}
+	d.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	d.DrawString(text)
+}
+
+// blend linearly interpolates between two colors
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
@@ -0,0 +1,91 @@
+package flamegraph
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/pprof/profile"
+)
+
+// ParsePprof converts a pprof profile into folded stacks.
+//
+// sampleType selects the value to aggregate (e.g. "cpu", "samples",
+// "inuse_space"); empty picks the profile's default sample type, which is what
+// `go tool pprof` shows first. The returned unit names the chosen value.
+func ParsePprof(r io.Reader, sampleType string) (*Profile, string, error) {
+	return parsePprof(r, sampleType, false)
+}
+
+// ParsePprofLines is ParsePprof with frames named "function file:line" after
+// the source line of every location, like go tool pprof -lines
+func ParsePprofLines(r io.Reader, sampleType string) (*Profile, string, error) {
+	return parsePprof(r, sampleType, true)
+}
+
+func parsePprof(r io.Reader, sampleType string, lines bool) (*Profile, string, error) {
+	prof, err := profile.Parse(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse pprof profile: %w", err)
+	}
+	if len(prof.SampleType) == 0 {
+		return nil, "", fmt.Errorf("pprof profile has no sample types")
+	}
+
+	index, err := sampleIndex(prof, sampleType)
+	if err != nil {
+		return nil, "", err
+	}
+	st := prof.SampleType[index]
+
+	result := &Profile{}
+	for _, s := range prof.Sample {
+		value := s.Value[index]
+		if value <= 0 {
+			continue
+		}
+
+		// Locations are leaf first, inlined lines within a location are callee first
+		var stack []string
+		for i := len(s.Location) - 1; i >= 0; i-- {
+			loc := s.Location[i]
+			if len(loc.Line) == 0 {
+				stack = append(stack, fmt.Sprintf("0x%x", loc.Address))
+				continue
+			}
+			for j := len(loc.Line) - 1; j >= 0; j-- {
+				line := loc.Line[j]
+				name := "?"
+				if fn := line.Function; fn != nil {
+					name = fn.Name
+					if lines && fn.Filename != "" && line.Line > 0 {
+						name = fmt.Sprintf("%s %s:%d", name, fn.Filename, line.Line)
+					}
+				}
+				stack = append(stack, name)
+			}
+		}
+		result.Samples = append(result.Samples, Sample{Stack: stack, Value: value, Labels: s.Label})
+	}
+
+	return result, fmt.Sprintf("%s/%s", st.Type, st.Unit), nil
+}
+
+// sampleIndex resolves the sample type to aggregate
+func sampleIndex(prof *profile.Profile, sampleType string) (int, error) {
+	if sampleType == "" {
+		if prof.DefaultSampleType != "" {
+			sampleType = prof.DefaultSampleType
+		} else {
+			return len(prof.SampleType) - 1, nil
+		}
+	}
+
+	var available []string
+	for i, st := range prof.SampleType {
+		if st.Type == sampleType {
+			return i, nil
+		}
+		available = append(available, st.Type)
+	}
+	return 0, fmt.Errorf("sample type %q not found in profile (available: %v)", sampleType, available)
+}
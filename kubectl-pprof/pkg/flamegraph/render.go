@@ -0,0 +1,301 @@
+package flamegraph
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// Options 渲染选项，与 flamegraph.pl 的参数一一对应
+type Options struct {
+	Title      string   // Graph title
+	Subtitle   string   // Second title line
+	Colors     string   // Palette, see Palettes
+	BgColors   string   // Background, see BackgroundColors or #rrggbb
+	Width      int      // Image width in pixels
+	Height     int      // Frame height in pixels
+	FontType   string   // Font family
+	FontSize   float64  // Font size
+	Inverted   bool     // Icicle graph, root at the top
+	FlameChart bool     // Keep sample order instead of merging stacks
+	Hash       bool     // Hash-based colors, stable across graphs
+	Random     bool     // Random colors
+	MinWidth   float64  // Frames narrower than this many pixels are omitted
+	CountName  string   // Unit shown in frame details
+	DPI        int      // Raster resolution, BaseDPI renders one pixel per layout unit
+	Facts      []Fact   // Session facts listed above the graph in HTML output
+	Baseline   *Profile // Differential graph: color frames by their change in share against this profile
+	// Links the frames named "function file:line" in HTML output to the
+	// returned URL, frames it returns "" for stay plain
+	SourceURL func(function, file string, line int) string
+	// Problems listed above the graph in HTML output
+	Findings []Finding
+}
+
+// Fact 一条会话信息，例如运行时指标
+type Fact struct {
+	Name  string
+	Value string
+}
+
+// Finding 一条分析结论，HTML 中点击后用 Search 高亮相关帧
+type Finding struct {
+	Title  string
+	Detail string
+	Search string // Regex of the frames, put into the search box
+}
+
+// Defaults mirror flamegraph.pl
+const (
+	defaultWidth     = 1200
+	defaultHeight    = 16
+	defaultFontType  = "Verdana"
+	defaultFontSize  = 12
+	defaultMinWidth  = 0.1
+	defaultCountName = "samples"
+	defaultPalette   = "hot"
+
+	// Average character width relative to the font size
+	fontWidth = 0.59
+	// Horizontal padding and vertical gap between frames
+	xPad     = 10
+	framePad = 1
+)
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.Width <= 0 {
+		o.Width = defaultWidth
+	}
+	if o.Height <= 0 {
+		o.Height = defaultHeight
+	}
+	if o.FontType == "" {
+		o.FontType = defaultFontType
+	}
+	if o.FontSize <= 0 {
+		o.FontSize = defaultFontSize
+	}
+	if o.MinWidth <= 0 {
+		o.MinWidth = defaultMinWidth
+	}
+	if o.CountName == "" {
+		o.CountName = defaultCountName
+	}
+	if o.Colors == "" {
+		o.Colors = defaultPalette
+	}
+	if o.DPI <= 0 {
+		o.DPI = BaseDPI
+	}
+	if o.Title == "" {
+		if o.FlameChart {
+			o.Title = "Flame Chart"
+		} else if o.Inverted {
+			o.Title = "Icicle Graph"
+		} else {
+			o.Title = "Flame Graph"
+		}
+	}
+	return o
+}
+
+// scale returns the factor between layout units and output pixels
+func (o Options) scale() float64 {
+	return float64(o.DPI) / BaseDPI
+}
+
+// box 一个栈帧在图像中的位置
+type box struct {
+	Frame
+	X1, Y1, X2, Y2 float64
+	// Change of the frame's share against the baseline, in percentage points
+	Delta float64
+}
+
+// graph 计算好布局的火焰图，供各输出格式共用
+type graph struct {
+	opts          Options
+	total         int64
+	boxes         []box
+	width, height float64
+	titleY        float64
+	subtitleY     float64
+	detailsY      float64
+	// Largest absolute frame delta of a differential graph
+	maxDelta float64
+}
+
+// newGraph lays out the profile with the given options
+func newGraph(p *Profile, opts Options) (*graph, error) {
+	opts = opts.withDefaults()
+
+	root := BuildTree(p, opts.FlameChart)
+	if root.Value == 0 {
+		return nil, fmt.Errorf("no stack samples to render")
+	}
+	frames, _ := Layout(root)
+	var (
+		baseline      []int64
+		baselineTotal int64
+	)
+	if opts.Baseline != nil {
+		base := BuildTree(opts.Baseline, false)
+		baseline, baselineTotal = baselineValues(root, base), base.Value
+	}
+
+	// Vertical space for the title, subtitle and the details line at the bottom
+	yPad1 := opts.FontSize * 3
+	yPad2 := opts.FontSize*2 + 10
+	yPadSubtitle := 0.0
+	if opts.Subtitle != "" {
+		yPadSubtitle = opts.FontSize * 2
+	}
+
+	widthPerSample := (float64(opts.Width) - 2*xPad) / float64(root.Value)
+
+	g := &graph{opts: opts, total: root.Value, width: float64(opts.Width)}
+
+	maxDepth := 0
+	for i, f := range frames {
+		if float64(f.Value)*widthPerSample < opts.MinWidth {
+			continue
+		}
+		b := box{Frame: f}
+		if baseline != nil {
+			b.Delta = stacks.Share(f.Value, root.Value) - stacks.Share(baseline[i], baselineTotal)
+			g.maxDelta = math.Max(g.maxDelta, math.Abs(b.Delta))
+		}
+		g.boxes = append(g.boxes, b)
+		if f.Depth > maxDepth {
+			maxDepth = f.Depth
+		}
+	}
+
+	frameHeight := float64(opts.Height)
+	g.height = float64(maxDepth+1)*frameHeight + yPad1 + yPad2 + yPadSubtitle
+	g.titleY = opts.FontSize * 2
+	g.subtitleY = opts.FontSize * 4
+	g.detailsY = g.height - yPad2/2
+
+	for i := range g.boxes {
+		b := &g.boxes[i]
+		b.X1 = xPad + float64(b.Start)*widthPerSample
+		b.X2 = xPad + float64(b.Start+b.Value)*widthPerSample
+		if opts.Inverted {
+			b.Y1 = yPad1 + yPadSubtitle + float64(b.Depth)*frameHeight
+		} else {
+			b.Y1 = g.height - yPad2 - float64(b.Depth+1)*frameHeight + framePad
+		}
+		b.Y2 = b.Y1 + frameHeight - framePad
+	}
+
+	return g, nil
+}
+
+// label returns the frame text that fits its box, or "" if none fits
+func (g *graph) label(b box) string {
+	chars := int((b.X2 - b.X1) / (g.opts.FontSize * fontWidth))
+	if chars < 3 {
+		return ""
+	}
+	name := []rune(b.Name)
+	if len(name) <= chars {
+		return b.Name
+	}
+	return string(name[:chars-2]) + ".."
+}
+
+// details returns the tooltip text of a frame
+func (g *graph) details(b box) string {
+	pct := stacks.Share(b.Value, g.total)
+	return fmt.Sprintf("%s (%d %s, %.2f%%%s)", b.Name, b.Value, g.opts.CountName, pct, g.deltaDetails(b))
+}
+
+// sourceURL returns the link of a frame with a source location, if any
+func (g *graph) sourceURL(b box) string {
+	if g.opts.SourceURL == nil {
+		return ""
+	}
+	function, file, line, ok := SourceLocation(b.Name)
+	if !ok {
+		return ""
+	}
+	return g.opts.SourceURL(function, file, line)
+}
+
+// RenderSVG renders the profile as a standalone SVG image
+func RenderSVG(w io.Writer, p *Profile, opts Options) error {
+	return renderSVG(w, p, opts, false)
+}
+
+// renderSVG renders the SVG, optionally annotating frames with their position
+// so that the HTML page can compute search statistics
+func renderSVG(w io.Writer, p *Profile, opts Options, annotate bool) error {
+	g, err := newGraph(p, opts)
+	if err != nil {
+		return err
+	}
+	bg1, bg2, err := background(g.opts.Colors, g.opts.BgColors)
+	if err != nil {
+		return err
+	}
+	colors := newColorizer(g.opts)
+	o := g.opts
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" standalone="no"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg version="1.1" width="%[1]d" height="%.0[2]f" viewBox="0 0 %[1]d %.0[2]f" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+<defs>
+	<linearGradient id="background" y1="0" y2="1" x1="0" x2="0">
+		<stop stop-color="%[3]s" offset="5%%" />
+		<stop stop-color="%[4]s" offset="95%%" />
+	</linearGradient>
+</defs>
+<style type="text/css">
+	text { font-family:%[5]s; font-size:%[6]gpx; fill:rgb(0,0,0); }
+	#title { text-anchor:middle; font-size:%[7]gpx; }
+	#subtitle { text-anchor:middle; fill:rgb(160,160,160); }
+	g:hover rect { stroke:rgb(0,0,0); stroke-width:0.5; }
+</style>
+<rect x="0" y="0" width="100%%" height="100%%" fill="url(#background)" />
+`, o.Width, g.height, cssColor(bg1), cssColor(bg2), html.EscapeString(o.FontType), o.FontSize, o.FontSize+5)
+
+	fmt.Fprintf(bw, "<text id=\"title\" x=\"%.2f\" y=\"%.2f\">%s</text>\n", g.width/2, g.titleY, html.EscapeString(o.Title))
+	if o.Subtitle != "" {
+		fmt.Fprintf(bw, "<text id=\"subtitle\" x=\"%.2f\" y=\"%.2f\">%s</text>\n", g.width/2, g.subtitleY, html.EscapeString(o.Subtitle))
+	}
+	fmt.Fprintf(bw, "<text x=\"%d\" y=\"%.2f\">%s</text>\n", xPad, g.detailsY,
+		html.EscapeString(fmt.Sprintf("%d %s", g.total, o.CountName)))
+
+	for _, b := range g.boxes {
+		fill := g.fill(b, colors)
+		link := ""
+		if annotate {
+			fmt.Fprintf(bw, "<g data-depth=\"%d\" data-start=\"%d\" data-value=\"%d\">\n", b.Depth, b.Start, b.Value)
+			link = g.sourceURL(b)
+		} else {
+			bw.WriteString("<g>\n")
+		}
+		if link != "" {
+			fmt.Fprintf(bw, "<a href=\"%s\" target=\"_blank\">\n", html.EscapeString(link))
+		}
+		fmt.Fprintf(bw, "<title>%s</title><rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\" rx=\"2\" ry=\"2\" />\n",
+			html.EscapeString(g.details(b)), b.X1, b.Y1, b.X2-b.X1, b.Y2-b.Y1, cssColor(fill))
+		if text := g.label(b); text != "" {
+			fmt.Fprintf(bw, "<text x=\"%.2f\" y=\"%.2f\">%s</text>\n", b.X1+3, (b.Y1+b.Y2)/2+o.FontSize/3, html.EscapeString(text))
+		}
+		if link != "" {
+			bw.WriteString("</a>\n")
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,65 @@
+package flamegraph
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SourceLink links the frames of a Go program's own module to its source at
+// the revision it was built from. Template is a URL with {commit}, {file}
+// and {line} placeholders, e.g.
+// https://github.com/org/repo/blob/{commit}/{file}#L{line}.
+type SourceLink struct {
+	Template    string
+	Commit      string // VCS revision the binary was built from
+	Module      string // Main module path, the root of the repository
+	MainPackage string // Import path of the main package, e.g. example.com/app/cmd/server
+}
+
+// URL returns the link of a frame of the main module and "" for frames of the
+// standard library and dependencies. The file is placed from the package of
+// the function rather than from its recorded path, which is the build
+// directory of the binary unless it was built with -trimpath.
+func (l SourceLink) URL(function, file string, line int) string {
+	dir, ok := l.packageDir(functionPackage(function))
+	if !ok {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{commit}", l.Commit,
+		"{file}", path.Join(dir, path.Base(file)),
+		"{line}", strconv.Itoa(line),
+	).Replace(l.Template)
+}
+
+// packageDir returns the directory of a package of the main module relative
+// to the module root
+func (l SourceLink) packageDir(pkg string) (string, bool) {
+	if pkg == "main" {
+		pkg = l.MainPackage
+	}
+	if l.Module == "" || pkg == "" {
+		return "", false
+	}
+	if pkg == l.Module {
+		return "", true
+	}
+	rel, ok := strings.CutPrefix(pkg, l.Module+"/")
+	return rel, ok
+}
+
+// functionPackage returns the import path of a Go function name such as
+// example.com/app/store.(*DB).Query or main.main
+func functionPackage(function string) string {
+	// Type parameters may hold import paths of their own
+	if i := strings.IndexByte(function, '['); i >= 0 {
+		function = function[:i]
+	}
+	slash := strings.LastIndexByte(function, '/') + 1
+	dot := strings.IndexByte(function[slash:], '.')
+	if dot < 0 {
+		return ""
+	}
+	return function[:slash+dot]
+}
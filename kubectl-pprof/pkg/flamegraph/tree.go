@@ -0,0 +1,91 @@
+package flamegraph
+
+import "sort"
+
+// Node 调用树节点
+type Node struct {
+	Name     string
+	Value    int64
+	Children []*Node
+}
+
+// Frame 布局后的单个栈帧，Start 和 Value 以样本数为单位
+type Frame struct {
+	Name  string
+	Depth int
+	Start int64
+	Value int64
+}
+
+// BuildTree merges samples into a call tree rooted at "all".
+//
+// Flame graphs merge identical frames regardless of time and order siblings
+// alphabetically. Flame charts keep the sample order and only merge a frame
+// with its directly preceding sibling, so the x axis stays a time axis.
+func BuildTree(p *Profile, flameChart bool) *Node {
+	root := &Node{Name: "all"}
+
+	for _, sample := range p.Samples {
+		root.Value += sample.Value
+		node := root
+		for _, name := range sample.Stack {
+			node = node.child(name, flameChart)
+			node.Value += sample.Value
+		}
+	}
+
+	if !flameChart {
+		root.sortChildren()
+	}
+	return root
+}
+
+// child finds or appends the child frame with the given name
+func (n *Node) child(name string, adjacentOnly bool) *Node {
+	if adjacentOnly {
+		if len(n.Children) > 0 && n.Children[len(n.Children)-1].Name == name {
+			return n.Children[len(n.Children)-1]
+		}
+	} else {
+		for _, c := range n.Children {
+			if c.Name == name {
+				return c
+			}
+		}
+	}
+
+	c := &Node{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sortChildren orders siblings alphabetically, as flamegraph.pl does
+func (n *Node) sortChildren() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sortChildren()
+	}
+}
+
+// Layout flattens the tree into frames and returns the maximum depth
+func Layout(root *Node) ([]Frame, int) {
+	var frames []Frame
+	maxDepth := 0
+
+	var walk func(n *Node, depth int, start int64)
+	walk = func(n *Node, depth int, start int64) {
+		frames = append(frames, Frame{Name: n.Name, Depth: depth, Start: start, Value: n.Value})
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		for _, c := range n.Children {
+			walk(c, depth+1, start)
+			start += c.Value
+		}
+	}
+	walk(root, 0, 0)
+
+	return frames, maxDepth
+}
@@ -0,0 +1,129 @@
+// Package gate evaluates performance gates against a profile, so pipelines
+// can fail when hot functions or unsymbolized stacks exceed a share of the
+// samples.
+package gate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/compare"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// unknownFramePattern matches frames the profiler could not symbolize
+var unknownFramePattern = regexp.MustCompile(`^(\[unknown\]|0x[0-9a-fA-F]+)$`)
+
+// FuncLimit bounds the total share of samples of the functions matching a
+// pattern, each matching function on its own
+type FuncLimit struct {
+	// Function name, * matches any run of characters
+	Pattern string
+	// Percentage of samples
+	Max float64
+
+	re *regexp.Regexp
+}
+
+// ParseFuncLimit parses "function=20%"
+func ParseFuncLimit(value string) (FuncLimit, error) {
+	pattern, max, ok := strings.Cut(value, "=")
+	pattern = strings.TrimSpace(pattern)
+	if !ok || pattern == "" {
+		return FuncLimit{}, fmt.Errorf("invalid function limit %q, must be function=percent, e.g. runtime.gcBgMarkWorker=20%%", value)
+	}
+	limit, err := compare.ParseThreshold(max)
+	if err != nil {
+		return FuncLimit{}, fmt.Errorf("invalid function limit %q: %w", value, err)
+	}
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	return FuncLimit{Pattern: pattern, Max: limit, re: regexp.MustCompile("^" + quoted + "$")}, nil
+}
+
+// Gate is the set of limits a profile must stay within
+type Gate struct {
+	Funcs []FuncLimit
+	// Percentage of samples with an unsymbolized frame, negative to skip
+	MaxUnknown float64
+}
+
+// Check is the outcome of one limit
+type Check struct {
+	Name   string
+	Value  float64
+	Max    float64
+	Passed bool
+}
+
+// Evaluate checks the profile against every limit of the gate
+func (g Gate) Evaluate(p *flamegraph.Profile) []Check {
+	total := p.Total()
+	stats := flamegraph.Functions(p)
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var checks []Check
+	for _, limit := range g.Funcs {
+		matched := false
+		for _, name := range names {
+			if !limit.re.MatchString(name) {
+				continue
+			}
+			matched = true
+			value := stacks.Share(stats[name].Total, total)
+			checks = append(checks, Check{Name: name, Value: value, Max: limit.Max, Passed: value <= limit.Max})
+		}
+		// An absent function takes no samples, it passes
+		if !matched {
+			checks = append(checks, Check{Name: limit.Pattern, Max: limit.Max, Passed: true})
+		}
+	}
+
+	if g.MaxUnknown >= 0 {
+		var unknown int64
+		for _, sample := range p.Samples {
+			for _, frame := range sample.Stack {
+				if unknownFramePattern.MatchString(frame) {
+					unknown += sample.Value
+					break
+				}
+			}
+		}
+		value := stacks.Share(unknown, total)
+		checks = append(checks, Check{Name: "unknown frames", Value: value, Max: g.MaxUnknown, Passed: value <= g.MaxUnknown})
+	}
+	return checks
+}
+
+// Failed counts the checks that did not pass
+func Failed(checks []Check) int {
+	failed := 0
+	for _, c := range checks {
+		if !c.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteChecks writes one line per check
+func WriteChecks(w io.Writer, checks []Check) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-6s %9s %9s  %s\n", "RESULT", "SHARE", "MAX", "CHECK")
+	for _, c := range checks {
+		result := "PASS"
+		if !c.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(&b, "%-6s %8.2f%% %8.2f%%  %s\n", result, c.Value, c.Max, c.Name)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
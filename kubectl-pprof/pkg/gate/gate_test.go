@@ -0,0 +1,148 @@
+package gate_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/gate"
+)
+
+func TestParseFuncLimit(t *testing.T) {
+	tests := []struct {
+		value       string
+		wantPattern string
+		wantMax     float64
+		wantErr     bool
+	}{
+		{value: "runtime.gcBgMarkWorker=20%", wantPattern: "runtime.gcBgMarkWorker", wantMax: 20},
+		{value: " runtime.* = 12.5 ", wantPattern: "runtime.*", wantMax: 12.5},
+		{value: "main.main=0", wantPattern: "main.main", wantMax: 0},
+		{value: "main.main", wantErr: true},
+		{value: "=20%", wantErr: true},
+		{value: "main.main=", wantErr: true},
+		{value: "main.main=120%", wantErr: true},
+		{value: "main.main=-1%", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, err := gate.ParseFuncLimit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseFuncLimit(%q) = %+v, want an error", tt.value, limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFuncLimit(%q): %v", tt.value, err)
+			}
+			if limit.Pattern != tt.wantPattern || limit.Max != tt.wantMax {
+				t.Errorf("ParseFuncLimit(%q) = %q at %g%%, want %q at %g%%", tt.value, limit.Pattern, limit.Max, tt.wantPattern, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	// 100 samples: runtime.mallocgc in 30, reached twice through recursion in
+	// 10 of them, and 5 samples with an unsymbolized frame
+	profile, err := flamegraph.ParseFolded(strings.NewReader(`main.main;api.handle 50
+main.main;api.handle;runtime.mallocgc 20
+main.main;runtime.mallocgc;runtime.gc;runtime.mallocgc 10
+main.main;[unknown] 3
+main.main;0x7f3a2b1c 2
+runtime.gcBgMarkWorker 15
+`))
+	if err != nil {
+		t.Fatalf("ParseFolded: %v", err)
+	}
+	limit := func(value string) gate.FuncLimit {
+		l, err := gate.ParseFuncLimit(value)
+		if err != nil {
+			t.Fatalf("ParseFuncLimit(%q): %v", value, err)
+		}
+		return l
+	}
+
+	tests := []struct {
+		name string
+		gate gate.Gate
+		want []gate.Check
+	}{
+		{
+			name: "function within its limit",
+			gate: gate.Gate{Funcs: []gate.FuncLimit{limit("runtime.mallocgc=30%")}, MaxUnknown: -1},
+			want: []gate.Check{{Name: "runtime.mallocgc", Value: 30, Max: 30, Passed: true}},
+		},
+		{
+			name: "function over its limit",
+			gate: gate.Gate{Funcs: []gate.FuncLimit{limit("api.handle=50%")}, MaxUnknown: -1},
+			want: []gate.Check{{Name: "api.handle", Value: 70, Max: 50, Passed: false}},
+		},
+		{
+			name: "pattern checks every matching function",
+			gate: gate.Gate{Funcs: []gate.FuncLimit{limit("runtime.*=20%")}, MaxUnknown: -1},
+			want: []gate.Check{
+				{Name: "runtime.gc", Value: 10, Max: 20, Passed: true},
+				{Name: "runtime.gcBgMarkWorker", Value: 15, Max: 20, Passed: true},
+				{Name: "runtime.mallocgc", Value: 30, Max: 20, Passed: false},
+			},
+		},
+		{
+			name: "absent function passes",
+			gate: gate.Gate{Funcs: []gate.FuncLimit{limit("compress/gzip.*=1%")}, MaxUnknown: -1},
+			want: []gate.Check{{Name: "compress/gzip.*", Max: 1, Passed: true}},
+		},
+		{
+			name: "unknown frames over the limit",
+			gate: gate.Gate{MaxUnknown: 2},
+			want: []gate.Check{{Name: "unknown frames", Value: 5, Max: 2, Passed: false}},
+		},
+		{
+			name: "unknown frames within the limit",
+			gate: gate.Gate{MaxUnknown: 5},
+			want: []gate.Check{{Name: "unknown frames", Value: 5, Max: 5, Passed: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.gate.Evaluate(profile)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateEmptyProfile(t *testing.T) {
+	l, err := gate.ParseFuncLimit("main.main=0%")
+	if err != nil {
+		t.Fatalf("ParseFuncLimit: %v", err)
+	}
+	checks := gate.Gate{Funcs: []gate.FuncLimit{l}, MaxUnknown: 0}.Evaluate(&flamegraph.Profile{})
+	if failed := gate.Failed(checks); failed != 0 {
+		t.Errorf("%d checks failed on an empty profile: %+v", failed, checks)
+	}
+}
+
+func TestWriteChecks(t *testing.T) {
+	checks := []gate.Check{
+		{Name: "runtime.mallocgc", Value: 30, Max: 20, Passed: false},
+		{Name: "unknown frames", Value: 1.5, Max: 5, Passed: true},
+	}
+	if failed := gate.Failed(checks); failed != 1 {
+		t.Errorf("Failed = %d, want 1", failed)
+	}
+	var b strings.Builder
+	if err := gate.WriteChecks(&b, checks); err != nil {
+		t.Fatalf("WriteChecks: %v", err)
+	}
+	want := `RESULT     SHARE       MAX  CHECK
+FAIL      30.00%    20.00%  runtime.mallocgc
+PASS       1.50%     5.00%  unknown frames
+`
+	if b.String() != want {
+		t.Errorf("WriteChecks wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
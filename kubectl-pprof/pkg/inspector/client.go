@@ -0,0 +1,144 @@
+package inspector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// target a debuggable context /json/list reports
+type target struct {
+	Type                 string `json:"type"`
+	Title                string `json:"title"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+// message a request, response or event of the inspector protocol
+type message struct {
+	ID     int             `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params interface{}     `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// CPUProfile runs the V8 CPU profiler of the process behind a forwarded
+// inspector port for duration, sampling every interval, and returns the
+// profile as the .cpuprofile JSON Chrome DevTools opens
+func CPUProfile(ctx context.Context, localPort uint16, duration, interval time.Duration) ([]byte, error) {
+	debuggerURL, err := debuggerURL(ctx, localPort)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, debuggerURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the inspector: %w", err)
+	}
+	defer conn.Close()
+	// Unblock reads once the session is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	s := &session{conn: conn}
+	if _, err := s.call("Profiler.enable", nil); err != nil {
+		return nil, err
+	}
+	defer s.call("Profiler.disable", nil)
+	if _, err := s.call("Profiler.setSamplingInterval", map[string]int64{"interval": interval.Microseconds()}); err != nil {
+		return nil, err
+	}
+	if _, err := s.call("Profiler.start", nil); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-time.After(duration):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	result, err := s.call("Profiler.stop", nil)
+	if err != nil {
+		return nil, err
+	}
+	var stopped struct {
+		Profile json.RawMessage `json:"profile"`
+	}
+	if err := json.Unmarshal(result, &stopped); err != nil || len(stopped.Profile) == 0 {
+		return nil, fmt.Errorf("inspector returned no CPU profile")
+	}
+	return stopped.Profile, nil
+}
+
+// debuggerURL finds the WebSocket of the main context through the forwarded
+// port. node reports it with the address it listens on, which is only
+// reachable inside the pod, so the host is pointed at the forward.
+func debuggerURL(ctx context.Context, localPort uint16) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/json/list", localPort), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to list inspector targets: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("failed to list inspector targets: %s: %s", resp.Status, body)
+	}
+
+	var targets []target
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return "", fmt.Errorf("failed to decode inspector targets: %w", err)
+	}
+	for _, t := range targets {
+		if t.WebSocketDebuggerURL == "" {
+			continue
+		}
+		u, err := url.Parse(t.WebSocketDebuggerURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid inspector WebSocket URL %q: %w", t.WebSocketDebuggerURL, err)
+		}
+		u.Host = fmt.Sprintf("127.0.0.1:%d", localPort)
+		return u.String(), nil
+	}
+	return "", fmt.Errorf("the inspector has no debuggable target, is another debugger attached?")
+}
+
+// session numbers the requests of one inspector connection
+type session struct {
+	conn   *websocket.Conn
+	nextID int
+}
+
+// call sends a request and waits for its response, skipping the events in between
+func (s *session) call(method string, params interface{}) (json.RawMessage, error) {
+	s.nextID++
+	if err := s.conn.WriteJSON(message{ID: s.nextID, Method: method, Params: params}); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", method, err)
+	}
+	for {
+		var resp message
+		if err := s.conn.ReadJSON(&resp); err != nil {
+			return nil, fmt.Errorf("failed to read the response to %s: %w", method, err)
+		}
+		if resp.ID != s.nextID {
+			continue
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s failed: %s", method, resp.Error.Message)
+		}
+		return resp.Result, nil
+	}
+}
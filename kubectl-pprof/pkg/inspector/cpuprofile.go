@@ -0,0 +1,83 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// cpuProfile the V8 CPU profile format, a call tree with the node every
+// sample hit
+type cpuProfile struct {
+	Nodes []struct {
+		ID        int `json:"id"`
+		CallFrame struct {
+			FunctionName string `json:"functionName"`
+			URL          string `json:"url"`
+			LineNumber   int    `json:"lineNumber"` // 0-based
+		} `json:"callFrame"`
+		HitCount int64 `json:"hitCount"`
+		Children []int `json:"children"`
+	} `json:"nodes"`
+	Samples []int `json:"samples"`
+}
+
+// ParseCPUProfile turns a .cpuprofile into stacks for the flame graph. Frames
+// are named "function file:line" so the js palette tells JavaScript from the
+// runtime; the (program), (idle) and (garbage collector) pseudo frames stay.
+func ParseCPUProfile(data []byte) (*flamegraph.Profile, error) {
+	var cpu cpuProfile
+	if err := json.Unmarshal(data, &cpu); err != nil {
+		return nil, fmt.Errorf("failed to parse CPU profile: %w", err)
+	}
+
+	parents := make(map[int]int, len(cpu.Nodes))
+	names := make(map[int]string, len(cpu.Nodes))
+	hits := make(map[int]int64, len(cpu.Nodes))
+	for _, node := range cpu.Nodes {
+		for _, child := range node.Children {
+			parents[child] = node.ID
+		}
+		frame := node.CallFrame
+		name := frame.FunctionName
+		if name == "" {
+			name = "(anonymous)"
+		}
+		if frame.URL != "" {
+			name = fmt.Sprintf("%s %s:%d", name, path.Base(frame.URL), frame.LineNumber+1)
+		}
+		names[node.ID] = name
+		hits[node.ID] = node.HitCount
+	}
+	// The sample list is authoritative, older profiles only count hits
+	if len(cpu.Samples) > 0 {
+		hits = make(map[int]int64, len(cpu.Nodes))
+		for _, id := range cpu.Samples {
+			hits[id]++
+		}
+	}
+
+	profile := &flamegraph.Profile{}
+	for _, node := range cpu.Nodes {
+		if hits[node.ID] == 0 {
+			continue
+		}
+		var stack []string
+		for id, ok := node.ID, true; ok; id, ok = parents[id] {
+			if names[id] != "(root)" {
+				stack = append(stack, names[id])
+			}
+		}
+		if len(stack) == 0 {
+			continue
+		}
+		// Walked from the leaf, the flame graph wants the root first
+		for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+			stack[i], stack[j] = stack[j], stack[i]
+		}
+		profile.Samples = append(profile.Samples, flamegraph.Sample{Stack: stack, Value: hits[node.ID]})
+	}
+	return profile, nil
+}
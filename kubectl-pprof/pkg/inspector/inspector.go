@@ -0,0 +1,116 @@
+// Package inspector profiles Node.js processes with the V8 CPU profiler
+// through the inspector protocol, reached with a port-forward like the pprof
+// endpoint of Go programs.
+package inspector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+)
+
+// PortAnnotation points kubectl-pprof at the inspector port of a pod
+const PortAnnotation = "kubectl-pprof.io/inspector-port"
+
+// DefaultPort is where node listens with a bare --inspect
+const DefaultPort = 9229
+
+// inspectFlags enable the inspector, optionally with [host:]port
+var inspectFlags = []string{"--inspect", "--inspect-brk", "--inspect-wait"}
+
+// Endpoint the inspector of a Node.js container
+type Endpoint struct {
+	Port   int32
+	Reason string // How the port was found
+}
+
+// Detect finds the inspector of a container. An explicit port wins over the
+// pod annotation, which wins over the --inspect flags node was started with,
+// on its command line or in NODE_OPTIONS.
+func Detect(pod *corev1.Pod, container *corev1.Container, port int32) (*Endpoint, error) {
+	if port != 0 {
+		return &Endpoint{Port: port, Reason: "set with --inspect-port"}, nil
+	}
+	if value := pod.Annotations[PortAnnotation]; value != "" {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, errors.NewValidationError(
+				fmt.Sprintf("invalid %s annotation %q", PortAnnotation, value),
+				"Set it to the port node listens for the inspector on",
+			)
+		}
+		return &Endpoint{Port: int32(n), Reason: fmt.Sprintf("named by the %s annotation", PortAnnotation)}, nil
+	}
+
+	args := append(append([]string{}, container.Command...), container.Args...)
+	for _, env := range container.Env {
+		if env.Name == "NODE_OPTIONS" {
+			args = append(args, strings.Fields(env.Value)...)
+		}
+	}
+	if port, flag, ok := inspectPort(args); ok {
+		return &Endpoint{Port: port, Reason: fmt.Sprintf("node runs with %s", flag)}, nil
+	}
+	return nil, errors.NewValidationError(
+		fmt.Sprintf("container %s does not run node with the inspector enabled", container.Name),
+		"Start node with --inspect, on its command line or in NODE_OPTIONS",
+		fmt.Sprintf("Name the port with --inspect-port or the %s pod annotation", PortAnnotation),
+		"Use --attach perf to sample with perf instead",
+	)
+}
+
+// inspectPort returns the port the --inspect flags among args listen on.
+// --inspect-port overrides the port of the others, which take [host:]port.
+func inspectPort(args []string) (int32, string, bool) {
+	var (
+		port, override int32
+		flag           string
+	)
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case name == "--inspect-port":
+			if !hasValue && i+1 < len(args) {
+				value = args[i+1]
+			}
+			override = parseHostPort(value)
+		case contains(inspectFlags, name):
+			flag, port = arg, DefaultPort
+			if p := parseHostPort(value); hasValue && p != 0 {
+				port = p
+			}
+		}
+	}
+	if flag == "" {
+		return 0, "", false
+	}
+	if override != 0 {
+		port = override
+	}
+	return port, flag, true
+}
+
+// parseHostPort reads the port of a [host:]port value, 0 when it names only a host
+func parseHostPort(value string) int32 {
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		value = value[i+1:]
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 1 || n > 65535 {
+		return 0
+	}
+	return int32(n)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
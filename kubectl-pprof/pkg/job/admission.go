@@ -0,0 +1,68 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// AdmissionRejectedError reports that the cluster refused to run the
+// profiler, as GKE Autopilot, Pod Security Admission or a policy engine such
+// as Gatekeeper or Kyverno do for privileged, hostPID or extra capabilities
+type AdmissionRejectedError struct {
+	Mode    api.ProfileMode // How the profiler was to be run
+	Name    string          // Job or ephemeral container refused
+	Message string          // Reason given by the admission chain
+}
+
+func (e *AdmissionRejectedError) Error() string {
+	return fmt.Sprintf("the cluster refused the profiler (%s %s): %s", e.Mode, e.Name, e.Message)
+}
+
+// admissionRejection returns the rejection of a create or update request by
+// admission, nil for other errors. RBAC denials are Forbidden as well but
+// name the verb the user cannot perform.
+func admissionRejection(mode api.ProfileMode, name string, err error) *AdmissionRejectedError {
+	if !apierrors.IsForbidden(err) {
+		return nil
+	}
+	message := err.Error()
+	if strings.Contains(message, "cannot create resource") || strings.Contains(message, "cannot update resource") {
+		return nil
+	}
+	return &AdmissionRejectedError{Mode: mode, Name: name, Message: message}
+}
+
+// podRejection looks for the Job controller failing to create the profiler
+// pod because admission refused it, which only shows in a FailedCreate event
+// of the Job while it waits without pods
+func (m *Manager) podRejection(ctx context.Context, jobName, namespace string) *AdmissionRejectedError {
+	events, err := m.k8sConfig.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Job,involvedObject.name=" + jobName + ",reason=FailedCreate",
+	})
+	if err != nil {
+		return nil
+	}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Name != jobName || event.Reason != "FailedCreate" {
+			continue
+		}
+		if strings.Contains(event.Message, "forbidden") {
+			return &AdmissionRejectedError{Mode: api.ModeJob, Name: jobName, Message: event.Message}
+		}
+	}
+	return nil
+}
+
+// jobHasPods reports whether the Job controller created a pod for the Job
+func (m *Manager) jobHasPods(ctx context.Context, jobName, namespace string) bool {
+	pods, err := m.k8sConfig.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	return err != nil || len(pods.Items) > 0
+}
@@ -0,0 +1,13 @@
+package job
+
+import (
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+)
+
+// apiError converts an error of the Kubernetes API into a structured error
+// with suggestions, the message formatted like fmt.Sprintf
+func apiError(err error, format string, args ...any) error {
+	return errors.FromKubernetes(err, fmt.Sprintf(format, args...))
+}
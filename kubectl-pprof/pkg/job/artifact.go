@@ -0,0 +1,381 @@
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Artifacts emitted by the job script. Each one is written to the logs as
+// "<NAME>_CHUNK:<index>:<length>:<base64>" lines of at most
+// launcher.ChunkSize characters, followed by "<NAME>_END:<chunks>", the
+// payload gzip or zstd compressed, told apart by its magic number. The
+// length verifies every chunk, so a download cut short resumes from the last
+// chunk received instead of profiling again.
+const (
+	flameGraphArtifact = "FLAMEGRAPH"
+	foldedArtifact     = "FOLDED"
+	timelineArtifact   = "TIMELINE"
+	schedLatArtifact   = "SCHEDLAT"
+	netArtifact        = "NET"
+)
+
+// Paths where golang-profiling writes extra artifacts inside the job pod
+const (
+	foldedPodPath   = "/tmp/profile.folded"
+	timelinePodPath = "/tmp/profile.timeline"
+	schedLatPodPath = "/tmp/profile.schedlat"
+	netPodPath      = "/tmp/profile.net"
+)
+
+// artifactFile is an artifact and the path the profiler writes it to
+type artifactFile struct {
+	name string
+	path string
+}
+
+// optionalArtifacts lists the artifacts the profiler writes besides the flame
+// graph, by the options of the session
+func optionalArtifacts(cfg *api.ProfileConfig) []artifactFile {
+	var artifacts []artifactFile
+	if exportsFolded(cfg) {
+		artifacts = append(artifacts, artifactFile{foldedArtifact, foldedPodPath})
+	}
+	if exportsTimeline(cfg) {
+		artifacts = append(artifacts, artifactFile{timelineArtifact, timelinePodPath})
+	}
+	if cfg.ProfileType == api.ProfileTypeSchedLat {
+		artifacts = append(artifacts, artifactFile{schedLatArtifact, schedLatPodPath})
+	}
+	if cfg.ProfileType == api.ProfileTypeNet {
+		artifacts = append(artifacts, artifactFile{netArtifact, netPodPath})
+	}
+	return artifacts
+}
+
+// buildArtifactScript builds the shell snippet that emits a file as a log
+// artifact. zstd falls back to gzip when the image has no zstd binary.
+func buildArtifactScript(name, path, compression string) string {
+	compress := fmt.Sprintf("gzip -c %s", path)
+	if compression == api.CompressionZstd {
+		compress = fmt.Sprintf("{ if command -v zstd >/dev/null 2>&1; then zstd -q -c %[1]s; else gzip -c %[1]s; fi; }", path)
+	}
+	return fmt.Sprintf(`
+			%[2]s | base64 -w %[3]d | awk '{ printf "%[1]s_CHUNK:%%d:%%d:%%s\n", NR-1, length($0), $0 } END { printf "%[1]s_END:%%d\n", NR }'
+	`, name, compress, launcher.ChunkSize)
+}
+
+// buildOptionalArtifactScript emits a file as a log artifact only if the profiler wrote it
+func buildOptionalArtifactScript(name, path, compression string) string {
+	return `
+			if [ -s ` + path + ` ]; then` + buildArtifactScript(name, path, compression) + `
+			else
+				echo "Warning: no ` + strings.ToLower(name) + ` data written to ` + path + `"
+			fi
+	`
+}
+
+// maxArtifactResumes bounds how often an interrupted artifact download is
+// resumed before giving up
+const maxArtifactResumes = 5
+
+// extractArtifactFromLogs extracts and decodes a named artifact from the job
+// logs, unless it was fetched from the launcher already. When the log stream breaks off mid-artifact, the logs are reopened
+// from the time of the last chunk received and the chunks read so far kept.
+func (m *Manager) extractArtifactFromLogs(ctx context.Context, jobName, namespace, name string) ([]byte, error) {
+	if data, ok := m.served.get(jobName, name); ok {
+		return data, nil
+	}
+
+	artifact := newArtifactReader(name)
+	logOpts := corev1.PodLogOptions{Timestamps: true}
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		logs, err := m.openJobLogsWith(ctx, jobName, namespace, logOpts)
+		if err != nil {
+			return nil, err
+		}
+		readErr := artifact.read(logs)
+		logs.Close()
+
+		if artifact.complete() {
+			return artifact.decode()
+		}
+		if readErr == nil {
+			return nil, artifact.missing()
+		}
+		if attempt == maxArtifactResumes || ctx.Err() != nil {
+			return nil, fmt.Errorf("error reading logs: %w", readErr)
+		}
+
+		// Log timestamps have a second precision once truncated, the chunks
+		// read again are skipped
+		if !artifact.lastSeen.IsZero() {
+			logOpts.SinceTime = &metav1.Time{Time: artifact.lastSeen.Truncate(time.Second)}
+		}
+		m.logger.Warn("Artifact download interrupted, resuming", "artifact", artifact.label, "chunks", len(artifact.chunks), "error", readErr)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// decodeArtifact scans a log stream for the named artifact, printed in chunks
+// by the job script or as status records by the launcher
+func decodeArtifact(logs io.Reader, name string) ([]byte, error) {
+	artifact := newArtifactReader(name)
+	if err := artifact.read(logs); err != nil {
+		return nil, fmt.Errorf("error reading logs: %w", err)
+	}
+	if !artifact.complete() {
+		return nil, artifact.missing()
+	}
+	return artifact.decode()
+}
+
+// artifactReader assembles an artifact from its chunks, which may be read
+// over several log streams and more than once
+type artifactReader struct {
+	name  string
+	label string
+	// chunks holds the verified chunks by index
+	chunks map[int]string
+	// total is the number of chunks, -1 until the end of the artifact is read
+	total int
+	// lastSeen is the log timestamp of the last chunk read
+	lastSeen time.Time
+
+	// legacy collects the single payload of "<NAME>_START:" artifacts
+	legacy   strings.Builder
+	inLegacy bool
+}
+
+func newArtifactReader(name string) *artifactReader {
+	return &artifactReader{name: name, label: strings.ToLower(name), chunks: make(map[int]string), total: -1}
+}
+
+// read adds the chunks of a log stream until the artifact is complete or the
+// stream ends, returning the error the stream ended with if not io.EOF.
+// Lines are read without a length limit, the base64 payload of a large
+// profile easily exceeds the default bufio.Scanner token size.
+func (a *artifactReader) read(logs io.Reader) error {
+	reader := bufio.NewReader(logs)
+	for !a.complete() {
+		line, err := reader.ReadString('\n')
+		if line != "" && (err == nil || err == io.EOF) {
+			a.add(strings.TrimRight(line, "\r\n"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add reads one log line, prefixed with its timestamp when the logs were
+// opened with timestamps
+func (a *artifactReader) add(line string) {
+	var ts time.Time
+	if prefix, rest, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, prefix); err == nil {
+			ts, line = t, rest
+		}
+	}
+
+	// The launcher of --direct-exec runs prints artifacts as status records
+	if status, ok := launcher.ParseStatus(line); ok {
+		if status.Type != launcher.StatusArtifact || status.Name != a.name {
+			return
+		}
+		if status.Chunks == 0 {
+			a.setChunk(0, 1, status.Data, ts)
+		} else {
+			a.setChunk(status.Chunk, status.Chunks, status.Data, ts)
+		}
+		return
+	}
+
+	chunkMarker := a.name + "_CHUNK:"
+	endMarker := a.name + "_END"
+	switch {
+	case strings.HasPrefix(line, chunkMarker):
+		fields := strings.SplitN(strings.TrimPrefix(line, chunkMarker), ":", 3)
+		if len(fields) != 3 {
+			return
+		}
+		index, err1 := strconv.Atoi(fields[0])
+		length, err2 := strconv.Atoi(fields[1])
+		// A chunk cut short is dropped and read again on resume
+		if err1 != nil || err2 != nil || len(fields[2]) != length {
+			return
+		}
+		a.setChunk(index, a.total, fields[2], ts)
+	case strings.HasPrefix(line, endMarker+":"):
+		if total, err := strconv.Atoi(strings.TrimPrefix(line, endMarker+":")); err == nil {
+			a.total = total
+		}
+	case strings.HasPrefix(line, a.name+"_START:"):
+		// Logs of runs made before artifacts were chunked
+		a.inLegacy = true
+		a.legacy.WriteString(strings.TrimPrefix(line, a.name+"_START:"))
+	case line == endMarker && a.inLegacy:
+		a.inLegacy = false
+		a.setChunk(0, 1, strings.TrimSpace(a.legacy.String()), ts)
+	case a.inLegacy:
+		a.legacy.WriteString(line)
+	}
+}
+
+// setChunk records a verified chunk, keeping the first copy of chunks read
+// again after resuming
+func (a *artifactReader) setChunk(index, total int, data string, ts time.Time) {
+	if index < 0 || (total >= 0 && index >= total) {
+		return
+	}
+	if _, ok := a.chunks[index]; !ok {
+		a.chunks[index] = data
+	}
+	if total >= 0 {
+		a.total = total
+	}
+	if !ts.IsZero() {
+		a.lastSeen = ts
+	}
+}
+
+// complete reports whether every chunk of the artifact was read
+func (a *artifactReader) complete() bool {
+	return a.total >= 0 && len(a.chunks) == a.total
+}
+
+// missing describes why the artifact could not be assembled
+func (a *artifactReader) missing() error {
+	if a.total < 0 && len(a.chunks) == 0 {
+		return fmt.Errorf("no %s content found in logs", a.label)
+	}
+	if a.total < 0 {
+		return fmt.Errorf("%s content in logs is incomplete, %d chunks read", a.label, len(a.chunks))
+	}
+	return fmt.Errorf("%s content in logs is incomplete, %d of %d chunks read", a.label, len(a.chunks), a.total)
+}
+
+// decode joins the chunks and decodes the payload
+func (a *artifactReader) decode() ([]byte, error) {
+	var encoded strings.Builder
+	for i := 0; i < a.total; i++ {
+		encoded.WriteString(a.chunks[i])
+	}
+	if encoded.Len() == 0 {
+		return nil, fmt.Errorf("no %s content found in logs", a.label)
+	}
+	return decodePayload(encoded.String(), a.label)
+}
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// decodePayload decodes the base64 payload of an artifact, gzip or zstd
+// compressed whatever the session asked for, as the image may lack zstd
+func decodePayload(encoded, label string) ([]byte, error) {
+	// Decode base64
+	decodedData, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s base64 content: %w", label, err)
+	}
+	return decompressPayload(decodedData, label)
+}
+
+// decompressPayload decompresses an artifact, told gzip or zstd by its magic
+// number
+func decompressPayload(decodedData []byte, label string) ([]byte, error) {
+	if bytes.HasPrefix(decodedData, zstdMagic) {
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		defer decoder.Close()
+
+		data, err := decoder.DecodeAll(decodedData, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s content: %w", label, err)
+		}
+		return data, nil
+	}
+
+	// Decompress gzip
+	gzipReader, err := gzip.NewReader(bytes.NewReader(decodedData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzipReader.Close()
+
+	data, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s content: %w", label, err)
+	}
+
+	return data, nil
+}
+
+// LogArtifacts extracts the artifacts of runs from profiler logs read
+// elsewhere than the cluster, such as a recorded session
+type LogArtifacts struct {
+	// Open returns the profiler logs of a run
+	Open func(jobName, namespace string) (io.ReadCloser, error)
+}
+
+// extract decodes a named artifact from the logs of a run
+func (l *LogArtifacts) extract(jobName, namespace, name string) ([]byte, error) {
+	logs, err := l.Open(jobName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+
+	return decodeArtifact(logs, name)
+}
+
+// ExtractFlameGraphFromLogs extracts the flame graph of a run
+func (l *LogArtifacts) ExtractFlameGraphFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return l.extract(jobName, namespace, flameGraphArtifact)
+}
+
+// ExtractFoldedFromLogs extracts the folded stacks of a run
+func (l *LogArtifacts) ExtractFoldedFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return l.extract(jobName, namespace, foldedArtifact)
+}
+
+// ExtractTimelineFromLogs extracts the time-ordered stacks of a run
+func (l *LogArtifacts) ExtractTimelineFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return l.extract(jobName, namespace, timelineArtifact)
+}
+
+// ExtractNetFromLogs extracts the per endpoint network statistics of a run
+func (l *LogArtifacts) ExtractNetFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return l.extract(jobName, namespace, netArtifact)
+}
+
+// ExtractSchedLatencyFromLogs extracts the scheduling latency histogram of a run
+func (l *LogArtifacts) ExtractSchedLatencyFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return l.extract(jobName, namespace, schedLatArtifact)
+}
@@ -0,0 +1,48 @@
+package job
+
+import (
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// asyncProfilerBackend samples JVMs with async-profiler, which attaches to
+// the target through /proc/<pid>/root and so reaches JVMs in any container
+type asyncProfilerBackend struct{}
+
+func (asyncProfilerBackend) Name() string {
+	return "async-profiler"
+}
+
+func (asyncProfilerBackend) BuildArgs(cfg *api.ProfileConfig) []string {
+	event := "cpu"
+	if cfg.GoOptions != nil && cfg.GoOptions.OffCPU {
+		event = "wall"
+	}
+	// async-profiler takes the sampling interval in nanoseconds
+	interval := int64(1e9) / int64(backendFrequency(cfg))
+	return []string{"-e", event, "-i", fmt.Sprintf("%d", interval)}
+}
+
+func (b asyncProfilerBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	return singlePIDWarning("async-profiler") + fmt.Sprintf(`
+		echo "Starting async-profiler with arguments: -d %[2]d %[3]s -o collapsed $%[1]s"
+		/opt/async-profiler/bin/asprof -d %[2]d %[3]s -o collapsed -f %[4]s $%[1]s
+		PROFILE_EXIT_CODE=$?
+	`, pidVar, int(cfg.Duration.Seconds()), shellJoin(b.BuildArgs(cfg)), api.BackendFoldedPath) + renderFoldedScript(cfg)
+}
+
+func (asyncProfilerBackend) RequiredMounts() []api.HostMount {
+	return nil
+}
+
+// RequiredCapabilities lists what attaching to a JVM of another user and
+// sampling with perf events take
+func (asyncProfilerBackend) RequiredCapabilities() []string {
+	return []string{"SYS_PTRACE", "PERFMON", "SYS_ADMIN"}
+}
+
+// ParseOutput reads the sample summary the job script computes from the folded stacks
+func (asyncProfilerBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	return parseSampleOutput(logs)
+}
@@ -0,0 +1,165 @@
+package job
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// NewLanguageManager returns a language manager with the Backend of every
+// language the profiler image can sample registered. Node.js processes
+// listening for the inspector are profiled through it, without a Job.
+func NewLanguageManager() *api.LanguageManager {
+	lm := api.NewLanguageManager()
+	lm.RegisterBackend(api.LanguageGo, golangBackend{})
+	lm.RegisterBackend(api.LanguageJava, asyncProfilerBackend{})
+	lm.RegisterBackend(api.LanguagePython, pySpyBackend{})
+	lm.RegisterBackend(api.LanguageNode, nodePerfBackend{perfBackend{unwind: api.UnwindFP}})
+	lm.RegisterBackend(api.LanguageRust, perfBackend{unwind: api.UnwindDWARF})
+	return lm
+}
+
+// backend returns the Backend profiling the language of the session, one
+// detecting it in the job when unset or auto, and how its sampling differs
+// when it fell back to perf. Custom languages of the options take part.
+func (m *Manager) backend(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (api.Backend, string, error) {
+	languages := m.languages
+	if opts != nil && opts.Languages != nil {
+		languages = opts.Languages
+	}
+	lang, err := languages.ParseLanguage(cfg.Language)
+	if err != nil {
+		return nil, "", err
+	}
+	if lang == api.LanguageAuto {
+		auto, err := m.autoBackend(cfg, target)
+		return auto, "", err
+	}
+	backend, err := languages.GetBackend(lang)
+	if err != nil {
+		return nil, "", err
+	}
+	backend, methodology := perfFallback(cfg, target, backend)
+	return backend, methodology, nil
+}
+
+// completeBackendOutput fills in what the backend leaves to the job manager,
+// the tool and language of a session that named them, and logs a language
+// the job detected
+func completeBackendOutput(output *api.BackendOutput, backend api.Backend, methodology string, cfg *api.ProfileConfig, opts *api.ProfileOptions) {
+	if output.Backend == "" {
+		output.Backend = backend.Name()
+	}
+	if output.Methodology == "" {
+		output.Methodology = methodology
+	}
+	if output.Detection != "" {
+		opts.Log().Info("Detected the target language", "language", output.Language, "from", output.Detection, "backend", output.Backend)
+		return
+	}
+	if lang, err := opts.Languages.ParseLanguage(cfg.Language); err == nil && lang != api.LanguageAuto && output.Language == "" {
+		output.Language = lang
+	}
+}
+
+// backendFrequency returns the sampling frequency of a tool sampling on a
+// timer, traced profile types sample nothing but fall back to the default
+func backendFrequency(cfg *api.ProfileConfig) int {
+	if frequency := cfg.SamplingFrequency(); frequency > 0 {
+		return frequency
+	}
+	return api.DefaultFrequency
+}
+
+// backendCapabilities appends the capabilities the backend needs to base,
+// each listed once
+func backendCapabilities(backend api.Backend, base ...corev1.Capability) []corev1.Capability {
+	capabilities := append([]corev1.Capability{}, base...)
+	for _, name := range backend.RequiredCapabilities() {
+		capability := corev1.Capability(name)
+		found := false
+		for _, existing := range capabilities {
+			if existing == capability {
+				found = true
+				break
+			}
+		}
+		if !found {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// backendVolumes returns the hostPath volumes and the mounts of the host
+// paths the backend needs
+func backendVolumes(backend api.Backend) ([]corev1.Volume, []corev1.VolumeMount) {
+	var (
+		volumes []corev1.Volume
+		mounts  []corev1.VolumeMount
+	)
+	for _, mount := range backend.RequiredMounts() {
+		volumes = append(volumes, corev1.Volume{
+			Name: mount.Name,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: mount.HostPath,
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      mount.Name,
+			MountPath: mount.MountPath,
+			ReadOnly:  mount.ReadOnly,
+		})
+	}
+	return volumes, mounts
+}
+
+// foldedSummaryScript prints the sample summary golang-profiling reports for
+// tools that only leave folded stacks: the samples, the distinct frames and
+// the frames the tool could not symbolize
+const foldedSummaryScript = `
+		if [ -s ` + api.BackendFoldedPath + ` ]; then
+			awk '{ n += $NF; sub(/ [0-9]+$/, ""); k = split($0, f, ";"); for (i = 1; i <= k; i++) seen[f[i]] = 1 }
+				END { for (s in seen) { d++; if (s ~ /^(\[unknown\]|0x[0-9a-f]+)$/) u++ }; printf "` + sampleSummaryMarker + `%d 0 0 0 %d %d\n", n, d, u }' ` + api.BackendFoldedPath + `
+		fi
+	`
+
+// renderFoldedScript renders the folded stacks a tool left into the flame
+// graph with the flamegraph.pl the profiler image ships
+func renderFoldedScript(cfg *api.ProfileConfig) string {
+	var args []string
+	if cfg.GoOptions != nil && cfg.GoOptions.Title != "" {
+		args = append(args, "--title", cfg.GoOptions.Title)
+	}
+	if cfg.GoOptions != nil && cfg.GoOptions.Subtitle != "" {
+		args = append(args, "--subtitle", cfg.GoOptions.Subtitle)
+	}
+	return fmt.Sprintf(`
+		if [ $PROFILE_EXIT_CODE -eq 0 ]; then
+			/usr/local/bin/flamegraph.pl %[1]s %[2]s > %[3]s
+			PROFILE_EXIT_CODE=$?
+		fi
+	`, shellJoin(args), api.BackendFoldedPath, api.BackendFlameGraphPath) + foldedSummaryScript
+}
+
+// singlePIDWarning notes that a tool sampling one process ignores the other
+// PIDs of the session
+func singlePIDWarning(tool string) string {
+	return fmt.Sprintf(`
+		if [ -n "$EXTRA_PIDS" ]; then
+			echo "Warning: %s samples one process, ignoring PIDs $EXTRA_PIDS"
+		fi
+	`, tool)
+}
+
+// parseSampleOutput reads the sample reports the job script prints for any backend
+func parseSampleOutput(logs string) (*api.BackendOutput, error) {
+	output := &api.BackendOutput{}
+	output.SampleStats, _ = parseSampleSummary(logs)
+	output.SampleTarget, _ = parseSampleTarget(logs)
+	return output, nil
+}
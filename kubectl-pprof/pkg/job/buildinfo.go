@@ -0,0 +1,60 @@
+package job
+
+import (
+	"bufio"
+	"runtime/debug"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// buildInfoMarker prefixes the lines of the target's Go build info in the job
+// logs, golang-profiling prints them as go version -m does
+const buildInfoMarker = "BUILD_INFO:"
+
+// parseBuildInfo reassembles the Go build info golang-profiling read from the
+// target executable. It reports false when none was printed, e.g. for a
+// binary built before Go 1.18 or a perf backend.
+func parseBuildInfo(logs string) (*api.BuildInfo, bool) {
+	var (
+		text      strings.Builder
+		goVersion string
+	)
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), buildInfoMarker)
+		if !ok {
+			continue
+		}
+		// ParseBuildInfo ignores the go line, keep the version it names
+		if version, ok := strings.CutPrefix(line, "go\t"); ok {
+			goVersion = version
+		}
+		text.WriteString(line + "\n")
+	}
+	if text.Len() == 0 {
+		return nil, false
+	}
+	info, err := debug.ParseBuildInfo(text.String())
+	if err != nil {
+		return nil, false
+	}
+
+	build := &api.BuildInfo{GoVersion: goVersion, Path: info.Path, Module: info.Main.Path}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		case "vcs.time":
+			build.Time = setting.Value
+		default:
+			if !strings.HasPrefix(setting.Key, "vcs") {
+				build.Flags = append(build.Flags, api.BuildSetting{Key: setting.Key, Value: setting.Value})
+			}
+		}
+	}
+	return build, true
+}
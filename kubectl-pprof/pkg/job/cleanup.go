@@ -3,13 +3,15 @@ package job
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/withlin/kubectl-pprof/pkg/logging"
 )
 
 // CleanupConfig 清理配置
@@ -26,6 +28,8 @@ type CleanupConfig struct {
 	CleanupFailedJobs bool
 	// 清理成功的 Job
 	CleanupSuccessfulJobs bool
+	// 只记录将要删除的资源，不实际删除
+	DryRun bool
 }
 
 // DefaultCleanupConfig 默认清理配置
@@ -44,15 +48,18 @@ func DefaultCleanupConfig() *CleanupConfig {
 type JobCleaner struct {
 	client kubernetes.Interface
 	config *CleanupConfig
-	logger *log.Logger
+	logger *slog.Logger
 	stopCh chan struct{}
 }
 
 // NewJobCleaner 创建新的 Job 清理器
-func NewJobCleaner(client kubernetes.Interface, config *CleanupConfig, logger *log.Logger) *JobCleaner {
+func NewJobCleaner(client kubernetes.Interface, config *CleanupConfig, logger *slog.Logger) *JobCleaner {
 	if config == nil {
 		config = DefaultCleanupConfig()
 	}
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
 
 	return &JobCleaner{
 		client: client,
@@ -65,11 +72,11 @@ func NewJobCleaner(client kubernetes.Interface, config *CleanupConfig, logger *l
 // Start 启动自动清理
 func (jc *JobCleaner) Start(ctx context.Context) {
 	if !jc.config.EnableAutoCleanup {
-		jc.logf("Auto cleanup is disabled")
+		jc.logger.Info("Auto cleanup is disabled")
 		return
 	}
 
-	jc.logf("Starting job cleaner with interval: %v", jc.config.CleanupInterval)
+	jc.logger.Info("Starting job cleaner", "interval", jc.config.CleanupInterval)
 
 	ticker := time.NewTicker(jc.config.CleanupInterval)
 	defer ticker.Stop()
@@ -77,14 +84,14 @@ func (jc *JobCleaner) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			jc.logf("Job cleaner stopped due to context cancellation")
+			jc.logger.Info("Job cleaner stopped due to context cancellation")
 			return
 		case <-jc.stopCh:
-			jc.logf("Job cleaner stopped")
+			jc.logger.Info("Job cleaner stopped")
 			return
 		case <-ticker.C:
 			if err := jc.cleanupExpiredJobs(ctx); err != nil {
-				jc.logf("Error during cleanup: %v", err)
+				jc.logger.Warn("Error during cleanup", "error", err)
 			}
 		}
 	}
@@ -97,7 +104,11 @@ func (jc *JobCleaner) Stop() {
 
 // CleanupJob 清理指定的 Job
 func (jc *JobCleaner) CleanupJob(ctx context.Context, jobName, namespace string) error {
-	jc.logf("Cleaning up job: %s in namespace: %s", jobName, namespace)
+	if jc.config.DryRun {
+		jc.logger.Info("Would clean up job", "job", jobName, "namespace", namespace)
+		return nil
+	}
+	jc.logger.Info("Cleaning up job", "job", jobName, "namespace", namespace)
 
 	// 删除 Job（前台删除，确保 Pod 也被删除）
 	deletePolicy := metav1.DeletePropagationForeground
@@ -109,7 +120,23 @@ func (jc *JobCleaner) CleanupJob(ctx context.Context, jobName, namespace string)
 		return fmt.Errorf("failed to delete job %s: %w", jobName, err)
 	}
 
-	jc.logf("Successfully cleaned up job: %s", jobName)
+	jc.logger.Log(ctx, logging.V(1), "Successfully cleaned up job", "job", jobName)
+	return nil
+}
+
+// CleanupPod 清理指定的孤立 Pod
+func (jc *JobCleaner) CleanupPod(ctx context.Context, podName, namespace string) error {
+	if jc.config.DryRun {
+		jc.logger.Info("Would clean up pod", "pod", podName, "namespace", namespace)
+		return nil
+	}
+	jc.logger.Info("Cleaning up pod", "pod", podName, "namespace", namespace)
+
+	if err := jc.client.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to delete pod %s: %w", podName, err)
+	}
+
+	jc.logger.Log(ctx, logging.V(1), "Successfully cleaned up pod", "pod", podName)
 	return nil
 }
 
@@ -121,73 +148,140 @@ func (jc *JobCleaner) CleanupJobAfterDelay(ctx context.Context, jobName, namespa
 
 		select {
 		case <-ctx.Done():
-			jc.logf("Cleanup cancelled for job: %s", jobName)
+			jc.logger.Info("Cleanup cancelled", "job", jobName)
 			return
 		case <-timer.C:
 			if err := jc.CleanupJob(ctx, jobName, namespace); err != nil {
-				jc.logf("Failed to cleanup job %s after delay: %v", jobName, err)
+				jc.logger.Warn("Failed to cleanup job after delay", "job", jobName, "error", err)
 			}
 		}
 	}()
 }
 
-// cleanupExpiredJobs 清理过期的 Job
+// RunOnce 执行一次过期 Job 清理
+func (jc *JobCleaner) RunOnce(ctx context.Context) error {
+	return jc.cleanupExpiredJobs(ctx)
+}
+
+// cleanupExpiredJobs 清理过期的 Job 和孤立的 Pod
 func (jc *JobCleaner) cleanupExpiredJobs(ctx context.Context) error {
-	// 获取所有命名空间的 Job
-	jobs, err := jc.client.BatchV1().Jobs("").List(ctx, metav1.ListOptions{
-		LabelSelector: "app=kubectl-pprof", // 只清理我们创建的 Job
-	})
+	jobs, pods, err := jc.FindStale(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list jobs: %w", err)
+		return err
 	}
 
-	now := time.Now()
 	cleanedCount := 0
+	for _, job := range jobs {
+		if err := jc.CleanupJob(ctx, job.Name, job.Namespace); err != nil {
+			jc.logger.Warn("Failed to cleanup expired job", "job", job.Name, "error", err)
+			continue
+		}
+		cleanedCount++
+	}
+	for _, pod := range pods {
+		if err := jc.CleanupPod(ctx, pod.Name, pod.Namespace); err != nil {
+			jc.logger.Warn("Failed to cleanup orphaned pod", "pod", pod.Name, "error", err)
+			continue
+		}
+		cleanedCount++
+	}
+
+	if cleanedCount > 0 {
+		jc.logger.Info("Cleaned up expired jobs and orphaned pods", "count", cleanedCount)
+	}
+
+	return nil
+}
+
+// FindStale 查找所有命名空间中应清理的 Job，以及所属 Job 已不存在的孤立 Pod
+func (jc *JobCleaner) FindStale(ctx context.Context) ([]batchv1.Job, []corev1.Pod, error) {
+	// 只清理我们创建的资源
+	listOpts := metav1.ListOptions{LabelSelector: jobLabelSelector}
+	jobs, err := jc.client.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, listOpts)
+	if err != nil {
+		return nil, nil, apiError(err, "failed to list profiling jobs")
+	}
+	pods, err := jc.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, listOpts)
+	if err != nil {
+		return nil, nil, apiError(err, "failed to list profiling pods")
+	}
 
+	now := time.Now()
+	var staleJobs []batchv1.Job
+	existing := make(map[string]bool, len(jobs.Items))
 	for _, job := range jobs.Items {
+		existing[job.Namespace+"/"+job.Name] = true
 		if jc.shouldCleanupJob(&job, now) {
-			if err := jc.CleanupJob(ctx, job.Name, job.Namespace); err != nil {
-				jc.logf("Failed to cleanup expired job %s: %v", job.Name, err)
-				continue
-			}
-			cleanedCount++
+			staleJobs = append(staleJobs, job)
 		}
 	}
 
-	if cleanedCount > 0 {
-		jc.logf("Cleaned up %d expired jobs", cleanedCount)
+	var orphanedPods []corev1.Pod
+	for _, pod := range pods.Items {
+		if podHasJob(&pod, existing) || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if !jc.config.CleanupSuccessfulJobs && pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		if now.Sub(pod.CreationTimestamp.Time) > jc.config.MaxJobRetention {
+			orphanedPods = append(orphanedPods, pod)
+		}
 	}
+	return staleJobs, orphanedPods, nil
+}
 
-	return nil
+// podHasJob 判断 Pod 所属的 Job 是否仍然存在
+func podHasJob(pod *corev1.Pod, existing map[string]bool) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "Job" && existing[pod.Namespace+"/"+owner.Name] {
+			return true
+		}
+	}
+	return false
 }
 
-// shouldCleanupJob 判断是否应该清理 Job
+// shouldCleanupJob 判断是否应该清理 Job：仍在运行的 Job 无论多久都不清理，
+// --no-collect 启动的 Job 在被 collect 之前也不清理
 func (jc *JobCleaner) shouldCleanupJob(job *batchv1.Job, now time.Time) bool {
-	// 检查 Job 年龄
-	age := now.Sub(job.CreationTimestamp.Time)
-	if age > jc.config.MaxJobRetention {
+	if job.DeletionTimestamp != nil || job.Status.Active > 0 {
+		return false
+	}
+	finished, failed := jobFinished(job)
+	if finished == nil {
+		return false
+	}
+	if _, detached := job.Annotations[JobAnnotationSession]; detached {
+		if _, collected := job.Annotations[JobAnnotationCollected]; !collected {
+			return false
+		}
+	}
+	if (failed && !jc.config.CleanupFailedJobs) || (!failed && !jc.config.CleanupSuccessfulJobs) {
+		return false
+	}
+
+	// 检查 Job 年龄与结束时间
+	if now.Sub(job.CreationTimestamp.Time) > jc.config.MaxJobRetention {
 		return true
 	}
+	return now.Sub(finished.LastTransitionTime.Time) > jc.config.AutoCleanupDelay
+}
 
-	// 检查 Job 状态
-	for _, condition := range job.Status.Conditions {
-		switch condition.Type {
+// jobFinished 返回 Job 的完成或失败条件，Job 尚未结束时为 nil
+func jobFinished(job *batchv1.Job) (condition *batchv1.JobCondition, failed bool) {
+	for i := range job.Status.Conditions {
+		c := &job.Status.Conditions[i]
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
 		case batchv1.JobComplete:
-			if condition.Status == corev1.ConditionTrue && jc.config.CleanupSuccessfulJobs {
-				// 成功的 Job，检查完成时间
-				completionAge := now.Sub(condition.LastTransitionTime.Time)
-				return completionAge > jc.config.AutoCleanupDelay
-			}
+			return c, false
 		case batchv1.JobFailed:
-			if condition.Status == corev1.ConditionTrue && jc.config.CleanupFailedJobs {
-				// 失败的 Job，检查失败时间
-				failureAge := now.Sub(condition.LastTransitionTime.Time)
-				return failureAge > jc.config.AutoCleanupDelay
-			}
+			return c, true
 		}
 	}
-
-	return false
+	return nil, false
 }
 
 // GetJobStatus 获取 Job 状态
@@ -210,12 +304,12 @@ func (jc *JobCleaner) WaitForJobCompletion(ctx context.Context, jobName, namespa
 		case <-ticker.C:
 			job, err := jc.GetJobStatus(ctx, jobName, namespace)
 			if err != nil {
-				return fmt.Errorf("failed to get job status: %w", err)
+				return apiError(err, "failed to get status of job %s/%s", namespace, jobName)
 			}
 
 			for _, condition := range job.Status.Conditions {
 				if condition.Type == batchv1.JobComplete && condition.Status == corev1.ConditionTrue {
-					jc.logf("Job %s completed successfully", jobName)
+					jc.logger.Info("Job completed successfully", "job", jobName)
 					return nil
 				}
 				if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
@@ -225,10 +319,3 @@ func (jc *JobCleaner) WaitForJobCompletion(ctx context.Context, jobName, namespa
 		}
 	}
 }
-
-// logf 记录日志
-func (jc *JobCleaner) logf(format string, args ...interface{}) {
-	if jc.logger != nil {
-		jc.logger.Printf("[JobCleaner] "+format, args...)
-	}
-}
\ No newline at end of file
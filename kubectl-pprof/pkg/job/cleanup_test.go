@@ -0,0 +1,119 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestShouldCleanupJob(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	// job returns a profiling Job created age ago, finished with condition
+	// finishedAge ago unless condition is empty
+	job := func(age time.Duration, condition batchv1.JobConditionType, finishedAge time.Duration) *batchv1.Job {
+		j := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:              "pprof-job",
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Annotations:       map[string]string{},
+		}}
+		if condition == "" {
+			j.Status.Active = 1
+			return j
+		}
+		j.Status.Conditions = []batchv1.JobCondition{{
+			Type:               condition,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(now.Add(-finishedAge)),
+		}}
+		if condition == batchv1.JobFailed {
+			j.Status.Failed = 1
+		} else {
+			j.Status.Succeeded = 1
+		}
+		return j
+	}
+	with := func(j *batchv1.Job, change func(*batchv1.Job)) *batchv1.Job {
+		change(j)
+		return j
+	}
+	// The config of `gc --older-than 1h`
+	gc := &CleanupConfig{
+		AutoCleanupDelay:      time.Hour,
+		MaxJobRetention:       time.Hour,
+		CleanupFailedJobs:     true,
+		CleanupSuccessfulJobs: true,
+	}
+	failedOnly := *gc
+	failedOnly.CleanupSuccessfulJobs = false
+
+	tests := []struct {
+		name   string
+		config *CleanupConfig
+		job    *batchv1.Job
+		want   bool
+	}{
+		{name: "succeeded past retention", config: gc, job: job(2*time.Hour, batchv1.JobComplete, 2*time.Hour), want: true},
+		{name: "failed past retention", config: gc, job: job(2*time.Hour, batchv1.JobFailed, 2*time.Hour), want: true},
+		{name: "finished recently", config: gc, job: job(30*time.Minute, batchv1.JobComplete, 10*time.Minute), want: false},
+		{name: "old Job finished recently", config: gc, job: job(3*time.Hour, batchv1.JobComplete, time.Minute), want: true},
+		{name: "running past retention", config: gc, job: job(5*time.Hour, "", 0), want: false},
+		{
+			name:   "active pod with a finished condition",
+			config: gc,
+			job:    with(job(5*time.Hour, batchv1.JobFailed, 2*time.Hour), func(j *batchv1.Job) { j.Status.Active = 1 }),
+			want:   false,
+		},
+		{
+			name:   "failed count without a condition",
+			config: gc,
+			job:    with(job(5*time.Hour, "", 0), func(j *batchv1.Job) { j.Status.Active, j.Status.Failed = 0, 1 }),
+			want:   false,
+		},
+		{
+			name:   "condition not true",
+			config: gc,
+			job: with(job(5*time.Hour, batchv1.JobComplete, 2*time.Hour), func(j *batchv1.Job) {
+				j.Status.Conditions[0].Status = corev1.ConditionFalse
+			}),
+			want: false,
+		},
+		{
+			name:   "being deleted",
+			config: gc,
+			job: with(job(2*time.Hour, batchv1.JobComplete, 2*time.Hour), func(j *batchv1.Job) {
+				j.DeletionTimestamp = &metav1.Time{Time: now}
+			}),
+			want: false,
+		},
+		{
+			name:   "detached not collected",
+			config: gc,
+			job: with(job(48*time.Hour, batchv1.JobComplete, 47*time.Hour), func(j *batchv1.Job) {
+				j.Annotations[JobAnnotationSession] = "{}"
+			}),
+			want: false,
+		},
+		{
+			name:   "detached collected",
+			config: gc,
+			job: with(job(48*time.Hour, batchv1.JobComplete, 47*time.Hour), func(j *batchv1.Job) {
+				j.Annotations[JobAnnotationSession] = "{}"
+				j.Annotations[JobAnnotationCollected] = now.Add(-time.Hour).Format(time.RFC3339)
+			}),
+			want: true,
+		},
+		{name: "failed only skips succeeded", config: &failedOnly, job: job(2*time.Hour, batchv1.JobComplete, 2*time.Hour), want: false},
+		{name: "failed only deletes failed", config: &failedOnly, job: job(2*time.Hour, batchv1.JobFailed, 2*time.Hour), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jc := NewJobCleaner(nil, tt.config, nil)
+			if got := jc.shouldCleanupJob(tt.job, now); got != tt.want {
+				t.Errorf("shouldCleanupJob = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
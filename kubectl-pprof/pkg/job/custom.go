@@ -0,0 +1,161 @@
+package job
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Output formats of custom profilers
+const (
+	customOutputFolded = "folded"
+	customOutputSVG    = "svg"
+)
+
+// customLanguageName restricts language names to what reads well in flags,
+// labels and logs
+var customLanguageName = regexp.MustCompile(`^[a-z][a-z0-9-]{0,30}$`)
+
+// capabilityName matches Linux capability names without their CAP_ prefix
+var capabilityName = regexp.MustCompile(`^[A-Z][A-Z_]*$`)
+
+// customCommandData is what the command template of a custom profiler sees
+type customCommandData struct {
+	PID            string // Shell expansion of the target PID, e.g. $TARGET_PID
+	Duration       int    // Seconds
+	Frequency      int    // Hz
+	ProfileType    string
+	FoldedPath     string
+	FlameGraphPath string
+}
+
+// customBackend runs a profiler the user declared in the configuration file
+type customBackend struct {
+	name         string
+	command      *template.Template
+	folded       bool // The command leaves folded stacks, rendered by the script
+	capabilities []string
+}
+
+// NewCustomBackend builds the Backend of a custom language, checking its
+// declaration
+func NewCustomBackend(lang api.CustomLanguage) (api.Backend, error) {
+	if !customLanguageName.MatchString(lang.Name) {
+		return nil, fmt.Errorf("language name %q must be lowercase letters, digits and dashes", lang.Name)
+	}
+	if lang.Image == "" {
+		return nil, fmt.Errorf("language %s has no image", lang.Name)
+	}
+	if strings.TrimSpace(lang.Command) == "" {
+		return nil, fmt.Errorf("language %s has no command", lang.Name)
+	}
+	command, err := template.New(lang.Name).Option("missingkey=error").Parse(lang.Command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command of language %s: %w", lang.Name, err)
+	}
+	// Execute once so unknown fields fail now rather than in the job
+	if err := command.Execute(&bytes.Buffer{}, customCommandData{}); err != nil {
+		return nil, fmt.Errorf("invalid command of language %s: %w", lang.Name, err)
+	}
+
+	backend := &customBackend{name: lang.Name, command: command}
+	formats := lang.OutputFormats
+	if len(formats) == 0 {
+		formats = []string{customOutputFolded}
+	}
+	for _, format := range formats {
+		switch format {
+		case customOutputFolded:
+			backend.folded = true
+		case customOutputSVG:
+		default:
+			return nil, fmt.Errorf("unsupported output format %q of language %s, must be %s or %s", format, lang.Name, customOutputFolded, customOutputSVG)
+		}
+	}
+	for _, capability := range lang.Capabilities {
+		capability = strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if !capabilityName.MatchString(capability) {
+			return nil, fmt.Errorf("invalid capability %q of language %s", capability, lang.Name)
+		}
+		backend.capabilities = append(backend.capabilities, capability)
+	}
+	return backend, nil
+}
+
+// RegisterCustomLanguages registers the languages declared in the
+// configuration file in lm, each profiled by its custom backend
+func RegisterCustomLanguages(lm *api.LanguageManager, langs []api.CustomLanguage) error {
+	for _, lang := range langs {
+		backend, err := NewCustomBackend(lang)
+		if err != nil {
+			return err
+		}
+		formats := lang.OutputFormats
+		if len(formats) == 0 {
+			formats = []string{customOutputFolded}
+		}
+		config := &api.LanguageConfig{
+			Language:             api.Language(lang.Name),
+			SupportedTypes:       []string{api.ProfileTypeCPU},
+			DefaultType:          api.ProfileTypeCPU,
+			DefaultImage:         lang.Image,
+			ProfilerCommand:      []string{lang.Command},
+			OutputFormats:        formats,
+			RequiredCapabilities: backend.RequiredCapabilities(),
+		}
+		if err := lm.RegisterLanguage(config, backend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *customBackend) Name() string {
+	return b.name
+}
+
+// BuildArgs returns nothing, the arguments are part of the command template
+func (b *customBackend) BuildArgs(cfg *api.ProfileConfig) []string {
+	return nil
+}
+
+// BuildScript runs the command of the user, rendering its folded stacks like
+// the built-in backends do
+func (b *customBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	var command bytes.Buffer
+	// Checked by NewCustomBackend, the data has every field
+	_ = b.command.Execute(&command, customCommandData{
+		PID:            "$" + pidVar,
+		Duration:       int(cfg.Duration.Seconds()),
+		Frequency:      backendFrequency(cfg),
+		ProfileType:    cfg.ProfileType,
+		FoldedPath:     api.BackendFoldedPath,
+		FlameGraphPath: api.BackendFlameGraphPath,
+	})
+	script := singlePIDWarning(b.name) + fmt.Sprintf(`
+		echo "Starting %[1]s"
+		%[2]s
+		PROFILE_EXIT_CODE=$?
+	`, b.name, command.String())
+	if b.folded {
+		script += renderFoldedScript(cfg)
+	}
+	return script
+}
+
+func (b *customBackend) RequiredMounts() []api.HostMount {
+	return nil
+}
+
+func (b *customBackend) RequiredCapabilities() []string {
+	return b.capabilities
+}
+
+// ParseOutput reads the sample summary the job script computes from the folded stacks
+func (b *customBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	return parseSampleOutput(logs)
+}
@@ -0,0 +1,165 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// JobAnnotationSession holds the session of a Job started with --no-collect,
+// which `kubectl pprof collect` reads back to fetch its results
+const JobAnnotationSession = "kubectl-pprof/session"
+
+// JobAnnotationCollected marks a Job of JobAnnotationSession whose results
+// were collected, so that gc may delete it once it is kept afterwards
+const JobAnnotationCollected = "kubectl-pprof/collected"
+
+// DetachedSession is what collecting the results of a Job started without
+// waiting for it needs to know of the session that started it
+type DetachedSession struct {
+	Config   *api.ProfileConfig   `json:"config"`
+	Options  *api.ProfileOptions  `json:"options"`
+	Target   *api.TargetInfo      `json:"target"`
+	Metadata *api.SessionMetadata `json:"metadata,omitempty"`
+}
+
+// StartProfilingJob creates a profiling Job and returns at once, with the
+// session recorded on the Job for CollectProfilingJob. The Job is kept once
+// finished until collected or until --job-ttl expires. The node Lease stays
+// with the Job: it expires once the Job is past its deadline, or is released
+// when CollectProfilingJob collects it.
+func (m *Manager) StartProfilingJob(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, meta *api.SessionMetadata) (*api.ProfileResult, error) {
+	if _, err := ParseTolerations(cfg.Tolerations); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.PIDs(); err != nil {
+		return nil, err
+	}
+	backend, methodology, err := m.backend(cfg, opts, target)
+	if err != nil {
+		return nil, err
+	}
+	if methodology != "" {
+		opts.Log().Warn("eBPF unavailable on the node, falling back", "backend", backend.Name(), "note", methodology)
+	}
+
+	session, err := detachedSessionAnnotation(cfg, opts, target, meta)
+	if err != nil {
+		return nil, err
+	}
+	jobName, _, err := m.createJob(ctx, cfg, opts, target, backend, func(job *batchv1.Job) error {
+		if job.Annotations == nil {
+			job.Annotations = map[string]string{}
+		}
+		job.Annotations[JobAnnotationSession] = session
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The Lease is not released here, the node is busy until the Job is done.
+	// No other session of this process shares it, its holder is the Job.
+	opts.Emit(api.ProgressEvent{
+		Type:      api.EventJobCreated,
+		Namespace: target.Namespace,
+		PodName:   target.PodName,
+		Container: target.ContainerName,
+		NodeName:  target.NodeName,
+		JobName:   jobName,
+	})
+
+	return &api.ProfileResult{
+		JobName:   jobName,
+		JobStatus: &api.JobStatus{JobName: jobName, Namespace: cfg.GetJobNamespace(), Phase: api.JobPhasePending},
+		Metadata:  meta,
+	}, nil
+}
+
+// detachedSessionAnnotation encodes the session for JobAnnotationSession,
+// without the pod and container objects of the target, which the Job spec
+// already reflects
+func detachedSessionAnnotation(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, meta *api.SessionMetadata) (string, error) {
+	sessionTarget := *target
+	sessionTarget.Pod, sessionTarget.Container = nil, nil
+	sessionOpts := *opts
+	sessionOpts.RecordSession = ""
+
+	data, err := json.Marshal(&DetachedSession{Config: cfg, Options: &sessionOpts, Target: &sessionTarget, Metadata: meta})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+	return string(data), nil
+}
+
+// CollectProfilingJob waits for a Job started by StartProfilingJob to finish
+// and reads its outcome from the logs, returning the session recorded on it.
+// opts supplies the Logger and Progress of the collection.
+func (m *Manager) CollectProfilingJob(ctx context.Context, jobName, namespace string, opts *api.ProfileOptions) (*DetachedSession, *api.ProfileResult, error) {
+	job, err := m.k8sConfig.Clientset.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, apiError(err, "failed to get job %s/%s", namespace, jobName)
+	}
+	value, ok := job.Annotations[JobAnnotationSession]
+	if !ok {
+		return nil, nil, fmt.Errorf("job %s/%s was not started with --no-collect, it has no %s annotation", namespace, jobName, JobAnnotationSession)
+	}
+	var session DetachedSession
+	if err := json.Unmarshal([]byte(value), &session); err != nil || session.Config == nil || session.Target == nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation on job %s/%s", JobAnnotationSession, namespace, jobName)
+	}
+	if session.Options == nil {
+		session.Options = api.DefaultProfileOptions()
+	}
+	// Logs and cleanup go to the namespace the Job was found in
+	session.Config.JobNamespace = namespace
+
+	backend, methodology, err := m.backend(session.Config, opts, session.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var status *api.JobStatus
+	if opts.PrintLogs {
+		status, err = m.WaitForCompletionWithLogs(ctx, opts, jobName, namespace, session.Config.Timings().Monitor)
+	} else {
+		status, err = m.WaitForCompletion(ctx, opts, jobName, namespace, session.Config.Timings().Monitor)
+	}
+	// The Job no longer samples the node, whatever its outcome, unless the
+	// collection itself was interrupted
+	if ctx.Err() == nil {
+		m.releaseJobLease(session.Config, session.Target.NodeName, jobName)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("job execution failed: %w", err)
+	}
+
+	result, err := m.jobResult(ctx, session.Config, opts, session.Target, backend, methodology, jobName, status)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &session, result, nil
+}
+
+// MarkCollected records on a Job started by StartProfilingJob that its results
+// were collected, for a Job kept afterwards
+func (m *Manager) MarkCollected(ctx context.Context, jobName, namespace string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{JobAnnotationCollected: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode patch: %w", err)
+	}
+	if _, err := m.k8sConfig.Clientset.BatchV1().Jobs(namespace).Patch(ctx, jobName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return apiError(err, "failed to mark job %s/%s collected", namespace, jobName)
+	}
+	return nil
+}
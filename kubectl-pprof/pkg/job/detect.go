@@ -0,0 +1,182 @@
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// languageMarker prefixes the "<language> <reason>" line the job prints once
+// it detected the language of the target process
+const languageMarker = "LANGUAGE_DETECTED:"
+
+// detectedLanguages lists what the detection script can report, in the order
+// the job dispatches them
+var detectedLanguages = []api.Language{api.LanguageJava, api.LanguagePython, api.LanguageNode, api.LanguageGo, api.LanguageRust}
+
+// detectLanguageFunctionScript defines detect_language, which prints the
+// language of a process and what revealed it. Runtimes are recognized from the
+// libraries or interpreter they map before the executable is inspected, since
+// a JVM or Python embedded into a native binary still needs its own sampler.
+const detectLanguageFunctionScript = `
+		detect_language() {
+			PROC_DIR="${PROC_ROOT:-/proc}/$1"
+			EXE_NAME=$(basename "$(readlink "$PROC_DIR/exe" 2>/dev/null)")
+			if grep -q 'libjvm\.so' "$PROC_DIR/maps" 2>/dev/null; then
+				echo "java libjvm.so is mapped"
+			elif grep -q 'libpython[0-9.]*\.so' "$PROC_DIR/maps" 2>/dev/null || echo "$EXE_NAME" | grep -q '^python'; then
+				echo "python the CPython interpreter $EXE_NAME runs it"
+			elif grep -q 'libnode\.so' "$PROC_DIR/maps" 2>/dev/null || [ "$EXE_NAME" = node ] || [ "$EXE_NAME" = nodejs ]; then
+				echo "node the Node.js runtime $EXE_NAME runs it"
+			elif grep -aq 'Go buildinf:' "$PROC_DIR/exe" 2>/dev/null; then
+				echo "go $EXE_NAME carries Go build info"
+			elif grep -aq '/rustc/' "$PROC_DIR/exe" 2>/dev/null; then
+				echo "rust $EXE_NAME was built by rustc"
+			else
+				echo "unknown nothing in $EXE_NAME was recognized"
+			fi
+		}
+	`
+
+// autoBackend detects the language of the target in the job and runs the
+// Backend registered for it, falling back to golang-profiling for languages
+// without one
+type autoBackend struct {
+	fallback api.Backend
+	backends map[api.Language]api.Backend
+	// How a backend swapped for perf on the node samples differently
+	methodology map[api.Language]string
+}
+
+// autoBackend builds the detecting backend from the registered ones, each
+// already swapped for perf where the node kernel cannot run eBPF
+func (m *Manager) autoBackend(cfg *api.ProfileConfig, target *api.TargetInfo) (*autoBackend, error) {
+	fallback, err := m.languages.GetBackend(api.LanguageGo)
+	if err != nil {
+		return nil, err
+	}
+	auto := &autoBackend{
+		backends:    map[api.Language]api.Backend{},
+		methodology: map[api.Language]string{},
+	}
+	auto.fallback, auto.methodology[""] = perfFallback(cfg, target, fallback)
+	for _, lang := range detectedLanguages {
+		backend, err := m.languages.GetBackend(lang)
+		if err != nil {
+			continue
+		}
+		auto.backends[lang], auto.methodology[lang] = perfFallback(cfg, target, backend)
+	}
+	return auto, nil
+}
+
+func (b *autoBackend) Name() string {
+	return "auto"
+}
+
+// candidates returns the fallback and every registered backend
+func (b *autoBackend) candidates() []api.Backend {
+	backends := []api.Backend{b.fallback}
+	for _, lang := range detectedLanguages {
+		if backend, ok := b.backends[lang]; ok {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// BuildArgs returns the arguments of the fallback, the others are only known in the job
+func (b *autoBackend) BuildArgs(cfg *api.ProfileConfig) []string {
+	return b.fallback.BuildArgs(cfg)
+}
+
+func (b *autoBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	var cases strings.Builder
+	for _, lang := range detectedLanguages {
+		if backend, ok := b.backends[lang]; ok {
+			fmt.Fprintf(&cases, `
+		%s)%s;;`, lang, backend.BuildScript(cfg, pidVar))
+		}
+	}
+	return detectLanguageFunctionScript + fmt.Sprintf(`
+		DETECTED_LANGUAGE=$(detect_language "$%[1]s")
+		echo "%[2]s$DETECTED_LANGUAGE"
+		case "${DETECTED_LANGUAGE%%%% *}" in%[3]s
+		*)
+			echo "No profiling backend for ${DETECTED_LANGUAGE%%%% *}, sampling with %[4]s"%[5]s;;
+		esac
+	`, pidVar, languageMarker, cases.String(), b.fallback.Name(), b.fallback.BuildScript(cfg, pidVar))
+}
+
+// RequiredMounts lists the mounts of every candidate, the job only learns which one runs
+func (b *autoBackend) RequiredMounts() []api.HostMount {
+	var mounts []api.HostMount
+	seen := map[string]bool{}
+	for _, backend := range b.candidates() {
+		for _, mount := range backend.RequiredMounts() {
+			if !seen[mount.Name] {
+				seen[mount.Name] = true
+				mounts = append(mounts, mount)
+			}
+		}
+	}
+	return mounts
+}
+
+// RequiredCapabilities lists the capabilities of every candidate
+func (b *autoBackend) RequiredCapabilities() []string {
+	var capabilities []string
+	seen := map[string]bool{}
+	for _, backend := range b.candidates() {
+		for _, capability := range backend.RequiredCapabilities() {
+			if !seen[capability] {
+				seen[capability] = true
+				capabilities = append(capabilities, capability)
+			}
+		}
+	}
+	return capabilities
+}
+
+// ParseOutput reads the detected language and leaves the rest to the backend
+// that sampled it. Logs without a detection, as when the job failed before,
+// are read as the fallback's.
+func (b *autoBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	lang, detection, found := parseDetectedLanguage(logs)
+	backend, ok := b.backends[lang]
+	if !ok {
+		backend = b.fallback
+	}
+	output, err := backend.ParseOutput(logs)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		output.Language, output.Detection = lang, detection
+	}
+	output.Backend = backend.Name()
+	if !ok {
+		lang = ""
+	}
+	if methodology := b.methodology[lang]; methodology != "" {
+		output.Methodology = methodology
+	}
+	return output, nil
+}
+
+// parseDetectedLanguage finds the language the job detected and what revealed it
+func parseDetectedLanguage(logs string) (api.Language, string, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, languageMarker) {
+			continue
+		}
+		lang, detection, _ := strings.Cut(strings.TrimPrefix(line, languageMarker), " ")
+		return api.Language(lang), detection, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,109 @@
+package job
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+)
+
+// Binaries of the profiling image run by a --direct-exec Job
+const (
+	launcherPath        = "/usr/local/bin/kubectl-pprof-launcher"
+	golangProfilingPath = "/usr/local/bin/golang-profiling"
+)
+
+// buildDirectCommand returns the command and the arguments of a profiler
+// container running the launcher instead of the job script: the target and
+// the profiler command are structured arguments, nothing goes through a
+// shell. Only golang-profiling runs this way, the other backends need the
+// pipelines of their scripts.
+func buildDirectCommand(cfg *api.ProfileConfig, target *api.TargetInfo, backend api.Backend) ([]string, []string, error) {
+	if _, ok := backend.(golangBackend); !ok {
+		return nil, nil, fmt.Errorf("--direct-exec runs golang-profiling only, the %s backend needs the job script", backend.Name())
+	}
+	if cfg.Live {
+		return nil, nil, fmt.Errorf("--direct-exec cannot stream --live snapshots, they need the job script")
+	}
+
+	var args []string
+	if target.HostProcess != "" {
+		args = append(args, "--process", target.HostProcess)
+	} else {
+		args = append(args,
+			"--runtime-endpoint", "unix://"+RuntimeSocket(cfg, target),
+			"--container", target.ContainerName,
+			"--container-id", runtimeContainerID(target.ContainerID),
+			"--pod-uid", cgroupPodUID(target),
+		)
+		if len(target.Command) > 0 {
+			args = append(args, "--command", path.Base(target.Command[0]))
+		}
+		// Validated before the Job is built
+		if pids, _ := cfg.PIDs(); len(pids) > 0 {
+			args = append(args, "--pids", joinPIDs(pids, ","))
+		}
+	}
+	// Launchers predating zstd only know gzip, the flag is left out for it
+	if cfg.GetCompression() != api.CompressionGzip {
+		args = append(args, "--compression", cfg.GetCompression())
+	}
+	if cfg.GetTransfer() == api.TransferPortForward {
+		args = append(args, "--serve-timeout", cfg.Timings().Serve.String())
+	}
+	args = append(args, "--artifact", flameGraphArtifact+"="+api.BackendFlameGraphPath)
+	for _, artifact := range optionalArtifacts(cfg) {
+		args = append(args, "--optional-artifact", artifact.name+"="+artifact.path)
+	}
+
+	args = append(args, "--", golangProfilingPath,
+		"--duration", strconv.Itoa(int(cfg.Duration.Seconds())),
+		"--output", api.BackendFlameGraphPath)
+	return []string{launcherPath}, append(args, backend.BuildArgs(cfg)...), nil
+}
+
+// launcherFailure returns why a --direct-exec run failed, from the failed
+// status record of the launcher in its logs, nil when it did not fail
+func launcherFailure(logs string) error {
+	for _, line := range strings.Split(logs, "\n") {
+		status, ok := launcher.ParseStatus(line)
+		if !ok || status.Type != launcher.StatusFailed {
+			continue
+		}
+		return errors.NewProfilerError("the profiler launcher failed: "+status.Message, nil, false,
+			"Check the target is running on the node, and the logs of the profiler pod with --keep-failed-jobs")
+	}
+	return nil
+}
+
+// logLauncherStatus logs a status record of the launcher streamed from the
+// profiler pod
+func logLauncherStatus(log *slog.Logger, status launcher.Status) {
+	switch status.Type {
+	case launcher.StatusProgress:
+		log.Info(status.Message)
+	case launcher.StatusWarning:
+		log.Warn(status.Message)
+	case launcher.StatusFailed:
+		log.Error("Launcher failed", "error", status.Message)
+	case launcher.StatusResolved:
+		log.Info("Target resolved", "pid", status.PID, "extraPids", status.ExtraPIDs)
+	case launcher.StatusStarted:
+		log.Info("Profiler started", "command", strings.Join(status.Command, " "))
+	case launcher.StatusExited:
+		if status.ExitCode != nil {
+			log.Info("Profiler exited", "exitCode", *status.ExitCode)
+		}
+	case launcher.StatusArtifact:
+		log.Debug("Artifact received", "name", status.Name, "encoding", status.Encoding, "chunk", status.Chunk, "chunks", status.Chunks, "bytes", len(status.Data))
+	case launcher.StatusServing:
+		log.Debug("Serving artifacts", "port", status.Port, "artifacts", len(status.Artifacts))
+	case launcher.StatusCompleted:
+		log.Info("Profiling completed")
+	}
+}
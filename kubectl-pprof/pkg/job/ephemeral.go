@@ -0,0 +1,310 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// ephemeralSession an ephemeral profiler container standing in for a Job, so
+// that log extraction and cleanup keep working by session name
+type ephemeralSession struct {
+	namespace string
+	pod       string
+	container string
+}
+
+// ephemeralSessions ephemeral profiler containers started by this manager
+type ephemeralSessions struct {
+	mu       sync.Mutex
+	sessions map[string]ephemeralSession
+}
+
+func (s *ephemeralSessions) add(name string, session ephemeralSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]ephemeralSession)
+	}
+	s.sessions[name] = session
+}
+
+func (s *ephemeralSessions) get(name string) (ephemeralSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[name]
+	return session, ok
+}
+
+func (s *ephemeralSessions) remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[name]
+	delete(s.sessions, name)
+	return ok
+}
+
+// SupportsEphemeralContainers reports whether the API server serves the
+// pods/ephemeralcontainers subresource (Kubernetes >= 1.23 by default)
+func (m *Manager) SupportsEphemeralContainers() (bool, error) {
+	resources, err := m.k8sConfig.Clientset.Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		return false, apiError(err, "failed to discover core API resources")
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "pods/ephemeralcontainers" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ephemeralContainerExists reports whether the pod already has an ephemeral
+// container with the name
+func ephemeralContainerExists(pod *corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.EphemeralContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateEphemeralProfiler injects the profiler as an ephemeral debug container
+// into the target pod and monitors its execution. The container shares the
+// PID namespace of the target container, so neither hostPID nor a privileged
+// Job is needed; it only adds the capabilities eBPF sampling requires.
+func (m *Manager) CreateEphemeralProfiler(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	backend, methodology, err := m.backend(cfg, opts, target)
+	if err != nil {
+		return nil, err
+	}
+	if methodology != "" {
+		opts.Log().Warn("eBPF unavailable on the node, falling back", "backend", backend.Name(), "note", methodology)
+	}
+	// Ephemeral containers can only mount the volumes the pod already has
+	if len(backend.RequiredMounts()) > 0 {
+		return nil, fmt.Errorf("%s needs host paths an ephemeral container cannot mount, use --mode job", backend.Name())
+	}
+	script, err := buildEphemeralScript(cfg, backend, target)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := m.k8sConfig.Clientset.CoreV1().Pods(target.Namespace)
+	pod, err := pods.Get(ctx, target.PodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, apiError(err, "failed to get pod %s/%s", target.Namespace, target.PodName)
+	}
+
+	// The container lives in the target pod, so its name only has to be unique there
+	name := GenerateJobName(cfg, "")
+	for ephemeralContainerExists(pod, name) {
+		name = GenerateJobName(cfg, "")
+	}
+
+	release, err := m.acquireNodeLease(ctx, cfg, opts, target.NodeName, name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:            name,
+			Image:           ProfilingImage(cfg, target.NodeInfo),
+			Command:         []string{"/bin/sh"},
+			Args:            []string{"-c", script},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			SecurityContext: &corev1.SecurityContext{
+				Privileged: &[]bool{false}[0],
+				RunAsUser:  &[]int64{0}[0],
+				Capabilities: &corev1.Capabilities{
+					Add: backendCapabilities(backend),
+				},
+			},
+		},
+		TargetContainerName: target.ContainerName,
+	})
+
+	if _, err := pods.UpdateEphemeralContainers(ctx, pod.Name, pod, metav1.UpdateOptions{}); err != nil {
+		if rejection := admissionRejection(api.ModeEphemeral, name, err); rejection != nil {
+			return nil, rejection
+		}
+		return nil, apiError(err, "failed to add ephemeral profiler container to pod %s/%s", target.Namespace, target.PodName)
+	}
+	m.ephemeral.add(name, ephemeralSession{namespace: target.Namespace, pod: target.PodName, container: name})
+	stopSnapshots := m.followSnapshots(ctx, cfg, opts, name, target.Namespace)
+	defer stopSnapshots()
+	opts.Emit(api.ProgressEvent{
+		Type:      api.EventJobCreated,
+		Namespace: target.Namespace,
+		PodName:   target.PodName,
+		Container: target.ContainerName,
+		NodeName:  target.NodeName,
+		JobName:   name,
+	})
+
+	status, err := m.waitForEphemeralContainer(ctx, name, target.Namespace, target.PodName, cfg.Timings().Monitor, opts)
+	stopSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("ephemeral profiler execution failed: %w", err)
+	}
+
+	// Missing logs are tolerated so that the result can still be collected
+	logs, _ := m.readJobLogs(ctx, name, target.Namespace)
+
+	preflight, err := checkPreflight(logs, target)
+	if err != nil {
+		return nil, err
+	}
+
+	overhead := parseOverhead(logs)
+	throttling, _ := parseThrottling(logs)
+	buildInfo, _ := parseBuildInfo(logs)
+	output, err := backend.ParseOutput(logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s output: %w", backend.Name(), err)
+	}
+	completeBackendOutput(output, backend, methodology, cfg, opts)
+
+	return &api.ProfileResult{
+		JobName:      name,
+		JobStatus:    status,
+		Success:      status.Phase == api.JobPhaseSucceeded,
+		Preflight:    preflight,
+		Overhead:     overhead,
+		Throttling:   throttling,
+		BuildInfo:    buildInfo,
+		SampleTarget: output.SampleTarget,
+		SampleStats:  output.SampleStats,
+		Backend:      output.Backend,
+		Methodology:  output.Methodology,
+		Language:     string(output.Language),
+		Detection:    output.Detection,
+	}, nil
+}
+
+// buildEphemeralScript builds the script of the ephemeral profiler container.
+// The container sees the target's processes through the shared PID namespace,
+// where the container entrypoint is PID 1 unless --pid names other processes.
+func buildEphemeralScript(cfg *api.ProfileConfig, backend api.Backend, target *api.TargetInfo) (string, error) {
+	pids, err := cfg.PIDs()
+	if err != nil {
+		return "", err
+	}
+	return renderScript(scriptTemplates, ephemeralScriptTemplate, ephemeralScriptData{
+		Preflight: buildPreflightScript(cfg, backend, "", target.Preflight),
+		PIDs:      buildEphemeralPIDsScript(pids),
+		Run:       profilerRunData(backend, cfg, "TARGET_PID"),
+	})
+}
+
+// waitForEphemeralContainer waits until the ephemeral profiler container has
+// terminated, optionally streaming its logs
+func (m *Manager) waitForEphemeralContainer(ctx context.Context, name, namespace, podName string, timeout time.Duration, opts *api.ProfileOptions) (*api.JobStatus, error) {
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		finalStatus *api.JobStatus
+		streaming   sync.WaitGroup
+		streamed    bool
+		running     bool
+	)
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		status, started, err := m.ephemeralStatus(ctx, name, namespace, podName)
+		if err != nil {
+			return false, err
+		}
+
+		if started && !running {
+			running = true
+			opts.Emit(api.ProgressEvent{Type: api.EventPodRunning, JobName: name})
+			opts.Emit(api.ProgressEvent{Type: api.EventSamplingStarted, JobName: name})
+		}
+		if opts.PrintLogs && started && !streamed {
+			streamed = true
+			opts.Log().Info("Streaming logs from ephemeral container", "container", name, "pod", podName)
+			streaming.Add(1)
+			go func() {
+				defer streaming.Done()
+				m.streamContainerLogs(ctx, opts.Log(), podName, namespace, name)
+			}()
+		}
+
+		finalStatus = status
+		switch status.Phase {
+		case api.JobPhaseSucceeded, api.JobPhaseFailed:
+			return true, nil
+		default:
+			return false, nil
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if streamed {
+		streaming.Wait()
+		opts.Log().Info("Log streaming completed")
+	}
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingFinished, JobName: name})
+	return finalStatus, nil
+}
+
+// ephemeralStatus maps the state of an ephemeral profiler container to a Job
+// status, and reports whether the container has started
+func (m *Manager) ephemeralStatus(ctx context.Context, name, namespace, podName string) (*api.JobStatus, bool, error) {
+	pod, err := m.k8sConfig.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, apiError(err, "failed to get pod %s/%s", namespace, podName)
+	}
+
+	status := &api.JobStatus{
+		JobName:   name,
+		Namespace: namespace,
+		Phase:     api.JobPhasePending,
+		PodName:   podName,
+	}
+
+	for _, cs := range pod.Status.EphemeralContainerStatuses {
+		if cs.Name != name {
+			continue
+		}
+		switch {
+		case cs.State.Terminated != nil:
+			terminated := cs.State.Terminated
+			status.StartTime = &terminated.StartedAt.Time
+			status.EndTime = &terminated.FinishedAt.Time
+			if terminated.ExitCode == 0 {
+				status.Phase = api.JobPhaseSucceeded
+			} else {
+				status.Phase = api.JobPhaseFailed
+				status.Message = fmt.Sprintf("ephemeral container exited with code %d: %s", terminated.ExitCode, terminated.Reason)
+			}
+			return status, true, nil
+		case cs.State.Running != nil:
+			status.Phase = api.JobPhaseRunning
+			status.StartTime = &cs.State.Running.StartedAt.Time
+			return status, true, nil
+		case cs.State.Waiting != nil:
+			status.Message = cs.State.Waiting.Reason
+			if failure := m.containerStartFailure(ctx, pod, cs); failure != nil {
+				return nil, false, failure
+			}
+		}
+	}
+	return status, false, nil
+}
@@ -0,0 +1,44 @@
+package job
+
+import (
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// golangBackend samples Go processes with the eBPF profiler of this
+// repository, which symbolizes goroutine stacks and renders the graph itself
+type golangBackend struct{}
+
+func (golangBackend) Name() string {
+	return "golang-profiling"
+}
+
+func (golangBackend) BuildArgs(cfg *api.ProfileConfig) []string {
+	var args []string
+	args = append(args, buildTargetArgs(cfg)...)
+	args = append(args, buildProfileTypeArgs(cfg)...)
+	args = append(args, buildGoOptionArgs(cfg)...)
+	return append(args, buildExportArgs(cfg)...)
+}
+
+func (b golangBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	return fmt.Sprintf(`
+		echo "Starting golang-profiling with arguments: --pid $%[1]s ${EXTRA_PIDS:+--extra-pids $EXTRA_PIDS} --duration %[2]d --output %[4]s" %[3]s
+		/usr/local/bin/golang-profiling --pid $%[1]s ${EXTRA_PIDS:+--extra-pids "$EXTRA_PIDS"} --duration %[2]d --output %[4]s %[3]s
+		PROFILE_EXIT_CODE=$?
+	`, pidVar, int(cfg.Duration.Seconds()), shellJoin(b.BuildArgs(cfg)), api.BackendFlameGraphPath)
+}
+
+func (golangBackend) RequiredMounts() []api.HostMount {
+	return nil
+}
+
+func (golangBackend) RequiredCapabilities() []string {
+	return []string{"SYS_RESOURCE", "SYS_PTRACE", "BPF", "PERFMON"}
+}
+
+// ParseOutput reads the sample summary and target golang-profiling prints
+func (golangBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	return parseSampleOutput(logs)
+}
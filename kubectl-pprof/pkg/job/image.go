@@ -0,0 +1,107 @@
+package job
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// SupportedArchitectures node architectures with a published profiling image
+var SupportedArchitectures = []string{"amd64", "arm64"}
+
+// CheckNodeCompatibility verifies the profiling image can run on the target node
+func CheckNodeCompatibility(node *api.NodeInfo) error {
+	if node == nil {
+		return nil
+	}
+
+	if node.OperatingSystem != "" && node.OperatingSystem != "linux" {
+		return fmt.Errorf("node %s runs %s, profiling is only supported on linux nodes (eBPF is not available on %s)",
+			node.Name, node.OperatingSystem, node.OperatingSystem)
+	}
+
+	if node.Architecture == "" {
+		return nil
+	}
+	for _, arch := range SupportedArchitectures {
+		if node.Architecture == arch {
+			return nil
+		}
+	}
+	return fmt.Errorf("node %s has architecture %s, no profiling image is published for it (supported: %s)",
+		node.Name, node.Architecture, strings.Join(SupportedArchitectures, ", "))
+}
+
+// ResolveImage returns the image reference to run on the given node.
+//
+// By default the image is expected to be a multi-arch manifest list and is used
+// as-is. Registries publishing one tag per architecture can pass a suffix
+// scheme such as "-{arch}", which turns golang-profiling:v1 into
+// golang-profiling:v1-arm64 on arm64 nodes. Digest-pinned references are never
+// rewritten.
+func ResolveImage(image, archSuffix string, node *api.NodeInfo) string {
+	if archSuffix == "" || node == nil || node.Architecture == "" || strings.Contains(image, "@") {
+		return image
+	}
+
+	suffix := strings.ReplaceAll(archSuffix, "{arch}", node.Architecture)
+
+	// Only a colon after the last slash separates the tag, earlier ones belong to the registry port
+	repo, tag := image, "latest"
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i+1:]
+	}
+
+	return fmt.Sprintf("%s:%s%s", repo, tag, suffix)
+}
+
+// digestPattern matches the digests an image can be pinned to
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ValidateDigest checks an image digest given with --image-digest
+func ValidateDigest(digest string) error {
+	if !digestPattern.MatchString(digest) {
+		return fmt.Errorf("invalid image digest %q, expected sha256: followed by 64 lowercase hex digits", digest)
+	}
+	return nil
+}
+
+// MirrorImage moves an image reference to a registry mirror. The registry of
+// the reference, docker.io when it names none, is replaced by the mirror, so
+// golang-profiling:v1 becomes mirror.corp:5000/golang-profiling:v1 and
+// ghcr.io/foo/profiler:v1 becomes mirror.corp:5000/foo/profiler:v1.
+func MirrorImage(image, mirror string) string {
+	if mirror == "" {
+		return image
+	}
+	path := image
+	// Like docker, the first component is a registry only if it looks like a host
+	if first, rest, found := strings.Cut(image, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		path = rest
+	}
+	return strings.TrimSuffix(mirror, "/") + "/" + path
+}
+
+// PinImage pins an image reference to a digest, replacing the digest it may
+// already have. The tag is kept for readability, the runtime ignores it.
+func PinImage(image, digest string) string {
+	if digest == "" {
+		return image
+	}
+	image, _, _ = strings.Cut(image, "@")
+	return image + "@" + digest
+}
+
+// IsPinned reports whether an image reference names its image by digest
+func IsPinned(image string) bool {
+	return strings.Contains(image, "@sha256:")
+}
+
+// ProfilingImage returns the profiling image to run on a node: the configured
+// image moved to the registry mirror, pinned to the digest and, unless pinned,
+// resolved to the tag of the node's architecture
+func ProfilingImage(cfg *api.ProfileConfig, node *api.NodeInfo) string {
+	return ResolveImage(PinImage(MirrorImage(cfg.Image, cfg.RegistryMirror), cfg.ImageDigest), cfg.ImageArchSuffix, node)
+}
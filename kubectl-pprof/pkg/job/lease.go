@@ -0,0 +1,254 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// NodeBusyError reports a node held by the Lease of another session
+type NodeBusyError struct {
+	Node   string
+	Holder string
+	Lease  string
+	Until  time.Time
+}
+
+func (e *NodeBusyError) Error() string {
+	return fmt.Sprintf("node %s is busy being profiled by job %s (lease %s held until %s), retry later or queue with --wait-for-slot",
+		e.Node, e.Holder, e.Lease, e.Until.Format(time.RFC3339))
+}
+
+// leaseRaceAttempts bounds the immediate retries after another writer changed
+// the Lease between reading and writing it
+const leaseRaceAttempts = 3
+
+// leaseHolds counts the sessions of this process holding each Lease, so
+// sessions sharing a holder identity release it only when the last one ends
+type leaseHolds struct {
+	mu    sync.Mutex
+	holds map[string]int
+}
+
+// share joins a Lease this process already holds
+func (h *leaseHolds) share(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.holds[key] == 0 {
+		return false
+	}
+	h.holds[key]++
+	return true
+}
+
+func (h *leaseHolds) add(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.holds == nil {
+		h.holds = make(map[string]int)
+	}
+	h.holds[key]++
+}
+
+// release reports whether the last session holding the Lease ended
+func (h *leaseHolds) release(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.holds[key]--
+	if h.holds[key] > 0 {
+		return false
+	}
+	delete(h.holds, key)
+	return true
+}
+
+// NodeLeaseName returns the name of the Lease guarding a node
+func NodeLeaseName(nodeName string) string {
+	return "kubectl-pprof-" + nodeName
+}
+
+// acquireNodeLease takes the Lease of the target node for the session, queuing
+// up to cfg.WaitForSlot while another session holds it. The holder identity is
+// cfg.LeaseHolder, or the Job name. The returned function releases it.
+// Clusters where Leases cannot be managed are profiled unguarded.
+func (m *Manager) acquireNodeLease(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, nodeName, jobName string) (func(), error) {
+	noop := func() {}
+	if nodeName == "" {
+		return noop, nil
+	}
+
+	namespace := cfg.GetLeaseNamespace()
+	name := NodeLeaseName(nodeName)
+	holder := jobName
+	if cfg.LeaseHolder != "" {
+		holder = cfg.LeaseHolder
+	}
+	key := namespace + "/" + name + "/" + holder
+	// The Lease outlives the wait for the profiler, expired Leases of crashed
+	// sessions are taken over
+	duration := int32(cfg.Timings().Lease.Seconds())
+
+	var (
+		busy   *NodeBusyError
+		shared bool
+	)
+	try := func(ctx context.Context) (bool, error) {
+		if m.leases.share(key) {
+			busy, shared = nil, true
+			return true, nil
+		}
+		var err error
+		busy, err = m.tryNodeLease(ctx, namespace, name, holder, duration)
+		if err != nil {
+			return false, err
+		}
+		if busy != nil {
+			busy.Node = nodeName
+		}
+		return busy == nil, nil
+	}
+
+	acquired, err := try(ctx)
+	if err == nil && !acquired && cfg.WaitForSlot > 0 {
+		opts.Log().Info("Node is being profiled by another session, waiting", "node", nodeName, "job", busy.Holder, "timeout", cfg.WaitForSlot)
+		waitCtx, cancel := context.WithTimeout(ctx, cfg.WaitForSlot)
+		err = wait.PollUntilContextCancel(waitCtx, 2*time.Second, false, try)
+		cancel()
+		if err != nil && ctx.Err() == nil && busy != nil {
+			return nil, busy
+		}
+		acquired = err == nil
+	}
+	if apierrors.IsForbidden(err) {
+		opts.Log().Warn("Cannot manage the node lease, other sessions on the node are not detected", "namespace", namespace, "error", err)
+		return noop, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease of node %s: %w", nodeName, err)
+	}
+	if !acquired {
+		return nil, busy
+	}
+	if !shared {
+		m.leases.add(key)
+	}
+
+	return func() {
+		if !m.leases.release(key) {
+			return
+		}
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		m.releaseNodeLease(releaseCtx, namespace, name, holder)
+	}, nil
+}
+
+// tryNodeLease creates the Lease, renews it for the same holder or takes it
+// over once expired. A non-nil NodeBusyError reports a Lease held by another
+// session.
+func (m *Manager) tryNodeLease(ctx context.Context, namespace, name, holder string, duration int32) (*NodeBusyError, error) {
+	for attempt := 1; ; attempt++ {
+		busy, raced, err := m.tryNodeLeaseOnce(ctx, namespace, name, holder, duration)
+		if !raced || attempt == leaseRaceAttempts {
+			return busy, err
+		}
+	}
+}
+
+// tryNodeLeaseOnce makes one attempt at the Lease, raced reports that another
+// writer changed it meanwhile
+func (m *Manager) tryNodeLeaseOnce(ctx context.Context, namespace, name, holder string, duration int32) (busy *NodeBusyError, raced bool, err error) {
+	leases := m.k8sConfig.Clientset.CoordinationV1().Leases(namespace)
+	now := metav1.NewMicroTime(time.Now())
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"app": "kubectl-pprof"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// Another session was faster, look at its Lease
+			return &NodeBusyError{Holder: "unknown", Lease: namespace + "/" + name, Until: now.Time}, true, nil
+		}
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if until, held := leaseHeldUntil(lease); held && (lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder) {
+		current := "unknown"
+		if lease.Spec.HolderIdentity != nil {
+			current = *lease.Spec.HolderIdentity
+		}
+		return &NodeBusyError{Holder: current, Lease: namespace + "/" + name, Until: until}, false, nil
+	}
+
+	// Renew our own, or take over an expired one; a conflict means another writer was faster
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return &NodeBusyError{Holder: "unknown", Lease: namespace + "/" + name, Until: now.Time}, true, nil
+		}
+		return nil, false, err
+	}
+	return nil, false, nil
+}
+
+// leaseHeldUntil returns when a Lease expires and whether it is still held
+func leaseHeldUntil(lease *coordinationv1.Lease) (time.Time, bool) {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}, false
+	}
+	until := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return until, time.Now().Before(until)
+}
+
+// releaseJobLease releases the Lease a Job started by StartProfilingJob took
+// on its node, when the Job still holds it
+func (m *Manager) releaseJobLease(cfg *api.ProfileConfig, nodeName, jobName string) {
+	if nodeName == "" {
+		return
+	}
+	holder := jobName
+	if cfg.LeaseHolder != "" {
+		holder = cfg.LeaseHolder
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.releaseNodeLease(ctx, cfg.GetLeaseNamespace(), NodeLeaseName(nodeName), holder)
+}
+
+// releaseNodeLease deletes the Lease if holder still holds it
+func (m *Manager) releaseNodeLease(ctx context.Context, namespace, name, holder string) {
+	leases := m.k8sConfig.Clientset.CoordinationV1().Leases(namespace)
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if err != nil || lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+		return
+	}
+	leases.Delete(ctx, name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion},
+	})
+}
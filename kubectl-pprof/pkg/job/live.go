@@ -0,0 +1,108 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+// snapshotArtifact carries the folded stacks sampled so far by a Live session.
+// Unlike the other artifacts it is printed repeatedly, each time on one line,
+// so a follower of the logs can decode it as soon as it appears.
+const snapshotArtifact = "SNAPSHOT"
+
+// snapshotPodPath is where golang-profiling writes its snapshots in the pod
+const snapshotPodPath = "/tmp/profile.snapshot"
+
+// snapshotPollInterval is how often the job script looks for a new snapshot
+const snapshotPollInterval = 5
+
+// buildSnapshotScript starts a background loop printing every new snapshot
+// golang-profiling writes. A snapshot is encoded before it is printed with a
+// single printf, which keeps it on one line next to the profiler's own output.
+func buildSnapshotScript() string {
+	return fmt.Sprintf(`
+		touch %[1]s.sent
+		(
+			while true; do
+				sleep %[2]d
+				if [ %[1]s -nt %[1]s.sent ]; then
+					touch %[1]s.sent
+					SNAPSHOT=$(gzip -c %[1]s | base64 -w 0)
+					printf '%[3]s_START:%%s\n%[3]s_END\n' "$SNAPSHOT"
+				fi
+			done
+		) &
+		SNAPSHOT_PID=$!
+	`, snapshotPodPath, snapshotPollInterval, snapshotArtifact)
+}
+
+// stopSnapshotScript stops the loop started by buildSnapshotScript
+const stopSnapshotScript = `kill $SNAPSHOT_PID 2>/dev/null`
+
+// followSnapshots hands the snapshots a Live session prints to opts.Snapshot
+// as they appear in the profiler logs. The returned function stops following
+// and waits for the snapshot being handled, so none lands after the result.
+func (m *Manager) followSnapshots(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName, namespace string) func() {
+	if !cfg.Live || opts.Snapshot == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// The logs can only be followed once the profiler container started
+		var logs io.ReadCloser
+		err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+			var err error
+			logs, err = m.openJobLogs(ctx, jobName, namespace, true)
+			return err == nil, nil
+		})
+		if err != nil {
+			return
+		}
+		defer logs.Close()
+
+		if err := scanSnapshots(logs, opts.Snapshot); err != nil && ctx.Err() == nil {
+			opts.Log().Log(ctx, logging.V(1), "Stopped following live snapshots", "error", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// scanSnapshots decodes the snapshots of a log stream until it ends. A
+// snapshot garbled by output interleaved with it is skipped, the next one
+// replaces it anyway.
+func scanSnapshots(logs io.Reader, handle func(folded []byte)) error {
+	startMarker := snapshotArtifact + "_START:"
+	reader := bufio.NewReader(logs)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if encoded, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), startMarker); ok {
+			if folded, decodeErr := decodePayload(encoded, "snapshot"); decodeErr == nil {
+				handle(folded)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading logs: %w", err)
+		}
+	}
+}
@@ -2,198 +2,408 @@ package job
 
 import (
 	"bufio"
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"regexp"
+	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/wait"
 
-	"github.com/withlin/kubectl-pprof/internal/types"
+	"github.com/withlin/kubectl-pprof/pkg/api"
 	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+	"github.com/withlin/kubectl-pprof/pkg/policy"
 )
 
 // Manager simplified Job manager
 type Manager struct {
 	k8sConfig *config.KubernetesConfig
 	cleaner   *JobCleaner
+	ephemeral ephemeralSessions
+	leases    leaseHolds
+	podLogs   PodLogsFunc
+	languages *api.LanguageManager
+	logger    *slog.Logger
+	served    servedArtifacts
+}
+
+// PodLogsFunc opens the log stream of a container
+type PodLogsFunc func(ctx context.Context, namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithPodLogs reads container logs through open instead of the API server,
+// so the artifacts and reports of scripted logs go through the real parsers
+func WithPodLogs(open PodLogsFunc) ManagerOption {
+	return func(m *Manager) {
+		m.podLogs = open
+	}
+}
+
+// WithLanguages profiles each language with the Backend registered in lm
+// instead of the ones NewLanguageManager registers
+func WithLanguages(lm *api.LanguageManager) ManagerOption {
+	return func(m *Manager) {
+		m.languages = lm
+	}
+}
+
+// WithLogger logs what the Manager does outside of a session, such as
+// resuming an interrupted artifact download, instead of slog.Default()
+func WithLogger(logger *slog.Logger) ManagerOption {
+	return func(m *Manager) {
+		m.logger = logger
+	}
 }
 
 // NewManager creates a new Job manager
-func NewManager(k8sConfig *config.KubernetesConfig) (*Manager, error) {
+func NewManager(k8sConfig *config.KubernetesConfig, options ...ManagerOption) (*Manager, error) {
 	// Create cleaner
 	cleaner := NewJobCleaner(k8sConfig.Clientset, nil, nil)
 
-	return &Manager{
+	m := &Manager{
 		k8sConfig: k8sConfig,
 		cleaner:   cleaner,
-	}, nil
+		languages: NewLanguageManager(),
+	}
+	for _, option := range options {
+		option(m)
+	}
+	if m.logger == nil {
+		m.logger = slog.Default()
+	}
+	return m, nil
 }
 
-// CreateProfilingJobWithMonitoring creates a profiling Job and monitors execution
-func (m *Manager) CreateProfilingJobWithMonitoring(ctx context.Context, cfg *types.ProfileConfig, opts *types.ProfileOptions, target *types.TargetInfo) (*types.ProfileResult, error) {
-	// Generate Job name
-	jobName := fmt.Sprintf("kubectl-pprof-%d", time.Now().Unix())
+// streamLogs opens the log stream of a container
+func (m *Manager) streamLogs(ctx context.Context, namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	if m.podLogs != nil {
+		return m.podLogs(ctx, namespace, podName, opts)
+	}
+	return m.k8sConfig.Clientset.CoreV1().Pods(namespace).GetLogs(podName, opts).Stream(ctx)
+}
 
-	// Create Job
-	job := m.buildJobSpec(jobName, cfg, opts, target)
-	_, err := m.k8sConfig.Clientset.BatchV1().Jobs(cfg.Namespace).Create(ctx, job, metav1.CreateOptions{})
+// CreateProfilingJobWithMonitoring creates a profiling Job and monitors execution.
+// The finished Job is left to the caller to delete once its artifacts are read.
+func (m *Manager) CreateProfilingJobWithMonitoring(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	jobNamespace := cfg.GetJobNamespace()
+	if _, err := ParseTolerations(cfg.Tolerations); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.PIDs(); err != nil {
+		return nil, err
+	}
+	backend, methodology, err := m.backend(cfg, opts, target)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create job: %w", err)
+		return nil, err
+	}
+	if methodology != "" {
+		opts.Log().Warn("eBPF unavailable on the node, falling back", "backend", backend.Name(), "note", methodology)
 	}
 
+	jobName, release, err := m.createJob(ctx, cfg, opts, target, backend, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	stopSnapshots := m.followSnapshots(ctx, cfg, opts, jobName, jobNamespace)
+	defer stopSnapshots()
+	stopFetching := m.followServedArtifacts(ctx, cfg, opts, jobName, jobNamespace)
+	defer stopFetching()
+	opts.Emit(api.ProgressEvent{
+		Type:      api.EventJobCreated,
+		Namespace: target.Namespace,
+		PodName:   target.PodName,
+		Container: target.ContainerName,
+		NodeName:  target.NodeName,
+		JobName:   jobName,
+	})
+
 	// Wait for Job completion, decide whether to print logs based on PrintLogs parameter
-	var status *types.JobStatus
+	var (
+		status  *api.JobStatus
+		timings = cfg.Timings()
+	)
 	if opts.PrintLogs {
-		status, err = m.WaitForCompletionWithLogs(ctx, jobName, cfg.Namespace, 5*time.Minute)
+		status, err = m.WaitForCompletionWithLogs(ctx, opts, jobName, jobNamespace, timings.Monitor)
 	} else {
-		status, err = m.WaitForCompletion(ctx, jobName, cfg.Namespace, 5*time.Minute)
+		status, err = m.WaitForCompletion(ctx, opts, jobName, jobNamespace, timings.Monitor)
 	}
+	stopSnapshots()
+	stopFetching()
 	if err != nil {
+		// An aborted session stops sampling at once instead of at its deadline,
+		// before returning so an interrupted CLI does not exit first; a Job
+		// whose pod was refused or cannot start would only wait for its
+		// deadline
+		var (
+			rejected    *AdmissionRejectedError
+			startFailed *PodStartError
+		)
+		if (ctx.Err() != nil || errors.As(err, &rejected) || errors.As(err, &startFailed)) && !cfg.RetainJob(false) {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), timings.Cleanup)
+			m.DeleteJob(cleanupCtx, jobName, jobNamespace)
+			cancel()
+		}
 		return nil, fmt.Errorf("job execution failed: %w", err)
 	}
 
+	result, err := m.jobResult(ctx, cfg, opts, target, backend, methodology, jobName, status)
+	if err != nil {
+		return nil, err
+	}
+
 	// Extract flame graph content from logs (temporarily commented out to simplify implementation)
-	// flameGraphData, err := m.extractFlameGraphFromLogs(ctx, jobName, cfg.Namespace)
+	// flameGraphData, err := m.extractFlameGraphFromLogs(ctx, jobName, jobNamespace)
 	// if err != nil {
 	//	return nil, fmt.Errorf("failed to extract flamegraph from logs: %w", err)
 	// }
 
-	// Clean up Job
-	go func() {
-		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		m.DeleteJob(cleanupCtx, jobName, cfg.Namespace)
-	}()
-
-	return &types.ProfileResult{
-		JobName:   jobName,
-		JobStatus: status,
-		Success:   status.Phase == types.JobPhaseSucceeded,
-	}, nil
+	// The caller deletes the Job once it read the artifacts from its logs,
+	// which go with its pod; ttlSecondsAfterFinished collects the ones kept
+	return result, nil
 }
 
-// extractFlameGraphFromLogs extracts flame graph content from Pod logs
-func (m *Manager) extractFlameGraphFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
-	// Get Pods associated with the Job
-	pods, err := m.k8sConfig.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %w", err)
+// createJob creates the profiling Job, under a fresh name when a concurrent
+// run took the generated one, while holding the node so no other session
+// samples it meanwhile. annotate, when set, edits the Job before it is
+// created. The returned function releases the node.
+func (m *Manager) createJob(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, backend api.Backend, annotate func(*batchv1.Job) error) (string, func(), error) {
+	jobNamespace := cfg.GetJobNamespace()
+	for attempt := 1; ; attempt++ {
+		jobName := GenerateJobName(cfg, jobTargetName(target))
+		job, err := m.buildJobSpec(jobName, cfg, opts, target, backend)
+		if err != nil {
+			return "", nil, err
+		}
+		if job, err = applyJobTemplate(job, cfg.JobTemplate); err != nil {
+			return "", nil, err
+		}
+		if annotate != nil {
+			if err := annotate(job); err != nil {
+				return "", nil, err
+			}
+		}
+		release, err := m.acquireNodeLease(ctx, cfg, opts, target.NodeName, jobName)
+		if err != nil {
+			return "", nil, err
+		}
+		_, err = m.k8sConfig.Clientset.BatchV1().Jobs(jobNamespace).Create(ctx, job, metav1.CreateOptions{})
+		if err == nil {
+			return jobName, release, nil
+		}
+		release()
+		if rejection := admissionRejection(api.ModeJob, jobName, err); rejection != nil {
+			return "", nil, rejection
+		}
+		if !apierrors.IsAlreadyExists(err) || attempt == jobNameAttempts {
+			return "", nil, apiError(err, "failed to create job %s/%s", jobNamespace, jobName)
+		}
 	}
+}
 
-	if len(pods.Items) == 0 {
-		return nil, fmt.Errorf("no pods found for job %s", jobName)
-	}
+// jobResult reads the outcome of a finished profiling Job from its logs
+func (m *Manager) jobResult(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, backend api.Backend, methodology, jobName string, status *api.JobStatus) (*api.ProfileResult, error) {
+	jobNamespace := cfg.GetJobNamespace()
 
-	pod := pods.Items[0]
+	// Missing logs are tolerated so that the result can still be collected
+	logs, _ := m.readJobLogs(ctx, jobName, jobNamespace)
+	if cfg.DirectExec {
+		if err := launcherFailure(logs); err != nil {
+			return nil, err
+		}
+	}
 
-	// Get Pod logs
-	req := m.k8sConfig.Clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
-		Container: "profiler",
-	})
+	// Surface kernel preflight results before looking at the profile itself
+	preflight, err := checkPreflight(logs, target)
+	if err != nil {
+		return nil, err
+	}
 
-	logs, err := req.Stream(ctx)
+	overhead := parseOverhead(logs)
+	throttling, _ := parseThrottling(logs)
+	buildInfo, _ := parseBuildInfo(logs)
+	output, err := backend.ParseOutput(logs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pod logs: %w", err)
+		return nil, fmt.Errorf("failed to parse %s output: %w", backend.Name(), err)
 	}
-	defer logs.Close()
+	completeBackendOutput(output, backend, methodology, cfg, opts)
+
+	return &api.ProfileResult{
+		JobName:      jobName,
+		JobStatus:    status,
+		Success:      status.Phase == api.JobPhaseSucceeded,
+		Preflight:    preflight,
+		Overhead:     overhead,
+		Throttling:   throttling,
+		BuildInfo:    buildInfo,
+		SampleTarget: output.SampleTarget,
+		SampleStats:  output.SampleStats,
+		Backend:      output.Backend,
+		Methodology:  output.Methodology,
+		Language:     string(output.Language),
+		Detection:    output.Detection,
+	}, nil
+}
 
-	// Parse logs to find flame graph content
-	scanner := bufio.NewScanner(logs)
-	var flameGraphContent strings.Builder
-	inFlameGraph := false
+// jobTargetName names the target in the Job name and annotations: its pod,
+// or the node of a host process
+func jobTargetName(target *api.TargetInfo) string {
+	if target.HostProcess != "" {
+		return "node-" + target.NodeName
+	}
+	return target.PodName
+}
 
-	// Define flame graph start and end markers
-	flameGraphStartPattern := regexp.MustCompile(`^FLAMEGRAPH_START:(.*)$`)
-	flameGraphEndPattern := regexp.MustCompile(`^FLAMEGRAPH_END$`)
+// maxJobNamePrefix leaves room for the run suffixes in a 63 character name
+const maxJobNamePrefix = 52
 
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if matches := flameGraphStartPattern.FindStringSubmatch(line); matches != nil {
-			// Found flame graph start marker
-			inFlameGraph = true
-			if len(matches) > 1 && matches[1] != "" {
-				// If start marker contains content, add to flame graph
-				flameGraphContent.WriteString(matches[1])
-			}
-			continue
-		}
+// jobNameSuffixLen is the length of the random suffix of generated names
+const jobNameSuffixLen = 5
 
-		if flameGraphEndPattern.MatchString(line) {
-			// Found flame graph end marker
-			inFlameGraph = false
-			break
-		}
+// jobNameAttempts bounds the retries after a generated Job name was taken
+const jobNameAttempts = 3
 
-		if inFlameGraph {
-			// In flame graph content area, collect all lines
-			flameGraphContent.WriteString(line)
-			flameGraphContent.WriteString("\n")
-		}
+// JobNamePrefix returns the configured Job name prefix, shortened to fit
+func JobNamePrefix(cfg *api.ProfileConfig) string {
+	prefix := cfg.JobName
+	if prefix == "" {
+		prefix = "kubectl-pprof"
+	}
+	if len(prefix) > maxJobNamePrefix {
+		prefix = strings.TrimRight(prefix[:maxJobNamePrefix], "-")
 	}
+	return prefix
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading logs: %w", err)
+// GenerateJobName returns a unique name for one profiling run: the configured
+// prefix, the target pod name and a random suffix, shortened to a 63
+// character DNS label
+func GenerateJobName(cfg *api.ProfileConfig, podName string) string {
+	name := JobNamePrefix(cfg)
+	if podName != "" {
+		name += "-" + strings.ReplaceAll(podName, ".", "-")
+	}
+	if room := validation.DNS1123LabelMaxLength - jobNameSuffixLen - 1; len(name) > room {
+		name = name[:room]
 	}
+	return strings.TrimRight(name, "-") + "-" + rand.String(jobNameSuffixLen)
+}
+
+// JobNameWithSuffix returns the Job name prefix with a suffix identifying one
+// of several concurrent runs, shortening the prefix so the suffix survives
+func JobNameWithSuffix(cfg *api.ProfileConfig, suffix string) string {
+	prefix := JobNamePrefix(cfg)
+	if room := maxJobNamePrefix - len(suffix) - 1; len(prefix) > room {
+		prefix = strings.TrimRight(prefix[:room], "-")
+	}
+	return prefix + "-" + suffix
+}
+
+// openJobLogs opens the profiler container log stream of the Job's pod,
+// following it while the container runs with follow
+func (m *Manager) openJobLogs(ctx context.Context, jobName, namespace string, follow bool) (io.ReadCloser, error) {
+	return m.openJobLogsWith(ctx, jobName, namespace, corev1.PodLogOptions{Follow: follow})
+}
 
-	if flameGraphContent.Len() == 0 {
-		return nil, fmt.Errorf("no flamegraph content found in logs")
+// openJobLogsWith opens the profiler container log stream of the Job's pod
+// with the given options, the container is filled in
+func (m *Manager) openJobLogsWith(ctx context.Context, jobName, namespace string, logOpts corev1.PodLogOptions) (io.ReadCloser, error) {
+	// Ephemeral profiler containers log in the target pod
+	if session, ok := m.ephemeral.get(jobName); ok {
+		logOpts.Container = session.container
+		logs, err := m.streamLogs(ctx, session.namespace, session.pod, &logOpts)
+		if err != nil {
+			return nil, apiError(err, "failed to get logs of ephemeral container %s", session.container)
+		}
+		return logs, nil
 	}
 
-	// Decode base64 content and decompress gzip
-	content := strings.TrimSpace(flameGraphContent.String())
-	if content == "" {
-		return nil, fmt.Errorf("empty flamegraph content")
+	podName, err := m.jobPodName(ctx, jobName, namespace)
+	if err != nil {
+		return nil, err
 	}
 
-	// Decode base64
-	decodedData, err := base64.StdEncoding.DecodeString(content)
+	// Get Pod logs
+	logOpts.Container = "profiler"
+	logs, err := m.streamLogs(ctx, namespace, podName, &logOpts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
+		return nil, apiError(err, "failed to get logs of pod %s/%s", namespace, podName)
 	}
+	return logs, nil
+}
 
-	// Decompress gzip
-	gzipReader, err := gzip.NewReader(bytes.NewReader(decodedData))
+// readJobLogs reads the complete profiler container logs of the Job's pod
+func (m *Manager) readJobLogs(ctx context.Context, jobName, namespace string) (string, error) {
+	logs, err := m.openJobLogs(ctx, jobName, namespace, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		return "", err
 	}
-	defer gzipReader.Close()
+	defer logs.Close()
 
-	// Read decompressed content
-	decompressedData, err := io.ReadAll(gzipReader)
+	data, err := io.ReadAll(logs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decompress gzip content: %w", err)
+		return "", fmt.Errorf("error reading logs: %w", err)
 	}
+	return string(data), nil
+}
 
-	return decompressedData, nil
+// extractFlameGraphFromLogs extracts flame graph content from Pod logs
+func (m *Manager) extractFlameGraphFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return m.extractArtifactFromLogs(ctx, jobName, namespace, flameGraphArtifact)
 }
 
 // buildJobSpec builds Job specification
-func (m *Manager) buildJobSpec(jobName string, cfg *types.ProfileConfig, opts *types.ProfileOptions, target *types.TargetInfo) *batchv1.Job {
-	// Build profiling script
-	script := m.buildAdvancedProfilingScript(target, cfg)
+func (m *Manager) buildJobSpec(jobName string, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, backend api.Backend) (*batchv1.Job, error) {
+	// Build profiling script, the target reaches it through scriptEnv; with
+	// --direct-exec the launcher runs the profiler instead
+	if err := validateScriptTarget(target); err != nil {
+		return nil, err
+	}
+	command, args, env := []string{"/bin/sh"}, []string{"-c"}, scriptEnv(cfg, target)
+	if cfg.DirectExec {
+		var err error
+		if command, args, err = buildDirectCommand(cfg, target, backend); err != nil {
+			return nil, err
+		}
+		env = []corev1.EnvVar{{Name: "PROC_ROOT", Value: "/host/proc"}}
+	} else {
+		script, err := m.buildAdvancedProfilingScript(target, cfg, backend)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, script)
+	}
+	socket := RuntimeSocket(cfg, target)
+	volumes, mounts := backendVolumes(backend)
 
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      jobName,
-			Namespace: cfg.Namespace,
+			Namespace: cfg.GetJobNamespace(),
 			Labels: map[string]string{
 				"app": "kubectl-pprof",
 			},
+			Annotations: map[string]string{
+				// The Job may live outside the target namespace, record what it profiles
+				policy.JobAnnotationTarget:   fmt.Sprintf("%s/%s", target.Namespace, jobTargetName(target)),
+				policy.JobAnnotationDuration: cfg.Duration.String(),
+			},
 		},
 		Spec: batchv1.JobSpec{
-			BackoffLimit: &[]int32{0}[0],
+			BackoffLimit:          &[]int32{0}[0],
+			ActiveDeadlineSeconds: &[]int64{int64(cfg.Timings().ActiveDeadline / time.Second)}[0],
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
@@ -201,37 +411,30 @@ func (m *Manager) buildJobSpec(jobName string, cfg *types.ProfileConfig, opts *t
 					},
 				},
 				Spec: corev1.PodSpec{
-					RestartPolicy: corev1.RestartPolicyNever,
-					HostPID:       true,
+					RestartPolicy:                 corev1.RestartPolicyNever,
+					HostPID:                       true,
+					TerminationGracePeriodSeconds: &[]int64{terminationGracePeriod}[0],
 					NodeSelector: map[string]string{
 						"kubernetes.io/hostname": target.NodeName,
 					},
-					Tolerations: []corev1.Toleration{
-						{
-							Operator: corev1.TolerationOpExists,
-						},
-					},
+					Tolerations: jobTolerations(cfg, target),
 					Containers: []corev1.Container{
 						{
 							Name:            "profiler",
-							Image:           cfg.Image,
-							Command:         []string{"/bin/sh"},
-							Args:            []string{"-c", script},
+							Image:           ProfilingImage(cfg, target.NodeInfo),
+							Command:         command,
+							Args:            args,
+							Env:             env,
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							SecurityContext: &corev1.SecurityContext{
 								Privileged: &[]bool{true}[0],
 								RunAsUser:  &[]int64{0}[0],
 								Capabilities: &corev1.Capabilities{
-									Add: []corev1.Capability{
-										"SYS_ADMIN",
-										"SYS_RESOURCE",
-										"SYS_PTRACE",
-										"BPF",
-										"PERFMON",
-									},
+									// crictl and nsenter need SYS_ADMIN whatever the backend
+									Add: backendCapabilities(backend, "SYS_ADMIN"),
 								},
 							},
-							VolumeMounts: []corev1.VolumeMount{
+							VolumeMounts: append([]corev1.VolumeMount{
 								{
 									Name:      "proc",
 									MountPath: "/host/proc",
@@ -243,8 +446,8 @@ func (m *Manager) buildJobSpec(jobName string, cfg *types.ProfileConfig, opts *t
 									ReadOnly:  true,
 								},
 								{
-									Name:      "containerd-sock",
-									MountPath: "/run/containerd/containerd.sock",
+									Name:      "cri-sock",
+									MountPath: socket,
 									ReadOnly:  true,
 								},
 								{
@@ -252,10 +455,10 @@ func (m *Manager) buildJobSpec(jobName string, cfg *types.ProfileConfig, opts *t
 									MountPath: "/usr/local/bin/crictl",
 									ReadOnly:  true,
 								},
-							},
+							}, mounts...),
 						},
 					},
-					Volumes: []corev1.Volume{
+					Volumes: append([]corev1.Volume{
 						{
 							Name: "proc",
 							VolumeSource: corev1.VolumeSource{
@@ -273,10 +476,10 @@ func (m *Manager) buildJobSpec(jobName string, cfg *types.ProfileConfig, opts *t
 							},
 						},
 						{
-							Name: "containerd-sock",
+							Name: "cri-sock",
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/run/containerd/containerd.sock",
+									Path: socket,
 								},
 							},
 						},
@@ -288,113 +491,253 @@ func (m *Manager) buildJobSpec(jobName string, cfg *types.ProfileConfig, opts *t
 								},
 							},
 						},
-					},
+					}, volumes...),
 				},
 			},
 		},
 	}
 
-	return job
+	// Let the TTL controller collect Jobs no session deletes, e.g. after an interrupt
+	if cfg.JobTTL > 0 {
+		ttl := int32(cfg.JobTTL / time.Second)
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+
+	// Never share a node with another session when profiling many nodes at once
+	if cfg.NodeAntiAffinity {
+		job.Spec.Template.Spec.Affinity = nodeAntiAffinity()
+	}
+
+	// Rank the profiler against the node's workloads: a high priority keeps it
+	// from being preempted mid-sample, a class with preemptionPolicy Never
+	// also keeps it from preempting them
+	if cfg.PriorityClass != "" {
+		job.Spec.Template.Spec.PriorityClassName = cfg.PriorityClass
+	}
+
+	// Run under a specific ServiceAccount and authenticate image pulls
+	if cfg.ServiceAccount != "" {
+		job.Spec.Template.Spec.ServiceAccountName = cfg.ServiceAccount
+	}
+	for _, secret := range cfg.ImagePullSecrets {
+		job.Spec.Template.Spec.ImagePullSecrets = append(job.Spec.Template.Spec.ImagePullSecrets, corev1.LocalObjectReference{
+			Name: secret,
+		})
+	}
+
+	// OpenShift admits privileged pods through SecurityContextConstraints
+	applyOpenShift(job, cfg)
+
+	// Let the policy webhook check the sampling frequency, 0 for traced
+	// profiles which sample nothing
+	job.Annotations[policy.JobAnnotationFrequency] = strconv.Itoa(max(cfg.SamplingFrequency(), 0))
+
+	return job, nil
 }
 
 // buildProfilingArgs builds profiling arguments
-func (m *Manager) buildProfilingArgs(cfg *types.ProfileConfig, opts *types.ProfileOptions, target *types.TargetInfo) []string {
+func (m *Manager) buildProfilingArgs(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) []string {
 	args := []string{
 		"--pid", fmt.Sprintf("%d", target.PID),
 		"--duration", fmt.Sprintf("%.0f", cfg.Duration.Seconds()),
 	}
 
-	if cfg.GoOptions != nil && cfg.GoOptions.Frequency > 0 {
-		args = append(args, "--frequency", fmt.Sprintf("%d", cfg.GoOptions.Frequency))
+	return append(args, golangBackend{}.BuildArgs(cfg)...)
+}
+
+// buildAdvancedProfilingScript builds the script of the profiling Job from
+// the Job script template, the embedded one unless --script-template
+// replaces it
+func (m *Manager) buildAdvancedProfilingScript(target *api.TargetInfo, cfg *api.ProfileConfig, backend api.Backend) (string, error) {
+	templates, err := LoadScriptTemplate(cfg.ScriptTemplate)
+	if err != nil {
+		return "", err
+	}
+	data := jobScriptData{
+		Preflight: buildPreflightScript(cfg, backend, "/host", target.Preflight),
+		Run:       profilerRunData(backend, cfg, "CONTAINER_PID"),
 	}
+	if target.HostProcess != "" {
+		data.HostProcess = buildHostProcessScript()
+	} else {
+		// Validated before the Job is built
+		pids, _ := cfg.PIDs()
+		data.Fallbacks = buildRuntimeStateScript() + buildProcScanScript()
+		data.PIDs = buildContainerPIDsScript(pids)
+	}
+	return renderScript(templates, jobScriptTemplate, data)
+}
 
-	if cfg.GoOptions != nil && cfg.GoOptions.Width > 0 {
-		args = append(args, "--width", fmt.Sprintf("%d", cfg.GoOptions.Width))
+// buildTargetArgs builds the golang-profiling arguments that decide which
+// processes around the target PID are sampled and which of their frames are kept
+func buildTargetArgs(cfg *api.ProfileConfig) []string {
+	var args []string
+	if cfg.IncludeChildren {
+		args = append(args, "--include-children")
+	}
+	if cfg.CgroupOnly {
+		args = append(args, "--cgroup-only")
+	}
+	if cfg.AllProcesses {
+		args = append(args, "--all-processes")
+	}
+	if cfg.SeparateProcesses {
+		args = append(args, "--separate-processes")
+	}
+	if cfg.IncludeKernelStacks {
+		args = append(args, "--include-kernel-stacks")
+	}
+	if cfg.PerCPU {
+		args = append(args, "--per-cpu")
+	}
+	if cfg.GetAnnotate() == api.AnnotateThread {
+		args = append(args, "--per-thread")
+	}
+	if cfg.GetGranularity() == api.GranularityLines {
+		args = append(args, "--granularity", api.GranularityLines)
+	}
+	return args
+}
+
+// buildProfileTypeArgs builds the golang-profiling arguments that pick what is measured
+func buildProfileTypeArgs(cfg *api.ProfileConfig) []string {
+	switch cfg.ProfileType {
+	case api.ProfileTypeSchedLat:
+		return []string{"--sched-latency"}
+	case api.ProfileTypeNet:
+		args := []string{"--net"}
+		if cfg.NetThreshold > 0 {
+			args = append(args, "--net-threshold-us", fmt.Sprintf("%d", cfg.NetThreshold.Microseconds()))
+		}
+		return args
 	}
+	if cfg.MinSamples > 0 {
+		return []string{"--min-samples", fmt.Sprintf("%d", cfg.MinSamples)}
+	}
+	return nil
+}
 
-	if cfg.GoOptions != nil && cfg.GoOptions.Height > 0 {
+// buildGoOptionArgs builds golang-profiling flame graph arguments from GoOptions
+func buildGoOptionArgs(cfg *api.ProfileConfig) []string {
+	var args []string
+	if cfg.GoOptions == nil {
+		return args
+	}
+
+	if cfg.GoOptions.Frequency > 0 {
+		args = append(args, "--frequency", fmt.Sprintf("%d", cfg.GoOptions.Frequency))
+	}
+	if cfg.GoOptions.Title != "" {
+		args = append(args, "--title", cfg.GoOptions.Title)
+	}
+	if cfg.GoOptions.Subtitle != "" {
+		args = append(args, "--subtitle", cfg.GoOptions.Subtitle)
+	}
+	if cfg.GoOptions.OffCPU {
+		args = append(args, "--off-cpu")
+	}
+	if cfg.GoOptions.Colors != "" {
+		args = append(args, "--colors", cfg.GoOptions.Colors)
+	}
+	if cfg.GoOptions.BgColors != "" {
+		args = append(args, "--bgcolors", cfg.GoOptions.BgColors)
+	}
+	if cfg.GoOptions.Width > 0 {
+		args = append(args, "--width", fmt.Sprintf("%d", cfg.GoOptions.Width))
+	}
+	if cfg.GoOptions.Height > 0 {
 		args = append(args, "--height", fmt.Sprintf("%d", cfg.GoOptions.Height))
 	}
+	if cfg.GoOptions.FontType != "" {
+		args = append(args, "--fonttype", cfg.GoOptions.FontType)
+	}
+	if cfg.GoOptions.FontSize > 0 {
+		args = append(args, "--fontsize", strconv.FormatFloat(cfg.GoOptions.FontSize, 'f', -1, 64))
+	}
+	if cfg.GoOptions.Inverted {
+		args = append(args, "--inverted")
+	}
+	if cfg.GoOptions.FlameChart {
+		args = append(args, "--flamechart")
+	}
+	if cfg.GoOptions.Hash {
+		args = append(args, "--hash")
+	}
+	if cfg.GoOptions.Random {
+		args = append(args, "--random")
+	}
+
+	return args
+}
+
+// exportsFolded reports whether folded stacks should be shipped back to the
+// client, which breaks per-CPU profiles down from their cpuN root frames,
+// lists source lines, analyzes and summarizes the stacks from them
+func exportsFolded(cfg *api.ProfileConfig) bool {
+	return cfg.PerCPU || cfg.List != "" || cfg.Analyze || cfg.ExportSummary != "" || cfg.GoOptions != nil && (cfg.GoOptions.ExportFolded != "" || cfg.GoOptions.ClientRender)
+}
+
+// exportsTimeline reports whether time-ordered stacks should be shipped back,
+// flame charts keep them so the chart can be re-rendered client-side
+func exportsTimeline(cfg *api.ProfileConfig) bool {
+	return cfg.GoOptions != nil && cfg.GoOptions.FlameChart
+}
 
+// buildExportArgs builds the golang-profiling arguments for extra artifacts.
+// ExportFolded is a local path, the job always writes to a fixed pod path.
+func buildExportArgs(cfg *api.ProfileConfig) []string {
+	var args []string
+	if cfg.ProfileType == api.ProfileTypeSchedLat {
+		args = append(args, "--export-histogram", schedLatPodPath)
+	}
+	if cfg.ProfileType == api.ProfileTypeNet {
+		args = append(args, "--export-net", netPodPath)
+	}
+	if exportsFolded(cfg) {
+		args = append(args, "--export-folded", foldedPodPath)
+	}
+	if exportsTimeline(cfg) {
+		args = append(args, "--export-timeline", timelinePodPath)
+	}
+	if cfg.Live {
+		args = append(args, "--snapshot", snapshotPodPath, "--snapshot-interval", fmt.Sprintf("%.0f", cfg.SnapshotInterval().Seconds()))
+	}
 	return args
 }
 
-// buildAdvancedProfilingScript builds advanced profiling script
-func (m *Manager) buildAdvancedProfilingScript(target *types.TargetInfo, cfg *types.ProfileConfig) string {
-	// Convert duration to seconds
-	durationSeconds := int(cfg.Duration.Seconds())
-
-	return fmt.Sprintf(`		
-		# Get target container ID (using grep to match container name)
-		CONTAINER_ID=$(crictl --runtime-endpoint unix:///run/containerd/containerd.sock ps | grep -w "%s" | awk '{print $1}' | head -1)
-		if [ -z "$CONTAINER_ID" ]; then
-			echo "Error: Container %s not found"
-			echo "Available containers:"
-			crictl --runtime-endpoint unix:///run/containerd/containerd.sock ps
-			exit 1
-		fi
-		
-		echo "Found container ID: $CONTAINER_ID"
-		
-		# Get container PID
-		CONTAINER_PID=$(crictl --runtime-endpoint unix:///run/containerd/containerd.sock inspect "$CONTAINER_ID" | grep '"pid"' | head -1 | awk '{print $2}' | tr -d ',')
-		if [ -z "$CONTAINER_PID" ]; then
-			echo "Error: Cannot get PID for container $CONTAINER_ID"
-			exit 1
-		fi
-		
-		echo "Found target container PID: $CONTAINER_PID"
-		
-		# Check if PID exists
-		if [ ! -d "/host/proc/$CONTAINER_PID" ]; then
-			echo "Error: Process $CONTAINER_PID not found in /host/proc"
-			echo "Available processes:"
-			ls /host/proc/ | grep '^[0-9]*$' | head -10
-			exit 1
-		fi
-		
-		# Use nsenter to enter target container namespace and run profiling
-		# Need to use host proc filesystem
-		PROC_PATH="/host/proc/$CONTAINER_PID"
-		if [ ! -d "$PROC_PATH/ns" ]; then
-			echo "Error: Cannot access namespace files at $PROC_PATH/ns"
-			echo "Available proc entries:"
-			ls /host/proc/ | grep '^[0-9]*$' | head -5
-			exit 1
-		fi
-		
-		# Run golang-profiling directly on host, specifying target PID
-		# Set PROC_ROOT environment variable to point to host proc filesystem
-		export PROC_ROOT=/host/proc
-		echo "Starting golang-profiling with arguments: --pid $CONTAINER_PID --duration %d --output /tmp/profile.svg"
-		/usr/local/bin/golang-profiling --pid $CONTAINER_PID --duration %d --output /tmp/profile.svg
-		PROFILE_EXIT_CODE=$?
-		echo "golang-profiling exit code: $PROFILE_EXIT_CODE"
-		if [ $PROFILE_EXIT_CODE -eq 0 ]; then
-			echo "Profiling completed successfully"
-			ls -la /tmp/profile.svg
-			
-			# Output flame graph content to logs (using gzip compression and base64 encoding)
-			echo -n "FLAMEGRAPH_START:"
-			gzip -c /tmp/profile.svg | base64 -w 0
-			echo ""
-			echo "FLAMEGRAPH_END"
-			
-			# Create completion marker file
-			echo "PROFILING_COMPLETED" > /tmp/profiling_done
-			echo "Profiling completed and flamegraph output to logs"
-		else
-			echo "Profiling failed with exit code: $PROFILE_EXIT_CODE"
-		fi
-	`, target.ContainerName, target.ContainerName, durationSeconds, durationSeconds)
+// shellQuote quotes a value for safe use as a single POSIX shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// shellJoin quotes and joins arguments for a shell command line
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
 }
 
 // WaitForCompletion waits for Job completion
-func (m *Manager) WaitForCompletion(ctx context.Context, jobName string, namespace string, timeout time.Duration) (*types.JobStatus, error) {
+func (m *Manager) WaitForCompletion(ctx context.Context, opts *api.ProfileOptions, jobName string, namespace string, timeout time.Duration) (*api.JobStatus, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	var finalStatus *types.JobStatus
+	return m.pollJob(ctx, opts, jobName, namespace)
+}
+
+// pollJob polls the Job until it finished. With a progress consumer it also
+// reports when the profiler pod starts and stops sampling. A profiler pod
+// that never ran is explained from its status and events rather than by a
+// bare timeout.
+func (m *Manager) pollJob(ctx context.Context, opts *api.ProfileOptions, jobName string, namespace string) (*api.JobStatus, error) {
+	var (
+		finalStatus *api.JobStatus
+		running     bool
+		created     bool
+		pending     *corev1.Pod // Last look at the profiler pod while it did not run
+	)
 	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
 		status, err := m.GetJobStatus(ctx, jobName, namespace)
 		if err != nil {
@@ -402,25 +745,71 @@ func (m *Manager) WaitForCompletion(ctx context.Context, jobName string, namespa
 		}
 
 		finalStatus = status
+
+		// A pod refused by admission leaves the Job waiting without pods
+		if !created && status.Phase == api.JobPhaseRunning {
+			if created = m.jobHasPods(ctx, jobName, namespace); !created {
+				if rejection := m.podRejection(ctx, jobName, namespace); rejection != nil {
+					return false, rejection
+				}
+			}
+		}
+
+		// Until the profiler runs, the node may be failing to pull its image
+		// or create its container, which would only end with the startup
+		// timeout
+		if !running {
+			if pods, err := m.jobPods(ctx, jobName, namespace); err == nil {
+				for i := range pods {
+					if failure := m.podStartFailure(ctx, &pods[i]); failure != nil {
+						return false, failure
+					}
+					switch pods[i].Status.Phase {
+					case corev1.PodRunning, corev1.PodSucceeded, corev1.PodFailed:
+						running = true
+					default:
+						pending = &pods[i]
+					}
+				}
+			}
+			if running {
+				opts.Emit(api.ProgressEvent{Type: api.EventPodRunning, JobName: jobName})
+				opts.Emit(api.ProgressEvent{Type: api.EventSamplingStarted, JobName: jobName})
+			}
+		}
 		switch status.Phase {
-		case types.JobPhaseSucceeded, types.JobPhaseFailed:
+		case api.JobPhaseSucceeded, api.JobPhaseFailed:
 			return true, nil
 		default:
 			return false, nil
 		}
 	})
 
+	// The startup timeout or the deadline of the Job ended a pod that never
+	// ran, e.g. one left unschedulable. The wait context is over, the events
+	// are read with a fresh one.
+	timedOut := err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if !running && pending != nil && (timedOut || (err == nil && finalStatus.Phase == api.JobPhaseFailed)) {
+		lookupCtx, cancel := context.WithTimeout(context.Background(), podLookupTimeout)
+		failure := m.pendingFailure(lookupCtx, pending)
+		cancel()
+		if failure != nil {
+			return nil, failure
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingFinished, JobName: jobName})
 	return finalStatus, nil
 }
 
-// WaitForCompletionWithLogs waits for Job completion and prints logs in real time
-func (m *Manager) WaitForCompletionWithLogs(ctx context.Context, jobName string, namespace string, timeout time.Duration) (*types.JobStatus, error) {
+// WaitForCompletionWithLogs waits for Job completion and logs the pod output in real time
+func (m *Manager) WaitForCompletionWithLogs(ctx context.Context, opts *api.ProfileOptions, jobName string, namespace string, timeout time.Duration) (*api.JobStatus, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+	log := opts.Log()
 
 	// Wait for Pod to start
 	var podName string
@@ -436,41 +825,29 @@ func (m *Manager) WaitForCompletionWithLogs(ctx context.Context, jobName string,
 	}
 
 	if podName == "" {
+		if rejection := m.podRejection(ctx, jobName, namespace); rejection != nil {
+			return nil, rejection
+		}
 		return nil, fmt.Errorf("failed to find pod for job %s", jobName)
 	}
 
-	fmt.Printf("📋 Streaming logs from pod %s...\n", podName)
+	log.Info("Streaming logs from pod", "pod", podName)
 
 	// Start log streaming
-	go m.streamPodLogs(ctx, podName, namespace)
+	go m.streamPodLogs(ctx, log, podName, namespace)
 
 	// Wait for Job completion
-	var finalStatus *types.JobStatus
-	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
-		status, err := m.GetJobStatus(ctx, jobName, namespace)
-		if err != nil {
-			return false, err
-		}
-
-		finalStatus = status
-		switch status.Phase {
-		case types.JobPhaseSucceeded, types.JobPhaseFailed:
-			return true, nil
-		default:
-			return false, nil
-		}
-	})
-
+	finalStatus, err := m.pollJob(ctx, opts, jobName, namespace)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println("📋 Log streaming completed.")
+	log.Info("Log streaming completed")
 	return finalStatus, nil
 }
 
 // streamPodLogs streams Pod logs
-func (m *Manager) streamPodLogs(ctx context.Context, podName, namespace string) {
+func (m *Manager) streamPodLogs(ctx context.Context, log *slog.Logger, podName, namespace string) {
 	// Wait for Pod to enter Running state
 	for i := 0; i < 60; i++ {
 		pod, err := m.k8sConfig.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
@@ -484,15 +861,18 @@ func (m *Manager) streamPodLogs(ctx context.Context, podName, namespace string)
 		}
 	}
 
+	m.streamContainerLogs(ctx, log, podName, namespace, "profiler")
+}
+
+// streamContainerLogs follows the logs of a container, one message per line
+func (m *Manager) streamContainerLogs(ctx context.Context, log *slog.Logger, podName, namespace, container string) {
 	// Get log stream
-	req := m.k8sConfig.Clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
-		Container: "profiler",
+	logs, err := m.streamLogs(ctx, namespace, podName, &corev1.PodLogOptions{
+		Container: container,
 		Follow:    true,
 	})
-
-	logs, err := req.Stream(ctx)
 	if err != nil {
-		fmt.Printf("Warning: failed to stream logs: %v\n", err)
+		log.Warn("Failed to stream logs", "error", err)
 		return
 	}
 	defer logs.Close()
@@ -504,32 +884,41 @@ func (m *Manager) streamPodLogs(ctx context.Context, podName, namespace string)
 		case <-ctx.Done():
 			return
 		default:
-			fmt.Println(scanner.Text())
+			if status, ok := launcher.ParseStatus(scanner.Text()); ok {
+				logLauncherStatus(log, status)
+				continue
+			}
+			log.Info(scanner.Text())
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		fmt.Printf("Warning: error reading logs: %v\n", err)
+		log.Warn("Error reading logs", "error", err)
 	}
 }
 
 // GetJobStatus gets Job status
-func (m *Manager) GetJobStatus(ctx context.Context, jobName string, namespace string) (*types.JobStatus, error) {
+func (m *Manager) GetJobStatus(ctx context.Context, jobName string, namespace string) (*api.JobStatus, error) {
+	if session, ok := m.ephemeral.get(jobName); ok {
+		status, _, err := m.ephemeralStatus(ctx, jobName, session.namespace, session.pod)
+		return status, err
+	}
+
 	job, err := m.k8sConfig.Clientset.BatchV1().Jobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get job: %w", err)
+		return nil, apiError(err, "failed to get job %s/%s", namespace, jobName)
 	}
 
-	status := &types.JobStatus{
+	status := &api.JobStatus{
 		JobName:   job.Name,
 		Namespace: job.Namespace,
-		Phase:     types.JobPhaseRunning,
+		Phase:     api.JobPhaseRunning,
 	}
 
 	if job.Status.Succeeded > 0 {
-		status.Phase = types.JobPhaseSucceeded
+		status.Phase = api.JobPhaseSucceeded
 	} else if job.Status.Failed > 0 {
-		status.Phase = types.JobPhaseFailed
+		status.Phase = api.JobPhaseFailed
 	}
 
 	return status, nil
@@ -537,6 +926,11 @@ func (m *Manager) GetJobStatus(ctx context.Context, jobName string, namespace st
 
 // DeleteJob deletes Job
 func (m *Manager) DeleteJob(ctx context.Context, jobName string, namespace string) error {
+	// Ephemeral containers cannot be removed from a pod, the exited profiler stays in its status
+	if m.ephemeral.remove(jobName) {
+		return nil
+	}
+
 	propagationPolicy := metav1.DeletePropagationForeground
 	return m.k8sConfig.Clientset.BatchV1().Jobs(namespace).Delete(ctx, jobName, metav1.DeleteOptions{
 		PropagationPolicy: &propagationPolicy,
@@ -548,15 +942,43 @@ func (m *Manager) ExtractFlameGraphFromLogs(ctx context.Context, jobName, namesp
 	return m.extractFlameGraphFromLogs(ctx, jobName, namespace)
 }
 
+// ExtractFoldedFromLogs public method for extracting folded stacks from logs
+func (m *Manager) ExtractFoldedFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return m.extractArtifactFromLogs(ctx, jobName, namespace, foldedArtifact)
+}
+
+// ExtractTimelineFromLogs public method for extracting time-ordered stacks from logs
+func (m *Manager) ExtractTimelineFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return m.extractArtifactFromLogs(ctx, jobName, namespace, timelineArtifact)
+}
+
+// ExtractNetFromLogs public method for extracting the per endpoint network statistics from logs
+func (m *Manager) ExtractNetFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return m.extractArtifactFromLogs(ctx, jobName, namespace, netArtifact)
+}
+
+// ExtractSchedLatencyFromLogs public method for extracting the scheduling latency histogram from logs
+func (m *Manager) ExtractSchedLatencyFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return m.extractArtifactFromLogs(ctx, jobName, namespace, schedLatArtifact)
+}
+
 // Test methods retained for compatibility
-func (m *Manager) BuildProfilingArgsForTest(cfg *types.ProfileConfig, opts *types.ProfileOptions, target *types.TargetInfo) []string {
+func (m *Manager) BuildProfilingArgsForTest(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) []string {
 	return m.buildProfilingArgs(cfg, opts, target)
 }
 
-func (m *Manager) BuildProfilingScriptForTest(target *types.TargetInfo, cfg *types.ProfileConfig) string {
-	return m.buildAdvancedProfilingScript(target, cfg)
+func (m *Manager) BuildProfilingScriptForTest(target *api.TargetInfo, cfg *api.ProfileConfig) (string, error) {
+	return m.buildAdvancedProfilingScript(target, cfg, m.backendForTest(cfg, target))
 }
 
-func (m *Manager) BuildJobSpecForTest(jobName string, cfg *types.ProfileConfig, opts *types.ProfileOptions, target *types.TargetInfo) *batchv1.Job {
-	return m.buildJobSpec(jobName, cfg, opts, target)
+func (m *Manager) BuildJobSpecForTest(jobName string, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*batchv1.Job, error) {
+	return m.buildJobSpec(jobName, cfg, opts, target, m.backendForTest(cfg, target))
+}
+
+// backendForTest falls back to golang-profiling for languages without a Backend
+func (m *Manager) backendForTest(cfg *api.ProfileConfig, target *api.TargetInfo) api.Backend {
+	if backend, _, err := m.backend(cfg, nil, target); err == nil {
+		return backend
+	}
+	return golangBackend{}
 }
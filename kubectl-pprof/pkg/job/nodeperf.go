@@ -0,0 +1,42 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// perfMapMarker prefixes the line the job prints when the Node.js target
+// writes no perf map, leaving its JavaScript frames unnamed
+const perfMapMarker = "PERF_MAP_MISSING:"
+
+// nodePerfBackend samples Node.js processes the inspector cannot reach with
+// perf. V8 names the code it compiles in /tmp/perf-<pid>.map when node runs
+// with --perf-basic-prof, which perf script reads through the target's root.
+// JIT code keeps frame pointers, so stacks are unwound with them.
+type nodePerfBackend struct {
+	perfBackend
+}
+
+func (b nodePerfBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	return fmt.Sprintf(`
+		if ! ls "${PROC_ROOT:-/proc}/$%[1]s/root/tmp/"perf-*.map >/dev/null 2>&1; then
+			echo "Warning: no /tmp/perf-<pid>.map in the target, start node with --perf-basic-prof to name JavaScript frames"
+			echo "%[2]s$%[1]s"
+		fi
+	`, pidVar, perfMapMarker) + b.perfBackend.BuildScript(cfg, pidVar)
+}
+
+// ParseOutput explains unnamed JavaScript frames when the target wrote no perf map
+func (b nodePerfBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	output, err := b.perfBackend.ParseOutput(logs)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(logs, perfMapMarker) {
+		output.Methodology = "node was started without --perf-basic-prof, so JavaScript frames show as [unknown] or bare addresses; " +
+			"enable the inspector with --inspect for a V8 CPU profile, or add --perf-basic-prof to NODE_OPTIONS"
+	}
+	return output, nil
+}
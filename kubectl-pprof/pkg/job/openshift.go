@@ -0,0 +1,55 @@
+package job
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// CRI sockets crictl talks to on the node
+const (
+	containerdSocket = "/run/containerd/containerd.sock"
+	crioSocket       = "/var/run/crio/crio.sock"
+)
+
+// openShiftSCCAnnotation makes OpenShift admit the pod under the named
+// SecurityContextConstraints rather than the first one that happens to fit
+const openShiftSCCAnnotation = "openshift.io/required-scc"
+
+// spcSELinuxType is the SELinux type of super privileged containers, allowed
+// to read the host's /proc, /sys and runtime socket without relabeling them
+const spcSELinuxType = "spc_t"
+
+// RuntimeSocket returns the CRI socket of the target node: CRI-O on OpenShift
+// and for CRI-O containers, containerd otherwise
+func RuntimeSocket(cfg *api.ProfileConfig, target *api.TargetInfo) string {
+	if cfg.IsOpenShift() {
+		return crioSocket
+	}
+	if target.RuntimeInfo != nil && target.RuntimeInfo.Runtime == api.RuntimeCRIO {
+		return crioSocket
+	}
+	return containerdSocket
+}
+
+// applyOpenShift prepares the Job for the SCC admission of OpenShift: the pod
+// requests the configured SCC explicitly and the profiler runs as spc_t so
+// that SELinux lets it use the hostPath mounts
+func applyOpenShift(job *batchv1.Job, cfg *api.ProfileConfig) {
+	if !cfg.IsOpenShift() {
+		return
+	}
+	template := &job.Spec.Template
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[openShiftSCCAnnotation] = cfg.GetSCC()
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		if container.SecurityContext == nil {
+			container.SecurityContext = &corev1.SecurityContext{}
+		}
+		container.SecurityContext.SELinuxOptions = &corev1.SELinuxOptions{Type: spcSELinuxType}
+	}
+}
@@ -0,0 +1,96 @@
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// profilerCPUMarker prefixes the CPU ticks consumed by golang-profiling in the job logs
+const profilerCPUMarker = "PROFILER_CPU:"
+
+// Markers of the profiler pod's own cgroup counters in the job logs
+const (
+	profilerCPUStatMarker = "PROFILER_CPU_STAT:"
+	profilerMemoryMarker  = "PROFILER_MEMORY:"
+)
+
+// Shell snippets that measure the user+system CPU time of golang-profiling.
+// The profiler is a child of the job shell, so its usage shows up in the
+// cutime/cstime fields (16 and 17) of /proc/$$/stat once it has exited.
+// eBPF programs run in the context of the sampled task, so their cost is
+// charged to the target and is only covered by the estimate.
+const (
+	cpuTicksBeforeScript = `CPU_TICKS_BEFORE=$(awk '{print $16+$17}' /proc/$$/stat 2>/dev/null || echo 0)`
+	cpuTicksReportScript = `echo "` + profilerCPUMarker + `$(( $(awk '{print $16+$17}' /proc/$$/stat 2>/dev/null || echo 0) - CPU_TICKS_BEFORE )) $(getconf CLK_TCK 2>/dev/null || echo 100)"`
+)
+
+// profilerCPUStatScript prints the CPU bandwidth counters of the profiler's
+// own cgroup, mounted at /sys/fs/cgroup of its container, tagged with when
+func profilerCPUStatScript(when string) string {
+	return fmt.Sprintf(`PROFILER_CPU_STAT_%[1]s=$(cgroup_cpu_stat /sys/fs/cgroup) && echo "%[2]s%[1]s $PROFILER_CPU_STAT_%[1]s"`, when, profilerCPUStatMarker)
+}
+
+// profilerMemoryScript prints the peak memory usage of the profiler's own
+// cgroup: memory.peak on cgroup v2 (Linux 5.19+), max_usage_in_bytes on v1
+const profilerMemoryScript = `for MEMORY_PEAK_FILE in /sys/fs/cgroup/memory.peak /sys/fs/cgroup/memory/memory.max_usage_in_bytes; do
+			if [ -r "$MEMORY_PEAK_FILE" ]; then echo "` + profilerMemoryMarker + `$(cat "$MEMORY_PEAK_FILE")"; break; fi
+		done`
+
+// parseOverhead collects what the profiler pod measured of its own usage:
+// the CPU time of golang-profiling, the peak memory and the throttling of its
+// cgroup. It returns nil when the logs hold none of them.
+func parseOverhead(logs string) *api.OverheadReport {
+	report := &api.OverheadReport{}
+	cpu, measured := parseProfilerCPU(logs)
+	report.ProfilerCPU = cpu
+	if memory, ok := parseProfilerMemory(logs); ok {
+		report.ProfilerMemory = memory
+		measured = true
+	}
+	if throttling, ok := parseCPUStat(logs, profilerCPUStatMarker); ok {
+		report.ProfilerThrottling = throttling
+		measured = true
+	}
+	if !measured {
+		return nil
+	}
+	return report
+}
+
+// parseProfilerMemory finds the peak memory of the profiler pod in the job logs
+func parseProfilerMemory(logs string) (uint64, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, profilerMemoryMarker) {
+			continue
+		}
+		memory, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, profilerMemoryMarker)), 10, 64)
+		return memory, err == nil
+	}
+	return 0, false
+}
+
+// parseProfilerCPU finds the measured profiler CPU time in the job logs
+func parseProfilerCPU(logs string) (time.Duration, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, profilerCPUMarker) {
+			continue
+		}
+		var ticks, clockTicks int64
+		if _, err := fmt.Sscanf(strings.TrimPrefix(line, profilerCPUMarker), "%d %d", &ticks, &clockTicks); err != nil || ticks < 0 || clockTicks <= 0 {
+			return 0, false
+		}
+		return time.Duration(ticks) * time.Second / time.Duration(clockTicks), true
+	}
+	return 0, false
+}
@@ -0,0 +1,94 @@
+package job
+
+import (
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// perfDataPath is where perf record leaves its samples in the profiler container
+const perfDataPath = "/tmp/perf.data"
+
+// perfBackend samples native processes, such as Rust ones, with perf and
+// folds the stacks perf script prints for flamegraph.pl
+type perfBackend struct {
+	// Unwinding method unless the session picks one, frame pointers where
+	// perf stands in for the eBPF sampler, which walks them too
+	unwind string
+}
+
+func (perfBackend) Name() string {
+	return "perf"
+}
+
+// BuildArgs leaves out --include-children, perf inherits to children by default
+func (b perfBackend) BuildArgs(cfg *api.ProfileConfig) []string {
+	unwind := b.unwind
+	if cfg.NativeOptions != nil && cfg.NativeOptions.Unwind != "" {
+		unwind = cfg.NativeOptions.Unwind
+	}
+	if unwind == "" {
+		unwind = api.UnwindFP
+	}
+	args := []string{"-F", fmt.Sprintf("%d", backendFrequency(cfg)), "--call-graph", unwind}
+	if cfg.PerCPU {
+		args = append(args, "--sample-cpu")
+	}
+	return args
+}
+
+// BuildScript records the target and its extra PIDs, which perf takes as one
+// comma-separated list, then folds each sample into one line of frames from
+// the root to the leaf. Kernel frames are dropped unless the session includes
+// them, suffixed _[k] like golang-profiling does. The root is the name of the
+// sampled thread, a thread:name frame with --annotate thread, below the cpuN
+// frame of the [NNN] column perf script prints in per-CPU sessions.
+func (b perfBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	debuginfod := ""
+	if cfg.NativeOptions != nil && cfg.NativeOptions.DebuginfodURL != "" {
+		// perf script fetches the debug info of stripped binaries by build ID
+		debuginfod = `
+		export DEBUGINFOD_URLS=` + shellQuote(cfg.NativeOptions.DebuginfodURL)
+	}
+	kernel, perCPU, thread := 0, 0, 0
+	if cfg.IncludeKernelStacks {
+		kernel = 1
+	}
+	if cfg.PerCPU {
+		perCPU = 1
+	}
+	if cfg.GetAnnotate() == api.AnnotateThread {
+		thread = 1
+	}
+	return debuginfod + fmt.Sprintf(`
+		echo "Starting perf record with arguments: -p $%[1]s${EXTRA_PIDS:+,$EXTRA_PIDS} %[3]s -- sleep %[2]d"
+		perf record -o %[4]s -p "$%[1]s${EXTRA_PIDS:+,$EXTRA_PIDS}" %[3]s -- sleep %[2]d
+		PROFILE_EXIT_CODE=$?
+		if [ $PROFILE_EXIT_CODE -eq 0 ]; then
+			perf script -i %[4]s 2>/dev/null | awk -v kernel=%[6]d -v percpu=%[7]d -v thread=%[8]d '
+				/^[^ \t]/ { if (n) emit(); comm = thread ? "thread:" $1 : $1; if (percpu && match($0, /\[[0-9]+\]/)) comm = "cpu" (substr($0, RSTART + 1, RLENGTH - 2) + 0) ";" comm; n = 0; next }
+				/^[ \t]*$/ { if (n) emit(); n = 0; next }
+				/\(\[kernel\.kallsyms\]\)$/ && !kernel { next }
+				{ name = $0; sub(/^[ \t]*[0-9a-f]+ /, "", name); k = sub(/ \(\[kernel\.kallsyms\]\)$/, "", name); sub(/ \([^()]*\)$/, "", name); sub(/\+0x[0-9a-f]+$/, "", name); frames[++n] = k ? name "_[k]" : name }
+				function emit(  i, s) { s = comm; for (i = n; i > 0; i--) s = s ";" frames[i]; count[s]++ }
+				END { if (n) emit(); for (s in count) print s, count[s] }
+			' > %[5]s
+			PROFILE_EXIT_CODE=$?
+		fi
+	`, pidVar, int(cfg.Duration.Seconds()), shellJoin(b.BuildArgs(cfg)), perfDataPath, api.BackendFoldedPath, kernel, perCPU, thread) + renderFoldedScript(cfg)
+}
+
+func (perfBackend) RequiredMounts() []api.HostMount {
+	return nil
+}
+
+// RequiredCapabilities also lists SYS_ADMIN, which perf_event_open needs on
+// kernels older than 5.8 that lack PERFMON
+func (perfBackend) RequiredCapabilities() []string {
+	return []string{"SYS_ADMIN", "SYS_PTRACE", "PERFMON"}
+}
+
+// ParseOutput reads the sample summary the job script computes from the folded stacks
+func (perfBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	return parseSampleOutput(logs)
+}
@@ -0,0 +1,68 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Oldest kernel that attaches BPF programs to perf events, as the preflight checks
+const (
+	minEBPFKernelMajor = 4
+	minEBPFKernelMinor = 9
+)
+
+// usesEBPF reports whether the backend loads eBPF programs, which only
+// backends asking for the BPF capability do
+func usesEBPF(backend api.Backend) bool {
+	for _, capability := range backend.RequiredCapabilities() {
+		if capability == "BPF" {
+			return true
+		}
+	}
+	return false
+}
+
+// kernelOlderThan reports whether a kernel release such as 4.4.0-1128-aws
+// predates major.minor. Releases that do not parse count as recent.
+func kernelOlderThan(release string, major, minor int) bool {
+	fields := strings.SplitN(release, ".", 3)
+	if len(fields) < 2 {
+		return false
+	}
+	gotMajor, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return false
+	}
+	gotMinor, err := strconv.Atoi(strings.TrimRightFunc(fields[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return false
+	}
+	return gotMajor < major || (gotMajor == major && gotMinor < minor)
+}
+
+// perfFallback swaps an eBPF backend for perf on nodes whose kernel, as the
+// node reports it, cannot run the eBPF sampler, and explains how the graph
+// differs. Only plain CPU profiles fall back: off-CPU, scheduling latency and
+// network profiles have no perf equivalent and fail the preflight instead.
+func perfFallback(cfg *api.ProfileConfig, target *api.TargetInfo, backend api.Backend) (api.Backend, string) {
+	if !usesEBPF(backend) || target.NodeInfo == nil {
+		return backend, ""
+	}
+	if cfg.ProfileType != "" && cfg.ProfileType != api.ProfileTypeCPU {
+		return backend, ""
+	}
+	if cfg.GoOptions != nil && cfg.GoOptions.OffCPU {
+		return backend, ""
+	}
+	kernel := target.NodeInfo.KernelVersion
+	if !kernelOlderThan(kernel, minEBPFKernelMajor, minEBPFKernelMinor) {
+		return backend, ""
+	}
+	return perfBackend{unwind: api.UnwindFP}, fmt.Sprintf("sampled with perf instead of %s: kernel %s predates eBPF perf-event programs (Linux %d.%d). "+
+		"perf walks stacks by frame pointers and copies every sample to user space, so expect a higher overhead, "+
+		"inlined functions folded into their callers and threads in place of goroutines",
+		backend.Name(), kernel, minEBPFKernelMajor, minEBPFKernelMinor)
+}
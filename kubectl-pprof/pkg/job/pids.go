@@ -0,0 +1,79 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// buildContainerPIDsScript translates the PIDs given with --pid, as seen in
+// the target container, to host PIDs once CONTAINER_PID was found: the first
+// replaces CONTAINER_PID as the target, the others are listed in EXTRA_PIDS.
+// A process is matched by the last field of its NSpid, in the PID namespace
+// of CONTAINER_PID.
+func buildContainerPIDsScript(pids []int) string {
+	if len(pids) == 0 {
+		return ""
+	}
+	return `
+		PID_NS=$(readlink /host/proc/$CONTAINER_PID/ns/pid)
+		host_pid() {
+			for dir in $(ls /host/proc | grep -E '^[0-9]+$'); do
+				[ "$(readlink /host/proc/$dir/ns/pid 2>/dev/null)" = "$PID_NS" ] || continue
+				if [ "$(awk '/^NSpid:/ {print $NF}' /host/proc/$dir/status 2>/dev/null)" = "$1" ]; then
+					echo "$dir"
+					return 0
+				fi
+			done
+			return 1
+		}
+		REQUESTED_PIDS=""
+		for CONTAINER_NS_PID in ` + joinPIDs(pids, " ") + `; do
+			HOST_PID=$(host_pid $CONTAINER_NS_PID)
+			if [ -z "$HOST_PID" ]; then
+				echo "Error: Process $CONTAINER_NS_PID not found in the target container's PID namespace"
+				exit 1
+			fi
+			echo "Found process $CONTAINER_NS_PID of the target container: host PID $HOST_PID"
+			REQUESTED_PIDS="$REQUESTED_PIDS $HOST_PID"
+		done
+		set -- $REQUESTED_PIDS
+		CONTAINER_PID=$1
+		shift
+		EXTRA_PIDS=$(echo "$@" | tr ' ' ',')
+	`
+}
+
+// buildEphemeralPIDsScript checks the PIDs given with --pid in the target
+// container's PID namespace, which the ephemeral container shares: the first
+// becomes TARGET_PID, the others are listed in EXTRA_PIDS. Without PIDs the
+// container entrypoint, PID 1, is the target.
+func buildEphemeralPIDsScript(pids []int) string {
+	if len(pids) == 0 {
+		pids = []int{1}
+	}
+	extra := joinPIDs(pids[1:], ",")
+	return fmt.Sprintf(`
+		TARGET_PID=%d
+		EXTRA_PIDS=%s
+		for PID in $TARGET_PID $(echo "$EXTRA_PIDS" | tr ',' ' '); do
+			if [ ! -d "/proc/$PID" ]; then
+				echo "Error: Process $PID not found in the target container's PID namespace"
+				echo "Available processes:"
+				ls /proc/ | grep '^[0-9]*$' | head -10
+				exit 1
+			fi
+		done
+
+		echo "Found target process: $TARGET_PID ($(cat /proc/$TARGET_PID/comm 2>/dev/null))"
+	`, pids[0], shellQuote(extra))
+}
+
+// joinPIDs joins process IDs with a separator
+func joinPIDs(pids []int, sep string) string {
+	fields := make([]string, len(pids))
+	for i, pid := range pids {
+		fields[i] = strconv.Itoa(pid)
+	}
+	return strings.Join(fields, sep)
+}
@@ -0,0 +1,248 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+)
+
+// podLookupTimeout bounds reading why a pod never ran, after waiting for it
+// gave up
+const podLookupTimeout = 10 * time.Second
+
+// imagePullFailures are the waiting reasons of a container whose image cannot be pulled
+var imagePullFailures = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// containerCreateFailures are the waiting reasons of a container the kubelet
+// cannot create, which it retries forever
+var containerCreateFailures = map[string]bool{
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// podWarnings are the reasons of the events explaining why a scheduled pod
+// never started, from the kubelet and the volume controllers
+var podWarnings = []string{"FailedMount", "FailedAttachVolume", "FailedCreatePodSandBox", "FailedCreatePodContainer", "NetworkNotReady"}
+
+// pullRemediation returns actionable hints for an image pull failure, from
+// the reason and the message of the runtime
+func pullRemediation(reason, message string) []string {
+	message = strings.ToLower(message)
+	var hints []string
+	switch {
+	case reason == "InvalidImageName":
+		hints = append(hints, "The image reference is malformed, check --image, --image-digest and the registryMirror of ~/.kubectl-pprof.yaml")
+	case containsAny(message, "unauthorized", "authentication required", "denied", "forbidden", "403"):
+		hints = append(hints, "The registry refused the node, give the profiling pod its credentials with --image-pull-secret")
+	case containsAny(message, "not found", "manifest unknown", "no match for platform"):
+		hints = append(hints, "The image is not in the registry: check the tag, that --image-digest belongs to the image, and with --image-arch-suffix that the tag of the node's architecture is published")
+	case containsAny(message, "timeout", "no such host", "connection refused", "dial tcp", "network is unreachable"):
+		hints = append(hints, "The node cannot reach the registry: in an air-gapped cluster copy the image into a reachable registry and set registryMirror in ~/.kubectl-pprof.yaml, or pass --registry-mirror")
+	}
+	return append(hints, "Pull the image onto the nodes ahead of a profile with 'kubectl pprof preheat'")
+}
+
+// createRemediation returns actionable hints for a container the kubelet
+// cannot create
+func createRemediation(message string) []string {
+	message = strings.ToLower(message)
+	switch {
+	case containsAny(message, "secret", "configmap"):
+		return []string{"The pod references a Secret or ConfigMap missing from its namespace, create it or drop it from --job-template"}
+	case containsAny(message, "serviceaccount", "service account"):
+		return []string{"The ServiceAccount of the pod is missing, create it or fix --service-account"}
+	case containsAny(message, "runasnonroot", "non-root"):
+		return []string{"The namespace enforces non-root pods, the profiler needs root: profile from a namespace allowing privileged pods with --job-namespace"}
+	}
+	return []string{"Check the pod spec overlaid by --job-template, and the events of the pod with 'kubectl describe pod'"}
+}
+
+// schedulingRemediation returns actionable hints for a pod the scheduler
+// cannot place, from the message of the scheduler
+func schedulingRemediation(message string) []string {
+	message = strings.ToLower(message)
+	var hints []string
+	if containsAny(message, "insufficient cpu", "insufficient memory", "insufficient ephemeral-storage") {
+		hints = append(hints, "The node lacks the resources the profiler requests, lower --cpu-limit and --memory-limit or free some room on the node")
+	}
+	if containsAny(message, "too many pods") {
+		hints = append(hints, "The node runs as many pods as it allows, free a slot on it")
+	}
+	if containsAny(message, "untolerated taint", "had taint", "unschedulable") {
+		hints = append(hints, "The node has taints the profiler does not tolerate or is cordoned, pass --tolerations for them or --tolerate-all")
+	}
+	if containsAny(message, "affinity", "selector") {
+		hints = append(hints, "The nodeSelector or affinity of the pod excludes the node of the target, check --job-template")
+	}
+	if containsAny(message, "volume", "persistentvolumeclaim") {
+		hints = append(hints, "A volume of the pod cannot be bound on the node, check the volumes added by --job-template")
+	}
+	if len(hints) == 0 {
+		hints = append(hints, "Check why the scheduler refuses the node with 'kubectl describe pod'")
+	}
+	return hints
+}
+
+// containsAny reports whether s contains one of the substrings
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// PodStartError reports a profiler pod that cannot start: it cannot be
+// scheduled, its node cannot pull the image or create the container. The
+// session would otherwise only end with its startup timeout. It unwraps to
+// the kubernetes error carrying the suggestions.
+type PodStartError struct {
+	Pod    string
+	Node   string // Empty while the pod is not scheduled
+	Reason string // Waiting reason of the container or reason of the event, e.g. ImagePullBackOff
+	Detail string // Message of the scheduler, kubelet or runtime
+	err    *errors.ProfileError
+}
+
+func (e *PodStartError) Error() string {
+	return e.err.Error()
+}
+
+func (e *PodStartError) Unwrap() error {
+	return e.err
+}
+
+// newPodStartError describes why a pod cannot start, summary naming what
+// failed, with remediation
+func newPodStartError(pod *corev1.Pod, summary, reason, detail string, retryable bool, suggestions []string) *PodStartError {
+	message := fmt.Sprintf("%s (%s: %s)", summary, reason, detail)
+	return &PodStartError{
+		Pod:    pod.Name,
+		Node:   pod.Spec.NodeName,
+		Reason: reason,
+		Detail: detail,
+		err:    errors.NewKubernetesError(message, nil, retryable, suggestions...),
+	}
+}
+
+// containerStartFailure returns why a container of a pod cannot start, nil
+// while it is starting. The waiting message of a pull back-off only names
+// the image, the error of the runtime is in the pod's Failed events.
+func (m *Manager) containerStartFailure(ctx context.Context, pod *corev1.Pod, status corev1.ContainerStatus) *PodStartError {
+	waiting := status.State.Waiting
+	switch {
+	case waiting == nil:
+		return nil
+	case imagePullFailures[waiting.Reason]:
+		message := waiting.Message
+		if waiting.Reason == "ImagePullBackOff" {
+			if _, failed := m.lastPodEvent(ctx, pod, "Failed"); failed != "" {
+				message = failed
+			}
+		}
+		summary := fmt.Sprintf("the profiling image %s cannot be pulled on node %s", status.Image, pod.Spec.NodeName)
+		return newPodStartError(pod, summary, waiting.Reason, message, false, pullRemediation(waiting.Reason, message))
+	case containerCreateFailures[waiting.Reason]:
+		summary := fmt.Sprintf("container %s of pod %s cannot be created on node %s", status.Name, pod.Name, pod.Spec.NodeName)
+		return newPodStartError(pod, summary, waiting.Reason, waiting.Message, false, createRemediation(waiting.Message))
+	}
+	return nil
+}
+
+// podStartFailure returns why a pod cannot start, nil while it is starting
+func (m *Manager) podStartFailure(ctx context.Context, pod *corev1.Pod) *PodStartError {
+	for _, status := range pod.Status.ContainerStatuses {
+		if failure := m.containerStartFailure(ctx, pod, status); failure != nil {
+			return failure
+		}
+	}
+	return nil
+}
+
+// pendingFailure explains a pod that never ran once waiting for it gave up:
+// the scheduler could not place it, or the kubelet failed to set it up. It
+// returns nil when nothing explains it.
+func (m *Manager) pendingFailure(ctx context.Context, pod *corev1.Pod) *PodStartError {
+	if failure := m.podStartFailure(ctx, pod); failure != nil {
+		return failure
+	}
+	if pod.Spec.NodeName == "" {
+		var message string
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+				message = condition.Message
+			}
+		}
+		// The condition is gone once the pod was deleted, the event stays
+		if _, event := m.lastPodEvent(ctx, pod, "FailedScheduling"); event != "" {
+			message = event
+		}
+		if message == "" {
+			return nil
+		}
+		summary := fmt.Sprintf("the profiler pod %s cannot be scheduled", pod.Name)
+		return newPodStartError(pod, summary, "FailedScheduling", message, true, schedulingRemediation(message))
+	}
+	reason, message := m.lastPodEvent(ctx, pod, podWarnings...)
+	if message == "" {
+		return nil
+	}
+	var suggestions []string
+	if reason == "FailedMount" && strings.Contains(message, ".sock") {
+		suggestions = append(suggestions, "The container runtime socket is not where the profiler looks for it, pass --openshift on CRI-O nodes")
+	}
+	suggestions = append(suggestions, "Check the events of the pod with 'kubectl describe pod'")
+	summary := fmt.Sprintf("the profiler pod %s cannot start on node %s", pod.Name, pod.Spec.NodeName)
+	return newPodStartError(pod, summary, reason, message, false, suggestions)
+}
+
+// jobPods lists the pods of a Job
+func (m *Manager) jobPods(ctx context.Context, jobName, namespace string) ([]corev1.Pod, error) {
+	pods, err := m.k8sConfig.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// lastPodEvent returns the reason and the message of the latest event of a
+// pod with one of the reasons, "" when none
+func (m *Manager) lastPodEvent(ctx context.Context, pod *corev1.Pod, reasons ...string) (string, string) {
+	selector := "involvedObject.kind=Pod,involvedObject.name=" + pod.Name
+	if len(reasons) == 1 {
+		selector += ",reason=" + reasons[0]
+	}
+	events, err := m.k8sConfig.Clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return "", ""
+	}
+	var items []corev1.Event
+	for _, event := range events.Items {
+		for _, reason := range reasons {
+			if event.InvolvedObject.Name == pod.Name && event.Reason == reason {
+				items = append(items, event)
+			}
+		}
+	}
+	if len(items) == 0 {
+		return "", ""
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].LastTimestamp.Before(&items[j].LastTimestamp) })
+	latest := items[len(items)-1]
+	return latest.Reason, latest.Message
+}
@@ -0,0 +1,159 @@
+package job
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// preflightMarker prefixes the single-line JSON preflight report in the job logs
+const preflightMarker = "PREFLIGHT_RESULT:"
+
+// Preflight check identifiers emitted by the job script
+const (
+	checkKernelTooOld      = "kernel-too-old"
+	checkBTFMissing        = "btf-missing"
+	checkPerfEventParanoid = "perf-event-paranoid"
+	checkMemlockLimited    = "memlock-limited"
+)
+
+// buildPreflightScript builds the shell snippet that inspects the node kernel
+// before sampling. It prints a PREFLIGHT_RESULT JSON line and exits with code 3
+// when a blocking check fails, so the profiler never hits a cryptic eBPF load error.
+// hostRoot is where the node's /proc and /sys are mounted, "" for the container's own.
+// The kernel, BTF and memlock checks only apply to backends loading eBPF programs.
+// The node facts of a cached report are used as they are instead of probed.
+func buildPreflightScript(cfg *api.ProfileConfig, backend api.Backend, hostRoot string, cached *api.PreflightReport) string {
+	// BTF is only mandatory for the sched tracepoints used by off-CPU analysis
+	// and scheduling latency
+	btfSeverity := "WARNINGS"
+	if (cfg.GoOptions != nil && cfg.GoOptions.OffCPU) || cfg.ProfileType == api.ProfileTypeSchedLat {
+		btfSeverity = "FAILURES"
+	}
+	ebpfChecks := ""
+	if usesEBPF(backend) {
+		ebpfChecks = `
+		if [ "$KERNEL_MAJOR" -lt 4 ] || { [ "$KERNEL_MAJOR" -eq 4 ] && [ "$KERNEL_MINOR" -lt 9 ]; }; then
+			FAILURES="$FAILURES ` + checkKernelTooOld + `"
+		fi
+		if [ "$BTF" = false ]; then
+			` + btfSeverity + `="$` + btfSeverity + ` ` + checkBTFMissing + `"
+		fi
+		if [ "$MEMLOCK" != "unlimited" ] && { [ "$KERNEL_MAJOR" -lt 5 ] || { [ "$KERNEL_MAJOR" -eq 5 ] && [ "$KERNEL_MINOR" -lt 11 ]; }; }; then
+			WARNINGS="$WARNINGS ` + checkMemlockLimited + `"
+		fi`
+	}
+
+	return `
+		# Kernel feature preflight` + preflightProbeScript(hostRoot, cached) + `
+		KERNEL_MAJOR=$(echo "$KERNEL_VERSION" | cut -d. -f1)
+		KERNEL_MINOR=$(echo "$KERNEL_VERSION" | cut -d. -f2 | tr -cd '0-9')
+		MEMLOCK=$(ulimit -l 2>/dev/null || echo unknown)
+
+		FAILURES=""
+		WARNINGS=""` + ebpfChecks + `
+		if [ "$PERF_PARANOID" -gt 2 ]; then
+			WARNINGS="$WARNINGS ` + checkPerfEventParanoid + `"
+		fi
+
+		json_list() {
+			printf '['
+			SEP=""
+			for ITEM in $1; do
+				printf '%s"%s"' "$SEP" "$ITEM"
+				SEP=","
+			done
+			printf ']'
+		}
+
+		echo "` + preflightMarker + `{\"kernelVersion\":\"$KERNEL_VERSION\",\"btf\":$BTF,\"perfEventParanoid\":$PERF_PARANOID,\"memlockLimit\":\"$MEMLOCK\",\"cgroupVersion\":\"$CGROUP_VERSION\",\"failures\":$(json_list "$FAILURES"),\"warnings\":$(json_list "$WARNINGS")}"
+		if [ -n "$FAILURES" ]; then
+			echo "Preflight failed:$FAILURES"
+			exit 3
+		fi
+	`
+}
+
+// preflightProbeScript sets the node facts the checks look at, probed from
+// hostRoot or taken from a cached report. The memlock limit is the profiler
+// container's own and always read.
+func preflightProbeScript(hostRoot string, cached *api.PreflightReport) string {
+	if cached != nil {
+		return fmt.Sprintf(`
+		echo "Using the kernel capabilities cached for the node"
+		KERNEL_VERSION=%s
+		BTF=%t
+		PERF_PARANOID=%d
+		CGROUP_VERSION=%s`, shellQuote(cached.KernelVersion), cached.BTF, cached.PerfEventParanoid, shellQuote(cached.CgroupVersion))
+	}
+	return `
+		KERNEL_VERSION=$(uname -r)
+		BTF=false
+		if [ -f ` + hostRoot + `/sys/kernel/btf/vmlinux ]; then BTF=true; fi
+		PERF_PARANOID=$(cat ` + hostRoot + `/proc/sys/kernel/perf_event_paranoid 2>/dev/null || echo -1)
+		if [ -f ` + hostRoot + `/sys/fs/cgroup/cgroup.controllers ]; then CGROUP_VERSION=v2; else CGROUP_VERSION=v1; fi`
+}
+
+// parsePreflightReport finds and decodes the preflight report in the job logs
+func parsePreflightReport(logs string) (*api.PreflightReport, error) {
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, preflightMarker) {
+			continue
+		}
+		report := &api.PreflightReport{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, preflightMarker)), report); err != nil {
+			return nil, fmt.Errorf("failed to decode preflight report: %w", err)
+		}
+		return report, nil
+	}
+	return nil, fmt.Errorf("no preflight report found in logs")
+}
+
+// PreflightRemediation returns an actionable hint for a preflight check
+func PreflightRemediation(report *api.PreflightReport, check string) string {
+	switch check {
+	case checkKernelTooOld:
+		return fmt.Sprintf("Kernel %s is too old for eBPF perf-event programs, profile a workload on a node running Linux >= 4.9, or take a plain CPU profile, which falls back to perf on such kernels", report.KernelVersion)
+	case checkBTFMissing:
+		return "BTF type information (/sys/kernel/btf/vmlinux) is missing, use a kernel built with CONFIG_DEBUG_INFO_BTF=y or disable off-CPU analysis"
+	case checkPerfEventParanoid:
+		return fmt.Sprintf("kernel.perf_event_paranoid is %d, if sampling fails run 'sysctl -w kernel.perf_event_paranoid=2' on the node", report.PerfEventParanoid)
+	case checkMemlockLimited:
+		return fmt.Sprintf("Locked memory is limited to %s KiB on a pre-5.11 kernel, keep the SYS_RESOURCE capability or raise the memlock limit of the container runtime", report.MemlockLimit)
+	default:
+		return fmt.Sprintf("Unknown preflight check %q", check)
+	}
+}
+
+// preflightError converts blocking preflight failures into a profiler error with remediation
+func preflightError(report *api.PreflightReport, nodeName string) error {
+	suggestions := make([]string, 0, len(report.Failures))
+	for _, check := range report.Failures {
+		suggestions = append(suggestions, PreflightRemediation(report, check))
+	}
+
+	message := fmt.Sprintf("kernel preflight failed on node %s (kernel %s): %s",
+		nodeName, report.KernelVersion, strings.Join(report.Failures, ", "))
+	return errors.NewProfilerError(message, nil, false, suggestions...)
+}
+
+// checkPreflight reads the preflight report from the logs of a finished job.
+// Missing reports are tolerated so that older profiling images keep working.
+func checkPreflight(logs string, target *api.TargetInfo) (*api.PreflightReport, error) {
+	report, err := parsePreflightReport(logs)
+	if err != nil {
+		return nil, nil
+	}
+
+	if len(report.Failures) > 0 {
+		return report, preflightError(report, target.NodeName)
+	}
+	return report, nil
+}
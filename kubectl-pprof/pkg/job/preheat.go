@@ -0,0 +1,259 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// preheatLabel marks the DaemonSets and pods of preheat runs, apart from the
+// profiling Jobs gc deletes
+const preheatLabel = "kubectl-pprof-preheat"
+
+// preheatPollInterval is the time between two looks at the preheat pods
+const preheatPollInterval = 2 * time.Second
+
+// preheatCleanupTimeout bounds deleting the DaemonSets once the pulls ended
+const preheatCleanupTimeout = 30 * time.Second
+
+// Preheat statuses of a node
+const (
+	PreheatPulled  = "Pulled"
+	PreheatFailed  = "Failed"
+	PreheatSkipped = "Skipped"
+	PreheatPending = "Pending"
+)
+
+// PreheatSpec selects the nodes to pull the profiling image onto
+type PreheatSpec struct {
+	Namespace string
+	// Label selector of the nodes, every node when empty
+	NodeSelector string
+	Image        string
+	// Tag suffix scheme of per-architecture images, see ResolveImage
+	ImageArchSuffix  string
+	ImagePullSecrets []string
+	Timeout          time.Duration
+}
+
+// PreheatNode is the outcome of preheating one node
+type PreheatNode struct {
+	Node   string
+	Image  string
+	Status string
+	Detail string
+}
+
+// Preheater pulls the profiling image onto nodes ahead of a profile with a
+// short-lived DaemonSet per image, so an incident does not wait for the pull
+type Preheater struct {
+	client kubernetes.Interface
+	logger *slog.Logger
+}
+
+// NewPreheater creates a Preheater
+func NewPreheater(client kubernetes.Interface, logger *slog.Logger) *Preheater {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Preheater{client: client, logger: logger}
+}
+
+// Run pulls the image onto the selected nodes and deletes the DaemonSets
+// again, whether the pulls succeeded, failed or timed out. Nodes the image
+// cannot run on are skipped.
+func (p *Preheater) Run(ctx context.Context, spec PreheatSpec) ([]PreheatNode, error) {
+	nodes, err := p.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: spec.NodeSelector})
+	if err != nil {
+		return nil, apiError(err, "failed to list nodes")
+	}
+	if len(nodes.Items) == 0 {
+		return nil, fmt.Errorf("no node matches %q", spec.NodeSelector)
+	}
+
+	// Per-architecture tags need one DaemonSet per image
+	results := make(map[string]*PreheatNode)
+	byImage := make(map[string][]string)
+	for _, node := range nodes.Items {
+		info := &api.NodeInfo{
+			Name:            node.Name,
+			OperatingSystem: node.Status.NodeInfo.OperatingSystem,
+			Architecture:    node.Status.NodeInfo.Architecture,
+		}
+		image := ResolveImage(spec.Image, spec.ImageArchSuffix, info)
+		results[node.Name] = &PreheatNode{Node: node.Name, Image: image, Status: PreheatPending}
+		if err := CheckNodeCompatibility(info); err != nil {
+			results[node.Name].Status, results[node.Name].Detail = PreheatSkipped, err.Error()
+			continue
+		}
+		byImage[image] = append(byImage[image], node.Name)
+	}
+
+	var names []string
+	defer func() {
+		// The DaemonSets go away even when the caller was interrupted
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), preheatCleanupTimeout)
+		defer cancel()
+		for _, name := range names {
+			p.delete(cleanupCtx, name, spec.Namespace)
+		}
+	}()
+	for image, nodeNames := range byImage {
+		ds := buildPreheatDaemonSet(spec, image, nodeNames)
+		if _, err := p.client.AppsV1().DaemonSets(spec.Namespace).Create(ctx, ds, metav1.CreateOptions{}); err != nil {
+			return nil, apiError(err, "failed to create preheat DaemonSet %s/%s", spec.Namespace, ds.Name)
+		}
+		names = append(names, ds.Name)
+		p.logger.Info("Pulling the profiling image", "daemonset", ds.Name, "image", image, "nodes", len(nodeNames))
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, spec.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(preheatPollInterval)
+	defer ticker.Stop()
+wait:
+	for {
+		pending, err := p.observe(waitCtx, names, spec.Namespace, results)
+		if err != nil {
+			p.logger.Warn("Failed to list preheat pods", "error", err)
+		} else if pending == 0 {
+			break
+		}
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	nodeResults := make([]PreheatNode, 0, len(results))
+	for _, result := range results {
+		if result.Status == PreheatPending {
+			result.Detail = fmt.Sprintf("not pulled within %v", spec.Timeout)
+		}
+		nodeResults = append(nodeResults, *result)
+	}
+	sort.Slice(nodeResults, func(i, j int) bool { return nodeResults[i].Node < nodeResults[j].Node })
+	return nodeResults, nil
+}
+
+// observe updates the results from the pods of the DaemonSets and returns
+// how many nodes are still pending
+func (p *Preheater) observe(ctx context.Context, names []string, namespace string, results map[string]*PreheatNode) (int, error) {
+	pods, err := p.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s,preheat in (%s)", preheatLabel, strings.Join(names, ",")),
+	})
+	if err != nil {
+		return len(results), err
+	}
+	for _, pod := range pods.Items {
+		result, ok := results[pod.Spec.NodeName]
+		if !ok || result.Status != PreheatPending {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			switch {
+			case status.State.Running != nil || status.State.Terminated != nil:
+				// The container only starts once its image is on the node
+				result.Status = PreheatPulled
+			case status.State.Waiting != nil && imagePullFailures[status.State.Waiting.Reason]:
+				result.Status = PreheatFailed
+				result.Detail = fmt.Sprintf("%s: %s", status.State.Waiting.Reason, status.State.Waiting.Message)
+			}
+		}
+	}
+
+	pending := 0
+	for _, result := range results {
+		if result.Status == PreheatPending {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// delete removes a preheat DaemonSet and its pods
+func (p *Preheater) delete(ctx context.Context, name, namespace string) {
+	propagation := metav1.DeletePropagationBackground
+	err := p.client.AppsV1().DaemonSets(namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		p.logger.Warn("Failed to delete preheat DaemonSet", "daemonset", name, "error", err)
+	}
+}
+
+// buildPreheatDaemonSet builds a DaemonSet running the image on the named
+// nodes. Its container only sleeps, unprivileged, with the smallest requests;
+// every taint is tolerated since the nodes were picked explicitly.
+func buildPreheatDaemonSet(spec PreheatSpec, image string, nodeNames []string) *appsv1.DaemonSet {
+	name := preheatLabel + "-" + rand.String(jobNameSuffixLen)
+	labels := map[string]string{"app": preheatLabel, "preheat": name}
+	var pullSecrets []corev1.LocalObjectReference
+	for _, secret := range spec.ImagePullSecrets {
+		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: spec.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Affinity: &corev1.Affinity{
+						NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+									MatchExpressions: []corev1.NodeSelectorRequirement{{
+										Key:      "kubernetes.io/hostname",
+										Operator: corev1.NodeSelectorOpIn,
+										Values:   nodeNames,
+									}},
+								}},
+							},
+						},
+					},
+					Tolerations:                   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					TerminationGracePeriodSeconds: &[]int64{0}[0],
+					ImagePullSecrets:              pullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:            "preheat",
+							Image:           image,
+							Command:         []string{"/bin/sh", "-c", "sleep 3600"},
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("1m"),
+									corev1.ResourceMemory: resource.MustParse("8Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("32Mi"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
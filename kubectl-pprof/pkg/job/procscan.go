@@ -0,0 +1,81 @@
+package job
+
+import "strings"
+
+// procScanFunctionScript defines find_proc_pid, which prints the lowest PID
+// in /host/proc whose cgroup path contains $1 and whose command line contains
+// $2, if given. Kernel threads and the pod's pause container are skipped.
+const procScanFunctionScript = `
+		find_proc_pid() {
+			[ -n "$1" ] || return 1
+			for dir in $(ls /host/proc | grep -E '^[0-9]+$' | sort -n); do
+				grep -q -F -e "$1" /host/proc/$dir/cgroup 2>/dev/null || continue
+				CMDLINE=$(tr '\0' ' ' < /host/proc/$dir/cmdline 2>/dev/null)
+				case "$CMDLINE" in
+					""|"/pause "|*"/pause ") continue ;;
+				esac
+				if [ -n "$2" ]; then
+					case "$CMDLINE" in
+						*"$2"*) ;;
+						*) continue ;;
+					esac
+				fi
+				echo "$dir"
+				return 0
+			done
+			return 1
+		}
+`
+
+// buildProcScanScript looks for the target in /host/proc when the container
+// runtime could not resolve CONTAINER_PID, as happens for host PID and static
+// pods. The container ID is matched first, then the pod UID, in both the
+// cgroupfs and the systemd spelling, together with the container command.
+// The values come from the environment, see scriptEnv.
+func buildProcScanScript() string {
+	return procScanFunctionScript + `
+		if [ -z "$CONTAINER_PID" ]; then
+			echo "Container runtime lookup failed, scanning /host/proc"
+			CONTAINER_PID=$(find_proc_pid "$TARGET_CONTAINER_ID" "")
+			[ -n "$CONTAINER_PID" ] || CONTAINER_PID=$(find_proc_pid "$TARGET_POD_UID" "$TARGET_COMMAND")
+			[ -n "$CONTAINER_PID" ] || CONTAINER_PID=$(find_proc_pid "$(echo "$TARGET_POD_UID" | tr - _)" "$TARGET_COMMAND")
+			if [ -z "$CONTAINER_PID" ]; then
+				echo "Error: Container $TARGET_CONTAINER not found by the container runtime nor in /host/proc"
+				exit 1
+			fi
+			echo "Found target container PID in /host/proc: $CONTAINER_PID"
+		fi
+	`
+}
+
+// runtimeContainerID strips the runtime scheme from a container ID of the pod
+// status, leaving the ID found in cgroup paths
+func runtimeContainerID(containerID string) string {
+	if _, id, ok := strings.Cut(containerID, "://"); ok {
+		return id
+	}
+	return containerID
+}
+
+// buildHostProcessScript finds a process of the node by name for a host
+// process target: the oldest one whose comm, truncated by the kernel to 15
+// characters, or whose executable name matches. The names come from the
+// environment, see scriptEnv.
+func buildHostProcessScript() string {
+	return `
+		CONTAINER_PID=""
+		for dir in $(ls /host/proc | grep -E '^[0-9]+$' | sort -n); do
+			COMM=$(cat /host/proc/$dir/comm 2>/dev/null) || continue
+			EXE=$(tr '\0' '\n' < /host/proc/$dir/cmdline 2>/dev/null | head -1)
+			if [ "$COMM" = "$TARGET_COMM" ] || [ "${EXE##*/}" = "$TARGET_PROCESS" ]; then
+				CONTAINER_PID=$dir
+				break
+			fi
+		done
+		if [ -z "$CONTAINER_PID" ]; then
+			echo "Error: Host process $TARGET_PROCESS not found in /host/proc"
+			exit 1
+		fi
+		echo "Found host process $TARGET_PROCESS: $CONTAINER_PID"
+	`
+}
@@ -0,0 +1,49 @@
+package job
+
+import (
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// pySpyBackend samples CPython processes with py-spy, which reads the
+// interpreter stacks from the target's memory without stopping it
+type pySpyBackend struct{}
+
+func (pySpyBackend) Name() string {
+	return "py-spy"
+}
+
+func (pySpyBackend) BuildArgs(cfg *api.ProfileConfig) []string {
+	args := []string{"--rate", fmt.Sprintf("%d", backendFrequency(cfg)), "--nonblocking"}
+	if cfg.GoOptions != nil && cfg.GoOptions.OffCPU {
+		args = append(args, "--idle")
+	}
+	if cfg.IncludeChildren {
+		args = append(args, "--subprocesses")
+	}
+	return args
+}
+
+// BuildScript records raw stacks, which are already folded, and leaves the
+// rendering to flamegraph.pl like the other backends
+func (b pySpyBackend) BuildScript(cfg *api.ProfileConfig, pidVar string) string {
+	return singlePIDWarning("py-spy") + fmt.Sprintf(`
+		echo "Starting py-spy with arguments: record --pid $%[1]s --duration %[2]d %[3]s --format raw"
+		/usr/local/bin/py-spy record --pid $%[1]s --duration %[2]d %[3]s --format raw --output %[4]s
+		PROFILE_EXIT_CODE=$?
+	`, pidVar, int(cfg.Duration.Seconds()), shellJoin(b.BuildArgs(cfg)), api.BackendFoldedPath) + renderFoldedScript(cfg)
+}
+
+func (pySpyBackend) RequiredMounts() []api.HostMount {
+	return nil
+}
+
+func (pySpyBackend) RequiredCapabilities() []string {
+	return []string{"SYS_PTRACE"}
+}
+
+// ParseOutput reads the sample summary the job script computes from the folded stacks
+func (pySpyBackend) ParseOutput(logs string) (*api.BackendOutput, error) {
+	return parseSampleOutput(logs)
+}
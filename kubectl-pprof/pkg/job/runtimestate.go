@@ -0,0 +1,56 @@
+package job
+
+// runtimeStateFunctionScript defines find_state_pid, which prints the init
+// PID the container runtime recorded for container $1 under the node root:
+// containerd keeps one directory per namespace, k8s.io for CRI pods and moby
+// for Docker, as used by minikube; CRI-O writes a pidfile. Failing that, the
+// first process of the container cgroup is taken, whatever the cgroup version
+// and driver, since the ID is part of the cgroup directory name.
+const runtimeStateFunctionScript = `
+		find_state_pid() {
+			[ -n "$1" ] || return 1
+			for file in \
+				/host/proc/1/root/run/containerd/io.containerd.runtime.v2.task/k8s.io/$1/init.pid \
+				/host/proc/1/root/run/containerd/io.containerd.runtime.v2.task/moby/$1/init.pid \
+				/host/proc/1/root/run/containers/storage/overlay-containers/$1/userdata/pidfile; do
+				if [ -s "$file" ]; then
+					echo "Found runtime state $file" >&2
+					tr -d '\n' < "$file"
+					return 0
+				fi
+			done
+			for dir in $(find /host/sys/fs/cgroup -type d -name "*$1*" 2>/dev/null); do
+				PID=$(head -1 "$dir/cgroup.procs" 2>/dev/null)
+				if [ -n "$PID" ]; then
+					echo "Found container cgroup $dir" >&2
+					echo "$PID"
+					return 0
+				fi
+			done
+			return 1
+		}
+`
+
+// buildRuntimeStateScript resolves CONTAINER_PID from the runtime state on
+// the node when crictl could not, as on kind and minikube nodes whose runtime
+// socket or namespace differ from a regular node. It also logs the cgroup
+// version and whether the node itself runs in a container, whose PID
+// namespace the profiler then translates task IDs into.
+func buildRuntimeStateScript() string {
+	return runtimeStateFunctionScript + `
+		if [ -f /host/sys/fs/cgroup/cgroup.controllers ]; then
+			echo "Node uses cgroup v2"
+		else
+			echo "Node uses cgroup v1"
+		fi
+		if [ "$(readlink /host/proc/1/ns/pid)" != "pid:[4026531836]" ]; then
+			echo "Node runs in a container (kind, minikube), PIDs are namespaced"
+		fi
+		if [ -z "$CONTAINER_PID" ]; then
+			CONTAINER_PID=$(find_state_pid "$TARGET_CONTAINER_ID")
+			if [ -n "$CONTAINER_PID" ]; then
+				echo "Found target container PID from the runtime state: $CONTAINER_PID"
+			fi
+		fi
+	`
+}
@@ -0,0 +1,61 @@
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// sampleTargetMarker prefixes the "<min> <captured> <elapsed_ms>" line
+// golang-profiling prints when it ran with --min-samples
+const sampleTargetMarker = "SAMPLE_TARGET:"
+
+// sampleSummaryMarker prefixes the "<samples> <dropped> <lost_stacks>
+// <truncated> <frames> <unsymbolized>" line golang-profiling prints at the end
+const sampleSummaryMarker = "SAMPLE_SUMMARY:"
+
+// parseSampleSummary finds the sample statistics of a run
+func parseSampleSummary(logs string) (*api.SampleStats, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, sampleSummaryMarker) {
+			continue
+		}
+		var stats api.SampleStats
+		if _, err := fmt.Sscanf(strings.TrimPrefix(line, sampleSummaryMarker), "%d %d %d %d %d %d",
+			&stats.Samples, &stats.Dropped, &stats.LostStacks, &stats.Truncated, &stats.Frames, &stats.Unsymbolized); err != nil {
+			return nil, false
+		}
+		return &stats, true
+	}
+	return nil, false
+}
+
+// parseSampleTarget finds how many samples a run with a sample target
+// captured and how long it sampled
+func parseSampleTarget(logs string) (*api.SampleTargetReport, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, sampleTargetMarker) {
+			continue
+		}
+		var minSamples, samples, elapsedMs int64
+		if _, err := fmt.Sscanf(strings.TrimPrefix(line, sampleTargetMarker), "%d %d %d", &minSamples, &samples, &elapsedMs); err != nil {
+			return nil, false
+		}
+		return &api.SampleTargetReport{
+			MinSamples: minSamples,
+			Samples:    samples,
+			Elapsed:    time.Duration(elapsedMs) * time.Millisecond,
+			Reached:    samples >= minSamples,
+		}, true
+	}
+	return nil, false
+}
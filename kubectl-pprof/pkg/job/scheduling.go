@@ -0,0 +1,42 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// terminationGracePeriod gives a stopped profiler time to print the artifacts
+// it collected so far before it is killed
+const terminationGracePeriod = int64(60)
+
+// pressureConditions node conditions under which the kubelet evicts pods
+var pressureConditions = []string{"MemoryPressure", "DiskPressure", "PIDPressure"}
+
+// CheckNodePressure refuses nodes under resource pressure: the kubelet evicts
+// pods there, a profiler without resource requests among the first, and the
+// session would be lost mid-sample
+func CheckNodePressure(node *api.NodeInfo) error {
+	if node == nil {
+		return nil
+	}
+	var pressures []string
+	for _, condition := range node.Conditions {
+		for _, pressure := range pressureConditions {
+			if condition.Type == pressure && condition.Status == "True" {
+				pressures = append(pressures, pressure)
+			}
+		}
+	}
+	if len(pressures) == 0 {
+		return nil
+	}
+	return errors.NewValidationError(
+		fmt.Sprintf("refusing to profile: node %s reports %s, the kubelet is evicting pods from it", node.Name, strings.Join(pressures, ", ")),
+		"Wait until the pressure on the node clears",
+		"Pass --priority-class to rank the profiler above the pods evicted first",
+		"Pass --force to profile anyway",
+	)
+}
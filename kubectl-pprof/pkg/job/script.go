@@ -0,0 +1,123 @@
+package job
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Names of the embedded script templates
+const (
+	jobScriptTemplate       = "job.sh.tmpl"
+	ephemeralScriptTemplate = "ephemeral.sh.tmpl"
+	runScriptTemplate       = "run.sh.tmpl"
+)
+
+//go:embed scripts/*.sh.tmpl
+var scriptFS embed.FS
+
+// scriptFuncs are the functions of the script templates. quote makes a value
+// a single shell word, join a list of them; every value from the cluster or
+// the command line goes through one of them.
+var scriptFuncs = template.FuncMap{
+	"quote": shellQuote,
+	"join":  shellJoin,
+}
+
+// scriptTemplates are the embedded script templates
+var scriptTemplates = template.Must(template.New("scripts").Funcs(scriptFuncs).Option("missingkey=error").ParseFS(scriptFS, "scripts/*.sh.tmpl"))
+
+// jobScriptData is what the Job script template sees
+type jobScriptData struct {
+	Preflight   string // Checks of the node and the profiler
+	HostProcess string // Lookup of the host process, the container is not looked up when set
+	Fallbacks   string // Lookups of CONTAINER_PID when crictl finds nothing, from the runtime state and /proc
+	PIDs        string // Translation of the PIDs of --pid to host PIDs
+	Run         runScriptData
+}
+
+// ephemeralScriptData is what the ephemeral container script template sees
+type ephemeralScriptData struct {
+	Preflight string
+	PIDs      string // Checks of the PIDs of --pid, setting TARGET_PID
+	Run       runScriptData
+}
+
+// runScriptData is what the profiler run template sees
+type runScriptData struct {
+	Backend           string
+	CPUStatFunctions  string
+	TargetCPUBefore   string
+	ProfilerCPUBefore string
+	SnapshotStart     string // Empty without --live
+	Profile           string // Script of the backend, leaving PROFILE_EXIT_CODE
+	SnapshotStop      string
+	ProfilerCPUAfter  string
+	TargetCPUAfter    string
+	FlameGraphPath    string
+	Artifacts         string // Printing of the artifacts to the logs
+}
+
+// LoadScriptTemplate parses a Job script template replacing the embedded
+// job.sh.tmpl. It sees the fields of jobScriptData, the quote and join
+// functions, and can run the profiler with {{template "run.sh.tmpl" .Run}}.
+// An empty path returns the embedded templates.
+func LoadScriptTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return scriptTemplates, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script template %s: %w", path, err)
+	}
+	templates, err := scriptTemplates.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone script templates: %w", err)
+	}
+	if _, err := templates.New(jobScriptTemplate).Parse(string(content)); err != nil {
+		return nil, fmt.Errorf("failed to parse script template %s: %w", path, err)
+	}
+	// Execute once so unknown fields fail now rather than in the job
+	if err := templates.ExecuteTemplate(&bytes.Buffer{}, jobScriptTemplate, jobScriptData{}); err != nil {
+		return nil, fmt.Errorf("invalid script template %s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// renderScript executes the named script template
+func renderScript(templates *template.Template, name string, data any) (string, error) {
+	var script bytes.Buffer
+	if err := templates.ExecuteTemplate(&script, name, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return script.String(), nil
+}
+
+// profilerRunData fills the profiler run template for the backend, run
+// against the PID held in pidVar
+func profilerRunData(backend api.Backend, cfg *api.ProfileConfig, pidVar string) runScriptData {
+	compression := cfg.GetCompression()
+	artifacts := buildArtifactScript(flameGraphArtifact, api.BackendFlameGraphPath, compression)
+	for _, artifact := range optionalArtifacts(cfg) {
+		artifacts += buildOptionalArtifactScript(artifact.name, artifact.path, compression)
+	}
+	data := runScriptData{
+		Backend:           backend.Name(),
+		CPUStatFunctions:  cpuStatFunctionScript,
+		TargetCPUBefore:   cpuStatScript(pidVar, "before"),
+		ProfilerCPUBefore: cpuTicksBeforeScript + "\n" + profilerCPUStatScript("before"),
+		Profile:           backend.BuildScript(cfg, pidVar),
+		ProfilerCPUAfter:  cpuTicksReportScript + "\n" + profilerCPUStatScript("after") + "\n" + profilerMemoryScript,
+		TargetCPUAfter:    cpuStatScript(pidVar, "after"),
+		FlameGraphPath:    api.BackendFlameGraphPath,
+		Artifacts:         artifacts,
+	}
+	if cfg.Live {
+		data.SnapshotStart, data.SnapshotStop = buildSnapshotScript(), stopSnapshotScript
+	}
+	return data
+}
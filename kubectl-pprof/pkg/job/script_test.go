@@ -0,0 +1,283 @@
+package job
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// hostileValues are shell words a value from the cluster or the command line
+// could carry to break out of its quoting
+var hostileValues = []string{
+	"",
+	"plain",
+	"two words",
+	"it's",
+	`"double"`,
+	"$(touch pwned)",
+	"`touch pwned`",
+	"${HOME}",
+	"a;touch pwned",
+	"a|b&c",
+	"line\nbreak",
+	"tab\there",
+	"trailing\\",
+	"'",
+	"''",
+	"*",
+}
+
+// runShell runs a script with sh in an empty directory and returns its output,
+// failing the test when the script creates a file there
+func runShell(t *testing.T, script string) string {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	dir := t.TempDir()
+	cmd := exec.Command(sh, "-c", script)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("sh -c %q: %v\n%s", script, err, out)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Fatalf("script %q ran a command: created %s", script, entries[0].Name())
+	}
+	return string(out)
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: `''`},
+		{value: "plain", want: `'plain'`},
+		{value: "it's", want: `'it'"'"'s'`},
+		{value: "$(id)", want: `'$(id)'`},
+		{value: "`id`", want: "'`id`'"},
+		{value: "a\nb", want: "'a\nb'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.value); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range hostileValues {
+		t.Run(value, func(t *testing.T) {
+			if got := runShell(t, "printf '%s' "+shellQuote(value)); got != value {
+				t.Errorf("sh read shellQuote(%q) as %q", value, got)
+			}
+		})
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "none", args: nil},
+		{name: "single", args: []string{"--pid"}},
+		{name: "empty words", args: []string{"", "a", ""}},
+		{name: "hostile", args: hostileValues},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The count of arguments, then every argument, each NUL terminated
+			out := runShell(t, "set -- "+shellJoin(tt.args)+`; printf '%s\0' "$#" "$@"`)
+			got := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+			want := append([]string{strconv.Itoa(len(tt.args))}, tt.args...)
+			if strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+				t.Errorf("sh read shellJoin(%q) as %q", tt.args, got[1:])
+			}
+		})
+	}
+}
+
+func TestRunScriptQuoting(t *testing.T) {
+	for _, value := range hostileValues {
+		t.Run(value, func(t *testing.T) {
+			script, err := renderScript(scriptTemplates, runScriptTemplate, runScriptData{
+				Backend:        value,
+				Profile:        "PROFILE_EXIT_CODE=1",
+				FlameGraphPath: value,
+			})
+			if err != nil {
+				t.Fatalf("renderScript: %v", err)
+			}
+			want := value + " exit code: 1\nProfiling failed with exit code: 1\n"
+			if got := runShell(t, script); !strings.HasSuffix(got, want) {
+				t.Errorf("script printed %q, want it to end with %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJobScriptSyntax(t *testing.T) {
+	tests := []struct {
+		name string
+		data jobScriptData
+	}{
+		{name: "container", data: jobScriptData{
+			Fallbacks: buildRuntimeStateScript() + buildProcScanScript(),
+			PIDs:      buildContainerPIDsScript([]int{1, 42}),
+		}},
+		{name: "host process", data: jobScriptData{HostProcess: buildHostProcessScript()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.data.Run = runScriptData{Backend: "golang-profiling", Profile: "PROFILE_EXIT_CODE=0"}
+			script, err := renderScript(scriptTemplates, jobScriptTemplate, tt.data)
+			if err != nil {
+				t.Fatalf("renderScript: %v", err)
+			}
+			runShell(t, "sh -n -c "+shellQuote(script))
+		})
+	}
+}
+
+func TestLoadScriptTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{name: "quoted field", template: `echo {{quote .HostProcess}}`},
+		{name: "run template", template: "{{.Preflight}}\n{{template \"run.sh.tmpl\" .Run}}"},
+		{name: "unknown field", template: `{{.ContainerName}}`, wantErr: "ContainerName"},
+		{name: "syntax error", template: `{{if .HostProcess}}`, wantErr: "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "job.sh.tmpl")
+			if err := os.WriteFile(path, []byte(tt.template), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadScriptTemplate(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadScriptTemplate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadScriptTemplate error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadScriptTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Fatal("LoadScriptTemplate of a missing file succeeded")
+	}
+}
+
+// fakeCrictl is a crictl answering ps with the containers of the files
+// ps-<pod UID> of its directory, ps-all without a pod label, and inspect
+// with PID 4242. It logs its arguments to args.
+const fakeCrictl = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/args"
+while [ $# -gt 0 ]; do
+	case "$1" in
+	inspect) echo '  "pid": 4242,'; exit 0 ;;
+	--label) uid=${2#io.kubernetes.pod.uid=}; shift ;;
+	esac
+	shift
+done
+cat "$(dirname "$0")/ps-${uid:-all}" 2>/dev/null
+`
+
+func TestJobScriptContainerLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		containers  map[string]string // ps output by pod UID, "all" without a pod label
+		want        string
+		wantArgs    string
+		wantFailure string
+	}{
+		{
+			name:       "ID of the pod status",
+			env:        map[string]string{"TARGET_CONTAINER_ID": "abc123", "TARGET_POD_UID": "pod-a"},
+			containers: map[string]string{"pod-a": "other\n"},
+			want:       "Found container ID: abc123",
+		},
+		{
+			name:       "name in the target pod",
+			env:        map[string]string{"TARGET_POD_UID": "pod-a"},
+			containers: map[string]string{"pod-a": "aaa111\n", "pod-b": "bbb222\n", "all": "bbb222\naaa111\n"},
+			want:       "Found container ID: aaa111",
+			wantArgs:   `ps -q --name ^app$ --label io.kubernetes.pod.uid=pod-a`,
+		},
+		{
+			name:        "several matches",
+			env:         map[string]string{"TARGET_POD_UID": "pod-a"},
+			containers:  map[string]string{"pod-a": "aaa111\naaa222\n"},
+			wantFailure: "Error: 2 running containers match app",
+		},
+		{
+			name:        "several matches without a pod UID",
+			containers:  map[string]string{"all": "aaa111\nbbb222\n"},
+			wantFailure: "Error: 2 running containers match app",
+		},
+		{
+			name:       "no match",
+			env:        map[string]string{"TARGET_POD_UID": "pod-a"},
+			containers: map[string]string{"all": "bbb222\n"},
+			want:       "Warning: Container app not found by crictl",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh, err := exec.LookPath("sh")
+			if err != nil {
+				t.Skip("sh not found")
+			}
+			bin := t.TempDir()
+			if err := os.WriteFile(filepath.Join(bin, "crictl"), []byte(fakeCrictl), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for uid, ps := range tt.containers {
+				if err := os.WriteFile(filepath.Join(bin, "ps-"+uid), []byte(ps), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			script, err := renderScript(scriptTemplates, jobScriptTemplate, jobScriptData{
+				Run: runScriptData{Profile: "PROFILE_EXIT_CODE=0"},
+			})
+			if err != nil {
+				t.Fatalf("renderScript: %v", err)
+			}
+
+			cmd := exec.Command(sh, "-c", script)
+			cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"), "CRI_ENDPOINT=unix:///run/fake.sock", "TARGET_CONTAINER=app")
+			for name, value := range tt.env {
+				cmd.Env = append(cmd.Env, name+"="+value)
+			}
+			// The script goes on to look for the PID in /host/proc, which
+			// fails outside a node
+			out, _ := cmd.CombinedOutput()
+			if tt.wantFailure != "" {
+				if !strings.Contains(string(out), tt.wantFailure) || strings.Contains(string(out), "Found container ID") {
+					t.Fatalf("script output %q, want it to fail with %q", out, tt.wantFailure)
+				}
+				return
+			}
+			if !strings.Contains(string(out), tt.want) {
+				t.Errorf("script output %q, want %q", out, tt.want)
+			}
+			if tt.wantArgs != "" {
+				args, _ := os.ReadFile(filepath.Join(bin, "args"))
+				if !strings.Contains(string(args), tt.wantArgs) {
+					t.Errorf("crictl ran with %q, want %q", args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
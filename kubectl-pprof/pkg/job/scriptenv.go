@@ -0,0 +1,101 @@
+package job
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+)
+
+// Environment variables handing the target to the Job script. Names and IDs
+// from the cluster and the command line reach the shell through them, never
+// as part of the script text, where a crafted name could run commands.
+const (
+	envCRIEndpoint   = "CRI_ENDPOINT"
+	envContainerName = "TARGET_CONTAINER"
+	envContainerID   = "TARGET_CONTAINER_ID" // Without the runtime scheme, as in cgroup paths
+	envPodUID        = "TARGET_POD_UID"      // As in the cgroupfs paths, systemd ones spell it with underscores
+	envCommand       = "TARGET_COMMAND"      // Executable name of the container command
+	envHostProcess   = "TARGET_PROCESS"
+	envHostComm      = "TARGET_COMM" // TARGET_PROCESS truncated like the kernel truncates comm
+)
+
+// runtimeIDPattern matches the container IDs and pod UIDs the script looks
+// for in runtime state and cgroup paths, which must not leave them
+var runtimeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateHostProcess checks the name of a node process given with --process
+func ValidateHostProcess(name string) error {
+	if name == "" || len(name) > 255 {
+		return fmt.Errorf("process name must be 1 to 255 characters")
+	}
+	if strings.ContainsRune(name, '/') {
+		return fmt.Errorf("process name %q must be an executable name, not a path", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("process name %q must not contain control characters", name)
+		}
+	}
+	return nil
+}
+
+// validateScriptTarget checks the values of the target the Job script looks
+// up before they are handed to it: the API server already validated them,
+// unless a tampered pod status or the command line says otherwise
+func validateScriptTarget(target *api.TargetInfo) error {
+	if target.HostProcess != "" {
+		return ValidateHostProcess(target.HostProcess)
+	}
+	if errs := validation.IsDNS1123Label(target.ContainerName); len(errs) > 0 {
+		return fmt.Errorf("invalid container name %q: %s", target.ContainerName, strings.Join(errs, ", "))
+	}
+	if id := runtimeContainerID(target.ContainerID); id != "" && !runtimeIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid container ID %q of container %s", target.ContainerID, target.ContainerName)
+	}
+	if uid := cgroupPodUID(target); uid != "" && !runtimeIDPattern.MatchString(uid) {
+		return fmt.Errorf("invalid pod UID %q of pod %s", uid, target.PodName)
+	}
+	return nil
+}
+
+// scriptEnv returns the environment handing the target to the Job script
+func scriptEnv(cfg *api.ProfileConfig, target *api.TargetInfo) []corev1.EnvVar {
+	if target.HostProcess != "" {
+		comm := target.HostProcess
+		if len(comm) > launcher.MaxCommLen {
+			comm = comm[:launcher.MaxCommLen]
+		}
+		return []corev1.EnvVar{
+			{Name: envHostProcess, Value: target.HostProcess},
+			{Name: envHostComm, Value: comm},
+		}
+	}
+	var command string
+	if len(target.Command) > 0 {
+		command = path.Base(target.Command[0])
+	}
+	return []corev1.EnvVar{
+		{Name: envCRIEndpoint, Value: "unix://" + RuntimeSocket(cfg, target)},
+		{Name: envContainerName, Value: target.ContainerName},
+		{Name: envContainerID, Value: runtimeContainerID(target.ContainerID)},
+		{Name: envPodUID, Value: cgroupPodUID(target)},
+		{Name: envCommand, Value: command},
+	}
+}
+
+// cgroupPodUID returns the pod UID as it appears in the cgroup paths of the
+// node, which static pods spell differently from their mirror pod
+func cgroupPodUID(target *api.TargetInfo) string {
+	if target.CgroupUID != "" {
+		return target.CgroupUID
+	}
+	return target.PodUID
+}
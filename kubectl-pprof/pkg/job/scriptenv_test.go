@@ -0,0 +1,111 @@
+package job
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// addTargetSeeds seeds a fuzz test of the target with valid values and ones
+// crafted to break out of a script
+func addTargetSeeds(f *testing.F) {
+	f.Add("app", "containerd://0123abcd", "6b1c1f4e-0d3a-4c1e-9d2a-5f0e8c7b6a59", "", "/usr/bin/server")
+	f.Add("app", "cri-o://abc_def-1", "", "", "")
+	f.Add("", "", "", "kubelet", "")
+	f.Add("", "", "", "a-very-long-process-name", "")
+	f.Add("app;id", "containerd://$(id)", "`id`", "", "/bin/sh -c 'id'")
+	f.Add("app", "containerd://x\ny", "'", "", "\"$HOME\"")
+	f.Add("", "", "", "$(touch pwned)", "")
+	f.Add("", "", "", "proc\nname", "")
+	f.Add("", "", "", "../../bin/sh", "")
+}
+
+// dnsLabel matches the container names the API server accepts
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func FuzzValidateScriptTarget(f *testing.F) {
+	addTargetSeeds(f)
+	f.Fuzz(func(t *testing.T, containerName, containerID, podUID, hostProcess, command string) {
+		target := &api.TargetInfo{
+			ContainerName: containerName,
+			ContainerID:   containerID,
+			PodUID:        podUID,
+			HostProcess:   hostProcess,
+			Command:       []string{command},
+		}
+		if err := validateScriptTarget(target); err != nil {
+			return
+		}
+		if hostProcess != "" {
+			if len(hostProcess) > 255 || strings.ContainsRune(hostProcess, '/') || strings.IndexFunc(hostProcess, unicode.IsControl) >= 0 {
+				t.Fatalf("accepted process name %q", hostProcess)
+			}
+			return
+		}
+		if len(containerName) > 63 || !dnsLabel.MatchString(containerName) {
+			t.Fatalf("accepted container name %q", containerName)
+		}
+		for _, id := range []string{runtimeContainerID(containerID), podUID} {
+			if strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-") != "" {
+				t.Fatalf("accepted runtime ID %q", id)
+			}
+		}
+	})
+}
+
+func FuzzJobScriptTarget(f *testing.F) {
+	addTargetSeeds(f)
+	m := &Manager{}
+	cfg := &api.ProfileConfig{Duration: 30 * time.Second}
+	render := func(t testing.TB, target *api.TargetInfo) string {
+		t.Helper()
+		script, err := m.buildAdvancedProfilingScript(target, cfg, golangBackend{})
+		if err != nil {
+			t.Fatalf("buildAdvancedProfilingScript: %v", err)
+		}
+		return script
+	}
+	containerScript := render(f, &api.TargetInfo{ContainerName: "app"})
+	hostScript := render(f, &api.TargetInfo{HostProcess: "kubelet"})
+
+	f.Fuzz(func(t *testing.T, containerName, containerID, podUID, hostProcess, command string) {
+		target := &api.TargetInfo{
+			ContainerName: containerName,
+			ContainerID:   containerID,
+			PodUID:        podUID,
+			HostProcess:   hostProcess,
+			Command:       []string{command},
+		}
+		// The script is the same whatever the target, which only reaches it
+		// through the environment
+		want := containerScript
+		if hostProcess != "" {
+			want = hostScript
+		}
+		if render(t, target) != want {
+			t.Fatalf("script of target %+v differs from the one of any other target", target)
+		}
+
+		env := make(map[string]string)
+		for _, e := range scriptEnv(cfg, target) {
+			env[e.Name] = e.Value
+		}
+		wantEnv := map[string]string{envHostProcess: hostProcess}
+		if hostProcess == "" {
+			wantEnv = map[string]string{
+				envContainerName: containerName,
+				envContainerID:   runtimeContainerID(containerID),
+				envPodUID:        podUID,
+			}
+		}
+		for name, value := range wantEnv {
+			if env[name] != value {
+				t.Fatalf("%s = %q, want %q", name, env[name], value)
+			}
+		}
+	})
+}
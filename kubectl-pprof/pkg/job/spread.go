@@ -0,0 +1,60 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// jobLabelSelector matches the Jobs and pods of every kubectl-pprof session
+const jobLabelSelector = "app=kubectl-pprof"
+
+// nodeAntiAffinity keeps the profiling pod off nodes where a pod of another
+// kubectl-pprof session runs, in any namespace, so two samplers never share a node
+func nodeAntiAffinity() *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "kubectl-pprof"},
+					},
+					NamespaceSelector: &metav1.LabelSelector{},
+					TopologyKey:       "kubernetes.io/hostname",
+				},
+			},
+		},
+	}
+}
+
+// ActiveJobs counts the unfinished kubectl-pprof Jobs of the whole cluster
+func (m *Manager) ActiveJobs(ctx context.Context) (int, error) {
+	jobs, err := m.k8sConfig.Clientset.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: jobLabelSelector,
+	})
+	if err != nil {
+		return 0, apiError(err, "failed to list profiling jobs")
+	}
+	active := 0
+	for _, job := range jobs.Items {
+		if job.Status.Succeeded == 0 && job.Status.Failed == 0 && job.DeletionTimestamp == nil {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// WaitForJobSlot waits until fewer than limit kubectl-pprof Jobs are active in
+// the cluster. Sessions check independently, so the cap is best effort.
+func (m *Manager) WaitForJobSlot(ctx context.Context, limit int) error {
+	return wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		active, err := m.ActiveJobs(ctx)
+		if err != nil {
+			return false, err
+		}
+		return active < limit, nil
+	})
+}
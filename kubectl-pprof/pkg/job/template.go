@@ -0,0 +1,64 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// applyJobTemplate strategically merges a user-provided Job overlay onto the
+// generated Job. The overlay is a (partial) Job manifest, e.g.
+//
+//	metadata:
+//	  annotations:
+//	    policy.example.com/owner: sre
+//	spec:
+//	  template:
+//	    spec:
+//	      serviceAccountName: profiler
+//	      priorityClassName: system-node-critical
+//	      nodeSelector:
+//	        pool: infra
+//
+// Containers are merged by name, so the profiler container can be patched
+// through an entry named "profiler".
+func applyJobTemplate(job *batchv1.Job, templatePath string) (*batchv1.Job, error) {
+	if templatePath == "" {
+		return job, nil
+	}
+
+	overlay, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job template %s: %w", templatePath, err)
+	}
+
+	patch, err := yaml.YAMLToJSON(overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse job template %s: %w", templatePath, err)
+	}
+
+	original, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, batchv1.Job{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply job template %s: %w", templatePath, err)
+	}
+
+	result := &batchv1.Job{}
+	if err := json.Unmarshal(merged, result); err != nil {
+		return nil, fmt.Errorf("failed to decode merged job: %w", err)
+	}
+
+	// The overlay must not rename or move the Job, the manager tracks it by name
+	result.Name = job.Name
+	result.Namespace = job.Namespace
+
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// cpuStatMarker prefixes the target's cgroup CPU bandwidth counters in the job logs
+const cpuStatMarker = "CPU_STAT:"
+
+// cpuStatFunctionScript defines cgroup_cpu_stat, which prints "<nr_periods>
+// <nr_throttled> <throttled_ns>" for a cgroup mount, and cpu_stat, which does
+// so for a PID. The cgroup of a PID is read through its own mount namespace,
+// where the container runtime mounts its cgroup at /sys/fs/cgroup (cgroup v2)
+// or /sys/fs/cgroup/cpu,cpuacct (v1). cgroup v2 reports throttled_usec, v1
+// throttled_time in nanoseconds; awk formats with %.0f because some awks
+// overflow %d past 2^31.
+const cpuStatFunctionScript = `
+		cgroup_cpu_stat() {
+			for CPU_STAT_FILE in "$1/cpu.stat" "$1/cpu,cpuacct/cpu.stat" "$1/cpu/cpu.stat"; do
+				if [ -r "$CPU_STAT_FILE" ]; then
+					awk '$1 == "nr_periods" { p = $2 } $1 == "nr_throttled" { t = $2 } $1 == "throttled_usec" { ns = $2 * 1000 } $1 == "throttled_time" { ns = $2 } END { printf "%.0f %.0f %.0f", p, t, ns }' "$CPU_STAT_FILE"
+					return 0
+				fi
+			done
+			return 1
+		}
+		cpu_stat() {
+			cgroup_cpu_stat "${PROC_ROOT:-/proc}/$1/root/sys/fs/cgroup"
+		}
+`
+
+// cpuStatScript prints the cgroup CPU bandwidth counters of the PID held in
+// pidVar, tagged with when ("before" or "after")
+func cpuStatScript(pidVar, when string) string {
+	return fmt.Sprintf(`CPU_STAT_%[2]s=$(cpu_stat "$%[1]s") && echo "%[3]s%[2]s $CPU_STAT_%[2]s"`, pidVar, when, cpuStatMarker)
+}
+
+// cpuStat is one reading of cpu.stat
+type cpuStat struct {
+	periods, throttled, throttledNs uint64
+}
+
+// parseThrottling compares the cpu.stat readings of the target taken around
+// the profile. It reports false when either reading is missing, e.g. on a node
+// whose container runtime does not mount the cgroup into the container.
+func parseThrottling(logs string) (*api.ThrottlingReport, bool) {
+	return parseCPUStat(logs, cpuStatMarker)
+}
+
+// parseCPUStat compares the cpu.stat readings tagged with marker
+func parseCPUStat(logs, marker string) (*api.ThrottlingReport, bool) {
+	readings := make(map[string]cpuStat)
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, marker) {
+			continue
+		}
+		var (
+			when string
+			stat cpuStat
+		)
+		if _, err := fmt.Sscanf(strings.TrimPrefix(line, marker), "%s %d %d %d", &when, &stat.periods, &stat.throttled, &stat.throttledNs); err != nil {
+			continue
+		}
+		readings[when] = stat
+	}
+
+	before, ok := readings["before"]
+	if !ok {
+		return nil, false
+	}
+	after, ok := readings["after"]
+	if !ok || after.periods < before.periods || after.throttled < before.throttled || after.throttledNs < before.throttledNs {
+		return nil, false
+	}
+
+	report := &api.ThrottlingReport{
+		Periods:          after.periods - before.periods,
+		ThrottledPeriods: after.throttled - before.throttled,
+		ThrottledTime:    time.Duration(after.throttledNs - before.throttledNs),
+	}
+	report.ThrottledPercent = stacks.Share(int64(report.ThrottledPeriods), int64(report.Periods))
+	return report, true
+}
@@ -0,0 +1,67 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// ParseToleration parses a toleration given as key[=value][:effect]. Without
+// a value any value of the key is tolerated, without an effect every effect.
+func ParseToleration(s string) (corev1.Toleration, error) {
+	spec, effect, hasEffect := strings.Cut(s, ":")
+	key, value, hasValue := strings.Cut(spec, "=")
+	if key == "" {
+		return corev1.Toleration{}, fmt.Errorf("invalid toleration %q, want key[=value][:effect]", s)
+	}
+
+	toleration := corev1.Toleration{Key: key, Operator: corev1.TolerationOpExists}
+	if hasValue {
+		toleration.Operator = corev1.TolerationOpEqual
+		toleration.Value = value
+	}
+	if hasEffect {
+		switch corev1.TaintEffect(effect) {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			toleration.Effect = corev1.TaintEffect(effect)
+		default:
+			return corev1.Toleration{}, fmt.Errorf("invalid toleration %q, effect must be one of: NoSchedule, PreferNoSchedule, NoExecute", s)
+		}
+	}
+	return toleration, nil
+}
+
+// ParseTolerations parses every toleration of cfg.Tolerations
+func ParseTolerations(specs []string) ([]corev1.Toleration, error) {
+	tolerations := make([]corev1.Toleration, 0, len(specs))
+	for _, spec := range specs {
+		toleration, err := ParseToleration(spec)
+		if err != nil {
+			return nil, err
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	return tolerations, nil
+}
+
+// jobTolerations returns the tolerations of the profiling pod: every taint
+// with TolerateAll, else those of the target pod, which already runs on the
+// node, and the extra ones given. Cordoned and dedicated nodes the target
+// does not tolerate stay off limits. A host process has no pod to copy from,
+// the node was picked explicitly so all its taints are tolerated.
+func jobTolerations(cfg *api.ProfileConfig, target *api.TargetInfo) []corev1.Toleration {
+	if cfg.TolerateAll || target.HostProcess != "" {
+		return []corev1.Toleration{{Operator: corev1.TolerationOpExists}}
+	}
+
+	var tolerations []corev1.Toleration
+	if pod, ok := target.Pod.(*corev1.Pod); ok {
+		tolerations = append(tolerations, pod.Spec.Tolerations...)
+	}
+	// Validated before the Job is built
+	extra, _ := ParseTolerations(cfg.Tolerations)
+	return append(tolerations, extra...)
+}
@@ -0,0 +1,301 @@
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+// maxServedRuns bounds the runs whose fetched artifacts are kept
+const maxServedRuns = 16
+
+// maxFetchAttempts bounds the downloads of one served artifact
+const maxFetchAttempts = 5
+
+// servedArtifacts holds the artifacts fetched from the launchers of recent
+// runs, decompressed, by run and artifact name
+type servedArtifacts struct {
+	mu    sync.Mutex
+	runs  map[string]map[string][]byte
+	order []string
+}
+
+func (s *servedArtifacts) add(jobName string, artifacts map[string][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runs == nil {
+		s.runs = make(map[string]map[string][]byte)
+	}
+	if len(s.order) == maxServedRuns {
+		delete(s.runs, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.runs[jobName] = artifacts
+	s.order = append(s.order, jobName)
+}
+
+func (s *servedArtifacts) get(jobName, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.runs[jobName][name]
+	return data, ok
+}
+
+// followServedArtifacts fetches the artifacts the launcher of a port-forward
+// transfer serves as soon as its logs announce them, and tells the launcher
+// so it exits without printing them. When fetching fails the launcher prints
+// them to the logs once its serve timeout passes. The returned function
+// stops following and waits for a fetch in progress.
+func (m *Manager) followServedArtifacts(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName, namespace string) func() {
+	if !cfg.DirectExec || cfg.GetTransfer() != api.TransferPortForward || m.k8sConfig.Config == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// The logs can only be followed once the profiler container started
+		var logs io.ReadCloser
+		err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+			var err error
+			logs, err = m.openJobLogs(ctx, jobName, namespace, true)
+			return err == nil, nil
+		})
+		if err != nil {
+			return
+		}
+		defer logs.Close()
+
+		serving, err := scanServing(logs)
+		if err != nil || serving == nil {
+			if err != nil && ctx.Err() == nil {
+				opts.Log().Log(ctx, logging.V(1), "Stopped waiting for served artifacts", "error", err)
+			}
+			return
+		}
+		if err := m.fetchServedArtifacts(ctx, opts.Log(), jobName, namespace, serving); err != nil && ctx.Err() == nil {
+			opts.Log().Warn("Failed to fetch the artifacts through a port-forward, reading them from the logs", "error", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// scanServing reads a log stream up to the serving record of the launcher,
+// nil when the stream ends without one
+func scanServing(logs io.Reader) (*launcher.Status, error) {
+	reader := bufio.NewReader(logs)
+	for {
+		line, err := reader.ReadString('\n')
+		if status, ok := launcher.ParseStatus(line); ok {
+			switch status.Type {
+			case launcher.StatusServing:
+				return &status, nil
+			case launcher.StatusFailed, launcher.StatusCompleted:
+				return nil, nil
+			}
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading logs: %w", err)
+		}
+	}
+}
+
+// fetchServedArtifacts downloads and verifies every artifact a launcher
+// serves, keeps them for the extraction of the results and reports them
+// fetched to the launcher
+func (m *Manager) fetchServedArtifacts(ctx context.Context, log *slog.Logger, jobName, namespace string, serving *launcher.Status) error {
+	podName, err := m.jobPodName(ctx, jobName, namespace)
+	if err != nil {
+		return err
+	}
+	fetcher := &artifactFetcher{m: m, log: log, namespace: namespace, pod: podName, port: int32(serving.Port)}
+	defer fetcher.close()
+
+	start := time.Now()
+	artifacts := make(map[string][]byte, len(serving.Artifacts))
+	var size int64
+	for _, info := range serving.Artifacts {
+		compressed, err := fetcher.fetch(ctx, info)
+		if err != nil {
+			return err
+		}
+		data, err := decompressPayload(compressed, strings.ToLower(info.Name))
+		if err != nil {
+			return err
+		}
+		artifacts[info.Name] = data
+		size += info.Size
+	}
+	m.served.add(jobName, artifacts)
+	log.Log(ctx, logging.V(1), "Fetched artifacts through a port-forward", "artifacts", len(artifacts), "bytes", size, "duration", time.Since(start).Round(time.Millisecond))
+
+	// A launcher not told waits for its serve timeout and prints the
+	// artifacts to the logs, which are then not read
+	if err := fetcher.done(ctx); err != nil {
+		log.Log(ctx, logging.V(1), "Failed to report the artifacts fetched to the launcher", "error", err)
+	}
+	return nil
+}
+
+// artifactFetcher downloads artifacts from a launcher through a port-forward,
+// opened again after a failure
+type artifactFetcher struct {
+	m         *Manager
+	log       *slog.Logger
+	namespace string
+	pod       string
+	port      int32
+
+	localPort uint16
+	stop      func()
+}
+
+// url returns the local URL of a path of the launcher, forwarding a port
+// first when none is open
+func (f *artifactFetcher) url(ctx context.Context, path string) (string, error) {
+	if f.stop == nil {
+		localPort, stop, err := endpoint.Forward(ctx, f.m.k8sConfig.Config, f.m.k8sConfig.Clientset, f.namespace, f.pod, f.port)
+		if err != nil {
+			return "", err
+		}
+		f.localPort, f.stop = localPort, stop
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d%s", f.localPort, path), nil
+}
+
+// close closes the port-forward
+func (f *artifactFetcher) close() {
+	if f.stop != nil {
+		f.stop()
+		f.stop = nil
+	}
+}
+
+// fetch downloads an artifact, resuming an interrupted download from the
+// bytes received so far, until its checksum matches
+func (f *artifactFetcher) fetch(ctx context.Context, info launcher.ArtifactInfo) ([]byte, error) {
+	var data []byte
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := f.download(ctx, info, &data)
+		if err == nil {
+			sum := sha256.Sum256(data)
+			if hex.EncodeToString(sum[:]) == info.SHA256 {
+				return data, nil
+			}
+			err = fmt.Errorf("checksum mismatch")
+			data = nil
+		}
+		if attempt == maxFetchAttempts || ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", strings.ToLower(info.Name), err)
+		}
+
+		f.log.Log(ctx, logging.V(1), "Retrying artifact download", "artifact", strings.ToLower(info.Name), "received", len(data), "size", info.Size, "error", err)
+		f.close()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// download appends the rest of an artifact to data
+func (f *artifactFetcher) download(ctx context.Context, info launcher.ArtifactInfo, data *[]byte) error {
+	url, err := f.url(ctx, launcher.ArtifactPath+info.Name)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if len(*data) > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(*data)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		*data = (*data)[:0]
+	case http.StatusPartialContent:
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	buf := bytes.NewBuffer(*data)
+	_, err = io.Copy(buf, resp.Body)
+	*data = buf.Bytes()
+	if err != nil {
+		return err
+	}
+	if int64(len(*data)) != info.Size {
+		return fmt.Errorf("received %d of %d bytes", len(*data), info.Size)
+	}
+	return nil
+}
+
+// done reports the artifacts fetched to the launcher
+func (f *artifactFetcher) done(ctx context.Context) error {
+	url, err := f.url(ctx, launcher.DonePath)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// jobPodName returns the name of the pod of a Job
+func (m *Manager) jobPodName(ctx context.Context, jobName, namespace string) (string, error) {
+	pods, err := m.k8sConfig.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", jobName),
+	})
+	if err != nil {
+		return "", apiError(err, "failed to list pods of job %s", jobName)
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for job %s", jobName)
+	}
+	return pods.Items[0].Name, nil
+}
@@ -0,0 +1,251 @@
+// Package launcher runs the profiler in the profiling pod without a shell
+// script: it resolves the target PID on the node, runs the profiler with the
+// arguments of the Job spec and reports its progress and artifacts as
+// newline-delimited JSON status records on stdout, which the CLI reads from
+// the logs.
+package launcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// MaxCommLen is the length of the comm of a process, longer names are truncated
+const MaxCommLen = 15
+
+// Artifact is a file the profiler writes, printed to the logs under Name
+// once it exits
+type Artifact struct {
+	Name     string
+	Path     string
+	Optional bool // Skipped with a warning when missing
+}
+
+// Options describe the target and the profiler run, from the arguments the
+// Job spec gives the launcher
+type Options struct {
+	// Container target, looked up through the container runtime
+	RuntimeEndpoint string
+	ContainerName   string
+	ContainerID     string // Without the runtime scheme
+	PodUID          string // As in cgroup paths
+	Command         string // Executable name of the container command
+	// Node process target instead of a container
+	HostProcess string
+	// PIDs as seen in the target container, the first one is sampled instead
+	// of the container's init process and the others along with it
+	PIDs []int
+
+	ProcRoot   string // Host /proc, /host/proc in the profiling pod
+	CgroupRoot string // Host cgroup hierarchy
+	Crictl     string
+
+	// Profiler command, run with --pid and --extra-pids appended
+	Profiler  []string
+	Artifacts []Artifact
+	// Compression of the artifacts, EncodingGzip or EncodingZstd; gzip when empty
+	Compression string
+	// How long the artifacts are served over HTTP on ServeAddr for the CLI to
+	// fetch through a port-forward before they are printed to the logs
+	// instead, 0 to only print them
+	ServeTimeout time.Duration
+	// Address the artifacts are served on, a random localhost port when empty
+	ServeAddr string
+}
+
+// Launcher runs one profile
+type Launcher struct {
+	opts   Options
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// New creates a Launcher writing the logs of the run to stdout and stderr
+func New(opts Options, stdout, stderr io.Writer) *Launcher {
+	if opts.ProcRoot == "" {
+		opts.ProcRoot = "/host/proc"
+	}
+	if opts.CgroupRoot == "" {
+		opts.CgroupRoot = "/host/sys/fs/cgroup"
+	}
+	if opts.Crictl == "" {
+		opts.Crictl = "crictl"
+	}
+	return &Launcher{opts: opts, stdout: stdout, stderr: stderr}
+}
+
+// Run resolves the target, profiles it and serves or prints the artifacts, reporting
+// each step as a status record on stdout, the last one completed or failed.
+// It returns the exit code of the profiler wrapped in an *exec.ExitError when
+// it failed.
+func (l *Launcher) Run(ctx context.Context) error {
+	err := l.run(ctx)
+	if err != nil {
+		l.emit(Status{Type: StatusFailed, Message: err.Error()})
+		return err
+	}
+	l.emit(Status{Type: StatusCompleted})
+	return nil
+}
+
+func (l *Launcher) run(ctx context.Context) error {
+	if len(l.opts.Profiler) == 0 {
+		return fmt.Errorf("no profiler command")
+	}
+	target, err := l.resolveTarget(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(l.opts.ProcRoot, strconv.Itoa(target), "ns")); err != nil {
+		return fmt.Errorf("cannot access the namespaces of process %d: %w", target, err)
+	}
+	var extra []int
+	if len(l.opts.PIDs) > 0 {
+		hostPIDs, err := l.translatePIDs(target)
+		if err != nil {
+			return err
+		}
+		target, extra = hostPIDs[0], hostPIDs[1:]
+	}
+	l.emit(Status{Type: StatusResolved, PID: target, ExtraPIDs: extra})
+
+	args := append([]string{}, l.opts.Profiler[1:]...)
+	args = append(args, "--pid", strconv.Itoa(target))
+	if len(extra) > 0 {
+		extraPIDs := make([]string, len(extra))
+		for i, pid := range extra {
+			extraPIDs[i] = strconv.Itoa(pid)
+		}
+		args = append(args, "--extra-pids", strings.Join(extraPIDs, ","))
+	}
+	name := filepath.Base(l.opts.Profiler[0])
+	l.emit(Status{Type: StatusStarted, Command: append([]string{l.opts.Profiler[0]}, args...)})
+
+	// The profiler prints its own output, read by the CLI, between the records
+	cmd := exec.CommandContext(ctx, l.opts.Profiler[0], args...)
+	cmd.Stdout, cmd.Stderr = l.stdout, l.stderr
+	cmd.Env = append(os.Environ(), "PROC_ROOT="+l.opts.ProcRoot)
+	err = cmd.Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	l.emit(Status{Type: StatusExited, ExitCode: &exitCode})
+	if exitCode != 0 {
+		return fmt.Errorf("%s exited with code %d: %w", name, exitCode, err)
+	}
+
+	var artifacts []compressedArtifact
+	for _, artifact := range l.opts.Artifacts {
+		compressed, ok, err := l.compressArtifact(artifact)
+		if err != nil {
+			return err
+		}
+		if ok {
+			artifacts = append(artifacts, compressed)
+		}
+	}
+
+	// Artifacts fetched from the server need not go through the logs
+	if l.opts.ServeTimeout > 0 {
+		fetched, err := l.serve(ctx, artifacts)
+		if err != nil {
+			l.emit(Status{Type: StatusWarning, Message: fmt.Sprintf("cannot serve the artifacts, printing them to the logs: %v", err)})
+		}
+		if fetched {
+			return nil
+		}
+	}
+	for _, artifact := range artifacts {
+		l.printArtifact(artifact)
+	}
+	return nil
+}
+
+// compressedArtifact is an artifact compressed in memory, ready to be
+// printed or served
+type compressedArtifact struct {
+	name     string
+	encoding string
+	data     []byte
+}
+
+// compressArtifact compresses the file of an artifact as the options say,
+// false for an optional artifact the profiler did not write
+func (l *Launcher) compressArtifact(artifact Artifact) (compressedArtifact, bool, error) {
+	file, err := os.Open(artifact.Path)
+	if err != nil {
+		if artifact.Optional {
+			l.emit(Status{Type: StatusWarning, Message: fmt.Sprintf("no %s data written to %s", strings.ToLower(artifact.Name), artifact.Path)})
+			return compressedArtifact{}, false, nil
+		}
+		return compressedArtifact{}, false, fmt.Errorf("failed to read %s: %w", artifact.Path, err)
+	}
+	defer file.Close()
+
+	var (
+		data       bytes.Buffer
+		compressor io.WriteCloser
+		encoding   = EncodingGzip
+	)
+	if l.opts.Compression == EncodingZstd {
+		encoding = EncodingZstd
+		if compressor, err = zstd.NewWriter(&data); err != nil {
+			return compressedArtifact{}, false, fmt.Errorf("failed to compress %s: %w", artifact.Path, err)
+		}
+	} else {
+		compressor = gzip.NewWriter(&data)
+	}
+	if _, err := io.Copy(compressor, file); err != nil {
+		compressor.Close()
+		return compressedArtifact{}, false, fmt.Errorf("failed to compress %s: %w", artifact.Path, err)
+	}
+	if err := compressor.Close(); err != nil {
+		return compressedArtifact{}, false, fmt.Errorf("failed to compress %s: %w", artifact.Path, err)
+	}
+	return compressedArtifact{name: artifact.Name, encoding: encoding, data: data.Bytes()}, true, nil
+}
+
+// printArtifact prints an artifact as records of base64 encoded chunks, with
+// the encoding in every record
+func (l *Launcher) printArtifact(artifact compressedArtifact) {
+	encoded := base64.StdEncoding.EncodeToString(artifact.data)
+	chunks := (len(encoded) + ChunkSize - 1) / ChunkSize
+	for i := 0; i < chunks; i++ {
+		end := min((i+1)*ChunkSize, len(encoded))
+		l.emit(Status{Type: StatusArtifact, Name: artifact.name, Encoding: artifact.encoding, Data: encoded[i*ChunkSize : end], Chunk: i, Chunks: chunks})
+	}
+}
+
+// logf reports what the launcher is doing as a progress record
+func (l *Launcher) logf(format string, args ...any) {
+	l.emit(Status{Type: StatusProgress, Message: fmt.Sprintf(format, args...)})
+}
+
+// emit prints a status record on a line of its own
+func (l *Launcher) emit(status Status) {
+	status.Version = ProtocolVersion
+	status.Time = time.Now().UTC()
+	line, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(l.stdout, "%s\n", line)
+}
@@ -0,0 +1,228 @@
+package launcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// fakeProfiler prints a line and writes the flame graph to its first argument
+const fakeProfiler = `#!/bin/sh
+echo "profiling $*"
+printf '<svg>flame</svg>' > "$1"
+`
+
+// runLauncher runs a launcher profiling the node process kubelet with the
+// profiler script and returns its status records, other lines and error
+func runLauncher(t *testing.T, profiler string, opts Options) ([]Status, []string, error) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"profiler": profiler})
+	if err := os.Chmod(filepath.Join(dir, "profiler"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	procRoot := filepath.Join(dir, "proc")
+	writeProcRoot(t, procRoot, map[int]process{70: {comm: "kubelet", cmdline: []string{"/usr/bin/kubelet"}}})
+	if err := os.Mkdir(filepath.Join(procRoot, "70", "ns"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.HostProcess = "kubelet"
+	opts.ProcRoot = procRoot
+	opts.Profiler = []string{filepath.Join(dir, "profiler"), filepath.Join(dir, "flamegraph.svg")}
+	opts.Artifacts = append([]Artifact{{Name: "FLAMEGRAPH", Path: filepath.Join(dir, "flamegraph.svg")}}, opts.Artifacts...)
+	var stdout bytes.Buffer
+	err := New(opts, &stdout, io.Discard).Run(context.Background())
+
+	var (
+		records []Status
+		other   []string
+	)
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		status, ok := ParseStatus(scanner.Text())
+		if !ok {
+			other = append(other, scanner.Text())
+			continue
+		}
+		if status.Version != ProtocolVersion || status.Time.IsZero() {
+			t.Errorf("record %+v lacks the protocol version or the time", status)
+		}
+		records = append(records, status)
+	}
+	return records, other, err
+}
+
+// types lists the types of the records
+func types(records []Status) []string {
+	var types []string
+	for _, status := range records {
+		types = append(types, status.Type)
+	}
+	return types
+}
+
+// decodeArtifact joins the chunks of an artifact and decompresses them
+func decodeArtifact(t *testing.T, records []Status, name string) string {
+	t.Helper()
+	var (
+		encoded  strings.Builder
+		encoding string
+		chunks   int
+	)
+	for _, status := range records {
+		if status.Type != StatusArtifact || status.Name != name {
+			continue
+		}
+		if status.Chunk != chunks {
+			t.Fatalf("chunk %d of %s, want %d", status.Chunk, name, chunks)
+		}
+		encoded.WriteString(status.Data)
+		encoding = status.Encoding
+		chunks++
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+	var r io.Reader
+	switch encoding {
+	case EncodingGzip:
+		if r, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			t.Fatalf("gunzip %s: %v", name, err)
+		}
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("zstd %s: %v", name, err)
+		}
+		defer decoder.Close()
+		r = decoder
+	default:
+		t.Fatalf("encoding %q of %s", encoding, name)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestRunStatusRecords(t *testing.T) {
+	for _, compression := range []string{"", EncodingZstd} {
+		t.Run("compression "+compression, func(t *testing.T) {
+			records, other, err := runLauncher(t, fakeProfiler, Options{
+				Compression: compression,
+				Artifacts:   []Artifact{{Name: "FOLDED", Path: "/nonexistent/folded.txt", Optional: true}},
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			want := []string{StatusProgress, StatusResolved, StatusStarted, StatusExited, StatusWarning, StatusArtifact, StatusCompleted}
+			if got := types(records); !reflect.DeepEqual(got, want) {
+				t.Fatalf("records %v, want %v", got, want)
+			}
+			if resolved := records[1]; resolved.PID != 70 {
+				t.Errorf("resolved PID %d, want 70", resolved.PID)
+			}
+			if started := records[2]; !reflect.DeepEqual(started.Command[2:], []string{"--pid", "70"}) {
+				t.Errorf("started %v, want the target PID appended", started.Command)
+			}
+			if exited := records[3]; exited.ExitCode == nil || *exited.ExitCode != 0 {
+				t.Errorf("exited with %v, want 0", exited.ExitCode)
+			}
+			if warning := records[4]; !strings.Contains(warning.Message, "no folded data") {
+				t.Errorf("warning %q, want the missing optional artifact", warning.Message)
+			}
+			if got := decodeArtifact(t, records, "FLAMEGRAPH"); got != "<svg>flame</svg>" {
+				t.Errorf("artifact %q, want the flame graph", got)
+			}
+			// The output of the profiler goes through as is
+			if len(other) != 1 || !strings.HasPrefix(other[0], "profiling ") {
+				t.Errorf("profiler output %q", other)
+			}
+		})
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiler string
+		opts     Options
+		wantCode int // Exit code of the profiler, 0 when it did not run
+		wantErr  string
+	}{
+		{
+			name:     "profiler fails",
+			profiler: "#!/bin/sh\necho 'Error: failed to attach' >&2\nexit 3\n",
+			wantCode: 3,
+			wantErr:  "exited with code 3",
+		},
+		{
+			name:     "required artifact missing",
+			profiler: "#!/bin/sh\nexit 0\n",
+			wantErr:  "failed to read",
+		},
+		{
+			name:     "PID namespace of the target unreadable",
+			profiler: fakeProfiler,
+			opts:     Options{PIDs: []int{7}},
+			wantErr:  "failed to read the PID namespace of 70",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _, err := runLauncher(t, tt.profiler, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Run error = %v, want %q", err, tt.wantErr)
+			}
+			var exitErr *exec.ExitError
+			if tt.wantCode != 0 && (!errors.As(err, &exitErr) || exitErr.ExitCode() != tt.wantCode) {
+				t.Errorf("Run error = %v, want the exit code %d", err, tt.wantCode)
+			}
+			last := records[len(records)-1]
+			if last.Type != StatusFailed || last.Message != err.Error() {
+				t.Errorf("last record %+v, want failed with %q", last, err)
+			}
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "record", line: `{"pprofLauncher":2,"type":"resolved","time":"2025-06-01T12:00:00Z","pid":70}`, want: true},
+		{name: "surrounding spaces", line: "  {\"pprofLauncher\":2,\"type\":\"completed\",\"time\":\"2025-06-01T12:00:00Z\"}\r", want: true},
+		{name: "profiler output", line: "Collected 100 samples"},
+		{name: "other JSON", line: `{"level":"info","msg":"sampling"}`},
+		{name: "truncated record", line: `{"pprofLauncher":2,"type":"artif`},
+		{name: "record without a type", line: `{"pprofLauncher":2,"time":"2025-06-01T12:00:00Z"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ParseStatus(tt.line); ok != tt.want {
+				t.Errorf("ParseStatus(%q) = %v, want %v", tt.line, ok, tt.want)
+			}
+		})
+	}
+}
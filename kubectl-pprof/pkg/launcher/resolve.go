@@ -0,0 +1,296 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// criContainer is what the launcher reads from 'crictl inspect -o json'
+type criContainer struct {
+	Info struct {
+		PID int `json:"pid"`
+	} `json:"info"`
+}
+
+// criContainers is what the launcher reads from 'crictl ps -o json'
+type criContainers struct {
+	Containers []struct {
+		ID string `json:"id"`
+	} `json:"containers"`
+}
+
+// errAmbiguousContainer is returned when several running containers of the
+// node match the name of the target, which no other lookup can tell apart
+var errAmbiguousContainer = errors.New("several running containers match")
+
+// resolveTarget returns the host PID of the target: the named node process,
+// or the init process of the container, looked up through the container
+// runtime, its state on the node, then the cgroups in /proc
+func (l *Launcher) resolveTarget(ctx context.Context) (int, error) {
+	if l.opts.HostProcess != "" {
+		return l.findHostProcess()
+	}
+
+	pid, err := l.criPID(ctx)
+	if errors.Is(err, errAmbiguousContainer) {
+		return 0, err
+	}
+	if err != nil {
+		l.logf("Container runtime lookup failed: %v", err)
+	} else {
+		l.logf("Found target container PID: %d", pid)
+		return pid, nil
+	}
+	if pid := l.statePID(); pid > 0 {
+		l.logf("Found target container PID from the runtime state: %d", pid)
+		return pid, nil
+	}
+	l.logf("Scanning %s", l.opts.ProcRoot)
+	if pid := l.scanProc(l.opts.ContainerID, ""); pid > 0 {
+		return l.foundInProc(pid), nil
+	}
+	if l.opts.PodUID != "" {
+		if pid := l.scanProc(l.opts.PodUID, l.opts.Command); pid > 0 {
+			return l.foundInProc(pid), nil
+		}
+		if pid := l.scanProc(strings.ReplaceAll(l.opts.PodUID, "-", "_"), l.opts.Command); pid > 0 {
+			return l.foundInProc(pid), nil
+		}
+	}
+	return 0, fmt.Errorf("container %s not found by the container runtime nor in %s", l.opts.ContainerName, l.opts.ProcRoot)
+}
+
+// foundInProc logs a PID found by scanning /proc
+func (l *Launcher) foundInProc(pid int) int {
+	l.logf("Found target container PID in %s: %d", l.opts.ProcRoot, pid)
+	return pid
+}
+
+// criPID asks the container runtime for the init PID of the container, found
+// by ID, or by name and pod UID when the pod status had no ID yet
+func (l *Launcher) criPID(ctx context.Context) (int, error) {
+	id := l.opts.ContainerID
+	if id == "" {
+		args := []string{"ps", "-o", "json", "--name", "^" + l.opts.ContainerName + "$"}
+		if l.opts.PodUID != "" {
+			args = append(args, "--label", "io.kubernetes.pod.uid="+l.opts.PodUID)
+		}
+		var containers criContainers
+		if err := l.crictl(ctx, &containers, args...); err != nil {
+			return 0, err
+		}
+		switch len(containers.Containers) {
+		case 0:
+			return 0, fmt.Errorf("no running container %s", l.opts.ContainerName)
+		case 1:
+			id = containers.Containers[0].ID
+		default:
+			ids := make([]string, len(containers.Containers))
+			for i, container := range containers.Containers {
+				ids[i] = container.ID
+			}
+			return 0, fmt.Errorf("%w %s of pod %s: %s", errAmbiguousContainer, l.opts.ContainerName, l.opts.PodUID, strings.Join(ids, ", "))
+		}
+	}
+	l.logf("Found container ID: %s", id)
+
+	var container criContainer
+	if err := l.crictl(ctx, &container, "inspect", "-o", "json", id); err != nil {
+		return 0, err
+	}
+	if container.Info.PID <= 0 {
+		return 0, fmt.Errorf("the container runtime reports no PID for container %s", id)
+	}
+	return container.Info.PID, nil
+}
+
+// crictl runs crictl against the runtime endpoint and decodes its JSON output
+func (l *Launcher) crictl(ctx context.Context, out any, args ...string) error {
+	subcommand := args[0]
+	if l.opts.RuntimeEndpoint != "" {
+		args = append([]string{"--runtime-endpoint", l.opts.RuntimeEndpoint}, args...)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, l.opts.Crictl, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("crictl %s failed: %w: %s", subcommand, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(output, out); err != nil {
+		return fmt.Errorf("failed to decode the output of crictl %s: %w", subcommand, err)
+	}
+	return nil
+}
+
+// statePID reads the init PID the container runtime recorded on the node:
+// containerd keeps one directory per namespace, k8s.io for CRI pods and moby
+// for Docker, CRI-O writes a pidfile. Failing that the first process of the
+// container cgroup is taken. Returns 0 when none is found.
+func (l *Launcher) statePID() int {
+	id := l.opts.ContainerID
+	if id == "" {
+		return 0
+	}
+	nodeRoot := filepath.Join(l.opts.ProcRoot, "1", "root")
+	for _, file := range []string{
+		filepath.Join(nodeRoot, "run/containerd/io.containerd.runtime.v2.task/k8s.io", id, "init.pid"),
+		filepath.Join(nodeRoot, "run/containerd/io.containerd.runtime.v2.task/moby", id, "init.pid"),
+		filepath.Join(nodeRoot, "run/containers/storage/overlay-containers", id, "userdata/pidfile"),
+	} {
+		if pid := readPID(file); pid > 0 {
+			l.logf("Found runtime state %s", file)
+			return pid
+		}
+	}
+
+	pid := 0
+	_ = filepath.WalkDir(l.opts.CgroupRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() || !strings.Contains(entry.Name(), id) {
+			return nil
+		}
+		if pid = readPID(filepath.Join(path, "cgroup.procs")); pid > 0 {
+			l.logf("Found container cgroup %s", path)
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return pid
+}
+
+// scanProc returns the lowest PID whose cgroup path contains match and whose
+// command line contains command, if given. Kernel threads and pause
+// containers are skipped. Returns 0 when none is found.
+func (l *Launcher) scanProc(match, command string) int {
+	if match == "" {
+		return 0
+	}
+	for _, pid := range l.pids() {
+		dir := filepath.Join(l.opts.ProcRoot, strconv.Itoa(pid))
+		cgroup, err := os.ReadFile(filepath.Join(dir, "cgroup"))
+		if err != nil || !bytes.Contains(cgroup, []byte(match)) {
+			continue
+		}
+		cmdline := readCmdline(dir)
+		if len(cmdline) == 0 || filepath.Base(cmdline[0]) == "pause" {
+			continue
+		}
+		if command != "" && !strings.Contains(strings.Join(cmdline, " "), command) {
+			continue
+		}
+		return pid
+	}
+	return 0
+}
+
+// findHostProcess returns the oldest node process whose comm, truncated by
+// the kernel to 15 characters, or whose executable name matches
+func (l *Launcher) findHostProcess() (int, error) {
+	comm := l.opts.HostProcess
+	if len(comm) > MaxCommLen {
+		comm = comm[:MaxCommLen]
+	}
+	for _, pid := range l.pids() {
+		dir := filepath.Join(l.opts.ProcRoot, strconv.Itoa(pid))
+		name, err := os.ReadFile(filepath.Join(dir, "comm"))
+		if err != nil {
+			continue
+		}
+		cmdline := readCmdline(dir)
+		if strings.TrimSpace(string(name)) == comm || (len(cmdline) > 0 && filepath.Base(cmdline[0]) == l.opts.HostProcess) {
+			l.logf("Found host process %s: %d", l.opts.HostProcess, pid)
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("host process %s not found in %s", l.opts.HostProcess, l.opts.ProcRoot)
+}
+
+// translatePIDs maps the PIDs of --pid, as seen in the PID namespace of the
+// target, to host PIDs, matching the last field of NSpid
+func (l *Launcher) translatePIDs(target int) ([]int, error) {
+	namespace, err := os.Readlink(filepath.Join(l.opts.ProcRoot, strconv.Itoa(target), "ns", "pid"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the PID namespace of %d: %w", target, err)
+	}
+	hostPIDs := make([]int, 0, len(l.opts.PIDs))
+	for _, nsPID := range l.opts.PIDs {
+		hostPID := l.hostPID(namespace, nsPID)
+		if hostPID == 0 {
+			return nil, fmt.Errorf("process %d not found in the target container's PID namespace", nsPID)
+		}
+		l.logf("Found process %d of the target container: host PID %d", nsPID, hostPID)
+		hostPIDs = append(hostPIDs, hostPID)
+	}
+	return hostPIDs, nil
+}
+
+// hostPID returns the host PID of the process nsPID of the PID namespace,
+// 0 when none
+func (l *Launcher) hostPID(namespace string, nsPID int) int {
+	for _, pid := range l.pids() {
+		dir := filepath.Join(l.opts.ProcRoot, strconv.Itoa(pid))
+		if link, err := os.Readlink(filepath.Join(dir, "ns", "pid")); err != nil || link != namespace {
+			continue
+		}
+		status, err := os.ReadFile(filepath.Join(dir, "status"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(status), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 && fields[0] == "NSpid:" && fields[len(fields)-1] == strconv.Itoa(nsPID) {
+				return pid
+			}
+		}
+	}
+	return 0
+}
+
+// pids lists the processes in the proc root, in ascending order
+func (l *Launcher) pids() []int {
+	entries, err := os.ReadDir(l.opts.ProcRoot)
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// readPID reads the first PID of a pidfile or cgroup.procs, 0 when none
+func readPID(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return 0
+	}
+	pid, _ := strconv.Atoi(fields[0])
+	return pid
+}
+
+// readCmdline returns the arguments of a process, none for kernel threads
+func readCmdline(dir string) []string {
+	content, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil
+	}
+	return strings.FieldsFunc(string(content), func(r rune) bool { return r == 0 })
+}
@@ -0,0 +1,205 @@
+package launcher
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeCrictl answers 'crictl ps' with ps.json and 'crictl inspect <id>' with
+// inspect-<id>.json of its directory, failing when the file is missing
+const fakeCrictl = `#!/bin/sh
+dir=$(dirname "$0")
+while [ "$1" = "--runtime-endpoint" ]; do shift 2; done
+case "$1" in
+ps) exec cat "$dir/ps.json" ;;
+inspect) exec cat "$dir/inspect-$4.json" ;;
+esac
+exit 1
+`
+
+// process is a process of a fake proc root
+type process struct {
+	comm    string
+	cmdline []string
+	cgroup  string
+}
+
+// writeProcRoot writes the comm, cmdline and cgroup of processes under root
+func writeProcRoot(t *testing.T, root string, processes map[int]process) {
+	t.Helper()
+	for pid, p := range processes {
+		dir := filepath.Join(root, strconv.Itoa(pid))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		cmdline := strings.Join(p.cmdline, "\x00")
+		for name, content := range map[string]string{"comm": p.comm + "\n", "cmdline": cmdline, "cgroup": p.cgroup + "\n"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// writeFiles writes files by path relative to root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	const (
+		podCgroup  = "0::/kubepods/burstable/pod6b1c1f4e-0d3a/cri-containerd-abc123.scope"
+		pod2Cgroup = "0::/kubepods.slice/kubepods-pod6b1c1f4e_0d3a.slice/cri-containerd-def456.scope"
+	)
+	tests := []struct {
+		name      string
+		opts      Options
+		crictl    map[string]string // Answers of the fake crictl
+		processes map[int]process
+		files     map[string]string // Under the proc root, or the cgroup root for cgroup/
+		want      int
+		wantErr   string
+	}{
+		{
+			name:   "container ID",
+			opts:   Options{ContainerName: "app", ContainerID: "abc123"},
+			crictl: map[string]string{"inspect-abc123.json": `{"info": {"pid": 42}}`},
+			want:   42,
+		},
+		{
+			name: "name in the pod",
+			opts: Options{ContainerName: "app", PodUID: "6b1c1f4e-0d3a"},
+			crictl: map[string]string{
+				"ps.json":             `{"containers": [{"id": "abc123"}]}`,
+				"inspect-abc123.json": `{"info": {"pid": 42}}`,
+			},
+			want: 42,
+		},
+		{
+			name:      "several containers of the name",
+			opts:      Options{ContainerName: "app", PodUID: "6b1c1f4e-0d3a", Command: "server"},
+			crictl:    map[string]string{"ps.json": `{"containers": [{"id": "abc123"}, {"id": "def456"}]}`},
+			processes: map[int]process{50: {comm: "server", cmdline: []string{"/app/server"}, cgroup: podCgroup}},
+			wantErr:   "several running containers match app of pod 6b1c1f4e-0d3a: abc123, def456",
+		},
+		{
+			name:  "containerd state",
+			opts:  Options{ContainerName: "app", ContainerID: "abc123"},
+			files: map[string]string{"1/root/run/containerd/io.containerd.runtime.v2.task/k8s.io/abc123/init.pid": "43"},
+			want:  43,
+		},
+		{
+			name:  "CRI-O state",
+			opts:  Options{ContainerName: "app", ContainerID: "abc123"},
+			files: map[string]string{"1/root/run/containers/storage/overlay-containers/abc123/userdata/pidfile": "44\n"},
+			want:  44,
+		},
+		{
+			name:  "container cgroup",
+			opts:  Options{ContainerName: "app", ContainerID: "abc123"},
+			files: map[string]string{"cgroup/kubepods/burstable/pod6b1c1f4e-0d3a/cri-containerd-abc123.scope/cgroup.procs": "45\n46\n"},
+			want:  45,
+		},
+		{
+			name: "container ID in /proc, past the pause process",
+			opts: Options{ContainerName: "app", ContainerID: "abc123"},
+			processes: map[int]process{
+				46: {comm: "pause", cmdline: []string{"/pause"}, cgroup: podCgroup},
+				47: {comm: "server", cmdline: []string{"/app/server"}, cgroup: podCgroup},
+			},
+			want: 47,
+		},
+		{
+			name: "pod UID of a systemd cgroup and command",
+			opts: Options{ContainerName: "app", PodUID: "6b1c1f4e-0d3a", Command: "server"},
+			processes: map[int]process{
+				48: {comm: "envoy", cmdline: []string{"/usr/bin/envoy"}, cgroup: pod2Cgroup},
+				49: {comm: "server", cmdline: []string{"/app/server", "--port", "8080"}, cgroup: pod2Cgroup},
+			},
+			want: 49,
+		},
+		{
+			name:    "container not found",
+			opts:    Options{ContainerName: "app", ContainerID: "abc123"},
+			wantErr: "container app not found by the container runtime nor in",
+		},
+		{
+			name: "host process by truncated comm",
+			opts: Options{HostProcess: "kube-controller-manager"},
+			processes: map[int]process{
+				60: {comm: "kube-controller", cmdline: []string{"/usr/local/bin/kube-controller-manager"}},
+			},
+			want: 60,
+		},
+		{
+			name: "host process by executable name",
+			opts: Options{HostProcess: "containerd"},
+			processes: map[int]process{
+				61: {comm: "containerd-shim", cmdline: []string{"/usr/bin/containerd-shim-runc-v2"}},
+				62: {comm: "ctrd", cmdline: []string{"/usr/bin/containerd"}},
+			},
+			want: 62,
+		},
+		{
+			name:      "host process not found",
+			opts:      Options{HostProcess: "kubelet"},
+			processes: map[int]process{63: {comm: "containerd", cmdline: []string{"/usr/bin/containerd"}}},
+			wantErr:   "host process kubelet not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := exec.LookPath("sh"); err != nil {
+				t.Skip("sh not found")
+			}
+			bin := t.TempDir()
+			writeFiles(t, bin, tt.crictl)
+			writeFiles(t, bin, map[string]string{"crictl": fakeCrictl})
+			if err := os.Chmod(filepath.Join(bin, "crictl"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			root := t.TempDir()
+			writeProcRoot(t, filepath.Join(root, "proc"), tt.processes)
+			writeFiles(t, root, map[string]string{"cgroup/cgroup.controllers": ""})
+			for name, content := range tt.files {
+				if !strings.HasPrefix(name, "cgroup/") {
+					name = filepath.Join("proc", name)
+				}
+				writeFiles(t, root, map[string]string{name: content})
+			}
+
+			opts := tt.opts
+			opts.ProcRoot = filepath.Join(root, "proc")
+			opts.CgroupRoot = filepath.Join(root, "cgroup")
+			opts.Crictl = filepath.Join(bin, "crictl")
+			pid, err := New(opts, io.Discard, io.Discard).resolveTarget(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveTarget = %d, %v, want an error %q", pid, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveTarget: %v", err)
+			}
+			if pid != tt.want {
+				t.Errorf("resolveTarget = %d, want %d", pid, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Endpoints of the artifact server
+const (
+	// ArtifactPath serves an artifact by name, with Range requests to resume
+	// an interrupted download
+	ArtifactPath = "/artifacts/"
+	// DonePath is posted once every artifact was fetched and verified
+	DonePath = "/done"
+)
+
+// ChecksumHeader carries the hex SHA-256 digest of a served artifact
+const ChecksumHeader = "X-Checksum-Sha256"
+
+// serve serves the artifacts over HTTP until the CLI reports them fetched,
+// returning true, or until ServeTimeout passes, returning false. The port is
+// announced by a serving status record.
+func (l *Launcher) serve(ctx context.Context, artifacts []compressedArtifact) (bool, error) {
+	addr := l.opts.ServeAddr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false, err
+	}
+
+	done := make(chan struct{})
+	mux := http.NewServeMux()
+	infos := make([]ArtifactInfo, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		sum := sha256.Sum256(artifact.data)
+		info := ArtifactInfo{Name: artifact.name, Encoding: artifact.encoding, Size: int64(len(artifact.data)), SHA256: hex.EncodeToString(sum[:])}
+		infos = append(infos, info)
+
+		data := artifact.data
+		mux.HandleFunc("GET "+ArtifactPath+artifact.name, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(ChecksumHeader, info.SHA256)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			http.ServeContent(w, r, info.Name, time.Time{}, bytes.NewReader(data))
+		})
+	}
+	mux.HandleFunc("POST "+DonePath, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	l.emit(Status{Type: StatusServing, Port: listener.Addr().(*net.TCPAddr).Port, Artifacts: infos})
+
+	timer := time.NewTimer(l.opts.ServeTimeout)
+	defer timer.Stop()
+	fetched := false
+	select {
+	case <-done:
+		fetched = true
+	case <-timer.C:
+		l.logf("Artifacts not fetched within %s", l.opts.ServeTimeout)
+	case <-ctx.Done():
+	case err = <-serveErr:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fetched, fmt.Errorf("artifact server failed: %w", err)
+	}
+	return fetched, nil
+}
@@ -0,0 +1,79 @@
+package launcher
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ProtocolVersion is the version of the status records the launcher prints,
+// raised when a record changes incompatibly
+const ProtocolVersion = 2
+
+// statusPrefix starts every status record, telling them from the output of
+// the profiler sharing stdout; the version is the first field of a Status
+const statusPrefix = `{"pprofLauncher":`
+
+// Types of status records, in the order of a run
+const (
+	StatusProgress  = "progress"  // Message says what the launcher is doing
+	StatusResolved  = "resolved"  // PID and ExtraPIDs were found
+	StatusStarted   = "started"   // Command runs the profiler
+	StatusExited    = "exited"    // ExitCode of the profiler
+	StatusArtifact  = "artifact"  // Data holds chunk Chunk of Chunks of the file Name, compressed as Encoding says and base64 encoded
+	StatusServing   = "serving"   // Port serves the Artifacts over HTTP on localhost until fetched
+	StatusWarning   = "warning"   // Message, e.g. an optional artifact was not written
+	StatusCompleted = "completed" // The artifacts were printed
+	StatusFailed    = "failed"    // Message says why the run failed, the last record
+)
+
+// Encodings of artifact data
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// Status is one newline-delimited JSON record the launcher prints to stdout
+type Status struct {
+	Version   int            `json:"pprofLauncher"`
+	Type      string         `json:"type"`
+	Time      time.Time      `json:"time"`
+	Message   string         `json:"message,omitempty"`
+	PID       int            `json:"pid,omitempty"`
+	ExtraPIDs []int          `json:"extraPids,omitempty"`
+	Command   []string       `json:"command,omitempty"`
+	ExitCode  *int           `json:"exitCode,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Encoding  string         `json:"encoding,omitempty"` // Of Data, gzip when empty
+	Data      string         `json:"data,omitempty"`
+	Chunk     int            `json:"chunk,omitempty"`  // Index of the artifact chunk in Data
+	Chunks    int            `json:"chunks,omitempty"` // Number of chunks of the artifact, Data holds all of it when 0
+	Port      int            `json:"port,omitempty"`
+	Artifacts []ArtifactInfo `json:"artifacts,omitempty"`
+}
+
+// ArtifactInfo describes an artifact served by the launcher
+type ArtifactInfo struct {
+	Name     string `json:"name"`
+	Encoding string `json:"encoding"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"` // Hex digest of the compressed data
+}
+
+// ChunkSize is the base64 length of the artifact chunks, each a record of its
+// own so an interrupted download resumes from the last chunk received
+const ChunkSize = 64 * 1024
+
+// ParseStatus decodes a log line holding a status record, false for any
+// other line
+func ParseStatus(line string) (Status, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, statusPrefix) {
+		return Status{}, false
+	}
+	var status Status
+	if err := json.Unmarshal([]byte(line), &status); err != nil || status.Type == "" {
+		return Status{}, false
+	}
+	return status, true
+}
@@ -0,0 +1,131 @@
+// Package logging builds the slog loggers of kubectl-pprof: a compact text
+// format for terminals and JSON for automation, with klog style verbosity.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Log formats accepted by New
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// MaxVerbosity is the highest supported verbosity
+const MaxVerbosity = 5
+
+// V returns the level of messages shown from verbosity n on. V(0) is the
+// info level, higher verbosities map below slog.LevelDebug at 4.
+func V(n int) slog.Level {
+	return slog.LevelInfo - slog.Level(n)
+}
+
+// New returns a logger writing to w in the given format that shows messages
+// up to verbosity v
+func New(w io.Writer, format string, v int) (*slog.Logger, error) {
+	if v < 0 || v > MaxVerbosity {
+		return nil, fmt.Errorf("verbosity must be between 0 and %d", MaxVerbosity)
+	}
+	level := V(v)
+	switch format {
+	case FormatText:
+		return slog.New(&textHandler{w: w, level: level, mu: &sync.Mutex{}}), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})), nil
+	default:
+		return nil, fmt.Errorf("invalid log format '%s', must be one of: %s, %s", format, FormatText, FormatJSON)
+	}
+}
+
+// Discard returns a logger that drops every message
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// textHandler writes one line per record: the message, prefixed for warnings,
+// errors and verbose messages, followed by the attributes as key=value
+type textHandler struct {
+	w      io.Writer
+	level  slog.Level
+	attrs  []slog.Attr
+	prefix string // Group prefix of attribute keys
+	mu     *sync.Mutex
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	switch {
+	case r.Level >= slog.LevelError:
+		buf.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		buf.WriteString("Warning: ")
+	case r.Level < slog.LevelInfo:
+		fmt.Fprintf(&buf, "[v%d] ", slog.LevelInfo-r.Level)
+	}
+	buf.WriteString(r.Message)
+
+	for _, attr := range h.attrs {
+		appendAttr(&buf, "", attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		appendAttr(&buf, h.prefix, attr)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, attr := range attrs {
+		clone.attrs = append(clone.attrs, slog.Attr{Key: h.prefix + attr.Key, Value: attr.Value})
+	}
+	return &clone
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// appendAttr writes " key=value", quoting values with spaces and flattening groups
+func appendAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			appendAttr(buf, prefix, member)
+		}
+		return
+	}
+	value := attr.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = fmt.Sprintf("%q", value)
+	}
+	fmt.Fprintf(buf, " %s%s=%s", prefix, attr.Key, value)
+}
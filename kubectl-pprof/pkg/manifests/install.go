@@ -0,0 +1,195 @@
+package manifests
+
+import (
+	"fmt"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Install modes
+const (
+	// ModeWebhook installs the admission webhook enforcing namespace profiling policies
+	ModeWebhook = "webhook"
+	// ModeOpenShift installs the SecurityContextConstraints profiling Jobs run under on OpenShift
+	ModeOpenShift = "openshift"
+)
+
+// InstallModes lists the components install can deploy
+var InstallModes = []string{ModeWebhook, ModeOpenShift}
+
+// Names of the webhook objects
+const (
+	webhookName       = "kubectl-pprof-policy-webhook"
+	webhookTLSSecret  = webhookName + "-tls"
+	webhookConfigName = "kubectl-pprof-policy"
+	webhookPort       = 8443
+)
+
+// Install is a kubectl-pprof component deployed into a cluster
+type Install struct {
+	Mode      string
+	Namespace string
+	Image     string
+	// Create the namespace as well
+	CreateNamespace bool
+	// PEM CA bundle the API server verifies the webhook with, unless CertManager
+	CABundle []byte
+	// Let cert-manager issue the serving certificate and inject the CA bundle
+	CertManager bool
+	// Reject profiling Jobs while the webhook is unavailable
+	FailClosed bool
+}
+
+// Objects returns the objects of the component
+func (in Install) Objects() ([]runtime.Object, error) {
+	switch in.Mode {
+	case ModeWebhook:
+	case ModeOpenShift:
+		return in.openShiftObjects(), nil
+	default:
+		return nil, fmt.Errorf("invalid mode %q, must be one of: %v", in.Mode, InstallModes)
+	}
+	if !in.CertManager && len(in.CABundle) == 0 {
+		return nil, fmt.Errorf("the webhook needs a CA bundle, or cert-manager to issue its certificate")
+	}
+
+	var objects []runtime.Object
+	if in.CreateNamespace {
+		objects = append(objects, &corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: in.Namespace, Labels: labels(ModeWebhook)},
+		})
+	}
+	objects = append(objects, RBAC(in.Namespace, webhookName, ModeWebhook, []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"list"}},
+	})...)
+	if in.CertManager {
+		objects = append(objects, in.certificate()...)
+	}
+	return append(objects, in.deployment(), in.service(), in.webhookConfiguration()), nil
+}
+
+// deployment runs the policy-webhook subcommand with the serving certificate
+func (in Install) deployment() *appsv1.Deployment {
+	image := in.Image
+	if image == "" {
+		image = DefaultCLIImage
+	}
+	replicas := int32(2)
+	podLabels := map[string]string{"app.kubernetes.io/name": "kubectl-pprof", "app.kubernetes.io/component": ModeWebhook}
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: webhookName, Namespace: in.Namespace, Labels: labels(ModeWebhook)},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: webhookName,
+					Containers: []corev1.Container{{
+						Name:    "webhook",
+						Image:   image,
+						Command: []string{"kubectl-pprof"},
+						Args: []string{"policy-webhook",
+							"--addr", fmt.Sprintf(":%d", webhookPort),
+							"--tls-cert-file", "/tls/tls.crt",
+							"--tls-private-key-file", "/tls/tls.key",
+						},
+						Ports:        []corev1.ContainerPort{{Name: "https", ContainerPort: webhookPort}},
+						VolumeMounts: []corev1.VolumeMount{{Name: "tls", MountPath: "/tls", ReadOnly: true}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name:         "tls",
+						VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: webhookTLSSecret}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// service exposes the webhook to the API server
+func (in Install) service() *corev1.Service {
+	return &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Name: webhookName, Namespace: in.Namespace, Labels: labels(ModeWebhook)},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app.kubernetes.io/name": "kubectl-pprof", "app.kubernetes.io/component": ModeWebhook},
+			Ports:    []corev1.ServicePort{{Name: "https", Port: 443, TargetPort: intstr.FromString("https")}},
+		},
+	}
+}
+
+// webhookConfiguration sends the creation of profiling Jobs to the webhook
+func (in Install) webhookConfiguration() *admissionregistrationv1.ValidatingWebhookConfiguration {
+	path := "/validate"
+	failurePolicy := admissionregistrationv1.Ignore
+	if in.FailClosed {
+		failurePolicy = admissionregistrationv1.Fail
+	}
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	timeout := int32(5)
+
+	config := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "admissionregistration.k8s.io/v1", Kind: "ValidatingWebhookConfiguration"},
+		ObjectMeta: metav1.ObjectMeta{Name: webhookConfigName, Labels: labels(ModeWebhook)},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name: "policy.kubectl-pprof.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service:  &admissionregistrationv1.ServiceReference{Namespace: in.Namespace, Name: webhookName, Path: &path},
+				CABundle: in.CABundle,
+			},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"batch"},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"jobs"},
+				},
+			}},
+			// Every Job: a profiler is told apart by its pod template, the
+			// labels of a Job are whatever its client sets
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          &timeout,
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	}
+	if in.CertManager {
+		config.Annotations = map[string]string{"cert-manager.io/inject-ca-from": in.Namespace + "/" + webhookName}
+	}
+	return config
+}
+
+// certificate returns a self-signed cert-manager Issuer and the serving
+// Certificate of the webhook, stored in the Secret the Deployment mounts
+func (in Install) certificate() []runtime.Object {
+	issuer := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Issuer",
+		"metadata":   map[string]interface{}{"name": webhookName, "namespace": in.Namespace},
+		"spec":       map[string]interface{}{"selfSigned": map[string]interface{}{}},
+	}}
+	service := webhookName + "." + in.Namespace + ".svc"
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": webhookName, "namespace": in.Namespace},
+		"spec": map[string]interface{}{
+			"secretName": webhookTLSSecret,
+			"dnsNames":   []interface{}{service, service + ".cluster.local"},
+			"issuerRef":  map[string]interface{}{"name": webhookName, "kind": "Issuer"},
+		},
+	}}
+	return []runtime.Object{issuer, certificate}
+}
@@ -0,0 +1,176 @@
+// Package manifests generates the Kubernetes objects that run kubectl-pprof
+// inside a cluster, renders them as YAML and applies them, so installation
+// lives in the binary rather than in separate documents.
+package manifests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultCLIImage is the image with the kubectl-pprof binary that runs in the cluster
+const DefaultCLIImage = "kubectl-pprof:latest"
+
+// Labels of every generated object
+var commonLabels = map[string]string{
+	"app.kubernetes.io/name":       "kubectl-pprof",
+	"app.kubernetes.io/managed-by": "kubectl-pprof",
+}
+
+// labels returns the common labels plus the component
+func labels(component string) map[string]string {
+	l := map[string]string{"app.kubernetes.io/component": component}
+	for k, v := range commonLabels {
+		l[k] = v
+	}
+	return l
+}
+
+// ProfilerRules are the permissions a profiling session needs: finding
+// targets, running Jobs and ephemeral containers, reading their logs,
+// port-forwarding to pprof endpoints, reading the resource usage of targets,
+// auditing and guarding nodes
+func ProfilerRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"nodes", "namespaces"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets", "daemonsets"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"create", "get", "list", "watch", "delete"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/log"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/ephemeralcontainers"}, Verbs: []string{"update"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/portforward"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"metrics.k8s.io"}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "create", "update"}},
+		{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"get", "create", "update", "delete"}},
+		{APIGroups: []string{"authorization.k8s.io"}, Resources: []string{"selfsubjectaccessreviews"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"authentication.k8s.io"}, Resources: []string{"selfsubjectreviews"}, Verbs: []string{"create"}},
+	}
+}
+
+// RBAC returns a ServiceAccount in namespace bound to a ClusterRole with the
+// given rules, all named name
+func RBAC(namespace, name, component string, rules []rbacv1.PolicyRule) []runtime.Object {
+	return []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels(component)},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels(component)},
+			Rules:      rules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels(component)},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: name, Namespace: namespace}},
+		},
+	}
+}
+
+// Render writes the objects as a multi-document YAML stream
+func Render(w io.Writer, objects ...runtime.Object) error {
+	var buf bytes.Buffer
+	for i, obj := range objects {
+		content, err := toUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(content)
+		if err != nil {
+			return fmt.Errorf("failed to render %T: %w", obj, err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// FieldManager owns the fields kubectl-pprof applies
+const FieldManager = "kubectl-pprof"
+
+// Apply creates or updates the objects with server-side apply
+func Apply(ctx context.Context, restConfig *rest.Config, objects ...runtime.Object) error {
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	disco, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco))
+
+	for _, obj := range objects {
+		content, err := toUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		u := &unstructured.Unstructured{Object: content}
+		gvk := u.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return fmt.Errorf("failed to map %s: %w", gvk.Kind, err)
+		}
+
+		resource := dyn.Resource(mapping.Resource)
+		var client dynamic.ResourceInterface = resource
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			client = resource.Namespace(u.GetNamespace())
+		}
+		if _, err := client.Apply(ctx, u.GetName(), u, metav1.ApplyOptions{FieldManager: FieldManager, Force: true}); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w", gvk.Kind, u.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// toUnstructured converts a generated object, dropping the empty status and
+// creation timestamps the typed structs always carry
+func toUnstructured(obj runtime.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %T: %w", obj, err)
+	}
+	delete(content, "status")
+	dropNullTimestamps(content)
+	return content, nil
+}
+
+// dropNullTimestamps removes unset creationTimestamp fields, including those
+// of embedded templates
+func dropNullTimestamps(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ts, ok := v["creationTimestamp"]; ok && ts == nil {
+			delete(v, "creationTimestamp")
+		}
+		for _, child := range v {
+			dropNullTimestamps(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			dropNullTimestamps(child)
+		}
+	}
+}
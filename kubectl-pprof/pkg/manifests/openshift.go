@@ -0,0 +1,58 @@
+package manifests
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// SCCName names the SecurityContextConstraints and the ServiceAccount allowed
+// to use it, installed with --mode openshift
+const SCCName = "kubectl-pprof"
+
+// openShiftObjects returns an SCC admitting exactly what profiling Jobs need,
+// hostPID, hostPath volumes, the eBPF capabilities and the spc_t SELinux
+// type, with a ServiceAccount in the namespace that may use it. Jobs run as
+// that ServiceAccount with --service-account and request the SCC with --scc,
+// instead of needing the privileged SCC.
+func (in Install) openShiftObjects() []runtime.Object {
+	componentLabels := map[string]interface{}{}
+	for k, v := range labels(ModeOpenShift) {
+		componentLabels[k] = v
+	}
+	scc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":               "security.openshift.io/v1",
+		"kind":                     "SecurityContextConstraints",
+		"metadata":                 map[string]interface{}{"name": SCCName, "labels": componentLabels},
+		"allowPrivilegedContainer": true,
+		"allowPrivilegeEscalation": true,
+		"allowHostPID":             true,
+		"allowHostIPC":             false,
+		"allowHostNetwork":         false,
+		"allowHostPorts":           false,
+		"allowHostDirVolumePlugin": true,
+		"readOnlyRootFilesystem":   false,
+		"allowedCapabilities":      []interface{}{"SYS_ADMIN", "SYS_RESOURCE", "SYS_PTRACE", "BPF", "PERFMON"},
+		"runAsUser":                map[string]interface{}{"type": "RunAsAny"},
+		"seLinuxContext": map[string]interface{}{
+			"type":           "MustRunAs",
+			"seLinuxOptions": map[string]interface{}{"type": "spc_t"},
+		},
+		"fsGroup":            map[string]interface{}{"type": "RunAsAny"},
+		"supplementalGroups": map[string]interface{}{"type": "RunAsAny"},
+		"volumes":            []interface{}{"hostPath", "configMap", "secret", "emptyDir", "projected", "downwardAPI"},
+	}}
+	var objects []runtime.Object
+	if in.CreateNamespace {
+		objects = append(objects, &corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: in.Namespace, Labels: labels(ModeOpenShift)},
+		})
+	}
+	objects = append(objects, scc)
+	return append(objects, RBAC(in.Namespace, SCCName, ModeOpenShift, []rbacv1.PolicyRule{
+		{APIGroups: []string{"security.openshift.io"}, Resources: []string{"securitycontextconstraints"}, ResourceNames: []string{SCCName}, Verbs: []string{"use"}},
+	})...)
+}
@@ -0,0 +1,192 @@
+package manifests
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Sink kinds
+const (
+	SinkPVC = "pvc" // Session store on a PersistentVolumeClaim
+	SinkS3  = "s3"  // S3 bucket, uploaded with the AWS CLI
+	SinkGCS = "gs"  // Google Cloud Storage bucket, uploaded with gcloud
+)
+
+// Uploader images of the object store sinks
+var uploaderImages = map[string]string{
+	SinkS3:  "amazon/aws-cli:latest",
+	SinkGCS: "gcr.io/google.com/cloudsdktool/google-cloud-cli:slim",
+}
+
+// Mount points in the scheduled pods
+const (
+	sessionsMount = "/sessions"
+	workMount     = "/work"
+)
+
+// Sink is where scheduled runs store their sessions
+type Sink struct {
+	Kind string
+	// Claim name for pvc, bucket URL for object stores
+	Target string
+}
+
+// ParseSink parses pvc:CLAIM, s3://BUCKET/PREFIX or gs://BUCKET/PREFIX
+func ParseSink(value string) (Sink, error) {
+	if claim, ok := strings.CutPrefix(value, "pvc:"); ok {
+		if errs := validation.IsDNS1123Subdomain(claim); len(errs) > 0 {
+			return Sink{}, fmt.Errorf("invalid claim name %q: %s", claim, strings.Join(errs, ", "))
+		}
+		return Sink{Kind: SinkPVC, Target: claim}, nil
+	}
+	for _, kind := range []string{SinkS3, SinkGCS} {
+		if bucket, ok := strings.CutPrefix(value, kind+"://"); ok && bucket != "" && !strings.HasPrefix(bucket, "/") {
+			return Sink{Kind: kind, Target: value}, nil
+		}
+	}
+	return Sink{}, fmt.Errorf("invalid sink %q, must be pvc:CLAIM, s3://BUCKET/PREFIX or gs://BUCKET/PREFIX", value)
+}
+
+// CronSchedule converts an interval into a cron schedule. The interval must
+// divide an hour into whole minutes or a day into whole hours.
+func CronSchedule(every time.Duration) (string, error) {
+	switch {
+	case every <= 0 || every%time.Minute != 0:
+		return "", fmt.Errorf("interval %s must be a positive number of minutes", every)
+	case every < time.Hour && time.Hour%every == 0:
+		return fmt.Sprintf("*/%d * * * *", int(every.Minutes())), nil
+	case every == time.Hour:
+		return "0 * * * *", nil
+	case every < 24*time.Hour && every%time.Hour == 0 && (24*time.Hour)%every == 0:
+		return fmt.Sprintf("0 */%d * * *", int(every.Hours())), nil
+	case every == 24*time.Hour:
+		return "0 0 * * *", nil
+	}
+	return "", fmt.Errorf("interval %s cannot be expressed as a cron schedule, use a divisor of an hour or a day, or --cron", every)
+}
+
+// Schedule is a CronJob running kubectl-pprof periodically
+type Schedule struct {
+	Name      string
+	Namespace string
+	// Cron schedule, see CronSchedule
+	Cron  string
+	Image string
+	// Profiling arguments of kubectl-pprof, e.g. -n prod -p api-0 -d 60s
+	Args []string
+	Sink Sink
+	// Secret with the object store credentials, exposed as environment
+	SinkSecret string
+}
+
+// Validate checks the schedule before objects are generated
+func (s Schedule) Validate() error {
+	// CronJob names leave room for the Job name suffix
+	if errs := validation.IsDNS1123Label(s.Name); len(errs) > 0 || len(s.Name) > 52 {
+		return fmt.Errorf("invalid schedule name %q, must be a DNS label of at most 52 characters", s.Name)
+	}
+	if s.Cron == "" {
+		return fmt.Errorf("schedule %s has no cron schedule", s.Name)
+	}
+	if len(s.Args) == 0 {
+		return fmt.Errorf("schedule %s has no profiling arguments", s.Name)
+	}
+	if s.Sink.Kind == "" {
+		return fmt.Errorf("schedule %s has no sink", s.Name)
+	}
+	return nil
+}
+
+// Objects returns the ServiceAccount, RBAC and CronJob of the schedule
+func (s Schedule) Objects() []runtime.Object {
+	return append(RBAC(s.Namespace, s.Name, "schedule", ProfilerRules()), s.CronJob())
+}
+
+// CronJob returns the CronJob of the schedule. Runs save their session into
+// the store on the claim, or into an emptyDir uploaded to the object store
+// once the profiler exits.
+func (s Schedule) CronJob() *batchv1.CronJob {
+	image := s.Image
+	if image == "" {
+		image = DefaultCLIImage
+	}
+	args := append(append([]string{}, s.Args...),
+		"--quiet",
+		"--output", workMount+"/flamegraph.svg",
+		"--save-session",
+		"--sessions-dir", sessionsMount,
+	)
+	profiler := corev1.Container{
+		Name:    "kubectl-pprof",
+		Image:   image,
+		Command: []string{"kubectl-pprof"},
+		Args:    args,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "sessions", MountPath: sessionsMount},
+			{Name: "work", MountPath: workMount},
+		},
+	}
+
+	sessions := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	podSpec := corev1.PodSpec{
+		ServiceAccountName: s.Name,
+		RestartPolicy:      corev1.RestartPolicyNever,
+	}
+	switch s.Sink.Kind {
+	case SinkPVC:
+		sessions = corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: s.Sink.Target}}
+		podSpec.Containers = []corev1.Container{profiler}
+	default:
+		// Upload only after the profiler succeeded
+		uploader := corev1.Container{
+			Name:         "upload",
+			Image:        uploaderImages[s.Sink.Kind],
+			VolumeMounts: []corev1.VolumeMount{{Name: "sessions", MountPath: sessionsMount, ReadOnly: true}},
+		}
+		if s.Sink.Kind == SinkS3 {
+			uploader.Command = []string{"aws", "s3", "sync", sessionsMount, s.Sink.Target}
+		} else {
+			uploader.Command = []string{"gcloud", "storage", "rsync", "--recursive", sessionsMount, s.Sink.Target}
+		}
+		if s.SinkSecret != "" {
+			uploader.EnvFrom = []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: s.SinkSecret}}}}
+		}
+		podSpec.InitContainers = []corev1.Container{profiler}
+		podSpec.Containers = []corev1.Container{uploader}
+	}
+	podSpec.Volumes = []corev1.Volume{
+		{Name: "sessions", VolumeSource: sessions},
+		{Name: "work", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+
+	backoffLimit := int32(0)
+	history := int32(3)
+	return &batchv1.CronJob{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{Name: s.Name, Namespace: s.Namespace, Labels: labels("schedule")},
+		Spec: batchv1.CronJobSpec{
+			Schedule: s.Cron,
+			// A run still profiling delays the next, overlapping runs would share the node lease
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &history,
+			FailedJobsHistoryLimit:     &history,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels("schedule")},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels("schedule")},
+						Spec:       podSpec,
+					},
+				},
+			},
+		},
+	}
+}
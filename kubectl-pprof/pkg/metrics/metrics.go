@@ -0,0 +1,210 @@
+// Package metrics counts profiling sessions and serves them in the Prometheus
+// text exposition format, so long-running processes embedding the profiler
+// (operators, node agents, batch sweeps) make their activity observable.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Metric names
+const (
+	ProfilesStarted   = "kubectl_pprof_profiles_started_total"
+	ProfilesSucceeded = "kubectl_pprof_profiles_succeeded_total"
+	ProfilesFailed    = "kubectl_pprof_profiles_failed_total"
+	ProfileDuration   = "kubectl_pprof_profile_duration_seconds"
+	ArtifactSize      = "kubectl_pprof_artifact_size_bytes"
+	NodeOverhead      = "kubectl_pprof_node_sampling_overhead_percent"
+	NodeProfilerCPU   = "kubectl_pprof_node_profiler_cpu_seconds_total"
+)
+
+// ContentType is the Prometheus text exposition format served by Metrics
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Histogram buckets
+var (
+	durationBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 1800}
+	sizeBuckets     = []float64{1 << 10, 16 << 10, 128 << 10, 1 << 20, 8 << 20, 64 << 20}
+)
+
+// Metrics holds the metrics of the sessions observed so far. A nil *Metrics
+// observes nothing.
+type Metrics struct {
+	mu          sync.Mutex
+	started     map[string]float64
+	succeeded   map[string]float64
+	failed      map[string]float64
+	durations   map[string]*histogram
+	sizes       map[string]*histogram
+	overhead    map[string]float64
+	profilerCPU map[string]float64
+}
+
+// New returns empty metrics
+func New() *Metrics {
+	return &Metrics{
+		started:     make(map[string]float64),
+		succeeded:   make(map[string]float64),
+		failed:      make(map[string]float64),
+		durations:   make(map[string]*histogram),
+		sizes:       make(map[string]*histogram),
+		overhead:    make(map[string]float64),
+		profilerCPU: make(map[string]float64),
+	}
+}
+
+// ProfileStarted counts a session of a profile type
+func (m *Metrics) ProfileStarted(profileType string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started[profileType]++
+}
+
+// ProfileFinished records the outcome, duration, artifact sizes and sampling
+// overhead of a session
+func (m *Metrics) ProfileFinished(profileType string, elapsed time.Duration, result *api.ProfileResult, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil || result == nil || !result.Success {
+		m.failed[profileType]++
+	} else {
+		m.succeeded[profileType]++
+	}
+	observe(m.durations, profileType, durationBuckets, elapsed.Seconds())
+	if result == nil {
+		return
+	}
+
+	if result.FileSize > 0 {
+		observe(m.sizes, "flamegraph", sizeBuckets, float64(result.FileSize))
+	}
+	for artifact, path := range map[string]string{"folded": result.FoldedPath, "timeline": result.TimelinePath, "pprof": result.PprofPath} {
+		if info, err := os.Stat(path); path != "" && err == nil {
+			observe(m.sizes, artifact, sizeBuckets, float64(info.Size()))
+		}
+	}
+
+	if result.Metadata == nil || result.Overhead == nil || result.Metadata.NodeName == "" {
+		return
+	}
+	node := result.Metadata.NodeName
+	if result.Overhead.ProfilerCPU > 0 {
+		m.overhead[node] = result.Overhead.ProfilerCPUPercent
+		m.profilerCPU[node] += result.Overhead.ProfilerCPU.Seconds()
+	} else {
+		m.overhead[node] = result.Overhead.EstimatedCPUPercent
+	}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	m.Write(w)
+}
+
+// Listen serves the metrics on addr under /metrics until the returned server is closed
+func (m *Metrics) Listen(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	return server, nil
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeValues(&b, ProfilesStarted, "counter", "Profiling sessions started.", "profile_type", m.started)
+	writeValues(&b, ProfilesSucceeded, "counter", "Profiling sessions that produced their artifacts.", "profile_type", m.succeeded)
+	writeValues(&b, ProfilesFailed, "counter", "Profiling sessions that failed.", "profile_type", m.failed)
+	writeHistograms(&b, ProfileDuration, "Wall time of profiling sessions, from target discovery to the saved artifacts.", "profile_type", m.durations)
+	writeHistograms(&b, ArtifactSize, "Size of the artifacts saved by profiling sessions.", "artifact", m.sizes)
+	writeValues(&b, NodeOverhead, "gauge", "CPU share of the node spent sampling in the last session on it, measured or else estimated.", "node", m.overhead)
+	writeValues(&b, NodeProfilerCPU, "counter", "Measured CPU time of the profiler on the node.", "node", m.profilerCPU)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// observe adds a value to the histogram of a label value, creating it
+func observe(histograms map[string]*histogram, label string, buckets []float64, value float64) {
+	h, ok := histograms[label]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		histograms[label] = h
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// writeValues writes a counter or gauge with one sample per label value
+func writeValues(b *strings.Builder, name, kind, help, label string, values map[string]float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(b, "%s{%s=%q} %g\n", name, label, key, values[key])
+	}
+}
+
+// writeHistograms writes a histogram with one series per label value
+func writeHistograms(b *strings.Builder, name, help, label string, histograms map[string]*histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, key := range sortedKeys(histograms) {
+		h := histograms[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket{%s=%q,le=%q} %d\n", name, label, key, strconv.FormatFloat(bound, 'f', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, label, key, h.count)
+		fmt.Fprintf(b, "%s_sum{%s=%q} %g\n", name, label, key, h.sum)
+		fmt.Fprintf(b, "%s_count{%s=%q} %d\n", name, label, key, h.count)
+	}
+}
+
+// sortedKeys returns the keys of a map in order, for a stable exposition
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,117 @@
+// Package nodecache keeps the kernel capabilities the profiler probed on a
+// node in a local directory, one JSON file per cluster and node, so that
+// later runs against the same node skip probing them again.
+package nodecache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"k8s.io/client-go/util/homedir"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// DirEnv overrides the default cache directory
+const DirEnv = "KUBECTL_PPROF_NODE_CACHE"
+
+// DefaultTTL is how long a probed node is trusted by default. Kernel upgrades
+// change the kernel version of the node and invalidate its entry at once.
+const DefaultTTL = 24 * time.Hour
+
+// unsafeNameChars are replaced in the file names of entries
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Entry is what was probed on a node
+type Entry struct {
+	Server string `json:"server"`
+	Node   string `json:"node"`
+	// Kernel version and CRI socket of the node when it was probed; the entry
+	// no longer applies once either changed
+	KernelVersion string               `json:"kernelVersion"`
+	RuntimeSocket string               `json:"runtimeSocket"`
+	Preflight     *api.PreflightReport `json:"preflight"`
+	ProbedAt      time.Time            `json:"probedAt"`
+}
+
+// Cache is a directory of probed nodes
+type Cache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// New returns the cache in dir, DefaultDir() when empty
+func New(dir string, ttl time.Duration) *Cache {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	return &Cache{Dir: dir, TTL: ttl}
+}
+
+// DefaultDir returns $KUBECTL_PPROF_NODE_CACHE, else ~/.kubectl-pprof/nodes
+func DefaultDir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(homedir.HomeDir(), ".kubectl-pprof", "nodes")
+}
+
+// path returns the file of the entry of a node of the cluster behind server
+func (c *Cache) path(server, node string) string {
+	name := unsafeNameChars.ReplaceAllString(server, "_") + "_" + unsafeNameChars.ReplaceAllString(node, "_") + ".json"
+	return filepath.Join(c.Dir, name)
+}
+
+// Get returns the entry of a node when it was probed within the TTL on the
+// same kernel version and runtime socket. Missing, stale and unreadable
+// entries are all misses.
+func (c *Cache) Get(server, node, kernelVersion, runtimeSocket string, now time.Time) (*Entry, bool) {
+	data, err := os.ReadFile(c.path(server, node))
+	if err != nil {
+		return nil, false
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.Preflight == nil {
+		return nil, false
+	}
+	if entry.Server != server || entry.Node != node || entry.KernelVersion != kernelVersion || entry.RuntimeSocket != runtimeSocket {
+		return nil, false
+	}
+	if now.Sub(entry.ProbedAt) > c.TTL || entry.ProbedAt.After(now) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Put stores the entry of a node, replacing the previous one
+func (c *Cache) Put(entry *Entry) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create node cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode node cache entry: %w", err)
+	}
+	// Write then rename, so concurrent runs never read half an entry
+	path := c.path(entry.Server, entry.Node)
+	tmp, err := os.CreateTemp(c.Dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write node cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write node cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write node cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write node cache entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package policy bounds the profiling sessions of a namespace with
+// annotations set by the platform team on the namespace itself:
+//
+//	kubectl-pprof.io/max-duration: 2m
+//	kubectl-pprof.io/max-frequency: "99"
+//	kubectl-pprof.io/allowed-hours: 09:00-18:00,22:00-02:00
+//	kubectl-pprof.io/max-concurrent: "1"
+//
+// Allowed hours are UTC ranges, a range may wrap midnight. The profiler checks
+// the policy of the target namespace before starting, and the admission
+// Webhook enforces it on the Jobs of every client, finding the namespaces a
+// Job profiles from its pod template rather than from its labels.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Namespace annotations of a policy
+const (
+	AnnotationMaxDuration   = "kubectl-pprof.io/max-duration"
+	AnnotationMaxFrequency  = "kubectl-pprof.io/max-frequency"
+	AnnotationAllowedHours  = "kubectl-pprof.io/allowed-hours"
+	AnnotationMaxConcurrent = "kubectl-pprof.io/max-concurrent"
+)
+
+// Job annotations describing the session a profiling Job runs
+const (
+	JobAnnotationTarget    = "kubectl-pprof/target" // namespace/pod
+	JobAnnotationDuration  = "kubectl-pprof/duration"
+	JobAnnotationFrequency = "kubectl-pprof/frequency" // 0 for traced profiles
+)
+
+// HourRange is a daily UTC window, as offsets from midnight
+type HourRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Contains reports whether the time of day of t falls into the window
+func (r HourRange) Contains(t time.Time) bool {
+	t = t.UTC()
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if r.Start <= r.End {
+		return offset >= r.Start && offset < r.End
+	}
+	return offset >= r.Start || offset < r.End
+}
+
+func (r HourRange) String() string {
+	clock := func(d time.Duration) string { return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60) }
+	return clock(r.Start) + "-" + clock(r.End)
+}
+
+// Policy bounds the sessions profiling the pods of a namespace, zero fields
+// are unbounded
+type Policy struct {
+	Namespace     string
+	MaxDuration   time.Duration
+	MaxFrequency  int
+	AllowedHours  []HourRange
+	MaxConcurrent int
+}
+
+// Session is what a profiling session asks for
+type Session struct {
+	Duration time.Duration
+	// Frequency is 0 for traced profiles, which do not sample
+	Frequency int
+}
+
+// Parse reads the policy of a namespace from its annotations, nil when none is set
+func Parse(namespace string, annotations map[string]string) (*Policy, error) {
+	p := &Policy{Namespace: namespace}
+	set := false
+
+	if value, ok := annotations[AnnotationMaxDuration]; ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q on namespace %s: must be a positive duration", AnnotationMaxDuration, value, namespace)
+		}
+		p.MaxDuration, set = d, true
+	}
+	if value, ok := annotations[AnnotationMaxFrequency]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid %s %q on namespace %s: must be a positive number of Hz", AnnotationMaxFrequency, value, namespace)
+		}
+		p.MaxFrequency, set = n, true
+	}
+	if value, ok := annotations[AnnotationMaxConcurrent]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid %s %q on namespace %s: must be a positive number", AnnotationMaxConcurrent, value, namespace)
+		}
+		p.MaxConcurrent, set = n, true
+	}
+	if value, ok := annotations[AnnotationAllowedHours]; ok {
+		for _, window := range strings.Split(value, ",") {
+			r, err := parseHourRange(strings.TrimSpace(window))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q on namespace %s: %w", AnnotationAllowedHours, value, namespace, err)
+			}
+			p.AllowedHours = append(p.AllowedHours, r)
+		}
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// parseHourRange parses "HH:MM-HH:MM"
+func parseHourRange(value string) (HourRange, error) {
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return HourRange{}, fmt.Errorf("window %q must be HH:MM-HH:MM", value)
+	}
+	var r HourRange
+	for i, clock := range []string{start, end} {
+		t, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return HourRange{}, fmt.Errorf("window %q must be HH:MM-HH:MM", value)
+		}
+		offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			r.Start = offset
+		} else {
+			r.End = offset
+		}
+	}
+	if r.Start == r.End {
+		return HourRange{}, fmt.Errorf("window %q is empty", value)
+	}
+	return r, nil
+}
+
+// Check returns an error naming every bound the session exceeds, given the
+// sessions already active in the namespace
+func (p *Policy) Check(session Session, now time.Time, active int) error {
+	if p == nil {
+		return nil
+	}
+	var violations []string
+	if p.MaxDuration > 0 && session.Duration > p.MaxDuration {
+		violations = append(violations, fmt.Sprintf("duration %s exceeds the maximum of %s", session.Duration, p.MaxDuration))
+	}
+	if p.MaxFrequency > 0 && session.Frequency > p.MaxFrequency {
+		violations = append(violations, fmt.Sprintf("frequency %dHz exceeds the maximum of %dHz", session.Frequency, p.MaxFrequency))
+	}
+	if len(p.AllowedHours) > 0 {
+		allowed := false
+		windows := make([]string, len(p.AllowedHours))
+		for i, r := range p.AllowedHours {
+			allowed = allowed || r.Contains(now)
+			windows[i] = r.String()
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("profiling is only allowed %s UTC, it is %s UTC", strings.Join(windows, ", "), now.UTC().Format("15:04")))
+		}
+	}
+	if p.MaxConcurrent > 0 && active >= p.MaxConcurrent {
+		violations = append(violations, fmt.Sprintf("%d sessions already profile the namespace, the maximum is %d", active, p.MaxConcurrent))
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("profiling policy of namespace %s: %s", p.Namespace, strings.Join(violations, "; "))
+}
+
+// ForNamespace reads the policy of a namespace, nil when it sets none or is gone
+func ForNamespace(ctx context.Context, client kubernetes.Interface, namespace string) (*Policy, error) {
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	return Parse(namespace, ns.Annotations)
+}
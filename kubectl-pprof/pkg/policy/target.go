@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// hostnameLabel pins a pod to a node through its node selector
+const hostnameLabel = "kubernetes.io/hostname"
+
+// ProfilerTemplate reports whether a pod template can profile other pods:
+// sharing the host PID namespace or running a privileged container, whatever
+// its labels say
+func ProfilerTemplate(spec *corev1.PodSpec) bool {
+	if spec.HostPID {
+		return true
+	}
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, c := range containers {
+			if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// templateNode returns the node a profiler pod template runs on, empty when
+// it may run on any node
+func templateNode(spec *corev1.PodSpec) string {
+	if spec.NodeName != "" {
+		return spec.NodeName
+	}
+	return spec.NodeSelector[hostnameLabel]
+}
+
+// TargetNamespaces returns the namespaces whose policies bound a profiler pod
+// template: those of every pod on its node, or of the cluster when it names
+// no node. The pod a template says it profiles is not trusted, a privileged
+// host PID pod reaches every process of its node whatever its environment
+// or arguments name.
+func TargetNamespaces(ctx context.Context, client kubernetes.Interface, spec *corev1.PodSpec) ([]string, error) {
+	node := templateNode(spec)
+	opts := metav1.ListOptions{}
+	if node != "" {
+		opts.FieldSelector = "spec.nodeName=" + node
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pods the profiling job reaches: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var namespaces []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if (node != "" && pod.Spec.NodeName != node) || seen[pod.Namespace] {
+			continue
+		}
+		seen[pod.Namespace] = true
+		namespaces = append(namespaces, pod.Namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// ActiveSessions counts the unfinished profiling Jobs of the cluster reaching
+// a pod of namespace, told apart by their pod templates: those running on the
+// node of one of its pods
+func ActiveSessions(ctx context.Context, client kubernetes.Interface, namespace string) (int, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pods in %s: %w", namespace, err)
+	}
+	jobs, err := client.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list profiling jobs: %w", err)
+	}
+	active := 0
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if !jobActive(job) || !ProfilerTemplate(&job.Spec.Template.Spec) {
+			continue
+		}
+		node := templateNode(&job.Spec.Template.Spec)
+		for j := range pods.Items {
+			if node == "" || node == pods.Items[j].Spec.NodeName {
+				active++
+				break
+			}
+		}
+	}
+	return active, nil
+}
+
+// jobActive reports whether a Job has neither finished nor is being deleted
+func jobActive(job *batchv1.Job) bool {
+	return job.Status.Succeeded == 0 && job.Status.Failed == 0 && job.DeletionTimestamp == nil
+}
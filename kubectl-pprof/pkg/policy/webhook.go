@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Webhook is a validating admission webhook enforcing the policy of the target
+// namespace on the profiling Jobs created by any client. Ephemeral profiler
+// containers and pprof endpoint sessions create no Job and are only checked
+// client side.
+type Webhook struct {
+	Client kubernetes.Interface
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// ServeHTTP answers an AdmissionReview
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	if err := wh.review(r, review.Request); err != nil {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+			Message: err.Error(),
+		}
+	}
+	review.Response = response
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&review)
+}
+
+// review returns why a request must be denied, nil to admit it. Jobs are
+// told apart by their pod template, not by labels or annotations the client
+// sets: a privileged or host PID pod reaches the processes of other pods.
+func (wh *Webhook) review(r *http.Request, req *admissionv1.AdmissionRequest) error {
+	if req.Operation != admissionv1.Create || req.Kind.Group != "batch" || req.Kind.Kind != "Job" {
+		return nil
+	}
+	var job batchv1.Job
+	if err := json.Unmarshal(req.Object.Raw, &job); err != nil {
+		return fmt.Errorf("failed to decode job: %w", err)
+	}
+	if !ProfilerTemplate(&job.Spec.Template.Spec) {
+		return nil
+	}
+
+	namespaces, err := TargetNamespaces(r.Context(), wh.Client, &job.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
+	now := time.Now
+	if wh.Now != nil {
+		now = wh.Now
+	}
+	for _, namespace := range namespaces {
+		policy, err := ForNamespace(r.Context(), wh.Client, namespace)
+		if err != nil {
+			return err
+		}
+		if policy == nil {
+			continue
+		}
+		// Only a bounded namespace needs the session, clients predating
+		// the annotations still profile the others
+		session, err := JobSession(&job)
+		if err != nil {
+			return err
+		}
+		active := 0
+		if policy.MaxConcurrent > 0 {
+			if active, err = ActiveSessions(r.Context(), wh.Client, namespace); err != nil {
+				return err
+			}
+		}
+		if err := policy.Check(session, now(), active); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JobSession reads the session a profiling Job runs from its annotations. A
+// missing annotation is an error, a Job without it could exceed any bound.
+func JobSession(job *batchv1.Job) (Session, error) {
+	var session Session
+	value, ok := job.Annotations[JobAnnotationDuration]
+	if !ok {
+		return Session{}, fmt.Errorf("profiling job has no %s annotation", JobAnnotationDuration)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return Session{}, fmt.Errorf("invalid %s %q on profiling job: must be a positive duration", JobAnnotationDuration, value)
+	}
+	session.Duration = d
+
+	if value, ok = job.Annotations[JobAnnotationFrequency]; !ok {
+		return Session{}, fmt.Errorf("profiling job has no %s annotation", JobAnnotationFrequency)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return Session{}, fmt.Errorf("invalid %s %q on profiling job: must be a number of Hz, 0 for traced profiles", JobAnnotationFrequency, value)
+	}
+	session.Frequency = n
+	return session, nil
+}
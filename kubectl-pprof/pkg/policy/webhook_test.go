@@ -0,0 +1,182 @@
+package policy_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/withlin/kubectl-pprof/pkg/policy"
+)
+
+// namespace returns a namespace with the policy annotations
+func namespace(name string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+// pod returns a pod running on node
+func pod(namespace, name, uid, node string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID("uid-" + uid)},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+}
+
+// profilingJob returns a host PID Job on node profiling for duration,
+// naming podUID as its target the way kubectl-pprof Jobs do
+func profilingJob(node, podUID string, duration time.Duration) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "tenant",
+			Name:      "pprof-job",
+			Annotations: map[string]string{
+				policy.JobAnnotationDuration:  duration.String(),
+				policy.JobAnnotationFrequency: "99",
+			},
+		},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			HostPID:      true,
+			NodeSelector: map[string]string{"kubernetes.io/hostname": node},
+			Containers: []corev1.Container{{
+				Name: "profiler",
+				Env:  []corev1.EnvVar{{Name: "TARGET_POD_UID", Value: podUID}},
+				Args: []string{"--pod-uid", podUID},
+			}},
+		}}},
+	}
+}
+
+// admit sends the creation of job to the webhook and returns its response
+func admit(t *testing.T, wh *policy.Webhook, job *batchv1.Job) *admissionv1.AdmissionResponse {
+	t.Helper()
+	raw, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+		UID:       "review-1",
+		Kind:      metav1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+	body, err := json.Marshal(&review)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("webhook answered %d: %s", rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &review); err != nil || review.Response == nil {
+		t.Fatalf("invalid AdmissionReview %s: %v", rec.Body, err)
+	}
+	return review.Response
+}
+
+func TestWebhook(t *testing.T) {
+	// tenant may be profiled for 5 minutes, payments for 30 seconds and open
+	// without bounds. node-1 runs tenant and payments pods, node-2 payments
+	// and open ones, node-3 only open and node-4 only tenant.
+	objects := []runtime.Object{
+		namespace("tenant", map[string]string{policy.AnnotationMaxDuration: "5m"}),
+		namespace("payments", map[string]string{policy.AnnotationMaxDuration: "30s"}),
+		namespace("open", nil),
+		pod("tenant", "app", "tenant-app", "node-1"),
+		pod("payments", "api", "payments-api", "node-1"),
+		pod("payments", "worker", "payments-worker", "node-2"),
+		pod("tenant", "batch", "tenant-batch", "node-4"),
+		pod("open", "web", "open-web", "node-3"),
+		pod("open", "mine", "open-mine", "node-2"),
+	}
+	now := func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		job    func() *batchv1.Job
+		denied string // Part of the denial, empty to admit
+	}{
+		{
+			name: "within the policies of the node",
+			job:  func() *batchv1.Job { return profilingJob("node-1", "uid-tenant-app", 20*time.Second) },
+		},
+		{
+			name:   "beyond the policy of the target",
+			job:    func() *batchv1.Job { return profilingJob("node-4", "uid-tenant-batch", 10*time.Minute) },
+			denied: "namespace tenant",
+		},
+		{
+			name:   "target allowed, a neighbor on the node denies",
+			job:    func() *batchv1.Job { return profilingJob("node-1", "uid-tenant-app", time.Minute) },
+			denied: "namespace payments",
+		},
+		{
+			name:   "UID of an unbounded pod next to a bounded one",
+			job:    func() *batchv1.Job { return profilingJob("node-2", "uid-open-mine", time.Minute) },
+			denied: "namespace payments",
+		},
+		{
+			name: "node without bounded namespaces",
+			job:  func() *batchv1.Job { return profilingJob("node-3", "uid-tenant-app", time.Hour) },
+		},
+		{
+			name: "no node reaches the cluster",
+			job: func() *batchv1.Job {
+				job := profilingJob("", "uid-open-web", time.Minute)
+				job.Spec.Template.Spec.NodeSelector = nil
+				return job
+			},
+			denied: "namespace payments",
+		},
+		{
+			name: "missing session annotations",
+			job: func() *batchv1.Job {
+				job := profilingJob("node-1", "uid-tenant-app", 20*time.Second)
+				job.Annotations = nil
+				return job
+			},
+			denied: "has no kubectl-pprof/duration annotation",
+		},
+		{
+			name: "unprivileged Job",
+			job: func() *batchv1.Job {
+				job := profilingJob("node-1", "uid-tenant-app", time.Hour)
+				job.Spec.Template.Spec.HostPID = false
+				return job
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wh := &policy.Webhook{Client: k8sfake.NewSimpleClientset(objects...), Now: now}
+			response := admit(t, wh, tt.job())
+			if response.UID != "review-1" {
+				t.Errorf("response UID = %q, want the request UID", response.UID)
+			}
+			if tt.denied == "" {
+				if !response.Allowed {
+					t.Fatalf("denied: %s", response.Result.Message)
+				}
+				return
+			}
+			if response.Allowed {
+				t.Fatalf("admitted, want a denial naming %q", tt.denied)
+			}
+			if !strings.Contains(response.Result.Message, tt.denied) {
+				t.Errorf("denial %q does not name %q", response.Result.Message, tt.denied)
+			}
+		})
+	}
+}
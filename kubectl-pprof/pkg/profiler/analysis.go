@@ -0,0 +1,41 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/analysis"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// collectFindings runs the analysis rules over the folded stacks of the job
+func (p *Profiler) collectFindings(ctx context.Context, cfg *api.ProfileConfig, jobName string) ([]api.Finding, error) {
+	data, err := p.transport.ExtractFoldedFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract folded stacks: %w", err)
+	}
+	profile, err := flamegraph.ParseFolded(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse folded stacks: %w", err)
+	}
+	return analysis.Analyze(profile, analysis.DefaultRules()), nil
+}
+
+// HTMLFindings lists findings above the graph of the HTML report, clicking
+// one highlights the frames it matched
+func HTMLFindings(findings []api.Finding) []flamegraph.Finding {
+	if len(findings) == 0 {
+		return nil
+	}
+	highlights := make([]flamegraph.Finding, 0, len(findings))
+	for _, f := range findings {
+		highlights = append(highlights, flamegraph.Finding{
+			Title:  fmt.Sprintf("%s (%.1f%%)", f.Title, f.Share),
+			Detail: f.Recommendation,
+			Search: f.Search,
+		})
+	}
+	return highlights
+}
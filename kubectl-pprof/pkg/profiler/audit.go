@@ -0,0 +1,45 @@
+package profiler
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/audit"
+)
+
+// auditSession records who profiles the target before sampling starts: an
+// Event on the pod unless disabled, and an entry in the audit ConfigMap when
+// one is configured. Failures are logged, they never stop the session.
+func (p *Profiler) auditSession(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, mode api.ProfileMode) {
+	if p.auditor == nil || (cfg.NoEvents && cfg.AuditConfigMap == "") {
+		return
+	}
+
+	meta := newSessionMetadata(cfg, target)
+	entry := &audit.Entry{
+		Time:        time.Now(),
+		User:        p.auditor.User(ctx),
+		Namespace:   target.Namespace,
+		PodName:     target.PodName,
+		Container:   target.ContainerName,
+		NodeName:    target.NodeName,
+		Mode:        string(mode),
+		ProfileType: cfg.ProfileType,
+		Duration:    cfg.Duration,
+		Frequency:   meta.Frequency,
+	}
+
+	if pod, ok := target.Pod.(*corev1.Pod); ok && !cfg.NoEvents {
+		if err := p.auditor.RecordEvent(ctx, pod, entry); err != nil {
+			opts.Log().Warn("Failed to record profiling event", "error", err)
+		}
+	}
+	if cfg.AuditConfigMap != "" {
+		if err := p.auditor.AppendToConfigMap(ctx, cfg.AuditConfigMap, entry); err != nil {
+			opts.Log().Warn("Failed to append to audit ConfigMap", "error", err)
+		}
+	}
+}
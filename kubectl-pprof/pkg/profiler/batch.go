@@ -0,0 +1,272 @@
+package profiler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+)
+
+// batchSummaryFile is written to the output directory after a batch
+const batchSummaryFile = "summary.json"
+
+// batchSlotWait is how long batch runs queue for a node another run profiles,
+// unless WaitForSlot is set
+const batchSlotWait = 30 * time.Minute
+
+// unsafeFileChars are replaced in artifact names derived from targets
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// LoadBatchSpec reads and validates a batch manifest. Defaults are applied to
+// every target, so the returned targets are complete.
+func LoadBatchSpec(path string) (*api.BatchSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+	spec := &api.BatchSpec{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file %s: %w", path, err)
+	}
+	if len(spec.Targets) == 0 {
+		return nil, fmt.Errorf("batch file %s lists no targets", path)
+	}
+
+	for i := range spec.Targets {
+		target := withBatchDefaults(spec.Targets[i], spec.Defaults)
+		if err := validateBatchTarget(target); err != nil {
+			return nil, fmt.Errorf("target %d (%s): %w", i+1, batchLabel(target, ""), err)
+		}
+		spec.Targets[i] = target
+	}
+	return spec, nil
+}
+
+// withBatchDefaults fills the unset fields of a target from the defaults
+func withBatchDefaults(target, defaults api.BatchTarget) api.BatchTarget {
+	pick := func(value, fallback string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	}
+	target.Namespace = pick(target.Namespace, defaults.Namespace)
+	target.Container = pick(target.Container, defaults.Container)
+	target.Duration = pick(target.Duration, defaults.Duration)
+	target.Options.ProfileType = pick(target.Options.ProfileType, defaults.Options.ProfileType)
+	target.Options.Mode = pick(target.Options.Mode, defaults.Options.Mode)
+	target.Options.PprofPort = pick(target.Options.PprofPort, defaults.Options.PprofPort)
+	target.Options.OutputFormat = pick(target.Options.OutputFormat, defaults.Options.OutputFormat)
+	if target.Options.Frequency == 0 {
+		target.Options.Frequency = defaults.Options.Frequency
+	}
+	target.Options.OffCPU = target.Options.OffCPU || defaults.Options.OffCPU
+	return target
+}
+
+// validateBatchTarget checks a target after the defaults were applied
+func validateBatchTarget(target api.BatchTarget) error {
+	if target.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if (target.Pod == "") == (target.Selector == "") {
+		return fmt.Errorf("exactly one of pod and selector is required")
+	}
+	if target.Duration != "" {
+		duration, err := time.ParseDuration(target.Duration)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid duration %q", target.Duration)
+		}
+	}
+	switch target.Options.ProfileType {
+	case "", api.ProfileTypeCPU, api.ProfileTypeSchedLat, api.ProfileTypeHeap, api.ProfileTypeNet:
+	default:
+		return fmt.Errorf("invalid profile type %q, must be one of: cpu, schedlat, heap, net", target.Options.ProfileType)
+	}
+	switch api.ProfileMode(target.Options.Mode) {
+	case "", api.ModeAuto, api.ModeJob, api.ModeEphemeral, api.ModePprof:
+	default:
+		return fmt.Errorf("invalid mode %q, must be one of: auto, job, ephemeral, pprof-endpoint", target.Options.Mode)
+	}
+	switch target.Options.OutputFormat {
+	case "", "svg", "png", "pdf", "html", "json":
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of: svg, png, pdf, html, json", target.Options.OutputFormat)
+	}
+	if target.Options.Frequency < 0 || target.Options.Frequency > 10000 {
+		return fmt.Errorf("frequency must be between 1 and 10000 Hz")
+	}
+	return nil
+}
+
+// batchLabel names a target in the summary: its name or namespace/pod, with
+// the pod appended for selector targets
+func batchLabel(target api.BatchTarget, pod string) string {
+	label := target.Name
+	if label == "" {
+		label = target.Namespace + "/" + target.Pod
+		if target.Pod == "" {
+			label = target.Namespace + "/" + target.Selector
+		}
+	}
+	if target.Selector != "" && pod != "" {
+		label += "/" + pod
+	}
+	return label
+}
+
+// batchRun one pod of a batch target
+type batchRun struct {
+	target api.BatchTarget
+	pod    string
+	label  string
+	err    error // Set when the target could not be expanded to pods
+}
+
+// ProfileBatch profiles the targets of a batch manifest with at most
+// maxParallel sessions at a time. Selector targets are expanded to all their
+// running pods. Artifacts of each pod and a summary.json are written to
+// outputDir; a failing target never stops the others.
+func (p *Profiler) ProfileBatch(ctx context.Context, spec *api.BatchSpec, cfg *api.ProfileConfig, opts *api.ProfileOptions, maxParallel int, outputDir string) ([]api.BatchResult, error) {
+	opts = p.sessionOptions(opts)
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var runs []batchRun
+	for _, target := range spec.Targets {
+		if target.Pod != "" {
+			runs = append(runs, batchRun{target: target, pod: target.Pod, label: batchLabel(target, target.Pod)})
+			continue
+		}
+		pods, err := p.discovery.ListPods(ctx, target.Namespace, target.Selector)
+		if err == nil && len(pods) == 0 {
+			err = fmt.Errorf("no running pods in %s match %q", target.Namespace, target.Selector)
+		}
+		if err != nil {
+			runs = append(runs, batchRun{target: target, label: batchLabel(target, ""), err: err})
+			continue
+		}
+		for _, pod := range pods {
+			runs = append(runs, batchRun{target: target, pod: pod.Name, label: batchLabel(target, pod.Name)})
+		}
+	}
+
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+	results := make([]api.BatchResult, len(runs))
+	slots := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		results[i] = api.BatchResult{Target: run.label, Namespace: run.target.Namespace, PodName: run.pod}
+		if run.err != nil {
+			results[i].Error = run.err.Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			rcfg, ropts, err := batchConfig(cfg, opts, run, i, outputDir)
+			if err == nil {
+				opts.Log().Info("Profiling target", "target", run.label)
+				start := time.Now()
+				results[i].Result, err = p.Profile(ctx, rcfg, ropts)
+				results[i].Elapsed = time.Since(start)
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return results, fmt.Errorf("failed to encode batch summary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, batchSummaryFile), data, 0644); err != nil {
+		return results, fmt.Errorf("failed to save batch summary: %w", err)
+	}
+	return results, nil
+}
+
+// batchConfig derives the configuration of one batch run from the command
+// line configuration and the target. Runs are quiet, the batch prints its own
+// progress, and the Job name carries the run index so concurrent runs never
+// collide.
+func batchConfig(cfg *api.ProfileConfig, opts *api.ProfileOptions, run batchRun, index int, outputDir string) (*api.ProfileConfig, *api.ProfileOptions, error) {
+	rcfg := *cfg
+	ropts := *opts
+	ropts.Quiet = true
+
+	target := run.target
+	rcfg.Namespace = target.Namespace
+	rcfg.PodName = run.pod
+	rcfg.ContainerName = target.Container
+	rcfg.JobName = job.JobNameWithSuffix(cfg, "b"+strconv.Itoa(index))
+	if rcfg.WaitForSlot == 0 {
+		// Pods of a sweep often share nodes, queue instead of failing
+		rcfg.WaitForSlot = batchSlotWait
+	}
+	if target.Duration != "" {
+		// Validated by LoadBatchSpec
+		rcfg.Duration, _ = time.ParseDuration(target.Duration)
+	}
+	if target.Options.ProfileType != "" {
+		rcfg.ProfileType = target.Options.ProfileType
+	}
+	if target.Options.Mode != "" {
+		rcfg.Mode = api.ProfileMode(target.Options.Mode)
+	}
+	if target.Options.PprofPort != "" {
+		rcfg.PprofPort = target.Options.PprofPort
+	}
+	if target.Options.OutputFormat != "" {
+		ropts.OutputFormat = target.Options.OutputFormat
+	}
+
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	if target.Options.Frequency > 0 {
+		goOpts.Frequency = target.Options.Frequency
+	}
+	goOpts.OffCPU = goOpts.OffCPU || target.Options.OffCPU
+	rcfg.GoOptions = &goOpts
+
+	if rcfg.ProfileType == api.ProfileTypeHeap && (rcfg.Mode == api.ModeJob || rcfg.Mode == api.ModeEphemeral) {
+		return nil, nil, fmt.Errorf("profile type heap reads the pprof endpoint and cannot be used with mode %s", rcfg.Mode)
+	}
+
+	ext := ".svg"
+	if ropts.OutputFormat != "svg" && ropts.OutputFormat != "json" && ropts.OutputFormat != "" {
+		ext = "." + ropts.OutputFormat
+	}
+	name := unsafeFileChars.ReplaceAllString(strings.ReplaceAll(run.label, "/", "-"), "_")
+	if target.Container != "" {
+		name += "-" + target.Container
+	}
+	rcfg.OutputPath = filepath.Join(outputDir, name+ext)
+	if cfg.ExportSummary != "" {
+		rcfg.ExportSummary = containerPath(cfg.ExportSummary, name)
+	}
+	return &rcfg, &ropts, nil
+}
@@ -0,0 +1,70 @@
+package profiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// buildFacts lists the Go build of the target for the HTML report, so the
+// profile can be tied to the exact binary it was taken from
+func buildFacts(info *api.BuildInfo) []flamegraph.Fact {
+	if info == nil {
+		return nil
+	}
+	var facts []flamegraph.Fact
+	if build := BuildSummary(info); build != "" {
+		facts = append(facts, flamegraph.Fact{Name: "Build", Value: build})
+	}
+	if info.Revision != "" {
+		facts = append(facts, flamegraph.Fact{Name: "Revision", Value: RevisionSummary(info)})
+	}
+	if len(info.Flags) > 0 {
+		facts = append(facts, flamegraph.Fact{Name: "Build flags", Value: BuildFlags(info)})
+	}
+	return facts
+}
+
+// BuildSummary describes the main package and the Go version it was built with
+func BuildSummary(info *api.BuildInfo) string {
+	switch {
+	case info.Path != "" && info.GoVersion != "":
+		return fmt.Sprintf("%s, %s", info.Path, info.GoVersion)
+	case info.Path != "":
+		return info.Path
+	default:
+		return info.GoVersion
+	}
+}
+
+// RevisionSummary describes the VCS revision of the build, its commit time
+// and whether the working tree had uncommitted changes
+func RevisionSummary(info *api.BuildInfo) string {
+	var notes []string
+	if info.Time != "" {
+		notes = append(notes, info.Time)
+	}
+	if info.Modified {
+		notes = append(notes, "modified")
+	}
+	if len(notes) == 0 {
+		return info.Revision
+	}
+	return fmt.Sprintf("%s (%s)", info.Revision, strings.Join(notes, ", "))
+}
+
+// BuildFlags formats the build settings as key=value pairs, quoting values
+// with spaces such as -ldflags
+func BuildFlags(info *api.BuildInfo) string {
+	flags := make([]string, 0, len(info.Flags))
+	for _, flag := range info.Flags {
+		value := flag.Value
+		if strings.ContainsAny(value, " \t\"") {
+			value = fmt.Sprintf("%q", value)
+		}
+		flags = append(flags, flag.Key+"="+value)
+	}
+	return strings.Join(flags, " ")
+}
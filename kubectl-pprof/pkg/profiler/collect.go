@@ -0,0 +1,112 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// startDetached creates the profiling Job of a --no-collect session and
+// returns without waiting for it, the Job holds what Collect needs
+func (p *Profiler) startDetached(ctx context.Context, mode api.ProfileMode, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, meta *api.SessionMetadata) (*api.ProfileResult, error) {
+	if mode != api.ModeJob {
+		return nil, fmt.Errorf("--no-collect leaves a profiling Job running and cannot be used in %s mode", mode)
+	}
+	result, err := p.jobManager.StartProfilingJob(ctx, cfg, opts, target, meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start profiling job: %w", err)
+	}
+	opts.Log().Info("Profiling job started, collect its results later", "job", result.JobName, "namespace", cfg.GetJobNamespace())
+	return result, nil
+}
+
+// Collect waits for a Job started by a --no-collect session to finish and
+// collects and renders its results as the session would have. The config and
+// options of the session are recorded on the Job, except where the results
+// go: outputPath names the output, DefaultOutputPath when empty, overwrite
+// allows replacing it, and opts supplies the Logger, Progress, Quiet and
+// session store of the collection.
+func (p *Profiler) Collect(ctx context.Context, jobName, namespace, outputPath string, overwrite bool, opts *api.ProfileOptions) (*api.ProfileResult, error) {
+	opts = p.sessionOptions(opts)
+	session, jobResult, err := p.jobManager.CollectProfilingJob(ctx, jobName, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *session.Config
+	cfg.NoCollect = false
+	if err := localOutputs(&cfg, session.Options, outputPath, overwrite); err != nil {
+		return nil, fmt.Errorf("invalid session on job %s/%s: %w", namespace, jobName, err)
+	}
+	collectOpts := session.Options
+	collectOpts.Logger = opts.Logger
+	collectOpts.Progress = opts.Progress
+	collectOpts.Quiet = opts.Quiet
+	collectOpts.SessionsDir = opts.SessionsDir
+
+	jobResult.Metadata = session.Metadata
+	if target := jobResult.SampleTarget; target != nil {
+		jobResult.Duration = target.Elapsed
+		jobResult.Samples = target.Samples
+		collectOpts.Log().Info("Sampling stopped", "samples", target.Samples, "minSamples", target.MinSamples, "elapsed", target.Elapsed.Round(time.Second), "reached", target.Reached)
+	}
+	if stats := jobResult.SampleStats; stats != nil {
+		jobResult.Samples = stats.Samples
+		collectOpts.Log().Info("Samples collected", "samples", stats.Samples, "dropped", stats.Dropped, "lostStacks", stats.LostStacks,
+			"truncated", stats.Truncated, "frames", stats.Frames, "unsymbolized", stats.Unsymbolized)
+	}
+
+	result, err := p.collectResults(ctx, &cfg, collectOpts, jobResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect results: %w", err)
+	}
+	result.Config = &cfg
+
+	if cfg.RetainJob(result.Success) {
+		// gc keeps a --no-collect Job until it is collected
+		if err := p.jobManager.MarkCollected(ctx, result.JobName, cfg.GetJobNamespace()); err != nil {
+			collectOpts.Log().Warn("Failed to mark job collected", "error", err)
+		}
+	} else if err := p.cleanup(ctx, result.JobName, cfg.GetJobNamespace()); err != nil {
+		collectOpts.Log().Warn("Failed to cleanup resources", "error", err)
+	}
+	return result, nil
+}
+
+// localOutputs replaces the local files the session recorded on a Job would
+// write: whoever may edit the Job may edit the session, so none of its paths
+// are used. The output is the one of the collection, the exports are written
+// next to it under their own names, and the formats naming them must be
+// known ones.
+func localOutputs(cfg *api.ProfileConfig, opts *api.ProfileOptions, outputPath string, overwrite bool) error {
+	switch opts.OutputFormat {
+	case "", "svg", "png", "pdf", "html", "json":
+	default:
+		return fmt.Errorf("unknown output format %q", opts.OutputFormat)
+	}
+	for _, format := range cfg.AlsoFormats {
+		if !slices.Contains(flamegraph.ConvertFormats, format) {
+			return fmt.Errorf("unknown conversion format %q", format)
+		}
+	}
+
+	if outputPath == "" {
+		outputPath = DefaultOutputPath(opts.OutputFormat)
+	}
+	cfg.OutputPath = outputPath
+	cfg.Overwrite = overwrite
+	if cfg.ExportSummary != "" {
+		cfg.ExportSummary = filepath.Base(cfg.ExportSummary)
+	}
+	if cfg.GoOptions != nil && cfg.GoOptions.ExportFolded != "" {
+		goOpts := *cfg.GoOptions
+		goOpts.ExportFolded = filepath.Base(goOpts.ExportFolded)
+		cfg.GoOptions = &goOpts
+	}
+	return nil
+}
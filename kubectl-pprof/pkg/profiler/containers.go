@@ -0,0 +1,208 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// ProfileAllContainers profiles every container of the target pod with one Job
+// per container on the pod's node. Each container gets its own artifacts named
+// after it; with MergeContainers the stacks are also rendered as one graph
+// rooted at a frame per container.
+func (p *Profiler) ProfileAllContainers(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions) (*api.MultiProfileResult, error) {
+	opts = p.sessionOptions(opts)
+
+	pod, err := p.discovery.FindPod(ctx, cfg.Namespace, cfg.PodName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pod: %w", err)
+	}
+
+	containers := make([]string, 0, len(pod.Spec.Containers))
+	for _, container := range pod.Spec.Containers {
+		containers = append(containers, container.Name)
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("no containers found in pod %s/%s", cfg.Namespace, cfg.PodName)
+	}
+
+	results := make([]*api.ProfileResult, len(containers))
+	errs := make([]error, len(containers))
+
+	// Parallel Jobs sample the node together on purpose, they share its Lease
+	var leaseHolder string
+	if cfg.ParallelContainers {
+		leaseHolder = job.GenerateJobName(cfg, cfg.PodName)
+	}
+
+	run := func(i int) {
+		ccfg := containerConfig(cfg, containers[i])
+		if leaseHolder != "" {
+			ccfg.LeaseHolder = leaseHolder
+		}
+		results[i], errs[i] = p.Profile(ctx, ccfg, opts)
+		if errs[i] == nil {
+			results[i].Config = ccfg
+		}
+	}
+
+	if cfg.ParallelContainers {
+		var wg sync.WaitGroup
+		for i := range containers {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range containers {
+			run(i)
+		}
+	}
+
+	multi := &api.MultiProfileResult{}
+	for i, name := range containers {
+		if errs[i] != nil {
+			if multi.Failures == nil {
+				multi.Failures = make(map[string]string)
+			}
+			multi.Failures[name] = errs[i].Error()
+			continue
+		}
+		multi.Results = append(multi.Results, results[i])
+	}
+	if len(multi.Results) == 0 {
+		return multi, fmt.Errorf("profiling failed for all %d containers", len(containers))
+	}
+
+	if cfg.MergeContainers {
+		frame := func(result *api.ProfileResult) string { return result.Config.ContainerName }
+		subtitle := fmt.Sprintf("%s/%s, all containers", cfg.Namespace, cfg.PodName)
+		mergedPath, err := p.mergeProfiles(cfg, opts, multi.Results, frame, subtitle)
+		if err != nil {
+			return multi, err
+		}
+		multi.MergedPath = mergedPath
+	}
+
+	return multi, nil
+}
+
+// containerConfig derives the configuration of a single container run. Output
+// files and the Job name carry the container name so that runs never collide.
+func containerConfig(cfg *api.ProfileConfig, container string) *api.ProfileConfig {
+	ccfg := *cfg
+	ccfg.AllContainers = false
+	ccfg.ContainerName = container
+	ccfg.OutputPath = containerPath(cfg.OutputPath, container)
+	ccfg.JobName = job.JobNamePrefix(cfg) + "-" + container
+	if cfg.ExportSummary != "" {
+		ccfg.ExportSummary = containerPath(cfg.ExportSummary, container)
+	}
+
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	if goOpts.ExportFolded != "" {
+		goOpts.ExportFolded = containerPath(goOpts.ExportFolded, container)
+	} else if cfg.MergeContainers {
+		// The merged graph is built from the folded stacks of every container
+		goOpts.ExportFolded = filepath.Base(strings.TrimSuffix(ccfg.OutputPath, filepath.Ext(ccfg.OutputPath))) + ".folded"
+	}
+	ccfg.GoOptions = &goOpts
+
+	return &ccfg
+}
+
+// addFolded streams the stacks of a folded file into the aggregator, rooted at frame
+func addFolded(aggregator *stacks.Aggregator, path, frame string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open folded stacks: %w", err)
+	}
+	defer f.Close()
+
+	decoder := stacks.NewDecoder(f)
+	for {
+		sample, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		sample.Stack = append([]string{frame}, sample.Stack...)
+		if err := aggregator.Add(sample); err != nil {
+			return err
+		}
+	}
+}
+
+// containerPath inserts the container name before the file extension
+func containerPath(path, container string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + container + ext
+}
+
+// mergeProfiles renders the folded stacks of several runs as one graph, each
+// run's stacks rooted at the frame returned by frameOf
+func (p *Profiler) mergeProfiles(cfg *api.ProfileConfig, opts *api.ProfileOptions, results []*api.ProfileResult, frameOf func(*api.ProfileResult) string, subtitle string) (string, error) {
+	// Identical stacks are merged while reading, the runs of a batch can be large
+	aggregator := &stacks.Aggregator{}
+	defer aggregator.Close()
+	for _, result := range results {
+		if result.FoldedPath == "" {
+			continue
+		}
+		if err := addFolded(aggregator, result.FoldedPath, frameOf(result)); err != nil {
+			return "", err
+		}
+	}
+	merged, err := aggregator.Profile()
+	if err != nil {
+		return "", fmt.Errorf("failed to merge folded stacks: %w", err)
+	}
+
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	// Time order is per run, the merged graph is always a flame graph
+	goOpts.FlameChart = false
+	if goOpts.Subtitle == "" {
+		goOpts.Subtitle = subtitle
+	}
+
+	renderOpts := renderOptions(&goOpts)
+	if title, traced := tracedTitles[cfg.ProfileType]; traced {
+		if goOpts.Title == "" {
+			renderOpts.Title = title
+		}
+		renderOpts.CountName = "µs"
+	}
+
+	data, err := renderProfile(merged, renderOpts, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to render merged graph: %w", err)
+	}
+
+	mergedPath, err := writeLocalFile(containerPath(cfg.OutputPath, "merged"), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to save merged graph: %w", err)
+	}
+
+	artifactSaved(opts, "Merged flamegraph", mergedPath)
+	return mergedPath, nil
+}
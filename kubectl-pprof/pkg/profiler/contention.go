@@ -0,0 +1,161 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// contentionTitles are the flame graph titles of the contention profiles
+var contentionTitles = map[string]string{
+	"block": "Golang Block Contention",
+	"mutex": "Golang Mutex Contention",
+}
+
+// contentionFetch the raw block or mutex profile of the profiling window
+type contentionFetch struct {
+	name string
+	data []byte
+	err  error
+}
+
+// startContention starts fetching the requested block and mutex profiles from
+// the pprof endpoint of the target, each over the profiling duration, so they
+// cover about the same window as the CPU profile. The returned function waits
+// for the downloads; it is nil when no contention profile was requested.
+func (p *Profiler) startContention(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) func() []contentionFetch {
+	var names []string
+	if opts.BlockProfile {
+		names = append(names, "block")
+	}
+	if opts.MutexProfile {
+		names = append(names, "mutex")
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	fetches := make([]contentionFetch, len(names))
+	ep, err := detectEndpoint(cfg, target)
+	if err != nil {
+		for i, name := range names {
+			fetches[i] = contentionFetch{name: name, err: fmt.Errorf("contention profiles are read from the pprof endpoint: %w", err)}
+		}
+		return func() []contentionFetch { return fetches }
+	}
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetches[i] = contentionFetch{name: name}
+			fetches[i].data, fetches[i].err = p.fetchContention(ctx, cfg, ep, target, name)
+		}()
+	}
+	return func() []contentionFetch {
+		wg.Wait()
+		return fetches
+	}
+}
+
+// fetchContention downloads one windowed contention profile
+func (p *Profiler) fetchContention(ctx context.Context, cfg *api.ProfileConfig, ep *endpoint.Endpoint, target *api.TargetInfo, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout(cfg))
+	defer cancel()
+
+	localPort, stop, err := endpoint.Forward(ctx, p.k8sConfig.Config, p.k8sConfig.Clientset, target.Namespace, target.PodName, ep.Port)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	return endpoint.Fetch(ctx, ep.URL(localPort, name, cfg.Duration))
+}
+
+// attachContention renders the fetched contention profiles next to the main
+// output. Failures only cost the contention graph, never the profile.
+func (p *Profiler) attachContention(wait func() []contentionFetch, cfg *api.ProfileConfig, opts *api.ProfileOptions, result *api.ProfileResult) {
+	if wait == nil {
+		return
+	}
+	for _, fetch := range wait() {
+		report, err := p.renderContention(fetch, cfg, opts, result.Metadata)
+		if err != nil {
+			opts.Log().Warn("Failed to collect profile", "profile", fetch.name, "error", err)
+			continue
+		}
+		result.Contention = append(result.Contention, *report)
+	}
+}
+
+// renderContention saves a contention profile and renders it as a flame graph
+// weighted by the time goroutines spent waiting
+func (p *Profiler) renderContention(fetch contentionFetch, cfg *api.ProfileConfig, opts *api.ProfileOptions, meta *api.SessionMetadata) (*api.ContentionReport, error) {
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	delay, unit, err := flamegraph.ParsePprof(bytes.NewReader(fetch.data), "delay")
+	if err != nil {
+		return nil, err
+	}
+	contentions, _, err := flamegraph.ParsePprof(bytes.NewReader(fetch.data), "contentions")
+	if err != nil {
+		return nil, err
+	}
+	if len(delay.Samples) == 0 {
+		// The runtime records nothing until the application opts in
+		setting := "runtime.SetBlockProfileRate(1)"
+		if fetch.name == "mutex" {
+			setting = "runtime.SetMutexProfileFraction(5)"
+		}
+		return nil, errors.NewValidationError(
+			fmt.Sprintf("the %s profile is empty", fetch.name),
+			fmt.Sprintf("The Go runtime only records %s contention after the application calls %s", fetch.name, setting),
+			"Profile for longer if contention is rare",
+		)
+	}
+
+	base := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath)) + "." + fetch.name
+	report := &api.ContentionReport{
+		Profile:     fetch.name,
+		Contentions: contentions.Total(),
+		Delay:       time.Duration(delay.Total()),
+	}
+	if report.PprofPath, err = writeLocalFile(base+".pprof", fetch.data); err != nil {
+		return nil, fmt.Errorf("failed to save %s profile: %w", fetch.name, err)
+	}
+
+	runCfg := cfg
+	if meta != nil {
+		runCfg = withSessionSubtitle(cfg, meta)
+	}
+	renderOpts := renderOptions(&api.GoProfilingOptions{})
+	if runCfg.GoOptions != nil {
+		renderOpts = renderOptions(runCfg.GoOptions)
+	}
+	// The user's title names the main graph, and pprof has no sample order
+	renderOpts.Title = contentionTitles[fetch.name]
+	renderOpts.FlameChart = false
+	renderOpts.CountName = unit
+	graph, err := renderProfile(delay, renderOpts, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s flame graph: %w", fetch.name, err)
+	}
+	if meta != nil {
+		graph = embedSVGMetadata(graph, meta)
+	}
+	if report.OutputPath, err = writeLocalFile(base+filepath.Ext(cfg.OutputPath), graph); err != nil {
+		return nil, fmt.Errorf("failed to save %s flame graph: %w", fetch.name, err)
+	}
+	artifactSaved(opts, contentionTitles[fetch.name]+" flame graph", report.OutputPath)
+	return report, nil
+}
@@ -0,0 +1,54 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+// collectConversions converts the stacks of the job into the --also-format
+// formats concurrently and saves them next to the output file. The time of
+// every stage is logged at verbosity 1.
+func (p *Profiler) collectConversions(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, result *api.ProfileResult) (map[string]string, error) {
+	if cfg.OutputPath == "" {
+		return nil, nil
+	}
+	log := opts.Log()
+
+	start := time.Now()
+	profile, err := p.jobProfile(ctx, cfg, result.JobName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stacks for --also-format: %w", err)
+	}
+	log.Log(ctx, logging.V(1), "Fetched stacks for conversion", "stacks", len(profile.Samples), "duration", time.Since(start).Round(time.Millisecond))
+
+	renderOpts := localRenderOptions(cfg, opts, result.Metadata, profile)
+	renderOpts.DPI = opts.DPI
+
+	start = time.Now()
+	conversions := flamegraph.ConvertAll(profile, cfg.AlsoFormats, renderOpts, 0)
+	log.Log(ctx, logging.V(1), "Converted stacks", "formats", len(conversions), "duration", time.Since(start).Round(time.Millisecond))
+
+	base := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath))
+	paths := make(map[string]string, len(conversions))
+	for _, c := range conversions {
+		if c.Err != nil {
+			return nil, fmt.Errorf("failed to convert stacks to %s: %w", c.Format, c.Err)
+		}
+		log.Log(ctx, logging.V(1), "Conversion finished", "format", c.Format, "bytes", len(c.Data), "duration", c.Duration.Round(time.Millisecond))
+
+		path, err := writeLocalFile(base+flamegraph.Extension(c.Format), c.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save %s conversion: %w", c.Format, err)
+		}
+		artifactSaved(opts, "Converted "+c.Format, path)
+		paths[c.Format] = path
+	}
+	return paths, nil
+}
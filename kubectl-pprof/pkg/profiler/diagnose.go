@@ -0,0 +1,67 @@
+package profiler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// sparseSamples is the on-CPU sample count under which a CPU profile is too
+// thin to read and gets diagnosed
+const sparseSamples = 100
+
+// idleShare is the share of one busy core under which the target counts as
+// mostly idle while it was sampled
+const idleShare = 0.05
+
+// EmptyProfileError reports a CPU profile without a single on-CPU sample. Its
+// flame graph would be blank, so none is saved.
+type EmptyProfileError struct {
+	Diagnosis *api.SampleDiagnosis
+}
+
+func (e *EmptyProfileError) Error() string {
+	return "no on-CPU samples were captured, the flame graph would be blank; possible causes:\n  - " + strings.Join(e.Diagnosis.Causes, "\n  - ")
+}
+
+// diagnoseSamples explains a CPU profile with fewer than sparseSamples
+// on-CPU samples from the sampling window and the session settings, and
+// returns nil for profiles with enough samples or without statistics
+func diagnoseSamples(cfg *api.ProfileConfig, result *api.ProfileResult) *api.SampleDiagnosis {
+	stats := result.SampleStats
+	if stats == nil || cfg.ProfileType != api.ProfileTypeCPU || stats.Samples >= sparseSamples {
+		return nil
+	}
+
+	window := cfg.Duration
+	if result.Duration > 0 {
+		window = result.Duration
+	}
+	frequency := cfg.SamplingFrequency()
+	diagnosis := &api.SampleDiagnosis{
+		Samples:  stats.Samples,
+		Expected: int64(float64(frequency) * window.Seconds()),
+	}
+	cause := func(format string, args ...interface{}) {
+		diagnosis.Causes = append(diagnosis.Causes, fmt.Sprintf(format, args...))
+	}
+
+	if diagnosis.Expected < sparseSamples {
+		cause("the frequency is too low for the window: at %d Hz over %v a fully busy thread yields only about %d samples; raise --frequency or --duration",
+			frequency, window.Round(time.Second), diagnosis.Expected)
+	} else if float64(stats.Samples) < idleShare*float64(diagnosis.Expected) {
+		cause("the process was mostly idle: it used under %.0f%% of one core (%d samples of about %d per busy thread); if it is slow rather than busy, profile where it waits with --off-cpu, --profile-type schedlat or --profile-type net",
+			100*idleShare, stats.Samples, diagnosis.Expected)
+	}
+	if cfg.PID != "" {
+		cause("--pid %s may not be the Go process: check it is not a shell or init wrapper of the container", cfg.PID)
+	} else if !cfg.IncludeChildren {
+		cause("the container entrypoint may be a shell or init wrapper that runs the Go program as a child: retry with --include-children or name the process with --pid")
+	}
+	if cfg.CgroupOnly {
+		cause("--cgroup-only drops samples of tasks outside the target container's cgroup: if the process runs in another cgroup, retry with --cgroup-only=false")
+	}
+	return diagnosis
+}
@@ -0,0 +1,21 @@
+// Package profiler runs profiling sessions against pods: it resolves the
+// target, picks how to reach it, runs the profiler and collects the artifacts.
+//
+// Sessions report through the Logger and Progress of their options and never
+// print, so other programs can embed them behind their own UI:
+//
+//	k8sConfig, err := config.LoadKubernetesConfig()
+//	...
+//	p, err := profiler.NewProfiler(k8sConfig,
+//		profiler.WithLogger(logger),
+//		profiler.WithProgress(func(event api.ProgressEvent) { ... }),
+//	)
+//	...
+//	result, err := p.Profile(ctx, api.NewProfileConfig("default", "my-go-app"), nil)
+//
+// Targets are found through a TargetDiscovery, the profiler runs through a
+// JobRunner and artifacts come back through a ResultTransport. The options
+// WithDiscovery, WithJobRunner and WithTransport replace them; package fake
+// holds in-memory implementations and a harness running whole sessions
+// against a fake clientset.
+package profiler
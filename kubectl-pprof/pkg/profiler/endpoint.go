@@ -0,0 +1,218 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/analysis"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// pprofCPUFrequency is the sampling rate of the Go runtime CPU profiler
+const pprofCPUFrequency = 100
+
+// detectEndpoint finds the pprof handler of the target container
+func detectEndpoint(cfg *api.ProfileConfig, target *api.TargetInfo) (*endpoint.Endpoint, error) {
+	pod, ok := target.Pod.(*corev1.Pod)
+	if !ok || pod == nil {
+		return nil, fmt.Errorf("target pod is unknown")
+	}
+	container, ok := target.Container.(*corev1.Container)
+	if !ok || container == nil {
+		return nil, fmt.Errorf("target container is unknown")
+	}
+	return endpoint.Detect(pod, container, cfg.PprofPort, cfg.PprofPath)
+}
+
+// profileEndpoint fetches a profile from the net/http/pprof handler of the
+// target through a port-forward and saves it next to a flame graph rendered
+// the same way as the eBPF profiles
+func (p *Profiler) profileEndpoint(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	ep, err := detectEndpoint(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+
+	profileName := cfg.PprofProfile
+	if profileName == "" {
+		profileName = "profile"
+	}
+	opts.Log().Info("Using pprof endpoint", "port", ep.Port, "path", ep.Path, "reason", ep.Reason)
+
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout(cfg))
+	defer cancel()
+
+	localPort, stop, err := endpoint.Forward(ctx, p.k8sConfig.Config, p.k8sConfig.Clientset, target.Namespace, target.PodName, ep.Port)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	var runtimeStart *api.RuntimeSnapshot
+	if cfg.RuntimeMetrics {
+		if runtimeStart, err = ep.RuntimeSnapshot(ctx, localPort); err != nil {
+			opts.Log().Warn("Failed to read runtime metrics", "error", err)
+		}
+	}
+
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingStarted, Namespace: target.Namespace, PodName: target.PodName})
+	data, err := endpoint.Fetch(ctx, ep.URL(localPort, profileName, cfg.Duration))
+	if err != nil {
+		return nil, err
+	}
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingFinished, Namespace: target.Namespace, PodName: target.PodName})
+
+	var runtimeReport *api.RuntimeMetricsReport
+	if runtimeStart != nil {
+		if runtimeEnd, err := ep.RuntimeSnapshot(ctx, localPort); err == nil {
+			runtimeReport = endpoint.RuntimeReport(runtimeStart, runtimeEnd)
+		}
+	}
+
+	parse := flamegraph.ParsePprof
+	if cfg.GetGranularity() == api.GranularityLines {
+		parse = flamegraph.ParsePprofLines
+	}
+	profile, unit, err := parse(bytes.NewReader(data), "")
+	if err != nil {
+		return nil, err
+	}
+	// Only the CPU and goroutine profiles of the Go runtime carry labels
+	if profile, err = filterTags(profile, cfg.TagFilters); err != nil {
+		return nil, err
+	}
+	traces := traceReport(profile, cfg.GetTraceLabels())
+	if cfg.Traces && traces == nil {
+		opts.Log().Warn("No sample carries a trace ID label", "labels", strings.Join(cfg.GetTraceLabels(), ", "))
+	}
+	if cfg.GetAnnotate() == api.AnnotateLabels {
+		profile = flamegraph.RootAtLabels(profile)
+	}
+
+	meta := newSessionMetadata(cfg, target)
+	meta.Frequency = 0
+	if profileName == "profile" {
+		meta.Frequency = pprofCPUFrequency
+	}
+	meta.Runtime = runtimeReport
+	runCfg := withSessionSubtitle(cfg, meta)
+
+	result := &api.ProfileResult{
+		Config:   cfg,
+		Duration: cfg.Duration,
+		Success:  true,
+		Metadata: meta,
+		Traces:   traces,
+		JobStatus: &api.JobStatus{
+			Namespace: target.Namespace,
+			PodName:   target.PodName,
+			Phase:     api.JobPhaseSucceeded,
+		},
+	}
+
+	// Keep the raw profile, it holds more than the flame graph shows
+	base := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath))
+	if result.PprofPath, err = writeLocalFile(base+".pprof", data); err != nil {
+		return nil, fmt.Errorf("failed to save pprof profile: %w", err)
+	}
+	artifactSaved(opts, "pprof profile", result.PprofPath)
+
+	renderOpts := renderOptions(runCfg.GoOptions)
+	renderOpts.CountName = unit
+	renderOpts.Facts = runtimeFacts(runtimeReport)
+	renderOpts.SourceURL = sourceURL(cfg, opts, meta)
+	if cfg.Analyze {
+		result.Findings = analysis.Analyze(profile, analysis.DefaultRules())
+		renderOpts.Findings = HTMLFindings(result.Findings)
+	}
+	if runCfg.GoOptions.Title == "" && profileName != "profile" {
+		renderOpts.Title = fmt.Sprintf("Golang %s Profile", profileName)
+	}
+	graph, err := renderProfile(profile, renderOpts, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render flame graph: %w", err)
+	}
+	graph = embedSVGMetadata(graph, meta)
+	if err := p.saveOutputFile(cfg, opts, graph); err != nil {
+		return nil, fmt.Errorf("failed to save output file: %w", err)
+	}
+	result.OutputPath = cfg.OutputPath
+	result.FileSize = int64(len(graph))
+
+	if cfg.List != "" {
+		if result.Listing, err = listSource(profile, cfg.List, unit); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ExportSummary != "" {
+		if result.SummaryPath, err = writeSummary(cfg, opts, profile, unit, result); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.GoOptions != nil && cfg.GoOptions.ExportFolded != "" {
+		var folded bytes.Buffer
+		if err := flamegraph.WriteFolded(&folded, profile); err != nil {
+			return nil, fmt.Errorf("failed to export folded stacks: %w", err)
+		}
+		foldedPath := cfg.GoOptions.ExportFolded
+		if !filepath.IsAbs(foldedPath) {
+			foldedPath = filepath.Join(filepath.Dir(cfg.OutputPath), foldedPath)
+		}
+		if result.FoldedPath, err = writeLocalFile(foldedPath, folded.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to save folded stacks: %w", err)
+		}
+		artifactSaved(opts, "Folded stacks", result.FoldedPath)
+	}
+
+	return result, nil
+}
+
+// filterTags keeps the samples matching every "key=regex" tag filter and
+// explains an empty result with the labels the profile has
+func filterTags(profile *flamegraph.Profile, filters []string) (*flamegraph.Profile, error) {
+	if len(filters) == 0 {
+		return profile, nil
+	}
+	parsed := make([]flamegraph.TagFilter, 0, len(filters))
+	for _, filter := range filters {
+		f, err := flamegraph.ParseTagFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, f)
+	}
+	filtered := flamegraph.FilterTags(profile, parsed)
+	if len(filtered.Samples) > 0 {
+		return filtered, nil
+	}
+
+	var keys []string
+	for _, tag := range flamegraph.Tags(profile) {
+		keys = append(keys, tag.Key)
+	}
+	hint := "The profile has no labels, set them with pprof.Do or pprof.SetGoroutineLabels"
+	if len(keys) > 0 {
+		hint = fmt.Sprintf("The profile has the labels: %s", strings.Join(keys, ", "))
+	}
+	return nil, errors.NewValidationError(
+		fmt.Sprintf("no sample matches --tag-filter %s", strings.Join(filters, " --tag-filter ")),
+		hint,
+	)
+}
+
+// endpointTimeout bounds a pprof fetch: the profiling window plus time to
+// transfer the profile
+func endpointTimeout(cfg *api.ProfileConfig) time.Duration {
+	return cfg.Duration + time.Minute
+}
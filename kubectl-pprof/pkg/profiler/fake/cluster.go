@@ -0,0 +1,203 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+)
+
+var _ job.PodLogsFunc = (*Cluster)(nil).PodLogs
+
+// Cluster stands in for the Job controller and the kubelet behind a fake
+// clientset: every Job created is given a pod that already finished, whose
+// profiler container printed scripted logs. The real job manager can then
+// create, monitor and read Jobs as it would on a cluster.
+type Cluster struct {
+	// Phase is the phase Jobs finish in, succeeded when empty. Jobs left
+	// running never finish, the session ends at its deadline or when aborted.
+	Phase api.JobPhase
+	// Reject is the message of an admission webhook refusing every Job
+	// created, none are refused when empty
+	Reject string
+	// StartFailure leaves the pods of Jobs pending, their profiler container
+	// waiting with this reason, e.g. ImagePullBackOff. They start when nil.
+	StartFailure *corev1.ContainerStateWaiting
+
+	mu   sync.Mutex
+	logs *ProfilerLogs
+	// Logs scripted for a single Job, by Job name
+	jobLogs map[string]*ProfilerLogs
+	// Pods created for the Jobs, by namespace/name
+	pods map[string]string
+	// Jobs are the Jobs created, as the job manager built them
+	Jobs []*batchv1.Job
+}
+
+// NewCluster returns a Cluster printing SampleLogs
+func NewCluster() *Cluster {
+	return &Cluster{
+		logs:    SampleLogs(),
+		jobLogs: make(map[string]*ProfilerLogs),
+		pods:    make(map[string]string),
+	}
+}
+
+// SetLogs scripts the logs of every Job
+func (c *Cluster) SetLogs(logs *ProfilerLogs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logs = logs
+}
+
+// SetLogsForJob scripts the logs of a single Job
+func (c *Cluster) SetLogsForJob(jobName string, logs *ProfilerLogs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jobLogs[jobName] = logs
+}
+
+// install makes the clientset run the Jobs created through it, and delete
+// their pods with them like the garbage collector
+func (c *Cluster) install(clientset *k8sfake.Clientset) {
+	tracker := clientset.Tracker()
+	clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		created := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job).DeepCopy()
+		created.Namespace = action.GetNamespace()
+		if c.Reject != "" {
+			return true, nil, apierrors.NewForbidden(batchv1.Resource("jobs"), created.Name,
+				fmt.Errorf("admission webhook %q denied the request: %s", "fake.kubectl-pprof.io", c.Reject))
+		}
+
+		c.mu.Lock()
+		c.Jobs = append(c.Jobs, created.DeepCopy())
+		c.mu.Unlock()
+
+		finished := created.DeepCopy()
+		podPhase := c.finish(finished)
+		if err := tracker.Create(batchv1.SchemeGroupVersion.WithResource("jobs"), finished, finished.Namespace); err != nil {
+			return true, nil, err
+		}
+		pod := jobPod(finished, podPhase, c.StartFailure)
+		if err := tracker.Create(corev1.SchemeGroupVersion.WithResource("pods"), pod, pod.Namespace); err != nil {
+			return true, nil, err
+		}
+
+		c.mu.Lock()
+		c.pods[pod.Namespace+"/"+pod.Name] = finished.Name
+		c.mu.Unlock()
+		return true, finished, nil
+	})
+	// The tracker deletes the Job itself once its pods are gone
+	clientset.PrependReactor("delete", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		namespace, jobName := action.GetNamespace(), action.(k8stesting.DeleteAction).GetName()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for key, owner := range c.pods {
+			podNamespace, podName, _ := strings.Cut(key, "/")
+			if owner != jobName || podNamespace != namespace {
+				continue
+			}
+			delete(c.pods, key)
+			if err := tracker.Delete(corev1.SchemeGroupVersion.WithResource("pods"), podNamespace, podName); err != nil && !apierrors.IsNotFound(err) {
+				return true, nil, err
+			}
+		}
+		return false, nil, nil
+	})
+}
+
+// finish sets the status of a Job that ran to Phase and returns the phase
+// of its pod
+func (c *Cluster) finish(j *batchv1.Job) corev1.PodPhase {
+	now := metav1.Now()
+	j.Status.StartTime = &now
+	if c.StartFailure != nil {
+		j.Status.Active = 1
+		return corev1.PodPending
+	}
+	// The conditions the Job controller sets, which an API server requires
+	// of a finished Job
+	condition := func(conditionType batchv1.JobConditionType) batchv1.JobCondition {
+		return batchv1.JobCondition{Type: conditionType, Status: corev1.ConditionTrue, LastProbeTime: now, LastTransitionTime: now}
+	}
+	if c.Phase == api.JobPhaseFailed {
+		j.Status.Failed = 1
+		j.Status.Conditions = append(j.Status.Conditions, condition(batchv1.JobFailureTarget), condition(batchv1.JobFailed))
+		return corev1.PodFailed
+	}
+	if c.Phase == "" || c.Phase == api.JobPhaseSucceeded {
+		j.Status.Succeeded = 1
+		j.Status.CompletionTime = &now
+		j.Status.Conditions = append(j.Status.Conditions, condition(batchv1.JobSuccessCriteriaMet), condition(batchv1.JobComplete))
+		return corev1.PodSucceeded
+	}
+	j.Status.Active = 1
+	return corev1.PodRunning
+}
+
+// jobPod returns the pod the Job controller would have created for a Job,
+// its containers waiting when the pod cannot start
+func jobPod(j *batchv1.Job, phase corev1.PodPhase, waiting *corev1.ContainerStateWaiting) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      j.Name + "-pod",
+			Namespace: j.Namespace,
+			Labels:    map[string]string{"job-name": j.Name},
+		},
+		Spec:   *j.Spec.Template.Spec.DeepCopy(),
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	for k, v := range j.Spec.Template.Labels {
+		if _, ok := pod.Labels[k]; !ok {
+			pod.Labels[k] = v
+		}
+	}
+	// Scheduled on the node the template pins
+	if pod.Spec.NodeName == "" {
+		pod.Spec.NodeName = j.Spec.Template.Spec.NodeSelector["kubernetes.io/hostname"]
+	}
+	if waiting != nil {
+		for _, container := range pod.Spec.Containers {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name:  container.Name,
+				Image: container.Image,
+				State: corev1.ContainerState{Waiting: waiting.DeepCopy()},
+			})
+		}
+	}
+	return pod
+}
+
+// PodLogs serves the scripted logs of the profiler container of a Job's pod,
+// for job.WithPodLogs. The pods of deleted Jobs have no logs.
+func (c *Cluster) PodLogs(ctx context.Context, namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	jobName, ok := c.pods[namespace+"/"+podName]
+	if !ok {
+		return nil, fmt.Errorf("pods %q not found", podName)
+	}
+	logs := c.logs
+	if scripted, ok := c.jobLogs[jobName]; ok {
+		logs = scripted
+	}
+	if logs == nil {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return io.NopCloser(strings.NewReader(logs.String())), nil
+}
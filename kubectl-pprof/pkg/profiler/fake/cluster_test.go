@@ -0,0 +1,188 @@
+package fake_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/profiler/fake"
+)
+
+// newClusterHarness returns a harness with a Go pod on node-1
+func newClusterHarness(t *testing.T) *fake.Harness {
+	t.Helper()
+	h, err := fake.NewClusterHarness(fake.Node("node-1"), fake.Pod("default", "my-go-app", "node-1", "app"))
+	if err != nil {
+		t.Fatalf("NewClusterHarness: %v", err)
+	}
+	return h
+}
+
+// remainingJobs lists the names of the Jobs left in the cluster
+func remainingJobs(t *testing.T, h *fake.Harness) []string {
+	t.Helper()
+	jobs, err := h.Clientset.BatchV1().Jobs(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	var names []string
+	for _, j := range jobs.Items {
+		names = append(names, j.Name)
+	}
+	return names
+}
+
+func TestClusterJobSucceeds(t *testing.T) {
+	h := newClusterHarness(t)
+	cfg := fake.JobConfig("default", "my-go-app", t.TempDir())
+
+	result, err := h.Profiler.Profile(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Success = false, want true")
+	}
+	if len(h.Cluster.Jobs) != 1 {
+		t.Fatalf("created %d jobs, want 1", len(h.Cluster.Jobs))
+	}
+	spec := h.Cluster.Jobs[0].Spec.Template.Spec
+	if !spec.HostPID || spec.NodeSelector["kubernetes.io/hostname"] != "node-1" {
+		t.Errorf("job does not run in the host PID namespace of node-1: hostPID %v, node selector %v", spec.HostPID, spec.NodeSelector)
+	}
+
+	data, err := os.ReadFile(cfg.OutputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(data), "main.main") {
+		t.Errorf("output does not hold the flame graph of the logs:\n%s", data)
+	}
+}
+
+func TestClusterDetectsLanguage(t *testing.T) {
+	h := newClusterHarness(t)
+	h.Cluster.SetLogs(fake.SampleLogs().Line("LANGUAGE_DETECTED:go buildinfo of /app/server"))
+
+	cfg := fake.JobConfig("default", "my-go-app", t.TempDir())
+	result, err := h.Profiler.Profile(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if result.Language != string(api.LanguageGo) || result.Detection != "buildinfo of /app/server" {
+		t.Errorf("detected %q from %q, want go from the build info", result.Language, result.Detection)
+	}
+	if cfg.Language != string(api.LanguageAuto) {
+		t.Errorf("Profile changed the language of the config to %q", cfg.Language)
+	}
+}
+
+func TestClusterJobFails(t *testing.T) {
+	h := newClusterHarness(t)
+	h.Cluster.Phase = api.JobPhaseFailed
+	h.Cluster.SetLogs(fake.NewProfilerLogs().Line("Error: failed to attach to the target process"))
+
+	cfg := fake.JobConfig("default", "my-go-app", t.TempDir())
+	result, err := h.Profiler.Profile(context.Background(), cfg, nil)
+	if err == nil {
+		t.Fatalf("Profile succeeded with a failed job: %+v", result)
+	}
+	if _, statErr := os.Stat(cfg.OutputPath); !errors.Is(statErr, os.ErrNotExist) {
+		t.Errorf("failed job left an output file: %v", statErr)
+	}
+}
+
+func TestClusterAdmissionRejected(t *testing.T) {
+	h := newClusterHarness(t)
+	h.Cluster.Reject = "privileged pods are not allowed in default"
+
+	_, err := h.Profiler.Profile(context.Background(), fake.JobConfig("default", "my-go-app", t.TempDir()), nil)
+	var rejected *job.AdmissionRejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("Profile error = %v, want an AdmissionRejectedError", err)
+	}
+	if !strings.Contains(rejected.Message, "privileged pods are not allowed") {
+		t.Errorf("rejection message %q does not carry the webhook message", rejected.Message)
+	}
+	if jobs := remainingJobs(t, h); len(jobs) != 0 {
+		t.Errorf("rejected session left jobs %v", jobs)
+	}
+}
+
+func TestClusterPodStartFailure(t *testing.T) {
+	h := newClusterHarness(t)
+	h.Cluster.StartFailure = &corev1.ContainerStateWaiting{
+		Reason:  "ImagePullBackOff",
+		Message: `Back-off pulling image "golang-profiling:latest"`,
+	}
+
+	_, err := h.Profiler.Profile(context.Background(), fake.JobConfig("default", "my-go-app", t.TempDir()), nil)
+	var startErr *job.PodStartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("Profile error = %v, want a PodStartError", err)
+	}
+	if startErr.Reason != "ImagePullBackOff" || startErr.Node != "node-1" {
+		t.Errorf("PodStartError reason %q on node %q, want ImagePullBackOff on node-1", startErr.Reason, startErr.Node)
+	}
+}
+
+func TestClusterRetention(t *testing.T) {
+	tests := []struct {
+		name           string
+		phase          api.JobPhase
+		cleanup        bool
+		keepFailedJobs bool
+		wantKept       bool
+	}{
+		{name: "succeeded, cleanup", phase: api.JobPhaseSucceeded, cleanup: true},
+		{name: "succeeded, no cleanup", phase: api.JobPhaseSucceeded, wantKept: true},
+		{name: "succeeded, keep failed jobs", phase: api.JobPhaseSucceeded, cleanup: true, keepFailedJobs: true},
+		{name: "failed, cleanup", phase: api.JobPhaseFailed, cleanup: true},
+		{name: "failed, keep failed jobs", phase: api.JobPhaseFailed, cleanup: true, keepFailedJobs: true, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newClusterHarness(t)
+			h.Cluster.Phase = tt.phase
+			wantErr := tt.phase == api.JobPhaseFailed
+			if wantErr {
+				h.Cluster.SetLogs(fake.NewProfilerLogs().Line("Error: failed to attach to the target process"))
+			}
+			cfg := fake.JobConfig("default", "my-go-app", t.TempDir())
+			cfg.Cleanup = tt.cleanup
+			cfg.KeepFailedJobs = tt.keepFailedJobs
+
+			_, err := h.Profiler.Profile(context.Background(), cfg, nil)
+			if (err != nil) != wantErr {
+				t.Fatalf("Profile error = %v, want an error %v", err, wantErr)
+			}
+			if len(h.Cluster.Jobs) != 1 {
+				t.Fatalf("created %d jobs, want 1", len(h.Cluster.Jobs))
+			}
+			created := h.Cluster.Jobs[0]
+			jobs := remainingJobs(t, h)
+			if kept := len(jobs) == 1; kept != tt.wantKept {
+				t.Fatalf("job kept = %v, want %v (jobs left: %v)", kept, tt.wantKept, jobs)
+			}
+
+			pods, err := h.Clientset.CoreV1().Pods(created.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "job-name=" + created.Name})
+			if err != nil {
+				t.Fatalf("list pods: %v", err)
+			}
+			if kept := len(pods.Items) == 1; kept != tt.wantKept {
+				t.Fatalf("pod kept = %v, want %v", kept, tt.wantKept)
+			}
+			_, err = h.Cluster.PodLogs(context.Background(), created.Namespace, created.Name+"-pod", &corev1.PodLogOptions{})
+			if hasLogs := err == nil; hasLogs != tt.wantKept {
+				t.Errorf("logs served = %v, want %v (error: %v)", hasLogs, tt.wantKept, err)
+			}
+		})
+	}
+}
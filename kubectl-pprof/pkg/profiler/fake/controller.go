@@ -0,0 +1,148 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// controllerInterval spaces the passes of Run over the Jobs of the cluster
+const controllerInterval = 100 * time.Millisecond
+
+// Run stands in for the Job controller, the kubelets and the garbage
+// collector of an API server running without them, such as the one envtest
+// starts, until ctx is done: every new Job is given a pod that finished as
+// Phase says, and the pods of deleted Jobs are deleted. Reject does not
+// apply, admission is up to the API server.
+func (c *Cluster) Run(ctx context.Context, clientset kubernetes.Interface) error {
+	err := wait.PollUntilContextCancel(ctx, controllerInterval, true, func(ctx context.Context) (bool, error) {
+		if err := c.reconcile(ctx, clientset); err != nil && ctx.Err() == nil {
+			return false, err
+		}
+		return false, nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// reconcile runs the new Jobs and collects the pods of deleted ones
+func (c *Cluster) reconcile(ctx context.Context, clientset kubernetes.Interface) error {
+	jobs, err := clientset.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	existing := make(map[string]bool)
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		existing[j.Namespace+"/"+j.Name] = true
+		switch {
+		case j.DeletionTimestamp != nil:
+			if err := c.deleteJob(ctx, clientset, j); err != nil {
+				return err
+			}
+		case j.Status.StartTime == nil:
+			if err := c.runJob(ctx, clientset, j); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Jobs deleted in the background leave their pods to the collector
+	c.mu.Lock()
+	var orphans []string
+	for key, jobName := range c.pods {
+		namespace, _, _ := strings.Cut(key, "/")
+		if !existing[namespace+"/"+jobName] {
+			orphans = append(orphans, key)
+		}
+	}
+	c.mu.Unlock()
+	for _, key := range orphans {
+		if err := c.deletePod(ctx, clientset, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runJob creates the pod of a new Job, then finishes both
+func (c *Cluster) runJob(ctx context.Context, clientset kubernetes.Interface, j *batchv1.Job) error {
+	c.mu.Lock()
+	c.Jobs = append(c.Jobs, j.DeepCopy())
+	c.mu.Unlock()
+
+	finished := j.DeepCopy()
+	podPhase := c.finish(finished)
+	pod := jobPod(finished, podPhase, c.StartFailure)
+	pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(j, batchv1.SchemeGroupVersion.WithKind("Job"))}
+	status := pod.Status
+
+	// Pods are created without their status, the kubelet reports it
+	created, err := clientset.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create the pod of job %s/%s: %w", j.Namespace, j.Name, err)
+	}
+	c.mu.Lock()
+	c.pods[created.Namespace+"/"+created.Name] = j.Name
+	c.mu.Unlock()
+	created.Status = status
+	if _, err := clientset.CoreV1().Pods(created.Namespace).UpdateStatus(ctx, created, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status of pod %s/%s: %w", created.Namespace, created.Name, err)
+	}
+	if _, err := clientset.BatchV1().Jobs(j.Namespace).UpdateStatus(ctx, finished, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status of job %s/%s: %w", j.Namespace, j.Name, err)
+	}
+	return nil
+}
+
+// deleteJob deletes the pods of a Job being deleted in the foreground, then
+// lets the API server remove the Job
+func (c *Cluster) deleteJob(ctx context.Context, clientset kubernetes.Interface, j *batchv1.Job) error {
+	c.mu.Lock()
+	var pods []string
+	for key, jobName := range c.pods {
+		if namespace, _, _ := strings.Cut(key, "/"); namespace == j.Namespace && jobName == j.Name {
+			pods = append(pods, key)
+		}
+	}
+	c.mu.Unlock()
+	for _, key := range pods {
+		if err := c.deletePod(ctx, clientset, key); err != nil {
+			return err
+		}
+	}
+
+	if !slices.Contains(j.Finalizers, metav1.FinalizerDeleteDependents) {
+		return nil
+	}
+	j.Finalizers = slices.DeleteFunc(j.Finalizers, func(f string) bool { return f == metav1.FinalizerDeleteDependents })
+	if _, err := clientset.BatchV1().Jobs(j.Namespace).Update(ctx, j, metav1.UpdateOptions{}); err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return fmt.Errorf("failed to remove the finalizer of job %s/%s: %w", j.Namespace, j.Name, err)
+	}
+	return nil
+}
+
+// deletePod deletes a pod of a Job at once, no kubelet stops it
+func (c *Cluster) deletePod(ctx context.Context, clientset kubernetes.Interface, key string) error {
+	c.mu.Lock()
+	delete(c.pods, key)
+	c.mu.Unlock()
+
+	namespace, name, _ := strings.Cut(key, "/")
+	var grace int64
+	err := clientset.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete pod %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
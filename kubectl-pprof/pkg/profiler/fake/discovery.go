@@ -0,0 +1,121 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/discovery"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+var _ profiler.TargetDiscovery = (*Discovery)(nil)
+
+// Discovery finds targets among in-memory pods and nodes
+type Discovery struct {
+	// Pods are the pods of every namespace
+	Pods []corev1.Pod
+	// Nodes maps node names to their info, unknown nodes are linux/amd64
+	Nodes map[string]*api.NodeInfo
+	// Workloads maps kind/name references to the label selector of their pods
+	Workloads map[string]string
+}
+
+// NewDiscovery returns a Discovery serving the given pods
+func NewDiscovery(pods ...*corev1.Pod) *Discovery {
+	d := &Discovery{Nodes: make(map[string]*api.NodeInfo), Workloads: make(map[string]string)}
+	for _, pod := range pods {
+		d.Pods = append(d.Pods, *pod)
+	}
+	return d
+}
+
+// FindPod returns a running pod
+func (d *Discovery) FindPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error) {
+	for i := range d.Pods {
+		pod := &d.Pods[i]
+		if pod.Namespace != namespace || pod.Name != podName {
+			continue
+		}
+		if pod.Status.Phase != corev1.PodRunning {
+			return nil, fmt.Errorf("pod %s/%s is not running (phase: %s)", namespace, podName, pod.Status.Phase)
+		}
+		return pod.DeepCopy(), nil
+	}
+	return nil, fmt.Errorf("failed to get pod %s/%s: not found", namespace, podName)
+}
+
+// ListPods returns the running pods of a namespace matching a label selector, by name
+func (d *Discovery) ListPods(ctx context.Context, namespace, selector string) ([]corev1.Pod, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in %s matching %q: %w", namespace, selector, err)
+	}
+
+	var pods []corev1.Pod
+	for _, pod := range d.Pods {
+		if pod.Namespace == namespace && pod.Status.Phase == corev1.PodRunning && parsed.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, *pod.DeepCopy())
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// WorkloadPods returns the running pods of a workload registered in Workloads
+func (d *Discovery) WorkloadPods(ctx context.Context, namespace, ref string) ([]corev1.Pod, error) {
+	if !strings.Contains(ref, "/") {
+		ref = "daemonset/" + ref
+	}
+	selector, ok := d.Workloads[ref]
+	if !ok {
+		return nil, fmt.Errorf("failed to get %s in %s: not found", ref, namespace)
+	}
+	return d.ListPods(ctx, namespace, selector)
+}
+
+// FindContainer finds a container like discovery.Discovery does
+func (d *Discovery) FindContainer(pod *corev1.Pod, containerName string) (*corev1.Container, error) {
+	if containerName == "" {
+		container, _, err := discovery.SelectContainer(pod, false)
+		return container, err
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == containerName {
+			return &container, nil
+		}
+	}
+	return nil, fmt.Errorf("container %s not found in pod %s/%s", containerName, pod.Namespace, pod.Name)
+}
+
+// GetNodeInfo returns the registered node info, or a linux/amd64 node
+func (d *Discovery) GetNodeInfo(ctx context.Context, nodeName string) (*api.NodeInfo, error) {
+	if info, ok := d.Nodes[nodeName]; ok {
+		return info, nil
+	}
+	return &api.NodeInfo{
+		Name:            nodeName,
+		KernelVersion:   kernelVersion,
+		Architecture:    "amd64",
+		OperatingSystem: "linux",
+	}, nil
+}
+
+// GetRuntimeInfo reports containerd with the container ID of the pod status
+func (d *Discovery) GetRuntimeInfo(ctx context.Context, pod *corev1.Pod, container *corev1.Container) (*api.RuntimeInfo, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container.Name {
+			return &api.RuntimeInfo{
+				Runtime:     api.RuntimeContainerd,
+				ContainerID: status.ContainerID,
+				ImageID:     status.ImageID,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("container %s status not found", container.Name)
+}
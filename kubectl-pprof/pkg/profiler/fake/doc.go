@@ -0,0 +1,35 @@
+// Package fake provides in-memory stand-ins for the collaborators of a
+// profiler.Profiler and a harness wiring them to a fake clientset, so that
+// profiling workflows can be exercised without a cluster:
+//
+//	h, err := fake.NewHarness(
+//		fake.Node("node-1"),
+//		fake.Pod("default", "my-go-app", "node-1", "app"),
+//	)
+//	...
+//	cfg := api.NewProfileConfig("default", "my-go-app", api.WithMode(api.ModeJob))
+//	result, err := h.Profiler.Profile(ctx, cfg, nil)
+//	...
+//	run := h.Jobs.Runs[0] // the config and target the Job was created for
+//
+// The harness talks to k8s.io/client-go/kubernetes/fake rather than a real
+// API server: objects are stored as given, no controller runs and pod logs
+// are canned, which is why Jobs and artifacts are faked as well.
+//
+// NewClusterHarness keeps the real job manager instead, so that the Job spec,
+// its monitoring and the extraction of results from the logs are covered too.
+// Cluster finishes every Job created at once and serves the profiler logs
+// scripted for it:
+//
+//	h, err := fake.NewClusterHarness(fake.Node("node-1"), fake.Pod(...))
+//	...
+//	h.Cluster.SetLogs(fake.NewProfilerLogs().
+//		Artifact(fake.LogFlameGraph, svg).
+//		Line("SAMPLE_SUMMARY:12 0 0 0 40 0"))
+//	result, err := h.Profiler.Profile(ctx, cfg, nil)
+//	...
+//	spec := h.Cluster.Jobs[0] // the Job as the job manager built it
+//
+// Cluster.Run runs the Jobs of a real API server the same way, see package
+// integration.
+package fake
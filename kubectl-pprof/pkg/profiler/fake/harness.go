@@ -0,0 +1,137 @@
+package fake
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+// kernelVersion is the kernel of fake nodes, recent enough for every probe
+const kernelVersion = "6.1.0"
+
+// Harness is a Profiler wired to a fake clientset. Targets are discovered
+// from the objects of the clientset. Jobs and artifacts are either kept in
+// memory by Jobs and Transport, or, with NewClusterHarness, run by the real
+// job manager against Cluster.
+type Harness struct {
+	Clientset *k8sfake.Clientset
+	Config    *config.KubernetesConfig
+	Jobs      *JobRunner
+	Transport *Transport
+	Cluster   *Cluster
+	Profiler  *profiler.Profiler
+}
+
+// NewHarness returns a Harness whose clientset holds objects. Access reviews
+// are allowed, so the auto mode picks a Job like on a permissive cluster.
+func NewHarness(objects ...runtime.Object) (*Harness, error) {
+	clientset := newClientset(objects...)
+	h := &Harness{
+		Clientset: clientset,
+		Config:    &config.KubernetesConfig{Clientset: clientset, Namespace: "default"},
+		Jobs:      &JobRunner{},
+		Transport: NewTransport(),
+	}
+	p, err := profiler.NewProfiler(h.Config, profiler.WithJobRunner(h.Jobs), profiler.WithTransport(h.Transport))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profiler: %w", err)
+	}
+	h.Profiler = p
+	return h, nil
+}
+
+// NewClusterHarness returns a Harness running the whole Job path: the real
+// job manager builds and creates the Job, monitors it and extracts the
+// results from the profiler logs scripted in Cluster.
+func NewClusterHarness(objects ...runtime.Object) (*Harness, error) {
+	clientset := newClientset(objects...)
+	h := &Harness{
+		Clientset: clientset,
+		Config:    &config.KubernetesConfig{Clientset: clientset, Namespace: "default"},
+		Cluster:   NewCluster(),
+	}
+	h.Cluster.install(clientset)
+
+	manager, err := job.NewManager(h.Config, job.WithPodLogs(h.Cluster.PodLogs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job manager: %w", err)
+	}
+	p, err := profiler.NewProfiler(h.Config, profiler.WithJobRunner(manager), profiler.WithTransport(manager))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profiler: %w", err)
+	}
+	h.Profiler = p
+	return h, nil
+}
+
+// newClientset returns a fake clientset holding objects, allowing every
+// access review
+func newClientset(objects ...runtime.Object) *k8sfake.Clientset {
+	clientset := k8sfake.NewSimpleClientset(objects...)
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+	return clientset
+}
+
+// Pod returns a running pod scheduled on a node, with a ready containerd
+// container for every name
+func Pod(namespace, name, nodeName string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": name},
+		},
+		Spec:   corev1.PodSpec{NodeName: nodeName},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container, Image: container + ":latest"})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:        container,
+			Ready:       true,
+			Image:       container + ":latest",
+			ContainerID: fmt.Sprintf("containerd://%s-%s", name, container),
+		})
+	}
+	return pod
+}
+
+// JobConfig returns a config profiling a pod for a second with a Job,
+// writing the flame graph into dir
+func JobConfig(namespace, podName, dir string) *api.ProfileConfig {
+	return api.NewProfileConfig(namespace, podName,
+		api.WithMode(api.ModeJob),
+		api.WithDuration(time.Second),
+		api.WithOutputPath(filepath.Join(dir, "flamegraph.svg")))
+}
+
+// Node returns a ready linux/amd64 node
+func Node(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			NodeInfo: corev1.NodeSystemInfo{
+				KernelVersion:   kernelVersion,
+				OperatingSystem: "linux",
+				Architecture:    "amd64",
+			},
+		},
+	}
+}
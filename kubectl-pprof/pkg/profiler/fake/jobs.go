@@ -0,0 +1,178 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+var _ profiler.JobRunner = (*JobRunner)(nil)
+
+// Run is a profiler run recorded by JobRunner
+type Run struct {
+	JobName   string
+	Ephemeral bool
+	// Detached runs were started with --no-collect and finish once collected
+	Detached bool
+	Config   api.ProfileConfig
+	Target   *api.TargetInfo
+}
+
+// JobRunner records profiler runs and finishes them at once
+type JobRunner struct {
+	// Phase is the phase runs finish in, succeeded when empty
+	Phase api.JobPhase
+	// Err fails every run when set
+	Err error
+	// Ephemeral reports whether the cluster supports ephemeral containers
+	Ephemeral bool
+
+	mu      sync.Mutex
+	Runs    []Run
+	Deleted []string
+	// Collected are the detached runs marked collected and kept
+	Collected []string
+	sessions  map[string]*job.DetachedSession
+}
+
+// CreateProfilingJobWithMonitoring records a Job run
+func (r *JobRunner) CreateProfilingJobWithMonitoring(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	return r.run(cfg, opts, target, false)
+}
+
+// CreateEphemeralProfiler records an ephemeral container run
+func (r *JobRunner) CreateEphemeralProfiler(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	return r.run(cfg, opts, target, true)
+}
+
+// StartProfilingJob records a detached Job run and its session
+func (r *JobRunner) StartProfilingJob(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, meta *api.SessionMetadata) (*api.ProfileResult, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	r.mu.Lock()
+	jobName := fmt.Sprintf("%s-%d", JobNamePrefix, len(r.Runs))
+	r.Runs = append(r.Runs, Run{JobName: jobName, Detached: true, Config: *cfg, Target: target})
+	if r.sessions == nil {
+		r.sessions = make(map[string]*job.DetachedSession)
+	}
+	sessionCfg, sessionOpts := *cfg, *opts
+	r.sessions[jobName] = &job.DetachedSession{Config: &sessionCfg, Options: &sessionOpts, Target: target, Metadata: meta}
+	r.mu.Unlock()
+
+	return &api.ProfileResult{
+		JobName:   jobName,
+		JobStatus: &api.JobStatus{JobName: jobName, Namespace: cfg.GetJobNamespace(), Phase: api.JobPhasePending},
+		Metadata:  meta,
+	}, nil
+}
+
+// CollectProfilingJob finishes a detached run the way the job manager
+// reports it
+func (r *JobRunner) CollectProfilingJob(ctx context.Context, jobName, namespace string, opts *api.ProfileOptions) (*job.DetachedSession, *api.ProfileResult, error) {
+	r.mu.Lock()
+	session, ok := r.sessions[jobName]
+	r.mu.Unlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("job %s/%s was not started with --no-collect", namespace, jobName)
+	}
+
+	phase := r.Phase
+	if phase == "" {
+		phase = api.JobPhaseSucceeded
+	}
+	now := time.Now()
+	return session, &api.ProfileResult{
+		JobName: jobName,
+		JobStatus: &api.JobStatus{
+			JobName:   jobName,
+			Namespace: namespace,
+			Phase:     phase,
+			StartTime: &now,
+			EndTime:   &now,
+		},
+		Success: phase == api.JobPhaseSucceeded,
+	}, nil
+}
+
+// run records a run and reports it the way the job manager does
+func (r *JobRunner) run(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, ephemeral bool) (*api.ProfileResult, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	r.mu.Lock()
+	jobName := fmt.Sprintf("%s-%d", JobNamePrefix, len(r.Runs))
+	r.Runs = append(r.Runs, Run{JobName: jobName, Ephemeral: ephemeral, Config: *cfg, Target: target})
+	r.mu.Unlock()
+
+	opts.Emit(api.ProgressEvent{
+		Type:      api.EventJobCreated,
+		Namespace: target.Namespace,
+		PodName:   target.PodName,
+		Container: target.ContainerName,
+		NodeName:  target.NodeName,
+		JobName:   jobName,
+	})
+
+	phase := r.Phase
+	if phase == "" {
+		phase = api.JobPhaseSucceeded
+	}
+	now := time.Now()
+	return &api.ProfileResult{
+		JobName: jobName,
+		JobStatus: &api.JobStatus{
+			JobName:   jobName,
+			Namespace: cfg.GetJobNamespace(),
+			Phase:     phase,
+			StartTime: &now,
+			EndTime:   &now,
+		},
+		Success: phase == api.JobPhaseSucceeded,
+	}, nil
+}
+
+// SupportsEphemeralContainers returns Ephemeral
+func (r *JobRunner) SupportsEphemeralContainers() (bool, error) {
+	return r.Ephemeral, nil
+}
+
+// GetJobStatus reports the phase runs finish in
+func (r *JobRunner) GetJobStatus(ctx context.Context, jobName, namespace string) (*api.JobStatus, error) {
+	phase := r.Phase
+	if phase == "" {
+		phase = api.JobPhaseSucceeded
+	}
+	return &api.JobStatus{JobName: jobName, Namespace: namespace, Phase: phase}, nil
+}
+
+// DeleteJob records the deletion of a run
+func (r *JobRunner) DeleteJob(ctx context.Context, jobName, namespace string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Deleted = append(r.Deleted, jobName)
+	return nil
+}
+
+// MarkCollected records a detached run kept once collected
+func (r *JobRunner) MarkCollected(ctx context.Context, jobName, namespace string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Collected = append(r.Collected, jobName)
+	return nil
+}
+
+// WaitForJobSlot never waits, runs finish as soon as they start
+func (r *JobRunner) WaitForJobSlot(ctx context.Context, limit int) error {
+	return ctx.Err()
+}
+
+// JobNamePrefix prefixes the names of recorded runs, which are numbered from 0
+const JobNamePrefix = "fake-profiling"
@@ -0,0 +1,76 @@
+package fake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/launcher"
+)
+
+// Artifact names of the profiler logs, see ProfilerLogs.Artifact
+const (
+	LogFlameGraph = "FLAMEGRAPH"
+	LogFolded     = "FOLDED"
+	LogTimeline   = "TIMELINE"
+	LogSchedLat   = "SCHEDLAT"
+	LogNet        = "NET"
+	LogSnapshot   = "SNAPSHOT"
+)
+
+// ProfilerLogs scripts the output of the profiler container of a Job, in the
+// format its script prints: plain lines, reports such as
+// "SAMPLE_SUMMARY:<samples> ..." and artifacts as
+// "<NAME>_CHUNK:<index>:<length>:<base64 gzip>" lines followed by
+// "<NAME>_END:<chunks>".
+type ProfilerLogs struct {
+	lines []string
+}
+
+// NewProfilerLogs returns empty profiler logs
+func NewProfilerLogs() *ProfilerLogs {
+	return &ProfilerLogs{}
+}
+
+// SampleLogs returns the logs of a successful run: SampleFlameGraph and
+// SampleFolded as artifacts and the sample summary of SampleFolded
+func SampleLogs() *ProfilerLogs {
+	return NewProfilerLogs().
+		Line("Starting golang-profiling").
+		Artifact(LogFlameGraph, []byte(SampleFlameGraph)).
+		Artifact(LogFolded, []byte(SampleFolded)).
+		Line("SAMPLE_SUMMARY:100 0 0 0 8 0")
+}
+
+// Line appends a formatted line
+func (l *ProfilerLogs) Line(format string, args ...any) *ProfilerLogs {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+	return l
+}
+
+// Artifact appends data as the named artifact
+func (l *ProfilerLogs) Artifact(name string, data []byte) *ProfilerLogs {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(data)
+	gz.Close()
+
+	encoded := base64.StdEncoding.EncodeToString(compressed.Bytes())
+	chunks := 0
+	for ; chunks*launcher.ChunkSize < len(encoded); chunks++ {
+		chunk := encoded[chunks*launcher.ChunkSize : min((chunks+1)*launcher.ChunkSize, len(encoded))]
+		l.lines = append(l.lines, fmt.Sprintf("%s_CHUNK:%d:%d:%s", name, chunks, len(chunk), chunk))
+	}
+	l.lines = append(l.lines, fmt.Sprintf("%s_END:%d", name, chunks))
+	return l
+}
+
+// String returns the logs as the container printed them
+func (l *ProfilerLogs) String() string {
+	if len(l.lines) == 0 {
+		return ""
+	}
+	return strings.Join(l.lines, "\n") + "\n"
+}
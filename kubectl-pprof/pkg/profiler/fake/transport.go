@@ -0,0 +1,106 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+var _ profiler.ResultTransport = (*Transport)(nil)
+
+// Artifact kinds served by Transport
+const (
+	ArtifactFlameGraph = "flamegraph"
+	ArtifactFolded     = "folded"
+	ArtifactTimeline   = "timeline"
+	ArtifactNet        = "net"
+	ArtifactSchedLat   = "schedlat"
+)
+
+// SampleFlameGraph is the flame graph served by NewTransport
+const SampleFlameGraph = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="1200" height="100"><text x="10" y="20">main.main</text></svg>
+`
+
+// SampleFolded are the folded stacks served by NewTransport
+const SampleFolded = `main.main;main.work;runtime.mallocgc 30
+main.main;main.work 60
+main.main;net/http.(*conn).serve 10
+`
+
+// Transport serves artifacts from memory. Artifacts set for a job name take
+// precedence over the ones shared by every run.
+type Transport struct {
+	mu        sync.Mutex
+	artifacts map[string][]byte
+	jobs      map[string]map[string][]byte
+}
+
+// NewTransport returns a Transport serving SampleFlameGraph and SampleFolded
+func NewTransport() *Transport {
+	t := &Transport{artifacts: make(map[string][]byte), jobs: make(map[string]map[string][]byte)}
+	t.Set(ArtifactFlameGraph, []byte(SampleFlameGraph))
+	t.Set(ArtifactFolded, []byte(SampleFolded))
+	return t
+}
+
+// Set serves an artifact for every run, nil data removes it
+func (t *Transport) Set(kind string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if data == nil {
+		delete(t.artifacts, kind)
+		return
+	}
+	t.artifacts[kind] = data
+}
+
+// SetForJob serves an artifact for a single run
+func (t *Transport) SetForJob(jobName, kind string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.jobs[jobName] == nil {
+		t.jobs[jobName] = make(map[string][]byte)
+	}
+	t.jobs[jobName][kind] = data
+}
+
+// get returns an artifact of a run
+func (t *Transport) get(kind, jobName, namespace string) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if data, ok := t.jobs[jobName][kind]; ok {
+		return data, nil
+	}
+	if data, ok := t.artifacts[kind]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("no %s artifact found in the logs of %s/%s", kind, namespace, jobName)
+}
+
+// ExtractFlameGraphFromLogs returns the flame graph of a run
+func (t *Transport) ExtractFlameGraphFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return t.get(ArtifactFlameGraph, jobName, namespace)
+}
+
+// ExtractFoldedFromLogs returns the folded stacks of a run
+func (t *Transport) ExtractFoldedFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return t.get(ArtifactFolded, jobName, namespace)
+}
+
+// ExtractTimelineFromLogs returns the timeline of a run
+func (t *Transport) ExtractTimelineFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return t.get(ArtifactTimeline, jobName, namespace)
+}
+
+// ExtractNetFromLogs returns the network report of a run
+func (t *Transport) ExtractNetFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return t.get(ArtifactNet, jobName, namespace)
+}
+
+// ExtractSchedLatencyFromLogs returns the scheduler latency report of a run
+func (t *Transport) ExtractSchedLatencyFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error) {
+	return t.get(ArtifactSchedLat, jobName, namespace)
+}
@@ -0,0 +1,79 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+)
+
+// goroutineDumpTimeout bounds the port-forward and download of a goroutine dump
+const goroutineDumpTimeout = time.Minute
+
+// captureGoroutines fetches a full goroutine stack dump from the pprof endpoint
+// of the target and saves it next to the output as text and JSON. It holds the
+// same stacks the runtime prints on SIGQUIT, but the process keeps running.
+func (p *Profiler) captureGoroutines(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.GoroutineReport, error) {
+	ep, err := detectEndpoint(cfg, target)
+	if err != nil {
+		return nil, fmt.Errorf("goroutine dumps are read from the pprof endpoint: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, goroutineDumpTimeout)
+	defer cancel()
+
+	localPort, stop, err := endpoint.Forward(ctx, p.k8sConfig.Config, p.k8sConfig.Clientset, target.Namespace, target.PodName, ep.Port)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	text, err := endpoint.Fetch(ctx, ep.GoroutineDumpURL(localPort))
+	if err != nil {
+		return nil, err
+	}
+
+	dump, err := endpoint.ParseGoroutineDump(bytes.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode goroutine dump: %w", err)
+	}
+
+	base := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath)) + ".goroutines"
+	report := &api.GoroutineReport{
+		Total:  dump.Total,
+		States: dump.States,
+	}
+	if report.TextPath, err = writeLocalFile(base+".txt", text); err != nil {
+		return nil, fmt.Errorf("failed to save goroutine dump: %w", err)
+	}
+	if report.JSONPath, err = writeLocalFile(base+".json", data); err != nil {
+		return nil, fmt.Errorf("failed to save goroutine dump: %w", err)
+	}
+
+	artifactSaved(opts, "Goroutine dump", report.TextPath)
+	return report, nil
+}
+
+// attachGoroutines captures a goroutine dump when requested. A missing pprof
+// endpoint only costs the dump, never the profile.
+func (p *Profiler) attachGoroutines(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, result *api.ProfileResult) {
+	if cfg.GoOptions == nil || !cfg.GoOptions.GoroutineDump {
+		return
+	}
+	report, err := p.captureGoroutines(ctx, cfg, opts, target)
+	if err != nil {
+		opts.Log().Warn("Failed to capture goroutine dump", "error", err)
+		return
+	}
+	result.Goroutines = report
+}
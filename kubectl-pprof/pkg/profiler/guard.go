@@ -0,0 +1,249 @@
+package profiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/discovery"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+// Reasons a target was lost
+const (
+	TargetRestarted  = "restarted"
+	TargetEvicted    = "evicted"
+	TargetDeleted    = "deleted"
+	TargetReplaced   = "replaced"
+	TargetTerminated = "terminated"
+)
+
+// maxRetargets bounds how often a session follows its target to a replacement pod
+const maxRetargets = 3
+
+// retargetWait is how long to wait for a running replacement pod
+const retargetWait = 2 * time.Minute
+
+// TargetLostError reports that the target went away while it was profiled:
+// its container restarted or the pod was evicted, deleted or replaced. The
+// samples belong to a process that no longer exists, so there is no result.
+type TargetLostError struct {
+	Namespace string
+	Pod       string
+	Container string
+	Reason    string // TargetRestarted, TargetEvicted, ...
+	Detail    string // Why, as reported by the kubelet or the eviction
+	// Deployment owning the pod, whose replacement pods can be profiled instead
+	Deployment string
+}
+
+func (e *TargetLostError) Error() string {
+	var msg string
+	if e.Reason == TargetRestarted {
+		msg = fmt.Sprintf("target container %s of pod %s/%s restarted during profiling", e.Container, e.Namespace, e.Pod)
+	} else {
+		msg = fmt.Sprintf("target pod %s/%s was %s during profiling", e.Namespace, e.Pod, e.Reason)
+	}
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
+
+// guardTarget watches the target pod and cancels the returned context with a
+// *TargetLostError as soon as the target is lost, so the session stops
+// sampling a dead process instead of returning a misleading profile. The
+// returned function stops the watch.
+func (p *Profiler) guardTarget(ctx context.Context, opts *api.ProfileOptions, target *api.TargetInfo) (context.Context, func()) {
+	started, ok := target.Pod.(*corev1.Pod)
+	if p.k8sConfig == nil || p.k8sConfig.Clientset == nil || !ok {
+		return ctx, func() {}
+	}
+	pods := p.k8sConfig.Clientset.CoreV1().Pods(target.Namespace)
+	guardCtx, cancel := context.WithCancelCause(ctx)
+	lose := func(lost *TargetLostError) {
+		lost.Deployment = podDeployment(started)
+		cancel(lost)
+	}
+
+	go func() {
+		resourceVersion := started.ResourceVersion
+		for guardCtx.Err() == nil {
+			w, err := pods.Watch(guardCtx, metav1.ListOptions{
+				FieldSelector:   fields.OneTermEqualSelector("metadata.name", target.PodName).String(),
+				ResourceVersion: resourceVersion,
+			})
+			if err != nil {
+				opts.Log().Log(guardCtx, logging.V(1), "Failed to watch the target pod", "error", err)
+				select {
+				case <-guardCtx.Done():
+				case <-time.After(2 * time.Second):
+				}
+				continue
+			}
+			resourceVersion = watchTarget(guardCtx, w, target, started, resourceVersion, lose)
+			w.Stop()
+		}
+	}()
+
+	return guardCtx, func() { cancel(nil) }
+}
+
+// watchTarget reads the events of a watch on the target pod until it ends or
+// the target is lost, and returns the resource version to watch from next
+func watchTarget(ctx context.Context, w watch.Interface, target *api.TargetInfo, started *corev1.Pod, resourceVersion string, lose func(*TargetLostError)) string {
+	for {
+		var event watch.Event
+		select {
+		case <-ctx.Done():
+			return resourceVersion
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return resourceVersion
+			}
+			event = e
+		}
+
+		switch event.Type {
+		case watch.Deleted:
+			lose(&TargetLostError{Namespace: target.Namespace, Pod: target.PodName, Container: target.ContainerName, Reason: TargetDeleted})
+		case watch.Added, watch.Modified:
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			resourceVersion = pod.ResourceVersion
+			if lost := targetLost(target, started, pod); lost != nil {
+				lose(lost)
+			}
+		case watch.Error:
+			// Expired resource version, start over from the current pod
+			return ""
+		}
+	}
+}
+
+// lostTarget returns the *TargetLostError that ended a guarded context, or nil
+func lostTarget(ctx context.Context) *TargetLostError {
+	var lost *TargetLostError
+	if errors.As(context.Cause(ctx), &lost) {
+		return lost
+	}
+	return nil
+}
+
+// targetLost compares the pod with the one the session started on
+func targetLost(target *api.TargetInfo, started, pod *corev1.Pod) *TargetLostError {
+	lost := &TargetLostError{Namespace: target.Namespace, Pod: target.PodName, Container: target.ContainerName}
+
+	if pod.UID != started.UID {
+		lost.Reason = TargetReplaced
+		lost.Detail = "a new pod with the same name took its place"
+		return lost
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.DisruptionTarget && condition.Status == corev1.ConditionTrue {
+			lost.Reason = TargetEvicted
+			lost.Detail = joinDetail(condition.Reason, condition.Message)
+			return lost
+		}
+	}
+	if pod.Status.Reason == "Evicted" {
+		lost.Reason = TargetEvicted
+		lost.Detail = pod.Status.Message
+		return lost
+	}
+	if pod.DeletionTimestamp != nil {
+		lost.Reason = TargetDeleted
+		return lost
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		lost.Reason = TargetTerminated
+		lost.Detail = joinDetail(string(pod.Status.Phase), pod.Status.Message)
+		return lost
+	}
+
+	before, now := discovery.ContainerStatus(started, target.ContainerName), discovery.ContainerStatus(pod, target.ContainerName)
+	if before == nil || now == nil {
+		return nil
+	}
+	if now.RestartCount > before.RestartCount || (before.ContainerID != "" && now.ContainerID != "" && now.ContainerID != before.ContainerID) {
+		lost.Reason = TargetRestarted
+		if terminated := now.LastTerminationState.Terminated; terminated != nil {
+			lost.Detail = joinDetail(terminated.Reason, fmt.Sprintf("exit code %d", terminated.ExitCode))
+		}
+		return lost
+	}
+	return nil
+}
+
+// joinDetail joins the non-empty parts of a detail
+func joinDetail(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ", ")
+}
+
+// podDeployment returns the Deployment owning the pod through its
+// ReplicaSet, whose name is the Deployment's and the pod template hash
+func podDeployment(pod *corev1.Pod) string {
+	hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	owner := metav1.GetControllerOf(pod)
+	if hash == "" || owner == nil || owner.Kind != "ReplicaSet" {
+		return ""
+	}
+	name, found := strings.CutSuffix(owner.Name, "-"+hash)
+	if !found {
+		return ""
+	}
+	return name
+}
+
+// replacementPod waits for the pod to profile in place of a lost target: the
+// same pod once its container restarted, else the newest running pod of the
+// Deployment other than the lost one
+func (p *Profiler) replacementPod(ctx context.Context, lost *TargetLostError) (string, error) {
+	if lost.Reason == TargetRestarted {
+		return lost.Pod, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, retargetWait)
+	defer cancel()
+	for {
+		pods, err := p.discovery.WorkloadPods(ctx, lost.Namespace, "deployment/"+lost.Deployment)
+		if err != nil && ctx.Err() == nil {
+			return "", err
+		}
+		var candidates []corev1.Pod
+		for _, pod := range pods {
+			if pod.Name != lost.Pod && pod.DeletionTimestamp == nil {
+				candidates = append(candidates, pod)
+			}
+		}
+		if len(candidates) > 0 {
+			sort.Slice(candidates, func(i, j int) bool {
+				return candidates[j].CreationTimestamp.Before(&candidates[i].CreationTimestamp)
+			})
+			return candidates[0].Name, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no running replacement pod of deployment/%s within %v", lost.Deployment, retargetWait)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// heapGrowthTopSites is how many allocation sites the growth report lists
+const heapGrowthTopSites = 10
+
+// Titles of the heap growth graph and of the fallback when nothing grew
+const (
+	heapGrowthTitle = "Golang Heap Growth"
+	heapInuseTitle  = "Golang Heap In Use (no growth between snapshots)"
+)
+
+// profileHeapGrowth takes cfg.Snapshots heap profiles spread evenly over the
+// duration and reports which allocation sites grew from the first to the last.
+// A point-in-time heap shows what is big, the delta shows what keeps growing.
+func (p *Profiler) profileHeapGrowth(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	ep, err := detectEndpoint(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	opts.Log().Info("Using pprof endpoint", "port", ep.Port, "path", ep.Path, "reason", ep.Reason)
+
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout(cfg))
+	defer cancel()
+
+	localPort, stop, err := endpoint.Forward(ctx, p.k8sConfig.Config, p.k8sConfig.Clientset, target.Namespace, target.PodName, ep.Port)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	var runtimeStart *api.RuntimeSnapshot
+	if cfg.RuntimeMetrics {
+		if runtimeStart, err = ep.RuntimeSnapshot(ctx, localPort); err != nil {
+			opts.Log().Warn("Failed to read runtime metrics", "error", err)
+		}
+	}
+
+	base := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath))
+	interval := cfg.Duration / time.Duration(cfg.Snapshots-1)
+	report := &api.HeapGrowthReport{}
+	var first, last heapSnapshot
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingStarted, Namespace: target.Namespace, PodName: target.PodName})
+	for i := 0; i < cfg.Snapshots; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("heap snapshot %d of %d: %w", i+1, cfg.Snapshots, ctx.Err())
+			case <-time.After(interval):
+			}
+		}
+
+		snapshot, err := takeHeapSnapshot(ctx, ep, localPort)
+		if err != nil {
+			return nil, fmt.Errorf("heap snapshot %d of %d: %w", i+1, cfg.Snapshots, err)
+		}
+		if snapshot.info.Path, err = writeLocalFile(fmt.Sprintf("%s.heap.%d.pprof", base, i+1), snapshot.data); err != nil {
+			return nil, fmt.Errorf("failed to save heap snapshot: %w", err)
+		}
+		opts.Log().Info(fmt.Sprintf("Heap snapshot %d/%d", i+1, cfg.Snapshots),
+			"inuse", FormatBytes(uint64(snapshot.info.InuseBytes)), "objects", snapshot.info.InuseObjects)
+		report.Snapshots = append(report.Snapshots, snapshot.info)
+
+		if i == 0 {
+			first = snapshot
+		}
+		last = snapshot
+	}
+
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingFinished, Namespace: target.Namespace, PodName: target.PodName})
+
+	var runtimeReport *api.RuntimeMetricsReport
+	if runtimeStart != nil {
+		if runtimeEnd, err := ep.RuntimeSnapshot(ctx, localPort); err == nil {
+			runtimeReport = endpoint.RuntimeReport(runtimeStart, runtimeEnd)
+		}
+	}
+
+	growth := heapGrowth(first, last, report)
+
+	meta := newSessionMetadata(cfg, target)
+	meta.Frequency = 0
+	meta.Runtime = runtimeReport
+	runCfg := withSessionSubtitle(cfg, meta)
+
+	result := &api.ProfileResult{
+		Config:     cfg,
+		Duration:   cfg.Duration,
+		Success:    true,
+		Metadata:   meta,
+		HeapGrowth: report,
+		JobStatus: &api.JobStatus{
+			Namespace: target.Namespace,
+			PodName:   target.PodName,
+			Phase:     api.JobPhaseSucceeded,
+		},
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode heap growth report: %w", err)
+	}
+	if report.Path, err = writeLocalFile(base+".growth.json", data); err != nil {
+		return nil, fmt.Errorf("failed to save heap growth report: %w", err)
+	}
+	artifactSaved(opts, "Heap growth report", report.Path)
+
+	renderOpts := renderOptions(runCfg.GoOptions)
+	renderOpts.CountName = "inuse_space/bytes"
+	renderOpts.Facts = runtimeFacts(runtimeReport)
+	title := heapGrowthTitle
+	graphProfile := growth
+	// Nothing grew: show what the heap holds instead of failing on an empty graph
+	if len(growth.Samples) == 0 {
+		title = heapInuseTitle
+		graphProfile = last.space
+	}
+	if runCfg.GoOptions.Title == "" {
+		renderOpts.Title = title
+	}
+
+	graph, err := renderProfile(graphProfile, renderOpts, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render flame graph: %w", err)
+	}
+	graph = embedSVGMetadata(graph, meta)
+	if err := p.saveOutputFile(cfg, opts, graph); err != nil {
+		return nil, fmt.Errorf("failed to save output file: %w", err)
+	}
+	result.OutputPath = cfg.OutputPath
+	result.FileSize = int64(len(graph))
+
+	return result, nil
+}
+
+// heapSnapshot one heap profile, aggregated by in-use bytes and objects
+type heapSnapshot struct {
+	info    api.HeapSnapshot
+	data    []byte
+	space   *flamegraph.Profile
+	objects *flamegraph.Profile
+}
+
+// takeHeapSnapshot downloads and parses the current heap profile
+func takeHeapSnapshot(ctx context.Context, ep *endpoint.Endpoint, localPort uint16) (heapSnapshot, error) {
+	snapshot := heapSnapshot{info: api.HeapSnapshot{Time: time.Now().UTC()}}
+
+	var err error
+	if snapshot.data, err = endpoint.Fetch(ctx, ep.HeapSnapshotURL(localPort)); err != nil {
+		return snapshot, err
+	}
+	if snapshot.space, _, err = flamegraph.ParsePprof(bytes.NewReader(snapshot.data), "inuse_space"); err != nil {
+		return snapshot, err
+	}
+	if snapshot.objects, _, err = flamegraph.ParsePprof(bytes.NewReader(snapshot.data), "inuse_objects"); err != nil {
+		return snapshot, err
+	}
+	snapshot.info.InuseBytes = snapshot.space.Total()
+	snapshot.info.InuseObjects = snapshot.objects.Total()
+	return snapshot, nil
+}
+
+// heapGrowth fills the report with the growth from first to last and returns
+// the stacks whose in-use bytes grew, for the flame graph. Sites are the leaf
+// frames of the allocating stacks.
+func heapGrowth(first, last heapSnapshot, report *api.HeapGrowthReport) *flamegraph.Profile {
+	report.GrowthBytes = last.info.InuseBytes - first.info.InuseBytes
+
+	bytesDelta := stacks.Deltas(first.space, last.space)
+	objectsDelta := stacks.Deltas(first.objects, last.objects)
+
+	growth := &flamegraph.Profile{}
+	sites := make(map[string]*api.HeapGrowthSite)
+	for key, delta := range bytesDelta {
+		stack := stacks.SplitKey(key)
+		if delta > 0 {
+			growth.Samples = append(growth.Samples, flamegraph.Sample{Stack: stack, Value: delta})
+		}
+		leaf := stack[len(stack)-1]
+		site, ok := sites[leaf]
+		if !ok {
+			site = &api.HeapGrowthSite{Function: leaf}
+			sites[leaf] = site
+		}
+		site.BytesDelta += delta
+		site.ObjectsDelta += objectsDelta[key]
+	}
+	sort.Slice(growth.Samples, func(i, j int) bool {
+		return stacks.Key(growth.Samples[i].Stack) < stacks.Key(growth.Samples[j].Stack)
+	})
+
+	for _, site := range sites {
+		if site.BytesDelta > 0 {
+			report.TopSites = append(report.TopSites, *site)
+		}
+	}
+	sort.Slice(report.TopSites, func(i, j int) bool {
+		if report.TopSites[i].BytesDelta != report.TopSites[j].BytesDelta {
+			return report.TopSites[i].BytesDelta > report.TopSites[j].BytesDelta
+		}
+		return report.TopSites[i].Function < report.TopSites[j].Function
+	})
+	if len(report.TopSites) > heapGrowthTopSites {
+		report.TopSites = report.TopSites[:heapGrowthTopSites]
+	}
+	return growth
+}
@@ -0,0 +1,109 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/discovery"
+)
+
+// ImageTargets returns the containers of the running pods of the namespace
+// whose image matches the glob of cfg.TargetImage
+func (p *Profiler) ImageTargets(ctx context.Context, namespace, glob string) ([]discovery.ImageTarget, error) {
+	pods, err := p.discovery.ListPods(ctx, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	targets := discovery.ImageTargets(pods, glob)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no running containers with an image matching %q in namespace %s", glob, namespace)
+	}
+	return targets, nil
+}
+
+// ProfileImageTargets profiles the given containers one after the other, one
+// Job each. Each container gets its own artifacts named after its pod (and
+// the container, when several of a pod matched); with MergeContainers the
+// stacks are also rendered as one graph rooted at a frame per container.
+func (p *Profiler) ProfileImageTargets(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, targets []discovery.ImageTarget) (*api.MultiProfileResult, error) {
+	opts = p.sessionOptions(opts)
+
+	perPod := make(map[string]int)
+	for _, target := range targets {
+		perPod[target.Pod]++
+	}
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Pod
+		if perPod[target.Pod] > 1 {
+			names[i] += "-" + target.Container
+		}
+	}
+
+	multi := &api.MultiProfileResult{}
+	for i, target := range targets {
+		icfg := imageTargetConfig(cfg, target, names[i])
+		opts.Log().Info("Profiling container", "pod", target.Pod, "container", target.Container, "image", target.Image)
+		result, err := p.Profile(ctx, icfg, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return multi, ctx.Err()
+			}
+			if multi.Failures == nil {
+				multi.Failures = make(map[string]string)
+			}
+			multi.Failures[names[i]] = err.Error()
+			continue
+		}
+		result.Config = icfg
+		multi.Results = append(multi.Results, result)
+	}
+	if len(multi.Results) == 0 {
+		return multi, fmt.Errorf("profiling failed for all %d matching containers", len(targets))
+	}
+
+	if cfg.MergeContainers {
+		frame := func(result *api.ProfileResult) string {
+			return result.Config.PodName + "/" + result.Config.ContainerName
+		}
+		subtitle := fmt.Sprintf("%s, image %s, %d containers", cfg.Namespace, cfg.TargetImage, len(multi.Results))
+		mergedPath, err := p.mergeProfiles(cfg, opts, multi.Results, frame, subtitle)
+		if err != nil {
+			return multi, err
+		}
+		multi.MergedPath = mergedPath
+	}
+
+	return multi, nil
+}
+
+// imageTargetConfig derives the configuration of the run on one matching
+// container. Output files carry the given name so that runs never collide.
+func imageTargetConfig(cfg *api.ProfileConfig, target discovery.ImageTarget, name string) *api.ProfileConfig {
+	icfg := *cfg
+	icfg.TargetImage = ""
+	icfg.AllMatches = false
+	icfg.PodName = target.Pod
+	icfg.ContainerName = target.Container
+	icfg.OutputPath = containerPath(cfg.OutputPath, name)
+	if cfg.ExportSummary != "" {
+		icfg.ExportSummary = containerPath(cfg.ExportSummary, name)
+	}
+
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	if goOpts.ExportFolded != "" {
+		goOpts.ExportFolded = containerPath(goOpts.ExportFolded, name)
+	} else if cfg.MergeContainers {
+		// The merged graph is built from the folded stacks of every container
+		goOpts.ExportFolded = filepath.Base(strings.TrimSuffix(icfg.OutputPath, filepath.Ext(icfg.OutputPath))) + ".folded"
+	}
+	icfg.GoOptions = &goOpts
+
+	return &icfg
+}
@@ -0,0 +1,139 @@
+//go:build integration
+
+// Package integration runs profiling workflows against a real API server and
+// etcd, started by envtest from sigs.k8s.io/controller-runtime. Unlike the
+// fake clientset of package fake, the API server validates the Jobs and pods
+// the job manager creates, serves field and label selectors, Leases and
+// access reviews, and runs its admission plugins.
+//
+// envtest starts no controllers and no kubelets: fake.Cluster stands in for
+// the Job controller and the garbage collector, and serves the profiler logs
+// scripted for the Jobs. The kube-apiserver and etcd binaries are looked up
+// in $KUBEBUILDER_ASSETS, which setup-envtest prints:
+//
+//	export KUBEBUILDER_ASSETS=$(setup-envtest use -p path 1.33.x)
+//	go test -tags integration ./pkg/profiler/integration/
+//
+// Tests skip when it is not set, see RequireAssets.
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+	"github.com/withlin/kubectl-pprof/pkg/profiler/fake"
+)
+
+// AssetsEnv names the directory of the kube-apiserver and etcd binaries
+const AssetsEnv = "KUBEBUILDER_ASSETS"
+
+// Harness is a Profiler running the whole Job path against an API server
+// started by envtest, with Cluster running the Jobs
+type Harness struct {
+	Env      *envtest.Environment
+	Config   *config.KubernetesConfig
+	Cluster  *fake.Cluster
+	Profiler *profiler.Profiler
+
+	stop context.CancelFunc
+	done chan error
+}
+
+// RequireAssets skips a test when the envtest binaries are not installed
+func RequireAssets(t testing.TB) {
+	t.Helper()
+	if os.Getenv(AssetsEnv) == "" {
+		t.Skipf("%s is not set, install the API server binaries with setup-envtest", AssetsEnv)
+	}
+}
+
+// Start starts the API server and etcd, and Cluster against them. Stop
+// stops them.
+func Start() (*Harness, error) {
+	env := &envtest.Environment{}
+	restConfig, err := env.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the API server: %w", err)
+	}
+	h := &Harness{Env: env, Cluster: fake.NewCluster()}
+	if err := h.init(restConfig); err != nil {
+		env.Stop()
+		return nil, err
+	}
+	return h, nil
+}
+
+// init builds the profiler and starts Cluster
+func (h *Harness) init(restConfig *rest.Config) error {
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create clientset: %w", err)
+	}
+	h.Config = &config.KubernetesConfig{Config: restConfig, Clientset: clientset, Namespace: metav1.NamespaceDefault}
+
+	manager, err := job.NewManager(h.Config, job.WithPodLogs(h.Cluster.PodLogs))
+	if err != nil {
+		return fmt.Errorf("failed to create job manager: %w", err)
+	}
+	if h.Profiler, err = profiler.NewProfiler(h.Config, profiler.WithJobRunner(manager), profiler.WithTransport(manager)); err != nil {
+		return fmt.Errorf("failed to create profiler: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.stop, h.done = cancel, make(chan error, 1)
+	go func() { h.done <- h.Cluster.Run(ctx, clientset) }()
+	return nil
+}
+
+// Stop stops Cluster, the API server and etcd, returning the error that
+// stopped Cluster early, if any
+func (h *Harness) Stop() error {
+	h.stop()
+	return errors.Join(<-h.done, h.Env.Stop())
+}
+
+// CreateNode creates a node as fake.Node returns it, with its status
+func (h *Harness) CreateNode(ctx context.Context, node *corev1.Node) error {
+	nodes := h.Config.Clientset.CoreV1().Nodes()
+	created, err := nodes.Create(ctx, node, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create node %s: %w", node.Name, err)
+	}
+	created.Status = node.Status
+	if _, err := nodes.UpdateStatus(ctx, created, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status of node %s: %w", node.Name, err)
+	}
+	return nil
+}
+
+// CreatePod creates a pod as fake.Pod returns it, with its status, in a
+// namespace created when missing
+func (h *Harness) CreatePod(ctx context.Context, pod *corev1.Pod) error {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}
+	if _, err := h.Config.Clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s: %w", pod.Namespace, err)
+	}
+	pods := h.Config.Clientset.CoreV1().Pods(pod.Namespace)
+	created, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	created.Status = pod.Status
+	if _, err := pods.UpdateStatus(ctx, created, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the status of pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+//go:build integration
+
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/profiler/fake"
+	"github.com/withlin/kubectl-pprof/pkg/profiler/integration"
+)
+
+// start starts a harness with a Go pod on node-1, configured by setup
+// before the cluster runs any Job
+func start(t *testing.T, setup func(*fake.Cluster)) *integration.Harness {
+	t.Helper()
+	integration.RequireAssets(t)
+	h, err := integration.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := h.Stop(); err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+	})
+	if setup != nil {
+		setup(h.Cluster)
+	}
+
+	ctx := context.Background()
+	if err := h.CreateNode(ctx, fake.Node("node-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.CreatePod(ctx, fake.Pod("apps", "my-go-app", "node-1", "app")); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// waitForJobs waits until the cluster holds want Jobs, the garbage collection
+// of deleted ones runs in the background
+func waitForJobs(t *testing.T, h *integration.Harness, want int) {
+	t.Helper()
+	var got int
+	err := wait.PollUntilContextTimeout(context.Background(), 100*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		jobs, err := h.Config.Clientset.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		got = len(jobs.Items)
+		return got == want, nil
+	})
+	if err != nil {
+		t.Fatalf("the cluster holds %d jobs, want %d: %v", got, want, err)
+	}
+}
+
+func TestProfileJob(t *testing.T) {
+	h := start(t, nil)
+	cfg := fake.JobConfig("apps", "my-go-app", t.TempDir())
+
+	result, err := h.Profiler.Profile(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if !result.Success {
+		t.Fatalf("Success = false, want true")
+	}
+	data, err := os.ReadFile(cfg.OutputPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(data), "main.main") {
+		t.Errorf("output does not hold the flame graph of the logs:\n%s", data)
+	}
+
+	// Cleaned up with its pod, and the node Lease released
+	waitForJobs(t, h, 0)
+	if _, err := h.Config.Clientset.CoordinationV1().Leases(cfg.GetLeaseNamespace()).Get(context.Background(), job.NodeLeaseName("node-1"), metav1.GetOptions{}); err == nil {
+		t.Errorf("the lease of node-1 is still held")
+	}
+}
+
+func TestProfileJobFailedKept(t *testing.T) {
+	h := start(t, func(c *fake.Cluster) {
+		c.Phase = api.JobPhaseFailed
+		c.SetLogs(fake.NewProfilerLogs().Line("Error: failed to attach to the target process"))
+	})
+	cfg := fake.JobConfig("apps", "my-go-app", t.TempDir())
+	cfg.KeepFailedJobs = true
+
+	if _, err := h.Profiler.Profile(context.Background(), cfg, nil); err == nil {
+		t.Fatalf("Profile succeeded with a failed job")
+	}
+	waitForJobs(t, h, 1)
+	pods, err := h.Config.Clientset.CoreV1().Pods(cfg.GetJobNamespace()).List(context.Background(), metav1.ListOptions{LabelSelector: "job-name"})
+	if err != nil {
+		t.Fatalf("list pods: %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Status.Phase != corev1.PodFailed {
+		t.Errorf("the failed pod of the job was not kept: %d pods", len(pods.Items))
+	}
+}
+
+func TestProfileJobPodStartFailure(t *testing.T) {
+	h := start(t, func(c *fake.Cluster) {
+		c.StartFailure = &corev1.ContainerStateWaiting{
+			Reason:  "ErrImagePull",
+			Message: `failed to pull image "golang-profiling:latest": unauthorized`,
+		}
+	})
+
+	_, err := h.Profiler.Profile(context.Background(), fake.JobConfig("apps", "my-go-app", t.TempDir()), nil)
+	var startErr *job.PodStartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("Profile error = %v, want a PodStartError", err)
+	}
+	if startErr.Reason != "ErrImagePull" {
+		t.Errorf("PodStartError reason %q, want ErrImagePull", startErr.Reason)
+	}
+}
@@ -0,0 +1,68 @@
+package profiler
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/discovery"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+)
+
+// TargetDiscovery finds the pods, containers and nodes a session profiles.
+// *discovery.Discovery implements it against the API server.
+type TargetDiscovery interface {
+	FindPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error)
+	ListPods(ctx context.Context, namespace, selector string) ([]corev1.Pod, error)
+	WorkloadPods(ctx context.Context, namespace, ref string) ([]corev1.Pod, error)
+	FindContainer(pod *corev1.Pod, containerName string) (*corev1.Container, error)
+	GetNodeInfo(ctx context.Context, nodeName string) (*api.NodeInfo, error)
+	GetRuntimeInfo(ctx context.Context, pod *corev1.Pod, container *corev1.Container) (*api.RuntimeInfo, error)
+}
+
+// JobRunner runs the profiler next to the target and waits for it to exit.
+// *job.Manager implements it with Jobs and ephemeral containers.
+type JobRunner interface {
+	CreateProfilingJobWithMonitoring(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error)
+	CreateEphemeralProfiler(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error)
+	StartProfilingJob(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, meta *api.SessionMetadata) (*api.ProfileResult, error)
+	CollectProfilingJob(ctx context.Context, jobName, namespace string, opts *api.ProfileOptions) (*job.DetachedSession, *api.ProfileResult, error)
+	MarkCollected(ctx context.Context, jobName, namespace string) error
+	SupportsEphemeralContainers() (bool, error)
+	GetJobStatus(ctx context.Context, jobName, namespace string) (*api.JobStatus, error)
+	DeleteJob(ctx context.Context, jobName, namespace string) error
+	WaitForJobSlot(ctx context.Context, limit int) error
+}
+
+// ResultTransport fetches the artifacts a finished run produced.
+// *job.Manager implements it by decoding them from the run's logs.
+type ResultTransport interface {
+	ExtractFlameGraphFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error)
+	ExtractFoldedFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error)
+	ExtractTimelineFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error)
+	ExtractNetFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error)
+	ExtractSchedLatencyFromLogs(ctx context.Context, jobName, namespace string) ([]byte, error)
+}
+
+var (
+	_ TargetDiscovery = (*discovery.Discovery)(nil)
+	_ JobRunner       = (*job.Manager)(nil)
+	_ ResultTransport = (*job.Manager)(nil)
+	_ ResultTransport = (*job.LogArtifacts)(nil)
+)
+
+// WithDiscovery replaces the discovery of targets through the API server
+func WithDiscovery(d TargetDiscovery) Option {
+	return func(p *Profiler) { p.discovery = d }
+}
+
+// WithJobRunner replaces the Jobs and ephemeral containers running the profiler
+func WithJobRunner(runner JobRunner) Option {
+	return func(p *Profiler) { p.jobManager = runner }
+}
+
+// WithTransport replaces how artifacts are fetched from finished runs
+func WithTransport(transport ResultTransport) Option {
+	return func(p *Profiler) { p.transport = transport }
+}
@@ -0,0 +1,39 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// collectListing lists the hot source lines of the functions matching --list
+// from the folded stacks of the job, which name frames "function file:line"
+func (p *Profiler) collectListing(ctx context.Context, cfg *api.ProfileConfig, jobName string) (string, error) {
+	data, err := p.transport.ExtractFoldedFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return "", fmt.Errorf("failed to extract folded stacks: %w", err)
+	}
+	profile, err := flamegraph.ParseFolded(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse folded stacks: %w", err)
+	}
+	return listSource(profile, cfg.List, "")
+}
+
+// listSource renders the --list output of a profile, empty when no sampled
+// function with source lines matches the pattern
+func listSource(profile *flamegraph.Profile, pattern, countName string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid --list %q: %w", pattern, err)
+	}
+	var listing bytes.Buffer
+	if _, err := flamegraph.WriteList(&listing, profile, re, countName); err != nil {
+		return "", err
+	}
+	return listing.String(), nil
+}
@@ -0,0 +1,51 @@
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// liveRenderer re-renders the output file from every snapshot of a Live
+// session, so the picture can be watched while sampling continues and the
+// session stopped early once it is clear. The final result replaces it.
+func liveRenderer(cfg *api.ProfileConfig, opts *api.ProfileOptions) func(folded []byte) {
+	renderOpts := renderOptions(cfg.GoOptions)
+	// Snapshots are merged stacks, a flame chart needs the timeline
+	renderOpts.FlameChart = false
+	if _, traced := tracedTitles[cfg.ProfileType]; traced {
+		renderOpts.CountName = "µs"
+	}
+	started := time.Now()
+	snapshots := 0
+
+	return func(folded []byte) {
+		profile, err := flamegraph.ParseFolded(bytes.NewReader(folded))
+		if err != nil {
+			opts.Log().Warn("Skipped a live snapshot", "error", err)
+			return
+		}
+		snapshots++
+		elapsed := time.Since(started).Round(time.Second)
+
+		snapshotOpts := renderOpts
+		snapshotOpts.Subtitle = fmt.Sprintf("Live snapshot %d after %v of %v", snapshots, elapsed, cfg.Duration)
+		if renderOpts.Subtitle != "" {
+			snapshotOpts.Subtitle = renderOpts.Subtitle + " | " + snapshotOpts.Subtitle
+		}
+		data, err := renderProfile(profile, snapshotOpts, opts)
+		if err != nil {
+			opts.Log().Warn("Failed to render a live snapshot", "error", err)
+			return
+		}
+		path, err := writeLocalFile(cfg.OutputPath, data)
+		if err != nil {
+			opts.Log().Warn("Failed to save a live snapshot", "error", err)
+			return
+		}
+		opts.Log().Info("Live snapshot updated", "snapshot", snapshots, "samples", profile.Total(), "output", path)
+	}
+}
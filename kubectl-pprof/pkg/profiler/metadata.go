@@ -0,0 +1,95 @@
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Version is the kubectl-pprof version recorded in session metadata, set by the CLI
+var Version = "dev"
+
+// defaultFrequency matches the golang-profiling default sampling frequency
+const defaultFrequency = api.DefaultFrequency
+
+// newSessionMetadata collects the facts needed to interpret an artifact later
+func newSessionMetadata(cfg *api.ProfileConfig, target *api.TargetInfo) *api.SessionMetadata {
+	meta := &api.SessionMetadata{
+		Namespace:     target.Namespace,
+		PodName:       target.PodName,
+		ContainerName: target.ContainerName,
+		NodeName:      target.NodeName,
+		Frequency:     cfg.SamplingFrequency(),
+		Duration:      cfg.Duration,
+		ToolVersion:   Version,
+		StartTime:     time.Now().UTC(),
+		HostProcess:   target.HostProcess,
+	}
+	if target.NodeInfo != nil {
+		meta.KernelVersion = target.NodeInfo.KernelVersion
+	}
+	return meta
+}
+
+// sessionSubtitle renders the metadata as a single flame graph subtitle line
+func sessionSubtitle(meta *api.SessionMetadata) string {
+	frequency := ""
+	if meta.Frequency > 0 {
+		// Snapshot profiles from a pprof endpoint have no sampling frequency
+		frequency = fmt.Sprintf(" | %d Hz", meta.Frequency)
+	}
+	subject := fmt.Sprintf("%s/%s/%s", meta.Namespace, meta.PodName, meta.ContainerName)
+	if meta.HostProcess != "" {
+		subject = meta.HostProcess
+	}
+	return fmt.Sprintf("%s on %s | kernel %s%s | %v | kubectl-pprof %s | %s",
+		subject, meta.NodeName,
+		meta.KernelVersion, frequency, meta.Duration, meta.ToolVersion,
+		meta.StartTime.Format(time.RFC3339))
+}
+
+// withSessionSubtitle returns a copy of cfg whose flame graph subtitle carries
+// the session metadata, unless the user picked a subtitle explicitly
+func withSessionSubtitle(cfg *api.ProfileConfig, meta *api.SessionMetadata) *api.ProfileConfig {
+	runCfg := *cfg
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	if goOpts.Subtitle == "" {
+		goOpts.Subtitle = sessionSubtitle(meta)
+	}
+	runCfg.GoOptions = &goOpts
+	return &runCfg
+}
+
+// embedSVGMetadata inserts the session metadata as a <metadata> element right
+// after the opening <svg> tag. Non-SVG data is returned unchanged.
+func embedSVGMetadata(data []byte, meta *api.SessionMetadata) []byte {
+	start := bytes.Index(data, []byte("<svg"))
+	if start < 0 {
+		return data
+	}
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return data
+	}
+	insertAt := start + end + 1
+
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return data
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(payload) + 96)
+	buf.Write(data[:insertAt])
+	buf.WriteString("\n<metadata id=\"kubectl-pprof-session\"><![CDATA[")
+	buf.Write(payload)
+	buf.WriteString("]]></metadata>")
+	buf.Write(data[insertAt:])
+	return buf.Bytes()
+}
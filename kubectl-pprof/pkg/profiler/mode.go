@@ -0,0 +1,152 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// podSecurityEnforceLabel Pod Security Admission level enforced on a namespace
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// resolveMode decides how the profiler reaches the target and explains why.
+// An explicit mode is honored; auto keeps the hostPID Job unless the Job
+// namespace rejects privileged pods or the user may not create Jobs, in which
+// case an ephemeral container is used when the cluster and RBAC allow it, and
+// the target's pprof endpoint after that. Node.js targets listening for the
+// inspector are profiled through it.
+func (p *Profiler) resolveMode(ctx context.Context, cfg *api.ProfileConfig, target *api.TargetInfo) (api.ProfileMode, string, error) {
+	// Only the Go runtime knows its heap, eBPF cannot take heap snapshots
+	if cfg.ProfileType == api.ProfileTypeHeap {
+		return api.ModePprof, "heap snapshots are read from the pprof endpoint", nil
+	}
+	// Goroutine labels live in the Go runtime, eBPF only sees threads
+	if cfg.ReadsLabels() {
+		return api.ModePprof, "goroutine labels are read from the pprof endpoint", nil
+	}
+
+	// Only a hostPID Job sees the processes of the node
+	if target.HostProcess != "" {
+		if cfg.Mode != "" && cfg.Mode != api.ModeAuto && cfg.Mode != api.ModeJob {
+			return "", "", errors.NewValidationError(
+				fmt.Sprintf("host process %s can only be profiled with --mode job", target.HostProcess),
+				"Drop --mode or use --mode job",
+			)
+		}
+		return api.ModeJob, "host processes are only reachable from a hostPID Job", nil
+	}
+
+	// The launcher only runs in the profiling Job
+	if cfg.DirectExec && (cfg.Mode == "" || cfg.Mode == api.ModeAuto) {
+		return api.ModeJob, "requested with --direct-exec", nil
+	}
+
+	// The V8 inspector samples JavaScript through a port-forward, like the pprof endpoint
+	if inspect, reason, err := inspectorMode(cfg, target); err != nil || inspect {
+		return api.ModeInspector, reason, err
+	}
+
+	switch cfg.Mode {
+	case api.ModeJob:
+		return api.ModeJob, "requested with --mode job", nil
+	case api.ModePprof:
+		return api.ModePprof, "requested with --mode pprof-endpoint", nil
+	case api.ModeEphemeral:
+		if target.StaticPod {
+			return "", "", errors.NewValidationError(
+				fmt.Sprintf("pod %s/%s is a static pod, whose mirror cannot get ephemeral containers", target.Namespace, target.PodName),
+				"Use --mode job to profile it with a privileged Job instead",
+			)
+		}
+		supported, err := p.jobManager.SupportsEphemeralContainers()
+		if err != nil {
+			return "", "", err
+		}
+		if !supported {
+			return "", "", errors.NewValidationError(
+				"the cluster does not serve the pods/ephemeralcontainers subresource",
+				"Ephemeral containers need Kubernetes >= 1.23 or the EphemeralContainers feature gate",
+				"Use --mode job to profile with a privileged Job instead",
+			)
+		}
+		return api.ModeEphemeral, "requested with --mode ephemeral", nil
+	case "", api.ModeAuto:
+	default:
+		return "", "", errors.NewValidationError(fmt.Sprintf("unknown mode %q", cfg.Mode), "Use one of: auto, job, ephemeral, pprof-endpoint")
+	}
+
+	jobNamespace := cfg.GetJobNamespace()
+	var blocker string
+	if level := p.podSecurityLevel(ctx, jobNamespace); level == "baseline" || level == "restricted" {
+		blocker = fmt.Sprintf("namespace %s enforces the %s pod security level, which rejects privileged Jobs", jobNamespace, level)
+	} else if !p.canI(ctx, jobNamespace, "create", "batch", "jobs", "") {
+		blocker = fmt.Sprintf("not allowed to create Jobs in namespace %s", jobNamespace)
+	}
+	if blocker == "" {
+		return api.ModeJob, "privileged Jobs are allowed", nil
+	}
+	mode, reason := p.fallbackMode(ctx, cfg, target, blocker, false)
+	return mode, reason, nil
+}
+
+// fallbackMode picks how to reach the target when blocker rules out the
+// privileged Job: an ephemeral container unless those were refused too, then
+// the pprof endpoint, and the Job after all when neither can be used
+func (p *Profiler) fallbackMode(ctx context.Context, cfg *api.ProfileConfig, target *api.TargetInfo, blocker string, ephemeralRefused bool) (api.ProfileMode, string) {
+	var fallback string
+	if ephemeralRefused {
+		fallback = "the ephemeral container was refused as well"
+	} else if target.StaticPod {
+		fallback = "static pods cannot get ephemeral containers"
+	} else if supported, err := p.jobManager.SupportsEphemeralContainers(); err != nil || !supported {
+		fallback = "ephemeral containers are not supported by the cluster"
+	} else if !p.canI(ctx, cfg.Namespace, "update", "", "pods", "ephemeralcontainers") {
+		fallback = "not allowed to add ephemeral containers"
+	} else {
+		return api.ModeEphemeral, blocker
+	}
+
+	// Traced profile types need eBPF, a pprof endpoint cannot measure them
+	if _, traced := tracedTitles[cfg.ProfileType]; !traced {
+		if ep, err := detectEndpoint(cfg, target); err == nil && p.canI(ctx, cfg.Namespace, "create", "", "pods", "portforward") {
+			return api.ModePprof, fmt.Sprintf("%s and %s, the pod serves pprof on port %d", blocker, fallback, ep.Port)
+		}
+	}
+	return api.ModeJob, fmt.Sprintf("%s, but %s", blocker, fallback)
+}
+
+// podSecurityLevel returns the enforced pod security level of a namespace, or
+// "" when it is unknown
+func (p *Profiler) podSecurityLevel(ctx context.Context, namespace string) string {
+	ns, err := p.k8sConfig.Clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return ns.Labels[podSecurityEnforceLabel]
+}
+
+// canI asks the API server whether the current user may perform an action.
+// Failed reviews count as allowed so that the request itself reports the error.
+func (p *Profiler) canI(ctx context.Context, namespace, verb, group, resource, subresource string) bool {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        verb,
+				Group:       group,
+				Resource:    resource,
+				Subresource: subresource,
+			},
+		},
+	}
+	result, err := p.k8sConfig.Clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return true
+	}
+	return result.Status.Allowed
+}
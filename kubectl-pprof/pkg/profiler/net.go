@@ -0,0 +1,65 @@
+package profiler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// collectNet retrieves the per remote endpoint statistics of a net profile and
+// saves them next to the flame graph
+func (p *Profiler) collectNet(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName string) (*api.NetReport, error) {
+	data, err := p.transport.ExtractNetFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract network statistics: %w", err)
+	}
+
+	report, err := parseNet(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.OutputPath != "" {
+		path := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath)) + ".net.txt"
+		if report.Path, err = writeLocalFile(path, data); err != nil {
+			return nil, fmt.Errorf("failed to save network statistics: %w", err)
+		}
+		artifactSaved(opts, "Network statistics", report.Path)
+	}
+	return report, nil
+}
+
+// parseNet parses the "<remote> <connections> <calls> <bytes_sent>
+// <bytes_received> <total_us> <max_us>" lines written by golang-profiling
+// --export-net, which are sorted by total latency already
+func parseNet(data []byte) (*api.NetReport, error) {
+	report := &api.NetReport{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var (
+			stats          api.NetEndpointStats
+			totalUs, maxUs uint64
+		)
+		if _, err := fmt.Sscanf(line, "%s %d %d %d %d %d %d", &stats.Remote, &stats.Connections, &stats.Calls,
+			&stats.BytesSent, &stats.BytesReceived, &totalUs, &maxUs); err != nil {
+			return nil, fmt.Errorf("invalid network statistics line %q: %w", line, err)
+		}
+		stats.TotalLatency = time.Duration(totalUs) * time.Microsecond
+		stats.MaxLatency = time.Duration(maxUs) * time.Microsecond
+		report.Endpoints = append(report.Endpoints, stats)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read network statistics: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,36 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// resolveTarget discovers the target pod and container, or the node of a
+// host process, which skips pod discovery entirely
+func (p *Profiler) resolveTarget(ctx context.Context, cfg *api.ProfileConfig) (*api.TargetInfo, error) {
+	if cfg.HostProcess == "" {
+		return p.discoverTarget(ctx, cfg)
+	}
+	return p.nodeTarget(ctx, cfg)
+}
+
+// nodeTarget describes a process of the node itself, such as the kubelet or
+// containerd. The Job finds it by name in the node's /proc; its namespace
+// stands in for the target's in policies, audits and the Job annotations.
+func (p *Profiler) nodeTarget(ctx context.Context, cfg *api.ProfileConfig) (*api.TargetInfo, error) {
+	if cfg.NodeName == "" {
+		return nil, fmt.Errorf("a node is required to profile the host process %s", cfg.HostProcess)
+	}
+	nodeInfo, err := p.discovery.GetNodeInfo(ctx, cfg.NodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node info: %w", err)
+	}
+	return &api.TargetInfo{
+		Namespace:   cfg.GetJobNamespace(),
+		NodeName:    cfg.NodeName,
+		NodeInfo:    nodeInfo,
+		HostProcess: cfg.HostProcess,
+	}, nil
+}
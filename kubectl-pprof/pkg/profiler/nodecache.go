@@ -0,0 +1,68 @@
+package profiler
+
+import (
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/nodecache"
+)
+
+// nodeCache returns the cache of probed nodes, nil when the session disabled it
+func nodeCache(opts *api.ProfileOptions) *nodecache.Cache {
+	if opts.NodeCacheTTL <= 0 {
+		return nil
+	}
+	return nodecache.New("", opts.NodeCacheTTL)
+}
+
+// server identifies the cluster, so that nodes of different clusters sharing
+// a name, such as kind-control-plane, keep their own entries
+func (p *Profiler) server() string {
+	if p.k8sConfig == nil || p.k8sConfig.Config == nil {
+		return ""
+	}
+	return p.k8sConfig.Config.Host
+}
+
+// useCachedPreflight hands the kernel capabilities cached for the target node
+// to the Job, which then skips probing them
+func (p *Profiler) useCachedPreflight(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) {
+	cache := nodeCache(opts)
+	if cache == nil || target.NodeInfo == nil {
+		return
+	}
+	entry, ok := cache.Get(p.server(), target.NodeName, target.NodeInfo.KernelVersion, job.RuntimeSocket(cfg, target), time.Now())
+	if !ok {
+		return
+	}
+	target.Preflight = entry.Preflight
+	opts.Log().Info("Using cached node capabilities", "node", target.NodeName, "probedAt", entry.ProbedAt.Format(time.RFC3339))
+}
+
+// cachePreflight stores the kernel capabilities a Job probed on the target
+// node. The checks are left out, they depend on the session and are
+// evaluated again by every Job; cached capabilities are not stored again, so
+// they expire with the TTL of their probe.
+func (p *Profiler) cachePreflight(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, report *api.PreflightReport) {
+	cache := nodeCache(opts)
+	if cache == nil || report == nil || target.Preflight != nil || target.NodeInfo == nil {
+		return
+	}
+	err := cache.Put(&nodecache.Entry{
+		Server:        p.server(),
+		Node:          target.NodeName,
+		KernelVersion: target.NodeInfo.KernelVersion,
+		RuntimeSocket: job.RuntimeSocket(cfg, target),
+		Preflight: &api.PreflightReport{
+			KernelVersion:     report.KernelVersion,
+			BTF:               report.BTF,
+			PerfEventParanoid: report.PerfEventParanoid,
+			CgroupVersion:     report.CgroupVersion,
+		},
+		ProbedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		opts.Log().Warn("Failed to cache node capabilities", "node", target.NodeName, "error", err)
+	}
+}
@@ -0,0 +1,171 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/inspector"
+)
+
+// inspectorBackend names the V8 CPU profiler in results
+const inspectorBackend = "v8-inspector"
+
+// detectInspector finds the inspector of the target container
+func detectInspector(cfg *api.ProfileConfig, target *api.TargetInfo) (*inspector.Endpoint, error) {
+	pod, ok := target.Pod.(*corev1.Pod)
+	if !ok || pod == nil {
+		return nil, fmt.Errorf("target pod is unknown")
+	}
+	container, ok := target.Container.(*corev1.Container)
+	if !ok || container == nil {
+		return nil, fmt.Errorf("target container is unknown")
+	}
+	var port int32
+	if cfg.NodeOptions != nil {
+		port = cfg.NodeOptions.InspectPort
+	}
+	return inspector.Detect(pod, container, port)
+}
+
+// inspectorMode decides whether a Node.js session goes through the inspector:
+// always with --attach inspector, with auto when node listens for one and
+// neither a mode that reaches the node nor --per-cpu or --annotate thread,
+// which need perf, was requested
+func inspectorMode(cfg *api.ProfileConfig, target *api.TargetInfo) (bool, string, error) {
+	if lang, err := api.ParseLanguage(cfg.Language); err != nil || lang != api.LanguageNode {
+		return false, "", nil
+	}
+	attach := cfg.GetNodeAttach()
+	if attach == api.NodeAttachPerf || target.HostProcess != "" {
+		return false, "", nil
+	}
+	if attach == api.NodeAttachAuto && (cfg.Mode == api.ModeJob || cfg.Mode == api.ModeEphemeral || cfg.PerCPU || cfg.GetAnnotate() == api.AnnotateThread) {
+		return false, "", nil
+	}
+	ep, err := detectInspector(cfg, target)
+	if err != nil {
+		if attach == api.NodeAttachInspector {
+			return false, "", err
+		}
+		return false, "", nil
+	}
+	return true, fmt.Sprintf("the V8 inspector listens on port %d, %s", ep.Port, ep.Reason), nil
+}
+
+// inspectorInterval returns the V8 sampling interval of the session frequency
+func inspectorInterval(cfg *api.ProfileConfig) time.Duration {
+	frequency := cfg.SamplingFrequency()
+	if frequency <= 0 {
+		frequency = api.DefaultFrequency
+	}
+	return time.Second / time.Duration(frequency)
+}
+
+// profileInspector runs the V8 CPU profiler of a Node.js target through a
+// port-forward to its inspector and saves the .cpuprofile next to a flame
+// graph rendered locally
+func (p *Profiler) profileInspector(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	ep, err := detectInspector(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	opts.Log().Info("Using the V8 inspector", "port", ep.Port, "reason", ep.Reason)
+
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout(cfg))
+	defer cancel()
+
+	localPort, stop, err := endpoint.Forward(ctx, p.k8sConfig.Config, p.k8sConfig.Clientset, target.Namespace, target.PodName, ep.Port)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	interval := inspectorInterval(cfg)
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingStarted, Namespace: target.Namespace, PodName: target.PodName})
+	data, err := inspector.CPUProfile(ctx, localPort, cfg.Duration, interval)
+	if err != nil {
+		return nil, err
+	}
+	opts.Emit(api.ProgressEvent{Type: api.EventSamplingFinished, Namespace: target.Namespace, PodName: target.PodName})
+
+	profile, err := inspector.ParseCPUProfile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := newSessionMetadata(cfg, target)
+	meta.Frequency = int(time.Second / interval)
+	meta.Backend = inspectorBackend
+	runCfg := withSessionSubtitle(cfg, meta)
+
+	result := &api.ProfileResult{
+		Config:   cfg,
+		Duration: cfg.Duration,
+		Success:  true,
+		Metadata: meta,
+		Language: string(api.LanguageNode),
+		Backend:  inspectorBackend,
+		JobStatus: &api.JobStatus{
+			Namespace: target.Namespace,
+			PodName:   target.PodName,
+			Phase:     api.JobPhaseSucceeded,
+		},
+	}
+
+	// Keep the raw profile, Chrome DevTools opens it with source positions
+	base := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath))
+	if result.CPUProfilePath, err = writeLocalFile(base+".cpuprofile", data); err != nil {
+		return nil, fmt.Errorf("failed to save CPU profile: %w", err)
+	}
+	artifactSaved(opts, "V8 CPU profile", result.CPUProfilePath)
+
+	renderOpts := renderOptions(runCfg.GoOptions)
+	if runCfg.GoOptions.Title == "" {
+		renderOpts.Title = "Node.js CPU Profile"
+	}
+	if runCfg.GoOptions.Colors == "" {
+		renderOpts.Colors = "js"
+	}
+	graph, err := renderProfile(profile, renderOpts, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render flame graph: %w", err)
+	}
+	graph = embedSVGMetadata(graph, meta)
+	if err := p.saveOutputFile(cfg, opts, graph); err != nil {
+		return nil, fmt.Errorf("failed to save output file: %w", err)
+	}
+	result.OutputPath = cfg.OutputPath
+	result.FileSize = int64(len(graph))
+
+	if cfg.GoOptions != nil && cfg.GoOptions.ExportFolded != "" {
+		var folded bytes.Buffer
+		if err := flamegraph.WriteFolded(&folded, profile); err != nil {
+			return nil, fmt.Errorf("failed to export folded stacks: %w", err)
+		}
+		foldedPath := cfg.GoOptions.ExportFolded
+		if !filepath.IsAbs(foldedPath) {
+			foldedPath = filepath.Join(filepath.Dir(cfg.OutputPath), foldedPath)
+		}
+		if result.FoldedPath, err = writeLocalFile(foldedPath, folded.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to save folded stacks: %w", err)
+		}
+		artifactSaved(opts, "Folded stacks", result.FoldedPath)
+	}
+
+	if cfg.ExportSummary != "" {
+		if result.SummaryPath, err = writeSummary(cfg, opts, profile, "", result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,30 @@
+package profiler
+
+import (
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// openShiftSecurityGroup serves SecurityContextConstraints, only OpenShift has it
+const openShiftSecurityGroup = "security.openshift.io"
+
+// resolveOpenShift settles whether the Job is built for OpenShift when
+// --openshift was not given, from the API groups the cluster serves. A failed
+// discovery counts as a regular cluster.
+func (p *Profiler) resolveOpenShift(cfg *api.ProfileConfig, opts *api.ProfileOptions) {
+	if cfg.OpenShift != nil {
+		return
+	}
+	detected := false
+	if groups, err := p.k8sConfig.Clientset.Discovery().ServerGroups(); err == nil {
+		for _, group := range groups.Groups {
+			if group.Name == openShiftSecurityGroup {
+				detected = true
+				break
+			}
+		}
+	}
+	cfg.OpenShift = &detected
+	if detected {
+		opts.Log().Info("OpenShift detected, the Job requests an SCC and uses CRI-O", "scc", cfg.GetSCC())
+	}
+}
@@ -0,0 +1,163 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// Per-sample costs of the eBPF profiler. Every CPU of the node takes the timer
+// interrupt and runs the PID filter; samples that hit the target additionally
+// walk the user stack and update the stack maps.
+const (
+	interruptCost   = 2 * time.Microsecond
+	stackSampleCost = 20 * time.Microsecond
+)
+
+// Share of the node CPU capacity above which profiling warns or is refused
+const (
+	overheadWarnPercent   = 1.0
+	overheadRefusePercent = 5.0
+)
+
+// Share of CFS periods the profiler pod may be throttled in before its
+// profile is flagged, and refused. A throttled profiler falls behind reading
+// the sample maps and loses samples, which biases the flame graph toward
+// whatever ran while it kept up.
+const (
+	profilerThrottleWarnPercent   = 5.0
+	profilerThrottleRefusePercent = 25.0
+)
+
+// estimateOverhead predicts the sampling cost on the target node from the
+// frequency, the duration and the number of threads expected to be on CPU.
+// It returns nil when the node CPU capacity is unknown.
+func estimateOverhead(cfg *api.ProfileConfig, target *api.TargetInfo) *api.OverheadReport {
+	nodeCPUs := nodeCPUCount(target.NodeInfo)
+	if nodeCPUs <= 0 || cfg.Duration <= 0 {
+		return nil
+	}
+	// Tracepoints cost per context switch or syscall, not per sample, and
+	// those rates are not known up front
+	if _, traced := tracedTitles[cfg.ProfileType]; traced {
+		return nil
+	}
+
+	frequency := defaultFrequency
+	if cfg.GoOptions != nil && cfg.GoOptions.Frequency > 0 {
+		frequency = cfg.GoOptions.Frequency
+	}
+
+	// A Go program runs at most GOMAXPROCS threads at once, which follows the
+	// CPU limit of its container when the runtime honors it
+	threads := nodeCPUs
+	if limit := containerCPULimit(target); limit > 0 && limit < threads {
+		threads = limit
+	}
+
+	seconds := cfg.Duration.Seconds()
+	samples := int64(float64(frequency) * seconds * float64(threads))
+	interrupts := int64(float64(frequency) * seconds * float64(nodeCPUs))
+	cpu := time.Duration(samples)*stackSampleCost + time.Duration(interrupts)*interruptCost
+
+	report := &api.OverheadReport{
+		Frequency:           frequency,
+		ExpectedThreads:     threads,
+		NodeCPUs:            nodeCPUs,
+		ExpectedSamples:     samples,
+		EstimatedCPU:        cpu,
+		EstimatedCPUPercent: 100 * cpu.Seconds() / (seconds * float64(nodeCPUs)),
+	}
+	if report.EstimatedCPUPercent >= overheadWarnPercent {
+		report.Warning = fmt.Sprintf("sampling %d threads at %d Hz for %v is estimated to use %.1f%% of the %d CPUs of node %s (%v CPU time, %d samples)",
+			threads, frequency, cfg.Duration, report.EstimatedCPUPercent, nodeCPUs, target.NodeName, cpu.Round(time.Millisecond), samples)
+	}
+	return report
+}
+
+// checkOverhead refuses to profile when the estimated overhead is too high,
+// unless forced
+func checkOverhead(report *api.OverheadReport, force bool) error {
+	if report == nil || force || report.EstimatedCPUPercent < overheadRefusePercent {
+		return nil
+	}
+	return errors.NewValidationError(
+		fmt.Sprintf("refusing to profile: %s, above the %.0f%% limit", report.Warning, overheadRefusePercent),
+		"Lower the sampling frequency with --frequency",
+		"Shorten the profiling duration with --duration",
+		"Pass --force to profile anyway",
+	)
+}
+
+// recordProfilerUsage adds the CPU time, memory and throttling the profiler
+// pod measured of itself to the report
+func recordProfilerUsage(report *api.OverheadReport, measured *api.OverheadReport, duration time.Duration) *api.OverheadReport {
+	if measured == nil {
+		return report
+	}
+	if report == nil {
+		report = &api.OverheadReport{}
+	}
+	report.ProfilerCPU = measured.ProfilerCPU
+	if duration > 0 {
+		report.ProfilerCPUPercent = 100 * measured.ProfilerCPU.Seconds() / duration.Seconds()
+	}
+	report.ProfilerMemory = measured.ProfilerMemory
+	report.ProfilerThrottling = measured.ProfilerThrottling
+	return report
+}
+
+// checkProfilerThrottling flags a profile taken by a throttled profiler and
+// refuses it, unless forced, when the throttling was bad enough to bias it
+func checkProfilerThrottling(report *api.OverheadReport, force bool) error {
+	if report == nil || report.ProfilerThrottling == nil {
+		return nil
+	}
+	throttling := report.ProfilerThrottling
+	if throttling.Periods == 0 || throttling.ThrottledPercent <= profilerThrottleWarnPercent {
+		return nil
+	}
+	throttling.Warning = fmt.Sprintf("the profiler pod was CPU throttled in %.1f%% of CFS periods (%d of %d, %v held back) and may have lost samples",
+		throttling.ThrottledPercent, throttling.ThrottledPeriods, throttling.Periods, throttling.ThrottledTime.Round(time.Millisecond))
+	if force || throttling.ThrottledPercent < profilerThrottleRefusePercent {
+		return nil
+	}
+	return errors.NewValidationError(
+		fmt.Sprintf("refusing the profile: %s, above the %.0f%% limit", throttling.Warning, profilerThrottleRefusePercent),
+		"Raise the CPU limit of the profiler with --cpu-limit",
+		"Lower the sampling frequency with --frequency",
+		"Pass --force to accept the profile anyway",
+	)
+}
+
+// nodeCPUCount returns the CPU capacity of the node, rounded up
+func nodeCPUCount(node *api.NodeInfo) int {
+	if node == nil {
+		return 0
+	}
+	quantity, err := resource.ParseQuantity(node.Capacity[string(corev1.ResourceCPU)])
+	if err != nil {
+		return 0
+	}
+	return int(math.Ceil(float64(quantity.MilliValue()) / 1000))
+}
+
+// containerCPULimit returns the CPU limit of the target container, rounded up,
+// or 0 when it has none
+func containerCPULimit(target *api.TargetInfo) int {
+	container, ok := target.Container.(*corev1.Container)
+	if !ok || container == nil {
+		return 0
+	}
+	limit := container.Resources.Limits.Cpu()
+	if limit.IsZero() {
+		return 0
+	}
+	return int(math.Ceil(float64(limit.MilliValue()) / 1000))
+}
@@ -0,0 +1,114 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+const (
+	// peggedBusy is how busy a CPU must be to count as pegged
+	peggedBusy = 0.9
+	// peggedOthersBusy is how busy the other CPUs may be on average at most
+	peggedOthersBusy = 0.2
+)
+
+// collectCPUBreakdown splits the on-CPU samples of a --per-cpu profile by the
+// cpuN frame at the root of their stacks
+func (p *Profiler) collectCPUBreakdown(ctx context.Context, cfg *api.ProfileConfig, jobName string) (*api.CPUBreakdownReport, error) {
+	data, err := p.transport.ExtractFoldedFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract folded stacks: %w", err)
+	}
+	profile, err := flamegraph.ParseFolded(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse folded stacks: %w", err)
+	}
+	return cpuBreakdown(profile, float64(cfg.SamplingFrequency())*cfg.Duration.Seconds()), nil
+}
+
+// cpuBreakdown sums the samples of every cpuN root frame and the leaf frame
+// each CPU spent most of them in. expected is the number of samples a CPU
+// running the target for the whole window yields.
+func cpuBreakdown(profile *flamegraph.Profile, expected float64) *api.CPUBreakdownReport {
+	samples := make(map[int]int64)
+	leaves := make(map[int]map[string]int64)
+	for _, sample := range profile.Samples {
+		if len(sample.Stack) < 2 {
+			continue
+		}
+		cpu, ok := parseCPUFrame(sample.Stack[0])
+		if !ok {
+			continue
+		}
+		samples[cpu] += sample.Value
+		if leaves[cpu] == nil {
+			leaves[cpu] = make(map[string]int64)
+		}
+		leaves[cpu][sample.Stack[len(sample.Stack)-1]] += sample.Value
+	}
+
+	report := &api.CPUBreakdownReport{}
+	for cpu, count := range samples {
+		entry := api.CPUSamples{CPU: cpu, Samples: count}
+		if expected > 0 {
+			entry.Busy = float64(count) / expected
+		}
+		var top int64
+		for frame, value := range leaves[cpu] {
+			if value > top || value == top && frame < entry.TopFrame {
+				entry.TopFrame, top = frame, value
+			}
+		}
+		entry.TopShare = float64(top) / float64(count)
+		report.CPUs = append(report.CPUs, entry)
+	}
+	sort.Slice(report.CPUs, func(i, j int) bool {
+		if report.CPUs[i].Samples != report.CPUs[j].Samples {
+			return report.CPUs[i].Samples > report.CPUs[j].Samples
+		}
+		return report.CPUs[i].CPU < report.CPUs[j].CPU
+	})
+	report.Pegged = peggedCPU(report.CPUs, expected)
+	return report
+}
+
+// peggedCPU describes the busiest CPU when it ran the target nearly all the
+// window while the other CPUs the target ran on mostly idled
+func peggedCPU(cpus []api.CPUSamples, expected float64) string {
+	if len(cpus) == 0 || expected <= 0 || cpus[0].Busy < peggedBusy {
+		return ""
+	}
+	top := cpus[0]
+	var others float64
+	for _, cpu := range cpus[1:] {
+		others += cpu.Busy
+	}
+	if len(cpus) > 1 {
+		others /= float64(len(cpus) - 1)
+	}
+	if others >= peggedOthersBusy {
+		return ""
+	}
+	return fmt.Sprintf("cpu%d was busy %.0f%% of the window, %.0f%% of it in %s, while the other CPUs averaged %.0f%%",
+		top.CPU, min(top.Busy, 1)*100, top.TopShare*100, top.TopFrame, others*100)
+}
+
+// parseCPUFrame reads the CPU number of a cpuN root frame
+func parseCPUFrame(frame string) (int, bool) {
+	digits, ok := strings.CutPrefix(frame, "cpu")
+	if !ok {
+		return 0, false
+	}
+	cpu, err := strconv.Atoi(digits)
+	if err != nil || cpu < 0 {
+		return 0, false
+	}
+	return cpu, true
+}
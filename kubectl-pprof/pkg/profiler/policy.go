@@ -0,0 +1,41 @@
+package profiler
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/policy"
+)
+
+// checkPolicy fails the session early when it breaks the profiling policy of
+// the target namespace, rather than leaving the admission webhook to reject
+// its Job. Users who may not read namespaces are left to the webhook.
+func (p *Profiler) checkPolicy(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) error {
+	if p.k8sConfig == nil || p.k8sConfig.Clientset == nil {
+		return nil
+	}
+	client := p.k8sConfig.Clientset
+
+	pol, err := policy.ForNamespace(ctx, client, target.Namespace)
+	if apierrors.IsForbidden(err) {
+		opts.Log().Warn("Cannot read the profiling policy of the namespace, skipping the check", "namespace", target.Namespace)
+		return nil
+	}
+	if err != nil || pol == nil {
+		return err
+	}
+
+	active := 0
+	if pol.MaxConcurrent > 0 {
+		if active, err = policy.ActiveSessions(ctx, client, target.Namespace); err != nil {
+			if !apierrors.IsForbidden(err) {
+				return err
+			}
+			opts.Log().Warn("Cannot count the profiling sessions of the namespace, skipping the concurrency check", "namespace", target.Namespace)
+		}
+	}
+	return pol.Check(policy.Session{Duration: cfg.Duration, Frequency: cfg.SamplingFrequency()}, time.Now(), active)
+}
@@ -2,85 +2,378 @@ package profiler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/withlin/kubectl-pprof/internal/types"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/audit"
 	"github.com/withlin/kubectl-pprof/pkg/config"
 	"github.com/withlin/kubectl-pprof/pkg/discovery"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
 	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/metrics"
 )
 
 // Profiler performance analyzer
 type Profiler struct {
 	k8sConfig *config.KubernetesConfig
-	discovery *discovery.Discovery
-	jobManager *job.Manager
+	discovery  TargetDiscovery
+	jobManager JobRunner
+	transport  ResultTransport
+
+	// Defaults for sessions whose options bring none
+	logger   *slog.Logger
+	progress api.ProgressFunc
+	metrics  *metrics.Metrics
+	auditor  *audit.Auditor
+}
+
+// Option customizes a Profiler
+type Option func(*Profiler)
+
+// WithLogger logs the sessions whose options set no Logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Profiler) { p.logger = logger }
+}
+
+// WithProgress reports the progress of sessions whose options set no Progress
+func WithProgress(progress api.ProgressFunc) Option {
+	return func(p *Profiler) { p.progress = progress }
+}
+
+// WithMetrics counts every session, e.g. to serve them on /metrics
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(p *Profiler) { p.metrics = m }
 }
 
-// NewProfiler creates a new performance analyzer
-func NewProfiler(k8sConfig *config.KubernetesConfig) (*Profiler, error) {
+// NewProfiler creates a new performance analyzer. The Kubernetes client is
+// only used through kubernetes.Interface, so a fake clientset can stand in
+// for a cluster.
+func NewProfiler(k8sConfig *config.KubernetesConfig, options ...Option) (*Profiler, error) {
+	p := &Profiler{
+		k8sConfig: k8sConfig,
+	}
+	if k8sConfig != nil && k8sConfig.Clientset != nil {
+		p.auditor = audit.NewAuditor(k8sConfig.Clientset)
+	}
+	for _, option := range options {
+		option(p)
+	}
+
 	// Create discovery service
-	discoveryService, err := discovery.NewDiscovery(k8sConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create discovery service: %w", err)
+	if p.discovery == nil {
+		discoveryService, err := discovery.NewDiscovery(k8sConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discovery service: %w", err)
+		}
+		p.discovery = discoveryService
 	}
 
-	// Create Job manager
-	jobManager, err := job.NewManager(k8sConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create job manager: %w", err)
+	// Create Job manager, which also fetches the artifacts from the logs
+	if p.jobManager == nil || p.transport == nil {
+		jobManager, err := job.NewManager(k8sConfig, job.WithLogger(p.logger))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create job manager: %w", err)
+		}
+		if p.jobManager == nil {
+			p.jobManager = jobManager
+		}
+		if p.transport == nil {
+			p.transport = jobManager
+		}
 	}
+	return p, nil
+}
 
-	return &Profiler{
-		k8sConfig: k8sConfig,
-		discovery: discoveryService,
-		jobManager: jobManager,
-	}, nil
+// sessionOptions copies the options of a session, filling in the defaults of
+// the Profiler; nil options mean api.DefaultProfileOptions
+func (p *Profiler) sessionOptions(opts *api.ProfileOptions) *api.ProfileOptions {
+	if opts == nil {
+		opts = api.DefaultProfileOptions()
+	}
+	session := *opts
+	if session.Logger == nil {
+		session.Logger = p.logger
+	}
+	if session.Progress == nil {
+		session.Progress = p.progress
+	}
+	return &session
 }
 
 // Profile executes performance analysis
-func (p *Profiler) Profile(ctx context.Context, cfg *types.ProfileConfig, opts *types.ProfileOptions) (*types.ProfileResult, error) {
+func (p *Profiler) Profile(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions) (*api.ProfileResult, error) {
+	p.metrics.ProfileStarted(cfg.ProfileType)
+	start := time.Now()
+	result, err := p.profile(ctx, cfg, opts)
+
+	// Retry in a mode the cluster admits when it refused the profiler
+	auto := cfg.Mode == "" || cfg.Mode == api.ModeAuto
+	for fallbacks := 0; fallbacks < maxAdmissionFallbacks; fallbacks++ {
+		var rejected *job.AdmissionRejectedError
+		if !errors.As(err, &rejected) {
+			break
+		}
+		mode, fallbackErr := p.admissionFallback(ctx, cfg, p.sessionOptions(opts), rejected, auto)
+		if fallbackErr != nil {
+			err = fallbackErr
+			break
+		}
+		rcfg := *cfg
+		rcfg.Mode = mode
+		cfg = &rcfg
+		result, err = p.profile(ctx, cfg, opts)
+	}
+
+	// Follow a lost Deployment target to its replacement pod
+	for retargets := 0; cfg.Retarget && retargets < maxRetargets; retargets++ {
+		var lost *TargetLostError
+		if !errors.As(err, &lost) || lost.Deployment == "" {
+			break
+		}
+		pod, retargetErr := p.replacementPod(ctx, lost)
+		if retargetErr != nil {
+			err = fmt.Errorf("%w, not retargeted: %v", err, retargetErr)
+			break
+		}
+		p.sessionOptions(opts).Log().Warn("Target lost, profiling its replacement", "reason", lost.Reason, "pod", pod)
+		rcfg := *cfg
+		rcfg.PodName = pod
+		cfg = &rcfg
+		result, err = p.profile(ctx, cfg, opts)
+	}
+
+	p.metrics.ProfileFinished(cfg.ProfileType, time.Since(start), result, err)
+	return result, err
+}
+
+// profile runs a single session
+func (p *Profiler) profile(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions) (*api.ProfileResult, error) {
+	opts = p.sessionOptions(opts)
+
 	// 1. Discover target container
-	targetInfo, err := p.discoverTarget(ctx, cfg)
+	targetInfo, err := p.resolveTarget(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover target: %w", err)
 	}
 
+	if targetInfo.SelectionReason != "" {
+		opts.Log().Info("Selected container", "container", targetInfo.ContainerName, "reason", targetInfo.SelectionReason)
+	}
+
+	mode, reason, err := p.resolveMode(ctx, cfg, targetInfo)
+	if err != nil {
+		return nil, err
+	}
+	opts.Log().Info("Profiling mode", "mode", mode, "reason", reason)
+	opts.Emit(api.ProgressEvent{
+		Type:      api.EventTargetResolved,
+		Namespace: targetInfo.Namespace,
+		PodName:   targetInfo.PodName,
+		Container: targetInfo.ContainerName,
+		NodeName:  targetInfo.NodeName,
+		Mode:      mode,
+	})
+	if err := p.checkPolicy(ctx, cfg, opts, targetInfo); err != nil {
+		return nil, err
+	}
+	p.auditSession(ctx, cfg, opts, targetInfo, mode)
+
+	// Abort once the target restarts or goes away, its samples would be garbage
+	guardCtx, stopGuard := p.guardTarget(ctx, opts, targetInfo)
+	defer stopGuard()
+
+	// Contention profiles are fetched alongside the main profile; stop them
+	// when the session fails
+	contentionCtx, cancelContention := context.WithCancel(ctx)
+	defer cancelContention()
+
+	// The inspector needs neither the node nor perf
+	if mode == api.ModeInspector {
+		result, err := p.profileInspector(guardCtx, cfg, opts, targetInfo)
+		if lost := lostTarget(guardCtx); lost != nil {
+			return nil, lost
+		}
+		return result, err
+	}
+
+	// The pprof endpoint needs neither the node nor eBPF
+	if mode == api.ModePprof {
+		profileEndpoint := p.profileEndpoint
+		if cfg.ProfileType == api.ProfileTypeHeap {
+			profileEndpoint = p.profileHeapGrowth
+		}
+		waitContention := p.startContention(contentionCtx, cfg, opts, targetInfo)
+		result, err := profileEndpoint(guardCtx, cfg, opts, targetInfo)
+		if lost := lostTarget(guardCtx); lost != nil {
+			return nil, lost
+		}
+		if err != nil {
+			return nil, err
+		}
+		stopGuard()
+		p.attachContention(waitContention, cfg, opts, result)
+		p.attachGoroutines(ctx, cfg, opts, targetInfo, result)
+		return result, nil
+	}
+
+	if mode == api.ModeJob {
+		p.resolveOpenShift(cfg, opts)
+	}
+
+	// Refuse nodes the profiling image cannot run on before creating anything
+	if err := job.CheckNodeCompatibility(targetInfo.NodeInfo); err != nil {
+		return nil, err
+	}
+	if err := job.CheckNodePressure(targetInfo.NodeInfo); err != nil {
+		if !cfg.Force {
+			return nil, err
+		}
+		opts.Log().Warn("Profiling a node under pressure, the profiler may be evicted", "node", targetInfo.NodeName)
+	}
+
+	// Estimate the observer effect and refuse expensive sessions unless forced
+	overhead := estimateOverhead(cfg, targetInfo)
+	if overhead != nil && overhead.Warning != "" {
+		opts.Log().Warn(overhead.Warning)
+	}
+	if err := checkOverhead(overhead, cfg.Force); err != nil {
+		return nil, err
+	}
+
+	// Skip probing a node whose kernel capabilities a recent run cached
+	p.useCachedPreflight(cfg, opts, targetInfo)
+
+	// Record session metadata so the artifact stays interpretable later
+	meta := newSessionMetadata(cfg, targetInfo)
+
+	runCfg := withSessionSubtitle(cfg, meta)
+	if title, traced := tracedTitles[cfg.ProfileType]; traced && runCfg.GoOptions.Title == "" {
+		runCfg.GoOptions.Title = title
+	}
+
+	// Formats other than SVG are rendered locally from the raw stacks
+	if opts.OutputFormat != "" && opts.OutputFormat != "svg" && opts.OutputFormat != "json" {
+		runCfg.GoOptions.ClientRender = true
+	}
+
+	// Live sessions keep the output file up to date while sampling continues
+	if cfg.Live && opts.Snapshot == nil && opts.OutputFormat != "json" {
+		if err := checkOverwrite(runCfg); err != nil {
+			return nil, err
+		}
+		// The snapshots and the final result replace the file of the session
+		runCfg.Overwrite = true
+		opts.Snapshot = liveRenderer(runCfg, opts)
+	}
+
+	// Leave the Job running, `kubectl pprof collect` fetches its results
+	if cfg.NoCollect {
+		return p.startDetached(ctx, mode, runCfg, opts, targetInfo, meta)
+	}
+
+	// Bracket the window with runtime metrics to correlate CPU with GC behavior
+	runtimeStart := p.runtimeSnapshot(ctx, cfg, opts, targetInfo)
+	waitContention := p.startContention(contentionCtx, cfg, opts, targetInfo)
+	stopUsage := p.startUsage(ctx, cfg, opts, targetInfo)
+
 	// 2. 创建并执行分析Job
-	jobResult, err := p.executeProfilingJob(ctx, cfg, opts, targetInfo)
+	jobResult, err := p.executeProfilingJob(guardCtx, mode, runCfg, opts, targetInfo)
+	if stopUsage != nil {
+		meta.ResourceUsage = stopUsage()
+	}
+	if lost := lostTarget(guardCtx); lost != nil {
+		return nil, lost
+	}
+	stopGuard()
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute profiling job: %w", err)
 	}
+	// The Job is cleaned up once its results are read from its logs, a
+	// session failing meanwhile as a failed Job
+	succeeded := false
+	defer func() {
+		if cfg.RetainJob(succeeded) {
+			return
+		}
+		if err := p.cleanup(ctx, jobResult.JobName, cfg.GetJobNamespace()); err != nil {
+			// 记录清理错误但不影响主流程
+			opts.Log().Warn("Failed to cleanup resources", "error", err)
+		}
+	}()
+	p.cachePreflight(cfg, opts, targetInfo, jobResult.Preflight)
+	if runtimeStart != nil {
+		if runtimeEnd := p.runtimeSnapshot(ctx, cfg, opts, targetInfo); runtimeEnd != nil {
+			meta.Runtime = endpoint.RuntimeReport(runtimeStart, runtimeEnd)
+		}
+	}
+	checkThrottling(jobResult.Throttling, cfg.ThrottleWarnPercent)
+	meta.Throttling = jobResult.Throttling
+	meta.Backend = jobResult.Backend
+	meta.Methodology = jobResult.Methodology
+	meta.BuildInfo = jobResult.BuildInfo
+	jobResult.Metadata = meta
+	sampled := cfg.Duration
+	if target := jobResult.SampleTarget; target != nil {
+		// A sample target may have stopped sampling before the duration
+		sampled = target.Elapsed
+		jobResult.Duration = target.Elapsed
+		jobResult.Samples = target.Samples
+		opts.Log().Info("Sampling stopped", "samples", target.Samples, "minSamples", target.MinSamples, "elapsed", target.Elapsed.Round(time.Second), "reached", target.Reached)
+	}
+	jobResult.Overhead = recordProfilerUsage(overhead, jobResult.Overhead, sampled)
+	if err := checkProfilerThrottling(jobResult.Overhead, cfg.Force); err != nil {
+		return nil, err
+	}
+	if jobResult.Overhead != nil && jobResult.Overhead.ProfilerThrottling != nil && jobResult.Overhead.ProfilerThrottling.Warning != "" {
+		opts.Log().Warn(jobResult.Overhead.ProfilerThrottling.Warning)
+	}
+	if stats := jobResult.SampleStats; stats != nil {
+		jobResult.Samples = stats.Samples
+		opts.Log().Info("Samples collected", "samples", stats.Samples, "dropped", stats.Dropped, "lostStacks", stats.LostStacks,
+			"truncated", stats.Truncated, "frames", stats.Frames, "unsymbolized", stats.Unsymbolized)
+	}
+
+	recordSession(runCfg, opts, targetInfo, jobResult)
 
 	// 3. 收集结果
-	result, err := p.collectResults(ctx, cfg, jobResult)
+	result, err := p.collectResults(ctx, runCfg, opts, jobResult)
 	if err != nil {
 		return nil, fmt.Errorf("failed to collect results: %w", err)
 	}
+	p.attachContention(waitContention, cfg, opts, result)
+	p.attachGoroutines(ctx, cfg, opts, targetInfo, result)
 
 	// 4. 清理资源
-	if cfg.Cleanup {
-		if err := p.cleanup(ctx, result.JobName, cfg.Namespace); err != nil {
-			// 记录清理错误但不影响主流程
-			fmt.Printf("Warning: failed to cleanup resources: %v\n", err)
-		}
-	}
-
+	succeeded = result.Success
 	return result, nil
 }
 
 // discoverTarget discovers target container
-func (p *Profiler) discoverTarget(ctx context.Context, cfg *types.ProfileConfig) (*types.TargetInfo, error) {
+func (p *Profiler) discoverTarget(ctx context.Context, cfg *api.ProfileConfig) (*api.TargetInfo, error) {
 	// Find Pod
 	pod, err := p.discovery.FindPod(ctx, cfg.Namespace, cfg.PodName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find pod: %w", err)
 	}
 
-	// Find container
-	container, err := p.discovery.FindContainer(pod, cfg.ContainerName)
+	// Find container, skipping well-known sidecars when none was named
+	var (
+		container       *corev1.Container
+		selectionReason string
+	)
+	if cfg.ContainerName == "" {
+		container, selectionReason, err = discovery.SelectContainer(pod, cfg.Strict)
+	} else {
+		container, err = p.discovery.FindContainer(pod, cfg.ContainerName)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find container: %w", err)
 	}
@@ -100,24 +393,41 @@ func (p *Profiler) discoverTarget(ctx context.Context, cfg *types.ProfileConfig)
 	// Ensure using the actual found container name
 	actualContainerName := cfg.ContainerName
 	if actualContainerName == "" && container != nil {
-		// If no container name specified, use the first found container name
+		// If no container name specified, use the selected container name
 		actualContainerName = container.Name
 	}
 
-	return &types.TargetInfo{
-		Namespace:     cfg.Namespace,
-		PodName:       cfg.PodName,
-		ContainerName: actualContainerName,
-		NodeName:      pod.Spec.NodeName,
-		Pod:           pod,
-		Container:     container,
-		NodeInfo:      nodeInfo,
-		RuntimeInfo:   runtimeInfo,
+	// The container and pod IDs let the Job find the process in /proc when
+	// the runtime cannot, as for host PID and static pods
+	return &api.TargetInfo{
+		Namespace:       cfg.Namespace,
+		PodName:         cfg.PodName,
+		ContainerName:   actualContainerName,
+		NodeName:        pod.Spec.NodeName,
+		PodUID:          string(pod.UID),
+		ContainerID:     runtimeInfo.ContainerID,
+		Command:         container.Command,
+		Pod:             pod,
+		Container:       container,
+		NodeInfo:        nodeInfo,
+		RuntimeInfo:     runtimeInfo,
+		SelectionReason: selectionReason,
+		StaticPod:       discovery.IsStaticPod(pod),
+		CgroupUID:       discovery.CgroupPodUID(pod),
 	}, nil
 }
 
 // executeProfilingJob executes profiling Job
-func (p *Profiler) executeProfilingJob(ctx context.Context, cfg *types.ProfileConfig, opts *types.ProfileOptions, target *types.TargetInfo) (*types.ProfileResult, error) {
+func (p *Profiler) executeProfilingJob(ctx context.Context, mode api.ProfileMode, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) (*api.ProfileResult, error) {
+	if mode == api.ModeEphemeral {
+		// Inject the profiler into the target pod and wait for it to exit
+		result, err := p.jobManager.CreateEphemeralProfiler(ctx, cfg, opts, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run ephemeral profiler: %w", err)
+		}
+		return result, nil
+	}
+
 	// Create Job and wait for completion
 	result, err := p.jobManager.CreateProfilingJobWithMonitoring(ctx, cfg, opts, target)
 	if err != nil {
@@ -128,39 +438,45 @@ func (p *Profiler) executeProfilingJob(ctx context.Context, cfg *types.ProfileCo
 }
 
 // collectResults collects analysis results (simplified version, from logs)
-func (p *Profiler) collectResults(ctx context.Context, cfg *types.ProfileConfig, result *types.ProfileResult) (*types.ProfileResult, error) {
+func (p *Profiler) collectResults(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, result *api.ProfileResult) (*api.ProfileResult, error) {
+	// A profile too thin to read is explained instead of saved as a blank graph;
+	// off-CPU samples still fill the graph when on-CPU ones are missing
+	if diagnosis := diagnoseSamples(cfg, result); diagnosis != nil {
+		result.Diagnosis = diagnosis
+		if diagnosis.Samples == 0 && (cfg.GoOptions == nil || !cfg.GoOptions.OffCPU) {
+			return nil, &EmptyProfileError{Diagnosis: diagnosis}
+		}
+		opts.Log().Warn("Few on-CPU samples captured, the flame graph may be misleading", "samples", diagnosis.Samples, "expected", diagnosis.Expected)
+	}
+
 	// Extract actual flame graph content from Job logs
-	flameGraphData, err := p.jobManager.ExtractFlameGraphFromLogs(ctx, result.JobName, cfg.Namespace)
+	flameGraphData, err := p.transport.ExtractFlameGraphFromLogs(ctx, result.JobName, cfg.GetJobNamespace())
 	if err != nil {
-		// If extraction fails, create an error SVG with red X
-		errorSVG := `<?xml version="1.0" encoding="UTF-8"?>
-<svg xmlns="http://www.w3.org/2000/svg" width="500" height="300" viewBox="0 0 500 300">
-  <!-- 背景 -->
-  <rect width="500" height="300" fill="#f8f9fa" stroke="#dee2e6" stroke-width="2"/>
-  
-  <!-- Red X mark -->
-  <g transform="translate(250,100)">
-    <circle cx="0" cy="0" r="50" fill="#dc3545" stroke="#b02a37" stroke-width="3"/>
-    <line x1="-25" y1="-25" x2="25" y2="25" stroke="white" stroke-width="6" stroke-linecap="round"/>
-    <line x1="25" y1="-25" x2="-25" y2="25" stroke="white" stroke-width="6" stroke-linecap="round"/>
-  </g>
-  
-  <!-- Failure message text -->
-  <text x="250" y="200" text-anchor="middle" font-family="Arial, sans-serif" font-size="24" font-weight="bold" fill="#dc3545">
-    Flame Graph Generation Failed
-  </text>
-  <text x="250" y="230" text-anchor="middle" font-family="Arial, sans-serif" font-size="14" fill="#6c757d">
-    Failed to extract flamegraph from logs
-  </text>
-  <text x="250" y="250" text-anchor="middle" font-family="Arial, sans-serif" font-size="12" fill="#6c757d">
-    Error: ` + err.Error() + `
-  </text>
-</svg>`
-		flameGraphData = []byte(errorSVG)
+		if !opts.AllowPartial {
+			return nil, fmt.Errorf("failed to extract flamegraph from logs: %w", err)
+		}
+		// Interactive users may prefer a placeholder to nothing at all
+		opts.Log().Warn("Failed to extract the flame graph, saving a placeholder", "error", err)
+		flameGraphData = placeholderSVG(err)
+		result.Error = err.Error()
+		result.Success = false
+	}
+
+	// Re-render from the raw stacks so the visual options are applied locally
+	if cfg.GoOptions != nil && cfg.GoOptions.ClientRender {
+		rendered, err := p.renderLocally(ctx, cfg, opts, result.JobName, result.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render flame graph locally: %w", err)
+		}
+		flameGraphData = rendered
+	}
+
+	if result.Metadata != nil {
+		flameGraphData = embedSVGMetadata(flameGraphData, result.Metadata)
 	}
 	
 	if cfg.OutputPath != "" {
-		if err := p.saveOutputFile(cfg.OutputPath, flameGraphData); err != nil {
+		if err := p.saveOutputFile(cfg, opts, flameGraphData); err != nil {
 			return nil, fmt.Errorf("failed to save output file: %w", err)
 		}
 		
@@ -168,13 +484,177 @@ func (p *Profiler) collectResults(ctx context.Context, cfg *types.ProfileConfig,
 		result.FileSize = int64(len(flameGraphData))
 	}
 
+	if len(cfg.AlsoFormats) > 0 {
+		paths, err := p.collectConversions(ctx, cfg, opts, result)
+		if err != nil {
+			return nil, err
+		}
+		result.ConvertedPaths = paths
+	}
+
+	if cfg.GoOptions != nil && cfg.GoOptions.ExportFolded != "" {
+		foldedPath, err := p.collectFolded(ctx, cfg, opts, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.FoldedPath = foldedPath
+	}
+
+	if cfg.GoOptions != nil && cfg.GoOptions.FlameChart && cfg.OutputPath != "" {
+		timelinePath, err := p.collectTimeline(ctx, cfg, opts, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.TimelinePath = timelinePath
+	}
+
+	if cfg.List != "" {
+		listing, err := p.collectListing(ctx, cfg, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.Listing = listing
+	}
+
+	if cfg.Analyze {
+		findings, err := p.collectFindings(ctx, cfg, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.Findings = findings
+	}
+
+	if cfg.ExportSummary != "" {
+		summaryPath, err := p.collectSummary(ctx, cfg, opts, result)
+		if err != nil {
+			return nil, err
+		}
+		result.SummaryPath = summaryPath
+	}
+
+	if cfg.PerCPU {
+		report, err := p.collectCPUBreakdown(ctx, cfg, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.CPUBreakdown = report
+	}
+
+	if cfg.ProfileType == api.ProfileTypeSchedLat {
+		report, err := p.collectSchedLatency(ctx, cfg, opts, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.SchedLatency = report
+	}
+
+	if cfg.ProfileType == api.ProfileTypeNet {
+		report, err := p.collectNet(ctx, cfg, opts, result.JobName)
+		if err != nil {
+			return nil, err
+		}
+		result.Net = report
+	}
+
 	return result, nil
 }
 
+// collectTimeline retrieves the time-ordered stacks of a flame chart and saves
+// them next to the chart, so it can be re-rendered without profiling again
+func (p *Profiler) collectTimeline(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName string) (string, error) {
+	timeline, err := p.transport.ExtractTimelineFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return "", fmt.Errorf("failed to extract timeline: %w", err)
+	}
+
+	timelinePath := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath)) + ".timeline"
+	timelinePath, err = writeLocalFile(timelinePath, timeline)
+	if err != nil {
+		return "", fmt.Errorf("failed to save timeline: %w", err)
+	}
+
+	artifactSaved(opts, "Timeline", timelinePath)
+	return timelinePath, nil
+}
+
+// collectFolded retrieves the folded stacks artifact and saves it locally.
+// Relative paths are placed next to the flame graph output.
+func (p *Profiler) collectFolded(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName string) (string, error) {
+	folded, err := p.transport.ExtractFoldedFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return "", fmt.Errorf("failed to extract folded stacks: %w", err)
+	}
+
+	foldedPath := cfg.GoOptions.ExportFolded
+	if !filepath.IsAbs(foldedPath) && cfg.OutputPath != "" {
+		foldedPath = filepath.Join(filepath.Dir(cfg.OutputPath), foldedPath)
+	}
+
+	foldedPath, err = writeLocalFile(foldedPath, folded)
+	if err != nil {
+		return "", fmt.Errorf("failed to save folded stacks: %w", err)
+	}
+
+	artifactSaved(opts, "Folded stacks", foldedPath)
+	return foldedPath, nil
+}
+
 // saveOutputFile saves output file
-func (p *Profiler) saveOutputFile(outputPath string, data []byte) error {
+func (p *Profiler) saveOutputFile(cfg *api.ProfileConfig, opts *api.ProfileOptions, data []byte) error {
+	if err := checkOverwrite(cfg); err != nil {
+		return err
+	}
+	finalPath, err := writeLocalFile(cfg.OutputPath, data)
+	if err != nil {
+		return err
+	}
+
+	artifactSaved(opts, "Flamegraph", finalPath)
+	return nil
+}
+
+// OutputTimeFormat is the timestamp in the names of default outputs
+const OutputTimeFormat = "20060102-150405"
+
+// DefaultOutputPath names an output after the current time, in the current
+// directory and with the extension of the output format, so runs do not
+// overwrite each other
+func DefaultOutputPath(format string) string {
+	return "flamegraph-" + time.Now().Format(OutputTimeFormat) + OutputExtension(format)
+}
+
+// OutputExtension is the extension of the flame graphs of an output format,
+// JSON sessions still write an SVG
+func OutputExtension(format string) string {
+	if format != "svg" && format != "json" && format != "" {
+		return "." + format
+	}
+	return ".svg"
+}
+
+// checkOverwrite refuses to replace an existing output file without
+// Overwrite. The CLI checks it on start too, this catches files that appeared
+// while the session ran, e.g. hours later for a scheduled one.
+func checkOverwrite(cfg *api.ProfileConfig) error {
+	if cfg.Overwrite {
+		return nil
+	}
+	info, err := os.Stat(cfg.OutputPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("cannot check the output file: %w", err)
+	case info.IsDir():
+		return fmt.Errorf("the output path %s is a directory", cfg.OutputPath)
+	}
+	return fmt.Errorf("the output file %s already exists, pass --overwrite to replace it", cfg.OutputPath)
+}
+
+// writeLocalFile writes data to a local path and returns the absolute path written
+func writeLocalFile(outputPath string, data []byte) (string, error) {
 	if outputPath == "" {
-		return fmt.Errorf("output path is empty")
+		return "", fmt.Errorf("output path is empty")
 	}
 
 	// Handle path: if relative path, base on current working directory
@@ -185,7 +665,7 @@ func (p *Profiler) saveOutputFile(outputPath string, data []byte) error {
 		// 获取当前工作目录
 		cwd, err := os.Getwd()
 		if err != nil {
-			return fmt.Errorf("failed to get current working directory: %w", err)
+			return "", fmt.Errorf("failed to get current working directory: %w", err)
 		}
 		finalPath = filepath.Join(cwd, outputPath)
 	}
@@ -193,16 +673,15 @@ func (p *Profiler) saveOutputFile(outputPath string, data []byte) error {
 	// 确保输出目录存在
 	dir := filepath.Dir(finalPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// 写入文件
 	if err := os.WriteFile(finalPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+		return "", fmt.Errorf("failed to write output file: %w", err)
 	}
 
-	fmt.Printf("Flamegraph saved to: %s\n", finalPath)
-	return nil
+	return finalPath, nil
 }
 
 // cleanup 清理资源
@@ -211,15 +690,15 @@ func (p *Profiler) cleanup(ctx context.Context, jobName string, namespace string
 }
 
 // GetStatus 获取分析状态
-func (p *Profiler) GetStatus(ctx context.Context, jobName string, namespace string) (*types.JobStatus, error) {
+func (p *Profiler) GetStatus(ctx context.Context, jobName string, namespace string) (*api.JobStatus, error) {
 	return p.jobManager.GetJobStatus(ctx, jobName, namespace)
 }
 
 // ListJobs 列出所有分析Job（简化版本）
-func (p *Profiler) ListJobs(ctx context.Context, namespace string) ([]*types.JobStatus, error) {
+func (p *Profiler) ListJobs(ctx context.Context, namespace string) ([]*api.JobStatus, error) {
 	// 在简化架构中，我们不再维护Job列表
 	// 返回空列表
-	return []*types.JobStatus{}, nil
+	return []*api.JobStatus{}, nil
 }
 
 // Cancel 取消分析
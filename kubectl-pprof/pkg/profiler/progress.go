@@ -0,0 +1,22 @@
+package profiler
+
+import (
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// ProgressChannel returns a ProgressFunc sending events to ch. Events are
+// dropped while ch is full, so a slow consumer never stalls a session.
+func ProgressChannel(ch chan<- api.ProgressEvent) api.ProgressFunc {
+	return func(event api.ProgressEvent) {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// artifactSaved logs a locally written artifact and reports it to progress consumers
+func artifactSaved(opts *api.ProfileOptions, artifact, path string) {
+	opts.Log().Info(artifact+" saved", "path", path)
+	opts.Emit(api.ProgressEvent{Type: api.EventArtifactDownloaded, Artifact: artifact, Path: path})
+}
@@ -0,0 +1,192 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/analysis"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// Defaults of the in-pod golang-profiling renderer, kept for client-side rendering
+const (
+	defaultTitle   = "Golang CPU Profiling"
+	defaultPalette = "kernel_user"
+)
+
+// tracedTitles title the profile types traced with eBPF instead of sampled,
+// whose graph widths are microseconds of latency and not samples
+var tracedTitles = map[string]string{
+	api.ProfileTypeSchedLat: "Golang Scheduling Latency",
+	api.ProfileTypeNet:      "Golang Slow Network Calls",
+}
+
+// renderOptions converts Go profiling options into renderer options
+func renderOptions(goOpts *api.GoProfilingOptions) flamegraph.Options {
+	opts := flamegraph.Options{
+		Title:      goOpts.Title,
+		Subtitle:   goOpts.Subtitle,
+		Colors:     goOpts.Colors,
+		BgColors:   goOpts.BgColors,
+		Width:      goOpts.Width,
+		Height:     goOpts.Height,
+		FontType:   goOpts.FontType,
+		FontSize:   goOpts.FontSize,
+		Inverted:   goOpts.Inverted,
+		FlameChart: goOpts.FlameChart,
+		Hash:       goOpts.Hash,
+		Random:     goOpts.Random,
+	}
+	if opts.Title == "" && !opts.FlameChart {
+		opts.Title = defaultTitle
+	}
+	if opts.Colors == "" {
+		opts.Colors = defaultPalette
+	}
+	if goOpts.OffCPU {
+		opts.CountName = "samples/µs"
+	}
+	return opts
+}
+
+// renderLocally fetches the raw stacks of the job and renders the flame graph
+// client-side in the requested output format. Flame charts are rendered from
+// the time-ordered stacks.
+func (p *Profiler) renderLocally(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName string, meta *api.SessionMetadata) ([]byte, error) {
+	profile, err := p.jobProfile(ctx, cfg, jobName)
+	if err != nil {
+		return nil, err
+	}
+	return renderProfile(profile, localRenderOptions(cfg, opts, meta, profile), opts)
+}
+
+// jobProfile fetches and parses the raw stacks of the job, time-ordered for
+// flame charts
+func (p *Profiler) jobProfile(ctx context.Context, cfg *api.ProfileConfig, jobName string) (*flamegraph.Profile, error) {
+	var (
+		profile *flamegraph.Profile
+		err     error
+	)
+	if cfg.GoOptions != nil && cfg.GoOptions.FlameChart {
+		var timeline []byte
+		if timeline, err = p.transport.ExtractTimelineFromLogs(ctx, jobName, cfg.GetJobNamespace()); err != nil {
+			return nil, err
+		}
+		profile, err = flamegraph.ParseTimeline(bytes.NewReader(timeline))
+	} else {
+		var folded []byte
+		if folded, err = p.transport.ExtractFoldedFromLogs(ctx, jobName, cfg.GetJobNamespace()); err != nil {
+			return nil, err
+		}
+		profile, err = flamegraph.ParseFolded(bytes.NewReader(folded))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stacks: %w", err)
+	}
+	return profile, nil
+}
+
+// localRenderOptions returns the options of a graph rendered client-side,
+// with the session facts, source links and findings of the session
+func localRenderOptions(cfg *api.ProfileConfig, opts *api.ProfileOptions, meta *api.SessionMetadata, profile *flamegraph.Profile) flamegraph.Options {
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	renderOpts := renderOptions(&goOpts)
+	if meta != nil {
+		renderOpts.Facts = append(buildFacts(meta.BuildInfo), runtimeFacts(meta.Runtime)...)
+		renderOpts.Facts = append(renderOpts.Facts, throttlingFacts(meta.Throttling)...)
+		renderOpts.Facts = append(renderOpts.Facts, usageFacts(meta.ResourceUsage)...)
+	}
+	if _, traced := tracedTitles[cfg.ProfileType]; traced {
+		renderOpts.CountName = "µs"
+	}
+	renderOpts.SourceURL = sourceURL(cfg, opts, meta)
+	if cfg.Analyze {
+		renderOpts.Findings = HTMLFindings(analysis.Analyze(profile, analysis.DefaultRules()))
+	}
+	return renderOpts
+}
+
+// sourceURL links the frames of the target's own module to the revision it
+// was built from, with --source-url-template and a VCS revision in the build
+// info golang-profiling read from the target executable
+func sourceURL(cfg *api.ProfileConfig, opts *api.ProfileOptions, meta *api.SessionMetadata) func(function, file string, line int) string {
+	if cfg.SourceURLTemplate == "" {
+		return nil
+	}
+	if meta == nil || meta.BuildInfo == nil || meta.BuildInfo.Revision == "" {
+		opts.Log().Warn("The VCS revision of the target is unknown, frames are not linked to their source",
+			"hint", "the revision is read from the executable of Go 1.18+ programs built with VCS stamping in a profiling Job")
+		return nil
+	}
+	build := meta.BuildInfo
+	if build.Modified {
+		opts.Log().Warn("The target was built from uncommitted changes, source links may point at other lines", "revision", build.Revision)
+	}
+	return flamegraph.SourceLink{
+		Template:    cfg.SourceURLTemplate,
+		Commit:      build.Revision,
+		Module:      build.Module,
+		MainPackage: build.Path,
+	}.URL
+}
+
+// renderProfile renders stacks in the requested output format, SVG by default
+func renderProfile(profile *flamegraph.Profile, renderOpts flamegraph.Options, opts *api.ProfileOptions) ([]byte, error) {
+	renderOpts.DPI = opts.DPI
+
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	switch opts.OutputFormat {
+	case "png":
+		err = flamegraph.RenderPNG(&buf, profile, renderOpts)
+	case "pdf":
+		err = flamegraph.RenderPDF(&buf, profile, renderOpts)
+	case "html":
+		err = flamegraph.RenderHTML(&buf, profile, renderOpts)
+	default:
+		err = flamegraph.RenderSVG(&buf, profile, renderOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// placeholderSVG renders the red X shown in place of a flame graph that could
+// not be extracted, with --allow-partial
+func placeholderSVG(err error) []byte {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(err.Error()))
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="500" height="300" viewBox="0 0 500 300">
+  <!-- 背景 -->
+  <rect width="500" height="300" fill="#f8f9fa" stroke="#dee2e6" stroke-width="2"/>
+  
+  <!-- Red X mark -->
+  <g transform="translate(250,100)">
+    <circle cx="0" cy="0" r="50" fill="#dc3545" stroke="#b02a37" stroke-width="3"/>
+    <line x1="-25" y1="-25" x2="25" y2="25" stroke="white" stroke-width="6" stroke-linecap="round"/>
+    <line x1="25" y1="-25" x2="-25" y2="25" stroke="white" stroke-width="6" stroke-linecap="round"/>
+  </g>
+  
+  <!-- Failure message text -->
+  <text x="250" y="200" text-anchor="middle" font-family="Arial, sans-serif" font-size="24" font-weight="bold" fill="#dc3545">
+    Flame Graph Generation Failed
+  </text>
+  <text x="250" y="230" text-anchor="middle" font-family="Arial, sans-serif" font-size="14" fill="#6c757d">
+    Failed to extract flamegraph from logs
+  </text>
+  <text x="250" y="250" text-anchor="middle" font-family="Arial, sans-serif" font-size="12" fill="#6c757d">
+    Error: ` + escaped.String() + `
+  </text>
+</svg>`)
+}
@@ -0,0 +1,63 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+	"github.com/withlin/kubectl-pprof/pkg/record"
+)
+
+// recordSession saves what the profiler ran next to the recorded API traffic,
+// before the artifacts are collected so that failed collections can be replayed
+func recordSession(cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo, result *api.ProfileResult) {
+	if opts.RecordSession == "" {
+		return
+	}
+	err := record.WriteSession(opts.RecordSession, &record.Session{
+		Config:  cfg,
+		Options: opts,
+		Target:  target,
+		Result:  result,
+	})
+	if err != nil {
+		opts.Log().Warn("Failed to record session", "error", err)
+		return
+	}
+	opts.Log().Info("Session recorded", "dir", opts.RecordSession)
+}
+
+// Replay collects and renders the results of a recorded session again, reading
+// the artifacts from the recorded profiler logs instead of the cluster. The
+// recorded config and options are used; outputPath replaces the output when
+// set, and opts supplies the Logger, Progress and Quiet of the replay.
+func Replay(ctx context.Context, dir, outputPath string, opts *api.ProfileOptions) (*api.ProfileResult, error) {
+	rec, err := record.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *rec.Session.Config
+	if outputPath != "" {
+		cfg.OutputPath = outputPath
+	}
+	replayOpts := api.DefaultProfileOptions()
+	if rec.Session.Options != nil {
+		replayOpts = rec.Session.Options
+	}
+	replayOpts.RecordSession = ""
+	if opts != nil {
+		replayOpts.Logger = opts.Logger
+		replayOpts.Progress = opts.Progress
+		replayOpts.Quiet = opts.Quiet
+	}
+
+	p := &Profiler{transport: &job.LogArtifacts{Open: rec.JobLogs}}
+	result := *rec.Session.Result
+	replayed, err := p.collectResults(ctx, &cfg, replayOpts, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect results: %w", err)
+	}
+	return replayed, nil
+}
@@ -0,0 +1,61 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/internal/errors"
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+)
+
+// maxAdmissionFallbacks bounds the retries after admission refused the
+// profiler: from the Job to an ephemeral container to the pprof endpoint
+const maxAdmissionFallbacks = 2
+
+// reducedCapabilities explains what a session gives up in a fallback mode
+var reducedCapabilities = map[api.ProfileMode]string{
+	api.ModeEphemeral: "the profiler only sees the PID namespace of the target pod, and still needs the BPF and PERFMON capabilities",
+	api.ModePprof:     "the Go runtime profiles itself through the pprof endpoint: no eBPF, so no off-CPU, kernel or non-Go frames",
+}
+
+// admissionFallback decides how to retry a session whose profiler the
+// cluster refused to admit, as on GKE Autopilot which forbids privileged and
+// hostPID pods altogether. Only sessions that let auto pick the mode fall
+// back; otherwise, or when no other mode reaches the target, the rejection
+// is returned with an explanation instead of a bare "forbidden".
+func (p *Profiler) admissionFallback(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, rejected *job.AdmissionRejectedError, auto bool) (api.ProfileMode, error) {
+	if !auto {
+		return "", admissionError(rejected, fmt.Sprintf("--mode %s was requested", cfg.Mode),
+			"Use --mode auto to fall back to an ephemeral container or the pprof endpoint")
+	}
+	target, err := p.resolveTarget(ctx, cfg)
+	if err != nil {
+		return "", rejected
+	}
+	if target.HostProcess != "" {
+		return "", admissionError(rejected, "node processes are only reachable from a hostPID Job")
+	}
+
+	// Auto only picks an ephemeral container when the Job is ruled out as well
+	mode, reason := p.fallbackMode(ctx, cfg, target, "the cluster refused the privileged Job", rejected.Mode == api.ModeEphemeral)
+	if mode == api.ModeJob {
+		return "", admissionError(rejected, reason)
+	}
+	opts.Log().Warn("Profiler refused by admission, falling back", "mode", mode, "reason", reason, "limits", reducedCapabilities[mode])
+	return mode, nil
+}
+
+// admissionError explains a rejection no other mode could work around, and why
+func admissionError(rejected *job.AdmissionRejectedError, why string, suggestions ...string) error {
+	err := errors.NewPermissionError(
+		fmt.Sprintf("the cluster does not admit the %s profiler, a policy such as GKE Autopilot, Pod Security Admission, Gatekeeper or Kyverno forbids privileged, hostPID or extra capabilities; %s",
+			rejected.Mode, why),
+		append(suggestions,
+			"Profile a pod that serves net/http/pprof with --mode pprof-endpoint, which needs no privileges",
+			"Ask a cluster admin to exempt the Job namespace (--job-namespace) from the policy",
+		)...,
+	)
+	err.Cause = rejected
+	return err
+}
@@ -0,0 +1,78 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/endpoint"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// runtimeSnapshotTimeout bounds the port-forward and downloads of a snapshot
+const runtimeSnapshotTimeout = 30 * time.Second
+
+// runtimeSnapshot takes a Go runtime metrics snapshot through the pprof
+// endpoint of the target. It returns nil when disabled or when the target
+// serves no pprof endpoint.
+func (p *Profiler) runtimeSnapshot(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) *api.RuntimeSnapshot {
+	if !cfg.RuntimeMetrics {
+		return nil
+	}
+	ep, err := detectEndpoint(cfg, target)
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runtimeSnapshotTimeout)
+	defer cancel()
+
+	snapshot, err := func() (*api.RuntimeSnapshot, error) {
+		localPort, stop, err := endpoint.Forward(ctx, p.k8sConfig.Config, p.k8sConfig.Clientset, target.Namespace, target.PodName, ep.Port)
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+		return ep.RuntimeSnapshot(ctx, localPort)
+	}()
+	if err != nil {
+		opts.Log().Warn("Failed to read runtime metrics", "error", err)
+		return nil
+	}
+	return snapshot
+}
+
+// runtimeFacts lists the runtime metrics of the window for the HTML report
+func runtimeFacts(report *api.RuntimeMetricsReport) []flamegraph.Fact {
+	if report == nil {
+		return nil
+	}
+	facts := []flamegraph.Fact{
+		{Name: "GC cycles", Value: fmt.Sprintf("%d (%.2f%% of CPU since start)", report.GCCycles, 100*report.End.GCCPUFraction)},
+		{Name: "GC pauses", Value: fmt.Sprintf("%v total, %v max", report.GCPauseTotal, report.GCPauseMax)},
+		{Name: "Heap in use", Value: fmt.Sprintf("%s → %s (next GC at %s)", FormatBytes(report.Start.HeapAlloc), FormatBytes(report.End.HeapAlloc), FormatBytes(report.End.NextGC))},
+		{Name: "Goroutines", Value: fmt.Sprintf("%d → %d", report.Start.Goroutines, report.End.Goroutines)},
+	}
+	if report.End.GOMAXPROCS > 0 {
+		facts = append(facts, flamegraph.Fact{Name: "GOMAXPROCS", Value: fmt.Sprintf("%d", report.End.GOMAXPROCS)})
+	}
+	if report.SchedLatencyP99 > 0 {
+		facts = append(facts, flamegraph.Fact{Name: "Scheduling latency p99", Value: report.SchedLatencyP99.String()})
+	}
+	return facts
+}
+
+// FormatBytes formats a byte count with a binary unit
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,86 @@
+package profiler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// collectSchedLatency retrieves the run queue latency histogram of a schedlat
+// profile and saves it next to the flame graph
+func (p *Profiler) collectSchedLatency(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, jobName string) (*api.SchedLatencyReport, error) {
+	data, err := p.transport.ExtractSchedLatencyFromLogs(ctx, jobName, cfg.GetJobNamespace())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract scheduling latency histogram: %w", err)
+	}
+
+	report, err := parseSchedLatency(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.OutputPath != "" {
+		path := strings.TrimSuffix(cfg.OutputPath, filepath.Ext(cfg.OutputPath)) + ".schedlat.txt"
+		if report.Path, err = writeLocalFile(path, data); err != nil {
+			return nil, fmt.Errorf("failed to save scheduling latency histogram: %w", err)
+		}
+		artifactSaved(opts, "Scheduling latency histogram", report.Path)
+	}
+	return report, nil
+}
+
+// parseSchedLatency parses the "<low_us> <high_us> <count>" lines written by
+// golang-profiling --export-histogram. Quantiles are bucket upper bounds.
+func parseSchedLatency(data []byte) (*api.SchedLatencyReport, error) {
+	report := &api.SchedLatencyReport{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var low, high, count uint64
+		if _, err := fmt.Sscanf(line, "%d %d %d", &low, &high, &count); err != nil {
+			return nil, fmt.Errorf("invalid scheduling latency histogram line %q: %w", line, err)
+		}
+		report.Buckets = append(report.Buckets, api.SchedLatencyBucket{
+			Low:   time.Duration(low) * time.Microsecond,
+			High:  time.Duration(high) * time.Microsecond,
+			Count: count,
+		})
+		report.Count += count
+		if count > 0 {
+			report.Max = time.Duration(high) * time.Microsecond
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read scheduling latency histogram: %w", err)
+	}
+
+	report.P50 = schedLatencyQuantile(report, 0.5)
+	report.P99 = schedLatencyQuantile(report, 0.99)
+	return report, nil
+}
+
+// schedLatencyQuantile returns the upper bound of the bucket holding quantile q
+func schedLatencyQuantile(report *api.SchedLatencyReport, q float64) time.Duration {
+	if report.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(report.Count)))
+	var seen uint64
+	for _, bucket := range report.Buckets {
+		seen += bucket.Count
+		if seen >= rank {
+			return bucket.High
+		}
+	}
+	return report.Max
+}
@@ -0,0 +1,162 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/job"
+)
+
+// WorkloadPod returns the first running pod by name of a workload given as
+// kind/name, see ProfileSpread
+func (p *Profiler) WorkloadPod(ctx context.Context, namespace, ref string) (string, error) {
+	pods, err := p.discovery.WorkloadPods(ctx, namespace, ref)
+	if err != nil {
+		return "", err
+	}
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no running pods of %s in namespace %s", ref, namespace)
+	}
+	return pods[0].Name, nil
+}
+
+// ProfileSpread profiles one pod per node of the workload named by cfg.Spread,
+// one Job per node. At most MaxConcurrentJobs runs are in flight and no run
+// starts while the cluster already has that many kubectl-pprof Jobs active.
+// Jobs repel each other per node, so samplers of different sessions never
+// share a node. Each node gets its own artifacts named after it; with
+// MergeContainers the stacks are also rendered as one graph rooted at a frame
+// per node.
+func (p *Profiler) ProfileSpread(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions) (*api.MultiProfileResult, error) {
+	opts = p.sessionOptions(opts)
+
+	pods, err := p.discovery.WorkloadPods(ctx, cfg.Namespace, cfg.Spread)
+	if err != nil {
+		return nil, err
+	}
+
+	// One pod per node, the first by name
+	var nodes, podNames []string
+	seen := make(map[string]bool)
+	for _, pod := range pods {
+		node := pod.Spec.NodeName
+		if node == "" || seen[node] {
+			continue
+		}
+		seen[node] = true
+		nodes = append(nodes, node)
+		podNames = append(podNames, pod.Name)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no running pods of %s in namespace %s", cfg.Spread, cfg.Namespace)
+	}
+
+	limit := cfg.MaxConcurrentJobs
+	if limit < 1 {
+		limit = 1
+	}
+	opts.Log().Info("Profiling nodes", "nodes", len(nodes), "maxConcurrent", limit)
+
+	results := make([]*api.ProfileResult, len(nodes))
+	errs := make([]error, len(nodes))
+	slots := make(chan struct{}, limit)
+	var (
+		wg       sync.WaitGroup
+		slotMu   sync.Mutex // Serializes the cluster-wide check, so local runs never race for one slot
+		warnOnce sync.Once
+	)
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			slotMu.Lock()
+			err := p.jobManager.WaitForJobSlot(ctx, limit)
+			slotMu.Unlock()
+			if err != nil {
+				if ctx.Err() != nil {
+					errs[i] = ctx.Err()
+					return
+				}
+				// Fall back to the local cap when Jobs cannot be listed cluster-wide
+				warnOnce.Do(func() {
+					opts.Log().Warn("Cluster-wide job cap not enforced", "error", err)
+				})
+			}
+
+			scfg := spreadConfig(cfg, podNames[i], nodes[i], i)
+			sopts := *opts
+			sopts.Quiet = true
+			opts.Log().Info("Profiling node", "node", nodes[i], "pod", podNames[i])
+			results[i], errs[i] = p.Profile(ctx, scfg, &sopts)
+			if errs[i] == nil {
+				results[i].Config = scfg
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	multi := &api.MultiProfileResult{}
+	for i, node := range nodes {
+		if errs[i] != nil {
+			if multi.Failures == nil {
+				multi.Failures = make(map[string]string)
+			}
+			multi.Failures[node] = errs[i].Error()
+			continue
+		}
+		multi.Results = append(multi.Results, results[i])
+	}
+	if len(multi.Results) == 0 {
+		return multi, fmt.Errorf("profiling failed for all %d nodes", len(nodes))
+	}
+
+	if cfg.MergeContainers {
+		frame := func(result *api.ProfileResult) string { return result.Config.NodeName }
+		subtitle := fmt.Sprintf("%s/%s, %d nodes", cfg.Namespace, cfg.Spread, len(multi.Results))
+		mergedPath, err := p.mergeProfiles(cfg, opts, multi.Results, frame, subtitle)
+		if err != nil {
+			return multi, err
+		}
+		multi.MergedPath = mergedPath
+	}
+
+	return multi, nil
+}
+
+// spreadConfig derives the configuration of the run on one node. Output files
+// carry the node name and the Job name the run index, so runs never collide.
+func spreadConfig(cfg *api.ProfileConfig, pod, node string, index int) *api.ProfileConfig {
+	scfg := *cfg
+	scfg.Spread = ""
+	scfg.PodName = pod
+	scfg.NodeName = node
+	scfg.Mode = api.ModeJob
+	scfg.NodeAntiAffinity = true
+	scfg.OutputPath = containerPath(cfg.OutputPath, node)
+	scfg.JobName = job.JobNameWithSuffix(cfg, "n"+strconv.Itoa(index))
+	if cfg.ExportSummary != "" {
+		scfg.ExportSummary = containerPath(cfg.ExportSummary, node)
+	}
+
+	goOpts := api.GoProfilingOptions{}
+	if cfg.GoOptions != nil {
+		goOpts = *cfg.GoOptions
+	}
+	if goOpts.ExportFolded != "" {
+		goOpts.ExportFolded = containerPath(goOpts.ExportFolded, node)
+	} else if cfg.MergeContainers {
+		// The merged graph is built from the folded stacks of every node
+		goOpts.ExportFolded = filepath.Base(strings.TrimSuffix(scfg.OutputPath, filepath.Ext(scfg.OutputPath))) + ".folded"
+	}
+	scfg.GoOptions = &goOpts
+
+	return &scfg
+}
@@ -0,0 +1,105 @@
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/summary"
+)
+
+// summaryThreshold is the change in percentage points a function needs to be
+// listed against the baseline of a summary
+const summaryThreshold = 1
+
+// collectSummary writes the --export-summary of a job from its folded stacks
+func (p *Profiler) collectSummary(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, result *api.ProfileResult) (string, error) {
+	data, err := p.transport.ExtractFoldedFromLogs(ctx, result.JobName, cfg.GetJobNamespace())
+	if err != nil {
+		return "", fmt.Errorf("failed to extract folded stacks: %w", err)
+	}
+	profile, err := flamegraph.ParseFolded(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse folded stacks: %w", err)
+	}
+	countName := renderOptions(cfg.GoOptions).CountName
+	if _, traced := tracedTitles[cfg.ProfileType]; traced {
+		countName = "µs"
+	}
+	return writeSummary(cfg, opts, profile, countName, result)
+}
+
+// writeSummary saves the markdown summary of a profile, relative paths are
+// placed next to the output file like the other exports
+func writeSummary(cfg *api.ProfileConfig, opts *api.ProfileOptions, profile *flamegraph.Profile, countName string, result *api.ProfileResult) (string, error) {
+	s := summary.Summary{
+		Title:     "Profile summary",
+		Facts:     summaryFacts(cfg, result),
+		Profile:   profile,
+		CountName: countName,
+		Threshold: summaryThreshold,
+		Findings:  result.Findings,
+	}
+	if cfg.Baseline != "" {
+		baseline, _, err := flamegraph.LoadFile(cfg.Baseline, "", "")
+		if err != nil {
+			return "", fmt.Errorf("failed to load baseline: %w", err)
+		}
+		s.Baseline, s.BaselineLabel = baseline, cfg.Baseline
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteMarkdown(&buf); err != nil {
+		return "", err
+	}
+	path := cfg.ExportSummary
+	if !filepath.IsAbs(path) && cfg.OutputPath != "" {
+		path = filepath.Join(filepath.Dir(cfg.OutputPath), path)
+	}
+	path, err := writeLocalFile(path, buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to save profile summary: %w", err)
+	}
+	artifactSaved(opts, "Profile summary", path)
+	return path, nil
+}
+
+// summaryFacts lists the session, the build of the target and its runtime
+// behavior at the top of a summary
+func summaryFacts(cfg *api.ProfileConfig, result *api.ProfileResult) []flamegraph.Fact {
+	meta := result.Metadata
+	if meta == nil {
+		return nil
+	}
+	target := fmt.Sprintf("%s/%s (container %s) on node %s", meta.Namespace, meta.PodName, meta.ContainerName, meta.NodeName)
+	if meta.HostProcess != "" {
+		target = fmt.Sprintf("process %s on node %s", meta.HostProcess, meta.NodeName)
+	}
+	duration := result.Duration
+	if duration == 0 {
+		duration = meta.Duration
+	}
+	profile := fmt.Sprintf("%s for %v", cfg.ProfileType, duration.Round(time.Second))
+	if meta.Frequency > 0 {
+		profile += fmt.Sprintf(" at %d Hz", meta.Frequency)
+	}
+	if meta.Backend != "" {
+		profile += ", sampled with " + meta.Backend
+	}
+	facts := []flamegraph.Fact{
+		{Name: "Target", Value: target},
+		{Name: "Profile", Value: profile},
+		{Name: "Started", Value: meta.StartTime.Format(time.RFC3339)},
+	}
+	facts = append(facts, buildFacts(meta.BuildInfo)...)
+	if result.Traces != nil {
+		facts = append(facts, flamegraph.Fact{Name: "Traces", Value: TraceSummary(result.Traces)})
+	}
+	facts = append(facts, runtimeFacts(meta.Runtime)...)
+	facts = append(facts, throttlingFacts(meta.Throttling)...)
+	return append(facts, usageFacts(meta.ResourceUsage)...)
+}
@@ -0,0 +1,31 @@
+package profiler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+)
+
+// checkThrottling flags a profile whose target was throttled in more than
+// threshold percent of its CFS periods. Throttled threads sit off-CPU with
+// runnable work, which the flame graph does not show.
+func checkThrottling(report *api.ThrottlingReport, threshold float64) {
+	if report == nil || report.Periods == 0 || report.ThrottledPercent <= threshold {
+		return
+	}
+	report.Warning = fmt.Sprintf("the target container was CPU throttled in %.1f%% of CFS periods (%d of %d, %v held back); latency may come from its CPU limit rather than from the code in the flame graph",
+		report.ThrottledPercent, report.ThrottledPeriods, report.Periods, report.ThrottledTime.Round(time.Millisecond))
+}
+
+// throttlingFacts lists the CPU throttling of the window for the HTML report
+func throttlingFacts(report *api.ThrottlingReport) []flamegraph.Fact {
+	if report == nil || report.Periods == 0 {
+		return nil
+	}
+	return []flamegraph.Fact{{
+		Name:  "CPU throttling",
+		Value: fmt.Sprintf("%d of %d periods (%.1f%%), %v throttled", report.ThrottledPeriods, report.Periods, report.ThrottledPercent, report.ThrottledTime.Round(time.Millisecond)),
+	}}
+}
@@ -0,0 +1,50 @@
+package profiler
+
+import (
+	"fmt"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// traceReport lists the trace IDs of the first of the labels the samples
+// carry, nil when none is set. Spans started with the trace context in
+// runtime/pprof labels, like the OpenTelemetry profiling integrations do,
+// tag the samples of the work they did, so the IDs lead from the profile to
+// the traces that ran during the window.
+func traceReport(profile *flamegraph.Profile, labels []string) *api.TraceReport {
+	tags := make(map[string]flamegraph.TagBreakdown)
+	for _, tag := range flamegraph.Tags(profile) {
+		tags[tag.Key] = tag
+	}
+	for _, label := range labels {
+		tag, ok := tags[label]
+		if !ok {
+			continue
+		}
+		report := &api.TraceReport{Label: label, Samples: tag.Total}
+		for _, value := range tag.Values {
+			if value.Value == flamegraph.UnsetTag {
+				continue
+			}
+			report.Distinct++
+			report.Traced += value.Samples
+			if len(report.Traces) < api.MaxTraces {
+				report.Traces = append(report.Traces, api.TraceSamples{TraceID: value.Value, Samples: value.Samples})
+			}
+		}
+		return report
+	}
+	return nil
+}
+
+// TraceSummary describes the traces active during the window in a line
+func TraceSummary(report *api.TraceReport) string {
+	summary := fmt.Sprintf("%d traces in %.0f%% of the samples", report.Distinct, stacks.Share(report.Traced, report.Samples))
+	if len(report.Traces) > 0 {
+		top := report.Traces[0]
+		summary += fmt.Sprintf(", most sampled %s (%d samples)", top.TraceID, top.Samples)
+	}
+	return summary
+}
@@ -0,0 +1,293 @@
+package profiler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/logging"
+)
+
+// usageInterval spaces the usage readings of the target, metrics-server and
+// the kubelet refresh theirs about as often
+const usageInterval = 15 * time.Second
+
+// usageRequestTimeout bounds one usage reading
+const usageRequestTimeout = 10 * time.Second
+
+// nearLimitPercent is the share of a limit at which usage is flagged
+const nearLimitPercent = 90
+
+// usageSample is one usage reading of the target container
+type usageSample struct {
+	cpuMillis   int64
+	memoryBytes int64
+}
+
+// usageSource reads the usage of the target container
+type usageSource struct {
+	name string
+	read func(ctx context.Context, clientset kubernetes.Interface, target *api.TargetInfo) (usageSample, error)
+}
+
+// usageSources are tried in order, the kubelet when metrics-server is not
+// installed or has no metrics for the pod yet
+var usageSources = []usageSource{
+	{name: "metrics-server", read: metricsServerUsage},
+	{name: "kubelet", read: kubeletUsage},
+}
+
+// startUsage starts sampling the CPU and memory usage of the target container
+// until the returned function is called, which returns the report of the
+// window, nil when no reading succeeded. It is nil when disabled.
+func (p *Profiler) startUsage(ctx context.Context, cfg *api.ProfileConfig, opts *api.ProfileOptions, target *api.TargetInfo) func() *api.ResourceUsageReport {
+	container, ok := target.Container.(*corev1.Container)
+	if !cfg.ResourceUsage || target.HostProcess != "" || !ok || p.k8sConfig.Config == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var (
+		samples []usageSample
+		source  int
+	)
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(usageInterval)
+		defer ticker.Stop()
+		for {
+			for source < len(usageSources) {
+				readCtx, cancelRead := context.WithTimeout(ctx, usageRequestTimeout)
+				sample, err := usageSources[source].read(readCtx, p.k8sConfig.Clientset, target)
+				cancelRead()
+				if err == nil {
+					samples = append(samples, sample)
+					break
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				// A source that already answered only missed this reading
+				if len(samples) > 0 {
+					break
+				}
+				opts.Log().Log(ctx, logging.V(1), "Resource usage unavailable", "source", usageSources[source].name, "error", err)
+				source++
+			}
+			if source == len(usageSources) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() *api.ResourceUsageReport {
+		cancel()
+		<-done
+		if len(samples) == 0 {
+			return nil
+		}
+		report := usageReport(usageSources[source].name, samples, container.Resources)
+		checkUsage(report)
+		return report
+	}
+}
+
+// usageReport summarizes the readings of the window against the resources of
+// the container
+func usageReport(source string, samples []usageSample, resources corev1.ResourceRequirements) *api.ResourceUsageReport {
+	report := &api.ResourceUsageReport{Source: source, Samples: len(samples)}
+	var cpu, memory int64
+	for _, sample := range samples {
+		cpu += sample.cpuMillis
+		memory += sample.memoryBytes
+		report.CPUMaxMillis = max(report.CPUMaxMillis, sample.cpuMillis)
+		report.MemoryMaxBytes = max(report.MemoryMaxBytes, sample.memoryBytes)
+	}
+	report.CPUAvgMillis = cpu / int64(len(samples))
+	report.MemoryAvgBytes = memory / int64(len(samples))
+
+	if q, ok := resources.Requests[corev1.ResourceCPU]; ok {
+		report.CPURequestMillis = q.MilliValue()
+	}
+	if q, ok := resources.Limits[corev1.ResourceCPU]; ok {
+		report.CPULimitMillis = q.MilliValue()
+	}
+	if q, ok := resources.Requests[corev1.ResourceMemory]; ok {
+		report.MemoryRequestBytes = q.Value()
+	}
+	if q, ok := resources.Limits[corev1.ResourceMemory]; ok {
+		report.MemoryLimitBytes = q.Value()
+	}
+	return report
+}
+
+// checkUsage flags a window in which the target came close to a limit: near
+// its CPU limit it gets throttled, near its memory limit it reclaims memory
+// or gets OOM killed, neither of which the flame graph shows as such
+func checkUsage(report *api.ResourceUsageReport) {
+	if limit := report.CPULimitMillis; limit > 0 && 100*report.CPUMaxMillis >= nearLimitPercent*limit {
+		report.Warning = fmt.Sprintf("the target container used up to %s of its %s CPU limit; time may go to throttling rather than to the code in the flame graph",
+			formatMillicores(report.CPUMaxMillis), formatMillicores(limit))
+	}
+	if limit := report.MemoryLimitBytes; limit > 0 && 100*report.MemoryMaxBytes >= nearLimitPercent*limit {
+		warning := fmt.Sprintf("the target container used up to %s of its %s memory limit; GC and page reclaim may show in the flame graph",
+			FormatBytes(uint64(report.MemoryMaxBytes)), FormatBytes(uint64(limit)))
+		if report.Warning != "" {
+			warning = report.Warning + "; " + warning
+		}
+		report.Warning = warning
+	}
+}
+
+// usageFacts lists the resource usage of the window for the HTML report
+func usageFacts(report *api.ResourceUsageReport) []flamegraph.Fact {
+	if report == nil {
+		return nil
+	}
+	return []flamegraph.Fact{
+		{Name: "CPU usage", Value: FormatCPUUsage(report)},
+		{Name: "Memory usage", Value: FormatMemoryUsage(report)},
+	}
+}
+
+// FormatCPUUsage formats the CPU usage of a window against the CPU request
+// and limit of the container
+func FormatCPUUsage(report *api.ResourceUsageReport) string {
+	return fmt.Sprintf("%s avg, %s max%s", formatMillicores(report.CPUAvgMillis), formatMillicores(report.CPUMaxMillis),
+		formatBounds(report.CPURequestMillis, report.CPULimitMillis, report.CPUMaxMillis, formatMillicores))
+}
+
+// FormatMemoryUsage formats the working set memory of a window against the
+// memory request and limit of the container
+func FormatMemoryUsage(report *api.ResourceUsageReport) string {
+	format := func(n int64) string { return FormatBytes(uint64(n)) }
+	return fmt.Sprintf("%s avg, %s max%s", format(report.MemoryAvgBytes), format(report.MemoryMaxBytes),
+		formatBounds(report.MemoryRequestBytes, report.MemoryLimitBytes, report.MemoryMaxBytes, format))
+}
+
+// formatBounds formats a request and a limit, with the peak as a share of the
+// limit
+func formatBounds(request, limit, peak int64, format func(int64) string) string {
+	switch {
+	case request > 0 && limit > 0:
+		return fmt.Sprintf(" (request %s, limit %s, peak at %.0f%% of the limit)", format(request), format(limit), 100*float64(peak)/float64(limit))
+	case limit > 0:
+		return fmt.Sprintf(" (limit %s, peak at %.0f%% of the limit)", format(limit), 100*float64(peak)/float64(limit))
+	case request > 0:
+		return fmt.Sprintf(" (request %s, no limit)", format(request))
+	}
+	return " (no request or limit)"
+}
+
+// formatMillicores formats a CPU amount the way resources are written
+func formatMillicores(millis int64) string {
+	return resource.NewMilliQuantity(millis, resource.DecimalSI).String()
+}
+
+// podMetrics is the body of a pod of the metrics API (metrics-server),
+// decoded here to avoid depending on its client
+type podMetrics struct {
+	Containers []struct {
+		Name  string `json:"name"`
+		Usage struct {
+			CPU    string `json:"cpu"`
+			Memory string `json:"memory"`
+		} `json:"usage"`
+	} `json:"containers"`
+}
+
+// metricsServerUsage reads the usage of the target container from the
+// metrics API
+func metricsServerUsage(ctx context.Context, clientset kubernetes.Interface, target *api.TargetInfo) (usageSample, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1", "namespaces", target.Namespace, "pods", target.PodName).
+		DoRaw(ctx)
+	if err != nil {
+		return usageSample{}, err
+	}
+	var metrics podMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return usageSample{}, fmt.Errorf("failed to decode pod metrics: %w", err)
+	}
+	for _, container := range metrics.Containers {
+		if container.Name != target.ContainerName {
+			continue
+		}
+		cpu, err := resource.ParseQuantity(container.Usage.CPU)
+		if err != nil {
+			return usageSample{}, fmt.Errorf("invalid CPU usage %q: %w", container.Usage.CPU, err)
+		}
+		memory, err := resource.ParseQuantity(container.Usage.Memory)
+		if err != nil {
+			return usageSample{}, fmt.Errorf("invalid memory usage %q: %w", container.Usage.Memory, err)
+		}
+		return usageSample{cpuMillis: cpu.MilliValue(), memoryBytes: memory.Value()}, nil
+	}
+	return usageSample{}, fmt.Errorf("no metrics for container %s", target.ContainerName)
+}
+
+// statsSummary is the part of the kubelet summary API read for the usage of
+// a container
+type statsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Containers []struct {
+			Name string `json:"name"`
+			CPU  *struct {
+				UsageNanoCores *uint64 `json:"usageNanoCores"`
+			} `json:"cpu"`
+			Memory *struct {
+				WorkingSetBytes *uint64 `json:"workingSetBytes"`
+			} `json:"memory"`
+		} `json:"containers"`
+	} `json:"pods"`
+}
+
+// kubeletUsage reads the usage of the target container from the summary API
+// of the kubelet of its node, through the API server proxy
+func kubeletUsage(ctx context.Context, clientset kubernetes.Interface, target *api.TargetInfo) (usageSample, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", target.NodeName, "proxy", "stats", "summary").
+		DoRaw(ctx)
+	if err != nil {
+		return usageSample{}, err
+	}
+	var summary statsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return usageSample{}, fmt.Errorf("failed to decode kubelet stats: %w", err)
+	}
+	for _, pod := range summary.Pods {
+		if pod.PodRef.Namespace != target.Namespace || pod.PodRef.Name != target.PodName {
+			continue
+		}
+		for _, container := range pod.Containers {
+			if container.Name != target.ContainerName {
+				continue
+			}
+			if container.CPU == nil || container.CPU.UsageNanoCores == nil || container.Memory == nil || container.Memory.WorkingSetBytes == nil {
+				return usageSample{}, fmt.Errorf("no usage for container %s yet", target.ContainerName)
+			}
+			return usageSample{
+				cpuMillis:   int64(*container.CPU.UsageNanoCores / 1e6),
+				memoryBytes: int64(*container.Memory.WorkingSetBytes),
+			}, nil
+		}
+	}
+	return usageSample{}, fmt.Errorf("no stats for container %s on node %s", target.ContainerName, target.NodeName)
+}
@@ -0,0 +1,215 @@
+// Package record dumps the Kubernetes API traffic of a profiling session to a
+// directory and reads it back, so a session that failed in another cluster
+// can be inspected and its results collected again offline.
+//
+// A recording holds exchanges.jsonl, one Exchange per API request in the
+// order they were sent, the request and response bodies of every exchange
+// (created objects, watch event streams and log streams) and session.json,
+// the Session the profiler ran. Request headers, and with them credentials,
+// are not recorded.
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+)
+
+// Files of a recording
+const (
+	IndexFile   = "exchanges.jsonl"
+	SessionFile = "session.json"
+)
+
+// Exchange is a recorded API request and its response
+type Exchange struct {
+	Seq    int       `json:"seq"`
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	// Status is 0 when the request failed without a response
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Body files relative to the recording directory
+	RequestBody  string `json:"requestBody,omitempty"`
+	ResponseBody string `json:"responseBody,omitempty"`
+}
+
+// Session is what the profiler ran: the config and options after defaults,
+// the target it resolved and the result of the profiler run before the
+// artifacts were collected
+type Session struct {
+	Config  *api.ProfileConfig  `json:"config"`
+	Options *api.ProfileOptions `json:"options"`
+	Target  *api.TargetInfo     `json:"target"`
+	Result  *api.ProfileResult  `json:"result"`
+}
+
+// Recorder writes the API traffic of clients it wraps into a directory
+type Recorder struct {
+	dir string
+
+	mu    sync.Mutex
+	seq   int
+	index *os.File
+}
+
+// NewRecorder creates the recording directory and its index
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	index, err := os.Create(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording index: %w", err)
+	}
+	return &Recorder{dir: dir, index: index}, nil
+}
+
+// Close closes the recording index
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.index.Close()
+}
+
+// Wrap returns a copy of k8sConfig whose clients record through r
+func (r *Recorder) Wrap(k8sConfig *config.KubernetesConfig) (*config.KubernetesConfig, error) {
+	if k8sConfig.Config == nil {
+		return nil, fmt.Errorf("recording needs a REST config")
+	}
+	restConfig := rest.CopyConfig(k8sConfig.Config)
+	restConfig.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &roundTripper{recorder: r, next: next}
+	})
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording client: %w", err)
+	}
+	return &config.KubernetesConfig{
+		Config:    restConfig,
+		Clientset: clientset,
+		Namespace: k8sConfig.Namespace,
+	}, nil
+}
+
+// next reserves the sequence number of an exchange
+func (r *Recorder) next() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	return r.seq
+}
+
+// add appends an exchange to the index
+func (r *Recorder) add(exchange *Exchange) {
+	data, err := json.Marshal(exchange)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.index.Write(append(data, '\n'))
+}
+
+// bodyFile creates the file holding a body of an exchange
+func (r *Recorder) bodyFile(seq int, kind string) (*os.File, string, error) {
+	name := fmt.Sprintf("%04d-%s.body", seq, kind)
+	f, err := os.Create(filepath.Join(r.dir, name))
+	return f, name, err
+}
+
+// roundTripper records every request sent through it
+type roundTripper struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := rt.recorder
+	exchange := &Exchange{
+		Seq:    r.next(),
+		Time:   time.Now(),
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+
+	// Created and updated objects
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if f, name, err := r.bodyFile(exchange.Seq, "request"); err == nil {
+			f.Write(body)
+			f.Close()
+			exchange.RequestBody = name
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		exchange.Error = err.Error()
+		r.add(exchange)
+		return nil, err
+	}
+	exchange.Status = resp.StatusCode
+
+	// Upgraded connections (port-forward, exec) carry no recordable body
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.Body != nil {
+		if f, name, err := r.bodyFile(exchange.Seq, "response"); err == nil {
+			exchange.ResponseBody = name
+			resp.Body = &teeBody{ReadCloser: resp.Body, file: f}
+		}
+	}
+	r.add(exchange)
+	return resp, nil
+}
+
+// teeBody copies a response body to a file as the client reads it, so watch
+// and log streams are recorded up to where the client stopped
+type teeBody struct {
+	io.ReadCloser
+	file *os.File
+	once sync.Once
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.file.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	b.once.Do(func() { b.file.Close() })
+	return b.ReadCloser.Close()
+}
+
+// WriteSession saves the session the profiler ran into a recording
+func WriteSession(dir string, session *Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SessionFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recording is a recorded session read back from its directory
+type Recording struct {
+	Dir       string
+	Session   *Session
+	Exchanges []Exchange
+}
+
+// Open reads the index and session of a recording
+func Open(dir string) (*Recording, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SessionFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SessionFile, err)
+	}
+	if session.Config == nil || session.Result == nil {
+		return nil, fmt.Errorf("%s holds no profiler run", SessionFile)
+	}
+
+	index, err := os.Open(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording index: %w", err)
+	}
+	defer index.Close()
+
+	rec := &Recording{Dir: dir, Session: &session}
+	scanner := bufio.NewScanner(index)
+	for scanner.Scan() {
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", IndexFile, err)
+		}
+		rec.Exchanges = append(rec.Exchanges, exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording index: %w", err)
+	}
+	return rec, nil
+}
+
+// JobLogs opens the last complete profiler log stream recorded for a run:
+// the profiler container of a Job's pod, or the ephemeral container named
+// after the run. Followed streams are only used when nothing else was read.
+func (r *Recording) JobLogs(jobName, namespace string) (io.ReadCloser, error) {
+	var found, followed *Exchange
+	for i := range r.Exchanges {
+		exchange := &r.Exchanges[i]
+		if exchange.Method != "GET" || exchange.Status != 200 || exchange.ResponseBody == "" {
+			continue
+		}
+		u, err := url.Parse(exchange.URL)
+		if err != nil {
+			continue
+		}
+		pod, ok := logPod(u.Path, namespace)
+		if !ok {
+			continue
+		}
+		container := u.Query().Get("container")
+		jobPod := strings.HasPrefix(pod, jobName+"-") && container == "profiler"
+		if !jobPod && container != jobName {
+			continue
+		}
+		if u.Query().Get("follow") == "true" {
+			followed = exchange
+		} else {
+			found = exchange
+		}
+	}
+	if found == nil {
+		found = followed
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no profiler logs of %s/%s in the recording", namespace, jobName)
+	}
+	return os.Open(filepath.Join(r.Dir, found.ResponseBody))
+}
+
+// logPod returns the pod of a log request path in a namespace
+func logPod(path, namespace string) (string, bool) {
+	prefix := "/api/v1/namespaces/" + namespace + "/pods/"
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, "/log") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(path, prefix), "/log"), true
+}
@@ -0,0 +1,283 @@
+// Package selftest runs an end-to-end smoke test of the profiling pipeline
+// against one of the bundled example workloads.
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/config"
+	"github.com/withlin/kubectl-pprof/pkg/profiler"
+)
+
+// Workload describes one of the programs shipped in the example/ directory
+type Workload struct {
+	Name         string
+	Command      []string
+	HotFunctions []string // Functions that must show up in the flame graph
+}
+
+// workloads lists the example programs built into the example image
+var workloads = map[string]Workload{
+	"oncpu": {
+		Name:         "oncpu",
+		Command:      []string{"/usr/local/bin/test_oncpu"},
+		HotFunctions: []string{"cpuIntensiveTask"},
+	},
+	"offcpu": {
+		Name:         "offcpu",
+		Command:      []string{"/usr/local/bin/test_offcpu"},
+		HotFunctions: []string{"cpuIntensiveTask"},
+	},
+	"mixed": {
+		Name:         "mixed",
+		Command:      []string{"/usr/local/bin/test_mixed"},
+		HotFunctions: []string{"cpuIntensiveTask", "mixedTask"},
+	},
+}
+
+// WorkloadNames returns the names of all bundled workloads
+func WorkloadNames() []string {
+	names := make([]string, 0, len(workloads))
+	for name := range workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options selftest options
+type Options struct {
+	Namespace     string        // Sandbox namespace, created when missing
+	Workload      string        // One of WorkloadNames()
+	WorkloadImage string        // Image containing the example binaries
+	ReadyTimeout  time.Duration // How long to wait for the workload pod
+	KeepResources bool          // Skip cleanup for debugging
+}
+
+// Result selftest result
+type Result struct {
+	Workload         string        `json:"workload"`
+	Namespace        string        `json:"namespace"`
+	PodName          string        `json:"podName"`
+	OutputPath       string        `json:"outputPath"`
+	FoundFunctions   []string      `json:"foundFunctions"`
+	MissingFunctions []string      `json:"missingFunctions,omitempty"`
+	Duration         time.Duration `json:"duration"`
+	Passed           bool          `json:"passed"`
+}
+
+// Runner deploys a workload, profiles it and verifies the artifact
+type Runner struct {
+	k8sConfig *config.KubernetesConfig
+	profiler  *profiler.Profiler
+	logger    *slog.Logger
+}
+
+// NewRunner creates a new selftest runner
+func NewRunner(k8sConfig *config.KubernetesConfig, logger *slog.Logger) (*Runner, error) {
+	p, err := profiler.NewProfiler(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profiler: %w", err)
+	}
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	return &Runner{
+		k8sConfig: k8sConfig,
+		profiler:  p,
+		logger:    logger,
+	}, nil
+}
+
+// Run executes the smoke test. cfg and profileOpts carry the regular
+// profiling settings (image, duration, ...); the target fields are
+// overwritten to point at the deployed workload.
+func (r *Runner) Run(ctx context.Context, opts *Options, cfg *api.ProfileConfig, profileOpts *api.ProfileOptions) (*Result, error) {
+	workload, ok := workloads[opts.Workload]
+	if !ok {
+		return nil, fmt.Errorf("unknown workload %q, must be one of: %s", opts.Workload, strings.Join(WorkloadNames(), ", "))
+	}
+
+	start := time.Now()
+	result := &Result{
+		Workload:   workload.Name,
+		Namespace:  opts.Namespace,
+		OutputPath: cfg.OutputPath,
+	}
+
+	createdNamespace, err := r.ensureNamespace(ctx, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := r.createWorkloadPod(ctx, opts, workload)
+	if err != nil {
+		if createdNamespace && !opts.KeepResources {
+			r.deleteNamespace(opts.Namespace)
+		}
+		return nil, err
+	}
+	result.PodName = pod.Name
+
+	if !opts.KeepResources {
+		defer r.cleanup(pod.Name, opts.Namespace, createdNamespace)
+	}
+
+	r.logger.Info("Waiting for workload pod to become ready", "namespace", opts.Namespace, "pod", pod.Name)
+	if err := r.waitForPodRunning(ctx, opts.Namespace, pod.Name, opts.ReadyTimeout); err != nil {
+		return nil, err
+	}
+
+	// Point the profiling session at the workload
+	targetCfg := *cfg
+	targetCfg.Namespace = opts.Namespace
+	targetCfg.PodName = pod.Name
+	targetCfg.ContainerName = workloadContainerName(workload)
+
+	r.logger.Info("Profiling workload", "duration", targetCfg.Duration)
+	profileResult, err := r.profiler.Profile(ctx, &targetCfg, profileOpts)
+	if err != nil {
+		return nil, fmt.Errorf("profiling workload failed: %w", err)
+	}
+	result.OutputPath = profileResult.OutputPath
+
+	// Verify the artifact contains the expected hot functions
+	data, err := os.ReadFile(profileResult.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiling artifact: %w", err)
+	}
+	for _, fn := range workload.HotFunctions {
+		if strings.Contains(string(data), fn) {
+			result.FoundFunctions = append(result.FoundFunctions, fn)
+		} else {
+			result.MissingFunctions = append(result.MissingFunctions, fn)
+		}
+	}
+
+	result.Passed = len(result.MissingFunctions) == 0
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// ensureNamespace creates the sandbox namespace when missing and reports whether it did
+func (r *Runner) ensureNamespace(ctx context.Context, namespace string) (bool, error) {
+	namespaces := r.k8sConfig.Clientset.CoreV1().Namespaces()
+	if _, err := namespaces.Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+		return false, nil
+	} else if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	r.logger.Info("Creating sandbox namespace", "namespace", namespace)
+	_, err := namespaces.Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespace,
+			Labels: map[string]string{
+				"app": "kubectl-pprof-selftest",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+	}
+	return true, nil
+}
+
+// createWorkloadPod deploys the example workload
+func (r *Runner) createWorkloadPod(ctx context.Context, opts *Options, workload Workload) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("kubectl-pprof-selftest-%s-%d", workload.Name, time.Now().Unix()),
+			Namespace: opts.Namespace,
+			Labels: map[string]string{
+				"app":      "kubectl-pprof-selftest",
+				"workload": workload.Name,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:            workloadContainerName(workload),
+					Image:           opts.WorkloadImage,
+					Command:         workload.Command,
+					ImagePullPolicy: corev1.PullIfNotPresent,
+				},
+			},
+		},
+	}
+
+	r.logger.Info("Deploying workload", "workload", workload.Name, "image", opts.WorkloadImage)
+	created, err := r.k8sConfig.Clientset.CoreV1().Pods(opts.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workload pod: %w", err)
+	}
+	return created, nil
+}
+
+// waitForPodRunning waits until the workload pod is running
+func (r *Runner) waitForPodRunning(ctx context.Context, namespace, podName string, timeout time.Duration) error {
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := r.k8sConfig.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodSucceeded, corev1.PodFailed:
+			return false, fmt.Errorf("workload pod %s exited early (phase: %s)", podName, pod.Status.Phase)
+		default:
+			return false, nil
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("workload pod did not become ready: %w", err)
+	}
+	return nil
+}
+
+// cleanup removes the workload pod and the sandbox namespace if we created it
+func (r *Runner) cleanup(podName, namespace string, deleteNamespace bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.logger.Info("Cleaning up workload pod", "namespace", namespace, "pod", podName)
+	if err := r.k8sConfig.Clientset.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		r.logger.Warn("Failed to delete workload pod", "error", err)
+	}
+
+	if deleteNamespace {
+		r.deleteNamespace(namespace)
+	}
+}
+
+// deleteNamespace deletes the sandbox namespace
+func (r *Runner) deleteNamespace(namespace string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.logger.Info("Deleting sandbox namespace", "namespace", namespace)
+	if err := r.k8sConfig.Clientset.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		r.logger.Warn("Failed to delete namespace", "error", err)
+	}
+}
+
+// workloadContainerName returns a container name unlikely to collide with
+// other containers on the node, since the job locates it via crictl
+func workloadContainerName(workload Workload) string {
+	return "pprof-selftest-" + workload.Name
+}
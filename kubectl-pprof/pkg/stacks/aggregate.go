@@ -0,0 +1,208 @@
+package stacks
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Aggregator merges samples as they are read, holding one entry per distinct
+// stack instead of one per line. With MaxStacks set, the stacks held so far
+// are spilled to a sorted file on disk whenever that many are in memory, and
+// the files are merged back when the profile is read.
+type Aggregator struct {
+	// MaxStacks bounds the distinct stacks held in memory, 0 for no bound
+	MaxStacks int
+	// SpillDir receives the spilled stacks, the system temp dir when empty
+	SpillDir string
+
+	values map[string]int64
+	// order keeps the stacks in the order they are first seen
+	order []string
+	runs  []string
+}
+
+// Add merges a sample into the aggregate
+func (a *Aggregator) Add(s Sample) error {
+	if a.values == nil {
+		a.values = make(map[string]int64)
+	}
+	key := Key(s.Stack)
+	if _, ok := a.values[key]; !ok {
+		a.order = append(a.order, key)
+	}
+	a.values[key] += s.Value
+
+	if a.MaxStacks > 0 && len(a.values) >= a.MaxStacks {
+		return a.spill()
+	}
+	return nil
+}
+
+// Spilled reports whether stacks were spilled to disk
+func (a *Aggregator) Spilled() bool {
+	return len(a.runs) > 0
+}
+
+// Profile returns the merged stacks and releases the spill files. Stacks keep
+// the order they are first seen in, unless they were spilled, in which case
+// they are ordered by stack.
+func (a *Aggregator) Profile() (*Profile, error) {
+	defer a.Close()
+
+	if len(a.runs) == 0 {
+		profile := &Profile{Samples: make([]Sample, 0, len(a.order))}
+		for _, key := range a.order {
+			profile.Samples = append(profile.Samples, Sample{Stack: SplitKey(key), Value: a.values[key]})
+		}
+		a.values, a.order = nil, nil
+		return profile, nil
+	}
+
+	if len(a.values) > 0 {
+		if err := a.spill(); err != nil {
+			return nil, err
+		}
+	}
+	return a.mergeRuns()
+}
+
+// Close removes the spill files
+func (a *Aggregator) Close() {
+	for _, run := range a.runs {
+		os.Remove(run)
+	}
+	a.runs = nil
+}
+
+// spill writes the stacks in memory to a file sorted by stack
+func (a *Aggregator) spill() error {
+	sort.Strings(a.order)
+
+	f, err := os.CreateTemp(a.SpillDir, "kubectl-pprof-stacks-*.folded")
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %w", err)
+	}
+	a.runs = append(a.runs, f.Name())
+
+	bw := bufio.NewWriter(f)
+	for _, key := range a.order {
+		if _, err := fmt.Fprintf(bw, "%s %d\n", key, a.values[key]); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write spill file: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+
+	a.values = make(map[string]int64)
+	a.order = nil
+	return nil
+}
+
+// mergeRuns merges the sorted spill files, summing the stacks found in
+// several of them
+func (a *Aggregator) mergeRuns() (*Profile, error) {
+	h := &runHeap{}
+	for _, run := range a.runs {
+		f, err := os.Open(run)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spill file: %w", err)
+		}
+		defer f.Close()
+
+		r := &runReader{decoder: NewDecoder(f)}
+		ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Push(h, r)
+		}
+	}
+
+	profile := &Profile{}
+	lastKey := ""
+	for h.Len() > 0 {
+		r := (*h)[0]
+		if last := len(profile.Samples) - 1; last >= 0 && lastKey == r.key {
+			profile.Samples[last].Value += r.sample.Value
+		} else {
+			profile.Samples = append(profile.Samples, r.sample)
+			lastKey = r.key
+		}
+
+		ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return profile, nil
+}
+
+// runReader is the current sample of a spill file
+type runReader struct {
+	decoder *Decoder
+	sample  Sample
+	key     string
+}
+
+// next advances to the next sample, false at the end of the file
+func (r *runReader) next() (bool, error) {
+	sample, err := r.decoder.Next()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	r.sample, r.key = sample, Key(sample.Stack)
+	return true, nil
+}
+
+// runHeap orders spill files by their current stack
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].key < h[j].key }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// Aggregate reads every sample of the decoder into the aggregator and returns
+// the merged profile
+func Aggregate(d *Decoder, a *Aggregator) (*Profile, error) {
+	for {
+		sample, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return a.Profile()
+		}
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		if err := a.Add(sample); err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+}
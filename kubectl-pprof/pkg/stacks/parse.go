@@ -0,0 +1,173 @@
+package stacks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// utf8BOM is dropped from the first line, editors on Windows add it
+const utf8BOM = "\ufeff"
+
+// SyntaxError is a line that is not a valid folded stack
+type SyntaxError struct {
+	// Line number, starting at 1
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads folded stacks one line at a time, so callers can aggregate
+// profiles without holding every sample in memory
+type Decoder struct {
+	// Lenient skips malformed lines instead of failing on them, they are
+	// counted in Skipped
+	Lenient bool
+	// Skipped is the number of malformed lines skipped so far
+	Skipped int
+
+	reader   *bufio.Reader
+	timeline bool
+	line     int
+}
+
+// NewDecoder returns a decoder of the folded format
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: bufio.NewReader(r)}
+}
+
+// NewTimelineDecoder returns a decoder of the timeline format
+func NewTimelineDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: bufio.NewReader(r), timeline: true}
+}
+
+// Next returns the next sample with a positive value, or io.EOF once the
+// input is exhausted. Malformed lines fail with a *SyntaxError unless the
+// decoder is lenient.
+func (d *Decoder) Next() (Sample, error) {
+	for {
+		line, err := d.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return Sample{}, fmt.Errorf("failed to read stacks: %w", err)
+		}
+		if err == io.EOF && line == "" {
+			return Sample{}, io.EOF
+		}
+
+		d.line++
+		if d.line == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var sample Sample
+		var perr error
+		if d.timeline {
+			sample, perr = parseTimelineLine(text)
+		} else {
+			sample, perr = parseFoldedLine(text)
+		}
+		if perr != nil {
+			if d.Lenient {
+				d.Skipped++
+				continue
+			}
+			return Sample{}, &SyntaxError{Line: d.line, Err: perr}
+		}
+		if sample.Value > 0 {
+			return sample, nil
+		}
+	}
+}
+
+// Parse parses folded stacks
+func Parse(r io.Reader) (*Profile, error) {
+	return decodeAll(NewDecoder(r))
+}
+
+// ParseTimeline parses timeline stacks
+func ParseTimeline(r io.Reader) (*Profile, error) {
+	return decodeAll(NewTimelineDecoder(r))
+}
+
+// decodeAll collects every sample of the decoder
+func decodeAll(d *Decoder) (*Profile, error) {
+	profile := &Profile{}
+	for {
+		sample, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return profile, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		profile.Samples = append(profile.Samples, sample)
+	}
+}
+
+// parseTimelineLine parses a single "offset_ms stack count" line
+func parseTimelineLine(line string) (Sample, error) {
+	offset, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return Sample{}, fmt.Errorf("missing offset")
+	}
+	ms, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || ms < 0 {
+		return Sample{}, fmt.Errorf("invalid offset %q", offset)
+	}
+	sample, err := parseFoldedLine(strings.TrimSpace(rest))
+	if err != nil {
+		return Sample{}, err
+	}
+	sample.Offset = time.Duration(ms) * time.Millisecond
+	return sample, nil
+}
+
+// parseFoldedLine parses a single "stack count" line. Tabs separate the count
+// as well as spaces, and the empty frames left by stray semicolons are
+// dropped.
+func parseFoldedLine(line string) (Sample, error) {
+	i := strings.LastIndexAny(line, " \t")
+	if i <= 0 {
+		return Sample{}, fmt.Errorf("missing sample count")
+	}
+
+	stack, count := strings.TrimSpace(line[:i]), line[i+1:]
+	value, err := strconv.ParseInt(count, 10, 64)
+	if err != nil {
+		// flamegraph.pl also accepts fractional counts
+		f, ferr := strconv.ParseFloat(count, 64)
+		if ferr != nil || math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > math.MaxInt64 {
+			return Sample{}, fmt.Errorf("invalid sample count %q", count)
+		}
+		value = int64(math.Round(f))
+	}
+
+	frames := strings.Split(stack, Separator)
+	n := 0
+	for _, frame := range frames {
+		if frame != "" {
+			frames[n] = frame
+			n++
+		}
+	}
+	if n == 0 {
+		return Sample{}, fmt.Errorf("empty stack")
+	}
+	return Sample{Stack: frames[:n], Value: value}, nil
+}
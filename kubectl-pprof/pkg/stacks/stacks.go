@@ -0,0 +1,129 @@
+// Package stacks reads, writes and merges folded stacks, the format shared by
+// the profilers, the flame graph renderer and every post-processing step.
+//
+// A folded stack is one line of text, the frames from the root to the leaf
+// joined by semicolons followed by a space and the sample count:
+//
+//	main.main;net/http.(*conn).serve;api.handle 42
+//
+// Blank lines and lines starting with # are ignored. The count may be
+// fractional, as flamegraph.pl accepts, and is rounded to a whole number.
+// The timeline variant written by golang-profiling --export-timeline
+// prefixes every line with the offset in milliseconds since profiling
+// started:
+//
+//	1500 main.main;api.handle 3
+package stacks
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Separator joins the frames of a folded stack
+const Separator = ";"
+
+// Sample 一条折叠堆栈记录
+type Sample struct {
+	// Stack frames, root first
+	Stack []string
+	// Number of samples (or off-CPU microseconds) attributed to the stack
+	Value int64
+	// Time since profiling started, only set for timeline input
+	Offset time.Duration
+	// runtime/pprof labels of the goroutine, only set for pprof input
+	Labels map[string][]string
+}
+
+// Profile 折叠堆栈数据
+type Profile struct {
+	Samples []Sample
+}
+
+// Total returns the sum of all sample values
+func (p *Profile) Total() int64 {
+	var total int64
+	for _, s := range p.Samples {
+		total += s.Value
+	}
+	return total
+}
+
+// Share returns value as a percentage of total, 0 for an empty total
+func Share(value, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(value) / float64(total)
+}
+
+// Key returns the folded form of a stack, which identifies it when merging
+func Key(stack []string) string {
+	return strings.Join(stack, Separator)
+}
+
+// SplitKey turns a Key back into frames
+func SplitKey(key string) []string {
+	return strings.Split(key, Separator)
+}
+
+// Merge returns one sample per distinct stack of the profiles, with the
+// values of identical stacks summed. Stacks keep the order they are first
+// seen in; offsets and labels are dropped, as they differ between the merged
+// samples.
+func Merge(profiles ...*Profile) *Profile {
+	merged := &Profile{}
+	index := make(map[string]int)
+	for _, p := range profiles {
+		for _, s := range p.Samples {
+			key := Key(s.Stack)
+			if i, ok := index[key]; ok {
+				merged.Samples[i].Value += s.Value
+				continue
+			}
+			index[key] = len(merged.Samples)
+			merged.Samples = append(merged.Samples, Sample{Stack: s.Stack, Value: s.Value})
+		}
+	}
+	return merged
+}
+
+// Deltas returns the per-stack change of value from before to after, keyed
+// by Key
+func Deltas(before, after *Profile) map[string]int64 {
+	deltas := make(map[string]int64)
+	for _, s := range after.Samples {
+		deltas[Key(s.Stack)] += s.Value
+	}
+	for _, s := range before.Samples {
+		deltas[Key(s.Stack)] -= s.Value
+	}
+	return deltas
+}
+
+// Prefix returns the samples of p with frame added as their root frame, so
+// the stacks of several profiles can be told apart once merged
+func Prefix(p *Profile, frame string) *Profile {
+	prefixed := &Profile{Samples: make([]Sample, 0, len(p.Samples))}
+	for _, s := range p.Samples {
+		s.Stack = append([]string{frame}, s.Stack...)
+		prefixed.Samples = append(prefixed.Samples, s)
+	}
+	return prefixed
+}
+
+// Top returns the n samples with the largest values, ties ordered by stack
+func Top(p *Profile, n int) []Sample {
+	top := append([]Sample(nil), p.Samples...)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Value != top[j].Value {
+			return top[i].Value > top[j].Value
+		}
+		return Key(top[i].Stack) < Key(top[j].Stack)
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
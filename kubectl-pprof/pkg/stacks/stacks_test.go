@@ -0,0 +1,278 @@
+package stacks_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []stacks.Sample
+	}{
+		{
+			name:  "folded",
+			input: "main.main;api.handle 42\nmain.main;runtime.gcBgMarkWorker 7\n",
+			want: []stacks.Sample{
+				{Stack: []string{"main.main", "api.handle"}, Value: 42},
+				{Stack: []string{"main.main", "runtime.gcBgMarkWorker"}, Value: 7},
+			},
+		},
+		{
+			name:  "blank lines, comments and a byte order mark",
+			input: "\ufeff# golang-profiling\n\nmain.main 1\n   \n# end\n",
+			want:  []stacks.Sample{{Stack: []string{"main.main"}, Value: 1}},
+		},
+		{
+			name:  "no trailing newline",
+			input: "main.main 3",
+			want:  []stacks.Sample{{Stack: []string{"main.main"}, Value: 3}},
+		},
+		{
+			name:  "tab before the count",
+			input: "main.main;api.handle\t5\n",
+			want:  []stacks.Sample{{Stack: []string{"main.main", "api.handle"}, Value: 5}},
+		},
+		{
+			name:  "frames with spaces",
+			input: "main.main;func1 (inlined) 2\n",
+			want:  []stacks.Sample{{Stack: []string{"main.main", "func1 (inlined)"}, Value: 2}},
+		},
+		{
+			name:  "fractional counts are rounded",
+			input: "a 1.4\nb 2.5\n",
+			want: []stacks.Sample{
+				{Stack: []string{"a"}, Value: 1},
+				{Stack: []string{"b"}, Value: 3},
+			},
+		},
+		{
+			name:  "stray semicolons",
+			input: ";main.main;;api.handle; 4\n",
+			want:  []stacks.Sample{{Stack: []string{"main.main", "api.handle"}, Value: 4}},
+		},
+		{
+			name:  "zero and negative counts are dropped",
+			input: "a 0\nb -2\nc 1\n",
+			want:  []stacks.Sample{{Stack: []string{"c"}, Value: 1}},
+		},
+		{
+			name:  "empty input",
+			input: "",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := stacks.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(profile.Samples, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", profile.Samples, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		line    int
+		wantErr string
+	}{
+		{name: "missing count", input: "main.main\n", line: 1, wantErr: "missing sample count"},
+		{name: "invalid count", input: "# header\nmain.main 1\nmain.main x\n", line: 3, wantErr: `invalid sample count "x"`},
+		{name: "infinite count", input: "main.main Inf\n", line: 1, wantErr: "invalid sample count"},
+		{name: "empty stack", input: ";; 3\n", line: 1, wantErr: "empty stack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := stacks.Parse(strings.NewReader(tt.input))
+			var syntaxErr *stacks.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse error = %v, want a *SyntaxError", err)
+			}
+			if syntaxErr.Line != tt.line || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse error = %v, want line %d and %q", err, tt.line, tt.wantErr)
+			}
+
+			d := stacks.NewDecoder(strings.NewReader(tt.input))
+			d.Lenient = true
+			for {
+				_, err := d.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("lenient Next: %v", err)
+				}
+			}
+			if d.Skipped != 1 {
+				t.Errorf("lenient decoder skipped %d lines, want 1", d.Skipped)
+			}
+		})
+	}
+}
+
+func TestParseTimeline(t *testing.T) {
+	profile, err := stacks.ParseTimeline(strings.NewReader("0 main.main;a 1\n1500 main.main;b 3\n"))
+	if err != nil {
+		t.Fatalf("ParseTimeline: %v", err)
+	}
+	want := []stacks.Sample{
+		{Stack: []string{"main.main", "a"}, Value: 1},
+		{Stack: []string{"main.main", "b"}, Value: 3, Offset: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(profile.Samples, want) {
+		t.Errorf("ParseTimeline = %+v, want %+v", profile.Samples, want)
+	}
+
+	for _, input := range []string{"main.main 1\n", "-1 main.main 1\n"} {
+		if _, err := stacks.ParseTimeline(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseTimeline(%q) succeeded", input)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	first := &stacks.Profile{Samples: []stacks.Sample{
+		{Stack: []string{"main", "a"}, Value: 1, Offset: time.Second},
+		{Stack: []string{"main", "b"}, Value: 2, Labels: map[string][]string{"handler": {"/api"}}},
+		{Stack: []string{"main", "a"}, Value: 3},
+	}}
+	second := &stacks.Profile{Samples: []stacks.Sample{
+		{Stack: []string{"main", "c"}, Value: 4},
+		{Stack: []string{"main", "b"}, Value: 5},
+	}}
+
+	merged := stacks.Merge(first, second)
+	want := []stacks.Sample{
+		{Stack: []string{"main", "a"}, Value: 4},
+		{Stack: []string{"main", "b"}, Value: 7},
+		{Stack: []string{"main", "c"}, Value: 4},
+	}
+	if !reflect.DeepEqual(merged.Samples, want) {
+		t.Errorf("Merge = %+v, want %+v", merged.Samples, want)
+	}
+	if merged.Total() != first.Total()+second.Total() {
+		t.Errorf("Merge total = %d, want %d", merged.Total(), first.Total()+second.Total())
+	}
+
+	if empty := stacks.Merge(); len(empty.Samples) != 0 {
+		t.Errorf("Merge() = %+v, want no samples", empty.Samples)
+	}
+}
+
+func TestMergeMatchesAggregator(t *testing.T) {
+	input := foldedInput(2000, 20000)
+	profile, err := stacks.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := stacks.Deltas(&stacks.Profile{}, stacks.Merge(profile))
+
+	for _, maxStacks := range []int{0, 100} {
+		t.Run(fmt.Sprintf("max stacks %d", maxStacks), func(t *testing.T) {
+			a := &stacks.Aggregator{MaxStacks: maxStacks, SpillDir: t.TempDir()}
+			aggregated, err := stacks.Aggregate(stacks.NewDecoder(strings.NewReader(input)), a)
+			if err != nil {
+				t.Fatalf("Aggregate: %v", err)
+			}
+			if got := stacks.Deltas(&stacks.Profile{}, aggregated); !reflect.DeepEqual(got, want) {
+				t.Errorf("Aggregate differs from Merge: %d stacks, want %d", len(got), len(want))
+			}
+			if len(aggregated.Samples) != len(want) {
+				t.Errorf("Aggregate returned %d samples for %d stacks", len(aggregated.Samples), len(want))
+			}
+		})
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	profile, err := stacks.Parse(strings.NewReader(foldedInput(100, 1000)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := stacks.Write(&buf, profile); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	parsed, err := stacks.Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse of written stacks: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, profile) {
+		t.Error("stacks changed through Write and Parse")
+	}
+}
+
+// foldedInput returns lines of folded stacks shaped like a CPU profile of a
+// Go HTTP server: distinct stacks 4 to 36 frames deep below a few common
+// roots, most of the lines repeating a hot stack with a small count
+func foldedInput(distinct, lines int) string {
+	rng := rand.New(rand.NewSource(1))
+	roots := [][]string{
+		{"runtime.goexit", "net/http.(*conn).serve", "net/http.serverHandler.ServeHTTP", "net/http.(*ServeMux).ServeHTTP"},
+		{"runtime.goexit", "runtime.gcBgMarkWorker", "runtime.gcDrain"},
+		{"runtime.goexit", "main.(*worker).run", "main.(*worker).process"},
+		{"runtime.mcall", "runtime.park_m", "runtime.schedule", "runtime.findRunnable"},
+	}
+	packages := []string{"api", "store", "encoding/json", "database/sql", "github.com/lib/pq", "compress/gzip", "runtime", "syscall"}
+
+	keys := make([]string, distinct)
+	for i := range keys {
+		stack := append([]string(nil), roots[rng.Intn(len(roots))]...)
+		for depth := 4 + rng.Intn(33); len(stack) < depth; {
+			pkg := packages[rng.Intn(len(packages))]
+			stack = append(stack, fmt.Sprintf("%s.(*T%d).method%d", pkg, rng.Intn(50), rng.Intn(20)))
+		}
+		keys[i] = stacks.Key(stack)
+	}
+
+	var b strings.Builder
+	for i := 0; i < lines; i++ {
+		// Skewed towards the first stacks, as CPU profiles are
+		key := keys[int(float64(distinct)*rng.Float64()*rng.Float64())]
+		fmt.Fprintf(&b, "%s %d\n", key, 1+rng.Intn(10))
+	}
+	return b.String()
+}
+
+func BenchmarkParse(b *testing.B) {
+	input := foldedInput(5000, 50000)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := stacks.Parse(strings.NewReader(input)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	// One profile per replica of a deployment, running the same code
+	var profiles []*stacks.Profile
+	for i := 0; i < 10; i++ {
+		profile, err := stacks.Parse(strings.NewReader(foldedInput(5000, 50000)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		profiles = append(profiles, profile)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		stacks.Merge(profiles...)
+	}
+}
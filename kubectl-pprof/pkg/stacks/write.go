@@ -0,0 +1,18 @@
+package stacks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Write writes stacks in the folded format read by Parse
+func Write(w io.Writer, p *Profile) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range p.Samples {
+		if _, err := fmt.Fprintf(bw, "%s %d\n", Key(s.Stack), s.Value); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention bounds the sessions kept in a store, zero fields are unbounded
+type Retention struct {
+	// Keep at most this many sessions, the newest
+	Keep int
+	// Drop sessions older than this
+	OlderThan time.Duration
+	// Drop the oldest sessions until the store fits in this many bytes
+	MaxSize int64
+}
+
+// Expired returns the sessions, newest first as returned by List, that the
+// retention drops at now
+func (r Retention) Expired(sessions []*Session, now time.Time) []*Session {
+	var (
+		expired []*Session
+		size    int64 // Of the sessions kept
+		full    bool
+	)
+	for i, session := range sessions {
+		if r.Keep > 0 && i >= r.Keep || r.OlderThan > 0 && now.Sub(session.Time) > r.OlderThan {
+			expired = append(expired, session)
+			continue
+		}
+		// Past the first session that does not fit, the older ones go too
+		if r.MaxSize > 0 && size+session.Size > r.MaxSize {
+			full = true
+		}
+		if full {
+			expired = append(expired, session)
+			continue
+		}
+		size += session.Size
+	}
+	return expired
+}
+
+// Prune removes the sessions the retention drops and returns them with the
+// bytes freed. With dryRun nothing is removed.
+func (s *Store) Prune(r Retention, now time.Time, dryRun bool) ([]*Session, int64, error) {
+	sessions, err := s.List()
+	if err != nil {
+		return nil, 0, err
+	}
+	expired := r.Expired(sessions, now)
+
+	var freed int64
+	for i, session := range expired {
+		if !dryRun {
+			if err := s.Remove(session); err != nil {
+				return expired[:i], freed, err
+			}
+		}
+		freed += session.Size
+	}
+	return expired, freed, nil
+}
+
+// ParseAge parses a duration that also accepts days, e.g. "30d" or "1d12h"
+func ParseAge(value string) (time.Duration, error) {
+	days := time.Duration(0)
+	if before, after, ok := strings.Cut(value, "d"); ok {
+		n, err := strconv.Atoi(before)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		if value = after; value == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, use e.g. 30d, 12h or 1d12h", value)
+	}
+	return days + d, nil
+}
+
+// FormatSize renders a byte count for humans
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
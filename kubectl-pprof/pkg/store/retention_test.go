@@ -0,0 +1,112 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "30d", want: 30 * 24 * time.Hour},
+		{value: "1d12h", want: 36 * time.Hour},
+		{value: "0d", want: 0},
+		{value: "12h", want: 12 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "1d30m", want: 24*time.Hour + 30*time.Minute},
+		{value: "", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "7", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "1.5d", wantErr: true},
+		{value: "1d-1h", wantErr: true},
+		{value: "1dd", wantErr: true},
+		{value: "1w", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseAge(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAge(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAge(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAge(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	// Newest first as List returns them: s0 an hour old, s1 two hours old...
+	// each of 100 bytes but s1 of 300
+	var sessions []*Session
+	for i := 0; i < 5; i++ {
+		size := int64(100)
+		if i == 1 {
+			size = 300
+		}
+		sessions = append(sessions, &Session{
+			ID:   fmt.Sprintf("s%d", i),
+			Time: now.Add(-time.Duration(i+1) * time.Hour),
+			Size: size,
+		})
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		want      []string
+	}{
+		{name: "unbounded", retention: Retention{}},
+		{name: "keep", retention: Retention{Keep: 3}, want: []string{"s3", "s4"}},
+		{name: "older than", retention: Retention{OlderThan: 150 * time.Minute}, want: []string{"s2", "s3", "s4"}},
+		{name: "max size", retention: Retention{MaxSize: 500}, want: []string{"s3", "s4"}},
+		{name: "max size drops every older session", retention: Retention{MaxSize: 350}, want: []string{"s1", "s2", "s3", "s4"}},
+		{name: "max size below the newest", retention: Retention{MaxSize: 50}, want: []string{"s0", "s1", "s2", "s3", "s4"}},
+		{
+			name:      "keep and older than",
+			retention: Retention{Keep: 4, OlderThan: 150 * time.Minute},
+			want:      []string{"s2", "s3", "s4"},
+		},
+		{
+			// s0 and s1 fit in 400 bytes, the sessions dropped by Keep do not count
+			name:      "keep and max size",
+			retention: Retention{Keep: 2, MaxSize: 400},
+			want:      []string{"s2", "s3", "s4"},
+		},
+		{
+			name:      "older than and max size",
+			retention: Retention{OlderThan: 150 * time.Minute, MaxSize: 400},
+			want:      []string{"s2", "s3", "s4"},
+		},
+		{
+			name:      "all three",
+			retention: Retention{Keep: 4, OlderThan: 210 * time.Minute, MaxSize: 300},
+			want:      []string{"s1", "s2", "s3", "s4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, session := range tt.retention.Expired(sessions, now) {
+				got = append(got, session.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
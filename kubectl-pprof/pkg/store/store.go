@@ -0,0 +1,348 @@
+// Package store archives the artifacts of profiling sessions in a local
+// directory, one subdirectory per session holding the artifacts and
+// session.json, so sessions can be listed, compared and pruned later.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"k8s.io/client-go/util/homedir"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// MetaFile describes a stored session, sessions without it are incomplete
+const MetaFile = "session.json"
+
+// DirEnv overrides the default store directory
+const DirEnv = "KUBECTL_PPROF_SESSIONS"
+
+// ZstdExt is appended to the names of the artifacts stored compressed
+const ZstdExt = ".zst"
+
+// idTimeFormat starts session IDs so they sort by time
+const idTimeFormat = "20060102-150405"
+
+// unsafeIDChars are replaced in the target part of session IDs
+var unsafeIDChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Session is a stored profiling session
+type Session struct {
+	ID            string        `json:"id"`
+	Time          time.Time     `json:"time"`
+	Namespace     string        `json:"namespace"`
+	PodName       string        `json:"podName"`
+	ContainerName string        `json:"containerName,omitempty"`
+	NodeName      string        `json:"nodeName,omitempty"`
+	ProfileType   string        `json:"profileType"`
+	Duration      time.Duration `json:"duration"`
+	Frequency     int           `json:"frequency,omitempty"`
+	Samples       int64         `json:"samples,omitempty"`
+	// Artifact file names, relative to the session directory
+	Output   string `json:"output,omitempty"`
+	Folded   string `json:"folded,omitempty"`
+	Timeline string `json:"timeline,omitempty"`
+	Pprof    string `json:"pprof,omitempty"`
+
+	// Directory and size on disk, filled in when the session is read
+	Dir  string `json:"-"`
+	Size int64  `json:"-"`
+}
+
+// Target returns namespace/pod[/container]
+func (s *Session) Target() string {
+	target := s.Namespace + "/" + s.PodName
+	if s.ContainerName != "" {
+		target += "/" + s.ContainerName
+	}
+	return target
+}
+
+// Path returns the path of an artifact of the session, "" when not stored
+func (s *Session) Path(name string) string {
+	if name == "" {
+		return ""
+	}
+	return filepath.Join(s.Dir, name)
+}
+
+// Stacks returns the stored stacks of the session to analyze it by, the
+// folded stacks, else the raw pprof profile, else the timeline
+func (s *Session) Stacks() (string, error) {
+	for _, name := range []string{s.Folded, s.Pprof, s.Timeline} {
+		if name != "" {
+			return s.Path(name), nil
+		}
+	}
+	return "", fmt.Errorf("session %s stores no stacks", s.ID)
+}
+
+// Store is a directory of sessions
+type Store struct {
+	Dir string
+}
+
+// New returns the store in dir, DefaultDir() when empty
+func New(dir string) *Store {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	return &Store{Dir: dir}
+}
+
+// DefaultDir returns $KUBECTL_PPROF_SESSIONS, else ~/.kubectl-pprof/sessions
+func DefaultDir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(homedir.HomeDir(), ".kubectl-pprof", "sessions")
+}
+
+// Save copies the artifacts of a finished session into the store
+func (s *Store) Save(result *api.ProfileResult) (*Session, error) {
+	session := &Session{Time: time.Now().UTC(), Samples: result.Samples}
+	if cfg := result.Config; cfg != nil {
+		session.Namespace = cfg.Namespace
+		session.PodName = cfg.PodName
+		session.ContainerName = cfg.ContainerName
+		session.ProfileType = cfg.ProfileType
+		session.Duration = cfg.Duration
+	}
+	if meta := result.Metadata; meta != nil {
+		session.Namespace = meta.Namespace
+		session.PodName = meta.PodName
+		session.ContainerName = meta.ContainerName
+		session.NodeName = meta.NodeName
+		session.Duration = meta.Duration
+		session.Frequency = meta.Frequency
+		if !meta.StartTime.IsZero() {
+			session.Time = meta.StartTime.UTC()
+		}
+	}
+
+	dir, err := s.create(session)
+	if err != nil {
+		return nil, err
+	}
+	session.Dir = dir
+
+	// Text stacks are stored zstd compressed, they shrink tenfold
+	for _, artifact := range []struct {
+		src      string
+		name     *string
+		compress bool
+	}{
+		{result.OutputPath, &session.Output, false},
+		{result.FoldedPath, &session.Folded, true},
+		{result.TimelinePath, &session.Timeline, true},
+		{result.PprofPath, &session.Pprof, false},
+	} {
+		if artifact.src == "" {
+			continue
+		}
+		name := filepath.Base(artifact.src)
+		store := copyFile
+		if artifact.compress {
+			name += ZstdExt
+			store = compressFile
+		}
+		if err := store(artifact.src, filepath.Join(dir, name)); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to store %s: %w", artifact.src, err)
+		}
+		*artifact.name = name
+	}
+
+	// The metadata comes last, it marks the session complete
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MetaFile), data, 0644); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to write session: %w", err)
+	}
+	session.Size, _ = dirSize(dir)
+	return session, nil
+}
+
+// create makes the directory of a new session and sets its ID
+func (s *Store) create(session *Session) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create session store: %w", err)
+	}
+	base := session.Time.Format(idTimeFormat) + "-" + strings.Trim(unsafeIDChars.ReplaceAllString(session.PodName, "_"), "_")
+	for i := 1; ; i++ {
+		session.ID = base
+		if i > 1 {
+			session.ID = fmt.Sprintf("%s-%d", base, i)
+		}
+		dir := filepath.Join(s.Dir, session.ID)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to create session directory: %w", err)
+		}
+	}
+}
+
+// List returns the complete sessions of the store, newest first
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		session, err := read(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			// Interrupted saves and foreign directories are not sessions
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Time.Equal(sessions[j].Time) {
+			return sessions[i].Time.After(sessions[j].Time)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// Get returns a session by ID, unique ID prefix, or session directory path
+func (s *Store) Get(ref string) (*Session, error) {
+	if info, err := os.Stat(filepath.Join(ref, MetaFile)); err == nil && !info.IsDir() {
+		return read(ref)
+	}
+
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Session
+	for _, session := range sessions {
+		if session.ID == ref {
+			return session, nil
+		}
+		if strings.HasPrefix(session.ID, ref) {
+			matches = append(matches, session)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("session %q not found in %s", ref, s.Dir)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("session %q is ambiguous, it matches %d sessions", ref, len(matches))
+	}
+}
+
+// read loads the session stored in dir
+func read(dir string) (*Session, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", dir, err)
+	}
+	session.Dir = dir
+	session.Size, _ = dirSize(dir)
+	return session, nil
+}
+
+// Remove deletes a stored session
+func (s *Store) Remove(session *Session) error {
+	if err := os.RemoveAll(session.Dir); err != nil {
+		return fmt.Errorf("failed to remove session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+// copyFile copies src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// compressFile writes src zstd compressed to dst
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	encoder, err := zstd.NewWriter(out)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := io.Copy(encoder, in); err != nil {
+		encoder.Close()
+		out.Close()
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// dirSize sums the sizes of the files under dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,151 @@
+// Package summary writes a compact markdown summary of a profile: the session
+// facts, the hottest functions and stacks, the change against a baseline and
+// the findings of the analysis rules. It is sized to be pasted into an
+// incident document or a chat with a language model, which SVG and folded
+// files are far too large for.
+package summary
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+	"github.com/withlin/kubectl-pprof/pkg/compare"
+	"github.com/withlin/kubectl-pprof/pkg/flamegraph"
+	"github.com/withlin/kubectl-pprof/pkg/stacks"
+)
+
+// Limits keep the summary within a few kilobytes whatever the profile size
+const (
+	maxFunctions = 15
+	maxStacks    = 10
+	maxChanges   = 10
+	// Stacks deeper than this keep their root and leaf frames around an elision
+	maxStackFrames = 12
+	rootFrames     = 3
+)
+
+// Summary is the content of a profile summary
+type Summary struct {
+	Title     string
+	Facts     []flamegraph.Fact
+	Profile   *flamegraph.Profile
+	CountName string
+	// Profile the changes are computed against, nil to skip them
+	Baseline      *flamegraph.Profile
+	BaselineLabel string
+	// Percentage points a function must change by to be listed
+	Threshold float64
+	Findings  []api.Finding
+}
+
+// WriteMarkdown writes the summary as markdown
+func (s Summary) WriteMarkdown(w io.Writer) error {
+	countName := s.CountName
+	if countName == "" {
+		countName = "samples"
+	}
+	total := s.Profile.Total()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", s.Title)
+	for _, fact := range s.Facts {
+		fmt.Fprintf(&b, "- %s: %s\n", fact.Name, fact.Value)
+	}
+	fmt.Fprintf(&b, "- Total: %d %s in %d distinct stacks\n", total, countName, len(s.Profile.Samples))
+
+	b.WriteString("\n## Top functions\n\n")
+	b.WriteString("Self is the share of samples with the function as the leaf frame, total with it anywhere on the stack.\n\n")
+	b.WriteString("| Function | Self | Total |\n|---|---:|---:|\n")
+	for _, stat := range topFunctions(s.Profile, maxFunctions) {
+		fmt.Fprintf(&b, "| `%s` | %.1f%% | %.1f%% |\n", cell(stat.Name), stacks.Share(stat.Self, total), stacks.Share(stat.Total, total))
+	}
+
+	b.WriteString("\n## Top stacks\n\nRoot first, leaf last.\n\n")
+	for i, sample := range topStacks(s.Profile, maxStacks) {
+		fmt.Fprintf(&b, "%d. %.1f%%: `%s`\n", i+1, stacks.Share(sample.Value, total), strings.Join(elide(sample.Stack), " > "))
+	}
+
+	if s.Baseline != nil {
+		report := compare.Profiles(s.Baseline, s.Profile, s.BaselineLabel, "", s.Threshold)
+		fmt.Fprintf(&b, "\n## Changes against %s\n\n", s.BaselineLabel)
+		fmt.Fprintf(&b, "Functions whose share of samples changed by at least %g percentage points.\n\n", s.Threshold)
+		changes := append(append([]compare.Delta(nil), report.Regressions...), report.Improvements...)
+		if len(changes) == 0 {
+			b.WriteString("None.\n")
+		} else {
+			sort.SliceStable(changes, func(i, j int) bool {
+				return magnitude(changes[i]) > magnitude(changes[j])
+			})
+			if len(changes) > maxChanges {
+				changes = changes[:maxChanges]
+			}
+			b.WriteString("| Function | Self | Δ Self | Total | Δ Total |\n|---|---:|---:|---:|---:|\n")
+			for _, d := range changes {
+				fmt.Fprintf(&b, "| `%s` | %.1f%% | %+.1f | %.1f%% | %+.1f |\n", cell(d.Name), d.Self, d.SelfDelta(), d.Total, d.TotalDelta())
+			}
+		}
+	}
+
+	if len(s.Findings) > 0 {
+		b.WriteString("\n## Findings\n\n")
+		for i, f := range s.Findings {
+			fmt.Fprintf(&b, "%d. %s: %.1f%% of samples, in `%s`. %s.\n", i+1, f.Title, f.Share, strings.Join(f.Frames, "`, `"), f.Recommendation)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// topFunctions returns the n functions with the most self samples
+func topFunctions(p *flamegraph.Profile, n int) []*flamegraph.FunctionStat {
+	functions := flamegraph.Functions(p)
+	stats := make([]*flamegraph.FunctionStat, 0, len(functions))
+	for _, stat := range functions {
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Self != stats[j].Self {
+			return stats[i].Self > stats[j].Self
+		}
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// topStacks returns the n stacks with the most samples, identical stacks merged
+func topStacks(p *flamegraph.Profile, n int) []flamegraph.Sample {
+	return stacks.Top(stacks.Merge(p), n)
+}
+
+// elide shortens a deep stack to its root frames and the frames nearest the
+// leaf, which tell most about where the time goes
+func elide(stack []string) []string {
+	if len(stack) <= maxStackFrames {
+		return stack
+	}
+	leaf := maxStackFrames - rootFrames
+	elided := append([]string(nil), stack[:rootFrames]...)
+	elided = append(elided, fmt.Sprintf("… %d frames …", len(stack)-maxStackFrames))
+	return append(elided, stack[len(stack)-leaf:]...)
+}
+
+// magnitude is the larger absolute change of a function
+func magnitude(d compare.Delta) float64 {
+	return math.Max(math.Abs(d.SelfDelta()), math.Abs(d.TotalDelta()))
+}
+
+// cell escapes a function name for a markdown table cell
+func cell(name string) string {
+	return strings.ReplaceAll(name, "|", "\\|")
+}
@@ -0,0 +1,120 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// podMetricsList is the body of the pods resource of the metrics API
+// (metrics-server), decoded here to avoid depending on its client
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Containers []struct {
+			Name  string `json:"name"`
+			Usage struct {
+				CPU string `json:"cpu"`
+			} `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// PodObserver observes the containers of watched pods through the API server
+type PodObserver struct {
+	Clientset kubernetes.Interface
+	Log       *slog.Logger
+	// Namespaces whose missing CPU usage was already logged
+	warned map[string]bool
+}
+
+// Observe observes the containers of the running pods a watch selects. The
+// CPU usage is read from the metrics API when withCPU is set, and stays
+// unknown when the API is not served.
+func (p *PodObserver) Observe(ctx context.Context, watch api.WatchTarget, withCPU bool) ([]Observation, error) {
+	pods, err := p.Clientset.CoreV1().Pods(watch.Namespace).List(ctx, metav1.ListOptions{LabelSelector: watch.Selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in %s: %w", watch.Namespace, err)
+	}
+
+	var usage map[string]int64 // Millicores by pod/container
+	if withCPU {
+		if usage, err = containerCPU(ctx, p.Clientset, watch); err != nil && !p.warned[watch.Namespace] {
+			if p.warned == nil {
+				p.warned = make(map[string]bool)
+			}
+			p.warned[watch.Namespace] = true
+			p.Log.Warn("CPU usage unknown, CPU rules cannot fire: is metrics-server installed?", "namespace", watch.Namespace, "error", err)
+		}
+	}
+
+	var observations []Observation
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if watch.Container != "" && container.Name != watch.Container {
+				continue
+			}
+			o := Observation{Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name, CPUPercent: -1}
+			if millis, ok := usage[pod.Name+"/"+container.Name]; ok {
+				limit := int64(1000)
+				if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok && cpu.MilliValue() > 0 {
+					limit = cpu.MilliValue()
+				}
+				o.CPUPercent = 100 * float64(millis) / float64(limit)
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name != container.Name {
+					continue
+				}
+				o.Restarts = status.RestartCount
+				if last := status.LastTerminationState.Terminated; last != nil && last.Reason == "OOMKilled" {
+					o.OOMKilledAt = last.FinishedAt.Time
+				}
+			}
+			observations = append(observations, o)
+		}
+	}
+	return observations, nil
+}
+
+// containerCPU reads the CPU usage of the containers a watch selects from the
+// metrics API, in millicores by pod/container
+func containerCPU(ctx context.Context, clientset kubernetes.Interface, watch api.WatchTarget) (map[string]int64, error) {
+	req := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1", "namespaces", watch.Namespace, "pods")
+	if watch.Selector != "" {
+		req = req.Param("labelSelector", watch.Selector)
+	}
+	data, err := req.DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list podMetricsList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode pod metrics: %w", err)
+	}
+	usage := make(map[string]int64)
+	for _, pod := range list.Items {
+		for _, container := range pod.Containers {
+			cpu, err := resource.ParseQuantity(container.Usage.CPU)
+			if err != nil {
+				continue
+			}
+			usage[pod.Metadata.Name+"/"+container.Name] = cpu.MilliValue()
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,172 @@
+// Package trigger waits for a condition on the monitoring of a workload before
+// a profile is taken, so that the profile catches the moment a regression
+// shows rather than the minutes after somebody noticed it.
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prometheus evaluates PromQL expressions through the HTTP API of a
+// Prometheus compatible server
+type Prometheus struct {
+	URL string // Base URL, e.g. http://prometheus.monitoring:9090
+	// Value of the Authorization header, e.g. "Bearer <token>", if required
+	Authorization string
+}
+
+// queryResponse is the body of /api/v1/query
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// series is an element of an instant vector
+type series struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"`
+}
+
+// Query evaluates an instant query. Like an alerting rule, the condition holds
+// when the query returns at least one series, or a non-zero scalar, so
+// comparisons such as rate(x[1m]) > 0.8 filter the series that hold. The
+// description names the first series and its value.
+func (p *Prometheus) Query(ctx context.Context, expr string) (bool, string, error) {
+	endpoint := strings.TrimSuffix(p.URL, "/") + "/api/v1/query?" + url.Values{"query": {expr}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if p.Authorization != "" {
+		req.Header.Set("Authorization", p.Authorization)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to query %s: %w", p.URL, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to read the response of %s: %w", p.URL, err)
+	}
+
+	var body queryResponse
+	if err := json.Unmarshal(data, &body); err != nil {
+		return false, "", fmt.Errorf("failed to query %s: %s: %s", p.URL, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if body.Status != "success" {
+		return false, "", fmt.Errorf("query %q failed: %s: %s", expr, body.ErrorType, body.Error)
+	}
+	return evaluate(body.Data.ResultType, body.Data.Result)
+}
+
+// evaluate decides whether a query result holds
+func evaluate(resultType string, result json.RawMessage) (bool, string, error) {
+	switch resultType {
+	case "vector":
+		var vector []series
+		if err := json.Unmarshal(result, &vector); err != nil {
+			return false, "", fmt.Errorf("failed to decode the query result: %w", err)
+		}
+		if len(vector) == 0 {
+			return false, "no series", nil
+		}
+		return true, describe(vector[0], len(vector)), nil
+	case "scalar":
+		var sample [2]interface{}
+		if err := json.Unmarshal(result, &sample); err != nil {
+			return false, "", fmt.Errorf("failed to decode the query result: %w", err)
+		}
+		value, _ := sample[1].(string)
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid scalar %q", value)
+		}
+		return f != 0, value, nil
+	default:
+		return false, "", fmt.Errorf("the query returns a %s, use an instant vector or a scalar", resultType)
+	}
+}
+
+// describe names a series and its value, e.g. {pod="api-0"} = 0.93
+func describe(s series, count int) string {
+	keys := make([]string, 0, len(s.Metric))
+	for key := range s.Metric {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, s.Metric[key]))
+	}
+	value, _ := s.Value[1].(string)
+	description := fmt.Sprintf("{%s} = %s", strings.Join(pairs, ", "), value)
+	if count > 1 {
+		description += fmt.Sprintf(" and %d more series", count-1)
+	}
+	return description
+}
+
+// Condition is a PromQL expression that must hold for a while, like the for
+// clause of an alerting rule, so a single spike does not fire a profile
+type Condition struct {
+	Query    string
+	For      time.Duration
+	Interval time.Duration // Time between two evaluations
+}
+
+// Wait evaluates the condition every interval until it held for its whole
+// For duration, and returns the description of the last evaluation. The first
+// evaluation must succeed, which catches typos in the query; later failures
+// are logged and restart the For duration.
+func Wait(ctx context.Context, p *Prometheus, c Condition, log *slog.Logger) (string, error) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	var since time.Time
+	for evaluations := 0; ; evaluations++ {
+		holds, description, err := p.Query(ctx, c.Query)
+		switch {
+		case err != nil && evaluations == 0:
+			return "", err
+		case err != nil:
+			log.Warn("Failed to evaluate the trigger condition", "error", err)
+			since = time.Time{}
+		case !holds:
+			if !since.IsZero() {
+				log.Info("Trigger condition no longer holds")
+			}
+			since = time.Time{}
+		case since.IsZero():
+			since = time.Now()
+			log.Info("Trigger condition holds", "value", description, "for", c.For)
+			fallthrough
+		default:
+			if time.Since(since) >= c.For {
+				return description, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package trigger
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/withlin/kubectl-pprof/pkg/api"
+)
+
+// DefaultCooldown is the time a container is left alone after its profile
+const DefaultCooldown = 15 * time.Minute
+
+// Rule fires a profile on a signal of a watched container, one of a sustained
+// CPU usage, an OOM kill or a restart loop
+type Rule struct {
+	Name       string
+	CPUPercent float64
+	For        time.Duration
+	OOM        bool
+	Restarts   int
+	Window     time.Duration
+}
+
+// ParseRule checks a rule of a watch manifest and names it after its signal
+// unless it has a name
+func ParseRule(r api.WatchRule) (Rule, error) {
+	rule := Rule{Name: r.Name, CPUPercent: r.CPUPercent, OOM: r.OOM, Restarts: r.Restarts}
+	signals := 0
+	if r.CPUPercent != 0 {
+		signals++
+	}
+	if r.OOM {
+		signals++
+	}
+	if r.Restarts != 0 {
+		signals++
+	}
+	if signals != 1 {
+		return Rule{}, fmt.Errorf("set exactly one of cpuPercent, oom and restarts")
+	}
+
+	if (r.For != "" && r.CPUPercent == 0) || (r.Window != "" && r.Restarts == 0) {
+		return Rule{}, fmt.Errorf("for goes with cpuPercent and window with restarts")
+	}
+
+	var err error
+	switch {
+	case r.CPUPercent != 0:
+		if r.CPUPercent < 0 {
+			return Rule{}, fmt.Errorf("cpuPercent must be positive")
+		}
+		if r.For != "" {
+			if rule.For, err = time.ParseDuration(r.For); err != nil || rule.For < 0 {
+				return Rule{}, fmt.Errorf("invalid for %q", r.For)
+			}
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("cpu>%g%%", r.CPUPercent)
+		}
+	case r.OOM:
+		if rule.Name == "" {
+			rule.Name = "oom"
+		}
+	default:
+		if r.Restarts < 0 {
+			return Rule{}, fmt.Errorf("restarts must be positive")
+		}
+		if rule.Window, err = time.ParseDuration(r.Window); err != nil || rule.Window <= 0 {
+			return Rule{}, fmt.Errorf("restarts need a positive window, e.g. 10m")
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("restarts>=%d/%v", r.Restarts, rule.Window)
+		}
+	}
+	return rule, nil
+}
+
+// LoadWatchSpec reads and validates a watch manifest
+func LoadWatchSpec(path string) (*api.WatchSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch file: %w", err)
+	}
+	spec := &api.WatchSpec{}
+	if err := yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse watch file %s: %w", path, err)
+	}
+	if len(spec.Watches) == 0 {
+		return nil, fmt.Errorf("watch file %s lists no watches", path)
+	}
+	if spec.Cooldown != "" {
+		if cooldown, err := time.ParseDuration(spec.Cooldown); err != nil || cooldown < 0 {
+			return nil, fmt.Errorf("invalid cooldown %q in %s", spec.Cooldown, path)
+		}
+	}
+	for i, watch := range spec.Watches {
+		if watch.Namespace == "" {
+			return nil, fmt.Errorf("watch %d: namespace is required", i+1)
+		}
+		if len(watch.Rules) == 0 {
+			return nil, fmt.Errorf("watch %d (%s): no rules", i+1, watch.Namespace)
+		}
+		for j, rule := range watch.Rules {
+			if _, err := ParseRule(rule); err != nil {
+				return nil, fmt.Errorf("watch %d (%s), rule %d: %w", i+1, watch.Namespace, j+1, err)
+			}
+		}
+	}
+	return spec, nil
+}
+
+// Observation is the state of a watched container at one poll
+type Observation struct {
+	Namespace string
+	Pod       string
+	Container string
+	// CPU usage in percent of the limit, or of a core without one; negative
+	// when the metrics API does not know it
+	CPUPercent float64
+	Restarts   int32
+	// When the last termination of the container, an OOM kill, finished
+	OOMKilledAt time.Time
+}
+
+// Fired is a rule that fired for a container
+type Fired struct {
+	Observation
+	Rule   string
+	Reason string
+}
+
+// containerState is what the watcher remembers of a container between polls
+type containerState struct {
+	restarts   int32
+	restartAt  []time.Time
+	oomKilled  time.Time
+	cpuSince   map[string]time.Time // By rule name
+	quietUntil time.Time
+}
+
+// Watcher evaluates rules over successive observations of containers. Signals
+// that predate the first observation of a container, such as an OOM kill or
+// restarts of the day before, never fire.
+type Watcher struct {
+	Cooldown   time.Duration
+	containers map[string]*containerState
+}
+
+// Observe records an observation made at now and returns the first of the
+// rules it fires. A container is not fired again within the cooldown.
+func (w *Watcher) Observe(o Observation, rules []Rule, now time.Time) (Fired, bool) {
+	if w.containers == nil {
+		w.containers = make(map[string]*containerState)
+	}
+	key := o.Namespace + "/" + o.Pod + "/" + o.Container
+	state, seen := w.containers[key]
+	if !seen {
+		state = &containerState{restarts: o.Restarts, oomKilled: o.OOMKilledAt, cpuSince: make(map[string]time.Time)}
+		w.containers[key] = state
+	}
+
+	// Restarts are counted when they are observed, the API keeps no history
+	for ; state.restarts < o.Restarts; state.restarts++ {
+		state.restartAt = append(state.restartAt, now)
+	}
+	oomKilled := o.OOMKilledAt.After(state.oomKilled)
+	state.oomKilled = o.OOMKilledAt
+
+	var (
+		fired  *Fired
+		window time.Duration
+	)
+	for _, rule := range rules {
+		reason, ok := state.evaluate(rule, o, oomKilled, now)
+		if ok && fired == nil {
+			fired = &Fired{Observation: o, Rule: rule.Name, Reason: reason}
+		}
+		window = max(window, rule.Window)
+	}
+	// Forget the restarts no rule looks back to
+	recent := state.restartAt[:0]
+	for _, at := range state.restartAt {
+		if now.Sub(at) <= window {
+			recent = append(recent, at)
+		}
+	}
+	state.restartAt = recent
+	if fired == nil || now.Before(state.quietUntil) {
+		return Fired{}, false
+	}
+	state.quietUntil = now.Add(w.Cooldown)
+	state.cpuSince = make(map[string]time.Time)
+	state.restartAt = nil
+	return *fired, true
+}
+
+// evaluate updates the state of a rule with an observation and reports
+// whether the rule holds
+func (s *containerState) evaluate(rule Rule, o Observation, oomKilled bool, now time.Time) (string, bool) {
+	switch {
+	case rule.CPUPercent > 0:
+		if o.CPUPercent < rule.CPUPercent {
+			delete(s.cpuSince, rule.Name)
+			return "", false
+		}
+		since, ok := s.cpuSince[rule.Name]
+		if !ok {
+			since = now
+			s.cpuSince[rule.Name] = now
+		}
+		if now.Sub(since) < rule.For {
+			return "", false
+		}
+		return fmt.Sprintf("CPU at %.0f%% for %v", o.CPUPercent, now.Sub(since).Round(time.Second)), true
+	case rule.OOM:
+		if !oomKilled {
+			return "", false
+		}
+		return fmt.Sprintf("OOM killed at %s", o.OOMKilledAt.Format(time.RFC3339)), true
+	default:
+		recent := 0
+		for _, at := range s.restartAt {
+			if now.Sub(at) <= rule.Window {
+				recent++
+			}
+		}
+		if recent < rule.Restarts {
+			return "", false
+		}
+		return fmt.Sprintf("%d restarts within %v", recent, rule.Window), true
+	}
+}